@@ -0,0 +1,335 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Agent is a collection pipeline wired with opinionated defaults: a Sampler
+// that runs the main collectors every 10 seconds, an AlertEngine with the
+// disk full and OOM kill alerts, and an ExporterManager every batch of
+// metrics is exported to. The exporters (e.g. the one of the prometheus
+// package, which can't be imported from here) are added by the application:
+//   agent := sysstats.DefaultAgent()
+//   agent.LogTo(log.New(os.Stderr, "", log.LstdFlags))
+//   exporter := prometheus.NewExporter()
+//   agent.Exporters.Add(exporter)
+//   http.Handle("/metrics", exporter)
+//   go http.ListenAndServe(":9100", nil)
+//   agent.Run(ctx)
+// The agent doesn't log anything by itself: its errors, alerts and changes
+// are passed to its callbacks, and LogTo sets them to log with a logger. The
+// scrapes can be authenticated with the SetAuthenticator of the exporter.
+// The active probes (Prober and DnsProber) are added to the sampler before
+// it runs:
+//   agent.Sampler.Add(prober.Collector("probe", 30*time.Second))
+//
+// Its collectors and alert rules can be changed while it runs with the
+// ConfigHandler, which requires an Authenticator.
+type Agent struct {
+	Sampler          *Sampler                        // Sampler of the collectors
+	Alerts           *AlertEngine                    // Engine of the alert rules, evaluated on every batch
+	Exporters        *ExporterManager                // Exporters every batch is exported to
+	OnAlert          func(event AlertEvent)          // Called with the alert events
+	OnChange         func(change ConfigChange)       // Called with the changes made through ConfigHandler
+	OnIdentityChange func(change HostIdentityChange) // Called when the state restored by LoadState belongs to another host
+	OnError          func(err error)                 // Called when the state can't be restored on start or a collector fails (with a *FieldError of state or of the collector)
+	StatePath        string                          // File the last-seen counters are saved to on shutdown and restored from on start (see SaveState), none if empty
+
+	mu      sync.Mutex
+	metrics map[string][]Metric
+	state   *agentState
+}
+
+// DefaultAgentInterval is the interval of the collectors of DefaultAgent.
+const DefaultAgentInterval = 10 * time.Second
+
+// DefaultAgentRules are the alert rules of DefaultAgent:
+//   - diskfull: a file system is over 90% used for 5 minutes (grouped by
+//     mount point, notified at most every hour)
+//   - oomkill: the OOM killer killed a process since the last collection
+var DefaultAgentRules = []AlertRule{
+	{
+		Name:      `diskfull`,
+		Metric:    `fs.usedper`,
+		Op:        `>`,
+		Threshold: 90,
+		For:       5 * time.Minute,
+		GroupBy:   []string{`mountpoint`},
+		RateLimit: time.Hour,
+	},
+	{
+		Name:      `oomkill`,
+		Metric:    `mem.oomkills`,
+		Op:        `>`,
+		Threshold: 0,
+		RateLimit: 5 * time.Minute,
+	},
+}
+
+// DefaultAgent returns an Agent with the collectors of the host (CPU,
+// processes, network interfaces, disks and the HostScore between snapshots),
+// the memory with the OOM kills, the load average and the usage of the file
+// systems (without the pseudo ones), every DefaultAgentInterval, with the
+// DefaultAgentRules and the exporters queueing up to 16 batches each. The
+// sampler is adaptive, so the agent backs off while the host is overloaded.
+func DefaultAgent() *Agent {
+	alerts, err := NewAlertEngine(DefaultAgentRules)
+	if err != nil {
+		// The rules are static
+		panic(err)
+	}
+	a := &Agent{Alerts: alerts, Exporters: NewExporterManager(16), metrics: map[string][]Metric{}, state: &agentState{}}
+
+	host := newAgentHostCollector(a.state)
+	mem := newAgentMemCollector(a.state)
+	fs := NewFsUsageCollector(FsUsageConfig{ExcludePseudo: true})
+	a.Sampler = NewSampler([]SamplerCollector{
+		{Name: `host`, Interval: DefaultAgentInterval, Collect: func() (interface{}, error) { return host() }},
+		{Name: `mem`, Interval: DefaultAgentInterval, Collect: func() (interface{}, error) { return mem() }},
+		{Name: `load`, Interval: DefaultAgentInterval, Collect: func() (interface{}, error) { return agentLoadMetrics() }},
+		{Name: `fs`, Interval: DefaultAgentInterval, Collect: func() (interface{}, error) {
+			fsUsages, err := fs.Collect()
+			return agentFsMetrics(fsUsages), err
+		}},
+	}, a.handle)
+	a.Sampler.SetAdaptive(AdaptiveConfig{})
+
+	return a
+}
+
+// Run starts the exporters and runs the collectors until ctx is done. Then
+// it flushes and stops the exporters (waiting up to 10 seconds). If
+// StatePath is set, the state is restored from it before the collectors run
+// (a state that can't be restored is passed to OnError) and saved to it
+// after they stop.
+func (a *Agent) Run(ctx context.Context) error {
+	if a.StatePath != `` {
+		if err := a.LoadState(a.StatePath); err != nil && !os.IsNotExist(err) {
+			a.error(&FieldError{Field: `state`, Err: err})
+		}
+	}
+	if err := a.Exporters.Start(ctx); err != nil {
+		return err
+	}
+	err := a.Sampler.Run(ctx)
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if stopErr := a.Exporters.Stop(stopCtx); err == nil {
+		err = stopErr
+	}
+	if a.StatePath != `` {
+		if saveErr := a.SaveState(a.StatePath); err == nil {
+			err = saveErr
+		}
+	}
+
+	return err
+}
+
+// Metrics returns the last metrics of every collector (sorted by collector).
+func (a *Agent) Metrics() (metrics []Metric) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	collectors := make([]string, 0, len(a.metrics))
+	for collector := range a.metrics {
+		collectors = append(collectors, collector)
+	}
+	sort.Strings(collectors)
+	metrics = []Metric{}
+	for _, collector := range collectors {
+		metrics = append(metrics, a.metrics[collector]...)
+	}
+
+	return metrics
+}
+
+// handle keeps the metrics of a run of a collector, and exports and
+// evaluates the last metrics of all the collectors. The metrics of a
+// collector that fails are dropped until it succeeds again, so they aren't
+// exported stale, and its error is passed to OnError.
+func (a *Agent) handle(sample Sample) {
+	metrics, _ := sample.Value.([]Metric)
+	if sample.Error != `` && len(metrics) == 0 {
+		a.error(&FieldError{Field: sample.Collector, Err: errors.New(sample.Error)})
+	}
+	a.mu.Lock()
+	a.metrics[sample.Collector] = metrics
+	a.mu.Unlock()
+
+	batch := a.Metrics()
+	a.Exporters.Export(batch)
+	for _, event := range a.Alerts.Evaluate(batch) {
+		if a.OnAlert != nil {
+			a.OnAlert(event)
+		}
+	}
+}
+
+// error passes err to OnError (if it's set).
+func (a *Agent) error(err error) {
+	if a.OnError != nil {
+		a.OnError(err)
+	}
+}
+
+// LogTo sets the callbacks of the agent (OnAlert, OnChange, OnIdentityChange
+// and OnError) to log the alerts, the changes and the errors with logger.
+func (a *Agent) LogTo(logger *log.Logger) {
+	a.OnAlert = func(event AlertEvent) {
+		state := `RESOLVED`
+		if len(event.Firing) > 0 {
+			state = `FIRING`
+		}
+		logger.Printf("sysstats: [%s] %s %s: %d firing, %d resolved", state, event.Rule, labelsKey(event.Group), len(event.Firing), len(event.Resolved))
+	}
+	a.OnChange = func(change ConfigChange) {
+		logger.Printf("sysstats: config changed by %s (%s): %s %s %s", change.User, change.Address, change.Action, change.Target, change.Value)
+	}
+	a.OnIdentityChange = func(change HostIdentityChange) {
+		logger.Printf("sysstats: %s belongs to another host (%s changed), its counters were reset", change.Source, strings.Join(change.Changed, `, `))
+	}
+	a.OnError = func(err error) {
+		logger.Printf("sysstats: %s", err)
+	}
+}
+
+// newAgentHostCollector returns a function that returns the metrics of the
+// host (cpu.*, procs.*, net.*, disk.*) and its saturation score (host.*)
+// between its call and the previous one, from the same snapshots. The first
+// call only takes the first snapshot (unless the previous one was restored
+// in state).
+func newAgentHostCollector(state *agentState) func() ([]Metric, error) {
+	return func() ([]Metric, error) {
+		current, err := getHostScoreSnapshot()
+		if err != nil {
+			return nil, err
+		}
+		state.mu.Lock()
+		first := state.host
+		state.host = &current
+		state.mu.Unlock()
+		if first == nil {
+			return []Metric{}, nil
+		}
+		avgStats, err := getSnapshotAvgStats(*first, current)
+		if err != nil {
+			return nil, err
+		}
+		metrics, _ := avgStats.Metrics()
+		metrics = append(metrics, getHostScore(*first, current, avgStats).Metrics()...)
+
+		return metrics, nil
+	}
+}
+
+// newAgentMemCollector returns a function that returns the memory metrics
+// (mem.* as MemInfo.ToMap plus the registered memory fields, mem.usedper)
+// and mem.oomkills, the # of OOM kills since the previous call (from the
+// oom_kill counter of /proc/vmstat, 4.13+), kept in state. The memory
+// fields whose formulas can't be calculated are skipped.
+func newAgentMemCollector(state *agentState) func() ([]Metric, error) {
+	return func() (metrics []Metric, err error) {
+		memInfo, fields, err := getMemInfoFields()
+		if _, partial := err.(MultiError); err != nil && !partial {
+			return nil, err
+		}
+		memStats := memInfo.ToMap()
+		for name, value := range fields {
+			memStats[name] = value
+		}
+		keys := make([]string, 0, len(memStats))
+		for key := range memStats {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		metrics = make([]Metric, 0, len(keys)+2)
+		for _, key := range keys {
+			metrics = append(metrics, Metric{Name: `mem.` + key, Labels: map[string]string{}, Value: float64(memStats[key])})
+		}
+		if memInfo.MemTotal > 0 && memInfo.RealFree <= memInfo.MemTotal {
+			usedPer := 100 * float64(memInfo.MemTotal-memInfo.RealFree) / float64(memInfo.MemTotal)
+			metrics = append(metrics, Metric{Name: `mem.usedper`, Labels: map[string]string{}, Value: usedPer})
+		}
+
+		if oomKills, ok := readOomKills(); ok {
+			delta := uint64(0)
+			state.mu.Lock()
+			if state.oomKills != nil {
+				delta, _ = counterDelta(*state.oomKills, oomKills)
+			}
+			state.oomKills = &oomKills
+			state.mu.Unlock()
+			metrics = append(metrics, Metric{Name: `mem.oomkills`, Labels: map[string]string{}, Value: float64(delta)})
+		}
+
+		return metrics, nil
+	}
+}
+
+// readOomKills returns the oom_kill counter of /proc/vmstat. ok is false if
+// the kernel doesn't have it.
+func readOomKills() (oomKills uint64, ok bool) {
+	content, err := readProcFile("vmstat")
+	if err != nil {
+		return 0, false
+	}
+	for len(content) > 0 {
+		var line []byte
+		line, content = nextLine(content)
+		if value := bytes.TrimPrefix(line, []byte(`oom_kill `)); len(value) < len(line) {
+			return parseUintBytes(bytes.TrimSpace(value))
+		}
+	}
+
+	return 0, false
+}
+
+// agentLoadMetrics returns the load average as the metrics load.avg1,
+// load.avg5 and load.avg15.
+func agentLoadMetrics() (metrics []Metric, err error) {
+	loadAvg, err := getLoadAvg()
+	if err != nil {
+		return nil, err
+	}
+
+	return []Metric{
+		{Name: `load.avg1`, Labels: map[string]string{}, Value: loadAvg.Avg1},
+		{Name: `load.avg5`, Labels: map[string]string{}, Value: loadAvg.Avg5},
+		{Name: `load.avg15`, Labels: map[string]string{}, Value: loadAvg.Avg15},
+	}, nil
+}
+
+// agentFsMetrics returns the usage of the file systems as the metrics
+// fs.usedper, fs.available and fs.inodesusedper, labeled with the mount
+// point and the file system type. The file systems that failed are skipped.
+func agentFsMetrics(fsUsages []FsUsage) (metrics []Metric) {
+	metrics = make([]Metric, 0, 3*len(fsUsages))
+	for _, fsUsage := range fsUsages {
+		if fsUsage.Error != `` || fsUsage.Total == 0 {
+			continue
+		}
+		labels := map[string]string{`mountpoint`: fsUsage.MountPoint, `fstype`: fsUsage.FsType}
+		metrics = append(metrics,
+			Metric{Name: `fs.usedper`, Labels: labels, Value: fsUsage.UsedPer},
+			Metric{Name: `fs.available`, Labels: labels, Value: float64(fsUsage.Available)},
+		)
+		if fsUsage.Inodes > 0 {
+			inodesUsedPer := 100 * float64(fsUsage.InodesUsed) / float64(fsUsage.Inodes)
+			metrics = append(metrics, Metric{Name: `fs.inodesusedper`, Labels: labels, Value: inodesUsedPer})
+		}
+	}
+
+	return metrics
+}
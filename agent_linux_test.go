@@ -0,0 +1,49 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestAgentOnError(t *testing.T) {
+	alerts, err := NewAlertEngine(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errs []error
+	a := &Agent{Alerts: alerts, Exporters: NewExporterManager(1), metrics: map[string][]Metric{}, OnError: func(err error) { errs = append(errs, err) }}
+
+	a.handle(Sample{Collector: `fs`, Value: []Metric{}, Error: `statfs failed`})
+	// A partial failure still has metrics
+	a.handle(Sample{Collector: `mem`, Value: []Metric{{Name: `mem.memtotal`, Value: 1}}, Error: `swap unavailable`})
+	if len(errs) != 1 {
+		t.Fatalf("Errors %v, want the one of fs", errs)
+	}
+	var fieldErr *FieldError
+	if !errors.As(errs[0], &fieldErr) || fieldErr.Field != `fs` || fieldErr.Err.Error() != `statfs failed` {
+		t.Errorf("Error %v, want the FieldError of fs", errs[0])
+	}
+}
+
+func TestAgentLogTo(t *testing.T) {
+	a := DefaultAgent()
+	if a.OnAlert != nil || a.OnChange != nil || a.OnIdentityChange != nil || a.OnError != nil {
+		t.Error("DefaultAgent has callbacks, it shouldn't log by default")
+	}
+
+	var buf bytes.Buffer
+	a.LogTo(log.New(&buf, ``, 0))
+	a.OnAlert(AlertEvent{Rule: `diskfull`, Firing: []Alert{{}}})
+	a.OnError(&FieldError{Field: `fs`, Err: errors.New(`statfs failed`)})
+	logged := buf.String()
+	for _, want := range []string{`[FIRING] diskfull`, `fs: statfs failed`} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("Log %q doesn't have %q", logged, want)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+// +build linux
+
+package sysstats
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ConfigChange represents *one* change of the configuration of a running
+// agent made through its ConfigHandler.
+type ConfigChange struct {
+	Time    time.Time `json:"time"`    // Time of the change
+	User    string    `json:"user"`    // Name of the Identity that made the change, verified by the Authenticator of the handler
+	Address string    `json:"address"` // Remote address of the request
+	Action  string    `json:"action"`  // enable, disable, interval, addrule, removerule, addmemfield or removememfield
+	Target  string    `json:"target"`  // Name of the collector, the rule or the memory field changed
+	Value   string    `json:"value"`   // New interval, rule (as JSON) or formula, empty for the other actions
+}
+
+// maxConfigBody is the max size of the bodies of the requests of the
+// ConfigHandler.
+const maxConfigBody = 64 << 10
+
+// configRule is an AlertRule as read and written by the ConfigHandler, with
+// the durations as strings (e.g. 5m).
+type configRule struct {
+	Name          string            `json:"name"`
+	Metric        string            `json:"metric"`
+	Labels        map[string]string `json:"labels"`
+	Op            string            `json:"op"`
+	Threshold     float64           `json:"threshold"`
+	For           string            `json:"for"`
+	GroupBy       []string          `json:"groupby"`
+	RateLimit     string            `json:"ratelimit"`
+	FlapThreshold int               `json:"flapthreshold"`
+	FlapWindow    string            `json:"flapwindow"`
+}
+
+// ConfigHandler returns an http.Handler that changes the configuration of
+// the agent while it runs, so it can be tuned without restarting it:
+//   GET    /collectors                  collectors with their interval, whether they are enabled and their self-metrics
+//   POST   /collectors/<name>/enable    enables a collector
+//   POST   /collectors/<name>/disable   disables a collector
+//   POST   /collectors/<name>/interval  changes the interval of a collector (interval parameter, e.g. interval=30s)
+//   GET    /rules                       alert rules
+//   POST   /rules                       adds an alert rule (JSON body, e.g. {"name": "load", "metric": "load.avg5", "op": ">", "threshold": 8, "for": "5m"})
+//   DELETE /rules/<name>                removes an alert rule
+//   GET    /memfields                   memory fields with their formulas
+//   POST   /memfields                   adds or replaces a memory field (JSON body, e.g. {"name": "appmemory", "formula": "MemTotal - MemAvailable"})
+//   DELETE /memfields/<name>            removes a memory field
+// The memory fields are the ones of the package (RegisterMemoryField), so
+// they are shared by all the agents of the process.
+//
+// Every request is authenticated by auth: the GET routes need AccessRead and
+// the ones that change the configuration AccessAdmin. A nil auth rejects all
+// the requests, so the configuration can't be changed by anyone who can reach
+// the handler:
+//   auth := &sysstats.Authenticator{Tokens: map[string]sysstats.Identity{
+//           token: {Name: "ops", Access: sysstats.AccessAdmin},
+//   }}
+//   http.Handle("/config/", http.StripPrefix("/config", agent.ConfigHandler(auth)))
+// Every change is passed to OnChange with the identity that made it (the
+// Name of its Identity).
+func (a *Agent) ConfigHandler(auth *Authenticator) http.Handler {
+	config := http.HandlerFunc(a.serveConfig)
+	read := auth.Handler(AccessRead, config)
+	admin := auth.Handler(AccessAdmin, config)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			read.ServeHTTP(w, r)
+			return
+		}
+		admin.ServeHTTP(w, r)
+	})
+}
+
+// serveConfig serves a request of the ConfigHandler.
+func (a *Agent) serveConfig(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, `/`), `/`)
+	switch {
+	case len(parts) == 1 && parts[0] == `collectors`:
+		if r.Method != http.MethodGet {
+			http.Error(w, `Method not allowed`, http.StatusMethodNotAllowed)
+			return
+		}
+		writeConfigJSON(w, a.Sampler.SelfStats())
+	case len(parts) == 3 && parts[0] == `collectors`:
+		if r.Method != http.MethodPost {
+			http.Error(w, `Method not allowed`, http.StatusMethodNotAllowed)
+			return
+		}
+		a.changeCollector(w, r, parts[1], parts[2])
+	case len(parts) == 1 && parts[0] == `rules`:
+		switch r.Method {
+		case http.MethodGet:
+			rules := a.Alerts.Rules()
+			configRules := make([]configRule, len(rules))
+			for i, rule := range rules {
+				configRules[i] = newConfigRule(rule)
+			}
+			writeConfigJSON(w, configRules)
+		case http.MethodPost:
+			a.addRule(w, r)
+		default:
+			http.Error(w, `Method not allowed`, http.StatusMethodNotAllowed)
+		}
+	case len(parts) == 2 && parts[0] == `rules`:
+		if r.Method != http.MethodDelete {
+			http.Error(w, `Method not allowed`, http.StatusMethodNotAllowed)
+			return
+		}
+		if err := a.Alerts.RemoveRule(parts[1]); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		a.audit(r, `removerule`, parts[1], ``)
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 1 && parts[0] == `memfields`:
+		switch r.Method {
+		case http.MethodGet:
+			writeConfigJSON(w, MemoryFields())
+		case http.MethodPost:
+			a.addMemoryField(w, r)
+		default:
+			http.Error(w, `Method not allowed`, http.StatusMethodNotAllowed)
+		}
+	case len(parts) == 2 && parts[0] == `memfields`:
+		if r.Method != http.MethodDelete {
+			http.Error(w, `Method not allowed`, http.StatusMethodNotAllowed)
+			return
+		}
+		name := strings.ToLower(parts[1])
+		if _, ok := MemoryFields()[name]; !ok {
+			http.Error(w, `Memory field `+name+` is not registered`, http.StatusNotFound)
+			return
+		}
+		UnregisterMemoryField(name)
+		a.audit(r, `removememfield`, name, ``)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// changeCollector enables, disables or changes the interval of the collector
+// name.
+func (a *Agent) changeCollector(w http.ResponseWriter, r *http.Request, name string, action string) {
+	var err error
+	value := ``
+	switch action {
+	case `enable`, `disable`:
+		err = a.Sampler.SetEnabled(name, action == `enable`)
+	case `interval`:
+		interval, parseErr := time.ParseDuration(r.FormValue(`interval`))
+		if parseErr != nil || interval <= 0 {
+			http.Error(w, `The interval must be a positive duration (e.g. 30s)`, http.StatusBadRequest)
+			return
+		}
+		value = interval.String()
+		err = a.Sampler.SetInterval(name, interval)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		// The only error left is an unknown collector
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	a.audit(r, action, name, value)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// addRule adds the alert rule of the body of the request.
+func (a *Agent) addRule(w http.ResponseWriter, r *http.Request) {
+	var configRule configRule
+	r.Body = http.MaxBytesReader(w, r.Body, maxConfigBody)
+	if err := json.NewDecoder(r.Body).Decode(&configRule); err != nil {
+		http.Error(w, `Invalid rule: `+err.Error(), http.StatusBadRequest)
+		return
+	}
+	rule, err := configRule.alertRule()
+	if err == nil {
+		err = a.Alerts.AddRule(rule)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	value, _ := json.Marshal(newConfigRule(rule))
+	a.audit(r, `addrule`, rule.Name, string(value))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// addMemoryField registers the memory field of the body of the request
+// ({"name": ..., "formula": ...}).
+func (a *Agent) addMemoryField(w http.ResponseWriter, r *http.Request) {
+	var field struct {
+		Name    string `json:"name"`
+		Formula string `json:"formula"`
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxConfigBody)
+	if err := json.NewDecoder(r.Body).Decode(&field); err != nil {
+		http.Error(w, `Invalid memory field: `+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := RegisterMemoryField(field.Name, field.Formula); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a.audit(r, `addmemfield`, strings.ToLower(field.Name), field.Formula)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// audit passes a change made by a request to OnChange, with the identity
+// verified by the Authenticator of the handler.
+func (a *Agent) audit(r *http.Request, action string, target string, value string) {
+	if a.OnChange == nil {
+		return
+	}
+	identity, _ := RequestIdentity(r)
+	a.OnChange(ConfigChange{
+		Time:    clockNow(),
+		User:    identity.Name,
+		Address: r.RemoteAddr,
+		Action:  action,
+		Target:  target,
+		Value:   value,
+	})
+}
+
+// writeConfigJSON writes v as the JSON response of a request.
+func writeConfigJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set(`Content-Type`, `application/json`)
+	json.NewEncoder(w).Encode(v)
+}
+
+// newConfigRule returns the configRule of an AlertRule.
+func newConfigRule(rule AlertRule) configRule {
+	return configRule{
+		Name:          rule.Name,
+		Metric:        rule.Metric,
+		Labels:        rule.Labels,
+		Op:            rule.Op,
+		Threshold:     rule.Threshold,
+		For:           rule.For.String(),
+		GroupBy:       rule.GroupBy,
+		RateLimit:     rule.RateLimit.String(),
+		FlapThreshold: rule.FlapThreshold,
+		FlapWindow:    rule.FlapWindow.String(),
+	}
+}
+
+// alertRule returns the AlertRule of a configRule. The empty durations are
+// 0 (the default).
+func (configRule configRule) alertRule() (rule AlertRule, err error) {
+	rule = AlertRule{
+		Name:          configRule.Name,
+		Metric:        configRule.Metric,
+		Labels:        configRule.Labels,
+		Op:            configRule.Op,
+		Threshold:     configRule.Threshold,
+		GroupBy:       configRule.GroupBy,
+		FlapThreshold: configRule.FlapThreshold,
+	}
+	durations := []struct {
+		value    string
+		duration *time.Duration
+	}{
+		{configRule.For, &rule.For},
+		{configRule.RateLimit, &rule.RateLimit},
+		{configRule.FlapWindow, &rule.FlapWindow},
+	}
+	for _, d := range durations {
+		if d.value == `` {
+			continue
+		}
+		if *d.duration, err = time.ParseDuration(d.value); err != nil {
+			return AlertRule{}, err
+		}
+	}
+
+	return rule, nil
+}
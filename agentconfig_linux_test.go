@@ -0,0 +1,53 @@
+// +build linux
+
+package sysstats
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConfigHandlerAudit(t *testing.T) {
+	alerts, err := NewAlertEngine(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changes []ConfigChange
+	a := &Agent{Alerts: alerts, OnChange: func(change ConfigChange) { changes = append(changes, change) }}
+	auth := &Authenticator{Tokens: map[string]Identity{
+		`readtoken`:  {Name: `scraper`, Access: AccessRead},
+		`admintoken`: {Name: `ops`, Access: AccessAdmin},
+	}}
+	h := a.ConfigHandler(auth)
+
+	post := func(token string, body string) int {
+		r := httptest.NewRequest(http.MethodPost, `/rules`, strings.NewReader(body))
+		r.Header.Set(`Authorization`, `Bearer `+token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+	rule := `{"name": "load", "metric": "load.avg5", "op": ">", "threshold": 8}`
+	if code := post(`readtoken`, rule); code != http.StatusForbidden {
+		t.Errorf("Status of a read token %d, want %d", code, http.StatusForbidden)
+	}
+	if code := post(`admintoken`, rule); code != http.StatusCreated {
+		t.Errorf("Status of an admin token %d, want %d", code, http.StatusCreated)
+	}
+	if code := post(`admintoken`, `{"name": "`+strings.Repeat(`x`, maxConfigBody)+`"}`); code != http.StatusBadRequest {
+		t.Errorf("Status of a body over the limit %d, want %d", code, http.StatusBadRequest)
+	}
+	if len(changes) != 1 || changes[0].User != `ops` || changes[0].Action != `addrule` {
+		t.Errorf("Audited changes %+v, want one addrule by ops", changes)
+	}
+
+	// Without an Authenticator nothing can be read nor changed
+	r := httptest.NewRequest(http.MethodGet, `/rules`, nil)
+	w := httptest.NewRecorder()
+	a.ConfigHandler(nil).ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Status without an Authenticator %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
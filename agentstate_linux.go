@@ -0,0 +1,146 @@
+// +build linux
+
+package sysstats
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// AgentStateMaxAge is the max age of the state restored by Agent.LoadState:
+// the first rates after a longer downtime would be the averages of all of
+// it.
+const AgentStateMaxAge = 5 * time.Minute
+
+// agentState represents the last-seen counters of the collectors of an
+// Agent, the previous snapshot of the host collector and the OOM kill
+// counter of the memory collector.
+type agentState struct {
+	mu       sync.Mutex
+	host     *Snapshot
+	oomKills *uint64
+}
+
+// agentStateFile is the content of the file of the state of an Agent.
+type agentStateFile struct {
+	BootId      string           `json:"bootid"`      // Boot the state was saved in (/proc/sys/kernel/random/boot_id)
+	Fingerprint *HostFingerprint `json:"fingerprint"` // Host the state was saved in (nil if it couldn't be read)
+	SavedAt     CollectedAt      `json:"savedat"`     // When the state was saved
+	Host        *Snapshot        `json:"host"`        // Last snapshot of the host collector
+	OomKills    *uint64          `json:"oomkills"`    // Last value of the OOM kill counter
+}
+
+// SaveState saves the last-seen counters of the collectors of the agent to
+// path (atomically), so they can be restored with LoadState after a
+// restart: then the first rates after the restart are calculated from them,
+// instead of missing a sample (and the OOM kills during the restart aren't
+// missed). Run does it on shutdown if StatePath is set.
+func (a *Agent) SaveState(path string) error {
+	file := agentStateFile{BootId: readBootId()}
+	var err error
+	if file.SavedAt, err = getCollectedAt(); err != nil {
+		return err
+	}
+	if fingerprint, err := getHostFingerprint(); err == nil {
+		file.Fingerprint = &fingerprint
+	}
+	if a.state != nil {
+		a.state.mu.Lock()
+		file.Host, file.OomKills = a.state.host, a.state.oomKills
+		a.state.mu.Unlock()
+	}
+	content, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+`.*`)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadState restores the last-seen counters of the collectors saved by
+// SaveState to path, before the agent runs. The state isn't restored (and
+// it doesn't return an error) if it was saved before the last boot, since
+// the counters restarted from 0, or more than AgentStateMaxAge ago. Nor if
+// it was saved in another host (see HostFingerprint), e.g. a VM cloned with
+// its disk (the boot id too if it was cloned running): then OnIdentityChange
+// is called, and the counters start over instead of producing rates from
+// the ones of the other host. Run does it on start if StatePath is set.
+func (a *Agent) LoadState(path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	file := agentStateFile{}
+	if err := json.Unmarshal(content, &file); err != nil {
+		return errors.New("Couldn't parse the state of " + path + ": " + err.Error())
+	}
+	if a.state == nil {
+		return nil
+	}
+
+	if file.Fingerprint != nil {
+		if current, err := getHostFingerprint(); err == nil && !file.Fingerprint.SameHost(current) {
+			if a.OnIdentityChange != nil {
+				a.OnIdentityChange(HostIdentityChange{
+					Time:     clockNow(),
+					Source:   path,
+					Previous: *file.Fingerprint,
+					Current:  current,
+					Changed:  file.Fingerprint.Changed(current),
+				})
+			}
+			return nil
+		}
+	}
+
+	now, err := getCollectedAt()
+	if err != nil {
+		return err
+	}
+	// CLOCK_BOOTTIME goes back after a reboot (and the boot ids are only
+	// compared if both are known)
+	age := time.Duration(now.Boottime - file.SavedAt.Boottime)
+	if bootId := readBootId(); age < 0 || age > AgentStateMaxAge || (bootId != `` && file.BootId != `` && bootId != file.BootId) {
+		return nil
+	}
+
+	a.state.mu.Lock()
+	defer a.state.mu.Unlock()
+	if file.Host != nil {
+		// The timestamp of the snapshot lost its monotonic clock reading:
+		// it's moved to the same point of CLOCK_MONOTONIC, which is the same
+		// for all the processes of the boot
+		host := *file.Host
+		host.Timestamp = clockNow().Add(-time.Duration(now.Monotonic - host.CollectedAt.Monotonic))
+		a.state.host = &host
+	}
+	a.state.oomKills = file.OomKills
+
+	return nil
+}
+
+// readBootId returns the id of the current boot (empty if it can't be
+// read).
+func readBootId() string {
+	bootId, _ := getProcReader().readString("sys", "kernel", "random", "boot_id")
+	return bootId
+}
@@ -0,0 +1,83 @@
+// +build linux
+
+package sysstats
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeAgentState writes the state file of an agent and returns its path.
+func writeAgentState(t *testing.T, file agentStateFile) string {
+	content, err := json.Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), `state.json`)
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadState(t *testing.T) {
+	useProcFixtures(t)
+	bootId := readBootId()
+	now, err := getCollectedAt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	oomKills := uint64(3)
+	tests := []struct {
+		name     string
+		file     agentStateFile
+		restored bool
+	}{
+		{`recent state`, agentStateFile{BootId: bootId, SavedAt: now, OomKills: &oomKills}, true},
+		{`unknown boot`, agentStateFile{SavedAt: now, OomKills: &oomKills}, true},
+		{`older than the max age`, agentStateFile{BootId: bootId, SavedAt: CollectedAt{Boottime: now.Boottime - int64(AgentStateMaxAge+time.Minute)}, OomKills: &oomKills}, false},
+		{`saved after now`, agentStateFile{BootId: bootId, SavedAt: CollectedAt{Boottime: now.Boottime + int64(time.Hour)}, OomKills: &oomKills}, false},
+		{`previous boot`, agentStateFile{BootId: `0b9e2f40-7d1c-4a3e-8f6b-5c2d1e0a9b87`, SavedAt: now, OomKills: &oomKills}, false},
+	}
+	for _, test := range tests {
+		a := &Agent{state: &agentState{}}
+		if err := a.LoadState(writeAgentState(t, test.file)); err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if restored := a.state.oomKills != nil; restored != test.restored {
+			t.Errorf("%s: restored %t, want %t", test.name, restored, test.restored)
+		}
+	}
+}
+
+func TestLoadStateOtherHost(t *testing.T) {
+	useProcFixtures(t)
+	current, err := getHostFingerprint()
+	if err != nil {
+		t.Skip("The fingerprint of the host can't be read")
+	}
+	now, err := getCollectedAt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := HostFingerprint{MachineId: current.MachineId + `0`, PrimaryMac: current.PrimaryMac + `0`, DiskSerials: []string{`other`}}
+	oomKills := uint64(3)
+	path := writeAgentState(t, agentStateFile{BootId: readBootId(), Fingerprint: &other, SavedAt: now, OomKills: &oomKills})
+
+	var changes []HostIdentityChange
+	a := &Agent{state: &agentState{}, OnIdentityChange: func(change HostIdentityChange) { changes = append(changes, change) }}
+	if err := a.LoadState(path); err != nil {
+		t.Fatal(err)
+	}
+	if a.state.oomKills != nil {
+		t.Error("The state of another host was restored")
+	}
+	if len(changes) != 1 || changes[0].Source != path || len(changes[0].Changed) == 0 {
+		t.Errorf("Identity changes %+v, want 1 of %s", changes, path)
+	}
+}
@@ -0,0 +1,383 @@
+package sysstats
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AlertRule represents a rule that fires when the values of a metric cross
+// a threshold.
+type AlertRule struct {
+	Name          string            // Unique name of the rule
+	Metric        string            // Name of the metric (e.g. cpu.total)
+	Labels        map[string]string // Labels the values must have (e.g. cpu: cpu). Empty matches all the values
+	Op            string            // Comparison of the value with the threshold (>, >=, <, <=, ==, !=)
+	Threshold     float64           // Threshold
+	For           time.Duration     // Time the condition must hold before the alert fires
+	GroupBy       []string          // Labels the alerts are grouped by into a single event (empty groups all the alerts of the rule)
+	RateLimit     time.Duration     // Min time between 2 events of the same group
+	FlapThreshold int               // Max # of state changes of an alert within FlapWindow before it's considered flapping (0 disables flap detection)
+	FlapWindow    time.Duration     // Window of the flap detection (default 10 minutes)
+}
+
+// Alert represents *one* value (metric and labels) of a rule that is firing.
+type Alert struct {
+	Labels map[string]string `json:"labels"` // Labels of the value
+	Value  float64           `json:"value"`  // Last value
+	Since  time.Time         `json:"since"`  // Time the alert started firing
+}
+
+// AlertEvent represents a notification of the alerts of *one* group of a
+// rule. An event is only generated when the set of firing alerts of the group
+// changes.
+type AlertEvent struct {
+	Rule     string            `json:"rule"`     // Name of the rule
+	Group    map[string]string `json:"group"`    // Values of the GroupBy labels of the group
+	Time     time.Time         `json:"time"`     // Time of the event
+	Firing   []Alert           `json:"firing"`   // Alerts of the group that are firing
+	Resolved []Alert           `json:"resolved"` // Alerts of the group resolved since the previous event
+}
+
+// AlertEngine evaluates a set of alert rules against batches of metrics.
+// To avoid notification storms:
+//   - The alerts are grouped (GroupBy) and an event is only generated when
+//     the set of firing alerts of a group changes.
+//   - The events of a group are rate limited (RateLimit): the changes that
+//     happen in between are merged into the next event.
+//   - An alert that changes state too often (more than FlapThreshold changes
+//     within FlapWindow) is flapping: its changes aren't notified until it
+//     changes state less often.
+//
+// The alerts can also be silenced (e.g. during maintenance windows) with
+// AddSilence.
+type AlertEngine struct {
+	mu          sync.Mutex
+	rules       []AlertRule
+	series      map[string]*alertSeries
+	groups      map[string]*alertGroup
+	silences    map[string]Silence
+	silenceFile string
+}
+
+// alertSeries is the state of *one* value of a rule.
+type alertSeries struct {
+	rule        int
+	labels      map[string]string
+	group       string
+	value       float64
+	pending     time.Time   // Time the condition started to hold (zero if it doesn't hold)
+	firing      bool        // Whether the alert is firing
+	since       time.Time   // Time the alert started firing
+	notified    bool        // Whether the alert is firing in the last event of its group
+	transitions []time.Time // State changes within the flap window
+}
+
+// alertGroup is the state of *one* group of a rule.
+type alertGroup struct {
+	rule       int
+	labels     map[string]string
+	lastNotify time.Time
+}
+
+// NewAlertEngine returns an AlertEngine for the given rules. It returns an
+// error if a rule doesn't have a name or a metric, has an unknown operator or
+// has the name of another rule.
+func NewAlertEngine(rules []AlertRule) (*AlertEngine, error) {
+	rules = append([]AlertRule{}, rules...)
+	names := map[string]bool{}
+	for i, rule := range rules {
+		rule, err := checkAlertRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		if names[rule.Name] {
+			return nil, errors.New("Alert rule " + rule.Name + " is duplicated")
+		}
+		names[rule.Name] = true
+		rules[i] = rule
+	}
+
+	return &AlertEngine{
+		rules:    rules,
+		series:   map[string]*alertSeries{},
+		groups:   map[string]*alertGroup{},
+		silences: map[string]Silence{},
+	}, nil
+}
+
+// checkAlertRule checks a rule and returns it with the defaults set.
+func checkAlertRule(rule AlertRule) (AlertRule, error) {
+	if rule.Name == `` || rule.Metric == `` {
+		return rule, errors.New("Alert rules need a name and a metric")
+	}
+	if _, ok := alertOps[rule.Op]; !ok {
+		return rule, errors.New("Alert rule " + rule.Name + " has an unknown operator: " + rule.Op)
+	}
+	if rule.FlapWindow <= 0 {
+		rule.FlapWindow = 10 * time.Minute
+	}
+
+	return rule, nil
+}
+
+// AddRule adds a rule to the engine (e.g. while it's running). It returns an
+// error if the rule isn't valid (as in NewAlertEngine) or the engine already
+// has a rule with its name.
+func (e *AlertEngine) AddRule(rule AlertRule) error {
+	rule, err := checkAlertRule(rule)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.ruleIndex(rule.Name) >= 0 {
+		return errors.New("Alert rule " + rule.Name + " is duplicated")
+	}
+	e.rules = append(e.rules, rule)
+
+	return nil
+}
+
+// RemoveRule removes a rule from the engine, with the state of its alerts
+// (the alerts firing aren't notified as resolved). It returns an error if
+// the engine doesn't have the rule.
+func (e *AlertEngine) RemoveRule(name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	i := e.ruleIndex(name)
+	if i < 0 {
+		return errors.New("Alert rule " + name + " doesn't exist")
+	}
+	e.rules = append(e.rules[:i:i], e.rules[i+1:]...)
+	// The state references the rules by index
+	for key, s := range e.series {
+		if s.rule == i {
+			delete(e.series, key)
+		} else if s.rule > i {
+			s.rule--
+		}
+	}
+	for key, group := range e.groups {
+		if group.rule == i {
+			delete(e.groups, key)
+		} else if group.rule > i {
+			group.rule--
+		}
+	}
+
+	return nil
+}
+
+// Rules returns the rules of the engine.
+func (e *AlertEngine) Rules() (rules []AlertRule) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]AlertRule{}, e.rules...)
+}
+
+// ruleIndex returns the index of the rule name (-1 if the engine doesn't
+// have it). e.mu must be held.
+func (e *AlertEngine) ruleIndex(name string) int {
+	for i, rule := range e.rules {
+		if rule.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+var alertOps = map[string]func(value float64, threshold float64) bool{
+	`>`:  func(value float64, threshold float64) bool { return value > threshold },
+	`>=`: func(value float64, threshold float64) bool { return value >= threshold },
+	`<`:  func(value float64, threshold float64) bool { return value < threshold },
+	`<=`: func(value float64, threshold float64) bool { return value <= threshold },
+	`==`: func(value float64, threshold float64) bool { return value == threshold },
+	`!=`: func(value float64, threshold float64) bool { return value != threshold },
+}
+
+// Evaluate evaluates the rules against a batch of metrics and returns the
+// events to notify (sorted by rule and group). The values of a rule missing
+// from the batch are considered resolved.
+func (e *AlertEngine) Evaluate(metrics []Metric) (events []AlertEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.evaluate(metrics, clockNow())
+}
+
+// evaluate evaluates the rules at the time now.
+func (e *AlertEngine) evaluate(metrics []Metric, now time.Time) (events []AlertEvent) {
+	seen := map[string]bool{}
+	for i, rule := range e.rules {
+		for _, metric := range metrics {
+			if metric.Name != rule.Metric || !matchLabels(metric.Labels, rule.Labels) {
+				continue
+			}
+			key := rule.Name + `{` + labelsKey(metric.Labels) + `}`
+			seen[key] = true
+			s, ok := e.series[key]
+			if !ok {
+				s = &alertSeries{rule: i, labels: metric.Labels, group: e.group(i, metric.Labels)}
+				e.series[key] = s
+			}
+			s.value = metric.Value
+			e.update(s, alertOps[rule.Op](metric.Value, rule.Threshold), now)
+		}
+	}
+	for key, s := range e.series {
+		if !seen[key] {
+			e.update(s, false, now)
+		}
+	}
+
+	e.expireSilences(now)
+
+	// Generate the events of the groups whose notified alerts changed
+	groupKeys := make([]string, 0, len(e.groups))
+	for key := range e.groups {
+		groupKeys = append(groupKeys, key)
+	}
+	sort.Strings(groupKeys)
+	events = []AlertEvent{}
+	for _, groupKey := range groupKeys {
+		group := e.groups[groupKey]
+		rule := e.rules[group.rule]
+		if !group.lastNotify.IsZero() && now.Sub(group.lastNotify) < rule.RateLimit {
+			continue
+		}
+
+		event := AlertEvent{Rule: rule.Name, Group: group.labels, Time: now, Firing: []Alert{}, Resolved: []Alert{}}
+		changed := false
+		seriesKeys := []string{}
+		for key, s := range e.series {
+			if s.group == groupKey {
+				seriesKeys = append(seriesKeys, key)
+			}
+		}
+		sort.Strings(seriesKeys)
+		for _, key := range seriesKeys {
+			s := e.series[key]
+			if e.silenced(rule.Name, s.labels, now) {
+				// It's notified when the silence ends
+				if s.notified {
+					event.Firing = append(event.Firing, Alert{Labels: s.labels, Value: s.value, Since: s.since})
+				}
+				continue
+			}
+			firing := s.notified
+			if !s.flapping(rule) {
+				firing = s.firing
+			}
+			if firing != s.notified {
+				changed = true
+			}
+			alert := Alert{Labels: s.labels, Value: s.value, Since: s.since}
+			if firing {
+				event.Firing = append(event.Firing, alert)
+			} else if s.notified {
+				event.Resolved = append(event.Resolved, alert)
+			}
+			s.notified = firing
+		}
+		if changed {
+			group.lastNotify = now
+			events = append(events, event)
+		}
+	}
+
+	// Forget the resolved alerts that aren't needed for the flap detection
+	for key, s := range e.series {
+		if !seen[key] && !s.firing && !s.notified && len(s.transitions) == 0 {
+			delete(e.series, key)
+		}
+	}
+
+	return events
+}
+
+// update updates the state of an alert with the result of its condition.
+func (e *AlertEngine) update(s *alertSeries, condition bool, now time.Time) {
+	rule := e.rules[s.rule]
+	firing := s.firing
+	if condition {
+		if s.pending.IsZero() {
+			s.pending = now
+		}
+		if now.Sub(s.pending) >= rule.For {
+			firing = true
+		}
+	} else {
+		s.pending = time.Time{}
+		firing = false
+	}
+
+	if firing != s.firing {
+		s.firing = firing
+		if firing {
+			s.since = now
+		}
+		if rule.FlapThreshold > 0 {
+			s.transitions = append(s.transitions, now)
+		}
+	}
+	// Only the state changes within the flap window count
+	for len(s.transitions) > 0 && now.Sub(s.transitions[0]) > rule.FlapWindow {
+		s.transitions = s.transitions[1:]
+	}
+}
+
+// flapping returns true if the alert changed state more than FlapThreshold
+// times within the flap window.
+func (s *alertSeries) flapping(rule AlertRule) bool {
+	return rule.FlapThreshold > 0 && len(s.transitions) > rule.FlapThreshold
+}
+
+// group returns the key of the group of the values of rule with labels,
+// adding the group if it doesn't exist.
+func (e *AlertEngine) group(rule int, labels map[string]string) string {
+	groupLabels := map[string]string{}
+	for _, label := range e.rules[rule].GroupBy {
+		groupLabels[label] = labels[label]
+	}
+	key := e.rules[rule].Name + `{` + labelsKey(groupLabels) + `}`
+	if _, ok := e.groups[key]; !ok {
+		e.groups[key] = &alertGroup{rule: rule, labels: groupLabels}
+	}
+
+	return key
+}
+
+// matchLabels returns true if labels has all the matchers.
+func matchLabels(labels map[string]string, matchers map[string]string) bool {
+	for name, value := range matchers {
+		if labels[name] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// labelsKey returns the labels as a string sorted by name:
+//   name1=value1,name2=value2
+func labelsKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+`=`+labels[name])
+	}
+
+	return strings.Join(pairs, `,`)
+}
@@ -0,0 +1,123 @@
+// +build linux
+
+package sysstats
+
+import (
+	"errors"
+	"syscall"
+)
+
+// AuditStats represents the status of the kernel audit subsystem of a linux
+// system. The events are lost when the backlog is full (auditd can't keep
+// up) or the rate limit is exceeded, so a growing Lost means the audit trail
+// has gaps.
+type AuditStats struct {
+	Enabled               bool   `json:"enabled"`               // Whether auditing is enabled
+	Locked                bool   `json:"locked"`                // Whether the configuration is locked (it can't be changed until reboot)
+	Failure               string `json:"failure"`               // What the kernel does on critical errors (silent, printk or panic)
+	Pid                   uint32 `json:"pid"`                   // Pid of the audit daemon (0 if there isn't one)
+	RateLimit             uint32 `json:"ratelimit"`             // Max # of messages per second (0 means no limit)
+	BacklogLimit          uint32 `json:"backloglimit"`          // Max # of messages waiting for the audit daemon
+	Backlog               uint32 `json:"backlog"`               // # of messages waiting for the audit daemon
+	Lost                  uint32 `json:"lost"`                  // # of messages lost (backlog full or rate limit exceeded) since boot
+	BacklogWaitTime       uint32 `json:"backlogwaittime"`       // Time a process waits when the backlog is full (jiffies)
+	BacklogWaitTimeActual uint32 `json:"backlogwaittimeactual"` // Time the processes have waited because the backlog was full (jiffies, since 5.9)
+}
+
+const (
+	// netlinkAudit is NETLINK_AUDIT (see linux/netlink.h)
+	netlinkAudit = 9
+	// auditGet is AUDIT_GET, the request of the status (see linux/audit.h)
+	auditGet = 1000
+	// auditStatusMinLen is the size of the first 8 fields of struct
+	// audit_status, that all the kernels have
+	auditStatusMinLen = 32
+)
+
+// auditFailures are the names of the failure modes of the audit subsystem.
+var auditFailures = []string{`silent`, `printk`, `panic`}
+
+// getAuditStats gets the status of the audit subsystem of a linux system
+// sending an AUDIT_GET request through the audit netlink socket (it needs
+// CAP_AUDIT_CONTROL, or CAP_AUDIT_READ on some kernels).
+func getAuditStats() (auditStats AuditStats, err error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, netlinkAudit)
+	if err != nil {
+		return AuditStats{}, err
+	}
+	defer syscall.Close(fd)
+
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return AuditStats{}, err
+	}
+
+	// struct nlmsghdr without payload
+	req := make([]byte, syscall.NLMSG_HDRLEN)
+	nativeEndian.PutUint32(req[0:4], uint32(len(req)))
+	nativeEndian.PutUint16(req[4:6], auditGet)
+	nativeEndian.PutUint16(req[6:8], syscall.NLM_F_REQUEST)
+	nativeEndian.PutUint32(req[8:12], 1)
+
+	if err := syscall.Sendto(fd, req, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return AuditStats{}, err
+	}
+
+	buf := make([]byte, 8192)
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return AuditStats{}, err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return AuditStats{}, err
+		}
+		for _, msg := range msgs {
+			switch msg.Header.Type {
+			case auditGet:
+				return parseAuditStatus(msg.Data)
+			case syscall.NLMSG_ERROR:
+				// struct nlmsgerr: the error (negative errno) is 0 for the acks
+				if len(msg.Data) >= 4 {
+					if errno := int32(nativeEndian.Uint32(msg.Data[0:4])); errno != 0 {
+						return AuditStats{}, syscall.Errno(-errno)
+					}
+				}
+			}
+		}
+	}
+}
+
+// parseAuditStatus parses a struct audit_status (see linux/audit.h): mask,
+// enabled, failure, pid, rate_limit, backlog_limit, lost, backlog,
+// version/feature_bitmap, backlog_wait_time and backlog_wait_time_actual.
+// The last fields are missing on the older kernels.
+func parseAuditStatus(data []byte) (auditStats AuditStats, err error) {
+	if len(data) < auditStatusMinLen {
+		return AuditStats{}, errors.New("The audit status is too short")
+	}
+
+	field := func(i int) uint32 {
+		if len(data) < (i+1)*4 {
+			return 0
+		}
+		return nativeEndian.Uint32(data[i*4:])
+	}
+	enabled := field(1)
+	auditStats = AuditStats{
+		Enabled:               enabled != 0,
+		Locked:                enabled == 2,
+		Pid:                   field(3),
+		RateLimit:             field(4),
+		BacklogLimit:          field(5),
+		Lost:                  field(6),
+		Backlog:               field(7),
+		BacklogWaitTime:       field(9),
+		BacklogWaitTimeActual: field(10),
+	}
+	if failure := field(2); int(failure) < len(auditFailures) {
+		auditStats.Failure = auditFailures[failure]
+	}
+
+	return auditStats, nil
+}
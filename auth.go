@@ -0,0 +1,136 @@
+package sysstats
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Access represents what an identity can do with the routes of the HTTP
+// handlers of the package.
+type Access int
+
+const (
+	AccessRead  Access = iota + 1 // Read the metrics and the configuration (e.g. /metrics, GET /collectors)
+	AccessAdmin                   // Also change the configuration (e.g. POST /collectors/<name>/disable)
+)
+
+// String returns the access as a string (read or admin).
+func (access Access) String() string {
+	switch access {
+	case AccessRead:
+		return `read`
+	case AccessAdmin:
+		return `admin`
+	}
+
+	return `none`
+}
+
+// Identity represents *one* identity allowed by an Authenticator.
+type Identity struct {
+	Name   string // Name of the identity (the one audited for the changes it makes), the identities without a name aren't allowed
+	Access Access // What the identity can do
+}
+
+// Authenticator authenticates the requests of the HTTP handlers of the
+// package (the Prometheus exporter and the ConfigHandler of the agent) with
+// bearer tokens or client certificates (mTLS), and authorizes them per
+// route: the read-only routes need AccessRead and the ones that change the
+// configuration AccessAdmin.
+//
+// The client certificates must be verified by the http.Server, with a
+// tls.Config whose ClientCAs are the allowed CAs and whose ClientAuth is
+// tls.VerifyClientCertIfGiven (to allow tokens too) or
+// tls.RequireAndVerifyClientCert. The identity of a certificate is the
+// common name of its subject, and only the ones in ClientCerts are allowed.
+type Authenticator struct {
+	Tokens      map[string]Identity // Bearer tokens (Authorization: Bearer <token>) and their identities, the empty ones are ignored
+	ClientCerts map[string]Access   // Common names of the verified client certificates and their access
+}
+
+// identityKey is the key of the Identity in the context of an authenticated
+// request.
+type identityKey struct{}
+
+// Authenticate returns the identity of the request, from its bearer token or
+// its verified client certificate. It returns false if the request doesn't
+// have any or they aren't allowed.
+func (auth *Authenticator) Authenticate(r *http.Request) (Identity, bool) {
+	if header := r.Header.Get(`Authorization`); len(header) > 7 && strings.EqualFold(header[:7], `bearer `) {
+		token := []byte(strings.TrimSpace(header[7:]))
+		if len(token) == 0 {
+			return Identity{}, false
+		}
+		// Compare with every token in constant time, so the time doesn't
+		// leak how much of a token matches. The empty tokens of the
+		// configuration are ignored, they would match any empty header
+		found := false
+		var identity Identity
+		for allowed, allowedIdentity := range auth.Tokens {
+			if allowed == `` {
+				continue
+			}
+			if subtle.ConstantTimeCompare(token, []byte(allowed)) == 1 && !found {
+				found = true
+				identity = allowedIdentity
+			}
+		}
+		return identity, found && identity.Name != ``
+	}
+
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		name := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		if access, ok := auth.ClientCerts[name]; ok && name != `` {
+			return Identity{Name: name, Access: access}, true
+		}
+	}
+
+	return Identity{}, false
+}
+
+// Handler returns h wrapped with the authentication of the requests, which
+// must have at least access. The requests that aren't authenticated get a
+// 401 (Unauthorized) and the ones without enough access a 403 (Forbidden).
+// The identity of the requests passed to h is returned by RequestIdentity.
+//
+// A nil Authenticator rejects all the requests.
+func (auth *Authenticator) Handler(access Access, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := auth.authorize(w, r, access)
+		if !ok {
+			return
+		}
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, identity)))
+	})
+}
+
+// authorize returns the identity of the request if it has at least access.
+// Otherwise it writes the error response and returns false.
+func (auth *Authenticator) authorize(w http.ResponseWriter, r *http.Request, access Access) (Identity, bool) {
+	var identity Identity
+	ok := false
+	if auth != nil {
+		identity, ok = auth.Authenticate(r)
+	}
+	if !ok {
+		w.Header().Set(`WWW-Authenticate`, `Bearer realm="sysstats"`)
+		http.Error(w, `Unauthorized`, http.StatusUnauthorized)
+		return Identity{}, false
+	}
+	if identity.Access < access {
+		http.Error(w, `Forbidden`, http.StatusForbidden)
+		return Identity{}, false
+	}
+
+	return identity, true
+}
+
+// RequestIdentity returns the identity of a request authenticated by the
+// Handler of an Authenticator. It returns false if the request wasn't
+// authenticated.
+func RequestIdentity(r *http.Request) (Identity, bool) {
+	identity, ok := r.Context().Value(identityKey{}).(Identity)
+	return identity, ok
+}
@@ -0,0 +1,75 @@
+package sysstats
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthenticatorHandler(t *testing.T) {
+	auth := &Authenticator{
+		Tokens: map[string]Identity{
+			`readtoken`:  {Name: `scraper`, Access: AccessRead},
+			`admintoken`: {Name: `ops`, Access: AccessAdmin},
+			// A misconfigured empty token
+			``: {Name: `empty`, Access: AccessAdmin},
+		},
+		ClientCerts: map[string]Access{`collector.example.com`: AccessRead},
+	}
+	var served Identity
+	h := auth.Handler(AccessAdmin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served, _ = RequestIdentity(r)
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		cert   string
+		status int
+	}{
+		{`no credentials`, ``, ``, http.StatusUnauthorized},
+		{`unknown token`, `Bearer nope`, ``, http.StatusUnauthorized},
+		{`empty token`, `Bearer `, ``, http.StatusUnauthorized},
+		{`blank token`, `Bearer    `, ``, http.StatusUnauthorized},
+		{`read token`, `Bearer readtoken`, ``, http.StatusForbidden},
+		{`admin token`, `Bearer admintoken`, ``, http.StatusOK},
+		{`basic auth`, `Basic b3BzOmFkbWludG9rZW4=`, ``, http.StatusUnauthorized},
+		{`read certificate`, ``, `collector.example.com`, http.StatusForbidden},
+		{`unknown certificate`, ``, `other.example.com`, http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, `/`, nil)
+		if test.header != `` {
+			r.Header.Set(`Authorization`, test.header)
+		}
+		if test.cert != `` {
+			cert := &x509.Certificate{Subject: pkix.Name{CommonName: test.cert}}
+			r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Errorf("%s: status %d, want %d", test.name, w.Code, test.status)
+		}
+	}
+	if served.Name != `ops` {
+		t.Errorf("Identity of the request %q, want ops", served.Name)
+	}
+}
+
+func TestAuthenticatorNil(t *testing.T) {
+	var auth *Authenticator
+	h := auth.Handler(AccessRead, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Request served without an Authenticator")
+	}))
+	r := httptest.NewRequest(http.MethodGet, `/`, nil)
+	r.Header.Set(`Authorization`, `Bearer token`)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
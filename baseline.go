@@ -0,0 +1,258 @@
+package sysstats
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Seasons of the baselines
+const (
+	SeasonDaily  = 24 * time.Hour     // The metrics have the same pattern every day
+	SeasonWeekly = 7 * 24 * time.Hour // The metrics have the same pattern every week (e.g. quiet weekends)
+)
+
+// BaselineConfig represents the configuration of the seasonal baselines of
+// the metrics.
+type BaselineConfig struct {
+	Season     time.Duration // Period of the pattern of the metrics: SeasonDaily or SeasonWeekly (default SeasonDaily)
+	Slot       time.Duration // Time of the season each baseline covers (default 1 hour)
+	Alpha      float64       // Weight of the last value in the baseline of its slot (default 0.1)
+	MinSamples uint64        // # of values a slot needs before its deviations are scored (default 5)
+}
+
+// Deviation represents the deviation of *one* value of a metric from the
+// baseline of its slot. The score is the # of standard deviations the value
+// is from the expected value (negative if it's below), so a rule like
+// cpu.total.deviation > 3 only fires for the usage that is unusual for the
+// time of the day (or of the week).
+type Deviation struct {
+	Name     string            `json:"name"`     // Name of the metric
+	Labels   map[string]string `json:"labels"`   // Labels of the value
+	Value    float64           `json:"value"`    // Value
+	Expected float64           `json:"expected"` // Expected value (average of the slot)
+	StdDev   float64           `json:"stddev"`   // Standard deviation of the slot
+	Score    float64           `json:"score"`    // # of standard deviations of the value from the expected value
+	Learning bool              `json:"learning"` // Whether the slot doesn't have MinSamples values yet (the score is 0)
+}
+
+// Baseline learns the typical values of the metrics for each slot of a
+// season (e.g. each hour of the day) with exponentially weighted averages
+// and scores how much the new values deviate from them. The slots are got
+// from the local time of the values.
+type Baseline struct {
+	config BaselineConfig
+	mu     sync.Mutex
+	series map[string]*baselineSeries
+}
+
+// baselineSeries is the baseline of *one* value (metric and labels).
+type baselineSeries struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+	Slots  []baselineSlot    `json:"slots"`
+}
+
+// baselineSlot is the baseline of *one* slot of a value.
+type baselineSlot struct {
+	Samples  uint64  `json:"samples"`
+	Mean     float64 `json:"mean"`
+	Variance float64 `json:"variance"`
+}
+
+// minStdDevRatio is the min standard deviation of a slot relative to its
+// expected value, so the scores of the values that are almost constant
+// aren't huge when they change a bit.
+const minStdDevRatio = 0.01
+
+// minStdDev is the min standard deviation of a slot (for the values that are
+// almost always 0).
+const minStdDev = 0.001
+
+// NewBaseline returns a Baseline for the given configuration. It returns an
+// error if the slot doesn't divide the season.
+func NewBaseline(config BaselineConfig) (*Baseline, error) {
+	if config.Season <= 0 {
+		config.Season = SeasonDaily
+	}
+	if config.Slot <= 0 {
+		config.Slot = time.Hour
+	}
+	if config.Alpha <= 0 || config.Alpha > 1 {
+		config.Alpha = 0.1
+	}
+	if config.MinSamples == 0 {
+		config.MinSamples = 5
+	}
+	if config.Slot > config.Season || config.Season%config.Slot != 0 {
+		return nil, errors.New("The baseline slot should divide the season")
+	}
+
+	return &Baseline{config: config, series: map[string]*baselineSeries{}}, nil
+}
+
+// Observe scores the deviations of a batch of metrics from their baselines
+// and then learns the values. The deviations are sorted by name and labels.
+func (b *Baseline) Observe(metrics []Metric) (deviations []Deviation) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.observe(metrics, clockNow())
+}
+
+// observe scores and learns the metrics at the time now.
+func (b *Baseline) observe(metrics []Metric, now time.Time) (deviations []Deviation) {
+	slot := b.slot(now)
+	deviations = make([]Deviation, 0, len(metrics))
+	for _, metric := range metrics {
+		key := metric.Name + `{` + labelsKey(metric.Labels) + `}`
+		s, ok := b.series[key]
+		if !ok {
+			s = &baselineSeries{Name: metric.Name, Labels: metric.Labels, Slots: make([]baselineSlot, b.config.Season/b.config.Slot)}
+			b.series[key] = s
+		}
+
+		deviations = append(deviations, s.Slots[slot].deviation(metric, b.config.MinSamples))
+		s.Slots[slot].learn(metric.Value, b.config.Alpha)
+	}
+	sort.SliceStable(deviations, func(i, j int) bool {
+		if deviations[i].Name != deviations[j].Name {
+			return deviations[i].Name < deviations[j].Name
+		}
+		return labelsKey(deviations[i].Labels) < labelsKey(deviations[j].Labels)
+	})
+
+	return deviations
+}
+
+// slot returns the slot of the season of the time now.
+func (b *Baseline) slot(now time.Time) int {
+	sinceMidnight := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute +
+		time.Duration(now.Second())*time.Second
+	sinceSeason := sinceMidnight
+	if b.config.Season > SeasonDaily {
+		sinceSeason += time.Duration(now.Weekday()) * SeasonDaily
+	}
+
+	return int((sinceSeason % b.config.Season) / b.config.Slot)
+}
+
+// deviation returns the deviation of the value of a metric from the slot.
+func (slot baselineSlot) deviation(metric Metric, minSamples uint64) Deviation {
+	deviation := Deviation{Name: metric.Name, Labels: metric.Labels, Value: metric.Value, Expected: slot.Mean}
+	deviation.StdDev = math.Sqrt(slot.Variance)
+	if slot.Samples < minSamples {
+		deviation.Learning = true
+		return deviation
+	}
+
+	stdDev := math.Max(deviation.StdDev, math.Max(minStdDevRatio*math.Abs(slot.Mean), minStdDev))
+	deviation.Score = (metric.Value - slot.Mean) / stdDev
+
+	return deviation
+}
+
+// learn adds a value to the exponentially weighted average and variance of
+// the slot. The first values are weighted as a plain average, so the
+// baseline doesn't depend on the first value.
+func (slot *baselineSlot) learn(value float64, alpha float64) {
+	slot.Samples++
+	if weight := 1 / float64(slot.Samples); weight > alpha {
+		alpha = weight
+	}
+	diff := value - slot.Mean
+	incr := alpha * diff
+	slot.Mean += incr
+	slot.Variance = (1 - alpha) * (slot.Variance + diff*incr)
+}
+
+// DeviationMetrics returns the scores of the deviations as derived metrics,
+// named as the metric with the .deviation suffix (e.g.
+// cpu.total.deviation), so they can be exported and evaluated by the
+// alerting rules. The values that are still learning are skipped.
+func DeviationMetrics(deviations []Deviation) (metrics []Metric) {
+	metrics = make([]Metric, 0, len(deviations))
+	for _, deviation := range deviations {
+		if deviation.Learning {
+			continue
+		}
+		metrics = append(metrics, Metric{Name: deviation.Name + `.deviation`, Labels: deviation.Labels, Value: deviation.Score, Derived: true})
+	}
+
+	return metrics
+}
+
+// baselineFile is the content of the file of the baselines.
+type baselineFile struct {
+	Season time.Duration     `json:"season"`
+	Slot   time.Duration     `json:"slot"`
+	Series []*baselineSeries `json:"series"`
+}
+
+// Save saves the baselines to path, so they can be loaded (with Load) after
+// a restart of the agent instead of learning them again.
+func (b *Baseline) Save(path string) error {
+	b.mu.Lock()
+	file := baselineFile{Season: b.config.Season, Slot: b.config.Slot, Series: make([]*baselineSeries, 0, len(b.series))}
+	for _, s := range b.series {
+		file.Series = append(file.Series, s)
+	}
+	content, err := json.Marshal(file)
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+`.*`)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load loads the baselines saved by Save to path, replacing the learned
+// ones. It returns an error if they were saved with another season or slot.
+func (b *Baseline) Load(path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	file := baselineFile{}
+	if err := json.Unmarshal(content, &file); err != nil {
+		return errors.New("Couldn't parse the baselines of " + path + ": " + err.Error())
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if file.Season != b.config.Season || file.Slot != b.config.Slot {
+		return errors.New("The baselines of " + path + " have another season or slot")
+	}
+	slots := int(b.config.Season / b.config.Slot)
+	series := make(map[string]*baselineSeries, len(file.Series))
+	for _, s := range file.Series {
+		if s == nil || len(s.Slots) != slots {
+			return errors.New("The baselines of " + path + " have another season or slot")
+		}
+		series[s.Name+`{`+labelsKey(s.Labels)+`}`] = s
+	}
+	b.series = series
+
+	return nil
+}
@@ -0,0 +1,77 @@
+// +build linux
+
+package sysstats
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// BlockQueue represents the I/O scheduler and request queue parameters of
+// *one* block device.
+type BlockQueue struct {
+	Device            string   `json:"device"`            // Block device name (sda, nvme0n1...)
+	Scheduler         string   `json:"scheduler"`         // Active I/O scheduler (mq-deadline, bfq, kyber, none...)
+	Schedulers        []string `json:"schedulers"`        // Available I/O schedulers
+	NrRequests        uint64   `json:"nrrequests"`        // Max # of requests queued in the scheduler
+	ReadAheadKb       uint64   `json:"readaheadkb"`       // Read-ahead size in KiB
+	Rotational        bool     `json:"rotational"`        // Whether the device is rotational (HDD)
+	MaxSectorsKb      uint64   `json:"maxsectorskb"`      // Max size of a request in KiB
+	LogicalBlockSize  uint64   `json:"logicalblocksize"`  // Logical block size in bytes
+	PhysicalBlockSize uint64   `json:"physicalblocksize"` // Physical block size in bytes
+	WriteCache        string   `json:"writecache"`        // Write cache mode (write back or write through)
+	QueueDepth        uint64   `json:"queuedepth"`        // Device queue depth (only SCSI devices, 0 if not available)
+}
+
+// getBlockQueues gets the I/O scheduler and request queue parameters of the
+// block devices of a linux system from /sys/block/<device>/queue and
+// /sys/block/<device>/device/queue_depth.
+func getBlockQueues() (blockQueues []BlockQueue, err error) {
+	deviceDirs, err := filepath.Glob("/sys/block/*")
+	if err != nil {
+		return nil, err
+	}
+
+	blockQueues = make([]BlockQueue, 0, len(deviceDirs))
+	for _, deviceDir := range deviceDirs {
+		queueDir := filepath.Join(deviceDir, "queue")
+		if !fileExists(queueDir) {
+			continue
+		}
+		blockQueue := BlockQueue{Device: filepath.Base(deviceDir), Schedulers: []string{}}
+
+		// The scheduler file has the active scheduler between brackets:
+		//   mq-deadline kyber [bfq] none
+		if schedulers, err := readStringFile(filepath.Join(queueDir, "scheduler")); err == nil {
+			for _, scheduler := range strings.Fields(schedulers) {
+				if strings.HasPrefix(scheduler, `[`) && strings.HasSuffix(scheduler, `]`) {
+					scheduler = strings.Trim(scheduler, `[]`)
+					blockQueue.Scheduler = scheduler
+				}
+				blockQueue.Schedulers = append(blockQueue.Schedulers, scheduler)
+			}
+			// Devices without scheduler only have "none"
+			if blockQueue.Scheduler == `` && len(blockQueue.Schedulers) == 1 {
+				blockQueue.Scheduler = blockQueue.Schedulers[0]
+			}
+		}
+
+		for file, value := range map[string]*uint64{
+			"nr_requests":         &blockQueue.NrRequests,
+			"read_ahead_kb":       &blockQueue.ReadAheadKb,
+			"max_sectors_kb":      &blockQueue.MaxSectorsKb,
+			"logical_block_size":  &blockQueue.LogicalBlockSize,
+			"physical_block_size": &blockQueue.PhysicalBlockSize,
+		} {
+			*value, _ = readUintFile(filepath.Join(queueDir, file))
+		}
+		rotational, _ := readUintFile(filepath.Join(queueDir, "rotational"))
+		blockQueue.Rotational = rotational == 1
+		blockQueue.WriteCache, _ = readStringFile(filepath.Join(queueDir, "write_cache"))
+		blockQueue.QueueDepth, _ = readUintFile(filepath.Join(deviceDir, "device", "queue_depth"))
+
+		blockQueues = append(blockQueues, blockQueue)
+	}
+
+	return blockQueues, nil
+}
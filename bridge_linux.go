@@ -0,0 +1,157 @@
+// +build linux
+
+package sysstats
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+// BridgeVlanStats represents the traffic counters of *one* VLAN of a bridge
+// or bridge port.
+type BridgeVlanStats struct {
+	Vid       uint64 `json:"vid"`       // VLAN id
+	RxBytes   uint64 `json:"rxbytes"`   // # of bytes received
+	RxPackets uint64 `json:"rxpackets"` // # of packets received
+	TxBytes   uint64 `json:"txbytes"`   // # of bytes transmitted
+	TxPackets uint64 `json:"txpackets"` // # of packets transmitted
+}
+
+// BridgePort represents *one* port of a bridge.
+type BridgePort struct {
+	Name   string            `json:"name"`   // Name of the interface attached to the bridge
+	PortNo uint64            `json:"portno"` // Port number
+	State  string            `json:"state"`  // STP state (disabled, listening, learning, forwarding, blocking)
+	Vlans  []BridgeVlanStats `json:"vlans"`  // Per-VLAN counters (only if the `bridge` tool is available)
+}
+
+// BridgeStats represents the statistics of *one* bridge of a linux system.
+type BridgeStats struct {
+	Name       string            `json:"name"`       // Name of the bridge
+	StpEnabled bool              `json:"stpenabled"` // Whether STP is enabled on the bridge
+	FdbEntries uint64            `json:"fdbentries"` // # of entries in the forwarding database
+	Ports      []BridgePort      `json:"ports"`      // Ports attached to the bridge
+	Vlans      []BridgeVlanStats `json:"vlans"`      // Per-VLAN counters of the bridge itself
+}
+
+// fdbEntrySize is sizeof(struct __fdb_entry) (see linux/if_bridge.h)
+const fdbEntrySize = 16
+
+// getBridgeStats gets the statistics of the bridges of a linux system from
+// /sys/class/net/<bridge>/{bridge,brif,brforward}. The per-VLAN counters are
+// got running the command (it's skipped if `bridge` isn't installed):
+//   bridge -s -j vlan show
+func getBridgeStats() (bridgeStatsArr []BridgeStats, err error) {
+	bridgeDirs, err := filepath.Glob("/sys/class/net/*/bridge")
+	if err != nil {
+		return nil, err
+	}
+
+	vlans, err := getBridgeVlanStats()
+	if err != nil {
+		return nil, err
+	}
+
+	bridgeStatsArr = make([]BridgeStats, 0, len(bridgeDirs))
+	for _, bridgeDir := range bridgeDirs {
+		netDir := filepath.Dir(bridgeDir)
+		bridgeStats := BridgeStats{Name: filepath.Base(netDir), Vlans: vlans[filepath.Base(netDir)]}
+
+		stpState, err := readUintFile(filepath.Join(bridgeDir, "stp_state"))
+		if err != nil {
+			return nil, err
+		}
+		bridgeStats.StpEnabled = stpState != 0
+
+		fdb, err := ioutil.ReadFile(filepath.Join(netDir, "brforward"))
+		if err != nil {
+			return nil, err
+		}
+		bridgeStats.FdbEntries = uint64(len(fdb) / fdbEntrySize)
+
+		portDirs, err := filepath.Glob(filepath.Join(netDir, "brif", "*"))
+		if err != nil {
+			return nil, err
+		}
+		bridgeStats.Ports = make([]BridgePort, 0, len(portDirs))
+		for _, portDir := range portDirs {
+			port := BridgePort{Name: filepath.Base(portDir), Vlans: vlans[filepath.Base(portDir)]}
+			// The kernel writes the port number in hex (0x%x)
+			portNo, err := readStringFile(filepath.Join(portDir, "port_no"))
+			if err != nil {
+				return nil, err
+			}
+			if port.PortNo, err = strconv.ParseUint(portNo, 0, 64); err != nil {
+				return nil, err
+			}
+			state, err := readUintFile(filepath.Join(portDir, "state"))
+			if err != nil {
+				return nil, err
+			}
+			port.State = stpPortState(state)
+			bridgeStats.Ports = append(bridgeStats.Ports, port)
+		}
+
+		bridgeStatsArr = append(bridgeStatsArr, bridgeStats)
+	}
+
+	return bridgeStatsArr, nil
+}
+
+// stpPortState returns the name of a STP port state (see linux/if_bridge.h)
+func stpPortState(state uint64) string {
+	switch state {
+	case 0:
+		return `disabled`
+	case 1:
+		return `listening`
+	case 2:
+		return `learning`
+	case 3:
+		return `forwarding`
+	case 4:
+		return `blocking`
+	}
+	return `unknown`
+}
+
+// getBridgeVlanStats returns the per-VLAN counters of every bridge and bridge
+// port. The map is empty if the `bridge` tool isn't installed.
+func getBridgeVlanStats() (vlans map[string][]BridgeVlanStats, err error) {
+	vlans = map[string][]BridgeVlanStats{}
+
+	bridge, err := exec.LookPath("bridge")
+	if err != nil {
+		return vlans, nil
+	}
+
+	out, err := exec.Command(bridge, "-s", "-j", "vlan", "show").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var ifaces []struct {
+		Ifname string `json:"ifname"`
+		Vlans  []struct {
+			Vid       uint64 `json:"vid"`
+			RxBytes   uint64 `json:"rx_bytes"`
+			RxPackets uint64 `json:"rx_packets"`
+			TxBytes   uint64 `json:"tx_bytes"`
+			TxPackets uint64 `json:"tx_packets"`
+		} `json:"vlans"`
+	}
+	if err := json.Unmarshal(out, &ifaces); err != nil {
+		return nil, err
+	}
+
+	for _, iface := range ifaces {
+		for _, vlan := range iface.Vlans {
+			vlans[iface.Ifname] = append(vlans[iface.Ifname], BridgeVlanStats(vlan))
+		}
+	}
+
+	return vlans, nil
+}
@@ -0,0 +1,279 @@
+// +build linux
+
+package sysstats
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BundleVersion is the version of the format of the diagnostic bundles.
+const BundleVersion = 1
+
+// BundleConfig represents the configuration of a diagnostic bundle.
+type BundleConfig struct {
+	Samples   int             // # of snapshots taken (default 3)
+	Interval  time.Duration   // Time between the snapshots (default 1 second)
+	Redaction RedactionPolicy // Sensitive values removed from the bundle (none by default)
+}
+
+// BundleManifest represents the metadata of a diagnostic bundle.
+//
+// Errors map keys are the names of the collectors that failed.
+type BundleManifest struct {
+	Version   int               `json:"version"`   // Version of the bundle format
+	CreatedAt time.Time         `json:"createdat"` // When the bundle was created
+	Hostname  string            `json:"hostname"`  // Hostname of the system
+	Samples   int               `json:"samples"`   // # of snapshots taken
+	Interval  time.Duration     `json:"interval"`  // Time between the snapshots
+	Redacted  bool              `json:"redacted"`  // Whether sensitive values were redacted
+	Errors    map[string]string `json:"errors"`    // Errors of the collectors that failed
+}
+
+// Bundle represents a diagnostic bundle of a linux system: the output of all
+// the collectors, several snapshots and the host facts, so it can be attached
+// to a support ticket from a machine without network access.
+//
+// Sysctl map keys are the kernel parameters (e.g. net.ipv4.ip_forward).
+// Collectors map keys are the names of the collectors (e.g. memstats) and the
+// values their output as JSON.
+type Bundle struct {
+	Manifest   BundleManifest             `json:"manifest"`   // Metadata of the bundle
+	SysInfo    SysInfo                    `json:"sysinfo"`    // System info
+	Sysctl     map[string]string          `json:"sysctl"`     // Kernel parameters (/proc/sys)
+	Mounts     []MountInfo                `json:"mounts"`     // Mounts (/proc/self/mountinfo)
+	Collectors map[string]json.RawMessage `json:"collectors"` // Output of the collectors
+	Snapshots  []Snapshot                 `json:"snapshots"`  // Snapshots taken
+	AvgStats   []SnapshotAvgStats         `json:"avgstats"`   // Stats between consecutive snapshots
+}
+
+// bundleCollectors are the collectors whose output is added to the bundles.
+var bundleCollectors = map[string]func() (interface{}, error){
+	`loadavg`:      func() (interface{}, error) { return getLoadAvg() },
+	`memstats`:     func() (interface{}, error) { return getMemInfo() },
+	`diskusage`:    func() (interface{}, error) { return getDiskUsage() },
+	`sockstats`:    func() (interface{}, error) { return getSockStats() },
+	`filestats`:    func() (interface{}, error) { return getFileStats() },
+	`tcprtt`:       func() (interface{}, error) { return getTcpRttStats(TcpGroupByRemotePort) },
+	`firewall`:     func() (interface{}, error) { return getFirewallStats() },
+	`wireguard`:    func() (interface{}, error) { return getWireGuardStats() },
+	`bridge`:       func() (interface{}, error) { return getBridgeStats() },
+	`sriov`:        func() (interface{}, error) { return getSriovStats() },
+	`infiniband`:   func() (interface{}, error) { return getInfinibandStats() },
+	`fchost`:       func() (interface{}, error) { return getFcHostStats() },
+	`iscsi`:        func() (interface{}, error) { return getIscsiSessions() },
+	`multipath`:    func() (interface{}, error) { return getMultipathMaps() },
+	`netfs`:        func() (interface{}, error) { return getNetFsClientStats() },
+	`mountstats`:   func() (interface{}, error) { return getMountStats() },
+	`loop`:         func() (interface{}, error) { return getLoopDevices() },
+	`overlay`:      func() (interface{}, error) { return getOverlayMounts() },
+	`sharedmem`:    func() (interface{}, error) { return getSharedMemStats() },
+	`ipc`:          func() (interface{}, error) { return getIpcStats() },
+	`modules`:      func() (interface{}, error) { return getKernelModules() },
+	`taint`:        func() (interface{}, error) { return getKernelTaint() },
+	`systemd`:      func() (interface{}, error) { return getSystemdStats() },
+	`packagefacts`: func() (interface{}, error) { return getPackageFacts() },
+}
+
+// getBundle collects a diagnostic bundle. The collectors that fail don't
+// make the bundle fail: their errors are added to the manifest.
+func getBundle(config BundleConfig) (bundle Bundle, err error) {
+	if config.Samples <= 0 {
+		config.Samples = 3
+	}
+	if config.Interval <= 0 {
+		config.Interval = time.Second
+	}
+
+	bundle = Bundle{
+		Manifest: BundleManifest{
+			Version:   BundleVersion,
+			CreatedAt: time.Now(),
+			Samples:   config.Samples,
+			Interval:  config.Interval,
+			Errors:    map[string]string{},
+		},
+		Collectors: map[string]json.RawMessage{},
+		Snapshots:  make([]Snapshot, 0, config.Samples),
+		AvgStats:   make([]SnapshotAvgStats, 0, config.Samples),
+	}
+	bundle.Manifest.Hostname, _ = getHostname()
+
+	if bundle.SysInfo, err = getSysInfo(); err != nil {
+		bundle.Manifest.Errors[`sysinfo`] = err.Error()
+	}
+	if bundle.Sysctl, err = getSysctl(); err != nil {
+		bundle.Manifest.Errors[`sysctl`] = err.Error()
+	}
+	if bundle.Mounts, err = getMountInfo(); err != nil {
+		bundle.Manifest.Errors[`mounts`] = err.Error()
+	}
+
+	for name, collect := range bundleCollectors {
+		value, err := collect()
+		if err != nil {
+			bundle.Manifest.Errors[name] = err.Error()
+			continue
+		}
+		if bundle.Collectors[name], err = json.Marshal(value); err != nil {
+			bundle.Manifest.Errors[name] = err.Error()
+		}
+	}
+
+	for i := 0; i < config.Samples; i++ {
+		if i > 0 {
+			time.Sleep(config.Interval)
+		}
+		snapshot, err := getSnapshot()
+		if err != nil {
+			bundle.Manifest.Errors[`snapshot`] = err.Error()
+			break
+		}
+		if n := len(bundle.Snapshots); n > 0 {
+			avgStats, err := getSnapshotAvgStats(bundle.Snapshots[n-1], snapshot)
+			if err != nil {
+				bundle.Manifest.Errors[`avgstats`] = err.Error()
+			} else {
+				bundle.AvgStats = append(bundle.AvgStats, avgStats)
+			}
+		}
+		bundle.Snapshots = append(bundle.Snapshots, snapshot)
+	}
+
+	if config.Redaction.Enabled() {
+		redactBundle(&bundle, config.Redaction)
+	}
+
+	return bundle, nil
+}
+
+// redactBundle applies a redaction policy to a bundle: the output of the
+// collectors is redacted as JSON and the hostnames, the kernel parameters and
+// the mount sources (e.g. the NFS servers) as strings. The snapshots only have
+// counters and interface and disk names, so they aren't redacted.
+func redactBundle(bundle *Bundle, policy RedactionPolicy) {
+	bundle.Manifest.Redacted = true
+
+	for name, value := range bundle.Collectors {
+		redacted, err := policy.RedactJSON(value)
+		if err != nil {
+			// Don't leak the values that couldn't be redacted
+			delete(bundle.Collectors, name)
+			bundle.Manifest.Errors[name] = err.Error()
+			continue
+		}
+		bundle.Collectors[name] = redacted
+	}
+
+	if policy.redactsField(`hostname`) {
+		bundle.Manifest.Hostname = policy.replacement()
+		bundle.SysInfo.Hostname = policy.replacement()
+		bundle.SysInfo.FQDN = policy.replacement()
+	}
+
+	for key, value := range bundle.Sysctl {
+		if policy.redactsField(key) {
+			bundle.Sysctl[key] = policy.replacement()
+		} else {
+			bundle.Sysctl[key] = policy.RedactString(value)
+		}
+	}
+
+	for i := range bundle.Mounts {
+		bundle.Mounts[i].Source = policy.RedactString(bundle.Mounts[i].Source)
+	}
+}
+
+// getSysctl gets the kernel parameters of a linux system from /proc/sys. The
+// write-only and unreadable parameters are skipped.
+func getSysctl() (sysctl map[string]string, err error) {
+	sysctl = map[string]string{}
+
+	err = filepath.Walk(procPath("sys"), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Directories that can't be read are skipped
+			return nil
+		}
+		if info.IsDir() || info.Mode().Perm()&0444 == 0 {
+			return nil
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		key := strings.Replace(strings.TrimPrefix(path, procPath("sys")+`/`), `/`, `.`, -1)
+		sysctl[key] = strings.TrimSpace(string(content))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sysctl, nil
+}
+
+// writeBundle writes a diagnostic bundle to w as a gzipped tar archive with
+// the following files:
+//   manifest.json
+//   sysinfo.json
+//   sysctl.json
+//   mounts.json
+//   collectors/<collector>.json
+//   snapshots.json
+//   avgstats.json
+func writeBundle(w io.Writer, bundle Bundle) (err error) {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	files := map[string]interface{}{
+		"manifest.json":  bundle.Manifest,
+		"sysinfo.json":   bundle.SysInfo,
+		"sysctl.json":    bundle.Sysctl,
+		"mounts.json":    bundle.Mounts,
+		"snapshots.json": bundle.Snapshots,
+		"avgstats.json":  bundle.AvgStats,
+	}
+	for name, value := range bundle.Collectors {
+		files["collectors/"+name+".json"] = value
+	}
+
+	// Write the files always in the same order
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		content, err := json.MarshalIndent(files[name], ``, `  `)
+		if err != nil {
+			return err
+		}
+		header := &tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(content)),
+			ModTime: bundle.Manifest.CreatedAt,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
@@ -0,0 +1,233 @@
+// +build linux
+
+package sysstats
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BundleChange represents *one* difference between 2 diagnostic bundles.
+//
+// Section values:
+//   sysinfo - System info (OS release, version...).
+//   sysctl  - Kernel parameters.
+//   mounts  - Mounts (key is the mount point).
+//   metrics - Averages of the stats of the snapshots (e.g. cpu.total,
+//             net.eth0.rxbytes, disk.sda.writebytes).
+type BundleChange struct {
+	Section string `json:"section"` // Section of the bundle that changed
+	Key     string `json:"key"`     // What changed
+	Before  string `json:"before"`  // Value in the first bundle (empty if it was added)
+	After   string `json:"after"`   // Value in the second bundle (empty if it was removed)
+}
+
+// baselineChangeThreshold is the relative change of a metric average between
+// 2 bundles to be reported (25%).
+const baselineChangeThreshold = 0.25
+
+// volatileSysctls are the kernel parameters that change on their own (they
+// are counters or random values), so they aren't compared.
+var volatileSysctls = []string{
+	`fs.dentry-state`, `fs.file-nr`, `fs.inode-nr`, `fs.inode-state`, `fs.aio-nr`,
+	`fs.quota.`, `kernel.random.`, `kernel.ns_last_pid`, `kernel.pty.nr`,
+	`net.netfilter.nf_conntrack_count`,
+}
+
+// readBundle reads a diagnostic bundle written by writeBundle.
+func readBundle(r io.Reader) (bundle Bundle, err error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return Bundle{}, err
+	}
+	defer gz.Close()
+
+	bundle = Bundle{Collectors: map[string]json.RawMessage{}}
+	files := map[string]interface{}{
+		"manifest.json": &bundle.Manifest,
+		"sysinfo.json":  &bundle.SysInfo,
+		"sysctl.json":   &bundle.Sysctl,
+		"mounts.json":   &bundle.Mounts,
+		"avgstats.json": &bundle.AvgStats,
+	}
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Bundle{}, err
+		}
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return Bundle{}, err
+		}
+		if strings.HasPrefix(header.Name, "collectors/") {
+			name := strings.TrimSuffix(strings.TrimPrefix(header.Name, "collectors/"), ".json")
+			bundle.Collectors[name] = json.RawMessage(content)
+			continue
+		}
+		if header.Name == "snapshots.json" {
+			// The snapshots may have been written by an older version
+			if bundle.Snapshots, err = unmarshalSnapshots(content); err != nil {
+				return Bundle{}, errors.New("Couldn't parse " + header.Name + " of the bundle: " + err.Error())
+			}
+			continue
+		}
+		if value, ok := files[header.Name]; ok {
+			if err := json.Unmarshal(content, value); err != nil {
+				return Bundle{}, errors.New("Couldn't parse " + header.Name + " of the bundle: " + err.Error())
+			}
+		}
+	}
+
+	if bundle.Manifest.Version == 0 {
+		return Bundle{}, errors.New("Couldn't read the bundle: manifest.json not found")
+	}
+	if bundle.Manifest.Version > BundleVersion {
+		return Bundle{}, fmt.Errorf("Couldn't read the bundle: version %d isn't supported", bundle.Manifest.Version)
+	}
+
+	return bundle, nil
+}
+
+// compareBundles returns what changed between 2 diagnostic bundles (e.g.
+// between "when it worked" and "now"), sorted by section and key.
+func compareBundles(firstBundle Bundle, secondBundle Bundle) (changes []BundleChange) {
+	changes = []BundleChange{}
+
+	// System info (the uptime always changes)
+	first, second := firstBundle.SysInfo, secondBundle.SysInfo
+	changes = appendChanges(changes, `sysinfo`,
+		map[string]string{`hostname`: first.Hostname, `fqdn`: first.FQDN, `domain`: first.Domain,
+			`ostype`: first.OsType, `osrelease`: first.OsRelease, `osversion`: first.OsVersion, `osarch`: first.OsArch},
+		map[string]string{`hostname`: second.Hostname, `fqdn`: second.FQDN, `domain`: second.Domain,
+			`ostype`: second.OsType, `osrelease`: second.OsRelease, `osversion`: second.OsVersion, `osarch`: second.OsArch})
+
+	changes = appendChanges(changes, `sysctl`, stableSysctls(firstBundle.Sysctl), stableSysctls(secondBundle.Sysctl))
+	changes = appendChanges(changes, `mounts`, bundleMounts(firstBundle.Mounts), bundleMounts(secondBundle.Mounts))
+
+	// Metrics are only reported if they changed more than the threshold
+	firstMetrics, secondMetrics := bundleBaselines(firstBundle.AvgStats), bundleBaselines(secondBundle.AvgStats)
+	for key, secondValue := range secondMetrics {
+		firstValue, ok := firstMetrics[key]
+		if !ok {
+			continue
+		}
+		base := math.Max(math.Abs(firstValue), math.Abs(secondValue))
+		if base < 1 || math.Abs(secondValue-firstValue)/base < baselineChangeThreshold {
+			continue
+		}
+		changes = append(changes, BundleChange{
+			Section: `metrics`,
+			Key:     key,
+			Before:  strconv.FormatFloat(firstValue, 'f', 2, 64),
+			After:   strconv.FormatFloat(secondValue, 'f', 2, 64),
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Section != changes[j].Section {
+			return changes[i].Section < changes[j].Section
+		}
+		return changes[i].Key < changes[j].Key
+	})
+
+	return changes
+}
+
+// appendChanges appends the keys added, removed or changed between 2 maps.
+func appendChanges(changes []BundleChange, section string, first map[string]string, second map[string]string) []BundleChange {
+	for key, secondValue := range second {
+		if firstValue, ok := first[key]; !ok || firstValue != secondValue {
+			changes = append(changes, BundleChange{Section: section, Key: key, Before: firstValue, After: secondValue})
+		}
+	}
+	for key, firstValue := range first {
+		if _, ok := second[key]; !ok {
+			changes = append(changes, BundleChange{Section: section, Key: key, Before: firstValue})
+		}
+	}
+
+	return changes
+}
+
+// stableSysctls returns the kernel parameters without the volatile ones.
+func stableSysctls(sysctl map[string]string) (stable map[string]string) {
+	stable = make(map[string]string, len(sysctl))
+	for key, value := range sysctl {
+		volatile := false
+		for _, prefix := range volatileSysctls {
+			if strings.HasPrefix(key, prefix) {
+				volatile = true
+				break
+			}
+		}
+		if !volatile {
+			stable[key] = value
+		}
+	}
+
+	return stable
+}
+
+// bundleMounts returns the mounts indexed by mount point with the following
+// value:
+//   <source> <fstype> <mount options>
+func bundleMounts(mounts []MountInfo) (values map[string]string) {
+	values = make(map[string]string, len(mounts))
+	for _, mount := range mounts {
+		values[mount.MountPoint] = mount.Source + ` ` + mount.FsType + ` ` + strings.Join(mount.MountOptions, `,`)
+	}
+
+	return values
+}
+
+// bundleBaselines returns the averages of the stats between the snapshots of
+// a bundle.
+func bundleBaselines(avgStatsArr []SnapshotAvgStats) (baselines map[string]float64) {
+	baselines = map[string]float64{}
+	counts := map[string]float64{}
+	add := func(key string, value float64) {
+		baselines[key] += value
+		counts[key]++
+	}
+
+	for _, avgStats := range avgStatsArr {
+		// The rates across a suspend aren't comparable
+		if avgStats.Resumed {
+			continue
+		}
+		if cpu, ok := avgStats.Cpus[`cpu`]; ok {
+			add(`cpu.total`, cpu[`total`])
+			add(`cpu.iowait`, cpu[`iowait`])
+			add(`cpu.steal`, cpu[`steal`])
+		}
+		add(`procs.newprocs`, avgStats.Procs.NewProcs)
+		for iface, ifaceAvgStats := range avgStats.Net {
+			add(`net.`+iface+`.rxbytes`, ifaceAvgStats[`rxbytes`])
+			add(`net.`+iface+`.txbytes`, ifaceAvgStats[`txbytes`])
+		}
+		for _, disk := range avgStats.Disks {
+			add(`disk.`+disk.Name+`.readbytes`, disk.ReadBytes)
+			add(`disk.`+disk.Name+`.writebytes`, disk.WriteBytes)
+		}
+	}
+
+	for key := range baselines {
+		baselines[key] /= counts[key]
+	}
+
+	return baselines
+}
@@ -0,0 +1,123 @@
+package sysstats
+
+// Metric types
+const (
+	MetricTypeGauge   = "gauge"   // A value that can go up and down
+	MetricTypeCounter = "counter" // A value that only goes up (until it's reset)
+)
+
+// MetricInfo represents the metadata of *one* metric of the catalogue.
+type MetricInfo struct {
+	Name        string   `json:"name"`        // Name of the metric (as in Metric)
+	Type        string   `json:"type"`        // Metric type (gauge or counter)
+	Unit        string   `json:"unit"`        // Unit of the values (percent, bytes/s, ops/s...)
+	Labels      []string `json:"labels"`      // Labels of the values
+	Source      string   `json:"source"`      // File the metric is read from
+	Kernel      string   `json:"kernel"`      // Min kernel version that reports the metric (empty if any)
+	Description string   `json:"description"` // Description of the metric
+}
+
+// metricCatalogue has the metadata of the native metrics (the ones returned
+// by SnapshotAvgStats.Metrics).
+var metricCatalogue = []MetricInfo{
+	{`cpu.user`, MetricTypeGauge, `percent`, []string{`cpu`}, `/proc/stat`, ``, `% of CPU time spent in user mode`},
+	{`cpu.nice`, MetricTypeGauge, `percent`, []string{`cpu`}, `/proc/stat`, ``, `% of CPU time spent in user mode with low priority (nice)`},
+	{`cpu.system`, MetricTypeGauge, `percent`, []string{`cpu`}, `/proc/stat`, ``, `% of CPU time spent in system mode`},
+	{`cpu.idle`, MetricTypeGauge, `percent`, []string{`cpu`}, `/proc/stat`, ``, `% of CPU time spent in the idle task`},
+	{`cpu.iowait`, MetricTypeGauge, `percent`, []string{`cpu`}, `/proc/stat`, `2.5.41`, `% of CPU time spent waiting for I/O to complete`},
+	{`cpu.irq`, MetricTypeGauge, `percent`, []string{`cpu`}, `/proc/stat`, `2.6.0`, `% of CPU time servicing interrupts`},
+	{`cpu.softirq`, MetricTypeGauge, `percent`, []string{`cpu`}, `/proc/stat`, `2.6.0`, `% of CPU time servicing softirqs`},
+	{`cpu.steal`, MetricTypeGauge, `percent`, []string{`cpu`}, `/proc/stat`, `2.6.11`, `% of CPU time stolen by other operating systems when running virtualized`},
+	{`cpu.guest`, MetricTypeGauge, `percent`, []string{`cpu`}, `/proc/stat`, `2.6.24`, `% of CPU time spent running a virtual CPU for guest operating systems`},
+	{`cpu.guestnice`, MetricTypeGauge, `percent`, []string{`cpu`}, `/proc/stat`, `2.6.33`, `% of CPU time spent running a niced guest`},
+	{`cpu.total`, MetricTypeGauge, `percent`, []string{`cpu`}, `/proc/stat`, ``, `% of CPU time not spent in the idle task`},
+
+	{`procs.newprocs`, MetricTypeGauge, `forks/s`, []string{}, `/proc/stat`, ``, `# of forks per second`},
+	{`procs.running`, MetricTypeGauge, `processes`, []string{}, `/proc/stat`, `2.5.45`, `# of processes in runnable state`},
+	{`procs.blocked`, MetricTypeGauge, `processes`, []string{}, `/proc/stat`, `2.5.45`, `# of processes blocked waiting for I/O to complete`},
+	{`procs.runqueue`, MetricTypeGauge, `tasks`, []string{}, `/proc/loadavg`, ``, `# of currently runnable kernel scheduling entities`},
+	{`procs.total`, MetricTypeGauge, `tasks`, []string{}, `/proc/loadavg`, ``, `# of kernel scheduling entities that currently exist`},
+
+	{`intr.total`, MetricTypeGauge, `interrupts/s`, []string{}, `/proc/stat`, ``, `# of interrupts serviced per second`},
+	{`intr.other`, MetricTypeGauge, `interrupts/s`, []string{}, `/proc/stat`, ``, `# of interrupts per second not counted per IRQ (architecture specific, e.g. local timer and IPIs)`},
+	{`intr.rate`, MetricTypeGauge, `interrupts/s`, []string{`irq`}, `/proc/stat`, ``, `# of interrupts per second of the IRQs that fired`},
+	{`softirq.total`, MetricTypeGauge, `softirqs/s`, []string{}, `/proc/stat`, `2.6.31`, `# of softirqs serviced per second`},
+	{`softirq.rate`, MetricTypeGauge, `softirqs/s`, []string{`softirq`}, `/proc/stat`, `2.6.31`, `# of softirqs serviced per second of each type (hi, timer, net_tx, net_rx...)`},
+
+	{`net.rxbytes`, MetricTypeGauge, `bytes/s`, []string{`iface`}, `/proc/net/dev`, ``, `# of bytes received per second`},
+	{`net.rxpkts`, MetricTypeGauge, `packets/s`, []string{`iface`}, `/proc/net/dev`, ``, `# of packets received per second`},
+	{`net.rxerrs`, MetricTypeGauge, `errors/s`, []string{`iface`}, `/proc/net/dev`, ``, `# of receive errors per second`},
+	{`net.rxdrop`, MetricTypeGauge, `packets/s`, []string{`iface`}, `/proc/net/dev`, ``, `# of received packets dropped per second`},
+	{`net.rxfifo`, MetricTypeGauge, `errors/s`, []string{`iface`}, `/proc/net/dev`, ``, `# of FIFO overruns on received packets per second`},
+	{`net.rxframe`, MetricTypeGauge, `errors/s`, []string{`iface`}, `/proc/net/dev`, ``, `# of framing errors on received packets per second`},
+	{`net.rxcompr`, MetricTypeGauge, `packets/s`, []string{`iface`}, `/proc/net/dev`, ``, `# of compressed packets received per second`},
+	{`net.rxmulti`, MetricTypeGauge, `packets/s`, []string{`iface`}, `/proc/net/dev`, ``, `# of multicast packets received per second`},
+	{`net.txbytes`, MetricTypeGauge, `bytes/s`, []string{`iface`}, `/proc/net/dev`, ``, `# of bytes transmitted per second`},
+	{`net.txpkts`, MetricTypeGauge, `packets/s`, []string{`iface`}, `/proc/net/dev`, ``, `# of packets transmitted per second`},
+	{`net.txerrs`, MetricTypeGauge, `errors/s`, []string{`iface`}, `/proc/net/dev`, ``, `# of transmit errors per second`},
+	{`net.txdrop`, MetricTypeGauge, `packets/s`, []string{`iface`}, `/proc/net/dev`, ``, `# of transmitted packets dropped per second`},
+	{`net.txfifo`, MetricTypeGauge, `errors/s`, []string{`iface`}, `/proc/net/dev`, ``, `# of FIFO overruns on transmitted packets per second`},
+	{`net.txcolls`, MetricTypeGauge, `collisions/s`, []string{`iface`}, `/proc/net/dev`, ``, `# of collisions detected per second`},
+	{`net.txcarr`, MetricTypeGauge, `errors/s`, []string{`iface`}, `/proc/net/dev`, ``, `# of carrier errors on transmitted packets per second`},
+	{`net.txcompr`, MetricTypeGauge, `packets/s`, []string{`iface`}, `/proc/net/dev`, ``, `# of compressed packets transmitted per second`},
+
+	{`disk.readios`, MetricTypeGauge, `ops/s`, []string{`disk`}, `/proc/diskstats`, `2.6.0`, `# of reads completed per second`},
+	{`disk.readmerges`, MetricTypeGauge, `ops/s`, []string{`disk`}, `/proc/diskstats`, `2.6.0`, `# of reads merged per second`},
+	{`disk.readbytes`, MetricTypeGauge, `bytes/s`, []string{`disk`}, `/proc/diskstats`, `2.6.0`, `# of bytes read per second`},
+	{`disk.writeios`, MetricTypeGauge, `ops/s`, []string{`disk`}, `/proc/diskstats`, `2.6.0`, `# of writes completed per second`},
+	{`disk.writemerges`, MetricTypeGauge, `ops/s`, []string{`disk`}, `/proc/diskstats`, `2.6.0`, `# of writes merged per second`},
+	{`disk.writebytes`, MetricTypeGauge, `bytes/s`, []string{`disk`}, `/proc/diskstats`, `2.6.0`, `# of bytes written per second`},
+	{`disk.inflight`, MetricTypeGauge, `ops`, []string{`disk`}, `/proc/diskstats`, `2.6.0`, `# of I/Os currently in progress`},
+	{`disk.ioticks`, MetricTypeGauge, `milliseconds`, []string{`disk`}, `/proc/diskstats`, `2.6.0`, `# of milliseconds spent doing I/Os between the snapshots`},
+	{`disk.timeinqueue`, MetricTypeGauge, `milliseconds`, []string{`disk`}, `/proc/diskstats`, `2.6.0`, `Weighted # of milliseconds spent doing I/Os between the snapshots`},
+	{`disk.util`, MetricTypeGauge, `percent`, []string{`disk`}, `/proc/diskstats`, `2.6.0`, `% of time the disk was doing I/Os`},
+	{`disk.discardios`, MetricTypeGauge, `ops/s`, []string{`disk`}, `/proc/diskstats`, `4.18`, `# of discards completed per second`},
+	{`disk.flushios`, MetricTypeGauge, `ops/s`, []string{`disk`}, `/proc/diskstats`, `5.5`, `# of flush requests completed per second`},
+}
+
+// MetricCatalogue returns the metadata of every metric (the native ones
+// followed by the registered derived metrics), so the exporters can generate
+// their help strings and the docs can be generated from it.
+func MetricCatalogue() (catalogue []MetricInfo) {
+	catalogue = make([]MetricInfo, 0, len(metricCatalogue))
+	for _, info := range metricCatalogue {
+		info.Labels = append([]string{}, info.Labels...)
+		catalogue = append(catalogue, info)
+	}
+
+	for _, name := range DerivedMetrics() {
+		catalogue = append(catalogue, derivedMetricInfo(name))
+	}
+
+	return catalogue
+}
+
+// LookupMetric returns the metadata of the metric name.
+func LookupMetric(name string) (info MetricInfo, ok bool) {
+	for _, info := range metricCatalogue {
+		if info.Name == name {
+			info.Labels = append([]string{}, info.Labels...)
+			return info, true
+		}
+	}
+
+	derivedMetricsMu.RLock()
+	_, ok = derivedMetrics[name]
+	derivedMetricsMu.RUnlock()
+	if ok {
+		return derivedMetricInfo(name), true
+	}
+
+	return MetricInfo{}, false
+}
+
+// derivedMetricInfo returns the metadata of a derived metric.
+func derivedMetricInfo(name string) MetricInfo {
+	return MetricInfo{
+		Name:        name,
+		Type:        MetricTypeGauge,
+		Labels:      []string{},
+		Source:      `derived`,
+		Description: `User-defined derived metric`,
+	}
+}
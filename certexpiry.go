@@ -0,0 +1,157 @@
+package sysstats
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+)
+
+// CertWatcherConfig represents the configuration of a certificate expiry
+// watcher.
+type CertWatcherConfig struct {
+	Files     []string      // PEM files to check (certificates, chains or bundles)
+	Endpoints []string      // TLS endpoints to check (host:port)
+	Timeout   time.Duration // Timeout of each TLS handshake (default 5 seconds)
+}
+
+// CertExpiry represents the expiry of *one* configured file or endpoint. If
+// there is more than one certificate (e.g. a chain) the one that expires first
+// is reported.
+type CertExpiry struct {
+	Source        string    `json:"source"`        // File path or endpoint (host:port)
+	Subject       string    `json:"subject"`       // Subject of the certificate
+	Issuer        string    `json:"issuer"`        // Issuer of the certificate
+	NotBefore     time.Time `json:"notbefore"`     // Start of the validity period
+	NotAfter      time.Time `json:"notafter"`      // End of the validity period
+	DaysRemaining float64   `json:"daysremaining"` // Days until NotAfter (negative if expired)
+	Error         string    `json:"error"`         // Error reading the certificate (empty if it succeeded)
+}
+
+// CertWatcher checks the expiry of a set of local certificate files and TLS
+// endpoints.
+type CertWatcher struct {
+	config CertWatcherConfig
+}
+
+// NewCertWatcher returns a CertWatcher for the given configuration.
+func NewCertWatcher(config CertWatcherConfig) *CertWatcher {
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+
+	return &CertWatcher{config: config}
+}
+
+// Check checks every configured file and endpoint (concurrently) and returns
+// one CertExpiry per file and endpoint.
+func (w *CertWatcher) Check() (expiries []CertExpiry, err error) {
+	expiries = make([]CertExpiry, len(w.config.Files)+len(w.config.Endpoints))
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	for i, file := range w.config.Files {
+		wg.Add(1)
+		go func(i int, file string) {
+			defer wg.Done()
+			certs, err := readCertFile(file)
+			expiries[i] = newCertExpiry(file, certs, err, now)
+		}(i, file)
+	}
+	for i, endpoint := range w.config.Endpoints {
+		wg.Add(1)
+		go func(i int, endpoint string) {
+			defer wg.Done()
+			certs, err := w.readEndpointCerts(endpoint)
+			expiries[i] = newCertExpiry(endpoint, certs, err, now)
+		}(len(w.config.Files)+i, endpoint)
+	}
+	wg.Wait()
+
+	return expiries, nil
+}
+
+// readCertFile returns the certificates of a PEM file. Other PEM blocks
+// (e.g. private keys) are ignored.
+func readCertFile(file string) (certs []*x509.Certificate, err error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var block *pem.Block
+		block, content = pem.Decode(content)
+		if block == nil {
+			break
+		}
+		if block.Type != `CERTIFICATE` {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("No certificates found in " + file)
+	}
+
+	return certs, nil
+}
+
+// readEndpointCerts returns the certificates presented by a TLS endpoint. The
+// chain isn't verified: expired or self-signed certificates must be reported
+// too.
+func (w *CertWatcher) readEndpointCerts(endpoint string) (certs []*x509.Certificate, err error) {
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: w.config.Timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", endpoint, &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	certs = conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, errors.New("No certificates presented by " + endpoint)
+	}
+
+	return certs, nil
+}
+
+// newCertExpiry returns the CertExpiry of the certificate of certs that
+// expires first.
+func newCertExpiry(source string, certs []*x509.Certificate, err error, now time.Time) (expiry CertExpiry) {
+	expiry.Source = source
+	if err != nil {
+		expiry.Error = err.Error()
+		return expiry
+	}
+
+	first := certs[0]
+	for _, cert := range certs[1:] {
+		if cert.NotAfter.Before(first.NotAfter) {
+			first = cert
+		}
+	}
+	expiry.Subject = first.Subject.String()
+	expiry.Issuer = first.Issuer.String()
+	expiry.NotBefore = first.NotBefore
+	expiry.NotAfter = first.NotAfter
+	expiry.DaysRemaining = first.NotAfter.Sub(now).Hours() / 24
+
+	return expiry
+}
@@ -0,0 +1,139 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	cgroupMountPoint   = "/sys/fs/cgroup"
+	cgroupUnifiedMount = "/sys/fs/cgroup/unified"
+)
+
+// cgroupRoot returns the root directory of the hierarchy of a cgroup
+// controller (blkio, cpu...) and the cgroup version of the hierarchy. On
+// cgroup v2 all the controllers share the /sys/fs/cgroup hierarchy.
+func cgroupRoot(controller string) (root string, version int) {
+	if fileExists(filepath.Join(cgroupMountPoint, "cgroup.controllers")) {
+		return cgroupMountPoint, 2
+	}
+
+	// On cgroup v1 some controllers are co-mounted (e.g. cpu,cpuacct) and
+	// /sys/fs/cgroup/cpu is a symlink to it
+	root, err := filepath.EvalSymlinks(filepath.Join(cgroupMountPoint, controller))
+	if err != nil {
+		return "", 1
+	}
+
+	return root, 1
+}
+
+// walkCgroups calls visit for every cgroup of the hierarchy in root. The name
+// of the cgroup is its path within the hierarchy (/ for the root cgroup).
+// Cgroups removed while walking the hierarchy are skipped.
+func walkCgroups(root string, visit func(cgroup string, dir string)) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path != root {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		cgroup, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		visit(filepath.Join("/", cgroup), path)
+		return nil
+	})
+}
+
+// readCgroupFlatKeyed reads a cgroup file in the flat keyed format:
+//   nr_periods 4
+//   nr_throttled 2
+//   throttled_usec 15424
+func readCgroupFlatKeyed(path string) (values map[string]uint64, err error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values = map[string]uint64{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if value, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = value
+		}
+	}
+
+	return values, nil
+}
+
+// readCgroupNestedKeyed reads a cgroup file in the nested keyed format, where
+// the first field of every line is the key (a device major:minor, some,
+// full...):
+//   8:16 rbps=2097152 wbps=max riops=max wiops=120
+// Values set to max are returned as 0.
+func readCgroupNestedKeyed(path string) (values map[string]map[string]uint64, err error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values = map[string]map[string]uint64{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		values[fields[0]] = map[string]uint64{}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, `=`, 2)
+			if len(kv) != 2 {
+				continue
+			}
+			if kv[1] == `max` {
+				values[fields[0]][kv[0]] = 0
+			} else if value, err := strconv.ParseFloat(kv[1], 64); err == nil {
+				values[fields[0]][kv[0]] = uint64(value)
+			}
+		}
+	}
+
+	return values, nil
+}
+
+// readCgroupPressure returns the total stall time (microseconds) of the some
+// and full lines of a cgroup pressure file (cpu.pressure, io.pressure...).
+// On cgroup v1 systems the pressure files are only available if the unified
+// hierarchy is mounted (/sys/fs/cgroup/unified) and has the same cgroup.
+func readCgroupPressure(cgroup string, version int, file string) (some uint64, full uint64) {
+	dir := filepath.Join(cgroupMountPoint, cgroup)
+	if version == 1 {
+		dir = filepath.Join(cgroupUnifiedMount, cgroup)
+	}
+
+	pressure, err := readCgroupNestedKeyed(filepath.Join(dir, file))
+	if err != nil {
+		return 0, 0
+	}
+
+	return pressure[`some`][`total`], pressure[`full`][`total`]
+}
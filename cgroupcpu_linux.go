@@ -0,0 +1,84 @@
+// +build linux
+
+package sysstats
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CgroupCpuStats represents the CPU bandwidth throttling statistics of *one*
+// cgroup. On cgroup v1 the throttled time is converted to microseconds.
+type CgroupCpuStats struct {
+	Cgroup        string  `json:"cgroup"`        // Path of the cgroup within the hierarchy
+	Version       int     `json:"version"`       // Cgroup version (1 or 2)
+	Quota         int64   `json:"quota"`         // CPU time (microseconds) the cgroup can use per period (-1 if unlimited)
+	Period        uint64  `json:"period"`        // Length (microseconds) of the enforcement period
+	NrPeriods     uint64  `json:"nrperiods"`     // # of enforcement periods elapsed
+	NrThrottled   uint64  `json:"nrthrottled"`   // # of periods the cgroup was throttled
+	ThrottledUsec uint64  `json:"throttledusec"` // Total time (microseconds) the cgroup was throttled
+	ThrottledPer  float64 `json:"throttledper"`  // Percentage of periods the cgroup was throttled
+}
+
+// getCgroupCpuStats gets the CPU bandwidth throttling statistics of the
+// cgroups of a linux system from the file cpu.stat of every cgroup:
+//   nr_periods 4
+//   nr_throttled 2
+//   throttled_usec 15424
+// On cgroup v1 the throttled time is throttled_time (nanoseconds) and the
+// quota and period are read from cpu.cfs_quota_us and cpu.cfs_period_us
+// instead of cpu.max. Only the cgroups with a cpu.stat file are returned.
+func getCgroupCpuStats() (cgroupCpuStatsArr []CgroupCpuStats, err error) {
+	root, version := cgroupRoot("cpu")
+	if root == "" {
+		return []CgroupCpuStats{}, nil
+	}
+
+	cgroupCpuStatsArr = []CgroupCpuStats{}
+	err = walkCgroups(root, func(cgroup string, dir string) {
+		stat, err := readCgroupFlatKeyed(filepath.Join(dir, "cpu.stat"))
+		if err != nil {
+			return
+		}
+
+		cgroupCpuStats := CgroupCpuStats{
+			Cgroup:      cgroup,
+			Version:     version,
+			Quota:       -1,
+			NrPeriods:   stat[`nr_periods`],
+			NrThrottled: stat[`nr_throttled`],
+		}
+		if version == 2 {
+			cgroupCpuStats.ThrottledUsec = stat[`throttled_usec`]
+			// cpu.max has the format: <quota|max> <period>
+			if cpuMax, err := readStringFile(filepath.Join(dir, "cpu.max")); err == nil {
+				fields := strings.Fields(cpuMax)
+				if len(fields) == 2 {
+					if quota, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+						cgroupCpuStats.Quota = quota
+					}
+					cgroupCpuStats.Period, _ = strconv.ParseUint(fields[1], 10, 64)
+				}
+			}
+		} else {
+			cgroupCpuStats.ThrottledUsec = stat[`throttled_time`] / 1000
+			if quota, err := readStringFile(filepath.Join(dir, "cpu.cfs_quota_us")); err == nil {
+				if value, err := strconv.ParseInt(quota, 10, 64); err == nil {
+					cgroupCpuStats.Quota = value
+				}
+			}
+			cgroupCpuStats.Period, _ = readUintFile(filepath.Join(dir, "cpu.cfs_period_us"))
+		}
+		if cgroupCpuStats.NrPeriods > 0 {
+			cgroupCpuStats.ThrottledPer = 100 * float64(cgroupCpuStats.NrThrottled) / float64(cgroupCpuStats.NrPeriods)
+		}
+
+		cgroupCpuStatsArr = append(cgroupCpuStatsArr, cgroupCpuStats)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cgroupCpuStatsArr, nil
+}
@@ -0,0 +1,180 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CgroupIoDevice represents the I/O limits and counters of *one* cgroup on
+// *one* block device. Limits set to 0 are unlimited.
+type CgroupIoDevice struct {
+	DevNum     string `json:"devnum"`     // Device major:minor
+	Device     string `json:"device"`     // Block device name (sda...)
+	Rbps       uint64 `json:"rbps"`       // Read bytes per second limit
+	Wbps       uint64 `json:"wbps"`       // Write bytes per second limit
+	Riops      uint64 `json:"riops"`      // Read I/O operations per second limit
+	Wiops      uint64 `json:"wiops"`      // Write I/O operations per second limit
+	ReadBytes  uint64 `json:"readbytes"`  // # of bytes read
+	WriteBytes uint64 `json:"writebytes"` // # of bytes written
+	ReadIos    uint64 `json:"readios"`    // # of read I/O operations
+	WriteIos   uint64 `json:"writeios"`   // # of write I/O operations
+}
+
+// CgroupIoStats represents the block I/O throttling statistics of *one*
+// cgroup.
+type CgroupIoStats struct {
+	Cgroup        string           `json:"cgroup"`        // Path of the cgroup within the hierarchy
+	Version       int              `json:"version"`       // Cgroup version (1 or 2)
+	Limited       bool             `json:"limited"`       // Whether the cgroup has any I/O limit
+	ThrottledTime uint64           `json:"throttledtime"` // Time (microseconds) some task of the cgroup was stalled on I/O (io.pressure)
+	StalledTime   uint64           `json:"stalledtime"`   // Time (microseconds) all the tasks of the cgroup were stalled on I/O (io.pressure)
+	Devices       []CgroupIoDevice `json:"devices"`       // Per device limits and counters
+}
+
+// getCgroupIoStats gets the block I/O limits and counters of the cgroups of a
+// linux system. On cgroup v2 they are read from io.max and io.stat and on
+// cgroup v1 from the blkio.throttle.* files. The stalled times are read from
+// io.pressure (needs a kernel with PSI enabled). Only the cgroups with I/O
+// limits or counters are returned.
+func getCgroupIoStats() (cgroupIoStatsArr []CgroupIoStats, err error) {
+	root, version := cgroupRoot("blkio")
+	if root == "" {
+		return []CgroupIoStats{}, nil
+	}
+
+	cgroupIoStatsArr = []CgroupIoStats{}
+	err = walkCgroups(root, func(cgroup string, dir string) {
+		var devices map[string]*CgroupIoDevice
+		if version == 2 {
+			devices = readCgroupV2IoDevices(dir)
+		} else {
+			devices = readCgroupV1IoDevices(dir)
+		}
+		if len(devices) == 0 {
+			return
+		}
+
+		cgroupIoStats := CgroupIoStats{Cgroup: cgroup, Version: version, Devices: make([]CgroupIoDevice, 0, len(devices))}
+		for _, device := range devices {
+			if device.Rbps > 0 || device.Wbps > 0 || device.Riops > 0 || device.Wiops > 0 {
+				cgroupIoStats.Limited = true
+			}
+			cgroupIoStats.Devices = append(cgroupIoStats.Devices, *device)
+		}
+		sort.Slice(cgroupIoStats.Devices, func(i, j int) bool {
+			return cgroupIoStats.Devices[i].DevNum < cgroupIoStats.Devices[j].DevNum
+		})
+		cgroupIoStats.ThrottledTime, cgroupIoStats.StalledTime = readCgroupPressure(cgroup, version, "io.pressure")
+
+		cgroupIoStatsArr = append(cgroupIoStatsArr, cgroupIoStats)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cgroupIoStatsArr, nil
+}
+
+// readCgroupV2IoDevices reads the I/O limits and counters of a cgroup v2 from
+// the files io.max and io.stat:
+//   8:16 rbps=2097152 wbps=max riops=max wiops=120
+//   8:16 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0
+func readCgroupV2IoDevices(dir string) (devices map[string]*CgroupIoDevice) {
+	devices = map[string]*CgroupIoDevice{}
+
+	if limits, err := readCgroupNestedKeyed(filepath.Join(dir, "io.max")); err == nil {
+		for devNum, limit := range limits {
+			d := cgroupIoDevice(devices, devNum)
+			d.Rbps, d.Wbps, d.Riops, d.Wiops = limit[`rbps`], limit[`wbps`], limit[`riops`], limit[`wiops`]
+		}
+	}
+	if stats, err := readCgroupNestedKeyed(filepath.Join(dir, "io.stat")); err == nil {
+		for devNum, stat := range stats {
+			d := cgroupIoDevice(devices, devNum)
+			d.ReadBytes, d.WriteBytes, d.ReadIos, d.WriteIos = stat[`rbytes`], stat[`wbytes`], stat[`rios`], stat[`wios`]
+		}
+	}
+
+	return devices
+}
+
+// readCgroupV1IoDevices reads the I/O limits and counters of a cgroup v1 from
+// the blkio.throttle.* files. The limit files have the format:
+//   8:16 2097152
+// and the counter files (blkio.throttle.io_service_bytes_recursive and
+// blkio.throttle.io_serviced_recursive):
+//   8:16 Read 1459200
+//   8:16 Write 314773504
+//   ...
+//   Total 316232704
+func readCgroupV1IoDevices(dir string) (devices map[string]*CgroupIoDevice) {
+	devices = map[string]*CgroupIoDevice{}
+
+	for file, value := range map[string]func(*CgroupIoDevice) *uint64{
+		"blkio.throttle.read_bps_device":   func(d *CgroupIoDevice) *uint64 { return &d.Rbps },
+		"blkio.throttle.write_bps_device":  func(d *CgroupIoDevice) *uint64 { return &d.Wbps },
+		"blkio.throttle.read_iops_device":  func(d *CgroupIoDevice) *uint64 { return &d.Riops },
+		"blkio.throttle.write_iops_device": func(d *CgroupIoDevice) *uint64 { return &d.Wiops },
+	} {
+		content, err := ioutil.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				continue
+			}
+			if limit, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+				*value(cgroupIoDevice(devices, fields[0])) = limit
+			}
+		}
+	}
+
+	for file, values := range map[string]func(*CgroupIoDevice) (*uint64, *uint64){
+		"blkio.throttle.io_service_bytes_recursive": func(d *CgroupIoDevice) (*uint64, *uint64) { return &d.ReadBytes, &d.WriteBytes },
+		"blkio.throttle.io_serviced_recursive":      func(d *CgroupIoDevice) (*uint64, *uint64) { return &d.ReadIos, &d.WriteIos },
+	} {
+		content, err := ioutil.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		scanner.Split(bufio.ScanLines)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) != 3 || (fields[1] != `Read` && fields[1] != `Write`) {
+				continue
+			}
+			counter, err := strconv.ParseUint(fields[2], 10, 64)
+			if err != nil {
+				continue
+			}
+			read, write := values(cgroupIoDevice(devices, fields[0]))
+			if fields[1] == `Read` {
+				*read = counter
+			} else {
+				*write = counter
+			}
+		}
+	}
+
+	return devices
+}
+
+// cgroupIoDevice returns the device devNum of devices, adding it if it isn't
+// there yet.
+func cgroupIoDevice(devices map[string]*CgroupIoDevice, devNum string) *CgroupIoDevice {
+	if devices[devNum] == nil {
+		devices[devNum] = &CgroupIoDevice{DevNum: devNum, Device: devNumToName(devNum)}
+	}
+
+	return devices[devNum]
+}
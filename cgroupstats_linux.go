@@ -0,0 +1,256 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// cgroupV1Unlimited is the lowest value of the v1 memory limits that means
+// unlimited (the kernel reports the max value rounded down to the page size).
+const cgroupV1Unlimited = 1 << 62
+
+// CgroupStats represents the resource usage and limits of *one* cgroup, e.g.
+// the ones of a container, where /proc/meminfo and /proc/stat report the
+// statistics of the host. The limits set to 0 are unlimited.
+//
+// Memory map keys are the counters of memory.stat, that depend on the cgroup
+// version (anon, file, shmem... on cgroup v2, rss, cache, shmem... on cgroup
+// v1).
+type CgroupStats struct {
+	Cgroup        string            `json:"cgroup"`        // Path of the cgroup within the hierarchy
+	Version       int               `json:"version"`       // Cgroup version (1 or 2)
+	MemoryUsage   uint64            `json:"memoryusage"`   // Memory used by the cgroup (bytes)
+	MemoryLimit   uint64            `json:"memorylimit"`   // Memory limit of the cgroup (bytes)
+	MemoryPer     float64           `json:"memoryper"`     // % of the memory limit used (0 if unlimited)
+	SwapUsage     uint64            `json:"swapusage"`     // Swap used by the cgroup (bytes)
+	Memory        map[string]uint64 `json:"memory"`        // memory.stat counters
+	CpuUsage      uint64            `json:"cpuusage"`      // CPU time used by the cgroup (microseconds)
+	CpuUser       uint64            `json:"cpuuser"`       // CPU time used in user mode (microseconds)
+	CpuSystem     uint64            `json:"cpusystem"`     // CPU time used in system mode (microseconds)
+	CpuLimit      float64           `json:"cpulimit"`      // # of CPUs the cgroup can use (quota / period)
+	NrThrottled   uint64            `json:"nrthrottled"`   // # of periods the cgroup was throttled
+	ThrottledUsec uint64            `json:"throttledusec"` // Total time (microseconds) the cgroup was throttled
+	Pids          uint64            `json:"pids"`          // # of processes of the cgroup
+	PidsLimit     uint64            `json:"pidslimit"`     // Max # of processes of the cgroup
+	ReadBytes     uint64            `json:"readbytes"`     // # of bytes read (all the devices)
+	WriteBytes    uint64            `json:"writebytes"`    // # of bytes written (all the devices)
+	ReadIos       uint64            `json:"readios"`       // # of read I/O operations (all the devices)
+	WriteIos      uint64            `json:"writeios"`      // # of write I/O operations (all the devices)
+	Devices       []CgroupIoDevice  `json:"devices"`       // Per device I/O limits and counters
+}
+
+// getCgroupStats gets the resource usage and limits of a cgroup. If cgroup is
+// empty, it's the cgroup of the calling process (on cgroup v1 the cgroup of
+// every controller). On cgroup v2 they are read from memory.current,
+// memory.max, memory.stat, cpu.stat, cpu.max, pids.* and io.stat, and on
+// cgroup v1 from memory.usage_in_bytes, memory.limit_in_bytes, cpuacct.usage,
+// cpuacct.stat, cpu.cfs_*, pids.* and blkio.throttle.*. Inside a container
+// without cgroup namespace the cgroup of the process isn't visible and its
+// hierarchy root (the cgroup of the container) is read instead.
+func getCgroupStats(cgroup string) (cgroupStats CgroupStats, err error) {
+	var cgroups map[string]string
+	if cgroup == `` {
+		if cgroups, err = getPidCgroups(os.Getpid()); err != nil {
+			return CgroupStats{}, err
+		}
+	}
+	// cgroupDir returns the directory of the cgroup of a controller
+	cgroupDir := func(controller string) (dir string, version int) {
+		root, version := cgroupRoot(controller)
+		if root == `` {
+			return ``, version
+		}
+		path := cgroup
+		if path == `` {
+			if version == 2 {
+				path = cgroups[``]
+			} else {
+				path = cgroups[controller]
+			}
+		}
+		dir = filepath.Join(root, path)
+		if !fileExists(dir) {
+			return root, version
+		}
+		return dir, version
+	}
+
+	memoryDir, version := cgroupDir("memory")
+	if memoryDir == `` {
+		return CgroupStats{}, errors.New("Couldn't find the hierarchy of the memory controller")
+	}
+	cgroupStats = CgroupStats{Cgroup: cgroup, Version: version, Memory: map[string]uint64{}, Devices: []CgroupIoDevice{}}
+	if cgroup == `` {
+		cgroupStats.Cgroup = cgroups[``]
+		if version == 1 {
+			cgroupStats.Cgroup = cgroups["memory"]
+		}
+	}
+
+	if version == 2 {
+		readCgroupV2Stats(memoryDir, &cgroupStats)
+	} else {
+		readCgroupV1MemoryStats(memoryDir, &cgroupStats)
+		if cpuacctDir, _ := cgroupDir("cpuacct"); cpuacctDir != `` {
+			readCgroupV1CpuacctStats(cpuacctDir, &cgroupStats)
+		}
+		if cpuDir, _ := cgroupDir("cpu"); cpuDir != `` {
+			readCgroupV1CpuStats(cpuDir, &cgroupStats)
+		}
+		if pidsDir, _ := cgroupDir("pids"); pidsDir != `` {
+			readCgroupPidsStats(pidsDir, &cgroupStats)
+		}
+		if blkioDir, _ := cgroupDir("blkio"); blkioDir != `` {
+			setCgroupIoDevices(readCgroupV1IoDevices(blkioDir), &cgroupStats)
+		}
+	}
+
+	if cgroupStats.MemoryLimit > 0 {
+		cgroupStats.MemoryPer = 100 * float64(cgroupStats.MemoryUsage) / float64(cgroupStats.MemoryLimit)
+	}
+
+	return cgroupStats, nil
+}
+
+// readCgroupV2Stats reads the statistics of a cgroup v2 (all the controllers
+// share the directory).
+func readCgroupV2Stats(dir string, cgroupStats *CgroupStats) {
+	cgroupStats.MemoryUsage, _ = readUintFile(filepath.Join(dir, "memory.current"))
+	// memory.max is max if unlimited
+	cgroupStats.MemoryLimit, _ = readUintFile(filepath.Join(dir, "memory.max"))
+	cgroupStats.SwapUsage, _ = readUintFile(filepath.Join(dir, "memory.swap.current"))
+	if memoryStat, err := readCgroupFlatKeyed(filepath.Join(dir, "memory.stat")); err == nil {
+		cgroupStats.Memory = memoryStat
+	}
+
+	if cpuStat, err := readCgroupFlatKeyed(filepath.Join(dir, "cpu.stat")); err == nil {
+		cgroupStats.CpuUsage = cpuStat[`usage_usec`]
+		cgroupStats.CpuUser = cpuStat[`user_usec`]
+		cgroupStats.CpuSystem = cpuStat[`system_usec`]
+		cgroupStats.NrThrottled = cpuStat[`nr_throttled`]
+		cgroupStats.ThrottledUsec = cpuStat[`throttled_usec`]
+	}
+	// cpu.max has the format: <quota|max> <period>
+	if cpuMax, err := readStringFile(filepath.Join(dir, "cpu.max")); err == nil {
+		fields := strings.Fields(cpuMax)
+		if len(fields) == 2 {
+			quota, errQuota := strconv.ParseUint(fields[0], 10, 64)
+			period, errPeriod := strconv.ParseUint(fields[1], 10, 64)
+			if errQuota == nil && errPeriod == nil && period > 0 {
+				cgroupStats.CpuLimit = float64(quota) / float64(period)
+			}
+		}
+	}
+
+	readCgroupPidsStats(dir, cgroupStats)
+	setCgroupIoDevices(readCgroupV2IoDevices(dir), cgroupStats)
+}
+
+// readCgroupV1MemoryStats reads the memory statistics of a cgroup v1 from
+// the memory controller.
+func readCgroupV1MemoryStats(dir string, cgroupStats *CgroupStats) {
+	cgroupStats.MemoryUsage, _ = readUintFile(filepath.Join(dir, "memory.usage_in_bytes"))
+	if limit, err := readUintFile(filepath.Join(dir, "memory.limit_in_bytes")); err == nil && limit < cgroupV1Unlimited {
+		cgroupStats.MemoryLimit = limit
+	}
+	// memsw is the memory plus the swap (only if swap accounting is enabled)
+	if memsw, err := readUintFile(filepath.Join(dir, "memory.memsw.usage_in_bytes")); err == nil && memsw > cgroupStats.MemoryUsage {
+		cgroupStats.SwapUsage = memsw - cgroupStats.MemoryUsage
+	}
+	if memoryStat, err := readCgroupFlatKeyed(filepath.Join(dir, "memory.stat")); err == nil {
+		cgroupStats.Memory = memoryStat
+	}
+}
+
+// readCgroupV1CpuacctStats reads the CPU usage of a cgroup v1 from the
+// cpuacct controller. cpuacct.usage is in nanoseconds and cpuacct.stat in
+// USER_HZ:
+//   user 4168
+//   system 1571
+func readCgroupV1CpuacctStats(dir string, cgroupStats *CgroupStats) {
+	if usage, err := readUintFile(filepath.Join(dir, "cpuacct.usage")); err == nil {
+		cgroupStats.CpuUsage = usage / 1000
+	}
+	if cpuacctStat, err := readCgroupFlatKeyed(filepath.Join(dir, "cpuacct.stat")); err == nil {
+		// USER_HZ is 100 on most architectures
+		cgroupStats.CpuUser = cpuacctStat[`user`] * 10000
+		cgroupStats.CpuSystem = cpuacctStat[`system`] * 10000
+	}
+}
+
+// readCgroupV1CpuStats reads the CPU limit and throttling of a cgroup v1 from
+// the cpu controller.
+func readCgroupV1CpuStats(dir string, cgroupStats *CgroupStats) {
+	if cpuStat, err := readCgroupFlatKeyed(filepath.Join(dir, "cpu.stat")); err == nil {
+		cgroupStats.NrThrottled = cpuStat[`nr_throttled`]
+		cgroupStats.ThrottledUsec = cpuStat[`throttled_time`] / 1000
+	}
+	// The quota is -1 if unlimited
+	quota, err := readStringFile(filepath.Join(dir, "cpu.cfs_quota_us"))
+	if err != nil {
+		return
+	}
+	period, err := readUintFile(filepath.Join(dir, "cpu.cfs_period_us"))
+	if err != nil || period == 0 {
+		return
+	}
+	if value, err := strconv.ParseInt(quota, 10, 64); err == nil && value > 0 {
+		cgroupStats.CpuLimit = float64(value) / float64(period)
+	}
+}
+
+// readCgroupPidsStats reads the # of processes and its limit (pids.max is max
+// if unlimited) of a cgroup from the pids controller.
+func readCgroupPidsStats(dir string, cgroupStats *CgroupStats) {
+	cgroupStats.Pids, _ = readUintFile(filepath.Join(dir, "pids.current"))
+	cgroupStats.PidsLimit, _ = readUintFile(filepath.Join(dir, "pids.max"))
+}
+
+// setCgroupIoDevices sets the per device I/O limits and counters of a cgroup
+// and their totals.
+func setCgroupIoDevices(devices map[string]*CgroupIoDevice, cgroupStats *CgroupStats) {
+	for _, device := range devices {
+		cgroupStats.ReadBytes += device.ReadBytes
+		cgroupStats.WriteBytes += device.WriteBytes
+		cgroupStats.ReadIos += device.ReadIos
+		cgroupStats.WriteIos += device.WriteIos
+		cgroupStats.Devices = append(cgroupStats.Devices, *device)
+	}
+	sort.Slice(cgroupStats.Devices, func(i, j int) bool {
+		return cgroupStats.Devices[i].DevNum < cgroupStats.Devices[j].DevNum
+	})
+}
+
+// getPidCgroups gets the cgroups of a process from /proc/[pid]/cgroup,
+// indexed by controller (the cgroup v2 one is indexed by an empty string):
+//   12:cpu,cpuacct:/system.slice/sshd.service
+//   0::/system.slice/sshd.service
+func getPidCgroups(pid int) (cgroups map[string]string, err error) {
+	file, err := getProcReader().open(strconv.Itoa(pid), "cgroup")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	cgroups = map[string]string{}
+	scanner := bufio.NewScanner(file)
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), `:`, 3)
+		if len(fields) != 3 {
+			continue
+		}
+		for _, controller := range strings.Split(fields[1], `,`) {
+			cgroups[controller] = fields[2]
+		}
+	}
+
+	return cgroups, nil
+}
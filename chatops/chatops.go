@@ -0,0 +1,317 @@
+// Package chatops renders the statistics of a host and the alert events as
+// chat messages (Markdown or Slack Block Kit), so the ops bots can answer
+// "how is host X?" without writing their own presentation layer:
+//   m := chatops.Snapshot(`web-1`, avgStats)
+//   m.Render(w, chatops.Slack)
+package chatops
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rafacas/sysstats"
+	"github.com/rafacas/sysstats/numfmt"
+)
+
+// Format represents the format of a rendered message.
+type Format int
+
+const (
+	Markdown Format = iota // Markdown with a table per section
+	Slack                  // Slack Block Kit message (JSON) with a preformatted table per section
+)
+
+// maxRows is the max # of rows of the disks and network interfaces sections
+// (the busiest ones), so the messages fit in a chat.
+const maxRows = 5
+
+// Message represents a chat message: a title and a table per section. The
+// numbers of the tables are formatted when the message is rendered.
+type Message struct {
+	Title    string
+	sections func(f numfmt.Formatter) []section
+}
+
+// section represents *one* table of a message.
+type section struct {
+	title  string
+	header []string
+	rows   [][]string
+}
+
+// Snapshot returns the message of the statistics of a host between 2
+// snapshots: the CPU usage, the processes and the busiest disks and network
+// interfaces (without the loopback).
+func Snapshot(host string, stats sysstats.SnapshotAvgStats) Message {
+	m := Message{Title: `Host ` + host + ` (last ` + numfmt.Default.Duration(stats.Interval.Round(time.Second)) + `)`}
+	m.sections = func(f numfmt.Formatter) []section { return snapshotSections(stats, f) }
+
+	return m
+}
+
+// snapshotSections returns the tables of the message of a snapshot, with
+// the numbers formatted by f.
+func snapshotSections(stats sysstats.SnapshotAvgStats, f numfmt.Formatter) (sections []section) {
+	if cpu, ok := stats.Cpus[`cpu`]; ok {
+		sections = append(sections, section{
+			title:  `CPU`,
+			header: []string{`Usage`, `User`, `System`, `IOwait`, `Steal`},
+			rows:   [][]string{{f.Percent(cpu[`total`]), f.Percent(cpu[`user`]), f.Percent(cpu[`system`]), f.Percent(cpu[`iowait`]), f.Percent(cpu[`steal`])}},
+		})
+	}
+
+	sections = append(sections, section{
+		title:  `Processes`,
+		header: []string{`Running`, `Blocked`, `Run queue`, `Forks/s`},
+		rows: [][]string{{
+			f.Number(float64(stats.Procs.Running), 0),
+			f.Number(float64(stats.Procs.Blocked), 0),
+			f.Number(float64(stats.Procs.RunQueue), 0),
+			f.Number(stats.Procs.NewProcs, 1),
+		}},
+	})
+
+	if len(stats.Disks) > 0 {
+		disks := append([]sysstats.DiskAvgStats{}, stats.Disks...)
+		sort.SliceStable(disks, func(i, j int) bool { return disks[i].Util > disks[j].Util })
+		diskSection := section{title: `Disks`, header: []string{`Disk`, `Util`, `Read/s`, `Write/s`, `In flight`}}
+		for i, disk := range disks {
+			if i == maxRows {
+				break
+			}
+			diskSection.rows = append(diskSection.rows, []string{
+				disk.Name,
+				f.Percent(disk.Util),
+				f.Bytes(disk.ReadBytes),
+				f.Bytes(disk.WriteBytes),
+				f.Number(float64(disk.InFlight), 0),
+			})
+		}
+		sections = append(sections, diskSection)
+	}
+
+	ifaces := make([]string, 0, len(stats.Net))
+	for iface := range stats.Net {
+		if iface != `lo` {
+			ifaces = append(ifaces, iface)
+		}
+	}
+	if len(ifaces) > 0 {
+		traffic := func(iface string) float64 { return stats.Net[iface][`rxbytes`] + stats.Net[iface][`txbytes`] }
+		sort.Slice(ifaces, func(i, j int) bool {
+			if traffic(ifaces[i]) != traffic(ifaces[j]) {
+				return traffic(ifaces[i]) > traffic(ifaces[j])
+			}
+			return ifaces[i] < ifaces[j]
+		})
+		netSection := section{title: `Network`, header: []string{`Interface`, `Rx/s`, `Tx/s`, `Errors/s`, `Drops/s`}}
+		for i, iface := range ifaces {
+			if i == maxRows {
+				break
+			}
+			rates := stats.Net[iface]
+			netSection.rows = append(netSection.rows, []string{
+				iface,
+				f.Bytes(rates[`rxbytes`]),
+				f.Bytes(rates[`txbytes`]),
+				f.Number(rates[`rxerrs`]+rates[`txerrs`], 1),
+				f.Number(rates[`rxdrop`]+rates[`txdrop`], 1),
+			})
+		}
+		sections = append(sections, netSection)
+	}
+
+	return sections
+}
+
+// Alerts returns the message of the alert events of an AlertEngine: a
+// section with the alerts firing and one with the alerts resolved for every
+// event, e.g. "[FIRING] highcpu (cpu=cpu)".
+func Alerts(events []sysstats.AlertEvent) Message {
+	m := Message{Title: strconv.Itoa(len(events)) + ` alert events`}
+	if len(events) == 1 {
+		m.Title = `[` + eventState(events[0]) + `] ` + eventName(events[0])
+	}
+	m.sections = func(f numfmt.Formatter) []section { return alertSections(events, f) }
+
+	return m
+}
+
+// alertSections returns the tables of the message of alert events, with the
+// numbers formatted by f.
+func alertSections(events []sysstats.AlertEvent, f numfmt.Formatter) (sections []section) {
+	for _, event := range events {
+		name := eventName(event)
+		if len(event.Firing) > 0 {
+			firingSection := section{title: `[FIRING] ` + name, header: []string{`Labels`, `Value`, `Since`}}
+			for _, alert := range event.Firing {
+				firingSection.rows = append(firingSection.rows, []string{formatLabels(alert.Labels), f.Number(alert.Value, 2), formatTime(alert.Since)})
+			}
+			sections = append(sections, firingSection)
+		}
+		if len(event.Resolved) > 0 {
+			resolvedSection := section{title: `[RESOLVED] ` + name, header: []string{`Labels`, `Last value`, `Since`}}
+			for _, alert := range event.Resolved {
+				resolvedSection.rows = append(resolvedSection.rows, []string{formatLabels(alert.Labels), f.Number(alert.Value, 2), formatTime(alert.Since)})
+			}
+			sections = append(sections, resolvedSection)
+		}
+	}
+
+	return sections
+}
+
+// eventName returns the name of the rule of an event with its group.
+func eventName(event sysstats.AlertEvent) string {
+	if len(event.Group) == 0 {
+		return event.Rule
+	}
+
+	return event.Rule + ` (` + formatLabels(event.Group) + `)`
+}
+
+// eventState returns FIRING if an event has alerts firing and RESOLVED
+// otherwise.
+func eventState(event sysstats.AlertEvent) string {
+	if len(event.Firing) > 0 {
+		return `FIRING`
+	}
+
+	return `RESOLVED`
+}
+
+// Render writes the message to w in format, with the numbers formatted by
+// numfmt.Default.
+func (m Message) Render(w io.Writer, format Format) error {
+	return m.RenderFormatted(w, format, numfmt.Default)
+}
+
+// RenderFormatted writes the message to w in format, with the numbers
+// formatted by f (e.g. with the separators of the locale of the chat).
+func (m Message) RenderFormatted(w io.Writer, format Format, f numfmt.Formatter) error {
+	sections := []section{}
+	if m.sections != nil {
+		sections = m.sections(f)
+	}
+
+	bw := bufio.NewWriter(w)
+	switch format {
+	case Markdown:
+		renderMarkdown(bw, m.Title, sections)
+	case Slack:
+		if err := renderSlack(bw, m.Title, sections); err != nil {
+			return err
+		}
+	default:
+		return errors.New("Unknown message format " + strconv.Itoa(int(format)))
+	}
+
+	return bw.Flush()
+}
+
+// renderMarkdown writes a message with a Markdown table per section.
+func renderMarkdown(w io.Writer, title string, sections []section) {
+	io.WriteString(w, "**"+title+"**\n")
+	for _, s := range sections {
+		io.WriteString(w, "\n**"+s.title+"**\n\n")
+		io.WriteString(w, markdownRow(s.header))
+		separators := make([]string, len(s.header))
+		for i := range separators {
+			separators[i] = `---`
+		}
+		io.WriteString(w, markdownRow(separators))
+		for _, row := range s.rows {
+			io.WriteString(w, markdownRow(row))
+		}
+	}
+}
+
+// markdownRow returns a row of a Markdown table. The pipes of the cells are
+// escaped.
+func markdownRow(cells []string) string {
+	escaped := make([]string, 0, len(cells))
+	for _, cell := range cells {
+		escaped = append(escaped, strings.Replace(cell, `|`, `\|`, -1))
+	}
+
+	return `| ` + strings.Join(escaped, ` | `) + " |\n"
+}
+
+// slackMessage is a Slack message with Block Kit blocks. The text is the
+// fallback of the notifications.
+type slackMessage struct {
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+// slackBlock is a header or section block of a Slack message.
+type slackBlock struct {
+	Type string    `json:"type"`
+	Text slackText `json:"text"`
+}
+
+// slackText is a text object of a Slack block.
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// slackHeaderLen is the max # of characters of the text of a Slack header block.
+const slackHeaderLen = 150
+
+// renderSlack writes a message as a Slack Block Kit message: a header
+// block with the title and a section block per section, with the table
+// preformatted (Slack doesn't render Markdown tables).
+func renderSlack(w io.Writer, title string, sections []section) error {
+	header := title
+	if runes := []rune(header); len(runes) > slackHeaderLen {
+		header = string(runes[:slackHeaderLen-3]) + `...`
+	}
+	message := slackMessage{Text: title, Blocks: []slackBlock{{Type: `header`, Text: slackText{Type: `plain_text`, Text: header}}}}
+	for _, s := range sections {
+		var b strings.Builder
+		tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		io.WriteString(tw, strings.Join(s.header, "\t")+"\n")
+		for _, row := range s.rows {
+			io.WriteString(tw, strings.Join(row, "\t")+"\n")
+		}
+		tw.Flush()
+		text := `*` + escapeSlack(s.title) + "*\n```\n" + escapeSlack(strings.TrimRight(b.String(), "\n")) + "\n```"
+		message.Blocks = append(message.Blocks, slackBlock{Type: `section`, Text: slackText{Type: `mrkdwn`, Text: text}})
+	}
+
+	return json.NewEncoder(w).Encode(message)
+}
+
+// escapeSlack escapes the characters Slack uses for the links and the
+// mentions.
+func escapeSlack(text string) string {
+	return strings.NewReplacer(`&`, `&amp;`, `<`, `&lt;`, `>`, `&gt;`).Replace(text)
+}
+
+// formatLabels returns labels as name=value pairs sorted by name (- if there
+// aren't labels).
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return `-`
+	}
+	pairs := make([]string, 0, len(labels))
+	for name, value := range labels {
+		pairs = append(pairs, name+`=`+value)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, `,`)
+}
+
+// formatTime returns a time of a message in UTC.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(`2006-01-02 15:04:05 MST`)
+}
@@ -0,0 +1,59 @@
+// +build linux
+
+package chatops
+
+import (
+	"github.com/rafacas/sysstats"
+	"github.com/rafacas/sysstats/numfmt"
+)
+
+// Summary returns the message of the summary of a host (see
+// sysstats.Summary): the memory, the load and the busiest disk and network
+// interface.
+func Summary(host string, summary sysstats.SystemSummary) Message {
+	m := Message{Title: `Host ` + host}
+	m.sections = func(f numfmt.Formatter) []section { return summarySections(summary, f) }
+
+	return m
+}
+
+// summarySections returns the tables of the message of a summary, with the
+// numbers formatted by f.
+func summarySections(summary sysstats.SystemSummary, f numfmt.Formatter) (sections []section) {
+	sections = append(sections, section{
+		title:  `Memory`,
+		header: []string{``, `Total`, `Used`, `Free`},
+		rows: [][]string{
+			{`Mem`, f.Bytes(float64(summary.MemTotal)), f.Bytes(float64(summary.MemUsed)), f.Bytes(float64(summary.MemFree))},
+			{`Swap`, f.Bytes(float64(summary.SwapTotal)), f.Bytes(float64(summary.SwapUsed)),
+				f.Bytes(float64(summary.SwapTotal) - float64(summary.SwapUsed))},
+		},
+	})
+	sections = append(sections, section{
+		title:  `Load`,
+		header: []string{`1m`, `5m`, `15m`, `CPUs`, `CPU usage`},
+		rows: [][]string{{
+			f.Number(summary.Load1, 2),
+			f.Number(summary.Load5, 2),
+			f.Number(summary.Load15, 2),
+			f.Number(float64(summary.Cpus), 0),
+			f.Percent(summary.CpuPer),
+		}},
+	})
+	if disk := summary.TopDisk; disk != nil {
+		sections = append(sections, section{
+			title:  `Busiest disk`,
+			header: []string{`Disk`, `Util`, `Read/s`, `Write/s`},
+			rows:   [][]string{{disk.Name, f.Percent(disk.Util), f.Bytes(disk.ReadBytes), f.Bytes(disk.WriteBytes)}},
+		})
+	}
+	if iface := summary.TopIface; iface != nil {
+		sections = append(sections, section{
+			title:  `Busiest interface`,
+			header: []string{`Interface`, `Rx/s`, `Tx/s`},
+			rows:   [][]string{{iface.Name, f.Bytes(iface.RxBytes), f.Bytes(iface.TxBytes)}},
+		})
+	}
+
+	return sections
+}
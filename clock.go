@@ -0,0 +1,193 @@
+package sysstats
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of the time of the samplers, the alert engines, the
+// silences, the histories and the snapshots (see SetClock).
+type Clock interface {
+	Now() time.Time                      // Current time
+	NewTimer(d time.Duration) ClockTimer // Timer that fires once after d
+}
+
+// ClockTimer represents a timer of a Clock.
+type ClockTimer interface {
+	C() <-chan time.Time // Channel the time is sent to when the timer fires
+	Stop() bool          // Stops the timer. It returns false if it already fired or was stopped
+}
+
+// realClock is the Clock of the time package.
+type realClock struct{}
+
+// Now returns time.Now().
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer returns a time.Timer.
+func (realClock) NewTimer(d time.Duration) ClockTimer {
+	return realTimer{time.NewTimer(d)}
+}
+
+// realTimer is a ClockTimer of a time.Timer.
+type realTimer struct {
+	timer *time.Timer
+}
+
+// C returns the channel of the timer.
+func (t realTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+// Stop stops the timer.
+func (t realTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+// clockMu protects clock.
+var clockMu sync.RWMutex
+
+// clock is the clock of the package.
+var clock Clock = realClock{}
+
+// setClock sets the clock of the package (the real one if c is nil).
+func setClock(c Clock) {
+	if c == nil {
+		c = realClock{}
+	}
+
+	clockMu.Lock()
+	defer clockMu.Unlock()
+	clock = c
+}
+
+// getClock returns the clock of the package.
+func getClock() Clock {
+	clockMu.RLock()
+	defer clockMu.RUnlock()
+
+	return clock
+}
+
+// clockNow returns the current time of the clock of the package.
+func clockNow() time.Time {
+	return getClock().Now()
+}
+
+// FakeClock is a Clock whose time only advances when Advance is called, so
+// the samplers and the alert engines run deterministically in the tests:
+//   clock := sysstats.NewFakeClock(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+//   sysstats.SetClock(clock)
+//   go sampler.Run(ctx)
+//   clock.BlockUntil(1) // The sampler waits for its next run
+//   clock.Advance(10 * time.Second)
+type FakeClock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// fakeTimer is a ClockTimer of a FakeClock.
+type fakeTimer struct {
+	clock    *FakeClock
+	deadline time.Time
+	c        chan time.Time
+}
+
+// NewFakeClock returns a FakeClock whose time is start.
+func NewFakeClock(start time.Time) *FakeClock {
+	c := &FakeClock{now: start}
+	c.cond = sync.NewCond(&c.mu)
+
+	return c
+}
+
+// Now returns the time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// NewTimer returns a timer that fires when the clock advances d (at once if
+// d <= 0).
+func (c *FakeClock) NewTimer(d time.Duration) ClockTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTimer{clock: c, deadline: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.c <- c.now
+		return t
+	}
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+
+	return t
+}
+
+// Advance advances the time of the clock by d and fires the timers whose
+// deadline is reached, in the order of their deadlines.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	sort.SliceStable(c.timers, func(i, j int) bool { return c.timers[i].deadline.Before(c.timers[j].deadline) })
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.deadline.After(c.now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.c <- t.deadline
+	}
+	c.timers = pending
+	c.cond.Broadcast()
+}
+
+// Timers returns the # of timers that haven't fired nor been stopped (e.g.
+// the one of each running sampler).
+func (c *FakeClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+// BlockUntil waits until there are at least n timers that haven't fired nor
+// been stopped, e.g. until a sampler waits for its next run after Advance.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+// C returns the channel of the timer.
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+// Stop stops the timer.
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	for i, timer := range t.clock.timers {
+		if timer == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			t.clock.cond.Broadcast()
+			return true
+		}
+	}
+
+	return false
+}
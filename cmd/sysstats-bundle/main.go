@@ -0,0 +1,63 @@
+// +build linux
+
+// Command sysstats-bundle collects a diagnostic bundle of the system (all the
+// collectors, several snapshots and the host facts) into a gzipped tar
+// archive that can be attached to a support ticket.
+//
+// Usage:
+//   sysstats-bundle [-o file] [-samples n] [-interval d] [-redact fields] [-redact-ips]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rafacas/sysstats"
+)
+
+func main() {
+	output := flag.String("o", ``, "Output file (default sysstats-<hostname>-<time>.tar.gz)")
+	samples := flag.Int("samples", 3, "# of snapshots")
+	interval := flag.Duration("interval", time.Second, "Time between the snapshots")
+	redact := flag.String("redact", ``, "Comma separated fields redacted (e.g. comm,uid,hostname)")
+	redactIps := flag.Bool("redact-ips", false, "Redact the IP addresses")
+	flag.Parse()
+
+	config := sysstats.BundleConfig{Samples: *samples, Interval: *interval}
+	if *redact != `` {
+		config.Redaction.Fields = strings.Split(*redact, `,`)
+	}
+	config.Redaction.IPAddresses = *redactIps
+	bundle, err := sysstats.GetBundle(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *output == `` {
+		*output = fmt.Sprintf("sysstats-%s-%s.tar.gz", bundle.Manifest.Hostname,
+			bundle.Manifest.CreatedAt.Format("20060102-150405"))
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := sysstats.WriteBundle(file, bundle); err != nil {
+		file.Close()
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := file.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	for name, e := range bundle.Manifest.Errors {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, e)
+	}
+	fmt.Println(*output)
+}
@@ -0,0 +1,75 @@
+// +build js,wasm
+
+// Command sysstats-replay exposes the replay provider to the JavaScript of a
+// browser-based viewer, so recorded snapshots can be explored with the same
+// analysis code as the agent.
+//
+// Build:
+//   GOOS=js GOARCH=wasm go build -o sysstats-replay.wasm
+// It registers the JavaScript functions:
+//   sysstatsLoad(json)  - Adds the snapshots of a blob (a snapshot or an array
+//                          of snapshots). It returns the # of snapshots.
+//   sysstatsStats(i)    - Returns the statistics between the snapshots i-1
+//                          and i as JSON.
+//   sysstatsMetrics(i)  - Returns the metrics between the snapshots i-1 and i
+//                          as JSON.
+// The functions return an object with an error field if they fail.
+package main
+
+import (
+	"encoding/json"
+	"syscall/js"
+
+	"github.com/rafacas/sysstats"
+)
+
+func main() {
+	provider, _ := sysstats.NewReplayProvider()
+
+	js.Global().Set("sysstatsLoad", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) != 1 {
+			return jsError("sysstatsLoad needs the snapshots")
+		}
+		if err := provider.Add([]byte(args[0].String())); err != nil {
+			return jsError(err.Error())
+		}
+		return provider.Len()
+	}))
+	js.Global().Set("sysstatsStats", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) != 1 {
+			return jsError("sysstatsStats needs the index of the snapshot")
+		}
+		avgStats, err := provider.AvgStats(args[0].Int())
+		if err != nil {
+			return jsError(err.Error())
+		}
+		return jsJSON(avgStats)
+	}))
+	js.Global().Set("sysstatsMetrics", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) != 1 {
+			return jsError("sysstatsMetrics needs the index of the snapshot")
+		}
+		metrics, _, err := provider.Metrics(args[0].Int())
+		if err != nil {
+			return jsError(err.Error())
+		}
+		return jsJSON(metrics)
+	}))
+
+	// Keep the functions registered
+	select {}
+}
+
+// jsError returns an object with the error message.
+func jsError(message string) interface{} {
+	return map[string]interface{}{"error": message}
+}
+
+// jsJSON returns value serialized as JSON.
+func jsJSON(value interface{}) interface{} {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return jsError(err.Error())
+	}
+	return string(content)
+}
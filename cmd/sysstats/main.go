@@ -0,0 +1,302 @@
+// +build linux
+
+// Command sysstats prints the statistics of the selected collectors once or,
+// in watch mode, every interval (a minimal vmstat/dstat replacement).
+//
+// Usage:
+//   sysstats [-interval d] [-watch] [-count n] [-format json|table|spark|prom] [-locale name] [collector...]
+// The collectors are cpu, mem, disk, net, procs and load (default all of
+// them). The rates are calculated between 2 snapshots taken interval (at
+// least 1 second) apart, so the first output is printed after interval. The
+// flags can also be given after the collectors:
+//   sysstats mem cpu disk --interval 2s --format json
+// The spark format is the table with a sparkline of the last values of every
+// metric, for the watch mode. The numbers of the table and spark formats
+// have the separators of the locale (by default the one of the environment:
+// LC_ALL, LC_NUMERIC or LANG). If the procfs is restricted (hidepid, gVisor,
+// WSL 1...) the collectors whose files aren't available are skipped, with a
+// warning.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rafacas/sysstats"
+	"github.com/rafacas/sysstats/numfmt"
+	"github.com/rafacas/sysstats/prometheus"
+	"github.com/rafacas/sysstats/termgraph"
+)
+
+// collectors are the collectors that can be selected, in output order.
+var collectors = []string{`cpu`, `mem`, `disk`, `net`, `procs`, `load`}
+
+// collectorFiles are the files of the procfs the collectors need.
+var collectorFiles = map[string]string{
+	`cpu`:   `stat`,
+	`mem`:   `meminfo`,
+	`disk`:  `diskstats`,
+	`net`:   `net/dev`,
+	`procs`: `stat`,
+	`load`:  `loadavg`,
+}
+
+// sparkValues is the # of values of the sparklines of the spark format.
+const sparkValues = 30
+
+// sample represents the metrics of the selected collectors at *one* time.
+type sample struct {
+	Time    time.Time         `json:"time"`    // Time of the second snapshot
+	Metrics []sysstats.Metric `json:"metrics"` // Metrics of the collectors
+}
+
+func main() {
+	flags := flag.NewFlagSet(`sysstats`, flag.ExitOnError)
+	interval := flags.Duration("interval", time.Second, "Time between the snapshots the rates are calculated from")
+	watch := flags.Bool("watch", false, "Print the statistics every interval until interrupted")
+	count := flags.Int("count", 0, "# of outputs in watch mode (0 means no limit)")
+	format := flags.String("format", `table`, "Output format: json, table, spark or prom")
+	locale := flags.String("locale", ``, "Locale of the numbers of the table and spark formats, e.g. de_DE (default the one of the environment)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: sysstats [flags] [collector...]")
+		fmt.Fprintln(flags.Output(), "Collectors: "+strings.Join(collectors, ` `)+" (default all of them)")
+		flags.PrintDefaults()
+	}
+
+	// The flag package stops at the first collector, so the flags after the
+	// collectors are parsed too
+	selected := []string{}
+	args := os.Args[1:]
+	for {
+		flags.Parse(args)
+		if flags.NArg() == 0 {
+			break
+		}
+		selected = append(selected, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+
+	enabled, err := parseCollectors(selected)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		flags.Usage()
+		os.Exit(2)
+	}
+	if procEnvironment := sysstats.GetProcEnvironment(); procEnvironment.Restricted {
+		sysstats.SetProcRestricted(true)
+		skipUnavailable(enabled, procEnvironment)
+	}
+	f := numfmt.Formatter{Locale: numfmt.LocaleFromEnv()}
+	if *locale != `` {
+		f.Locale = numfmt.LocaleFor(*locale)
+	}
+	write, err := writer(*format, f)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *interval < time.Second {
+		fmt.Fprintln(os.Stderr, "The interval should be at least 1 second")
+		os.Exit(2)
+	}
+
+	previous, err := sysstats.GetSnapshot()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for n := 1; ; n++ {
+		time.Sleep(*interval)
+		current, err := sysstats.GetSnapshot()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		s, err := collect(previous, current, enabled)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err := write(os.Stdout, s); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if !*watch || (*count > 0 && n >= *count) {
+			break
+		}
+		previous = current
+	}
+}
+
+// parseCollectors returns the collectors selected (all of them if none is).
+func parseCollectors(selected []string) (enabled map[string]bool, err error) {
+	enabled = map[string]bool{}
+	if len(selected) == 0 {
+		selected = collectors
+	}
+	for _, name := range selected {
+		known := false
+		for _, collector := range collectors {
+			known = known || name == collector
+		}
+		if !known {
+			return nil, errors.New("Unknown collector: " + name)
+		}
+		enabled[name] = true
+	}
+
+	return enabled, nil
+}
+
+// skipUnavailable disables the enabled collectors whose files aren't
+// available in a restricted procfs, with a warning.
+func skipUnavailable(enabled map[string]bool, procEnvironment sysstats.ProcEnvironment) {
+	for _, collector := range collectors {
+		if !enabled[collector] || procEnvironment.Available(collectorFiles[collector]) {
+			continue
+		}
+		delete(enabled, collector)
+		reason := ``
+		if procEnvironment.Sandbox != `` {
+			reason = ` (` + procEnvironment.Sandbox + `)`
+		}
+		fmt.Fprintln(os.Stderr, "Skipping collector "+collector+": "+procEnvironment.Root+"/"+collectorFiles[collector]+" isn't available"+reason)
+	}
+}
+
+// collect returns the metrics of the enabled collectors between 2 snapshots.
+// The memory and the load average are read when the second snapshot is.
+func collect(previous sysstats.Snapshot, current sysstats.Snapshot, enabled map[string]bool) (s sample, err error) {
+	avgStats, err := sysstats.GetSnapshotAvgStats(previous, current)
+	if err != nil {
+		return sample{}, err
+	}
+	metrics, _ := avgStats.Metrics()
+
+	s = sample{Time: current.Timestamp, Metrics: make([]sysstats.Metric, 0, len(metrics))}
+	for _, collector := range collectors {
+		if !enabled[collector] {
+			continue
+		}
+		switch collector {
+		case `mem`:
+			memInfo, err := sysstats.GetMemInfo()
+			if err != nil {
+				return sample{}, err
+			}
+			memStats := memInfo.ToMap()
+			keys := make([]string, 0, len(memStats))
+			for key := range memStats {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				s.Metrics = append(s.Metrics, sysstats.Metric{Name: `mem.` + key, Labels: map[string]string{}, Value: float64(memStats[key])})
+			}
+		case `load`:
+			loadAvg, err := sysstats.GetLoadAvg()
+			if err != nil {
+				return sample{}, err
+			}
+			for _, metric := range []sysstats.Metric{
+				{Name: `load.avg1`, Value: loadAvg.Avg1},
+				{Name: `load.avg5`, Value: loadAvg.Avg5},
+				{Name: `load.avg15`, Value: loadAvg.Avg15},
+			} {
+				metric.Labels = map[string]string{}
+				s.Metrics = append(s.Metrics, metric)
+			}
+		default:
+			// The metrics of the snapshots are prefixed by their collector
+			for _, metric := range metrics {
+				if strings.HasPrefix(metric.Name, collector+`.`) {
+					s.Metrics = append(s.Metrics, metric)
+				}
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// writer returns the function that writes a sample in format, with the
+// numbers formatted by f (table and spark).
+func writer(format string, f numfmt.Formatter) (write func(w io.Writer, s sample) error, err error) {
+	switch format {
+	case `json`:
+		return writeJSON, nil
+	case `table`:
+		return func(w io.Writer, s sample) error { return writeTable(w, s, f) }, nil
+	case `spark`:
+		return newSparkWriter(f), nil
+	case `prom`:
+		return func(w io.Writer, s sample) error { return prometheus.Write(w, s.Metrics) }, nil
+	}
+
+	return nil, errors.New("Unknown format: " + format)
+}
+
+// writeJSON writes a sample as *one* line of JSON.
+func writeJSON(w io.Writer, s sample) error {
+	return json.NewEncoder(w).Encode(s)
+}
+
+// writeTable writes a sample as a table with a metric per row, after its
+// time, with the numbers formatted by f.
+func writeTable(w io.Writer, s sample, f numfmt.Formatter) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, s.Time.Format(time.RFC3339))
+	fmt.Fprintln(tw, "METRIC\tLABELS\tVALUE")
+	for _, metric := range s.Metrics {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", metric.Name, formatLabels(metric.Labels), f.Number(metric.Value, 2))
+	}
+	fmt.Fprintln(tw)
+
+	return tw.Flush()
+}
+
+// newSparkWriter returns a function that writes a sample as a table with a
+// metric per row and a sparkline of its last values (of the samples written
+// before), with the numbers formatted by f.
+func newSparkWriter(f numfmt.Formatter) (write func(w io.Writer, s sample) error) {
+	values := map[string][]float64{}
+
+	return func(w io.Writer, s sample) error {
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, s.Time.Format(time.RFC3339))
+		fmt.Fprintln(tw, "METRIC\tLABELS\tVALUE\tLAST "+fmt.Sprint(sparkValues))
+		for _, metric := range s.Metrics {
+			labels := formatLabels(metric.Labels)
+			key := metric.Name + `{` + labels + `}`
+			last := append(values[key], metric.Value)
+			if len(last) > sparkValues {
+				last = last[len(last)-sparkValues:]
+			}
+			values[key] = last
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", metric.Name, labels, f.Number(metric.Value, 2), termgraph.Sparkline(last))
+		}
+		fmt.Fprintln(tw)
+
+		return tw.Flush()
+	}
+}
+
+// formatLabels returns the labels of a metric as name=value pairs sorted by
+// name.
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for name, value := range labels {
+		pairs = append(pairs, name+`=`+value)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, `,`)
+}
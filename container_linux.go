@@ -0,0 +1,86 @@
+// +build linux
+
+package sysstats
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ProcessContainer represents the container (and the Kubernetes pod) a
+// process of the host runs in, as identified by its cgroup.
+type ProcessContainer struct {
+	Runtime string `json:"runtime"` // Container runtime: docker, containerd, crio, podman or lxc (empty if it's unknown)
+	Id      string `json:"id"`      // Container id (the name for lxc)
+	PodUid  string `json:"poduid"`  // Uid of the Kubernetes pod of the container (empty if it isn't in a pod)
+}
+
+var (
+	// containerScopeRe matches the systemd scopes of the containers, e.g.
+	// docker-<id>.scope or cri-containerd-<id>.scope
+	containerScopeRe = regexp.MustCompile(`^(docker|cri-containerd|crio|libpod)-([0-9a-f]{12,64})\.scope$`)
+	// containerIdRe matches the cgroups named after a container id, e.g.
+	// /docker/<id> or /kubepods/burstable/pod<uid>/<id>
+	containerIdRe = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	// podRe matches the cgroups of the Kubernetes pods, e.g. pod<uid> or
+	// kubepods-burstable-pod<uid>.slice (with _ instead of - in the uid)
+	podRe = regexp.MustCompile(`^(?:kubepods-(?:besteffort-|burstable-)?)?pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})(?:\.slice)?$`)
+)
+
+// containerRuntimes are the runtimes of the prefixes of the container scopes.
+var containerRuntimes = map[string]string{
+	`docker`:         `docker`,
+	`cri-containerd`: `containerd`,
+	`crio`:           `crio`,
+	`libpod`:         `podman`,
+}
+
+// getPidContainer gets the container of a process of the host from its
+// cgroup. It returns nil if the process doesn't run in a container.
+func getPidContainer(pid int) (container *ProcessContainer, err error) {
+	cgroup, err := getPidCgroup(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseContainerCgroup(cgroup), nil
+}
+
+// parseContainerCgroup returns the container of a cgroup path (nil if it
+// isn't the cgroup of a container, or one of its children). It knows the
+// layouts of the cgroupfs and systemd drivers of docker, containerd, cri-o,
+// podman, lxc and the kubelet, e.g.
+//   /kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod<uid>.slice/cri-containerd-<id>.scope
+//   /kubepods/besteffort/pod<uid>/<id>
+//   /system.slice/docker-<id>.scope
+//   /lxc.payload.web
+func parseContainerCgroup(cgroup string) *ProcessContainer {
+	container := ProcessContainer{}
+	parent := ``
+	for _, name := range strings.Split(strings.Trim(cgroup, `/`), `/`) {
+		switch {
+		case container.Id != ``:
+			// A child cgroup of the container (e.g. created by systemd in it)
+		case podRe.MatchString(name):
+			container.PodUid = strings.Replace(podRe.FindStringSubmatch(name)[1], `_`, `-`, -1)
+		case containerScopeRe.MatchString(name):
+			match := containerScopeRe.FindStringSubmatch(name)
+			container.Runtime, container.Id = containerRuntimes[match[1]], match[2]
+		case containerIdRe.MatchString(name):
+			container.Id = name
+			if parent == `docker` {
+				container.Runtime = `docker`
+			}
+		case strings.HasPrefix(name, `lxc.payload.`):
+			container.Runtime, container.Id = `lxc`, strings.TrimPrefix(name, `lxc.payload.`)
+		case parent == `lxc` || parent == `lxc.payload`:
+			container.Runtime, container.Id = `lxc`, name
+		}
+		parent = name
+	}
+	if container.Id == `` {
+		return nil
+	}
+
+	return &container
+}
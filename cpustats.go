@@ -0,0 +1,378 @@
+package sysstats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CpuRawStats represents *one* CPU raw statistics of a linux system.
+//
+// Map keys:
+//   User      - Time spent in user mode.
+//   Nice      - Time spent in user mode with low priority (nice).
+//   System    - Time spent in system mode.
+//   Idle      - Time spent in the idle task.
+//   Iowait    - Time spent waiting for I/O to complete (since 2.5.41).
+//   Irq       - Time servicing interrupts (since 2.6.0-test4).
+//   Softirq   - Time servicing softirqs (since 2.6.0-test4).
+//   Steal     - Stolen time, which is the time spent in other operating
+//               systems when running a virtualized environment (since 2.6.11).
+//   Guest     - Time spent running a virtual Cpu for guest operating
+//               systems under the control of the Linux kernel (since 2.6.24).
+//   GuestNice - Time spent running a niced guest (virtual Cpu for guest
+//               operating systems under the control of the Linux kernel)
+//               (since 2.6.33).
+//   Total     - Total time. The guest time isn't added since the kernel
+//               already accounts it in User and Nice.
+// Note: CPU time is measured in units of USER_HZ (1/100ths of a second on most
+// architectures)
+type CpuRawStats map[string]uint64
+
+// CpuAvgStats represents *one* CPU statistics of a linux system.
+//
+// Map keys:
+//   User      - % of CPU time spent in user mode.
+//   Nice      - % of CPU time spent in user mode with low priority (nice).
+//   System    - % of CPU time spent in system mode.
+//   Idle      - % of CPU time spent in the idle task.
+//   Iowait    - % of CPU time spent waiting for I/O to complete (since 2.5.41).
+//   Irq       - % of CPU servicing interrupts (since 2.6.0-test4).
+//   Softirq   - % of CPU servicing softirqs (since 2.6.0-test4).
+//   Steal     - % of stolen CPU time, which is the time spent in other operating
+//               systems when running a virtualized environment (since 2.6.11).
+//   Guest     - % of CPU time spent running a virtual Cpu for guest operating
+//               systems under the control of the Linux kernel (since 2.6.24).
+//   GuestNice - % of CPU time spent running a niced guest (virtual Cpu for guest
+//               operating systems under the control of the Linux kernel)
+//               (since 2.6.33).
+//   Total     - Total time.
+type CpuAvgStats map[string]float64
+
+// CpusRawStats represents *all* the CPU raw statistics of a linux system.
+//
+// Map keys:
+//   Name - Name of the CPU (as it is on /proc/stat: cpu, cpu0,...).
+type CpusRawStats map[string]CpuRawStats
+
+// CpusAvgStats represents *all* the CPU statistics of a linux system.
+//
+// Map keys:
+//   Name - Name of the CPU (as it is on /proc/stat: cpu, cpu0,...).
+type CpusAvgStats map[string]CpuAvgStats
+
+// CpuTimes represents *one* CPU raw statistics of a linux system as a
+// struct. The JSON keys are the CpuRawStats map keys. The times are in units
+// of USER_HZ.
+type CpuTimes struct {
+	User      uint64 `json:"user"`      // Time spent in user mode
+	Nice      uint64 `json:"nice"`      // Time spent in user mode with low priority (nice)
+	System    uint64 `json:"system"`    // Time spent in system mode
+	Idle      uint64 `json:"idle"`      // Time spent in the idle task
+	Iowait    uint64 `json:"iowait"`    // Time spent waiting for I/O to complete
+	Irq       uint64 `json:"irq"`       // Time servicing interrupts
+	Softirq   uint64 `json:"softirq"`   // Time servicing softirqs
+	Steal     uint64 `json:"steal"`     // Time spent in other operating systems (virtualized environments)
+	Guest     uint64 `json:"guest"`     // Time spent running a virtual CPU for guests
+	GuestNice uint64 `json:"guestnice"` // Time spent running a niced guest
+	Total     uint64 `json:"total"`     // Total time (without the guest time)
+}
+
+// CpuUsage represents *one* CPU statistics of a linux system as a struct.
+// The JSON keys are the CpuAvgStats map keys.
+type CpuUsage struct {
+	User      float64 `json:"user"`      // % of CPU time spent in user mode
+	Nice      float64 `json:"nice"`      // % of CPU time spent in user mode with low priority (nice)
+	System    float64 `json:"system"`    // % of CPU time spent in system mode
+	Idle      float64 `json:"idle"`      // % of CPU time spent in the idle task
+	Iowait    float64 `json:"iowait"`    // % of CPU time spent waiting for I/O to complete
+	Irq       float64 `json:"irq"`       // % of CPU time servicing interrupts
+	Softirq   float64 `json:"softirq"`   // % of CPU time servicing softirqs
+	Steal     float64 `json:"steal"`     // % of CPU time spent in other operating systems (virtualized environments)
+	Guest     float64 `json:"guest"`     // % of CPU time spent running a virtual CPU for guests
+	GuestNice float64 `json:"guestnice"` // % of CPU time spent running a niced guest
+	Total     float64 `json:"total"`     // % of CPU time not idle
+}
+
+// cpuKeys are the keys of the CpuRawStats and CpuAvgStats maps.
+var cpuKeys = []string{`user`, `nice`, `system`, `idle`, `iowait`, `irq`,
+	`softirq`, `steal`, `guest`, `guestnice`, `total`}
+
+// fields returns the fields of cpuTimes in the order of cpuKeys.
+func (cpuTimes *CpuTimes) fields() []*uint64 {
+	return []*uint64{&cpuTimes.User, &cpuTimes.Nice, &cpuTimes.System,
+		&cpuTimes.Idle, &cpuTimes.Iowait, &cpuTimes.Irq, &cpuTimes.Softirq,
+		&cpuTimes.Steal, &cpuTimes.Guest, &cpuTimes.GuestNice, &cpuTimes.Total}
+}
+
+// fields returns the fields of cpuUsage in the order of cpuKeys.
+func (cpuUsage *CpuUsage) fields() []*float64 {
+	return []*float64{&cpuUsage.User, &cpuUsage.Nice, &cpuUsage.System,
+		&cpuUsage.Idle, &cpuUsage.Iowait, &cpuUsage.Irq, &cpuUsage.Softirq,
+		&cpuUsage.Steal, &cpuUsage.Guest, &cpuUsage.GuestNice, &cpuUsage.Total}
+}
+
+// Times returns the CPU raw statistics as a CpuTimes.
+func (cpuRawStats CpuRawStats) Times() (cpuTimes CpuTimes) {
+	for i, value := range cpuTimes.fields() {
+		*value = cpuRawStats[cpuKeys[i]]
+	}
+
+	return cpuTimes
+}
+
+// ToMap returns the CPU raw statistics as a CpuRawStats map.
+func (cpuTimes CpuTimes) ToMap() (cpuRawStats CpuRawStats) {
+	cpuRawStats = CpuRawStats{}
+	for i, value := range cpuTimes.fields() {
+		cpuRawStats[cpuKeys[i]] = *value
+	}
+
+	return cpuRawStats
+}
+
+// Usage returns the CPU statistics as a CpuUsage.
+func (cpuAvgStats CpuAvgStats) Usage() (cpuUsage CpuUsage) {
+	for i, value := range cpuUsage.fields() {
+		*value = cpuAvgStats[cpuKeys[i]]
+	}
+
+	return cpuUsage
+}
+
+// ToMap returns the CPU statistics as a CpuAvgStats map.
+func (cpuUsage CpuUsage) ToMap() (cpuAvgStats CpuAvgStats) {
+	cpuAvgStats = CpuAvgStats{}
+	for i, value := range cpuUsage.fields() {
+		cpuAvgStats[cpuKeys[i]] = *value
+	}
+
+	return cpuAvgStats
+}
+
+// Times returns the raw statistics of all the CPUs as CpuTimes.
+func (cpusRawStats CpusRawStats) Times() (cpuTimes map[string]CpuTimes) {
+	cpuTimes = make(map[string]CpuTimes, len(cpusRawStats))
+	for cpuName, cpuRawStats := range cpusRawStats {
+		cpuTimes[cpuName] = cpuRawStats.Times()
+	}
+
+	return cpuTimes
+}
+
+// Usage returns the statistics of all the CPUs as CpuUsage.
+func (cpusAvgStats CpusAvgStats) Usage() (cpuUsage map[string]CpuUsage) {
+	cpuUsage = make(map[string]CpuUsage, len(cpusAvgStats))
+	for cpuName, cpuAvgStats := range cpusAvgStats {
+		cpuUsage[cpuName] = cpuAvgStats.Usage()
+	}
+
+	return cpuUsage
+}
+
+// cpuRawStatsKeys are the keys of the fields of a cpu line of /proc/stat, in
+// order.
+var cpuRawStatsKeys = [...]string{`user`, `nice`, `system`, `idle`, `iowait`, `irq`, `softirq`, `steal`, `guest`, `guestnice`}
+
+// maxCpuNames is the max # of names of CPUs kept by internCpuName (more than
+// any system has, but a corrupted /proc/stat can't grow it without limit).
+const maxCpuNames = 8192
+
+var (
+	cpuNamesMu sync.RWMutex
+	cpuNames   = map[string]string{}
+)
+
+// internCpuName returns name as a string, the same string every time, so the
+// names of the CPUs aren't allocated on every sample.
+func internCpuName(name []byte) string {
+	cpuNamesMu.RLock()
+	cpuName, ok := cpuNames[string(name)]
+	cpuNamesMu.RUnlock()
+	if ok {
+		return cpuName
+	}
+
+	cpuNamesMu.Lock()
+	defer cpuNamesMu.Unlock()
+	cpuName = string(name)
+	if len(cpuNames) < maxCpuNames {
+		cpuNames[cpuName] = cpuName
+	}
+
+	return cpuName
+}
+
+// readCpuRawStats reads the CPU raw stats from content, that has the content
+// of the file /proc/stat.
+func readCpuRawStats(content []byte) (cpusRawStats CpusRawStats, err error) {
+	// Count the cpu lines, so the map isn't grown
+	cpus := 0
+	for rest := content; bytes.HasPrefix(rest, []byte(`cpu`)); cpus++ {
+		_, rest = nextLine(rest)
+	}
+	cpusRawStats = make(CpusRawStats, cpus)
+
+	for len(content) > 0 {
+		var line []byte
+		line, content = nextLine(content)
+		if !bytes.HasPrefix(line, []byte(`cpu`)) {
+			// No more cpu 'lines'
+			break
+		}
+		cpuName, rawStats, err := parseCpuRawStats(line)
+		if err != nil {
+			return nil, err
+		}
+		cpusRawStats[cpuName] = rawStats
+	}
+
+	return cpusRawStats, nil
+}
+
+// parseCpuRawStats parses the CPU stats as they are in the file /proc/stat.
+// The stat file has the following format:
+//   cpu  294 0 309 10612 71 30 0 0 0 0
+// It returns:
+//   - cpuName is the name of the CPU (cpu, cpu0, cpu1, etc)
+//   - rawStats has the following format:
+//       map[User:9366 Nice:0 System:5692 Iowait:114 Steal:0 GuestNice:0
+//           Idle:1458880 Irq:806 Softirq:0 Guest:0]
+func parseCpuRawStats(stats []byte) (cpuName string, rawStats CpuRawStats,
+	err error) {
+	rawStats = make(CpuRawStats, len(cpuRawStatsKeys)+1)
+
+	field, rest := nextField(stats)
+	cpuName = internCpuName(field)
+	i := 1
+	for field, rest = nextField(rest); len(field) > 0; field, rest = nextField(rest) {
+		stat, ok := parseUintBytes(field)
+		if !ok {
+			_, err := strconv.ParseUint(string(field), 10, 64)
+			return "", nil, err
+		}
+		// The guest time is already accounted in the user (and nice) time
+		if i < 9 {
+			rawStats[`total`] += stat
+		}
+		if i <= len(cpuRawStatsKeys) {
+			rawStats[cpuRawStatsKeys[i-1]] = stat
+		}
+		i++
+	}
+
+	return cpuName, rawStats, nil
+}
+
+// getCpuAvgStats calculates average between 2 CpusRawStats samples and returns
+// the % CPU usage
+func getCpuAvgStats(firstSample CpusRawStats, secondSample CpusRawStats) (cpusAvgStats CpusAvgStats, err error) {
+	cpusAvgStats = CpusAvgStats{}
+
+	for cpuName, secondRawStats := range secondSample {
+		if !strings.HasPrefix(cpuName, `cpu`) {
+			return nil, errors.New("cpuName doesn't match the pattern")
+		}
+
+		// Skip the CPUs brought online (or reset) between the samples
+		firstRawStats, ok := firstSample[cpuName]
+		if !ok || cpuRawStatsReset(firstRawStats, secondRawStats) {
+			continue
+		}
+
+		cpuStats := CpuAvgStats{}
+		timeDelta := float64(secondRawStats[`total`] - firstRawStats[`total`])
+		if timeDelta == 0 {
+			// The samples were taken within the same tick
+			continue
+		}
+		// Calculate average between the two samples
+		for key, secondValue := range secondRawStats {
+			// Don't calculate average if the key is 'total'
+			if key == `total` {
+				continue
+			}
+			// Some counters (iowait) can go backwards a bit on the
+			// tickless kernels: they didn't advance
+			delta, _ := counterDelta(firstRawStats[key], secondValue)
+			avg := float64(delta) * 100.00 / timeDelta
+			avgStr := fmt.Sprintf("%3.2f", avg)
+			cpuStats[key], err = strconv.ParseFloat(avgStr, 64)
+			if err != nil {
+				return nil, err
+			}
+
+		}
+		cpuTotal := 100.00 - cpuStats[`idle`]
+		cpuTotalStr := fmt.Sprintf("%3.2f", cpuTotal)
+		cpuStats[`total`], err = strconv.ParseFloat(cpuTotalStr, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		cpusAvgStats[cpuName] = cpuStats
+	}
+
+	return cpusAvgStats, nil
+}
+
+// Delta returns the % CPU utilization between a previous sample and this one.
+func (cpusRawStats CpusRawStats) Delta(prev CpusRawStats) (CpusAvgStats, error) {
+	return getCpuAvgStats(prev, cpusRawStats)
+}
+
+// getCpuTimes gets the CPU raw stats of a linux system as CpuTimes.
+func getCpuTimes() (cpuTimes map[string]CpuTimes, err error) {
+	cpusRawStats, err := getCpuRawStats()
+	if err != nil {
+		return nil, err
+	}
+
+	return cpusRawStats.Times(), nil
+}
+
+// getCpuUsage calculates the % CPU usage between 2 CpuTimes samples.
+func getCpuUsage(firstSample map[string]CpuTimes, secondSample map[string]CpuTimes) (cpuUsage map[string]CpuUsage, err error) {
+	firstRawStats := make(CpusRawStats, len(firstSample))
+	for cpuName, cpuTimes := range firstSample {
+		firstRawStats[cpuName] = cpuTimes.ToMap()
+	}
+	secondRawStats := make(CpusRawStats, len(secondSample))
+	for cpuName, cpuTimes := range secondSample {
+		secondRawStats[cpuName] = cpuTimes.ToMap()
+	}
+
+	cpusAvgStats, err := getCpuAvgStats(firstRawStats, secondRawStats)
+	if err != nil {
+		return nil, err
+	}
+
+	return cpusAvgStats.Usage(), nil
+}
+
+// getCpuStatsInterval returns the % CPU utilization between 2 samples.
+// Time interval between the 2 samples is given in seconds.
+func getCpuStatsInterval(interval int64) (cpusAvgStats CpusAvgStats, err error) {
+	firstSample, err := getCpuRawStats()
+	if err != nil {
+		return nil, err
+	}
+
+	time.Sleep(time.Duration(interval) * time.Second)
+
+	secondSample, err := getCpuRawStats()
+	if err != nil {
+		return nil, err
+	}
+
+	cpusAvgStats, err = getCpuAvgStats(firstSample, secondSample)
+	if err != nil {
+		return nil, err
+	}
+
+	return cpusAvgStats, nil
+}
@@ -0,0 +1,58 @@
+// +build darwin,cgo
+
+package sysstats
+
+/*
+#include <mach/mach_host.h>
+#include <mach/mach_init.h>
+#include <mach/processor_info.h>
+#include <mach/vm_map.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"strconv"
+	"unsafe"
+)
+
+// getCpuRawStats gets the CPU raw stats of a macOS system with
+// host_processor_info (cpu0, cpu1...). Their sum is cpu. The times are in
+// clock ticks. The keys that don't exist on macOS (iowait, irq, softirq...)
+// are always 0.
+func getCpuRawStats() (cpusRawStats CpusRawStats, err error) {
+	var count C.natural_t
+	var info C.processor_info_array_t
+	var infoCount C.mach_msg_type_number_t
+	if rc := C.host_processor_info(C.host_t(C.mach_host_self()), C.PROCESSOR_CPU_LOAD_INFO, &count, &info, &infoCount); rc != C.KERN_SUCCESS {
+		return nil, errors.New("Error calling host_processor_info: " + strconv.Itoa(int(rc)))
+	}
+	defer C.vm_deallocate(C.vm_map_t(C.mach_task_self_), C.vm_address_t(uintptr(unsafe.Pointer(info))), C.vm_size_t(uintptr(infoCount)*unsafe.Sizeof(C.integer_t(0))))
+
+	ticks := (*[1 << 20]C.integer_t)(unsafe.Pointer(info))[:infoCount:infoCount]
+
+	cpusRawStats = CpusRawStats{}
+	total := CpuRawStats{}
+	for _, key := range cpuKeys {
+		total[key] = 0
+	}
+	for cpu := 0; cpu < int(count); cpu++ {
+		load := ticks[cpu*C.CPU_STATE_MAX : (cpu+1)*C.CPU_STATE_MAX]
+		rawStats := CpuRawStats{}
+		for _, key := range cpuKeys {
+			rawStats[key] = 0
+		}
+		rawStats[`user`] = uint64(uint32(load[C.CPU_STATE_USER]))
+		rawStats[`nice`] = uint64(uint32(load[C.CPU_STATE_NICE]))
+		rawStats[`system`] = uint64(uint32(load[C.CPU_STATE_SYSTEM]))
+		rawStats[`idle`] = uint64(uint32(load[C.CPU_STATE_IDLE]))
+		rawStats[`total`] = rawStats[`user`] + rawStats[`nice`] + rawStats[`system`] + rawStats[`idle`]
+		for key, value := range rawStats {
+			total[key] += value
+		}
+		cpusRawStats[`cpu`+strconv.Itoa(cpu)] = rawStats
+	}
+	cpusRawStats[`cpu`] = total
+
+	return cpusRawStats, nil
+}
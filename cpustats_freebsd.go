@@ -0,0 +1,78 @@
+// +build freebsd
+
+package sysstats
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// cpuTimeKeys are the CpuRawStats map keys of the states of kern.cp_time and
+// kern.cp_times, in their order. The other keys (iowait, softirq, steal...)
+// don't exist on FreeBSD and are always 0.
+var cpuTimeKeys = []string{`user`, `nice`, `system`, `irq`, `idle`}
+
+// getCpuRawStats gets the CPU raw stats of a FreeBSD system from the sysctls
+// kern.cp_times (cpu0, cpu1...) and kern.cp_time (cpu). The times are in
+// clock ticks.
+func getCpuRawStats() (cpusRawStats CpusRawStats, err error) {
+	cpTime, err := sysctl(`kern.cp_time`)
+	if err != nil {
+		return nil, err
+	}
+	cpTimes, err := sysctl(`kern.cp_times`)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCpTimes(cpTime, cpTimes)
+}
+
+// parseCpTimes parses the values of the sysctls kern.cp_time (the ticks of
+// the states of all the CPUs) and kern.cp_times (the ticks of the states of
+// each CPU, one after the other):
+//   1538 0 2284 395 610872
+func parseCpTimes(cpTime string, cpTimes string) (cpusRawStats CpusRawStats, err error) {
+	cpusRawStats = CpusRawStats{}
+
+	ticks := strings.Fields(cpTime)
+	if len(ticks) != len(cpuTimeKeys) {
+		return nil, errors.New("Error parsing the sysctl kern.cp_time: " + cpTime)
+	}
+	if cpusRawStats[`cpu`], err = newCpTimeRawStats(ticks); err != nil {
+		return nil, err
+	}
+
+	ticks = strings.Fields(cpTimes)
+	if len(ticks)%len(cpuTimeKeys) != 0 {
+		return nil, errors.New("Error parsing the sysctl kern.cp_times: " + cpTimes)
+	}
+	for i := 0; i < len(ticks); i += len(cpuTimeKeys) {
+		name := `cpu` + strconv.Itoa(i/len(cpuTimeKeys))
+		if cpusRawStats[name], err = newCpTimeRawStats(ticks[i : i+len(cpuTimeKeys)]); err != nil {
+			return nil, err
+		}
+	}
+
+	return cpusRawStats, nil
+}
+
+// newCpTimeRawStats returns the CPU raw stats of the ticks of the states of
+// *one* CPU (in the order of cpuTimeKeys).
+func newCpTimeRawStats(ticks []string) (rawStats CpuRawStats, err error) {
+	rawStats = CpuRawStats{}
+	for _, key := range cpuKeys {
+		rawStats[key] = 0
+	}
+	for i, key := range cpuTimeKeys {
+		value, err := strconv.ParseUint(ticks[i], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		rawStats[key] = value
+		rawStats[`total`] += value
+	}
+
+	return rawStats, nil
+}
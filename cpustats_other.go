@@ -0,0 +1,13 @@
+// +build !linux,!solaris,!aix,!freebsd,!darwin aix,!cgo darwin,!cgo
+
+package sysstats
+
+import (
+	"errors"
+	"runtime"
+)
+
+// getCpuRawStats gets the CPU raw stats of the system
+func getCpuRawStats() (cpusRawStats CpusRawStats, err error) {
+	return nil, errors.New("getCpuRawStats: " + runtime.GOOS + " not supported yet")
+}
@@ -0,0 +1,63 @@
+// +build solaris
+
+package sysstats
+
+import (
+	"strconv"
+)
+
+// cpuKstatKeys are the CpuRawStats map keys of the statistics of the cpu:N:sys
+// kstats. The other keys (nice, irq, softirq, steal...) don't exist on
+// illumos/Solaris and are always 0.
+var cpuKstatKeys = map[string]string{
+	`cpu_ticks_user`:   `user`,
+	`cpu_ticks_kernel`: `system`,
+	`cpu_ticks_idle`:   `idle`,
+	`cpu_ticks_wait`:   `iowait`,
+}
+
+// getCpuRawStats gets the CPU raw stats of an illumos/Solaris system from the
+// cpu:N:sys kstats. The CPUs are named as on linux (cpu0, cpu1...) and their
+// sum is cpu. The times are in clock ticks.
+func getCpuRawStats() (cpusRawStats CpusRawStats, err error) {
+	kstats, err := getKstats(`cpu:::/^cpu_ticks_/`)
+	if err != nil {
+		return nil, err
+	}
+
+	return kstatsToCpuRawStats(kstats)
+}
+
+// kstatsToCpuRawStats returns the CPU raw stats of the cpu:N:sys kstats.
+func kstatsToCpuRawStats(kstats []kstat) (cpusRawStats CpusRawStats, err error) {
+	cpusRawStats = CpusRawStats{}
+	total := CpuRawStats{}
+	for _, key := range cpuKeys {
+		total[key] = 0
+	}
+
+	for _, k := range kstats {
+		if k.Module != `cpu` || k.Name != `sys` {
+			continue
+		}
+		rawStats := CpuRawStats{}
+		for _, key := range cpuKeys {
+			rawStats[key] = 0
+		}
+		for stat, key := range cpuKstatKeys {
+			value, err := k.uint(stat)
+			if err != nil {
+				return nil, err
+			}
+			rawStats[key] = value
+			rawStats[`total`] += value
+		}
+		for key, value := range rawStats {
+			total[key] += value
+		}
+		cpusRawStats[`cpu`+strconv.Itoa(k.Instance)] = rawStats
+	}
+	cpusRawStats[`cpu`] = total
+
+	return cpusRawStats, nil
+}
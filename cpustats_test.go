@@ -0,0 +1,60 @@
+package sysstats
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadCpuRawStatsAllocs(t *testing.T) {
+	content, err := ioutil.ReadFile(filepath.Join("testdata", "proc", "stat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The floor is the maps of the result: CpuRawStats is a map in the API
+	// and its 11 stats need 4 allocations per cpu (the header, the directory,
+	// the table and the groups of the map), plus 2 of CpusRawStats. The names
+	// of the cpus are interned and the map of the cpus is pre-sized. The
+	// fixture has 5 cpus (cpu and cpu0-3).
+	if allocs := testing.AllocsPerRun(100, func() { readCpuRawStats(content) }); allocs > 5*4+2 {
+		t.Errorf("readCpuRawStats allocates %.0f times, want at most %d", allocs, 5*4+2)
+	}
+}
+
+// BenchmarkReadCpuRawStats measures readCpuRawStats with the fixture of
+// testdata/proc. Its allocations are the floor of TestReadCpuRawStatsAllocs:
+// the map of every cpu and the map of the cpus.
+func BenchmarkReadCpuRawStats(b *testing.B) {
+	content, err := ioutil.ReadFile(filepath.Join("testdata", "proc", "stat"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		readCpuRawStats(content)
+	}
+}
+
+func FuzzReadCpuRawStats(f *testing.F) {
+	content, err := ioutil.ReadFile(filepath.Join("testdata", "proc", "stat"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(content)
+	f.Add([]byte("cpu  2255034 3871 617405 55914722 44837 0 27131 0 0 0\ncpu0 561937 969 15"))
+	f.Add([]byte("cpu  2255034 3871 617405\n"))
+	f.Add([]byte("cpu\ncpu0\n"))
+	f.Add([]byte("cpu  99999999999999999999999 -1 0x10\n"))
+	f.Fuzz(func(t *testing.T, content []byte) {
+		cpusRawStats, err := readCpuRawStats(content)
+		if err != nil {
+			return
+		}
+		for name, rawStats := range cpusRawStats {
+			if rawStats[`user`] > rawStats[`total`] {
+				t.Errorf("%s: user %d over total %d", name, rawStats[`user`], rawStats[`total`])
+			}
+		}
+	})
+}
@@ -0,0 +1,144 @@
+// +build linux
+
+package sysstats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// CrashReportConfig represents the configuration of the crash reports.
+type CrashReportConfig struct {
+	Dir       string          // Directory the reports are written to, e.g. the one of the crash dumps (default the working directory)
+	History   *History        // History the recent snapshots are got from (nil if there isn't one)
+	Window    time.Duration   // Window of the recent snapshots (default 5 minutes)
+	Redaction RedactionPolicy // Sensitive values removed from the report (none by default)
+
+	// OnWritten is called after every report with the path of its file, or
+	// the error if it couldn't be written (nil if it isn't needed). The
+	// package doesn't write anything to stderr, so the application decides
+	// how to log it.
+	OnWritten func(path string, err error)
+}
+
+// CrashReport represents the statistics of the system when an application
+// crashed (or was asked to report with a signal), so the post-mortem of a
+// crash dump has the context of the system: a one-shot bundle (all the
+// collectors and a snapshot) and the snapshots of the recent window.
+type CrashReport struct {
+	CreatedAt      time.Time          `json:"createdat"`      // When the report was created
+	Pid            int                `json:"pid"`            // Pid of the application
+	Executable     string             `json:"executable"`     // Path of the executable of the application
+	Reason         string             `json:"reason"`         // Why the report was written (e.g. the value of the panic)
+	Stack          string             `json:"stack"`          // Stack of the goroutine that wrote the report
+	Bundle         Bundle             `json:"bundle"`         // One-shot diagnostic bundle
+	Recent         []Snapshot         `json:"recent"`         // Snapshots of the recent window (oldest first)
+	RecentAvgStats []SnapshotAvgStats `json:"recentavgstats"` // Stats between consecutive recent snapshots
+}
+
+// writeCrashReport collects a crash report and writes it as JSON to the
+// file sysstats-crash-<pid>-<time>.json of the directory of the
+// configuration. It returns the path of the file.
+func writeCrashReport(config CrashReportConfig, reason string) (path string, err error) {
+	if config.Dir == "" {
+		config.Dir = "."
+	}
+	if config.Window <= 0 {
+		config.Window = 5 * time.Minute
+	}
+
+	report := CrashReport{
+		CreatedAt: time.Now(),
+		Pid:       os.Getpid(),
+		Reason:    reason,
+		Stack:     string(debug.Stack()),
+	}
+	report.Executable, _ = os.Executable()
+	if report.Bundle, err = getBundle(BundleConfig{Samples: 1, Redaction: config.Redaction}); err != nil {
+		return "", err
+	}
+	report.Recent = []Snapshot{}
+	report.RecentAvgStats = []SnapshotAvgStats{}
+	if config.History != nil {
+		report.Recent = config.History.Snapshots(Last(config.Window))
+		for i := 1; i < len(report.Recent); i++ {
+			if avgStats, err := getSnapshotAvgStats(report.Recent[i-1], report.Recent[i]); err == nil {
+				report.RecentAvgStats = append(report.RecentAvgStats, avgStats)
+			}
+		}
+	}
+
+	content, err := json.MarshalIndent(report, ``, `  `)
+	if err != nil {
+		return "", err
+	}
+	name := "sysstats-crash-" + strconv.Itoa(report.Pid) + "-" + report.CreatedAt.Format("20060102T150405") + ".json"
+	path = filepath.Join(config.Dir, name)
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// RecoverCrashReport writes a crash report if the goroutine is panicking and
+// then panics again with the same value, so the application still crashes
+// (and dumps its goroutines). It must be deferred directly, e.g.
+//   defer sysstats.RecoverCrashReport(config)
+// at the beginning of main or of a goroutine.
+func RecoverCrashReport(config CrashReportConfig) {
+	value := recover()
+	if value == nil {
+		return
+	}
+	path, err := writeCrashReport(config, fmt.Sprint("panic: ", value))
+	if config.OnWritten != nil {
+		config.OnWritten(path, err)
+	}
+
+	panic(value)
+}
+
+// NotifyCrashReport writes a crash report every time the application
+// receives one of the signals (default SIGQUIT), until stop is called.
+// SIGQUIT is raised again after the report is written, so the runtime still
+// dumps the goroutines and exits as it does by default.
+func NotifyCrashReport(config CrashReportConfig, signals ...os.Signal) (stop func()) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGQUIT}
+	}
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, signals...)
+
+	go func() {
+		for {
+			select {
+			case sig := <-ch:
+				path, err := writeCrashReport(config, "signal: "+sig.String())
+				if config.OnWritten != nil {
+					config.OnWritten(path, err)
+				}
+				if sig == syscall.SIGQUIT {
+					signal.Reset(syscall.SIGQUIT)
+					syscall.Kill(os.Getpid(), syscall.SIGQUIT)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
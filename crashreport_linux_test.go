@@ -0,0 +1,43 @@
+// +build linux
+
+package sysstats
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecoverCrashReportOnWritten(t *testing.T) {
+	dir, err := ioutil.TempDir(``, `crashreport`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var written string
+	var writtenErr error
+	config := CrashReportConfig{Dir: dir, OnWritten: func(path string, err error) {
+		written, writtenErr = path, err
+	}}
+	func() {
+		defer func() {
+			if value := recover(); value != `boom` {
+				t.Errorf("Panic value %v, want boom", value)
+			}
+		}()
+		defer RecoverCrashReport(config)
+		panic(`boom`)
+	}()
+
+	if writtenErr != nil {
+		t.Fatal(writtenErr)
+	}
+	if filepath.Dir(written) != dir {
+		t.Fatalf("Report written to %q, want a file of %s", written, dir)
+	}
+	if _, err := os.Stat(written); err != nil {
+		t.Error(err)
+	}
+}
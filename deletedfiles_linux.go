@@ -0,0 +1,89 @@
+// +build linux
+
+package sysstats
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// DeletedFile represents *one* deleted file a process still has open.
+type DeletedFile struct {
+	Fd   int    `json:"fd"`   // File descriptor (the first one if the file is open more than once)
+	Path string `json:"path"` // Path the file had before being deleted
+	Size uint64 `json:"size"` // Size of the file in bytes
+}
+
+// DeletedOpenFiles represents the deleted files *one* process still has
+// open. Their space isn't freed until they are closed, which is why `df` and
+// `du` disagree.
+type DeletedOpenFiles struct {
+	Pid       int           `json:"pid"`       // Process id
+	Comm      string        `json:"comm"`      // Command name
+	Files     []DeletedFile `json:"files"`     // Deleted files open
+	TotalSize uint64        `json:"totalsize"` // Space used by the deleted files in bytes
+}
+
+// getDeletedOpenFiles gets the processes with deleted regular files still
+// open from /proc/[pid]/fd, sorted by the space they hold (largest first).
+// The processes whose file descriptors can't be read (other users' processes
+// when not run as root) are skipped. Memory file descriptors (memfd) don't use
+// disk space so they are skipped too.
+func getDeletedOpenFiles() (deletedOpenFilesArr []DeletedOpenFiles, err error) {
+	pids, err := getPids()
+	if err != nil {
+		return nil, err
+	}
+
+	deletedOpenFilesArr = []DeletedOpenFiles{}
+	for _, pid := range pids {
+		fdDir := procPath(strconv.Itoa(pid), "fd") + "/"
+		fds, err := readProcDirNames(strconv.Itoa(pid), "fd")
+		if err != nil {
+			continue
+		}
+
+		deletedOpenFiles := DeletedOpenFiles{Pid: pid, Files: []DeletedFile{}}
+		// The same file can be open more than once (dup, fork...)
+		seen := map[[2]uint64]bool{}
+		for _, name := range fds {
+			target, err := os.Readlink(fdDir + name)
+			if err != nil || !strings.HasSuffix(target, ` (deleted)`) || strings.HasPrefix(target, `/memfd:`) {
+				continue
+			}
+			// Stat follows the link to the open file
+			info, err := os.Stat(fdDir + name)
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+				key := [2]uint64{uint64(stat.Dev), stat.Ino}
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+			}
+			fd, _ := strconv.Atoi(name)
+			deletedOpenFiles.Files = append(deletedOpenFiles.Files, DeletedFile{
+				Fd:   fd,
+				Path: strings.TrimSuffix(target, ` (deleted)`),
+				Size: uint64(info.Size()),
+			})
+			deletedOpenFiles.TotalSize += uint64(info.Size())
+		}
+
+		if len(deletedOpenFiles.Files) > 0 {
+			deletedOpenFiles.Comm, _ = getProcReader().readString(strconv.Itoa(pid), "comm")
+			deletedOpenFilesArr = append(deletedOpenFilesArr, deletedOpenFiles)
+		}
+	}
+
+	sort.Slice(deletedOpenFilesArr, func(i, j int) bool {
+		return deletedOpenFilesArr[i].TotalSize > deletedOpenFilesArr[j].TotalSize
+	})
+
+	return deletedOpenFilesArr, nil
+}
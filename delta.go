@@ -0,0 +1,45 @@
+package sysstats
+
+import (
+	"math"
+)
+
+// wrap32Threshold is the min value a counter has to have to be handled as a
+// 32-bit counter that wrapped around when it goes backwards. The counters of
+// 32-bit kernels (/proc/diskstats, /proc/net/dev) and of some network drivers
+// are 32-bit, and they wrap around after 4 GiB (or 49 days of milliseconds).
+const wrap32Threshold = 1 << 31
+
+// counterDelta returns the increase of a counter between 2 samples. A counter
+// that went backwards from the upper half of the 32-bit range wrapped around,
+// and the increase is the one across the wraparound. Otherwise the counter
+// was reset (the device was replaced, the driver reloaded...) and ok is
+// false.
+func counterDelta(first uint64, second uint64) (delta uint64, ok bool) {
+	if second >= first {
+		return second - first, true
+	}
+	if first >= wrap32Threshold && first <= math.MaxUint32 {
+		return math.MaxUint32 - first + second + 1, true
+	}
+
+	return 0, false
+}
+
+// counterRate returns the increase per second of a counter between 2 samples
+// taken seconds apart. The counters that were reset have a rate of 0.
+func counterRate(first uint64, second uint64, seconds float64) float64 {
+	if seconds <= 0 {
+		return 0
+	}
+	delta, _ := counterDelta(first, second)
+
+	return float64(delta) / seconds
+}
+
+// counterReset returns true if a counter was reset between 2 samples (it went
+// backwards and it isn't a 32-bit wraparound).
+func counterReset(first uint64, second uint64) bool {
+	_, ok := counterDelta(first, second)
+	return !ok
+}
@@ -0,0 +1,215 @@
+// +build linux
+
+package sysstats
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DirScannerConfig represents the configuration of a directory scanner.
+type DirScannerConfig struct {
+	Root        string // Directory (usually a mount point) to scan. The scan doesn't cross file system boundaries
+	TopN        int    // # of files and directories reported in each list (default 10)
+	MaxDepth    int    // Max depth below Root of the directories reported (default 4)
+	MinFileSize uint64 // Min disk usage in bytes of the files tracked between scans (default 1 MiB)
+}
+
+// DirUsage represents the disk usage of *one* file or directory.
+type DirUsage struct {
+	Path       string  `json:"path"`       // Path of the file or directory
+	Usage      uint64  `json:"usage"`      // Disk usage in bytes
+	Growth     int64   `json:"growth"`     // Disk usage growth in bytes since the previous scan
+	GrowthRate float64 `json:"growthrate"` // Disk usage growth in bytes per second since the previous scan
+}
+
+// DirScanResult represents the result of *one* scan of a directory tree.
+// The growth lists are empty on the first scan.
+type DirScanResult struct {
+	Root         string        `json:"root"`         // Directory scanned
+	ScannedAt    time.Time     `json:"scannedat"`    // When the scan started
+	Duration     time.Duration `json:"duration"`     // Time the scan took
+	Interval     time.Duration `json:"interval"`     // Time since the previous scan (0 on the first scan)
+	Usage        uint64        `json:"usage"`        // Disk usage in bytes of the tree
+	LargestFiles []DirUsage    `json:"largestfiles"` // Largest files
+	LargestDirs  []DirUsage    `json:"largestdirs"`  // Largest directories (up to MaxDepth)
+	FastestFiles []DirUsage    `json:"fastestfiles"` // Files growing fastest since the previous scan
+	FastestDirs  []DirUsage    `json:"fastestdirs"`  // Directories growing fastest since the previous scan
+}
+
+// DirScanner scans a directory tree and reports its largest files and
+// directories. It keeps the disk usage of the previous scan so the following
+// scans also report the files and directories growing fastest, which helps
+// to attribute disk-full incidents quickly.
+type DirScanner struct {
+	config    DirScannerConfig
+	mu        sync.Mutex
+	lastScan  time.Time
+	lastFiles map[string]uint64
+	lastDirs  map[string]uint64
+}
+
+// NewDirScanner returns a DirScanner for the given configuration.
+func NewDirScanner(config DirScannerConfig) *DirScanner {
+	if config.TopN <= 0 {
+		config.TopN = 10
+	}
+	if config.MaxDepth <= 0 {
+		config.MaxDepth = 4
+	}
+	if config.MinFileSize == 0 {
+		config.MinFileSize = 1 << 20
+	}
+
+	return &DirScanner{config: config}
+}
+
+// Scan scans the directory tree and compares it with the previous scan.
+func (s *DirScanner) Scan() (result DirScanResult, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.config.Root == `` {
+		return DirScanResult{}, errors.New("The directory scanner doesn't have a root")
+	}
+	root := filepath.Clean(s.config.Root)
+	if _, err := os.Lstat(root); err != nil {
+		return DirScanResult{}, err
+	}
+
+	result = DirScanResult{Root: root, ScannedAt: time.Now()}
+	files := map[string]uint64{}
+	dirs := map[string]uint64{root: 0}
+	result.Usage = walkDiskUsage(root, func(path string, info os.FileInfo, usage uint64) {
+		if info.IsDir() {
+			return
+		}
+		if usage >= s.config.MinFileSize {
+			files[path] = usage
+		}
+		// Add the usage to the ancestors up to MaxDepth
+		dir := filepath.Dir(path)
+		for depth := dirDepth(root, dir); depth >= 0; depth-- {
+			if depth <= s.config.MaxDepth {
+				dirs[dir] += usage
+			}
+			dir = filepath.Dir(dir)
+		}
+	})
+	result.Duration = time.Since(result.ScannedAt)
+
+	result.LargestFiles = topDirUsage(files, nil, 0, s.config.TopN, false)
+	result.LargestDirs = topDirUsage(dirs, nil, 0, s.config.TopN, false)
+	if !s.lastScan.IsZero() {
+		result.Interval = result.ScannedAt.Sub(s.lastScan)
+		result.FastestFiles = topDirUsage(files, s.lastFiles, result.Interval, s.config.TopN, true)
+		result.FastestDirs = topDirUsage(dirs, s.lastDirs, result.Interval, s.config.TopN, true)
+	} else {
+		result.FastestFiles = []DirUsage{}
+		result.FastestDirs = []DirUsage{}
+	}
+
+	s.lastScan, s.lastFiles, s.lastDirs = result.ScannedAt, files, dirs
+
+	return result, nil
+}
+
+// topDirUsage returns the n entries of usages with the highest usage (or the
+// highest growth since last if byGrowth is true). Only the entries that grew
+// are returned when sorting by growth.
+func topDirUsage(usages map[string]uint64, last map[string]uint64, interval time.Duration, n int, byGrowth bool) (top []DirUsage) {
+	top = make([]DirUsage, 0, len(usages))
+	for path, usage := range usages {
+		dirUsage := DirUsage{Path: path, Usage: usage}
+		if last != nil {
+			dirUsage.Growth = int64(usage) - int64(last[path])
+			if interval > 0 {
+				dirUsage.GrowthRate = float64(dirUsage.Growth) / interval.Seconds()
+			}
+		}
+		if byGrowth && dirUsage.Growth <= 0 {
+			continue
+		}
+		top = append(top, dirUsage)
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		if byGrowth && top[i].Growth != top[j].Growth {
+			return top[i].Growth > top[j].Growth
+		}
+		if top[i].Usage != top[j].Usage {
+			return top[i].Usage > top[j].Usage
+		}
+		return top[i].Path < top[j].Path
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+
+	return top
+}
+
+// dirDepth returns the # of levels dir is below root (0 if it's root).
+func dirDepth(root string, dir string) int {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == `.` {
+		return 0
+	}
+
+	return strings.Count(rel, `/`) + 1
+}
+
+// dirDiskUsage returns the disk space (bytes) used by the files of a
+// directory tree without crossing file system boundaries. Hard links are only
+// counted once and files that can't be read are skipped.
+func dirDiskUsage(dir string) (usage uint64) {
+	return walkDiskUsage(dir, nil)
+}
+
+// walkDiskUsage walks a directory tree like dirDiskUsage and calls visit (if
+// it isn't nil) with the disk usage of every file and directory.
+func walkDiskUsage(dir string, visit func(path string, info os.FileInfo, usage uint64)) (usage uint64) {
+	root, err := os.Lstat(dir)
+	if err != nil {
+		return 0
+	}
+	rootDev := root.Sys().(*syscall.Stat_t).Dev
+	seen := map[uint64]bool{}
+
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		stat, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return nil
+		}
+		if stat.Dev != rootDev {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if stat.Nlink > 1 && !info.IsDir() {
+			if seen[stat.Ino] {
+				return nil
+			}
+			seen[stat.Ino] = true
+		}
+		// Blocks are 512 bytes units
+		fileUsage := uint64(stat.Blocks) * 512
+		usage += fileUsage
+		if visit != nil {
+			visit(path, info, fileUsage)
+		}
+		return nil
+	})
+
+	return usage
+}
@@ -0,0 +1,274 @@
+package sysstats
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DiskRawStats represents the disk IO raw statistics of a linux system.
+type DiskRawStats struct {
+	Major          int    `json:"major"`          // Major number for the disk
+	Minor          int    `json:"minor"`          // Minor number for the disk
+	Name           string `json:"name"`           // Disk name
+	ReadIOs        uint64 `json:"readios"`        // # of reads completed since boot
+	ReadMerges     uint64 `json:"readmerges"`     // # of reads merged since boot
+	ReadSectors    uint64 `json:"readsectors"`    // # of sectors read since boot
+	ReadTicks      uint64 `json:"readticks"`      // # of milliseconds spent reading since boot
+	WriteIOs       uint64 `json:"writeios"`       // # of writes completed since boot
+	WriteMerges    uint64 `json:"writemerges"`    // # of writes merged since boot
+	WriteSectors   uint64 `json:"writesectors"`   // # of sectors written since boot
+	WriteTicks     uint64 `json:"writeticks"`     // # of milliseconds spent writing since boot
+	InFlight       uint64 `json:"inflight"`       // # of I/Os currently in progress
+	IOTicks        uint64 `json:"ioticks"`        // # of milliseconds spent doing I/Os since boot
+	TimeInQueue    uint64 `json:"timeinqueue"`    // Weighted # of milliseconds spent doing I/Os since boot
+	DiscardIOs     uint64 `json:"discardios"`     // # of discards completed since boot (since 4.18)
+	DiscardMerges  uint64 `json:"discardmerges"`  // # of discards merged since boot (since 4.18)
+	DiscardSectors uint64 `json:"discardsectors"` // # of sectors discarded since boot (since 4.18)
+	DiscardTicks   uint64 `json:"discardticks"`   // # of milliseconds spent discarding since boot (since 4.18)
+	FlushIOs       uint64 `json:"flushios"`       // # of flush requests completed since boot (since 5.5)
+	FlushTicks     uint64 `json:"flushticks"`     // # of milliseconds spent flushing since boot (since 5.5)
+	SampleTime     int64  `json:"sampletime"`     // Time when the sample was taken
+}
+
+// DiskFilter represents which disks are returned by the disk IO stats
+// collectors.
+type DiskFilter struct {
+	IncludeVirtual bool     // Whether to include the loop and ram devices (skipped by default)
+	Devices        []string // Names of the disks to return (empty means all)
+}
+
+// DiskAvgStats represents the average disk IO statistics (per second) of a
+// linux system.
+type DiskAvgStats struct {
+	Major       int     `json:"major"`       // Major number for the disk
+	Minor       int     `json:"minor"`       // Minor number for the disk
+	Name        string  `json:"name"`        // Disk name
+	ReadIOs     float64 `json:"readios"`     // # of reads completed per second
+	ReadMerges  float64 `json:"readmerges"`  // # of reads merged per second
+	ReadBytes   float64 `json:"readbytes"`   // # of bytes read per second
+	WriteIOs    float64 `json:"writeios"`    // # of writes completed per second
+	WriteMerges float64 `json:"writemerges"` // # of writes merged per second
+	WriteBytes  float64 `json:"writebytes"`  // # of bytes written per second
+	InFlight    uint64  `json:"inflight"`    // # of I/Os currently in progress
+	IOTicks     uint64  `json:"ioticks"`     // # of milliseconds spent doing I/Os
+	TimeInQueue uint64  `json:"timeinqueue"` // Weighted # of milliseconds spent doing I/Os
+	Util        float64 `json:"util"`        // % of time the disk was doing I/Os
+	DiscardIOs  float64 `json:"discardios"`  // # of discards completed per second
+	FlushIOs    float64 `json:"flushios"`    // # of flush requests completed per second
+}
+
+// getDiskRawStats gets the disk IO stats of a linux system from the
+// file /proc/diskstats. The loop and ram devices are skipped.
+func getDiskRawStats() (diskRawStatsArr []DiskRawStats, err error) {
+	return getFilteredDiskRawStats(DiskFilter{})
+}
+
+// filterDiskRawStats returns the disks that match filter.
+func filterDiskRawStats(diskRawStatsArr []DiskRawStats, filter DiskFilter) (filtered []DiskRawStats) {
+	devices := make(map[string]bool, len(filter.Devices))
+	for _, device := range filter.Devices {
+		devices[device] = true
+	}
+
+	filtered = make([]DiskRawStats, 0, len(diskRawStatsArr))
+	for _, diskRawStats := range diskRawStatsArr {
+		if len(devices) > 0 && !devices[diskRawStats.Name] {
+			continue
+		}
+		if !filter.IncludeVirtual && (strings.HasPrefix(diskRawStats.Name, `loop`) || strings.HasPrefix(diskRawStats.Name, `ram`)) {
+			continue
+		}
+		filtered = append(filtered, diskRawStats)
+	}
+
+	return filtered
+}
+
+// readDiskRawStats reads the disk IO stats from content, that has the content
+// of the file /proc/diskstats. now is the time of the sample.
+func readDiskRawStats(content []byte, now int64) (diskRawStatsArr []DiskRawStats, err error) {
+	diskRawStatsArr = make([]DiskRawStats, 0, countLines(content))
+
+	for len(content) > 0 {
+		var line []byte
+		line, content = nextLine(content)
+		if field, _ := nextField(line); len(field) == 0 {
+			// Blank line
+			continue
+		}
+		diskRawStats, err := parseDiskRawStats(line)
+		if err != nil {
+			return diskRawStatsArr, err
+		}
+		diskRawStats.SampleTime = now
+		diskRawStatsArr = append(diskRawStatsArr, diskRawStats)
+	}
+
+	return diskRawStatsArr, nil
+}
+
+// parseDiskRawStats parses the disk stats.
+// The file /proc/diskstats has the following format:
+//   7       0 loop0 0 0 0 0 0 0 0 0 0 0 0
+//   7       1 loop1 0 0 0 0 0 0 0 0 0 0 0
+//   7       2 loop2 0 0 0 0 0 0 0 0 0 0 0
+//   7       3 loop3 0 0 0 0 0 0 0 0 0 0 0
+//   7       4 loop4 0 0 0 0 0 0 0 0 0 0 0
+//   7       5 loop5 0 0 0 0 0 0 0 0 0 0 0
+//   7       6 loop6 0 0 0 0 0 0 0 0 0 0 0
+//   7       7 loop7 0 0 0 0 0 0 0 0 0 0 0
+//   8       0 sda 4222 4373 293854 48992 676 1024 13428 2016 0 1744 51004
+//   8       1 sda1 287 322 2296 68 6 0 12 0 0 68 68
+//   8       2 sda2 2 0 4 0 0 0 0 0 0 0 0
+//   8       5 sda5 3748 4051 290074 48904 587 1024 13416 2016 0 1676 50916
+// 252       0 dm-0 7516 0 287642 65724 1613 0 13416 4212 0 1644 69936
+// 252       1 dm-1 224 0 1792 28 0 0 0 0 0 28 28
+// Since 4.18 there are 4 more fields with the discard stats and since 5.5 2
+// more with the flush stats.
+func parseDiskRawStats(stats []byte) (diskRawStats DiskRawStats, err error) {
+	diskRawStats = DiskRawStats{}
+
+	// Counters of the disk, in the order of the fields after the name
+	counters := [...]*uint64{
+		&diskRawStats.ReadIOs, &diskRawStats.ReadMerges, &diskRawStats.ReadSectors, &diskRawStats.ReadTicks,
+		&diskRawStats.WriteIOs, &diskRawStats.WriteMerges, &diskRawStats.WriteSectors, &diskRawStats.WriteTicks,
+		&diskRawStats.InFlight, &diskRawStats.IOTicks, &diskRawStats.TimeInQueue,
+		&diskRawStats.DiscardIOs, &diskRawStats.DiscardMerges, &diskRawStats.DiscardSectors, &diskRawStats.DiscardTicks,
+		&diskRawStats.FlushIOs, &diskRawStats.FlushTicks,
+	}
+
+	// Parse fields
+	i := 0
+	for field, rest := nextField(stats); len(field) > 0; field, rest = nextField(rest) {
+		switch {
+		case i == 0:
+			major, _ := parseUintBytes(field)
+			diskRawStats.Major = int(major)
+		case i == 1:
+			minor, _ := parseUintBytes(field)
+			diskRawStats.Minor = int(minor)
+		case i == 2:
+			diskRawStats.Name = string(field)
+		case i-3 < len(counters):
+			*counters[i-3], _ = parseUintBytes(field)
+		}
+		i++
+	}
+
+	// Check there are at least 14 fields
+	if i < 14 {
+		return DiskRawStats{}, errors.New("Couldn't parse disk stats because there are less than 14 fields")
+	}
+
+	return diskRawStats, nil
+}
+
+// diskAvgStats calculates the average between 2 DiskRawStats samples taken
+// seconds apart and returns a DiskAvgStats variable with the number of IOs
+// per second.
+func diskAvgStats(firstSample DiskRawStats, secondSample DiskRawStats, seconds float64) (diskAvgStats DiskAvgStats, err error) {
+	diskAvgStats = DiskAvgStats{}
+
+	// Check the samples are from the same disk
+	if firstSample.Major != secondSample.Major ||
+		firstSample.Minor != secondSample.Minor ||
+		firstSample.Name != secondSample.Name {
+		msg := fmt.Sprintf("The samples are from different disks: \n\tfirstSample -> %d %d %s \n\t"+
+			"secondSample -> %d %d %s\n", firstSample.Major, firstSample.Minor, firstSample.Name,
+			secondSample.Major, secondSample.Minor, secondSample.Name)
+		return DiskAvgStats{}, errors.New(msg)
+	} else {
+		diskAvgStats.Major = firstSample.Major
+		diskAvgStats.Minor = firstSample.Minor
+		diskAvgStats.Name = firstSample.Name
+	}
+
+	// Calculate average between the 2 samples
+	diskAvgStats.ReadIOs = counterRate(firstSample.ReadIOs, secondSample.ReadIOs, seconds)
+	diskAvgStats.ReadMerges = counterRate(firstSample.ReadMerges, secondSample.ReadMerges, seconds)
+	diskAvgStats.ReadBytes = counterRate(firstSample.ReadSectors, secondSample.ReadSectors, seconds) * 512
+	diskAvgStats.WriteIOs = counterRate(firstSample.WriteIOs, secondSample.WriteIOs, seconds)
+	diskAvgStats.WriteMerges = counterRate(firstSample.WriteMerges, secondSample.WriteMerges, seconds)
+	diskAvgStats.WriteBytes = counterRate(firstSample.WriteSectors, secondSample.WriteSectors, seconds) * 512
+
+	diskAvgStats.DiscardIOs = counterRate(firstSample.DiscardIOs, secondSample.DiscardIOs, seconds)
+	diskAvgStats.FlushIOs = counterRate(firstSample.FlushIOs, secondSample.FlushIOs, seconds)
+
+	diskAvgStats.InFlight = secondSample.InFlight
+	diskAvgStats.IOTicks, _ = counterDelta(firstSample.IOTicks, secondSample.IOTicks)
+	diskAvgStats.TimeInQueue, _ = counterDelta(firstSample.TimeInQueue, secondSample.TimeInQueue)
+	diskAvgStats.Util = float64(diskAvgStats.IOTicks) * 100 / (seconds * 1000)
+	if diskAvgStats.Util > 100 {
+		diskAvgStats.Util = 100
+	}
+
+	return diskAvgStats, nil
+}
+
+// getDiskAvgStats calculates the average between 2 arrays of DiskRawStats
+// samples and returns an array of DiskAvgStats. The interval is got from the
+// time of the samples (in whole seconds).
+func getDiskAvgStats(firstSampleArr []DiskRawStats, secondSampleArr []DiskRawStats) (diskAvgStatsArr []DiskAvgStats, err error) {
+	return getDiskAvgStatsOver(firstSampleArr, secondSampleArr, 0)
+}
+
+// getDiskAvgStatsOver calculates the average between 2 arrays of
+// DiskRawStats samples taken seconds apart (e.g. measured with a monotonic
+// clock). If seconds is 0 it's got from the time of the samples.
+func getDiskAvgStatsOver(firstSampleArr []DiskRawStats, secondSampleArr []DiskRawStats, seconds float64) (diskAvgStatsArr []DiskAvgStats, err error) {
+
+	diskAvgStatsArr = make([]DiskAvgStats, 0, len(firstSampleArr))
+
+	for _, firstSample := range firstSampleArr {
+		diskName := firstSample.Name
+		for _, secondSample := range secondSampleArr {
+			if secondSample.Name == diskName {
+				// Skip the disks replaced between the samples
+				if diskRawStatsReset(firstSample, secondSample) {
+					break
+				}
+				timeDelta := seconds
+				if timeDelta == 0 {
+					timeDelta = float64(secondSample.SampleTime - firstSample.SampleTime)
+				}
+				if timeDelta <= 0 {
+					// The samples were taken within the same second (or
+					// the clock went backwards)
+					break
+				}
+				diskAvgStats, err := diskAvgStats(firstSample, secondSample, timeDelta)
+				if err != nil {
+					return nil, err
+				}
+				diskAvgStatsArr = append(diskAvgStatsArr, diskAvgStats)
+				break
+			} else {
+				continue
+			}
+		}
+	}
+
+	return diskAvgStatsArr, nil
+}
+
+// getDiskStatsInterval returns the IO average between 2 samples.
+// Time interval between the 2 samples is given in seconds.
+func getDiskStatsInterval(interval int64) (diskAvgStatsArr []DiskAvgStats, err error) {
+	firstSampleArr, err := getDiskRawStats()
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+
+	time.Sleep(time.Duration(interval) * time.Second)
+
+	secondSampleArr, err := getDiskRawStats()
+	if err != nil {
+		return nil, err
+	}
+
+	// The interval is measured with the monotonic clock
+	return getDiskAvgStatsOver(firstSampleArr, secondSampleArr, time.Since(start).Seconds())
+}
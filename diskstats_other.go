@@ -0,0 +1,14 @@
+// +build !linux,!aix aix,!cgo
+
+package sysstats
+
+import (
+	"errors"
+	"runtime"
+)
+
+// getFilteredDiskRawStats gets the disk IO stats of the disks of the system
+// that match filter
+func getFilteredDiskRawStats(filter DiskFilter) (diskRawStatsArr []DiskRawStats, err error) {
+	return nil, errors.New("getFilteredDiskRawStats: " + runtime.GOOS + " not supported yet")
+}
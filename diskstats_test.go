@@ -0,0 +1,81 @@
+package sysstats
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadDiskRawStatsAllocs(t *testing.T) {
+	content, err := ioutil.ReadFile(filepath.Join("testdata", "proc", "diskstats"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The slice and the names of the 5 disks of the fixture
+	if allocs := testing.AllocsPerRun(100, func() { readDiskRawStats(content, 0) }); allocs > 6 {
+		t.Errorf("readDiskRawStats allocates %.0f times, want at most 6", allocs)
+	}
+}
+
+func TestReadDiskRawStatsBlankLines(t *testing.T) {
+	tests := []struct {
+		content string
+		disks   []string
+	}{
+		{"   8       0 sda 4222 4373 293854 48992 676 1024 13428 2016 0 1744 51004\n", []string{`sda`}},
+		{"\n   8       0 sda 4222 4373 293854 48992 676 1024 13428 2016 0 1744 51004\n\n", []string{`sda`}},
+		{"   8       0 sda 4222 4373 293854 48992 676 1024 13428 2016 0 1744 51004\n \t\n   8       1 sda1 287 322 2296 68 6 0 12 0 0 68 68", []string{`sda`, `sda1`}},
+		{"\n\n", []string{}},
+		{"", []string{}},
+	}
+	for _, test := range tests {
+		diskRawStatsArr, err := readDiskRawStats([]byte(test.content), 0)
+		if err != nil {
+			t.Errorf("%q: %v", test.content, err)
+			continue
+		}
+		disks := []string{}
+		for _, diskRawStats := range diskRawStatsArr {
+			disks = append(disks, diskRawStats.Name)
+		}
+		if strings.Join(disks, ` `) != strings.Join(test.disks, ` `) {
+			t.Errorf("%q: disks %v, want %v", test.content, disks, test.disks)
+		}
+	}
+}
+
+func BenchmarkReadDiskRawStats(b *testing.B) {
+	content, err := ioutil.ReadFile(filepath.Join("testdata", "proc", "diskstats"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		readDiskRawStats(content, 0)
+	}
+}
+
+func FuzzReadDiskRawStats(f *testing.F) {
+	content, err := ioutil.ReadFile(filepath.Join("testdata", "proc", "diskstats"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(content)
+	f.Add([]byte(" 259       0 nvme0n1 1232754 324512 7598"))
+	f.Add([]byte("   8       0 sda 4222 4373 293854 48992 676 1024 13428 2016 0 1744 51004\n\n \t\n"))
+	f.Add([]byte("   8       0\n"))
+	f.Add([]byte("-8 x sda 99999999999999999999999 a b c d e f g h i j k\n"))
+	f.Fuzz(func(t *testing.T, content []byte) {
+		diskRawStatsArr, err := readDiskRawStats(content, 0)
+		if err != nil {
+			return
+		}
+		for _, diskRawStats := range diskRawStatsArr {
+			if diskRawStats.Name == `` {
+				t.Errorf("Disk without a name: %+v", diskRawStats)
+			}
+		}
+	})
+}
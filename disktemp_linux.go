@@ -0,0 +1,96 @@
+// +build linux
+
+package sysstats
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// DiskTemp represents the temperature of *one* drive as it is reported by
+// the hwmon of its driver. The temperatures are in Celsius and are 0 if the
+// drive doesn't report them.
+type DiskTemp struct {
+	Disk        string  `json:"disk"`        // Block device of the drive (sda, nvme0n1...)
+	Model       string  `json:"model"`       // Model of the drive
+	Driver      string  `json:"driver"`      // hwmon driver (drivetemp or nvme)
+	Temperature float64 `json:"temperature"` // Current temperature
+	Lowest      float64 `json:"lowest"`      // Lowest temperature since power on (drivetemp only)
+	Highest     float64 `json:"highest"`     // Highest temperature since power on (drivetemp only)
+	Max         float64 `json:"max"`         // Maximum operating temperature
+	Crit        float64 `json:"crit"`        // Critical temperature
+}
+
+// reNvmeNamespace matches the names of the namespaces of a NVMe controller.
+var reNvmeNamespace = regexp.MustCompile(`^nvme\d+n\d+$`)
+
+// getDiskTemps gets the temperatures of the drives of a linux system from the
+// hwmons of the drivetemp (SATA/SAS drives, it needs the drivetemp module)
+// and nvme drivers. Unlike SMART, it doesn't need privileges to access the
+// drives. The drives are sorted by block device.
+func getDiskTemps() (diskTemps []DiskTemp, err error) {
+	hwmons, err := filepath.Glob(filepath.Join(hwmonDir, "hwmon*"))
+	if err != nil {
+		return nil, err
+	}
+
+	diskTemps = make([]DiskTemp, 0, 4)
+	for _, hwmon := range hwmons {
+		driver, err := readStringFile(filepath.Join(hwmon, "name"))
+		if err != nil || (driver != `drivetemp` && driver != `nvme`) {
+			continue
+		}
+		temp, err := readHwmonTemp(filepath.Join(hwmon, "temp1_input"))
+		if err != nil {
+			// The drive is sleeping or it was removed
+			continue
+		}
+
+		device := filepath.Join(hwmon, "device")
+		diskTemp := DiskTemp{
+			Disk:        hwmonDiskName(device, driver),
+			Driver:      driver,
+			Temperature: temp,
+		}
+		diskTemp.Model, _ = readStringFile(filepath.Join(device, "model"))
+		diskTemp.Lowest, _ = readHwmonTemp(filepath.Join(hwmon, "temp1_lowest"))
+		diskTemp.Highest, _ = readHwmonTemp(filepath.Join(hwmon, "temp1_highest"))
+		diskTemp.Max, _ = readHwmonTemp(filepath.Join(hwmon, "temp1_max"))
+		diskTemp.Crit, _ = readHwmonTemp(filepath.Join(hwmon, "temp1_crit"))
+		diskTemps = append(diskTemps, diskTemp)
+	}
+	sort.Slice(diskTemps, func(i, j int) bool { return diskTemps[i].Disk < diskTemps[j].Disk })
+
+	return diskTemps, nil
+}
+
+// hwmonDiskName returns the block device of the device of a drivetemp (a SCSI
+// device with a block directory) or nvme (a controller with its namespaces)
+// hwmon. It returns the name of the device if it doesn't have a block device.
+func hwmonDiskName(device string, driver string) string {
+	if driver == `drivetemp` {
+		if blocks, _ := filepath.Glob(filepath.Join(device, "block", "*")); len(blocks) > 0 {
+			return filepath.Base(blocks[0])
+		}
+	} else if entries, err := ioutil.ReadDir(device); err == nil {
+		for _, entry := range entries {
+			if reNvmeNamespace.MatchString(entry.Name()) {
+				return entry.Name()
+			}
+		}
+	}
+
+	if target, err := filepath.EvalSymlinks(device); err == nil {
+		return filepath.Base(target)
+	}
+
+	return filepath.Base(device)
+}
+
+// readHwmonTemp reads a temperature file of a hwmon (millidegrees Celsius)
+// and returns it in Celsius.
+func readHwmonTemp(path string) (temp float64, err error) {
+	return readHwmonValue(path, hwmonScales[`temp`])
+}
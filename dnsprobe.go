@@ -0,0 +1,164 @@
+package sysstats
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// DnsProbeConfig represents the configuration of a DNS prober.
+type DnsProbeConfig struct {
+	Names     []string      // Names to resolve on each probe
+	Resolvers []string      // Resolvers to query (host:port). Empty means the system resolver
+	Timeout   time.Duration // Timeout of each lookup (default 2 seconds)
+}
+
+// DnsProbeResult represents the result of resolving *one* name against *one*
+// resolver.
+type DnsProbeResult struct {
+	Resolver    string  `json:"resolver"`    // Resolver queried ("system" for the system resolver)
+	Name        string  `json:"name"`        // Name resolved
+	Latency     float64 `json:"latency"`     // Lookup latency of the last probe (milliseconds)
+	Error       string  `json:"error"`       // Error of the last probe (empty if it succeeded)
+	Probes      uint64  `json:"probes"`      // # of probes since the prober was created
+	Failures    uint64  `json:"failures"`    // # of failed probes since the prober was created
+	FailureRate float64 `json:"failurerate"` // % of failed probes since the prober was created
+}
+
+// DnsProber measures the DNS lookup latency and failure rate of a set of
+// names against a set of resolvers. It keeps the number of probes and
+// failures between calls to Probe.
+type DnsProber struct {
+	config   DnsProbeConfig
+	mu       sync.Mutex
+	probes   map[dnsProbeKey]uint64
+	failures map[dnsProbeKey]uint64
+}
+
+type dnsProbeKey struct {
+	resolver string
+	name     string
+}
+
+// NewDnsProber returns a DnsProber for the given configuration.
+func NewDnsProber(config DnsProbeConfig) *DnsProber {
+	if config.Timeout <= 0 {
+		config.Timeout = 2 * time.Second
+	}
+	if len(config.Resolvers) == 0 {
+		config.Resolvers = []string{``}
+	}
+
+	return &DnsProber{
+		config:   config,
+		probes:   map[dnsProbeKey]uint64{},
+		failures: map[dnsProbeKey]uint64{},
+	}
+}
+
+// Probe resolves every configured name against every configured resolver
+// (concurrently) and returns one DnsProbeResult per name and resolver.
+func (p *DnsProber) Probe() (results []DnsProbeResult, err error) {
+	results = make([]DnsProbeResult, len(p.config.Resolvers)*len(p.config.Names))
+
+	var wg sync.WaitGroup
+	i := 0
+	for _, resolver := range p.config.Resolvers {
+		for _, name := range p.config.Names {
+			wg.Add(1)
+			go func(i int, resolver string, name string) {
+				defer wg.Done()
+				results[i] = p.probe(resolver, name)
+			}(i, resolver, name)
+			i++
+		}
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// Collector returns a SamplerCollector named name that resolves the names
+// every interval and returns the results as metrics (see DnsProbeMetrics),
+// so the lookups run with the other collectors of a Sampler, e.g. the one
+// of an Agent:
+//   agent.Sampler.Add(dnsProber.Collector("dns", time.Minute))
+// The collector is expensive: an adaptive sampler drops it while the host is
+// overloaded.
+func (p *DnsProber) Collector(name string, interval time.Duration) SamplerCollector {
+	return SamplerCollector{
+		Name:      name,
+		Interval:  interval,
+		Expensive: true,
+		Collect: func() (interface{}, error) {
+			results, err := p.Probe()
+			return DnsProbeMetrics(results), err
+		},
+	}
+}
+
+// DnsProbeMetrics returns the results of the lookups as metrics labeled
+// with the name and the resolver:
+//   - dns.up: 1 if the last lookup succeeded, 0 otherwise
+//   - dns.latency: latency of the last lookup (milliseconds)
+//   - dns.failures: # of failed lookups since the prober was created
+//   - dns.failurerate: % of failed lookups since the prober was created
+func DnsProbeMetrics(results []DnsProbeResult) (metrics []Metric) {
+	metrics = make([]Metric, 0, 4*len(results))
+	for _, result := range results {
+		labels := map[string]string{`name`: result.Name, `resolver`: result.Resolver}
+		up := 0.0
+		if result.Error == `` {
+			up = 1
+		}
+		metrics = append(metrics,
+			Metric{Name: `dns.up`, Labels: labels, Value: up},
+			Metric{Name: `dns.latency`, Labels: labels, Value: result.Latency},
+			Metric{Name: `dns.failures`, Labels: labels, Value: float64(result.Failures)},
+			Metric{Name: `dns.failurerate`, Labels: labels, Value: result.FailureRate},
+		)
+	}
+
+	return metrics
+}
+
+// probe resolves name against resolver and updates the probe counters.
+func (p *DnsProber) probe(resolver string, name string) (result DnsProbeResult) {
+	r := net.DefaultResolver
+	result.Resolver = `system`
+	if resolver != `` {
+		result.Resolver = resolver
+		r = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				d := net.Dialer{}
+				return d.DialContext(ctx, network, resolver)
+			},
+		}
+	}
+	result.Name = name
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	_, err := r.LookupHost(ctx, name)
+	result.Latency = float64(time.Since(start)) / float64(time.Millisecond)
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	key := dnsProbeKey{resolver: resolver, name: name}
+	p.mu.Lock()
+	p.probes[key]++
+	if err != nil {
+		p.failures[key]++
+	}
+	result.Probes = p.probes[key]
+	result.Failures = p.failures[key]
+	p.mu.Unlock()
+	result.FailureRate = float64(result.Failures) * 100 / float64(result.Probes)
+
+	return result
+}
@@ -0,0 +1,19 @@
+package sysstats
+
+import "testing"
+
+func TestDnsProbeMetrics(t *testing.T) {
+	metrics := DnsProbeMetrics([]DnsProbeResult{
+		{Resolver: `system`, Name: `example.com`, Latency: 12.5, Probes: 4, Failures: 1, FailureRate: 25, Error: `i/o timeout`},
+	})
+	values := map[string]float64{}
+	for _, metric := range metrics {
+		if metric.Labels[`name`] != `example.com` || metric.Labels[`resolver`] != `system` {
+			t.Errorf("Labels of %s %v", metric.Name, metric.Labels)
+		}
+		values[metric.Name] = metric.Value
+	}
+	if values[`dns.up`] != 0 || values[`dns.latency`] != 12.5 || values[`dns.failures`] != 1 || values[`dns.failurerate`] != 25 {
+		t.Errorf("Metrics of the lookup %v", values)
+	}
+}
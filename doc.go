@@ -0,0 +1,19 @@
+// Package sysstats provides system statistics.
+//
+// Android
+//
+// Android is built with the linux collectors and the following differences:
+//   - The procfs is restricted (SetProcRestricted): the apps only see their
+//     own processes, and /proc/stat, /proc/net/dev and /proc/diskstats are
+//     denied to them since Android 8 and 10, so the CPU, network and disk
+//     stats are empty instead of failing.
+//   - GetAndroidMemInfo returns the ION, CMA and GPU memory carve-outs where
+//     the kernel reports them.
+//
+// macOS and FreeBSD
+//
+// The load average, uptime, memory and CPU stats are got from sysctl (and
+// vm_stat and swapinfo for the memory). The CPU stats of macOS need cgo
+// (host_processor_info). The statistics these systems don't have (the idle
+// time, slab, iowait...) are always 0.
+package sysstats
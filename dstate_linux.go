@@ -0,0 +1,134 @@
+// +build linux
+
+package sysstats
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DStateTask represents *one* task (thread) in uninterruptible sleep (D
+// state), usually waiting for I/O (a slow disk, a hung NFS server...).
+type DStateTask struct {
+	Pid      int           `json:"pid"`      // Process id
+	Tid      int           `json:"tid"`      // Thread id
+	Comm     string        `json:"comm"`     // Command name of the thread
+	Wchan    string        `json:"wchan"`    // Kernel function the task is waiting in (empty if not readable)
+	Stack    []string      `json:"stack"`    // Kernel stack of the task (only readable by root)
+	Since    time.Time     `json:"since"`    // First time the task was seen in D state
+	Duration time.Duration `json:"duration"` // Time the task has been in D state (at least)
+}
+
+// DStateTracker tracks the tasks in uninterruptible sleep. The kernel
+// doesn't report for how long a task has been in D state, so the durations are
+// calculated between calls to Check: a task is in D state since the first
+// check that found it there (the durations are 0 on the first check).
+type DStateTracker struct {
+	mu    sync.Mutex
+	since map[taskKey]time.Time
+}
+
+// taskKey identifies a task (or process). The start time is used to tell
+// apart tasks reusing the same id.
+type taskKey struct {
+	id        int
+	startTime uint64
+}
+
+// NewDStateTracker returns a DStateTracker.
+func NewDStateTracker() *DStateTracker {
+	return &DStateTracker{since: map[taskKey]time.Time{}}
+}
+
+// Check returns the tasks currently in D state of a linux system, read from
+// /proc/[pid]/task/[tid]/stat, sorted by duration (longest first).
+func (t *DStateTracker) Check() (tasks []DStateTask, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pids, err := getPids()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	since := make(map[taskKey]time.Time, len(t.since))
+	tasks = []DStateTask{}
+	for _, pid := range pids {
+		tids, err := readProcDirNames(strconv.Itoa(pid), "task")
+		if err != nil {
+			// The process exited before (or while) reading it (or it
+			// can't be read in a restricted procfs)
+			if skipProcess(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, tid := range tids {
+			content, err := getProcReader().readFile(strconv.Itoa(pid), "task", tid, "stat")
+			if err != nil {
+				continue
+			}
+			taskStats, err := parsePidStats(string(content))
+			if err != nil {
+				return nil, err
+			}
+			if taskStats.State != `D` {
+				continue
+			}
+
+			key := taskKey{id: taskStats.Pid, startTime: taskStats.StartTime}
+			if first, ok := t.since[key]; ok {
+				since[key] = first
+			} else {
+				since[key] = now
+			}
+			task := DStateTask{
+				Pid:      pid,
+				Tid:      taskStats.Pid,
+				Comm:     taskStats.Comm,
+				Stack:    readTaskStack(strconv.Itoa(pid), tid),
+				Since:    since[key],
+				Duration: now.Sub(since[key]),
+			}
+			if wchan, err := getProcReader().readString(strconv.Itoa(pid), "task", tid, "wchan"); err == nil && wchan != `0` {
+				task.Wchan = wchan
+			}
+			tasks = append(tasks, task)
+		}
+	}
+	// Forget the tasks that are no longer in D state
+	t.since = since
+
+	sort.SliceStable(tasks, func(i, j int) bool { return tasks[i].Duration > tasks[j].Duration })
+
+	return tasks, nil
+}
+
+// readTaskStack reads the kernel stack of a task from /proc/[pid]/task/[tid]/stack:
+//   [<0>] io_schedule+0x12/0x40
+//   [<0>] wait_on_page_bit_common+0x10c/0x380
+// It returns the functions without the addresses (empty if the file isn't
+// readable).
+func readTaskStack(pid string, tid string) (stack []string) {
+	stack = []string{}
+
+	content, err := getProcReader().readFile(pid, "task", tid, "stack")
+	if err != nil {
+		return stack
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		if i := strings.Index(line, `] `); i >= 0 {
+			line = line[i+2:]
+		}
+		if line = strings.TrimSpace(line); line != `` {
+			stack = append(stack, line)
+		}
+	}
+
+	return stack
+}
@@ -0,0 +1,119 @@
+// +build linux
+
+package sysstats
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// EdacDimm represents the ECC error counters of *one* DIMM (or csrow on the
+// kernels without the dimm directories).
+type EdacDimm struct {
+	Name     string `json:"name"`     // Name of the sysfs directory (dimm0, rank1, csrow0...)
+	Label    string `json:"label"`    // Label of the DIMM (the slot of the motherboard, e.g. CPU_SrcID#0_Channel#1_DIMM#0)
+	Location string `json:"location"` // Location of the DIMM in the memory controller (channel 1 slot 0...)
+	MemType  string `json:"memtype"`  // Type of the memory (Registered-DDR4...)
+	Size     uint64 `json:"size"`     // Size of the DIMM (megabytes)
+	CeCount  uint64 `json:"cecount"`  // # of correctable errors
+	UeCount  uint64 `json:"uecount"`  // # of uncorrectable errors
+}
+
+// EdacController represents the ECC error counters of *one* memory
+// controller.
+type EdacController struct {
+	Name          string     `json:"name"`          // Name of the controller directory (mc0...)
+	Driver        string     `json:"driver"`        // EDAC driver of the controller (skx_edac, amd64_edac...)
+	Size          uint64     `json:"size"`          // Memory of the controller (megabytes)
+	CeCount       uint64     `json:"cecount"`       // # of correctable errors
+	UeCount       uint64     `json:"uecount"`       // # of uncorrectable errors
+	CeNoInfoCount uint64     `json:"cenoinfocount"` // # of correctable errors that couldn't be assigned to a DIMM
+	UeNoInfoCount uint64     `json:"uenoinfocount"` // # of uncorrectable errors that couldn't be assigned to a DIMM
+	Dimms         []EdacDimm `json:"dimms"`         // DIMMs of the controller
+}
+
+// edacDir is the directory of the memory controllers of EDAC in sysfs.
+const edacDir = "/sys/devices/system/edac/mc"
+
+// getEdacStats gets the ECC memory error counters of a linux system from
+// /sys/devices/system/edac/mc. The counters are accumulated since the EDAC
+// driver was loaded (or reset with reset_counters). It returns no
+// controllers if there isn't an EDAC driver for the system (or it doesn't
+// have ECC memory).
+func getEdacStats() (controllers []EdacController, err error) {
+	mcDirs, err := filepath.Glob(filepath.Join(edacDir, "mc*"))
+	if err != nil {
+		return nil, err
+	}
+
+	controllers = make([]EdacController, 0, len(mcDirs))
+	for _, mcDir := range mcDirs {
+		controller := EdacController{Name: filepath.Base(mcDir), Dimms: []EdacDimm{}}
+		controller.Driver, _ = readStringFile(filepath.Join(mcDir, "mc_name"))
+		controller.Size, _ = readUintFile(filepath.Join(mcDir, "size_mb"))
+		counters := map[string]*uint64{
+			`ce_count`:        &controller.CeCount,
+			`ue_count`:        &controller.UeCount,
+			`ce_noinfo_count`: &controller.CeNoInfoCount,
+			`ue_noinfo_count`: &controller.UeNoInfoCount,
+		}
+		for name, value := range counters {
+			*value, _ = readUintFile(filepath.Join(mcDir, name))
+		}
+
+		// The dimm (or rank) directories were added in 3.6; the older
+		// kernels only have the csrows
+		dimmDirs, _ := filepath.Glob(filepath.Join(mcDir, "dimm*"))
+		if len(dimmDirs) == 0 {
+			dimmDirs, _ = filepath.Glob(filepath.Join(mcDir, "rank*"))
+		}
+		for _, dimmDir := range dimmDirs {
+			controller.Dimms = append(controller.Dimms, readEdacDimm(dimmDir))
+		}
+		if len(dimmDirs) == 0 {
+			csrowDirs, _ := filepath.Glob(filepath.Join(mcDir, "csrow*"))
+			for _, csrowDir := range csrowDirs {
+				controller.Dimms = append(controller.Dimms, readEdacCsrow(csrowDir))
+			}
+		}
+		sort.Slice(controller.Dimms, func(i, j int) bool { return controller.Dimms[i].Name < controller.Dimms[j].Name })
+
+		controllers = append(controllers, controller)
+	}
+
+	return controllers, nil
+}
+
+// readEdacDimm reads a dimm (or rank) directory of a memory controller.
+func readEdacDimm(dir string) (dimm EdacDimm) {
+	dimm.Name = filepath.Base(dir)
+	dimm.Label, _ = readStringFile(filepath.Join(dir, "dimm_label"))
+	dimm.Location, _ = readStringFile(filepath.Join(dir, "dimm_location"))
+	dimm.MemType, _ = readStringFile(filepath.Join(dir, "dimm_mem_type"))
+	dimm.Size, _ = readUintFile(filepath.Join(dir, "size"))
+	dimm.CeCount, _ = readUintFile(filepath.Join(dir, "dimm_ce_count"))
+	dimm.UeCount, _ = readUintFile(filepath.Join(dir, "dimm_ue_count"))
+
+	return dimm
+}
+
+// readEdacCsrow reads a csrow directory of a memory controller. The labels of
+// its channels are joined with commas.
+func readEdacCsrow(dir string) (dimm EdacDimm) {
+	dimm.Name = filepath.Base(dir)
+	labelFiles, _ := filepath.Glob(filepath.Join(dir, "ch*_dimm_label"))
+	labels := make([]string, 0, len(labelFiles))
+	for _, labelFile := range labelFiles {
+		if label, err := readStringFile(labelFile); err == nil && label != `` {
+			labels = append(labels, label)
+		}
+	}
+	dimm.Label = strings.Join(labels, `,`)
+	dimm.MemType, _ = readStringFile(filepath.Join(dir, "mem_type"))
+	dimm.Size, _ = readUintFile(filepath.Join(dir, "size_mb"))
+	dimm.CeCount, _ = readUintFile(filepath.Join(dir, "ce_count"))
+	dimm.UeCount, _ = readUintFile(filepath.Join(dir, "ue_count"))
+
+	return dimm
+}
@@ -0,0 +1,91 @@
+package sysstats
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrStatUnavailable is the error of the statistics that the system doesn't
+// report (e.g. a key missing in /proc/meminfo).
+var ErrStatUnavailable = errors.New("The statistic is not available")
+
+// ParseError represents an error parsing *one* field of a file of the
+// system.
+type ParseError struct {
+	File  string // Path of the file
+	Line  int    // # of the line (starting at 1)
+	Field string // Field that couldn't be parsed
+	Err   error  // Cause of the error
+}
+
+// Error returns the error as a string.
+func (e *ParseError) Error() string {
+	return "Error parsing " + e.File + " line " + strconv.Itoa(e.Line) + " field " + e.Field + ": " + e.Err.Error()
+}
+
+// Unwrap returns the cause of the error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// FieldError represents a statistic (or collector) that couldn't be
+// collected. Err is ErrStatUnavailable if the system doesn't report it.
+type FieldError struct {
+	Field string // Name of the statistic (the JSON key, e.g. memtotal) or of the collector (cpu, mem...)
+	Err   error  // Cause of the error
+}
+
+// Error returns the error as a string.
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+// Unwrap returns the cause of the error.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError represents the errors of the statistics that couldn't be
+// collected by the collectors that return partial results. errors.Is and
+// errors.As check all of them.
+type MultiError []error
+
+// Error returns the errors as a string, separated by semicolons.
+func (m MultiError) Error() string {
+	messages := make([]string, 0, len(m))
+	for _, err := range m {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap returns the errors.
+func (m MultiError) Unwrap() []error {
+	return m
+}
+
+// Fields returns the names of the statistics (or collectors) of the
+// FieldErrors.
+func (m MultiError) Fields() (fields []string) {
+	fields = make([]string, 0, len(m))
+	for _, err := range m {
+		var fieldErr *FieldError
+		if errors.As(err, &fieldErr) {
+			fields = append(fields, fieldErr.Field)
+		}
+	}
+
+	return fields
+}
+
+// errorOrNil returns m as an error, or nil if it doesn't have errors (so the
+// callers don't get a non-nil error with an empty MultiError).
+func (m MultiError) errorOrNil() error {
+	if len(m) == 0 {
+		return nil
+	}
+
+	return m
+}
@@ -0,0 +1,383 @@
+package sysstats
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// Exporter is implemented by the destinations of the metrics (Prometheus,
+// statsd, files...). The ExporterManager serializes the calls to an exporter
+// (except Stop when the shutdown times out), so implementations don't need to
+// be safe for concurrent use.
+type Exporter interface {
+	Name() string                    // Unique name of the exporter
+	Start(ctx context.Context) error // Starts the exporter (connects, listens...)
+	Export(metrics []Metric) error   // Exports (or buffers) a batch of metrics
+	Flush(ctx context.Context) error // Sends the buffered metrics
+	Stop(ctx context.Context) error  // Stops the exporter, the batches have already been flushed
+}
+
+// QueuePolicy represents what an ExporterManager does with the batches of an
+// exporter that can't keep up (its queue is full).
+type QueuePolicy int
+
+const (
+	DropNewest QueuePolicy = iota // The new batches are dropped while the queue is full
+	DropOldest                    // The oldest queued batch is dropped to queue the new one, so the exporter gets the most recent metrics
+	Block                         // Export waits until there's room in the queue (the slow exporter slows down the collection)
+	SampleDown                    // Over half of the queue only every other batch is queued (lower resolution instead of a gap), the new batches are dropped while it's full
+)
+
+// String returns the name of the policy.
+func (policy QueuePolicy) String() string {
+	switch policy {
+	case DropNewest:
+		return `dropnewest`
+	case DropOldest:
+		return `dropoldest`
+	case Block:
+		return `block`
+	case SampleDown:
+		return `sampledown`
+	}
+
+	return `unknown`
+}
+
+// ExporterStats represents the health of *one* exporter managed by an
+// ExporterManager.
+type ExporterStats struct {
+	Name     string `json:"name"`     // Name of the exporter
+	Policy   string `json:"policy"`   // Queue policy of the exporter
+	Exported uint64 `json:"exported"` // # of batches exported
+	Failed   uint64 `json:"failed"`   // # of batches whose export failed
+	Dropped  uint64 `json:"dropped"`  // # of batches dropped because the queue of the exporter was full (the oldest ones with DropOldest)
+	Sampled  uint64 `json:"sampled"`  // # of batches skipped by SampleDown
+	Blocked  uint64 `json:"blocked"`  // # of batches Export waited for room in the queue with Block
+	Queued   int    `json:"queued"`   // # of batches waiting to be exported
+	Error    string `json:"error"`    // Last error of the exporter (empty if none)
+}
+
+// ExporterManager coordinates the lifecycle of the exporters: they can be
+// added and removed while the manager is running, every exporter gets the
+// batches through its own queue (so a slow exporter doesn't slow down the
+// others) and on shutdown the queues are drained and the exporters flushed
+// before they are stopped, so the final batch isn't lost.
+type ExporterManager struct {
+	queueSize int
+	mu        sync.Mutex
+	ctx       context.Context
+	running   bool
+	redaction RedactionPolicy
+	exporters map[string]*managedExporter
+}
+
+// managedExporter is an exporter with its queue. The batches are sent to
+// the queue with the read lock of sendMu, so it isn't closed while they are
+// (stop wakes up the Block sends).
+type managedExporter struct {
+	exporter Exporter
+	policy   QueuePolicy
+	queue    chan []Metric
+	done     chan struct{}
+	stop     chan struct{}
+	sendMu   sync.RWMutex
+	closed   bool
+	sampled  bool
+	mu       sync.Mutex
+	stats    ExporterStats
+}
+
+// NewExporterManager returns an ExporterManager whose exporters queue up to
+// queueSize batches (default 16). When the queue of an exporter is full the
+// new batches are dropped, unless the exporter is added with another policy
+// (see AddWithPolicy).
+func NewExporterManager(queueSize int) *ExporterManager {
+	if queueSize <= 0 {
+		queueSize = 16
+	}
+
+	return &ExporterManager{queueSize: queueSize, exporters: map[string]*managedExporter{}}
+}
+
+// Start starts all the exporters added so far. The exporters added later are
+// started when they are added.
+func (m *ExporterManager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.running {
+		return errors.New("The exporter manager is already running")
+	}
+	for _, name := range m.names() {
+		if err := m.start(ctx, m.exporters[name]); err != nil {
+			return errors.New("Couldn't start exporter " + name + ": " + err.Error())
+		}
+	}
+	m.ctx, m.running = ctx, true
+
+	return nil
+}
+
+// Add adds an exporter with the DropNewest policy, starting it if the manager
+// is running.
+func (m *ExporterManager) Add(exporter Exporter) error {
+	return m.AddWithPolicy(exporter, DropNewest)
+}
+
+// AddWithPolicy adds an exporter with the policy of its queue when it can't
+// keep up, starting it if the manager is running.
+func (m *ExporterManager) AddWithPolicy(exporter Exporter, policy QueuePolicy) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name := exporter.Name()
+	if _, ok := m.exporters[name]; ok {
+		return errors.New("Exporter " + name + " is already added")
+	}
+	if policy < DropNewest || policy > SampleDown {
+		return errors.New("Unknown queue policy of exporter " + name)
+	}
+	e := &managedExporter{exporter: exporter, policy: policy, stats: ExporterStats{Name: name, Policy: policy.String()}}
+	if m.running {
+		if err := m.start(m.ctx, e); err != nil {
+			return errors.New("Couldn't start exporter " + name + ": " + err.Error())
+		}
+	}
+	m.exporters[name] = e
+
+	return nil
+}
+
+// Remove drains the queue of an exporter, flushes it, stops it and removes
+// it from the manager.
+func (m *ExporterManager) Remove(ctx context.Context, name string) error {
+	m.mu.Lock()
+	e, ok := m.exporters[name]
+	delete(m.exporters, name)
+	running := m.running
+	m.mu.Unlock()
+
+	if !ok {
+		return errors.New("Exporter " + name + " isn't added")
+	}
+	if !running {
+		return nil
+	}
+
+	return e.shutdown(ctx)
+}
+
+// SetRedactionPolicy sets the redaction policy applied to the batches before
+// they are queued, so all the exporters get the same redacted metrics.
+func (m *ExporterManager) SetRedactionPolicy(policy RedactionPolicy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.redaction = policy
+}
+
+// Export queues a batch of metrics to every exporter. It doesn't wait for the
+// exporters, except the ones with the Block policy whose queue is full.
+func (m *ExporterManager) Export(metrics []Metric) {
+	m.mu.Lock()
+	if !m.running {
+		m.mu.Unlock()
+		return
+	}
+	metrics = m.redaction.RedactMetrics(metrics)
+	exporters := make([]*managedExporter, 0, len(m.exporters))
+	for _, name := range m.names() {
+		exporters = append(exporters, m.exporters[name])
+	}
+	m.mu.Unlock()
+
+	// The manager isn't locked while an exporter blocks
+	for _, e := range exporters {
+		e.enqueue(metrics)
+	}
+}
+
+// Stop drains the queues of the exporters, flushes them and stops them. If
+// ctx is done before, the batches still queued are lost. It returns the
+// first error of the exporters.
+func (m *ExporterManager) Stop(ctx context.Context) (err error) {
+	m.mu.Lock()
+	if !m.running {
+		m.mu.Unlock()
+		return nil
+	}
+	m.running = false
+	exporters := make([]*managedExporter, 0, len(m.exporters))
+	for _, name := range m.names() {
+		exporters = append(exporters, m.exporters[name])
+	}
+	m.mu.Unlock()
+
+	// The exporters are shut down concurrently so a slow one doesn't use up
+	// the time of the others
+	errs := make([]error, len(exporters))
+	var wg sync.WaitGroup
+	for i, e := range exporters {
+		wg.Add(1)
+		go func(i int, e *managedExporter) {
+			defer wg.Done()
+			errs[i] = e.shutdown(ctx)
+		}(i, e)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Stats returns the health of the exporters (sorted by name).
+func (m *ExporterManager) Stats() (stats []ExporterStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats = make([]ExporterStats, 0, len(m.exporters))
+	for _, name := range m.names() {
+		e := m.exporters[name]
+		e.mu.Lock()
+		s := e.stats
+		e.mu.Unlock()
+		if e.queue != nil {
+			s.Queued = len(e.queue)
+		}
+		stats = append(stats, s)
+	}
+
+	return stats
+}
+
+// names returns the names (sorted) of the exporters. The caller must hold
+// the lock.
+func (m *ExporterManager) names() (names []string) {
+	names = make([]string, 0, len(m.exporters))
+	for name := range m.exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// start starts an exporter and the goroutine that exports its queue.
+func (m *ExporterManager) start(ctx context.Context, e *managedExporter) error {
+	if err := e.exporter.Start(ctx); err != nil {
+		return err
+	}
+
+	e.sendMu.Lock()
+	e.queue = make(chan []Metric, m.queueSize)
+	e.done = make(chan struct{})
+	e.stop = make(chan struct{})
+	e.closed = false
+	e.sendMu.Unlock()
+	go func(queue chan []Metric, done chan struct{}) {
+		defer close(done)
+		for metrics := range queue {
+			err := e.exporter.Export(metrics)
+			e.mu.Lock()
+			if err != nil {
+				e.stats.Failed++
+				e.stats.Error = err.Error()
+			} else {
+				e.stats.Exported++
+			}
+			e.mu.Unlock()
+		}
+	}(e.queue, e.done)
+
+	return nil
+}
+
+// enqueue sends a batch to the queue of the exporter with its policy.
+func (e *managedExporter) enqueue(metrics []Metric) {
+	e.sendMu.RLock()
+	defer e.sendMu.RUnlock()
+
+	if e.closed {
+		return
+	}
+	if e.policy == SampleDown && 2*len(e.queue) >= cap(e.queue) && e.sampleOut() {
+		e.count(&e.stats.Sampled)
+		return
+	}
+	select {
+	case e.queue <- metrics:
+		return
+	default:
+	}
+
+	// The queue is full
+	switch e.policy {
+	case Block:
+		e.count(&e.stats.Blocked)
+		select {
+		case e.queue <- metrics:
+		case <-e.stop:
+		}
+		return
+	case DropOldest:
+		for {
+			select {
+			case <-e.queue:
+				e.count(&e.stats.Dropped)
+			default:
+			}
+			select {
+			case e.queue <- metrics:
+				return
+			default:
+			}
+		}
+	}
+	e.count(&e.stats.Dropped)
+}
+
+// sampleOut returns true for every other batch, the ones SampleDown skips.
+func (e *managedExporter) sampleOut() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.sampled = !e.sampled
+	return e.sampled
+}
+
+// count increments a counter of the stats of the exporter.
+func (e *managedExporter) count(counter *uint64) {
+	e.mu.Lock()
+	*counter++
+	e.mu.Unlock()
+}
+
+// shutdown drains the queue of the exporter, flushes it and stops it.
+func (e *managedExporter) shutdown(ctx context.Context) error {
+	close(e.stop)
+	e.sendMu.Lock()
+	e.closed = true
+	close(e.queue)
+	e.sendMu.Unlock()
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		// The exporter is stuck, it's stopped without flushing
+		return e.exporter.Stop(ctx)
+	}
+
+	if err := e.exporter.Flush(ctx); err != nil {
+		e.exporter.Stop(ctx)
+		return errors.New("Couldn't flush exporter " + e.stats.Name + ": " + err.Error())
+	}
+
+	return e.exporter.Stop(ctx)
+}
@@ -0,0 +1,84 @@
+// +build linux
+
+package sysstats
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+)
+
+// FcHostStats represents the statistics of *one* Fibre Channel HBA port.
+//
+// Statistics map keys are the names of the files in
+// /sys/class/fc_host/<host>/statistics, e.g.:
+//   link_failure_count          - # of link failures.
+//   loss_of_signal_count        - # of times the signal was lost.
+//   loss_of_sync_count          - # of times the synchronization was lost.
+//   invalid_crc_count           - # of frames received with an invalid CRC.
+//   invalid_tx_word_count       - # of invalid transmission words received.
+//   prim_seq_protocol_err_count - # of primitive sequence protocol errors.
+//   tx_frames                   - # of frames transmitted.
+//   rx_frames                   - # of frames received.
+//   error_frames                - # of frames received with errors.
+//   dumped_frames               - # of frames dropped.
+// Counters not supported by the driver (reported as 0xffffffffffffffff) are
+// not included.
+type FcHostStats struct {
+	Host       string            `json:"host"`       // SCSI host name (host0...)
+	PortName   string            `json:"portname"`   // WWPN of the port
+	NodeName   string            `json:"nodename"`   // WWNN of the port
+	FabricName string            `json:"fabricname"` // WWN of the fabric the port is attached to
+	PortState  string            `json:"portstate"`  // Port state (Online, Linkdown...)
+	Speed      string            `json:"speed"`      // Negotiated speed (e.g. 16 Gbit)
+	Statistics map[string]uint64 `json:"statistics"` // FC statistics counters
+}
+
+// getFcHostStats gets the Fibre Channel HBA statistics of a linux system from
+// /sys/class/fc_host/*.
+func getFcHostStats() (fcHostStatsArr []FcHostStats, err error) {
+	hostDirs, err := filepath.Glob("/sys/class/fc_host/*")
+	if err != nil {
+		return nil, err
+	}
+
+	fcHostStatsArr = make([]FcHostStats, 0, len(hostDirs))
+	for _, hostDir := range hostDirs {
+		fcHostStats := FcHostStats{Host: filepath.Base(hostDir), Statistics: map[string]uint64{}}
+		for file, value := range map[string]*string{
+			"port_name":   &fcHostStats.PortName,
+			"node_name":   &fcHostStats.NodeName,
+			"fabric_name": &fcHostStats.FabricName,
+			"port_state":  &fcHostStats.PortState,
+			"speed":       &fcHostStats.Speed,
+		} {
+			// Not every driver exposes every attribute
+			*value, _ = readStringFile(filepath.Join(hostDir, file))
+		}
+
+		statFiles, err := ioutil.ReadDir(filepath.Join(hostDir, "statistics"))
+		if err != nil {
+			return nil, err
+		}
+		for _, statFile := range statFiles {
+			if statFile.Name() == `reset_statistics` {
+				// Write only
+				continue
+			}
+			content, err := readStringFile(filepath.Join(hostDir, "statistics", statFile.Name()))
+			if err != nil {
+				continue
+			}
+			// Values are hexadecimal (0x1a)
+			value, err := strconv.ParseUint(content, 0, 64)
+			if err != nil || value == 0xffffffffffffffff {
+				continue
+			}
+			fcHostStats.Statistics[statFile.Name()] = value
+		}
+
+		fcHostStatsArr = append(fcHostStatsArr, fcHostStats)
+	}
+
+	return fcHostStatsArr, nil
+}
@@ -0,0 +1,166 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// FileLock represents *one* file lock (or lease) of a linux system as it is
+// in /proc/locks.
+type FileLock struct {
+	Id      int    `json:"id"`      // Lock id (the waiters have the id of the lock they are waiting for)
+	Class   string `json:"class"`   // Lock class (POSIX, FLOCK, OFDLCK, LEASE, DELEG)
+	Mode    string `json:"mode"`    // Lock mode (ADVISORY, MANDATORY) or lease state (ACTIVE, BREAKING, BREAKER)
+	Type    string `json:"type"`    // Lock type (READ, WRITE, UNLCK)
+	Pid     int    `json:"pid"`     // Process id of the lock holder (-1 for OFD locks)
+	Comm    string `json:"comm"`    // Command name of the lock holder
+	Major   uint64 `json:"major"`   // Major number of the device of the locked file
+	Minor   uint64 `json:"minor"`   // Minor number of the device of the locked file
+	Inode   uint64 `json:"inode"`   // Inode of the locked file
+	Start   uint64 `json:"start"`   // First byte of the locked range
+	End     int64  `json:"end"`     // Last byte of the locked range (-1 up to the end of the file)
+	Path    string `json:"path"`    // Path of the locked file (empty if it couldn't be resolved)
+	Blocked bool   `json:"blocked"` // Whether the process is waiting for the lock
+}
+
+// FileLocks represents the file locks of a linux system.
+//
+// ByClass map keys are the lock classes (POSIX, FLOCK, OFDLCK, LEASE, DELEG).
+type FileLocks struct {
+	Locks   []FileLock        `json:"locks"`   // Locks held and waited for
+	Held    uint64            `json:"held"`    // # of locks held
+	Blocked uint64            `json:"blocked"` // # of processes waiting for a lock
+	ByClass map[string]uint64 `json:"byclass"` // # of locks held per class
+}
+
+// getFileLocks gets the file locks of a linux system from the file
+// /proc/locks. The paths of the locked files are resolved looking for them in
+// the open file descriptors of the lock holders.
+func getFileLocks() (fileLocks FileLocks, err error) {
+	file, err := getProcReader().open("locks")
+	if err != nil {
+		return FileLocks{}, err
+	}
+	defer file.Close()
+
+	fileLocks = FileLocks{Locks: []FileLock{}, ByClass: map[string]uint64{}}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		lock, err := parseFileLock(scanner.Text())
+		if err != nil {
+			return FileLocks{}, err
+		}
+		if lock.Blocked {
+			fileLocks.Blocked++
+		} else {
+			fileLocks.Held++
+			fileLocks.ByClass[lock.Class]++
+		}
+		fileLocks.Locks = append(fileLocks.Locks, lock)
+	}
+	if err := scanner.Err(); err != nil {
+		return FileLocks{}, err
+	}
+
+	resolveFileLocks(fileLocks.Locks)
+
+	return fileLocks, nil
+}
+
+// parseFileLock parses *one* line of /proc/locks, that has the following
+// format (the waiters have -> after the id):
+//   1: POSIX  ADVISORY  WRITE 1234 08:01:1234567 0 EOF
+//   1: -> POSIX  ADVISORY  WRITE 1235 08:01:1234567 0 EOF
+// The device major and minor numbers are in hexadecimal.
+func parseFileLock(line string) (lock FileLock, err error) {
+	fields := strings.Fields(line)
+	if len(fields) > 1 && fields[1] == `->` {
+		lock.Blocked = true
+		fields = append(fields[:1], fields[2:]...)
+	}
+	if len(fields) != 8 {
+		return FileLock{}, errors.New("Error parsing file /proc/locks. Unexpected line: " + line)
+	}
+
+	if lock.Id, err = strconv.Atoi(strings.TrimSuffix(fields[0], `:`)); err != nil {
+		return FileLock{}, err
+	}
+	lock.Class, lock.Mode, lock.Type = fields[1], fields[2], fields[3]
+	if lock.Pid, err = strconv.Atoi(fields[4]); err != nil {
+		return FileLock{}, err
+	}
+
+	dev := strings.Split(fields[5], `:`)
+	if len(dev) != 3 {
+		return FileLock{}, errors.New("Error parsing file /proc/locks. Unexpected file: " + fields[5])
+	}
+	if lock.Major, err = strconv.ParseUint(dev[0], 16, 64); err != nil {
+		return FileLock{}, err
+	}
+	if lock.Minor, err = strconv.ParseUint(dev[1], 16, 64); err != nil {
+		return FileLock{}, err
+	}
+	if lock.Inode, err = strconv.ParseUint(dev[2], 10, 64); err != nil {
+		return FileLock{}, err
+	}
+
+	if lock.Start, err = strconv.ParseUint(fields[6], 10, 64); err != nil {
+		return FileLock{}, err
+	}
+	lock.End = -1
+	if fields[7] != `EOF` {
+		if lock.End, err = strconv.ParseInt(fields[7], 10, 64); err != nil {
+			return FileLock{}, err
+		}
+	}
+
+	return lock, nil
+}
+
+// resolveFileLocks sets the command name of the lock holders and the paths
+// of the locked files, looking for the device and inode of the locks in the
+// file descriptors of the holders (/proc/[pid]/fd). The locks of processes
+// that can't be read (or already exited) aren't resolved.
+func resolveFileLocks(locks []FileLock) {
+	type fileId struct {
+		major, minor, inode uint64
+	}
+	paths := map[int]map[fileId]string{}
+
+	for i := range locks {
+		lock := &locks[i]
+		if lock.Pid <= 0 {
+			continue
+		}
+		procDir := procPath(strconv.Itoa(lock.Pid))
+		lock.Comm, _ = getProcReader().readString(strconv.Itoa(lock.Pid), "comm")
+
+		if _, ok := paths[lock.Pid]; !ok {
+			paths[lock.Pid] = map[fileId]string{}
+			fds, _ := filepath.Glob(filepath.Join(procDir, "fd", "*"))
+			for _, fd := range fds {
+				var stat syscall.Stat_t
+				if err := syscall.Stat(fd, &stat); err != nil {
+					continue
+				}
+				// Same decoding as glibc's major() and minor()
+				dev := uint64(stat.Dev)
+				major := ((dev >> 8) & 0xfff) | ((dev >> 32) &^ 0xfff)
+				minor := (dev & 0xff) | ((dev >> 12) &^ 0xff)
+				if path, err := os.Readlink(fd); err == nil {
+					paths[lock.Pid][fileId{major, minor, uint64(stat.Ino)}] = path
+				}
+			}
+		}
+		lock.Path = paths[lock.Pid][fileId{lock.Major, lock.Minor, lock.Inode}]
+	}
+}
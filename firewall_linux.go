@@ -0,0 +1,258 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FirewallCounter represents the packet and byte counters of *one* firewall
+// rule, chain policy or named counter.
+type FirewallCounter struct {
+	Backend string `json:"backend"` // iptables, ip6tables or nftables
+	Family  string `json:"family"`  // nftables family (ip, ip6, inet...). Empty for iptables
+	Table   string `json:"table"`   // Table name (filter, nat...)
+	Chain   string `json:"chain"`   // Chain name. Empty for nftables named counters
+	Rule    string `json:"rule"`    // Rule comment (or spec if it has no comment), "policy <target>" or counter name
+	Packets uint64 `json:"packets"` // # of packets matched
+	Bytes   uint64 `json:"bytes"`   // # of bytes matched
+}
+
+// getFirewallStats gets the firewall counters of a linux system running the
+// commands `nft -j list ruleset`, `iptables-save -c` and `ip6tables-save -c`.
+// The backends that aren't installed or fail are skipped; it only returns an
+// error if none of them works.
+//
+// With iptables-nft (`iptables -V` shows "nf_tables") the iptables rules are
+// in the nftables ruleset, so iptables-save is skipped if nft works to not
+// count them twice.
+func getFirewallStats() (counters []FirewallCounter, err error) {
+	counters = make([]FirewallCounter, 0, 32)
+	var errs MultiError
+	found := false
+
+	nftOk := false
+	if nft, err := exec.LookPath("nft"); err == nil {
+		found = true
+		nftCounters, err := getNftCounters(nft)
+		if err != nil {
+			errs = append(errs, &FieldError{Field: `nftables`, Err: err})
+		} else {
+			nftOk = true
+			counters = append(counters, nftCounters...)
+		}
+	}
+
+	ok := nftOk
+	for _, backend := range []string{`iptables`, `ip6tables`} {
+		save, err := exec.LookPath(backend + "-save")
+		if err != nil {
+			continue
+		}
+		found = true
+		if nftOk && isIptablesNft(backend) {
+			continue
+		}
+		backendCounters, err := getIptablesCounters(backend, save)
+		if err != nil {
+			errs = append(errs, &FieldError{Field: backend, Err: err})
+			continue
+		}
+		ok = true
+		counters = append(counters, backendCounters...)
+	}
+
+	if !found {
+		return nil, errors.New("Couldn't get firewall counters: iptables-save, ip6tables-save and nft not found")
+	}
+	if !ok {
+		return nil, errs
+	}
+
+	return counters, nil
+}
+
+// getNftCounters gets the counters of the nftables ruleset running nft.
+func getNftCounters(nft string) ([]FirewallCounter, error) {
+	out, err := exec.Command(nft, "-j", "list", "ruleset").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseNftRuleset(out)
+}
+
+// getIptablesCounters gets the counters of an iptables backend (iptables or
+// ip6tables) running its save command.
+func getIptablesCounters(backend string, save string) ([]FirewallCounter, error) {
+	out, err := exec.Command(save, "-c").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseIptablesSave(backend, out)
+}
+
+// isIptablesNft returns true if the iptables backend (iptables or ip6tables)
+// is iptables-nft, i.e. if its rules are nftables rules.
+func isIptablesNft(backend string) bool {
+	path, err := exec.LookPath(backend)
+	if err != nil {
+		return false
+	}
+	out, err := exec.Command(path, "-V").Output()
+	if err != nil {
+		return false
+	}
+
+	return isNftVersion(out)
+}
+
+// isNftVersion returns true if the output of `iptables -V` is the one of
+// iptables-nft, e.g.:
+//
+//   iptables v1.8.7 (nf_tables)
+//
+// iptables-legacy shows "(legacy)" and the versions before 1.8 nothing.
+func isNftVersion(out []byte) bool {
+	return bytes.Contains(out, []byte(`(nf_tables)`))
+}
+
+var (
+	reIptChain   = regexp.MustCompile(`^:(\S+)\s+(\S+)\s+\[(\d+):(\d+)\]`)
+	reIptRule    = regexp.MustCompile(`^\[(\d+):(\d+)\]\s+-A\s+(\S+)\s*(.*)$`)
+	reIptComment = regexp.MustCompile(`--comment\s+(?:"((?:[^"\\]|\\.)*)"|(\S+))`)
+)
+
+// parseIptablesSave parses the output of `iptables-save -c`, which has the
+// following format:
+//   *filter
+//   :INPUT ACCEPT [1234:567890]
+//   :FORWARD DROP [0:0]
+//   [10:600] -A INPUT -i lo -m comment --comment "allow loopback" -j ACCEPT
+//   COMMIT
+func parseIptablesSave(backend string, out []byte) (counters []FirewallCounter, err error) {
+	counters = make([]FirewallCounter, 0, 32)
+	table := ``
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, `*`) {
+			table = line[1:]
+		} else if stat := reIptChain.FindStringSubmatch(line); stat != nil {
+			if stat[2] == `-` {
+				// User defined chains don't have a policy
+				continue
+			}
+			counter := FirewallCounter{Backend: backend, Table: table, Chain: stat[1], Rule: `policy ` + stat[2]}
+			if counter.Packets, err = strconv.ParseUint(stat[3], 10, 64); err != nil {
+				return nil, err
+			}
+			if counter.Bytes, err = strconv.ParseUint(stat[4], 10, 64); err != nil {
+				return nil, err
+			}
+			counters = append(counters, counter)
+		} else if stat := reIptRule.FindStringSubmatch(line); stat != nil {
+			counter := FirewallCounter{Backend: backend, Table: table, Chain: stat[3], Rule: stat[4]}
+			if comment := reIptComment.FindStringSubmatch(stat[4]); comment != nil {
+				counter.Rule = comment[1] + comment[2]
+			}
+			if counter.Packets, err = strconv.ParseUint(stat[1], 10, 64); err != nil {
+				return nil, err
+			}
+			if counter.Bytes, err = strconv.ParseUint(stat[2], 10, 64); err != nil {
+				return nil, err
+			}
+			counters = append(counters, counter)
+		}
+	}
+
+	return counters, nil
+}
+
+// nftCounter is the counter statement of a nftables rule (or a named counter
+// object) as it is in the JSON output of nft.
+type nftCounter struct {
+	Packets uint64 `json:"packets"`
+	Bytes   uint64 `json:"bytes"`
+}
+
+// parseNftRuleset parses the output of `nft -j list ruleset` and returns the
+// counters of the rules that have a counter statement plus the named counters.
+func parseNftRuleset(out []byte) (counters []FirewallCounter, err error) {
+	var ruleset struct {
+		Nftables []struct {
+			Rule *struct {
+				Family  string                       `json:"family"`
+				Table   string                       `json:"table"`
+				Chain   string                       `json:"chain"`
+				Handle  uint64                       `json:"handle"`
+				Comment string                       `json:"comment"`
+				Expr    []map[string]json.RawMessage `json:"expr"`
+			} `json:"rule"`
+			Counter *struct {
+				Family string `json:"family"`
+				Table  string `json:"table"`
+				Name   string `json:"name"`
+				nftCounter
+			} `json:"counter"`
+		} `json:"nftables"`
+	}
+	if err := json.Unmarshal(out, &ruleset); err != nil {
+		return nil, err
+	}
+
+	counters = make([]FirewallCounter, 0, 32)
+	for _, object := range ruleset.Nftables {
+		if object.Counter != nil {
+			counters = append(counters, FirewallCounter{
+				Backend: `nftables`,
+				Family:  object.Counter.Family,
+				Table:   object.Counter.Table,
+				Rule:    object.Counter.Name,
+				Packets: object.Counter.Packets,
+				Bytes:   object.Counter.Bytes,
+			})
+		}
+		rule := object.Rule
+		if rule == nil {
+			continue
+		}
+		for _, expr := range rule.Expr {
+			raw, ok := expr[`counter`]
+			if !ok {
+				continue
+			}
+			counter := nftCounter{}
+			// Anonymous counters are objects; references to named counters
+			// are strings and are reported with the named counters.
+			if err := json.Unmarshal(raw, &counter); err != nil {
+				continue
+			}
+			name := rule.Comment
+			if name == `` {
+				name = `handle ` + strconv.FormatUint(rule.Handle, 10)
+			}
+			counters = append(counters, FirewallCounter{
+				Backend: `nftables`,
+				Family:  rule.Family,
+				Table:   rule.Table,
+				Chain:   rule.Chain,
+				Rule:    name,
+				Packets: counter.Packets,
+				Bytes:   counter.Bytes,
+			})
+		}
+	}
+
+	return counters, nil
+}
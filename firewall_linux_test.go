@@ -0,0 +1,149 @@
+// +build linux
+
+package sysstats
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestIsNftVersion(t *testing.T) {
+	tests := []struct {
+		out string
+		nft bool
+	}{
+		{"iptables v1.8.7 (nf_tables)\n", true},
+		{"ip6tables v1.8.9 (nf_tables)\n", true},
+		{"iptables v1.8.7 (legacy)\n", false},
+		{"iptables v1.6.1\n", false},
+	}
+	for _, test := range tests {
+		if nft := isNftVersion([]byte(test.out)); nft != test.nft {
+			t.Errorf("isNftVersion(%q) = %t, want %t", test.out, nft, test.nft)
+		}
+	}
+}
+
+// readFirewallFixture returns the content of a fixture of testdata/firewall.
+func readFirewallFixture(tb testing.TB, name string) []byte {
+	content, err := ioutil.ReadFile(filepath.Join("testdata", "firewall", name))
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return content
+}
+
+func TestParseIptablesSave(t *testing.T) {
+	counters, err := parseIptablesSave(`iptables`, readFirewallFixture(t, "iptables-save"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FirewallCounter{
+		{Backend: `iptables`, Table: `filter`, Chain: `INPUT`, Rule: `policy DROP`, Packets: 1520, Bytes: 212345},
+		{Backend: `iptables`, Table: `filter`, Chain: `FORWARD`, Rule: `policy ACCEPT`},
+		{Backend: `iptables`, Table: `filter`, Chain: `OUTPUT`, Rule: `policy ACCEPT`, Packets: 98231, Bytes: 12873465},
+		// The user defined chains don't have a policy
+		{Backend: `iptables`, Table: `filter`, Chain: `INPUT`, Rule: `allow loopback`, Packets: 10, Bytes: 600},
+		// The rules without a comment are reported by their spec
+		{Backend: `iptables`, Table: `filter`, Chain: `INPUT`, Rule: `-m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT`, Packets: 8123, Bytes: 1234567},
+		{Backend: `iptables`, Table: `filter`, Chain: `INPUT`, Rule: `ssh`, Packets: 42, Bytes: 2520},
+		{Backend: `iptables`, Table: `filter`, Chain: `DOCKER`, Rule: `-d 172.17.0.2/32 -p tcp -j ACCEPT`},
+		{Backend: `iptables`, Table: `nat`, Chain: `PREROUTING`, Rule: `policy ACCEPT`, Packets: 300, Bytes: 18000},
+		{Backend: `iptables`, Table: `nat`, Chain: `POSTROUTING`, Rule: `policy ACCEPT`, Packets: 20, Bytes: 1200},
+		{Backend: `iptables`, Table: `nat`, Chain: `POSTROUTING`, Rule: `docker masquerade`, Packets: 5, Bytes: 300},
+	}
+	if !reflect.DeepEqual(counters, want) {
+		t.Errorf("Counters\n%+v\nwant\n%+v", counters, want)
+	}
+}
+
+func TestParseNftRuleset(t *testing.T) {
+	counters, err := parseNftRuleset(readFirewallFixture(t, "nft-ruleset.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FirewallCounter{
+		// The named counter, once (not with the rule that references it)
+		{Backend: `nftables`, Family: `inet`, Table: `filter`, Rule: `ssh_hits`, Packets: 42, Bytes: 2520},
+		// The anonymous counters, by comment or by handle
+		{Backend: `nftables`, Family: `inet`, Table: `filter`, Chain: `input`, Rule: `allow loopback`, Packets: 10, Bytes: 600},
+		{Backend: `nftables`, Family: `inet`, Table: `filter`, Chain: `input`, Rule: `handle 7`, Packets: 8123, Bytes: 1234567},
+	}
+	if !reflect.DeepEqual(counters, want) {
+		t.Errorf("Counters\n%+v\nwant\n%+v", counters, want)
+	}
+
+	if _, err := parseNftRuleset([]byte(`{"nftables": [`)); err == nil {
+		t.Error("No error parsing a truncated ruleset")
+	}
+}
+
+// writeFirewallCommand writes a fake command to dir, a shell script that
+// writes the fixture output (none if empty) and exits with status.
+func writeFirewallCommand(t *testing.T, dir string, name string, output string, status int) {
+	script := "#!/bin/sh\n"
+	if output != `` {
+		// Without external commands, the PATH only has dir
+		script += "while IFS= read -r line || [ -n \"$line\" ]; do printf '%s\\n' \"$line\"; done < '" + output + "'\n"
+	}
+	script += "exit " + strconv.Itoa(status) + "\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetFirewallStatsBackends(t *testing.T) {
+	fixtures, err := filepath.Abs(filepath.Join("testdata", "firewall"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	iptablesSave := filepath.Join(fixtures, "iptables-save")
+	nftRuleset := filepath.Join(fixtures, "nft-ruleset.json")
+
+	tests := []struct {
+		name     string
+		version  string // Output of iptables -V
+		nft      int    // Exit status of nft
+		counters int
+		ok       bool
+	}{
+		// The rules of iptables-nft are in the nftables ruleset
+		{`iptables-nft`, "iptables v1.8.7 (nf_tables)\n", 0, 3, true},
+		{`iptables-legacy`, "iptables v1.8.7 (legacy)\n", 0, 3 + 10, true},
+		// iptables-save is the only backend that works
+		{`iptables-nft without nft`, "iptables v1.8.7 (nf_tables)\n", 1, 10, true},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		version := filepath.Join(dir, "version")
+		if err := ioutil.WriteFile(version, []byte(test.version), 0644); err != nil {
+			t.Fatal(err)
+		}
+		writeFirewallCommand(t, dir, "iptables", version, 0)
+		writeFirewallCommand(t, dir, "iptables-save", iptablesSave, 0)
+		writeFirewallCommand(t, dir, "nft", nftRuleset, test.nft)
+		t.Setenv("PATH", dir)
+
+		counters, err := getFirewallStats()
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if len(counters) != test.counters {
+			t.Errorf("%s: %d counters, want %d", test.name, len(counters), test.counters)
+		}
+	}
+
+	// All the backends fail
+	dir := t.TempDir()
+	writeFirewallCommand(t, dir, "iptables-save", ``, 1)
+	writeFirewallCommand(t, dir, "nft", ``, 1)
+	t.Setenv("PATH", dir)
+	if counters, err := getFirewallStats(); err == nil {
+		t.Errorf("Counters %v without error, want the errors of iptables and nftables", counters)
+	} else if errs, ok := err.(MultiError); !ok || len(errs.Fields()) != 2 {
+		t.Errorf("Error %v, want the ones of iptables and nftables", err)
+	}
+}
@@ -0,0 +1,216 @@
+// +build linux
+
+package sysstats
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Quiesce event kinds
+const (
+	QuiesceEventQuiescent = "quiescent" // A file system has been idle for IdleFor
+	QuiesceEventActive    = "active"    // A quiescent file system is being written again
+)
+
+// FsQuiesceConfig represents the configuration of a file system quiescence
+// monitor.
+type FsQuiesceConfig struct {
+	MaxWriteRate float64       // Max write rate (bytes per second) of an idle file system (default 4 KiB/s)
+	IdleFor      time.Duration // Time a file system has to be idle to be quiescent (default 30 seconds)
+}
+
+// FsActivity represents the write activity of *one* file system backed by a
+// block device.
+type FsActivity struct {
+	MountPoint string    `json:"mountpoint"` // Mount point
+	Source     string    `json:"source"`     // Mount source (/dev/sda1, /dev/mapper/vg-root...)
+	FsType     string    `json:"fstype"`     // File system type
+	Device     string    `json:"device"`     // Block device of /proc/diskstats (sda1, dm-0...)
+	WriteRate  float64   `json:"writerate"`  // Write rate since the previous check (bytes per second)
+	WriteIOs   float64   `json:"writeios"`   // # of writes completed per second since the previous check
+	InFlight   uint64    `json:"inflight"`   // # of I/Os of the device in progress
+	Idle       bool      `json:"idle"`       // Whether the write rate is under MaxWriteRate and there are no I/Os in progress
+	IdleSince  time.Time `json:"idlesince"`  // When the file system became idle (zero if it isn't idle)
+	Quiescent  bool      `json:"quiescent"`  // Whether the file system has been idle for IdleFor (it's safe to snapshot)
+}
+
+// QuiesceEvent represents *one* change of the quiescence of a file system.
+type QuiesceEvent struct {
+	Kind       string    `json:"kind"`       // Kind of event (quiescent or active)
+	Time       time.Time `json:"time"`       // Time the change was detected
+	MountPoint string    `json:"mountpoint"` // Mount point
+	Device     string    `json:"device"`     // Block device (sda1, dm-0...)
+	WriteRate  float64   `json:"writerate"`  // Write rate when the change was detected (bytes per second)
+	Error      string    `json:"error"`      // Error checking the file systems (empty if it succeeded, and then the other fields are empty)
+}
+
+// FsQuiesceMonitor measures the write rates of the file systems of a linux
+// system so a backup tool can freeze (or snapshot) the ones that are idle,
+// which keeps the freezes short. The rates are calculated from
+// /proc/diskstats between calls to Check, so the file systems that aren't
+// backed by a block device (tmpfs, NFS, btrfs subvolumes...) aren't reported.
+type FsQuiesceMonitor struct {
+	config FsQuiesceConfig
+	mu     sync.Mutex
+	last   map[string]fsQuiesceState
+}
+
+type fsQuiesceState struct {
+	time         time.Time
+	writeIOs     uint64
+	writeSectors uint64
+	idleSince    time.Time
+	quiescent    bool
+}
+
+// NewFsQuiesceMonitor returns a FsQuiesceMonitor for the given configuration.
+func NewFsQuiesceMonitor(config FsQuiesceConfig) *FsQuiesceMonitor {
+	if config.MaxWriteRate <= 0 {
+		config.MaxWriteRate = 4 << 10
+	}
+	if config.IdleFor <= 0 {
+		config.IdleFor = 30 * time.Second
+	}
+
+	return &FsQuiesceMonitor{config: config, last: map[string]fsQuiesceState{}}
+}
+
+// Check returns the write activity of the file systems since the previous
+// call, and the file systems that became quiescent (or active) since then.
+// The bind mounts of a file system are reported once. On the first call the
+// rates are 0 and no file system is idle.
+func (m *FsQuiesceMonitor) Check() (activities []FsActivity, events []QuiesceEvent, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mounts, err := getMountInfo()
+	if err != nil {
+		return nil, nil, err
+	}
+	diskRawStatsArr, err := getFilteredDiskRawStats(DiskFilter{IncludeVirtual: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+
+	disks := make(map[string]DiskRawStats, len(diskRawStatsArr))
+	for _, diskRawStats := range diskRawStatsArr {
+		disks[deviceNumber(diskRawStats.Major, diskRawStats.Minor)] = diskRawStats
+	}
+	// The mount of the root of a file system is preferred over its bind
+	// mounts
+	fsMounts := map[string]MountInfo{}
+	order := []string{}
+	for _, mount := range mounts {
+		key := deviceNumber(mount.Major, mount.Minor)
+		if _, ok := disks[key]; !ok {
+			continue
+		}
+		previous, ok := fsMounts[key]
+		if !ok {
+			order = append(order, key)
+		}
+		if !ok || (previous.Root != `/` && mount.Root == `/`) {
+			fsMounts[key] = mount
+		}
+	}
+
+	activities = make([]FsActivity, 0, len(order))
+	events = []QuiesceEvent{}
+	current := make(map[string]fsQuiesceState, len(order))
+	for _, key := range order {
+		mount, disk := fsMounts[key], disks[key]
+		activity := FsActivity{
+			MountPoint: mount.MountPoint,
+			Source:     mount.Source,
+			FsType:     mount.FsType,
+			Device:     disk.Name,
+			InFlight:   disk.InFlight,
+		}
+		state := fsQuiesceState{time: now, writeIOs: disk.WriteIOs, writeSectors: disk.WriteSectors}
+
+		last, ok := m.last[key]
+		// The counters go backwards if the device was removed and added
+		// again: it's handled as a new one
+		if ok && disk.WriteIOs >= last.writeIOs && disk.WriteSectors >= last.writeSectors {
+			if elapsed := now.Sub(last.time).Seconds(); elapsed > 0 {
+				activity.WriteRate = float64(disk.WriteSectors-last.writeSectors) * 512 / elapsed
+				activity.WriteIOs = float64(disk.WriteIOs-last.writeIOs) / elapsed
+			}
+			activity.Idle = activity.WriteRate <= m.config.MaxWriteRate && disk.InFlight == 0
+		}
+		if activity.Idle {
+			state.idleSince = last.idleSince
+			if state.idleSince.IsZero() {
+				state.idleSince = last.time
+			}
+			activity.IdleSince = state.idleSince
+			activity.Quiescent = now.Sub(state.idleSince) >= m.config.IdleFor
+		}
+		state.quiescent = activity.Quiescent
+
+		if activity.Quiescent != last.quiescent {
+			event := QuiesceEvent{Kind: QuiesceEventActive, Time: now, MountPoint: activity.MountPoint, Device: activity.Device, WriteRate: activity.WriteRate}
+			if activity.Quiescent {
+				event.Kind = QuiesceEventQuiescent
+			}
+			events = append(events, event)
+		}
+
+		current[key] = state
+		activities = append(activities, activity)
+	}
+	m.last = current
+
+	return activities, events, nil
+}
+
+// Subscribe checks the file systems every interval until ctx is done and
+// sends the quiescence events to the channel returned, that is closed when
+// ctx is done. The errors of the checks are sent as events with Error set.
+func (m *FsQuiesceMonitor) Subscribe(ctx context.Context, interval time.Duration) (<-chan QuiesceEvent, error) {
+	if interval < time.Second {
+		return nil, errors.New("The check interval should be at least 1 second")
+	}
+	if _, _, err := m.Check(); err != nil {
+		return nil, err
+	}
+
+	events := make(chan QuiesceEvent)
+	go func() {
+		defer close(events)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			_, checkEvents, err := m.Check()
+			if err != nil {
+				checkEvents = []QuiesceEvent{{Time: time.Now(), Error: err.Error()}}
+			}
+			for _, event := range checkEvents {
+				select {
+				case <-ctx.Done():
+					return
+				case events <- event:
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// deviceNumber returns the major:minor number of a device.
+func deviceNumber(major int, minor int) string {
+	return strconv.Itoa(major) + `:` + strconv.Itoa(minor)
+}
@@ -0,0 +1,246 @@
+// +build linux
+
+package sysstats
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// FsUsageConfig represents the configuration of a file system usage
+// collector.
+type FsUsageConfig struct {
+	Timeout        time.Duration // Time to wait for the statfs calls of a collection (default 5 seconds)
+	ExcludeFsTypes []string      // File system types not to collect (e.g. nfs4)
+	IncludeFuse    bool          // Whether to collect the FUSE file systems (skipped by default)
+	ExcludePseudo  bool          // Whether to skip the pseudo file systems (tmpfs, proc, sysfs, overlay...) and the container storage mounts
+}
+
+// pseudoFsTypes are the file system types skipped with ExcludePseudo: the
+// in-memory and kernel file systems, the read-only images (snaps are always
+// 100% used) and the overlays of the containers.
+var pseudoFsTypes = map[string]bool{
+	`autofs`:      true,
+	`binfmt_misc`: true,
+	`bpf`:         true,
+	`cgroup`:      true,
+	`cgroup2`:     true,
+	`configfs`:    true,
+	`debugfs`:     true,
+	`devpts`:      true,
+	`devtmpfs`:    true,
+	`efivarfs`:    true,
+	`fusectl`:     true,
+	`hugetlbfs`:   true,
+	`iso9660`:     true,
+	`mqueue`:      true,
+	`nsfs`:        true,
+	`overlay`:     true,
+	`proc`:        true,
+	`pstore`:      true,
+	`ramfs`:       true,
+	`rpc_pipefs`:  true,
+	`securityfs`:  true,
+	`selinuxfs`:   true,
+	`squashfs`:    true,
+	`sysfs`:       true,
+	`tmpfs`:       true,
+	`tracefs`:     true,
+}
+
+// pseudoMountPoints are the directories whose mounts are skipped with
+// ExcludePseudo (the mounts below them included): the kernel interfaces and
+// the internal mounts of the container runtimes.
+var pseudoMountPoints = []string{
+	`/dev`,
+	`/proc`,
+	`/sys`,
+	`/run/credentials`,
+	`/var/lib/docker`,
+	`/var/lib/containers/storage`,
+	`/var/lib/kubelet/pods`,
+}
+
+// isPseudoMount returns true if mount is a pseudo file system or it is mounted
+// below one of pseudoMountPoints.
+func isPseudoMount(mount MountInfo) bool {
+	if pseudoFsTypes[mount.FsType] {
+		return true
+	}
+	for _, dir := range pseudoMountPoints {
+		if mount.MountPoint == dir || strings.HasPrefix(mount.MountPoint, dir+`/`) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FsUsage represents the disk space and inode usage of *one* mounted file
+// system. Sizes are in bytes.
+type FsUsage struct {
+	MountPoint  string    `json:"mountpoint"`  // Mount point
+	Source      string    `json:"source"`      // Mount source (device, server:/export...)
+	FsType      string    `json:"fstype"`      // File system type
+	FuseType    string    `json:"fusetype"`    // FUSE file system (sshfs, rclone, gvfsd-fuse...). Empty if it isn't a FUSE mount
+	Total       uint64    `json:"total"`       // Size of the file system
+	Free        uint64    `json:"free"`        // Free space
+	Available   uint64    `json:"available"`   // Free space available to unprivileged users
+	Used        uint64    `json:"used"`        // Used space
+	UsedPer     float64   `json:"usedper"`     // Percentage of the space available to unprivileged users that is used (as df)
+	Inodes      uint64    `json:"inodes"`      // # of inodes
+	InodesFree  uint64    `json:"inodesfree"`  // # of free inodes
+	InodesUsed  uint64    `json:"inodesused"`  // # of used inodes
+	Stale       bool      `json:"stale"`       // Whether statfs didn't return in time (the values are the last known good ones)
+	CollectedAt time.Time `json:"collectedat"` // Time the values were got
+	Error       string    `json:"error"`       // Error of the last statfs (empty if it succeeded)
+}
+
+// FsUsageCollector collects the usage of the mounted file systems calling
+// statfs on each of them in its own goroutine, since statfs on a dead NFS or
+// FUSE mount can block forever. The mounts that don't answer in time are
+// reported as stale with their last known good values, and no new statfs is
+// issued on them until the blocked one returns. The FUSE file systems (the
+// usual cause of hung collectors) are skipped unless IncludeFuse is set, and
+// the pseudo file systems are skipped if ExcludePseudo is set.
+type FsUsageCollector struct {
+	config  FsUsageConfig
+	mu      sync.Mutex
+	cache   map[string]FsUsage
+	pending map[string]time.Time
+}
+
+// NewFsUsageCollector returns a FsUsageCollector for the given configuration.
+func NewFsUsageCollector(config FsUsageConfig) *FsUsageCollector {
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+
+	return &FsUsageCollector{config: config, cache: map[string]FsUsage{}, pending: map[string]time.Time{}}
+}
+
+// Collect returns the usage of the mounted file systems (the ones of
+// /proc/self/mountinfo with a size), sorted by mount point. It takes at most
+// the configured timeout.
+func (c *FsUsageCollector) Collect() (usages []FsUsage, err error) {
+	mounts, err := getMountInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := map[string]bool{}
+	for _, fsType := range c.config.ExcludeFsTypes {
+		excluded[fsType] = true
+	}
+	// Only the last mount on a mount point is visible
+	visible := map[string]MountInfo{}
+	for _, mount := range mounts {
+		if excluded[mount.FsType] || (mount.IsFuse() && !c.config.IncludeFuse) || (c.config.ExcludePseudo && isPseudoMount(mount)) {
+			// Skip it but keep hiding the mounts below it
+			delete(visible, mount.MountPoint)
+			continue
+		}
+		visible[mount.MountPoint] = mount
+	}
+
+	// done is buffered so the blocked statfs goroutines don't block again
+	// when they return
+	done := make(chan struct{}, len(visible))
+	started := 0
+	c.mu.Lock()
+	for mountPoint, mount := range visible {
+		if _, ok := c.pending[mountPoint]; ok {
+			// The previous statfs is still blocked
+			continue
+		}
+		c.pending[mountPoint] = time.Now()
+		started++
+		go func(mount MountInfo) {
+			usage := statFsUsage(mount)
+			c.mu.Lock()
+			delete(c.pending, mount.MountPoint)
+			if last, ok := c.cache[mount.MountPoint]; ok && usage.Error != `` {
+				// Keep the last known good values
+				last.Error = usage.Error
+				usage = last
+			}
+			c.cache[mount.MountPoint] = usage
+			c.mu.Unlock()
+			done <- struct{}{}
+		}(mount)
+	}
+	c.mu.Unlock()
+
+	timer := time.NewTimer(c.config.Timeout)
+	defer timer.Stop()
+wait:
+	for ; started > 0; started-- {
+		select {
+		case <-done:
+		case <-timer.C:
+			break wait
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	usages = make([]FsUsage, 0, len(visible))
+	for mountPoint, mount := range visible {
+		usage, ok := c.cache[mountPoint]
+		if since, blocked := c.pending[mountPoint]; blocked {
+			if !ok {
+				usage = FsUsage{MountPoint: mount.MountPoint, Source: mount.Source, FsType: mount.FsType, FuseType: mount.FuseType()}
+			}
+			usage.Stale = true
+			usage.Error = "statfs blocked since " + since.Format(time.RFC3339)
+		} else if !ok || (usage.Total == 0 && usage.Error == ``) {
+			// Pseudo file systems (proc, sysfs, cgroup...) don't have a size
+			continue
+		}
+		usages = append(usages, usage)
+	}
+	// Forget the file systems that were unmounted
+	for mountPoint := range c.cache {
+		if _, ok := visible[mountPoint]; !ok {
+			delete(c.cache, mountPoint)
+		}
+	}
+	sort.Slice(usages, func(i, j int) bool { return usages[i].MountPoint < usages[j].MountPoint })
+
+	return usages, nil
+}
+
+// statFsUsage calls statfs on the mount point of mount. If statfs fails the
+// usage only has the error.
+func statFsUsage(mount MountInfo) (usage FsUsage) {
+	usage = FsUsage{
+		MountPoint:  mount.MountPoint,
+		Source:      mount.Source,
+		FsType:      mount.FsType,
+		FuseType:    mount.FuseType(),
+		CollectedAt: time.Now(),
+	}
+
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(mount.MountPoint, &stat); err != nil {
+		usage.Error = err.Error()
+		return usage
+	}
+
+	bsize := uint64(stat.Bsize)
+	usage.Total = uint64(stat.Blocks) * bsize
+	usage.Free = uint64(stat.Bfree) * bsize
+	usage.Available = uint64(stat.Bavail) * bsize
+	usage.Used = usage.Total - usage.Free
+	if usage.Used+usage.Available > 0 {
+		usage.UsedPer = 100 * float64(usage.Used) / float64(usage.Used+usage.Available)
+	}
+	usage.Inodes = uint64(stat.Files)
+	usage.InodesFree = uint64(stat.Ffree)
+	usage.InodesUsed = usage.Inodes - usage.InodesFree
+
+	return usage
+}
@@ -0,0 +1,206 @@
+package sysstats
+
+import (
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"runtime/pprof"
+	"sync"
+	"time"
+)
+
+// GoRuntimeStats represents the runtime statistics of the Go process that
+// hosts the package (the agent or the application that embeds it) since the
+// previous collection.
+type GoRuntimeStats struct {
+	Goroutines      uint64  `json:"goroutines"`      // # of goroutines
+	GoMaxProcs      uint64  `json:"gomaxprocs"`      // # of threads that can run Go code at the same time (GOMAXPROCS)
+	Threads         uint64  `json:"threads"`         // # of OS threads created by the runtime
+	CgoCalls        float64 `json:"cgocalls"`        // # of cgo calls per second
+	HeapObjects     uint64  `json:"heapobjects"`     // # of objects in the heap (live or not swept yet)
+	HeapBytes       uint64  `json:"heapbytes"`       // Bytes of the objects in the heap
+	HeapGoal        uint64  `json:"heapgoal"`        // Heap size the next GC cycle starts at
+	TotalBytes      uint64  `json:"totalbytes"`      // Bytes of memory mapped by the runtime
+	Allocs          float64 `json:"allocs"`          // Bytes allocated in the heap per second
+	GcCycles        float64 `json:"gccycles"`        // # of GC cycles per second
+	GcPauseP50      float64 `json:"gcpausep50"`      // Median of the GC stop-the-world pauses (seconds)
+	GcPauseP99      float64 `json:"gcpausep99"`      // 99th percentile of the GC stop-the-world pauses (seconds)
+	GcPauseMax      float64 `json:"gcpausemax"`      // Max GC stop-the-world pause (seconds)
+	SchedLatencyP50 float64 `json:"schedlatencyp50"` // Median of the time the goroutines waited to run after they were runnable (seconds)
+	SchedLatencyP99 float64 `json:"schedlatencyp99"` // 99th percentile of the time the goroutines waited to run (seconds)
+	SchedLatencyMax float64 `json:"schedlatencymax"` // Max time a goroutine waited to run (seconds)
+}
+
+// GoRuntimeCollector collects the runtime statistics of the hosting Go
+// process from runtime/metrics. The rates and the distributions (GC pauses
+// and scheduler latencies) are calculated between calls to Collect: the
+// first call doesn't have rates and has the distributions since the process
+// started.
+type GoRuntimeCollector struct {
+	mu       sync.Mutex
+	time     time.Time
+	previous []metrics.Sample
+	cgoCalls int64
+}
+
+// goRuntimeMetrics are the runtime/metrics read, in the order of the samples
+// of a GoRuntimeCollector.
+var goRuntimeMetrics = []string{
+	`/sched/goroutines:goroutines`,
+	`/sched/gomaxprocs:threads`,
+	`/gc/heap/objects:objects`,
+	`/memory/classes/heap/objects:bytes`,
+	`/gc/heap/goal:bytes`,
+	`/memory/classes/total:bytes`,
+	`/gc/heap/allocs:bytes`,
+	`/gc/cycles/total:gc-cycles`,
+	`/gc/pauses:seconds`,
+	`/sched/latencies:seconds`,
+}
+
+// NewGoRuntimeCollector returns a GoRuntimeCollector.
+func NewGoRuntimeCollector() *GoRuntimeCollector {
+	return &GoRuntimeCollector{}
+}
+
+// Collect returns the runtime statistics of the process. The metrics the Go
+// version doesn't have are 0.
+func (c *GoRuntimeCollector) Collect() (goRuntimeStats GoRuntimeStats, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	samples := make([]metrics.Sample, len(goRuntimeMetrics))
+	for i, name := range goRuntimeMetrics {
+		samples[i].Name = name
+	}
+	metrics.Read(samples)
+	cgoCalls := runtime.NumCgoCall()
+	previous := c.previous
+	if previous == nil {
+		// Since the process started
+		previous = make([]metrics.Sample, len(samples))
+	}
+
+	goRuntimeStats = GoRuntimeStats{
+		Goroutines:  sampleUint64(samples[0]),
+		GoMaxProcs:  sampleUint64(samples[1]),
+		Threads:     uint64(pprof.Lookup("threadcreate").Count()),
+		HeapObjects: sampleUint64(samples[2]),
+		HeapBytes:   sampleUint64(samples[3]),
+		HeapGoal:    sampleUint64(samples[4]),
+		TotalBytes:  sampleUint64(samples[5]),
+	}
+	if seconds := now.Sub(c.time).Seconds(); !c.time.IsZero() && seconds > 0 {
+		allocs, _ := counterDelta(sampleUint64(previous[6]), sampleUint64(samples[6]))
+		cycles, _ := counterDelta(sampleUint64(previous[7]), sampleUint64(samples[7]))
+		goRuntimeStats.Allocs = float64(allocs) / seconds
+		goRuntimeStats.GcCycles = float64(cycles) / seconds
+		goRuntimeStats.CgoCalls = float64(cgoCalls-c.cgoCalls) / seconds
+	}
+	pauses := histogramDelta(previous[8], samples[8])
+	goRuntimeStats.GcPauseP50 = histogramQuantile(pauses, 0.5)
+	goRuntimeStats.GcPauseP99 = histogramQuantile(pauses, 0.99)
+	goRuntimeStats.GcPauseMax = histogramQuantile(pauses, 1)
+	latencies := histogramDelta(previous[9], samples[9])
+	goRuntimeStats.SchedLatencyP50 = histogramQuantile(latencies, 0.5)
+	goRuntimeStats.SchedLatencyP99 = histogramQuantile(latencies, 0.99)
+	goRuntimeStats.SchedLatencyMax = histogramQuantile(latencies, 1)
+
+	c.time, c.previous, c.cgoCalls = now, samples, cgoCalls
+
+	return goRuntimeStats, nil
+}
+
+// Metrics returns the runtime statistics as a list of metrics named go.<key>
+// (e.g. go.goroutines), so they are exported and evaluated by the alerting
+// rules with the metrics of the host.
+func (goRuntimeStats GoRuntimeStats) Metrics() (metrics []Metric) {
+	metrics = []Metric{
+		{Name: `go.goroutines`, Value: float64(goRuntimeStats.Goroutines)},
+		{Name: `go.gomaxprocs`, Value: float64(goRuntimeStats.GoMaxProcs)},
+		{Name: `go.threads`, Value: float64(goRuntimeStats.Threads)},
+		{Name: `go.cgocalls`, Value: goRuntimeStats.CgoCalls},
+		{Name: `go.heapobjects`, Value: float64(goRuntimeStats.HeapObjects)},
+		{Name: `go.heapbytes`, Value: float64(goRuntimeStats.HeapBytes)},
+		{Name: `go.heapgoal`, Value: float64(goRuntimeStats.HeapGoal)},
+		{Name: `go.totalbytes`, Value: float64(goRuntimeStats.TotalBytes)},
+		{Name: `go.allocs`, Value: goRuntimeStats.Allocs},
+		{Name: `go.gccycles`, Value: goRuntimeStats.GcCycles},
+		{Name: `go.gcpausep50`, Value: goRuntimeStats.GcPauseP50},
+		{Name: `go.gcpausep99`, Value: goRuntimeStats.GcPauseP99},
+		{Name: `go.gcpausemax`, Value: goRuntimeStats.GcPauseMax},
+		{Name: `go.schedlatencyp50`, Value: goRuntimeStats.SchedLatencyP50},
+		{Name: `go.schedlatencyp99`, Value: goRuntimeStats.SchedLatencyP99},
+		{Name: `go.schedlatencymax`, Value: goRuntimeStats.SchedLatencyMax},
+	}
+	for i := range metrics {
+		metrics[i].Labels = map[string]string{}
+	}
+
+	return metrics
+}
+
+// sampleUint64 returns the value of a sample of runtime/metrics (0 if it
+// isn't an uint64, e.g. the metric doesn't exist).
+func sampleUint64(sample metrics.Sample) uint64 {
+	if sample.Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+
+	return sample.Value.Uint64()
+}
+
+// histogramDelta returns the histogram of a sample of runtime/metrics minus
+// the histogram of the previous sample (nil if it isn't a histogram). The
+// buckets of a metric don't change while the process runs.
+func histogramDelta(previous metrics.Sample, sample metrics.Sample) *metrics.Float64Histogram {
+	if sample.Value.Kind() != metrics.KindFloat64Histogram {
+		return nil
+	}
+	histogram := sample.Value.Float64Histogram()
+	delta := &metrics.Float64Histogram{Counts: append([]uint64(nil), histogram.Counts...), Buckets: histogram.Buckets}
+	if previous.Value.Kind() == metrics.KindFloat64Histogram {
+		if counts := previous.Value.Float64Histogram().Counts; len(counts) == len(delta.Counts) {
+			for i, count := range counts {
+				delta.Counts[i], _ = counterDelta(count, delta.Counts[i])
+			}
+		}
+	}
+
+	return delta
+}
+
+// histogramQuantile returns the q-th quantile (0-1) of a histogram of
+// runtime/metrics, as the upper bound of its bucket (the lower bound for
+// the last bucket, that is unbounded). It returns 0 if the histogram is
+// empty.
+func histogramQuantile(histogram *metrics.Float64Histogram, q float64) float64 {
+	if histogram == nil {
+		return 0
+	}
+	total := uint64(0)
+	for _, count := range histogram.Counts {
+		total += count
+	}
+	if total == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(q * float64(total)))
+	if rank < 1 {
+		rank = 1
+	}
+	cumulative := uint64(0)
+	for i, count := range histogram.Counts {
+		cumulative += count
+		if cumulative >= rank {
+			if upper := histogram.Buckets[i+1]; !math.IsInf(upper, 1) {
+				return upper
+			}
+			return histogram.Buckets[i]
+		}
+	}
+
+	return 0
+}
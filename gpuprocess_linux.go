@@ -0,0 +1,325 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProcessGpu represents the GPU usage of *one* process of a top-N ranking.
+type ProcessGpu struct {
+	Memory      uint64             `json:"memory"`      // GPU memory used in bytes (the resident memory if the driver reports it)
+	Utilization float64            `json:"utilization"` // % of time of the busiest engine used by the process
+	Engines     map[string]float64 `json:"engines"`     // % of time of each engine (render, video, compute...) used by the process (DRM drivers only)
+	Devices     []string           `json:"devices"`     // GPUs used by the process (PCI addresses)
+}
+
+// drmClientKey identifies a DRM client (the file descriptor it was opened
+// with can be shared by several processes).
+type drmClientKey struct {
+	pdev string
+	id   string
+}
+
+// drmClient represents the usage of *one* DRM client from its fdinfo.
+type drmClient struct {
+	pid         int
+	memory      uint64            // Bytes
+	engines     map[string]uint64 // Busy time of each engine (nanoseconds)
+	capacities  map[string]uint64 // # of engines of each class (1 if not reported)
+	cycles      map[string]uint64 // Busy cycles of each engine (drivers like xe)
+	totalCycles map[string]uint64 // Total cycles of each engine
+}
+
+// gpuSample represents the GPU usage of the processes at *one* time.
+type gpuSample struct {
+	time    time.Time
+	clients map[drmClientKey]drmClient
+	nvidia  map[int]*ProcessGpu // Usage reported by nvidia-smi by pid
+}
+
+// getGpuSample gets the GPU usage of the processes from the fdinfo of their
+// DRM file descriptors (kernel 5.19+, /dev/dri/*) and, if it's installed,
+// from nvidia-smi (the proprietary NVIDIA driver doesn't have DRM clients).
+// The processes whose file descriptors can't be read (other users'
+// processes when not run as root) are skipped. nvidia-smi samples the
+// utilization itself, so it's only run if nvidia is true.
+func getGpuSample(pids []int, nvidia bool) gpuSample {
+	sample := gpuSample{time: clockNow(), clients: map[drmClientKey]drmClient{}}
+	for _, pid := range pids {
+		fdDir := procPath(strconv.Itoa(pid), "fd")
+		fds, err := readProcDirNames(strconv.Itoa(pid), "fd")
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			target, err := os.Readlink(fdDir + "/" + fd)
+			if err != nil || !strings.HasPrefix(target, `/dev/dri/`) {
+				continue
+			}
+			content, err := getProcReader().readString(strconv.Itoa(pid), "fdinfo", fd)
+			if err != nil {
+				continue
+			}
+			key, client, ok := parseDrmFdinfo(content)
+			if !ok {
+				continue
+			}
+			// The pids are sorted, so a shared client is attributed to the
+			// oldest process (usually the one that opened it)
+			if _, seen := sample.clients[key]; !seen {
+				client.pid = pid
+				sample.clients[key] = client
+			}
+		}
+	}
+	if nvidia {
+		sample.nvidia = getNvidiaProcesses()
+	}
+
+	return sample
+}
+
+// parseDrmFdinfo parses the DRM keys of the fdinfo of a DRM file
+// descriptor:
+//   drm-driver:	i915
+//   drm-pdev:	0000:00:02.0
+//   drm-client-id:	7
+//   drm-engine-render:	9288864723 ns
+//   drm-engine-capacity-video:	2
+//   drm-memory-vram:	4096 KiB
+//   drm-resident-vram:	4096 KiB
+// The resident memory keys (6.4+) are used over the older drm-memory ones.
+// ok is false if the fdinfo doesn't have a client id (an old kernel or a
+// driver without the usage stats).
+func parseDrmFdinfo(content string) (key drmClientKey, client drmClient, ok bool) {
+	client = drmClient{engines: map[string]uint64{}, capacities: map[string]uint64{}, cycles: map[string]uint64{}, totalCycles: map[string]uint64{}}
+	var memory, resident uint64
+	hasResident := false
+	for _, line := range strings.Split(content, "\n") {
+		i := strings.IndexByte(line, ':')
+		if i < 0 || !strings.HasPrefix(line, `drm-`) {
+			continue
+		}
+		name, value := line[:i], strings.TrimSpace(line[i+1:])
+		switch {
+		case name == `drm-pdev`:
+			key.pdev = value
+		case name == `drm-client-id`:
+			key.id, ok = value, true
+		case strings.HasPrefix(name, `drm-engine-capacity-`):
+			client.capacities[strings.TrimPrefix(name, `drm-engine-capacity-`)], _ = strconv.ParseUint(value, 10, 64)
+		case strings.HasPrefix(name, `drm-engine-`):
+			client.engines[strings.TrimPrefix(name, `drm-engine-`)], _ = strconv.ParseUint(strings.TrimSuffix(value, ` ns`), 10, 64)
+		case strings.HasPrefix(name, `drm-total-cycles-`):
+			client.totalCycles[strings.TrimPrefix(name, `drm-total-cycles-`)], _ = strconv.ParseUint(value, 10, 64)
+		case strings.HasPrefix(name, `drm-cycles-`):
+			client.cycles[strings.TrimPrefix(name, `drm-cycles-`)], _ = strconv.ParseUint(value, 10, 64)
+		case strings.HasPrefix(name, `drm-resident-`):
+			resident += parseDrmMemory(value)
+			hasResident = true
+		case strings.HasPrefix(name, `drm-memory-`):
+			memory += parseDrmMemory(value)
+		}
+	}
+	client.memory = memory
+	if hasResident {
+		client.memory = resident
+	}
+
+	return key, client, ok
+}
+
+// parseDrmMemory returns the bytes of a memory value of the fdinfo (e.g.
+// 4096 KiB). The unit is optional (bytes).
+func parseDrmMemory(value string) uint64 {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return 0
+	}
+	size, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return 0
+	}
+	if len(fields) > 1 {
+		switch fields[1] {
+		case `KiB`:
+			size <<= 10
+		case `MiB`:
+			size <<= 20
+		case `GiB`:
+			size <<= 30
+		}
+	}
+
+	return size
+}
+
+// getNvidiaProcesses returns the GPU memory (nvidia-smi --query-compute-apps)
+// and the SM utilization (nvidia-smi pmon) of the processes using the NVIDIA
+// GPUs. It returns nil if nvidia-smi isn't installed or fails. The pids are
+// the ones of the host: the processes of other pid namespaces aren't
+// matched.
+func getNvidiaProcesses() (processes map[int]*ProcessGpu) {
+	nvidiaSmi, err := exec.LookPath("nvidia-smi")
+	if err != nil {
+		return nil
+	}
+	out, err := exec.Command(nvidiaSmi, "--query-compute-apps=pid,gpu_bus_id,used_memory", "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return nil
+	}
+	processes = parseNvidiaComputeApps(out)
+
+	// pmon samples the utilization for a second
+	if out, err := exec.Command(nvidiaSmi, "pmon", "-c", "1", "-s", "u").Output(); err == nil {
+		parseNvidiaPmon(out, processes)
+	}
+
+	return processes
+}
+
+// parseNvidiaComputeApps parses the output of nvidia-smi
+// --query-compute-apps=pid,gpu_bus_id,used_memory --format=csv,noheader,nounits:
+//   1234, 00000000:3B:00.0, 2048
+// The memory is in MiB.
+func parseNvidiaComputeApps(out []byte) (processes map[int]*ProcessGpu) {
+	processes = map[int]*ProcessGpu{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), `,`)
+		if len(fields) != 3 {
+			continue
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+		if err != nil {
+			continue
+		}
+		process := processGpu(processes, pid)
+		if memory, err := strconv.ParseUint(strings.TrimSpace(fields[2]), 10, 64); err == nil {
+			process.Memory += memory << 20
+		}
+		// The bus ids have a domain of 8 digits (00000000:3B:00.0) instead
+		// of the 4 ones of the PCI addresses
+		busId := strings.ToLower(strings.TrimSpace(fields[1]))
+		if len(busId) > len(`0000:00:00.0`) {
+			busId = busId[len(busId)-len(`0000:00:00.0`):]
+		}
+		process.Devices = appendDevice(process.Devices, busId)
+	}
+
+	return processes
+}
+
+// parseNvidiaPmon parses the SM utilization of the output of nvidia-smi pmon
+// -c 1 -s u (- if the process didn't use the GPU):
+//   # gpu        pid  type    sm   mem   enc   dec   command
+//   # Idx          #   C/G     %     %     %     %   name
+//       0       1234     C    45    12     -     -   python
+// The utilization of a process that uses several GPUs is the one of the
+// busiest one.
+func parseNvidiaPmon(out []byte, processes map[int]*ProcessGpu) {
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || strings.HasPrefix(fields[0], `#`) {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		if sm, err := strconv.ParseFloat(fields[3], 64); err == nil {
+			process := processGpu(processes, pid)
+			if sm > process.Utilization {
+				process.Utilization = sm
+			}
+		}
+	}
+}
+
+// processGpu returns the usage of pid of processes, adding it if it isn't
+// there.
+func processGpu(processes map[int]*ProcessGpu, pid int) *ProcessGpu {
+	process, ok := processes[pid]
+	if !ok {
+		process = &ProcessGpu{Engines: map[string]float64{}, Devices: []string{}}
+		processes[pid] = process
+	}
+
+	return process
+}
+
+// appendDevice appends a device to devices if it isn't there (sorted).
+func appendDevice(devices []string, device string) []string {
+	for _, d := range devices {
+		if d == device {
+			return devices
+		}
+	}
+	devices = append(devices, device)
+	sort.Strings(devices)
+
+	return devices
+}
+
+// getProcessGpus calculates the GPU usage of each process between 2
+// samples. The utilization of the clients that are only in the second
+// sample (opened during the interval) isn't known, only their memory. It
+// only returns the processes that use a GPU.
+func getProcessGpus(first gpuSample, second gpuSample) (gpus map[int]*ProcessGpu) {
+	gpus = map[int]*ProcessGpu{}
+	elapsed := float64(second.time.Sub(first.time))
+	for key, client := range second.clients {
+		gpu := processGpu(gpus, client.pid)
+		gpu.Memory += client.memory
+		if key.pdev != `` {
+			gpu.Devices = appendDevice(gpu.Devices, key.pdev)
+		}
+		previous, ok := first.clients[key]
+		if !ok || elapsed <= 0 {
+			continue
+		}
+		for engine, busy := range client.engines {
+			capacity := client.capacities[engine]
+			if capacity == 0 {
+				capacity = 1
+			}
+			delta, _ := counterDelta(previous.engines[engine], busy)
+			gpu.Engines[engine] += 100 * float64(delta) / elapsed / float64(capacity)
+		}
+		for engine, cycles := range client.cycles {
+			total, _ := counterDelta(previous.totalCycles[engine], client.totalCycles[engine])
+			if delta, _ := counterDelta(previous.cycles[engine], cycles); total > 0 {
+				gpu.Engines[engine] += 100 * float64(delta) / float64(total)
+			}
+		}
+	}
+	for pid, nvidia := range second.nvidia {
+		gpu := processGpu(gpus, pid)
+		gpu.Memory += nvidia.Memory
+		for _, device := range nvidia.Devices {
+			gpu.Devices = appendDevice(gpu.Devices, device)
+		}
+		if nvidia.Utilization > gpu.Utilization {
+			gpu.Utilization = nvidia.Utilization
+		}
+	}
+	for _, gpu := range gpus {
+		for engine, utilization := range gpu.Engines {
+			gpu.Engines[engine] = clampPer(utilization)
+			if gpu.Engines[engine] > gpu.Utilization {
+				gpu.Utilization = gpu.Engines[engine]
+			}
+		}
+	}
+
+	return gpus
+}
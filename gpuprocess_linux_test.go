@@ -0,0 +1,68 @@
+// +build linux
+
+package sysstats
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// drmFdinfo returns the fdinfo of an i915 client whose render engine was
+// busy for render nanoseconds.
+func drmFdinfo(render string) string {
+	return "pos:\t0\nflags:\t02100002\nmnt_id:\t26\n" +
+		"drm-driver:\ti915\ndrm-pdev:\t0000:00:02.0\ndrm-client-id:\t7\n" +
+		"drm-engine-render:\t" + render + " ns\ndrm-engine-video:\t0 ns\ndrm-engine-capacity-video:\t2\n" +
+		"drm-memory-system:\t8192 KiB\ndrm-resident-system:\t4096 KiB\n"
+}
+
+func TestParseDrmFdinfo(t *testing.T) {
+	key, client, ok := parseDrmFdinfo(drmFdinfo(`9288864723`))
+	if !ok {
+		t.Fatal("The fdinfo of a DRM client wasn't parsed")
+	}
+	if key != (drmClientKey{pdev: `0000:00:02.0`, id: `7`}) {
+		t.Errorf("Key %+v", key)
+	}
+	// The resident memory is used over the total one
+	if client.memory != 4096<<10 {
+		t.Errorf("Memory %d, want %d", client.memory, 4096<<10)
+	}
+	if client.engines[`render`] != 9288864723 || client.capacities[`video`] != 2 {
+		t.Errorf("Engines %v and capacities %v", client.engines, client.capacities)
+	}
+
+	// Without the usage stats of the driver
+	if _, _, ok := parseDrmFdinfo("pos:\t0\ndrm-driver:\tnouveau\n"); ok {
+		t.Error("An fdinfo without a client id was parsed")
+	}
+}
+
+func TestGetProcessGpus(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	sample := func(at time.Time, render string) gpuSample {
+		key, client, _ := parseDrmFdinfo(drmFdinfo(render))
+		client.pid = 1234
+		return gpuSample{time: at, clients: map[drmClientKey]drmClient{key: client}}
+	}
+	second := sample(start.Add(2*time.Second), `1500000000`)
+	second.nvidia = parseNvidiaComputeApps([]byte("4321, 00000000:3B:00.0, 2048\n"))
+	parseNvidiaPmon([]byte("# gpu        pid  type    sm   mem   enc   dec   command\n# Idx          #   C/G     %     %     %     %   name\n    0       4321     C    45    12     -     -   python\n"), second.nvidia)
+
+	gpus := getProcessGpus(sample(start, `500000000`), second)
+	drm, nvidia := gpus[1234], gpus[4321]
+	if drm == nil || nvidia == nil {
+		t.Fatalf("Processes %v, want 1234 and 4321", gpus)
+	}
+	// 1s of render time in 2s
+	if drm.Engines[`render`] != 50 || drm.Utilization != 50 || drm.Memory != 4096<<10 {
+		t.Errorf("Usage of the DRM client %+v", drm)
+	}
+	if !reflect.DeepEqual(drm.Devices, []string{`0000:00:02.0`}) {
+		t.Errorf("Devices of the DRM client %v", drm.Devices)
+	}
+	if nvidia.Utilization != 45 || nvidia.Memory != 2048<<20 || !reflect.DeepEqual(nvidia.Devices, []string{`0000:3b:00.0`}) {
+		t.Errorf("Usage of the nvidia process %+v", nvidia)
+	}
+}
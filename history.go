@@ -0,0 +1,455 @@
+package sysstats
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HistoryConfig represents how many snapshots a History retains. If both are
+// set, the snapshots are dropped when either limit is reached.
+type HistoryConfig struct {
+	MaxAge     time.Duration // Max age of the snapshots, relative to the newest one (default 1 hour if MaxSamples isn't set)
+	MaxSamples int           // Max # of snapshots (0 means no limit)
+}
+
+// Window represents the time window of a query of a History.
+type Window struct {
+	from time.Time
+	to   time.Time
+	last time.Duration
+}
+
+// Last returns the window of the last d (until the time of the query).
+func Last(d time.Duration) Window {
+	return Window{last: d}
+}
+
+// Between returns the window between from and to (both included).
+func Between(from time.Time, to time.Time) Window {
+	return Window{from: from, to: to}
+}
+
+// All returns the window with all the snapshots retained.
+func All() Window {
+	return Window{}
+}
+
+// contains returns true if the window has the time t, for a query at the
+// time now.
+func (w Window) contains(t time.Time, now time.Time) bool {
+	if w.last > 0 {
+		return !t.Before(now.Add(-w.last))
+	}
+	if !w.from.IsZero() && t.Before(w.from) {
+		return false
+	}
+	if !w.to.IsZero() && t.After(w.to) {
+		return false
+	}
+
+	return true
+}
+
+// Point represents *one* value of a Series.
+type Point struct {
+	Time  time.Time `json:"time"`  // Time of the snapshot
+	Value float64   `json:"value"` // Value
+}
+
+// Series represents the values of a statistic over a window, sorted by time.
+// The aggregations of an empty series are 0.
+type Series []Point
+
+// Len returns the # of values.
+func (s Series) Len() int {
+	return len(s)
+}
+
+// Values returns the values (oldest first), e.g. to render them with the
+// termgraph package.
+func (s Series) Values() []float64 {
+	values := make([]float64, 0, len(s))
+	for _, point := range s {
+		values = append(values, point.Value)
+	}
+
+	return values
+}
+
+// Last returns the newest value.
+func (s Series) Last() float64 {
+	if len(s) == 0 {
+		return 0
+	}
+
+	return s[len(s)-1].Value
+}
+
+// Min returns the min value.
+func (s Series) Min() float64 {
+	if len(s) == 0 {
+		return 0
+	}
+	min := math.Inf(1)
+	for _, point := range s {
+		min = math.Min(min, point.Value)
+	}
+
+	return min
+}
+
+// Max returns the max value.
+func (s Series) Max() float64 {
+	if len(s) == 0 {
+		return 0
+	}
+	max := math.Inf(-1)
+	for _, point := range s {
+		max = math.Max(max, point.Value)
+	}
+
+	return max
+}
+
+// Mean returns the average value.
+func (s Series) Mean() float64 {
+	if len(s) == 0 {
+		return 0
+	}
+	sum := float64(0)
+	for _, point := range s {
+		sum += point.Value
+	}
+
+	return sum / float64(len(s))
+}
+
+// Percentile returns the p-th percentile (0-100) of the values (nearest
+// rank), e.g. Percentile(95).
+func (s Series) Percentile(p float64) float64 {
+	if len(s) == 0 {
+		return 0
+	}
+	values := make([]float64, 0, len(s))
+	for _, point := range s {
+		values = append(values, point.Value)
+	}
+	sort.Float64s(values)
+	rank := int(math.Ceil(p / 100 * float64(len(values))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(values) {
+		rank = len(values)
+	}
+
+	return values[rank-1]
+}
+
+// historyEntry is *one* snapshot retained by a History with the statistics
+// since the previous one.
+type historyEntry struct {
+	snapshot Snapshot
+	avgStats *SnapshotAvgStats // nil for the first snapshot (or if the rates couldn't be calculated)
+	memInfo  *MemInfo
+}
+
+// History retains the last snapshots (and memory statistics) in a ring
+// buffer and answers aggregation queries over time windows, e.g.:
+//   history.MemUsed(Last(5 * time.Minute)).Max()
+//   history.CpuUsage(`cpu`, Last(time.Hour)).Percentile(95)
+// The rates (CPU, network, disk) are calculated between consecutive
+// snapshots when they are added.
+type History struct {
+	config HistoryConfig
+	mu     sync.RWMutex
+	buf    []historyEntry
+	start  int
+	count  int
+}
+
+// NewHistory returns a History for the given configuration.
+func NewHistory(config HistoryConfig) *History {
+	if config.MaxAge <= 0 && config.MaxSamples <= 0 {
+		config.MaxAge = time.Hour
+	}
+	size := 64
+	if config.MaxSamples > 0 && config.MaxSamples < size {
+		size = config.MaxSamples
+	}
+
+	return &History{config: config, buf: make([]historyEntry, size)}
+}
+
+// Add adds a snapshot (and the memory statistics at the same time, that can
+// be nil) and drops the snapshots over the limits. It returns an error if
+// the snapshot is older than the newest one.
+func (h *History) Add(snapshot Snapshot, memInfo *MemInfo) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entry := historyEntry{snapshot: snapshot, memInfo: memInfo}
+	if h.count > 0 {
+		newest := h.entry(h.count - 1)
+		if snapshot.Timestamp.Before(newest.snapshot.Timestamp) {
+			return errors.New("The snapshot is older than the newest one of the history")
+		}
+		// The snapshots less than 1 second apart don't have rates
+		if avgStats, err := getSnapshotAvgStats(newest.snapshot, snapshot); err == nil {
+			entry.avgStats = &avgStats
+		}
+	}
+
+	if h.count == len(h.buf) {
+		if h.config.MaxSamples > 0 && h.count >= h.config.MaxSamples {
+			h.pop()
+		} else {
+			h.grow()
+		}
+	}
+	h.buf[(h.start+h.count)%len(h.buf)] = entry
+	h.count++
+
+	if h.config.MaxAge > 0 {
+		for h.count > 1 && snapshot.Timestamp.Sub(h.entry(0).snapshot.Timestamp) > h.config.MaxAge {
+			h.pop()
+		}
+	}
+
+	return nil
+}
+
+// Len returns the # of snapshots retained.
+func (h *History) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.count
+}
+
+// Snapshots returns the snapshots of the window (oldest first).
+func (h *History) Snapshots(w Window) (snapshots []Snapshot) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	snapshots = []Snapshot{}
+	now := clockNow()
+	for i := 0; i < h.count; i++ {
+		entry := h.entry(i)
+		if w.contains(entry.snapshot.Timestamp, now) {
+			snapshots = append(snapshots, entry.snapshot)
+		}
+	}
+
+	return snapshots
+}
+
+// MemUsed returns the used memory (kilobytes, memtotal - memfree) over the
+// window.
+func (h *History) MemUsed(w Window) Series {
+	return h.memSeries(w, func(memInfo *MemInfo) float64 { return float64(memInfo.MemUsed) })
+}
+
+// MemFree returns the free memory (kilobytes) over the window.
+func (h *History) MemFree(w Window) Series {
+	return h.memSeries(w, func(memInfo *MemInfo) float64 { return float64(memInfo.MemFree) })
+}
+
+// MemRealFree returns the memory really free (kilobytes, memfree + buffers +
+// cached) over the window.
+func (h *History) MemRealFree(w Window) Series {
+	return h.memSeries(w, func(memInfo *MemInfo) float64 { return float64(memInfo.RealFree) })
+}
+
+// SwapUsed returns the used swap space (kilobytes) over the window.
+func (h *History) SwapUsed(w Window) Series {
+	return h.memSeries(w, func(memInfo *MemInfo) float64 { return float64(memInfo.SwapUsed) })
+}
+
+// CpuUsage returns the % of CPU time not idle of a CPU (cpu for all of them)
+// over the window.
+func (h *History) CpuUsage(cpu string, w Window) Series {
+	return h.avgSeries(w, func(avgStats *SnapshotAvgStats) (float64, bool) {
+		cpuAvgStats, ok := avgStats.Cpus[cpu]
+		return cpuAvgStats[`total`], ok
+	})
+}
+
+// RunQueue returns the # of runnable entities over the window.
+func (h *History) RunQueue(w Window) Series {
+	return h.avgSeries(w, func(avgStats *SnapshotAvgStats) (float64, bool) {
+		return float64(avgStats.Procs.RunQueue), true
+	})
+}
+
+// NetRxBytes returns the bytes received per second by a network interface
+// over the window.
+func (h *History) NetRxBytes(iface string, w Window) Series {
+	return h.avgSeries(w, func(avgStats *SnapshotAvgStats) (float64, bool) {
+		ifaceAvgStats, ok := avgStats.Net[iface]
+		return ifaceAvgStats[`rxbytes`], ok
+	})
+}
+
+// NetTxBytes returns the bytes transmitted per second by a network interface
+// over the window.
+func (h *History) NetTxBytes(iface string, w Window) Series {
+	return h.avgSeries(w, func(avgStats *SnapshotAvgStats) (float64, bool) {
+		ifaceAvgStats, ok := avgStats.Net[iface]
+		return ifaceAvgStats[`txbytes`], ok
+	})
+}
+
+// DiskUtil returns the % of time a disk was doing I/Os over the window.
+func (h *History) DiskUtil(disk string, w Window) Series {
+	return h.diskSeries(disk, w, func(diskAvgStats DiskAvgStats) float64 { return diskAvgStats.Util })
+}
+
+// DiskReadBytes returns the bytes read per second from a disk over the
+// window.
+func (h *History) DiskReadBytes(disk string, w Window) Series {
+	return h.diskSeries(disk, w, func(diskAvgStats DiskAvgStats) float64 { return diskAvgStats.ReadBytes })
+}
+
+// DiskWriteBytes returns the bytes written per second to a disk over the
+// window.
+func (h *History) DiskWriteBytes(disk string, w Window) Series {
+	return h.diskSeries(disk, w, func(diskAvgStats DiskAvgStats) float64 { return diskAvgStats.WriteBytes })
+}
+
+// Metric returns the values of a metric (see SnapshotAvgStats.Metrics) with
+// labels over the window, e.g. Metric(`cpu.iowait`, map[string]string{`cpu`:
+// `cpu0`}, Last(time.Minute)). If several values of the metric match the
+// labels, the first one is used.
+func (h *History) Metric(name string, labels map[string]string, w Window) Series {
+	return h.avgSeries(w, func(avgStats *SnapshotAvgStats) (float64, bool) {
+		metrics, _ := avgStats.Metrics()
+		for _, metric := range metrics {
+			if metric.Name == name && matchLabels(metric.Labels, labels) {
+				return metric.Value, true
+			}
+		}
+		return 0, false
+	})
+}
+
+// MetricBatch represents the metrics of *one* snapshot of a History.
+type MetricBatch struct {
+	Time    time.Time `json:"time"`    // Time of the snapshot
+	Metrics []Metric  `json:"metrics"` // Metrics since the previous snapshot and the memory statistics (mem.*)
+}
+
+// Batches returns the metrics of the snapshots of the window (oldest first):
+// the ones of SnapshotAvgStats.Metrics and the memory statistics as mem.*
+// (see MemInfo.ToMap). The snapshots without rates nor memory statistics are
+// skipped.
+func (h *History) Batches(w Window) (batches []MetricBatch) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	batches = []MetricBatch{}
+	now := clockNow()
+	for i := 0; i < h.count; i++ {
+		entry := h.entry(i)
+		if (entry.avgStats == nil && entry.memInfo == nil) || !w.contains(entry.snapshot.Timestamp, now) {
+			continue
+		}
+		batch := MetricBatch{Time: entry.snapshot.Timestamp, Metrics: []Metric{}}
+		if entry.avgStats != nil {
+			batch.Metrics, _ = entry.avgStats.Metrics()
+		}
+		if entry.memInfo != nil {
+			memStats := entry.memInfo.ToMap()
+			keys := make([]string, 0, len(memStats))
+			for key := range memStats {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				batch.Metrics = append(batch.Metrics, Metric{Name: `mem.` + key, Labels: map[string]string{}, Value: float64(memStats[key])})
+			}
+		}
+		batches = append(batches, batch)
+	}
+
+	return batches
+}
+
+// memSeries returns the values of a memory statistic over the window. The
+// snapshots without memory statistics are skipped.
+func (h *History) memSeries(w Window, value func(memInfo *MemInfo) float64) Series {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	series := Series{}
+	now := clockNow()
+	for i := 0; i < h.count; i++ {
+		entry := h.entry(i)
+		if entry.memInfo != nil && w.contains(entry.snapshot.Timestamp, now) {
+			series = append(series, Point{Time: entry.snapshot.Timestamp, Value: value(entry.memInfo)})
+		}
+	}
+
+	return series
+}
+
+// avgSeries returns the values of a rate over the window. The snapshots
+// without rates (or where value returns false) are skipped.
+func (h *History) avgSeries(w Window, value func(avgStats *SnapshotAvgStats) (float64, bool)) Series {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	series := Series{}
+	now := clockNow()
+	for i := 0; i < h.count; i++ {
+		entry := h.entry(i)
+		if entry.avgStats == nil || !w.contains(entry.snapshot.Timestamp, now) {
+			continue
+		}
+		if v, ok := value(entry.avgStats); ok {
+			series = append(series, Point{Time: entry.snapshot.Timestamp, Value: v})
+		}
+	}
+
+	return series
+}
+
+// diskSeries returns the values of a statistic of a disk over the window.
+func (h *History) diskSeries(disk string, w Window, value func(diskAvgStats DiskAvgStats) float64) Series {
+	return h.avgSeries(w, func(avgStats *SnapshotAvgStats) (float64, bool) {
+		for _, diskAvgStats := range avgStats.Disks {
+			if diskAvgStats.Name == disk {
+				return value(diskAvgStats), true
+			}
+		}
+		return 0, false
+	})
+}
+
+// entry returns the entry i of the ring buffer (0 is the oldest one).
+func (h *History) entry(i int) *historyEntry {
+	return &h.buf[(h.start+i)%len(h.buf)]
+}
+
+// pop drops the oldest entry.
+func (h *History) pop() {
+	h.buf[h.start] = historyEntry{}
+	h.start = (h.start + 1) % len(h.buf)
+	h.count--
+}
+
+// grow doubles the size of the ring buffer (up to MaxSamples).
+func (h *History) grow() {
+	size := 2 * len(h.buf)
+	if h.config.MaxSamples > 0 && size > h.config.MaxSamples {
+		size = h.config.MaxSamples
+	}
+	buf := make([]historyEntry, size)
+	for i := 0; i < h.count; i++ {
+		buf[i] = *h.entry(i)
+	}
+	h.buf, h.start = buf, 0
+}
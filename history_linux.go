@@ -0,0 +1,18 @@
+// +build linux
+
+package sysstats
+
+// Collect takes a snapshot and reads the memory statistics of the system and
+// adds them to the history, e.g. on every tick of a time.Ticker.
+func (h *History) Collect() error {
+	snapshot, err := getWatchSnapshot()
+	if err != nil {
+		return err
+	}
+	memInfo, err := getMemInfo()
+	if err != nil {
+		return err
+	}
+
+	return h.Add(snapshot, &memInfo)
+}
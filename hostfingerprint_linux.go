@@ -0,0 +1,204 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HostFingerprint represents the identity of a host, to detect that a
+// stored state belongs to another machine (e.g. a cloned VM, which keeps the
+// files of the original one). Each component can change on its own (a NIC
+// or a disk replaced, a machine-id regenerated), so 2 fingerprints are of
+// the same host if most of the components known in both of them match (see
+// SameHost).
+type HostFingerprint struct {
+	Id          string   `json:"id"`          // SHA-256 of the components (hex)
+	MachineId   string   `json:"machineid"`   // /etc/machine-id (or /var/lib/dbus/machine-id)
+	PrimaryMac  string   `json:"primarymac"`  // MAC address of the interface of the default route (or of the first physical interface)
+	DiskSerials []string `json:"diskserials"` // Serials of the physical disks (sorted)
+}
+
+// HostIdentityChange represents the detection that a stored state belongs
+// to another host.
+type HostIdentityChange struct {
+	Time     time.Time       `json:"time"`     // Time of the detection
+	Source   string          `json:"source"`   // What was stored (e.g. the file of the state of an agent)
+	Previous HostFingerprint `json:"previous"` // Fingerprint of the host that stored it
+	Current  HostFingerprint `json:"current"`  // Fingerprint of the current host
+	Changed  []string        `json:"changed"`  // Components that changed (machineid, primarymac, diskserials)
+}
+
+// machineIdFiles are the files of the machine id (systemd, and D-Bus on the
+// systems without systemd).
+var machineIdFiles = []string{`/etc/machine-id`, `/var/lib/dbus/machine-id`}
+
+// getHostFingerprint gets the fingerprint of a linux system from its
+// machine-id, /proc/net/route, /sys/class/net and /sys/block. The
+// components that can't be read are empty: it only returns an error if none
+// of them can.
+func getHostFingerprint() (fingerprint HostFingerprint, err error) {
+	for _, path := range machineIdFiles {
+		if fingerprint.MachineId, err = readStringFile(path); err == nil && fingerprint.MachineId != `` {
+			break
+		}
+	}
+	fingerprint.PrimaryMac = readPrimaryMac()
+	fingerprint.DiskSerials = readDiskSerials()
+	if fingerprint.MachineId == `` && fingerprint.PrimaryMac == `` && len(fingerprint.DiskSerials) == 0 {
+		return HostFingerprint{}, os.ErrNotExist
+	}
+
+	sum := sha256.Sum256([]byte(fingerprint.MachineId + "\n" + fingerprint.PrimaryMac + "\n" + strings.Join(fingerprint.DiskSerials, ",")))
+	fingerprint.Id = hex.EncodeToString(sum[:])
+
+	return fingerprint, nil
+}
+
+// readPrimaryMac returns the MAC address of the interface of the default
+// route of /proc/net/route or, without one, of the first physical interface
+// (the ones with a device in /sys/class/net) with an address. It returns an
+// empty string if there isn't any.
+func readPrimaryMac() string {
+	if file, err := getProcReader().open("net", "route"); err == nil {
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			// Iface Destination Gateway Flags RefCnt Use Metric Mask...
+			if len(fields) < 8 || fields[1] != `00000000` || fields[7] != `00000000` {
+				continue
+			}
+			if mac := readMac(fields[0]); mac != `` {
+				file.Close()
+				return mac
+			}
+		}
+		file.Close()
+	}
+
+	ifaces, _ := filepath.Glob(`/sys/class/net/*/device`)
+	sort.Strings(ifaces)
+	for _, iface := range ifaces {
+		if mac := readMac(filepath.Base(filepath.Dir(iface))); mac != `` {
+			return mac
+		}
+	}
+
+	return ``
+}
+
+// readMac returns the MAC address of an interface (empty if it doesn't have
+// one, e.g. a tunnel).
+func readMac(iface string) string {
+	mac, err := readStringFile(filepath.Join(`/sys/class/net`, iface, `address`))
+	if err != nil || mac == `` || strings.Trim(mac, `0:`) == `` {
+		return ``
+	}
+
+	return mac
+}
+
+// readDiskSerials returns the serials of the physical disks of /sys/block
+// (the virtual ones, e.g. loop or dm, don't have a device), from the serial
+// of the disk (virtio, NVMe), its wwid or its udev ID_SERIAL. The disks
+// without a serial are skipped.
+func readDiskSerials() (serials []string) {
+	serials = []string{}
+	disks, _ := filepath.Glob(`/sys/block/*/device`)
+	for _, device := range disks {
+		disk := filepath.Dir(device)
+		serial := ``
+		for _, path := range []string{filepath.Join(disk, `serial`), filepath.Join(device, `serial`), filepath.Join(device, `wwid`)} {
+			if serial, _ = readStringFile(path); serial != `` {
+				break
+			}
+		}
+		if serial == `` {
+			serial = readUdevSerial(disk)
+		}
+		if serial != `` {
+			serials = append(serials, serial)
+		}
+	}
+	sort.Strings(serials)
+
+	return serials
+}
+
+// readUdevSerial returns the ID_SERIAL of a disk in the udev database (empty
+// if it isn't there).
+func readUdevSerial(disk string) string {
+	devNum, err := readStringFile(filepath.Join(disk, `dev`))
+	if err != nil {
+		return ``
+	}
+	content, err := ioutil.ReadFile(`/run/udev/data/b` + devNum)
+	if err != nil {
+		return ``
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(line, `E:ID_SERIAL=`) {
+			return strings.TrimPrefix(line, `E:ID_SERIAL=`)
+		}
+	}
+
+	return ``
+}
+
+// Changed returns the components of the fingerprint that are different in
+// other (machineid, primarymac, diskserials). The components that are
+// empty in any of them aren't compared.
+func (fingerprint HostFingerprint) Changed(other HostFingerprint) (changed []string) {
+	changed = []string{}
+	for _, component := range fingerprint.components(other) {
+		if component.value != component.other {
+			changed = append(changed, component.name)
+		}
+	}
+
+	return changed
+}
+
+// SameHost returns true if other is the fingerprint of the same host: more
+// than half of the components known in both of them match (all of them if
+// only 1 or 2 are known). A fingerprint without components matches any
+// other.
+func (fingerprint HostFingerprint) SameHost(other HostFingerprint) bool {
+	components := fingerprint.components(other)
+	changed := len(fingerprint.Changed(other))
+	if len(components) < 3 {
+		return changed == 0
+	}
+
+	return 2*changed < len(components)
+}
+
+// fingerprintComponent is *one* component of 2 fingerprints.
+type fingerprintComponent struct {
+	name  string
+	value string
+	other string
+}
+
+// components returns the components known in both fingerprints.
+func (fingerprint HostFingerprint) components(other HostFingerprint) (components []fingerprintComponent) {
+	for _, component := range []fingerprintComponent{
+		{`machineid`, fingerprint.MachineId, other.MachineId},
+		{`primarymac`, fingerprint.PrimaryMac, other.PrimaryMac},
+		{`diskserials`, strings.Join(fingerprint.DiskSerials, `,`), strings.Join(other.DiskSerials, `,`)},
+	} {
+		if component.value != `` && component.other != `` {
+			components = append(components, component)
+		}
+	}
+
+	return components
+}
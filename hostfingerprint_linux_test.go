@@ -0,0 +1,33 @@
+// +build linux
+
+package sysstats
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHostFingerprintSameHost(t *testing.T) {
+	host := HostFingerprint{MachineId: `4c4c4544`, PrimaryMac: `52:54:00:12:34:56`, DiskSerials: []string{`S4EWNX0N`, `WD-WX11`}}
+	tests := []struct {
+		name    string
+		other   HostFingerprint
+		same    bool
+		changed []string
+	}{
+		{`same components`, host, true, []string{}},
+		{`NIC replaced`, HostFingerprint{MachineId: host.MachineId, PrimaryMac: `52:54:00:ab:cd:ef`, DiskSerials: host.DiskSerials}, true, []string{`primarymac`}},
+		{`cloned VM`, HostFingerprint{MachineId: host.MachineId, PrimaryMac: `52:54:00:ab:cd:ef`, DiskSerials: []string{`QM00001`}}, false, []string{`primarymac`, `diskserials`}},
+		{`other machine-id only`, HostFingerprint{MachineId: `9a8b7c6d`}, false, []string{`machineid`}},
+		{`same machine-id only`, HostFingerprint{MachineId: host.MachineId}, true, []string{}},
+		{`no components`, HostFingerprint{}, true, []string{}},
+	}
+	for _, test := range tests {
+		if same := host.SameHost(test.other); same != test.same {
+			t.Errorf("%s: SameHost %t, want %t", test.name, same, test.same)
+		}
+		if changed := host.Changed(test.other); !reflect.DeepEqual(changed, test.changed) {
+			t.Errorf("%s: Changed %v, want %v", test.name, changed, test.changed)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+// +build linux
+
+package sysstats
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ResourceScore represents the USE (utilization, saturation, errors) stats
+// of *one* resource of a HostScore. The utilization and the saturation are
+// percentages (0-100).
+type ResourceScore struct {
+	Resource    string  `json:"resource"`    // cpu, memory, disk or network
+	Device      string  `json:"device"`      // Disk or network interface with the highest score (empty for the cpu and the memory)
+	Utilization float64 `json:"utilization"` // % of time (or capacity) the resource was busy
+	Saturation  float64 `json:"saturation"`  // % of saturation: work the resource couldn't serve, stalled or queued
+	Errors      float64 `json:"errors"`      // # of errors per second (OOM kills for the memory, rx and tx errors for the network)
+	Score       float64 `json:"score"`       // Max of the utilization and the saturation (at least 50 if there are errors)
+}
+
+// HostScore represents a composite saturation score of a linux system in the
+// style of the USE method: the host is as saturated as its most saturated
+// resource, so the hosts of a fleet can be ranked by a single number. All
+// the stats are calculated between the same 2 snapshots.
+type HostScore struct {
+	Score      float64         `json:"score"`      // Score of the resource with the highest score (0-100)
+	Bottleneck string          `json:"bottleneck"` // Resource with the highest score
+	Interval   time.Duration   `json:"interval"`   // Time between the snapshots
+	Resources  []ResourceScore `json:"resources"`  // Stats of the cpu, the memory, the busiest disk and the busiest network interface
+}
+
+// scoreErrorsMin is the min score of a resource with errors.
+const scoreErrorsMin = 50
+
+// scoreSwapPages is the # of pages swapped per second that saturates the
+// memory when the kernel doesn't have PSI.
+const scoreSwapPages = 1000
+
+// scoreQueuedIOs is the # of I/Os queued on average (besides the one in
+// service) that saturates a disk when the kernel doesn't have PSI.
+const scoreQueuedIOs = 10
+
+// hostScoreFiles returns the extra files of the snapshots of the score that
+// are available: /proc/meminfo, /proc/vmstat and the PSI files.
+func hostScoreFiles() (files []string) {
+	for _, name := range []string{`meminfo`, `vmstat`, `pressure/cpu`, `pressure/memory`, `pressure/io`} {
+		if _, err := os.Stat(procPath(name)); err == nil {
+			files = append(files, procPath(name))
+		}
+	}
+
+	return files
+}
+
+// getHostScoreSnapshot takes a snapshot (without the loop and ram devices,
+// like getWatchSnapshot) with the extra files of the score, so the score and
+// the stats of the host are calculated from the same snapshots.
+func getHostScoreSnapshot() (snapshot Snapshot, err error) {
+	if snapshot, err = getSnapshot(hostScoreFiles()...); err != nil {
+		return Snapshot{}, err
+	}
+	snapshot.Disks = filterDiskRawStats(snapshot.Disks, DiskFilter{})
+
+	return snapshot, nil
+}
+
+// getHostScoreInterval gets the score of a linux system between 2 snapshots
+// taken 1 second apart.
+func getHostScoreInterval() (hostScore HostScore, err error) {
+	first, err := getHostScoreSnapshot()
+	if err != nil {
+		return HostScore{}, err
+	}
+	time.Sleep(time.Second)
+	second, err := getHostScoreSnapshot()
+	if err != nil {
+		return HostScore{}, err
+	}
+	avgStats, err := getSnapshotAvgStats(first, second)
+	if err != nil {
+		return HostScore{}, err
+	}
+
+	return getHostScore(first, second, avgStats), nil
+}
+
+// getHostScore calculates the score between 2 snapshots taken with
+// getHostScoreSnapshot, whose stats are avgStats. The saturation is the PSI
+// "some" stall time between the snapshots when the kernel has it. The memory
+// isn't scored if /proc/meminfo couldn't be read.
+func getHostScore(first Snapshot, second Snapshot, avgStats SnapshotAvgStats) (hostScore HostScore) {
+	hostScore = HostScore{Interval: avgStats.Interval, Resources: []ResourceScore{}}
+	seconds := avgStats.Interval.Seconds()
+
+	cpus := len(avgStats.Cpus) - 1
+	cpu := ResourceScore{Resource: `cpu`, Utilization: avgStats.Cpus[`cpu`][`total`]}
+	if stalled, ok := pressureStalled(first, second, `cpu`, seconds); ok {
+		cpu.Saturation = stalled
+	} else if cpus > 0 && second.Procs.Running > uint64(cpus) {
+		// Runnable processes beyond 1 per CPU wait for one
+		cpu.Saturation = 100 * float64(second.Procs.Running-uint64(cpus)) / float64(cpus)
+	}
+	hostScore.Resources = append(hostScore.Resources, cpu)
+
+	if memInfo, err := parseMemInfo(second.Files[procPath(`meminfo`)]); err == nil && memInfo.MemTotal > 0 {
+		memory := ResourceScore{Resource: `memory`}
+		if memInfo.RealFree <= memInfo.MemTotal {
+			memory.Utilization = 100 * float64(memInfo.MemTotal-memInfo.RealFree) / float64(memInfo.MemTotal)
+		}
+		firstSwapped, firstOomKills := snapshotVmCounters(first)
+		secondSwapped, secondOomKills := snapshotVmCounters(second)
+		if stalled, ok := pressureStalled(first, second, `memory`, seconds); ok {
+			memory.Saturation = stalled
+		} else {
+			memory.Saturation = 100 * counterRate(firstSwapped, secondSwapped, seconds) / scoreSwapPages
+		}
+		memory.Errors = counterRate(firstOomKills, secondOomKills, seconds)
+		hostScore.Resources = append(hostScore.Resources, memory)
+	}
+
+	ioStalled, ioPressure := pressureStalled(first, second, `io`, seconds)
+	disks := []ResourceScore{}
+	for _, disk := range avgStats.Disks {
+		score := ResourceScore{Resource: `disk`, Device: disk.Name, Utilization: disk.Util}
+		if ioPressure {
+			score.Saturation = ioStalled
+		} else if queued := float64(disk.TimeInQueue)/(seconds*1000) - 1; queued > 0 {
+			score.Saturation = 100 * queued / scoreQueuedIOs
+		}
+		disks = append(disks, score)
+	}
+	if disk, ok := maxResourceScore(disks); ok {
+		hostScore.Resources = append(hostScore.Resources, disk)
+	}
+
+	ifaces := []ResourceScore{}
+	for iface, stats := range avgStats.Net {
+		if iface == `lo` {
+			continue
+		}
+		score := ResourceScore{Resource: `network`, Device: iface, Errors: stats[`rxerrs`] + stats[`txerrs`]}
+		if speed, ok := readLinkSpeed(filepath.Join("/sys/class/net", iface, "speed")); ok {
+			// The speed is in Mbit/s and the rates in bytes/s
+			capacity := float64(speed) * 1e6 / 8
+			score.Utilization = 100 * stats[`rxbytes`] / capacity
+			if txUtil := 100 * stats[`txbytes`] / capacity; txUtil > score.Utilization {
+				score.Utilization = txUtil
+			}
+		}
+		// The packets dropped are the ones the interface (or the kernel)
+		// couldn't take
+		if pkts := stats[`rxpkts`] + stats[`txpkts`] + stats[`rxdrop`] + stats[`txdrop`]; pkts > 0 {
+			score.Saturation = 100 * (stats[`rxdrop`] + stats[`txdrop`]) / pkts
+		}
+		ifaces = append(ifaces, score)
+	}
+	if iface, ok := maxResourceScore(ifaces); ok {
+		hostScore.Resources = append(hostScore.Resources, iface)
+	}
+
+	for i := range hostScore.Resources {
+		resource := &hostScore.Resources[i]
+		resource.Utilization = clampPer(resource.Utilization)
+		resource.Saturation = clampPer(resource.Saturation)
+		resource.Score = resourceScore(*resource)
+		if i == 0 || resource.Score > hostScore.Score {
+			hostScore.Score, hostScore.Bottleneck = resource.Score, resource.Resource
+		}
+	}
+
+	return hostScore
+}
+
+// snapshotVmCounters returns the # of pages swapped (in and out) and the #
+// of OOM kills of the /proc/vmstat of a snapshot (0 if it doesn't have it).
+func snapshotVmCounters(snapshot Snapshot) (swapped uint64, oomKills uint64) {
+	var pswpIn, pswpOut uint64
+	parseVmCounters(snapshot.Files[procPath(`vmstat`)], map[string]*uint64{
+		`pswpin`:   &pswpIn,
+		`pswpout`:  &pswpOut,
+		`oom_kill`: &oomKills,
+	})
+
+	return pswpIn + pswpOut, oomKills
+}
+
+// pressureStalled returns the % of time some tasks were stalled on a
+// resource (cpu, memory or io) between 2 snapshots, from the PSI totals. ok
+// is false if the snapshots don't have the PSI file of the resource.
+func pressureStalled(first Snapshot, second Snapshot, resource string, seconds float64) (stalled float64, ok bool) {
+	path := procPath(`pressure`, resource)
+	firstContent, firstOk := first.Files[path]
+	secondContent, secondOk := second.Files[path]
+	if !firstOk || !secondOk || seconds <= 0 {
+		return 0, false
+	}
+	firstPressure, err := parsePressure(string(firstContent))
+	if err != nil {
+		return 0, false
+	}
+	secondPressure, err := parsePressure(string(secondContent))
+	if err != nil {
+		return 0, false
+	}
+
+	// The totals are in microseconds
+	return 100 * counterRate(firstPressure.Some.Total, secondPressure.Some.Total, seconds) / 1e6, true
+}
+
+// resourceScore returns the score of a resource: the max of its utilization
+// and its saturation, at least scoreErrorsMin if it has errors.
+func resourceScore(resource ResourceScore) float64 {
+	score := clampPer(resource.Utilization)
+	if saturation := clampPer(resource.Saturation); saturation > score {
+		score = saturation
+	}
+	if resource.Errors > 0 && score < scoreErrorsMin {
+		score = scoreErrorsMin
+	}
+
+	return score
+}
+
+// maxResourceScore returns the device with the highest score (the first one
+// by name if several have it). ok is false if there isn't any device.
+func maxResourceScore(devices []ResourceScore) (max ResourceScore, ok bool) {
+	if len(devices) == 0 {
+		return ResourceScore{}, false
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		iScore, jScore := resourceScore(devices[i]), resourceScore(devices[j])
+		if iScore != jScore {
+			return iScore > jScore
+		}
+		return devices[i].Device < devices[j].Device
+	})
+
+	return devices[0], true
+}
+
+// clampPer returns a percentage within [0, 100].
+func clampPer(per float64) float64 {
+	if per < 0 {
+		return 0
+	}
+	if per > 100 {
+		return 100
+	}
+
+	return per
+}
+
+// Metrics returns the score as the metric host.score and the breakdown as
+// host.resource.score, host.resource.utilization, host.resource.saturation
+// and host.resource.errors, labeled with the resource and the device.
+func (hostScore HostScore) Metrics() (metrics []Metric) {
+	metrics = make([]Metric, 0, 1+4*len(hostScore.Resources))
+	metrics = append(metrics, Metric{Name: `host.score`, Labels: map[string]string{}, Value: hostScore.Score})
+	for _, resource := range hostScore.Resources {
+		labels := map[string]string{`resource`: resource.Resource, `device`: resource.Device}
+		metrics = append(metrics,
+			Metric{Name: `host.resource.score`, Labels: labels, Value: resource.Score},
+			Metric{Name: `host.resource.utilization`, Labels: labels, Value: resource.Utilization},
+			Metric{Name: `host.resource.saturation`, Labels: labels, Value: resource.Saturation},
+			Metric{Name: `host.resource.errors`, Labels: labels, Value: resource.Errors},
+		)
+	}
+
+	return metrics
+}
@@ -0,0 +1,97 @@
+// +build linux
+
+package sysstats
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// InfinibandPortStats represents the statistics of *one* InfiniBand/RDMA port.
+//
+// Counters map keys are the names of the files in
+// /sys/class/infiniband/<device>/ports/<port>/counters, e.g.:
+//   port_xmit_data       - # of data octets transmitted divided by 4.
+//   port_rcv_data        - # of data octets received divided by 4.
+//   port_xmit_packets    - # of packets transmitted.
+//   port_rcv_packets     - # of packets received.
+//   port_rcv_errors      - # of packets received with errors.
+//   symbol_error         - # of minor link errors.
+//   link_downed          - # of times the link failed to recover and went down.
+//   link_error_recovery  - # of times the link successfully recovered.
+//   port_xmit_discards   - # of outbound packets discarded.
+type InfinibandPortStats struct {
+	Device    string            `json:"device"`    // Name of the HCA (mlx5_0...)
+	Port      int               `json:"port"`      // Port number
+	State     string            `json:"state"`     // Logical port state (e.g. ACTIVE)
+	PhysState string            `json:"physstate"` // Physical port state (e.g. LinkUp)
+	Rate      string            `json:"rate"`      // Link rate (e.g. 100 Gb/sec (4X EDR))
+	XmitBytes uint64            `json:"xmitbytes"` // # of data bytes transmitted (port_xmit_data * 4)
+	RcvBytes  uint64            `json:"rcvbytes"`  // # of data bytes received (port_rcv_data * 4)
+	Counters  map[string]uint64 `json:"counters"`  // Raw port counters
+}
+
+// getInfinibandStats gets the port counters of the InfiniBand/RDMA devices of
+// a linux system from /sys/class/infiniband/*/ports/*.
+func getInfinibandStats() (portStatsArr []InfinibandPortStats, err error) {
+	portDirs, err := filepath.Glob("/sys/class/infiniband/*/ports/*")
+	if err != nil {
+		return nil, err
+	}
+
+	portStatsArr = make([]InfinibandPortStats, 0, len(portDirs))
+	for _, portDir := range portDirs {
+		portStats := InfinibandPortStats{
+			Device:   filepath.Base(filepath.Dir(filepath.Dir(portDir))),
+			Counters: map[string]uint64{},
+		}
+		if portStats.Port, err = strconv.Atoi(filepath.Base(portDir)); err != nil {
+			return nil, err
+		}
+		// state has the format "4: ACTIVE" and phys_state "5: LinkUp"
+		if portStats.State, err = readIbStateFile(filepath.Join(portDir, "state")); err != nil {
+			return nil, err
+		}
+		if portStats.PhysState, err = readIbStateFile(filepath.Join(portDir, "phys_state")); err != nil {
+			return nil, err
+		}
+		if portStats.Rate, err = readStringFile(filepath.Join(portDir, "rate")); err != nil {
+			return nil, err
+		}
+
+		counterFiles, err := ioutil.ReadDir(filepath.Join(portDir, "counters"))
+		if err != nil {
+			return nil, err
+		}
+		for _, counterFile := range counterFiles {
+			value, err := readUintFile(filepath.Join(portDir, "counters", counterFile.Name()))
+			if err != nil {
+				// Some counters aren't readable (or implemented) by every driver
+				continue
+			}
+			portStats.Counters[counterFile.Name()] = value
+		}
+		portStats.XmitBytes = portStats.Counters[`port_xmit_data`] * 4
+		portStats.RcvBytes = portStats.Counters[`port_rcv_data`] * 4
+
+		portStatsArr = append(portStatsArr, portStats)
+	}
+
+	return portStatsArr, nil
+}
+
+// readIbStateFile reads an InfiniBand port state file ("4: ACTIVE") and
+// returns the name of the state.
+func readIbStateFile(path string) (state string, err error) {
+	content, err := readStringFile(path)
+	if err != nil {
+		return "", err
+	}
+	if i := strings.Index(content, `:`); i >= 0 {
+		return strings.TrimSpace(content[i+1:]), nil
+	}
+
+	return content, nil
+}
@@ -0,0 +1,183 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// IpmiSensor represents *one* sensor of the BMC (baseboard management
+// controller) of a system.
+//
+// Thresholds map keys are the thresholds of the sensor the BMC reports: lnr,
+// lcr, lnc (lower non-recoverable, critical and non-critical) and unc, ucr,
+// unr (upper non-critical, critical and non-recoverable).
+type IpmiSensor struct {
+	Name       string             `json:"name"`       // Name of the sensor (CPU Temp, FAN1, PS1 Status...)
+	Type       string             `json:"type"`       // Type of the sensor (temperature, fan, voltage, current, power or discrete)
+	Value      float64            `json:"value"`      // Reading of the sensor (the state bits for discrete sensors)
+	Unit       string             `json:"unit"`       // Unit of the reading (degrees C, RPM, Volts, Watts...)
+	Status     string             `json:"status"`     // Status of the sensor (ok, nc, cr, nr...)
+	Thresholds map[string]float64 `json:"thresholds"` // Thresholds of the sensor
+}
+
+// IpmiStats represents the sensors of the BMC of a system.
+type IpmiStats struct {
+	Sensors   []IpmiSensor `json:"sensors"`   // Sensors with a reading
+	PowerDraw float64      `json:"powerdraw"` // Instantaneous power draw (Watts) reported by DCMI. 0 if the BMC doesn't support it
+}
+
+// ipmiDevices are the device files of the OpenIPMI driver.
+var ipmiDevices = []string{`/dev/ipmi0`, `/dev/ipmi/0`, `/dev/ipmidev/0`}
+
+// ipmiThresholds are the thresholds of the columns of `ipmitool sensor`,
+// starting at the 5th column.
+var ipmiThresholds = []string{`lnr`, `lcr`, `lnc`, `unc`, `ucr`, `unr`}
+
+// ipmiSensorTypes are the types of the sensors by the unit of their readings.
+var ipmiSensorTypes = map[string]string{
+	`degrees C`: `temperature`,
+	`degrees F`: `temperature`,
+	`RPM`:       `fan`,
+	`Volts`:     `voltage`,
+	`Amps`:      `current`,
+	`Watts`:     `power`,
+	`discrete`:  `discrete`,
+}
+
+// getIpmiStats gets the sensors of the BMC of a linux system through the
+// OpenIPMI driver (it needs the ipmi_devintf module) running the command:
+//   ipmitool sensor
+// The power draw is got running (skipped if the BMC doesn't support DCMI):
+//   ipmitool dcmi power reading
+// It returns an error if the system doesn't have an IPMI device.
+func getIpmiStats() (ipmiStats IpmiStats, err error) {
+	device := false
+	for _, path := range ipmiDevices {
+		if fileExists(path) {
+			device = true
+			break
+		}
+	}
+	if !device {
+		return IpmiStats{}, errors.New("The system doesn't have an IPMI device (is ipmi_devintf loaded?)")
+	}
+
+	ipmitool, err := exec.LookPath("ipmitool")
+	if err != nil {
+		return IpmiStats{}, err
+	}
+
+	out, err := exec.Command(ipmitool, "sensor").Output()
+	if err != nil {
+		return IpmiStats{}, err
+	}
+
+	ipmiStats.Sensors = make([]IpmiSensor, 0, 32)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		sensor, ok, err := parseIpmiSensor(scanner.Text())
+		if err != nil {
+			return IpmiStats{}, err
+		}
+		if ok {
+			ipmiStats.Sensors = append(ipmiStats.Sensors, sensor)
+		}
+	}
+
+	if out, err := exec.Command(ipmitool, "dcmi", "power", "reading").Output(); err == nil {
+		ipmiStats.PowerDraw = parseIpmiPowerReading(string(out))
+	}
+
+	return ipmiStats, nil
+}
+
+// parseIpmiSensor parses *one* line of `ipmitool sensor`, that has the
+// following format:
+//   CPU Temp         | 45.000     | degrees C  | ok    | 0.000     | 0.000     | 0.000     | 95.000    | 100.000   | 100.000
+//   FAN1             | 3200.000   | RPM        | ok    | 300.000   | 500.000   | 700.000   | 25300.000 | 25400.000 | 25500.000
+//   PS1 Status       | 0x1        | discrete   | 0x0100| na        | na        | na        | na        | na        | na
+// The thresholds not reported are na. It returns false if the sensor doesn't
+// have a reading (na), e.g. the sensors of the empty sockets.
+func parseIpmiSensor(line string) (sensor IpmiSensor, ok bool, err error) {
+	fields := strings.Split(line, `|`)
+	if len(fields) < 4 {
+		return IpmiSensor{}, false, errors.New("Error parsing the output of ipmitool sensor. Unexpected line: " + line)
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+
+	if fields[1] == `na` || fields[1] == `` {
+		return IpmiSensor{}, false, nil
+	}
+
+	sensor = IpmiSensor{
+		Name:       fields[0],
+		Unit:       fields[2],
+		Status:     fields[3],
+		Thresholds: map[string]float64{},
+	}
+	sensor.Type = ipmiSensorTypes[sensor.Unit]
+	if sensor.Type == `` {
+		sensor.Type = `other`
+	}
+
+	if strings.HasPrefix(fields[1], `0x`) {
+		value, err := strconv.ParseUint(strings.TrimPrefix(fields[1], `0x`), 16, 64)
+		if err != nil {
+			return IpmiSensor{}, false, err
+		}
+		sensor.Value = float64(value)
+	} else if sensor.Value, err = strconv.ParseFloat(fields[1], 64); err != nil {
+		return IpmiSensor{}, false, err
+	}
+
+	for i, threshold := range ipmiThresholds {
+		if 4+i >= len(fields) {
+			break
+		}
+		value, err := strconv.ParseFloat(fields[4+i], 64)
+		if err != nil {
+			// na
+			continue
+		}
+		sensor.Thresholds[threshold] = value
+	}
+
+	return sensor, true, nil
+}
+
+// parseIpmiPowerReading parses the instantaneous power draw of the output of
+// `ipmitool dcmi power reading`:
+//   Instantaneous power reading:                   220 Watts
+//   Minimum during sampling period:                 38 Watts
+//   ...
+// It returns 0 if there isn't an instantaneous reading.
+func parseIpmiPowerReading(out string) float64 {
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, `Instantaneous power reading:`) {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, `Instantaneous power reading:`))
+		if len(fields) == 0 {
+			return 0
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return 0
+		}
+		return value
+	}
+
+	return 0
+}
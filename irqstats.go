@@ -0,0 +1,122 @@
+package sysstats
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// IrqRawStats represents the interrupt and softirq counters of the intr and
+// softirq lines of /proc/stat (counted since boot). The # of IRQs of the
+// intr line depends on the architecture and the kernel config (NR_IRQS), so
+// only the IRQs that fired at least once are kept.
+type IrqRawStats struct {
+	Total        uint64            `json:"total"`        // Interrupts serviced
+	Other        uint64            `json:"other"`        // Interrupts not counted per IRQ (the architecture specific ones, e.g. the local timer and the IPIs on x86)
+	NumIrqs      int               `json:"numirqs"`      // # of IRQs of the intr line
+	Irqs         map[string]uint64 `json:"irqs"`         // Interrupts serviced of each IRQ (by number) that fired at least once
+	SoftirqTotal uint64            `json:"softirqtotal"` // Softirqs serviced (2.6.31+)
+	Softirqs     map[string]uint64 `json:"softirqs"`     // Softirqs serviced of each type (hi, timer, net_tx, net_rx, block, irq_poll, tasklet, sched, hrtimer, rcu)
+	Time         int64             `json:"time"`         // Time when the sample was taken (Unix time)
+}
+
+// IrqAvgStats represents the interrupts and softirqs per second between 2
+// IrqRawStats samples.
+type IrqAvgStats struct {
+	Total        float64            `json:"total"`        // Interrupts per second
+	Other        float64            `json:"other"`        // Interrupts not counted per IRQ per second
+	Irqs         map[string]float64 `json:"irqs"`         // Interrupts per second of each IRQ that fired between the samples
+	SoftirqTotal float64            `json:"softirqtotal"` // Softirqs per second
+	Softirqs     map[string]float64 `json:"softirqs"`     // Softirqs per second of each type
+}
+
+// softirqTypes are the types of the fields of the softirq line of /proc/stat
+// after the total, in order. The order is the same on every architecture
+// (the kernel keeps the unused ones, like hrtimer between 4.2 and 4.16, so
+// the numbering doesn't change). block_iopoll was renamed to irq_poll in 4.5.
+var softirqTypes = [...]string{`hi`, `timer`, `net_tx`, `net_rx`, `block`, `irq_poll`, `tasklet`, `sched`, `hrtimer`, `rcu`}
+
+// readIrqRawStats reads the interrupt and softirq counters from content,
+// that has the content of the file /proc/stat:
+//   intr 1244742074 37 9 0 0 ...
+//   softirq 318870486 2 110465544 28 2266330 ...
+// The first field of each line is the total and the next ones are the
+// counts of each IRQ (from 0) and of each softirq type. The softirq types
+// unknown to this version (a newer kernel) are named softirq<index>.
+func readIrqRawStats(content []byte, now int64) (irqRawStats IrqRawStats, err error) {
+	irqRawStats = IrqRawStats{Irqs: map[string]uint64{}, Softirqs: map[string]uint64{}, Time: now}
+
+	for len(content) > 0 {
+		var line []byte
+		line, content = nextLine(content)
+		name, rest := nextField(line)
+		intr := bytes.Equal(name, []byte(`intr`))
+		if !intr && !bytes.Equal(name, []byte(`softirq`)) {
+			continue
+		}
+
+		var total, sum uint64
+		i := -1
+		for field, rest := nextField(rest); len(field) > 0; field, rest = nextField(rest) {
+			value, ok := parseUintBytes(field)
+			if !ok {
+				_, err := strconv.ParseUint(string(field), 10, 64)
+				return IrqRawStats{}, err
+			}
+			switch {
+			case i < 0:
+				total = value
+			case intr:
+				irqRawStats.NumIrqs++
+				sum += value
+				if value > 0 {
+					irqRawStats.Irqs[strconv.Itoa(i)] = value
+				}
+			case i < len(softirqTypes):
+				irqRawStats.Softirqs[softirqTypes[i]] = value
+			default:
+				irqRawStats.Softirqs[`softirq`+strconv.Itoa(i)] = value
+			}
+			i++
+		}
+
+		if intr {
+			irqRawStats.Total = total
+			if sum <= total {
+				irqRawStats.Other = total - sum
+			}
+		} else {
+			irqRawStats.SoftirqTotal = total
+		}
+	}
+
+	return irqRawStats, nil
+}
+
+// getIrqAvgStats calculates the interrupts and softirqs per second between
+// 2 IrqRawStats samples.
+func getIrqAvgStats(firstSample IrqRawStats, secondSample IrqRawStats) (irqAvgStats IrqAvgStats, err error) {
+	return getIrqAvgStatsOver(firstSample, secondSample, float64(secondSample.Time-firstSample.Time))
+}
+
+// getIrqAvgStatsOver calculates the interrupts and softirqs per second
+// between 2 IrqRawStats samples taken seconds apart. The IRQs that didn't
+// fire in the first sample had a count of 0.
+func getIrqAvgStatsOver(firstSample IrqRawStats, secondSample IrqRawStats, seconds float64) (irqAvgStats IrqAvgStats, err error) {
+	irqAvgStats = IrqAvgStats{
+		Total:        counterRate(firstSample.Total, secondSample.Total, seconds),
+		Other:        counterRate(firstSample.Other, secondSample.Other, seconds),
+		Irqs:         map[string]float64{},
+		SoftirqTotal: counterRate(firstSample.SoftirqTotal, secondSample.SoftirqTotal, seconds),
+		Softirqs:     make(map[string]float64, len(secondSample.Softirqs)),
+	}
+	for irq, count := range secondSample.Irqs {
+		if rate := counterRate(firstSample.Irqs[irq], count, seconds); rate > 0 {
+			irqAvgStats.Irqs[irq] = rate
+		}
+	}
+	for softirq, count := range secondSample.Softirqs {
+		irqAvgStats.Softirqs[softirq] = counterRate(firstSample.Softirqs[softirq], count, seconds)
+	}
+
+	return irqAvgStats, nil
+}
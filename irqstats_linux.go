@@ -0,0 +1,38 @@
+// +build linux
+
+package sysstats
+
+import "time"
+
+// getIrqRawStats gets the interrupt and softirq counters of a linux system
+// from the file /proc/stat.
+func getIrqRawStats() (irqRawStats IrqRawStats, err error) {
+	now := clockNow().Unix()
+	err = parseProcFile(func(content []byte) error {
+		irqRawStats, err = readIrqRawStats(content, now)
+		return err
+	}, "stat")
+	if err != nil {
+		return IrqRawStats{}, err
+	}
+
+	return irqRawStats, nil
+}
+
+// getIrqStatsInterval returns the interrupts and softirqs per second between
+// 2 samples. Time interval between the 2 samples is given in seconds.
+func getIrqStatsInterval(interval int64) (irqAvgStats IrqAvgStats, err error) {
+	firstSample, err := getIrqRawStats()
+	if err != nil {
+		return IrqAvgStats{}, err
+	}
+
+	time.Sleep(time.Duration(interval) * time.Second)
+
+	secondSample, err := getIrqRawStats()
+	if err != nil {
+		return IrqAvgStats{}, err
+	}
+
+	return getIrqAvgStats(firstSample, secondSample)
+}
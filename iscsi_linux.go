@@ -0,0 +1,130 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// IscsiSession represents the state and statistics of *one* iSCSI session.
+//
+// Counters map keys are the session statistics reported by iscsiadm, e.g.:
+//   txdata_octets - # of data octets transmitted.
+//   rxdata_octets - # of data octets received.
+//   digest_err    - # of header/data digest errors.
+//   timeout_err   - # of timeouts.
+//   scsicmd_pdus  - # of SCSI command PDUs sent.
+type IscsiSession struct {
+	Session     string            `json:"session"`     // Session name (session1...)
+	Sid         int               `json:"sid"`         // Session id
+	TargetName  string            `json:"targetname"`  // IQN of the target
+	State       string            `json:"state"`       // Session state (LOGGED_IN, FAILED, FREE)
+	Address     string            `json:"address"`     // Portal address of the session connection
+	Port        string            `json:"port"`        // Portal port of the session connection
+	RecoveryTmo uint64            `json:"recoverytmo"` // Replacement/recovery timeout in seconds
+	Counters    map[string]uint64 `json:"counters"`    // Session statistics (only if `iscsiadm` is available)
+}
+
+// getIscsiSessions gets the iSCSI sessions of a linux system from
+// /sys/class/iscsi_session. The error counters are got running the command
+// (skipped if `iscsiadm` isn't installed):
+//   iscsiadm -m session -s
+func getIscsiSessions() (sessions []IscsiSession, err error) {
+	sessionDirs, err := filepath.Glob("/sys/class/iscsi_session/session*")
+	if err != nil {
+		return nil, err
+	}
+	if len(sessionDirs) == 0 {
+		return []IscsiSession{}, nil
+	}
+
+	counters, err := getIscsiCounters()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions = make([]IscsiSession, 0, len(sessionDirs))
+	for _, sessionDir := range sessionDirs {
+		session := IscsiSession{Session: filepath.Base(sessionDir)}
+		if session.Sid, err = strconv.Atoi(strings.TrimPrefix(session.Session, `session`)); err != nil {
+			return nil, err
+		}
+		if session.TargetName, err = readStringFile(filepath.Join(sessionDir, "targetname")); err != nil {
+			return nil, err
+		}
+		if session.State, err = readStringFile(filepath.Join(sessionDir, "state")); err != nil {
+			return nil, err
+		}
+		session.RecoveryTmo, _ = readUintFile(filepath.Join(sessionDir, "recovery_tmo"))
+
+		connDirs, _ := filepath.Glob(filepath.Join(sessionDir, "device", "connection*", "iscsi_connection", "connection*"))
+		if len(connDirs) > 0 {
+			session.Address, _ = readStringFile(filepath.Join(connDirs[0], "persistent_address"))
+			session.Port, _ = readStringFile(filepath.Join(connDirs[0], "persistent_port"))
+		}
+
+		session.Counters = counters[session.Sid]
+		if session.Counters == nil {
+			session.Counters = map[string]uint64{}
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+var (
+	reIscsiSid  = regexp.MustCompile(`^Stats for session \[sid:\s*(\d+)`)
+	reIscsiStat = regexp.MustCompile(`^\s+(\w+):\s*(\d+)\s*$`)
+)
+
+// getIscsiCounters runs `iscsiadm -m session -s` and parses its output:
+//   Stats for session [sid: 1, target: iqn.2003-01.org.example:disk1, portal: 10.0.0.1,3260]
+//   iSCSI SNMP:
+//   	txdata_octets: 40960
+//   	rxdata_octets: 1024
+//   	...
+//   	digest_err: 0
+//   	timeout_err: 0
+// It returns the counters indexed by sid. The map is empty if `iscsiadm`
+// isn't installed.
+func getIscsiCounters() (counters map[int]map[string]uint64, err error) {
+	counters = map[int]map[string]uint64{}
+
+	iscsiadm, err := exec.LookPath("iscsiadm")
+	if err != nil {
+		return counters, nil
+	}
+
+	out, err := exec.Command(iscsiadm, "-m", "session", "-s").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	sid := -1
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if stat := reIscsiSid.FindStringSubmatch(line); stat != nil {
+			if sid, err = strconv.Atoi(stat[1]); err != nil {
+				return nil, err
+			}
+			counters[sid] = map[string]uint64{}
+		} else if stat := reIscsiStat.FindStringSubmatch(line); stat != nil && sid >= 0 {
+			value, err := strconv.ParseUint(stat[2], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			counters[sid][stat[1]] = value
+		}
+	}
+
+	return counters, nil
+}
@@ -0,0 +1,90 @@
+// +build linux
+
+package sysstats
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// KmsgRecord represents *one* record of the kernel log buffer.
+type KmsgRecord struct {
+	Priority  int     `json:"priority"`  // Syslog priority (facility * 8 + level)
+	Sequence  uint64  `json:"sequence"`  // Sequence number of the record
+	Timestamp float64 `json:"timestamp"` // Seconds since boot
+	Message   string  `json:"message"`   // Message
+}
+
+// readKmsg reads the records currently in the kernel log buffer from
+// /dev/kmsg without blocking. It needs CAP_SYSLOG if kernel.dmesg_restrict
+// is set.
+func readKmsg() (records []KmsgRecord, err error) {
+	fd, err := syscall.Open("/dev/kmsg", syscall.O_RDONLY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: "/dev/kmsg", Err: err}
+	}
+	defer syscall.Close(fd)
+
+	records = make([]KmsgRecord, 0, 1024)
+	// Every read returns one record and fails if the buffer is too small
+	buf := make([]byte, 8192)
+	for {
+		n, err := syscall.Read(fd, buf)
+		if err == syscall.EAGAIN {
+			break
+		}
+		if err == syscall.EPIPE {
+			// The record was overwritten while reading: go on with the next one
+			continue
+		}
+		if err != nil {
+			return nil, &os.PathError{Op: "read", Path: "/dev/kmsg", Err: err}
+		}
+		if n <= 0 {
+			break
+		}
+		record, err := parseKmsgRecord(string(buf[:n]))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// parseKmsgRecord parses *one* record of /dev/kmsg, that has the following
+// format (the continuation lines with the device info are ignored):
+//   6,339,5140900,-;NET: Registered protocol family 10
+//    SUBSYSTEM=net
+func parseKmsgRecord(line string) (record KmsgRecord, err error) {
+	sep := strings.IndexByte(line, ';')
+	if sep < 0 {
+		return KmsgRecord{}, errors.New("Couldn't parse kmsg record: " + line)
+	}
+	fields := strings.Split(line[:sep], `,`)
+	if len(fields) < 3 {
+		return KmsgRecord{}, errors.New("Couldn't parse kmsg record: " + line)
+	}
+	if record.Priority, err = strconv.Atoi(fields[0]); err != nil {
+		return KmsgRecord{}, err
+	}
+	if record.Sequence, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+		return KmsgRecord{}, err
+	}
+	usec, err := strconv.ParseUint(fields[2], 10, 64)
+	if err != nil {
+		return KmsgRecord{}, err
+	}
+	record.Timestamp = float64(usec) / 1e6
+
+	record.Message = line[sep+1:]
+	if nl := strings.IndexByte(record.Message, '\n'); nl >= 0 {
+		record.Message = record.Message[:nl]
+	}
+
+	return record, nil
+}
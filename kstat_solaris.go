@@ -0,0 +1,91 @@
+// +build solaris
+
+package sysstats
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// kstat represents the statistics of *one* kstat (module:instance:name) of
+// an illumos/Solaris system.
+type kstat struct {
+	Module   string
+	Instance int
+	Name     string
+	Stats    map[string]string
+}
+
+// getKstats gets the kstats that match the selectors (module:instance:name)
+// running the command:
+//   kstat -p <selectors...>
+func getKstats(selectors ...string) (kstats []kstat, err error) {
+	out, err := exec.Command(`kstat`, append([]string{`-p`}, selectors...)...).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseKstats(out)
+}
+
+// parseKstats parses the output of `kstat -p`, that has *one* statistic per
+// line with the following format:
+//   cpu:0:sys:cpu_ticks_user	1234
+// The kstats are returned in the order of the output.
+func parseKstats(out []byte) (kstats []kstat, err error) {
+	kstats = make([]kstat, 0, 16)
+	index := map[string]int{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == `` {
+			continue
+		}
+		fields := strings.SplitN(line, "\t", 2)
+		if len(fields) != 2 {
+			return nil, errors.New("Error parsing kstat output. Unexpected line: " + line)
+		}
+		// The name of the statistic is after the last colon (the kstat
+		// names may have colons)
+		colon := strings.LastIndex(fields[0], `:`)
+		if colon < 0 {
+			return nil, errors.New("Error parsing kstat output. Unexpected line: " + line)
+		}
+		key := fields[0][:colon]
+		id := strings.SplitN(key, `:`, 3)
+		if len(id) != 3 {
+			return nil, errors.New("Error parsing kstat output. Unexpected line: " + line)
+		}
+
+		i, ok := index[key]
+		if !ok {
+			instance, err := strconv.Atoi(id[1])
+			if err != nil {
+				return nil, err
+			}
+			kstats = append(kstats, kstat{Module: id[0], Instance: instance, Name: id[2], Stats: map[string]string{}})
+			i = len(kstats) - 1
+			index[key] = i
+		}
+		kstats[i].Stats[fields[0][colon+1:]] = strings.TrimSpace(fields[1])
+	}
+
+	return kstats, nil
+}
+
+// uint returns the value of a numeric statistic of the kstat. It returns 0 if
+// the statistic doesn't exist (older releases don't have all of them).
+func (k kstat) uint(stat string) (value uint64, err error) {
+	s, ok := k.Stats[stat]
+	if !ok {
+		return 0, nil
+	}
+
+	return strconv.ParseUint(s, 10, 64)
+}
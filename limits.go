@@ -0,0 +1,60 @@
+package sysstats
+
+import (
+	"sync/atomic"
+)
+
+// Limits represents the max # of entries the collectors read from the files
+// (and directories) that grow with the load of the system, so a fork bomb or
+// a SYN flood can't make the collectors allocate unbounded memory. The
+// entries after the limit are skipped and the truncation is counted (see
+// GetTruncations). A limit <= 0 means no limit.
+type Limits struct {
+	MaxProcesses   int // Max # of processes read from the procfs
+	MaxConnections int // Max # of TCP connections read per address family (/proc/net/tcp, /proc/net/tcp6 and sock_diag)
+	MaxMounts      int // Max # of mounts read from /proc/self/mountinfo
+}
+
+// DefaultLimits are the limits of the collectors by default: well above
+// the entries of a busy system (pid_max is 32768 by default on 32-bit
+// systems, many 64-bit distributions raise it to 4194304).
+var DefaultLimits = Limits{MaxProcesses: 100000, MaxConnections: 250000, MaxMounts: 10000}
+
+// Truncations represents the # of times the collectors stopped reading at
+// a limit since the process started.
+type Truncations struct {
+	Processes   uint64 `json:"processes"`   // # of times the processes were truncated
+	Connections uint64 `json:"connections"` // # of times the TCP connections were truncated
+	Mounts      uint64 `json:"mounts"`      // # of times the mounts were truncated
+}
+
+// limits are the limits of the collectors.
+var limits = DefaultLimits
+
+// truncations are the truncations of the collectors, updated atomically.
+var truncations Truncations
+
+// setLimits sets the limits of the collectors.
+func setLimits(l Limits) {
+	limits = l
+}
+
+// getTruncations returns the truncations of the collectors.
+func getTruncations() Truncations {
+	return Truncations{
+		Processes:   atomic.LoadUint64(&truncations.Processes),
+		Connections: atomic.LoadUint64(&truncations.Connections),
+		Mounts:      atomic.LoadUint64(&truncations.Mounts),
+	}
+}
+
+// overLimit returns true (and counts the truncation in counter) if n entries
+// have already been read and limit is set and reached.
+func overLimit(n int, limit int, counter *uint64) bool {
+	if limit <= 0 || n < limit {
+		return false
+	}
+	atomic.AddUint64(counter, 1)
+
+	return true
+}
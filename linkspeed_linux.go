@@ -0,0 +1,125 @@
+// +build linux
+
+package sysstats
+
+import (
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LinkMonitorConfig represents the configuration of a link capacity
+// monitor.
+type LinkMonitorConfig struct {
+	MaxUtilization float64 // An interface is saturated when it's over MaxUtilization % of its speed in either direction (default 90)
+}
+
+// LinkStats represents the throughput of *one* network interface relative
+// to its negotiated speed.
+type LinkStats struct {
+	Name       string  `json:"name"`       // Name of the interface
+	OperState  string  `json:"operstate"`  // Operational state (up, down, dormant...)
+	Duplex     string  `json:"duplex"`     // Duplex (full, half or unknown)
+	Speed      uint64  `json:"speed"`      // Negotiated speed (Mbit/s)
+	MaxSpeed   uint64  `json:"maxspeed"`   // Highest speed negotiated since the monitor started (Mbit/s)
+	RxBytes    float64 `json:"rxbytes"`    // Bytes received per second since the previous check
+	TxBytes    float64 `json:"txbytes"`    // Bytes transmitted per second since the previous check
+	RxUtil     float64 `json:"rxutil"`     // % of the speed used receiving
+	TxUtil     float64 `json:"txutil"`     // % of the speed used transmitting
+	Saturated  bool    `json:"saturated"`  // Whether the interface is over MaxUtilization in either direction
+	Downgraded bool    `json:"downgraded"` // Whether the interface renegotiated to a lower speed than MaxSpeed (e.g. 1G instead of 10G)
+	HalfDuplex bool    `json:"halfduplex"` // Whether the interface negotiated half duplex
+}
+
+// LinkMonitor measures the throughput of the network interfaces with a
+// negotiated speed (the physical ones, and the virtual ones that report it)
+// and flags the ones running near their capacity or that renegotiated to a
+// lower speed, which otherwise only shows up as a slow network. The rates
+// are calculated between calls to Check.
+type LinkMonitor struct {
+	config    LinkMonitorConfig
+	mu        sync.Mutex
+	time      time.Time
+	last      NetRawStats
+	maxSpeeds map[string]uint64
+}
+
+// NewLinkMonitor returns a LinkMonitor for the given configuration.
+func NewLinkMonitor(config LinkMonitorConfig) *LinkMonitor {
+	if config.MaxUtilization <= 0 {
+		config.MaxUtilization = 90
+	}
+
+	return &LinkMonitor{config: config, maxSpeeds: map[string]uint64{}}
+}
+
+// Check returns the throughput of the interfaces with a negotiated speed
+// (read from /sys/class/net/<iface>/speed), sorted by name. The first call
+// doesn't have rates. The interfaces whose link is down don't report a
+// speed and are skipped.
+func (m *LinkMonitor) Check() (linkStatsArr []LinkStats, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	netRawStats, err := getNetRawStats()
+	if err != nil {
+		return nil, err
+	}
+	seconds := now.Sub(m.time).Seconds()
+
+	linkStatsArr = make([]LinkStats, 0, len(netRawStats))
+	for name, ifaceRawStats := range netRawStats {
+		dir := filepath.Join("/sys/class/net", name)
+		speed, ok := readLinkSpeed(filepath.Join(dir, "speed"))
+		if !ok {
+			continue
+		}
+		linkStats := LinkStats{Name: name, Speed: speed}
+		linkStats.OperState, _ = readStringFile(filepath.Join(dir, "operstate"))
+		linkStats.Duplex, _ = readStringFile(filepath.Join(dir, "duplex"))
+		linkStats.HalfDuplex = linkStats.Duplex == `half`
+
+		if speed > m.maxSpeeds[name] {
+			m.maxSpeeds[name] = speed
+		}
+		linkStats.MaxSpeed = m.maxSpeeds[name]
+		linkStats.Downgraded = speed < linkStats.MaxSpeed
+
+		if last, ok := m.last[name]; ok && seconds > 0 {
+			linkStats.RxBytes = counterRate(last[`rxbytes`], ifaceRawStats[`rxbytes`], seconds)
+			linkStats.TxBytes = counterRate(last[`txbytes`], ifaceRawStats[`txbytes`], seconds)
+			// The speed is in Mbit/s and the rates in bytes/s
+			capacity := float64(speed) * 1e6 / 8
+			linkStats.RxUtil = linkStats.RxBytes * 100 / capacity
+			linkStats.TxUtil = linkStats.TxBytes * 100 / capacity
+			linkStats.Saturated = linkStats.RxUtil > m.config.MaxUtilization || linkStats.TxUtil > m.config.MaxUtilization
+		}
+
+		linkStatsArr = append(linkStatsArr, linkStats)
+	}
+	m.time = now
+	m.last = netRawStats
+
+	sort.Slice(linkStatsArr, func(i, j int) bool { return linkStatsArr[i].Name < linkStatsArr[j].Name })
+
+	return linkStatsArr, nil
+}
+
+// readLinkSpeed reads the speed file of a network interface (Mbit/s). ok is
+// false if the interface doesn't report its speed: reading it fails (the
+// link is down or the driver doesn't support it) or it's -1 (unknown).
+func readLinkSpeed(path string) (speed uint64, ok bool) {
+	content, err := readStringFile(path)
+	if err != nil {
+		return 0, false
+	}
+	value, err := strconv.ParseInt(content, 10, 64)
+	if err != nil || value <= 0 || value == 1<<32-1 {
+		return 0, false
+	}
+
+	return uint64(value), true
+}
@@ -0,0 +1,13 @@
+package sysstats
+
+// LoadAvg represents the load average of the system. The scheduling
+// entities and the last PID are only reported by linux (they are 0 on the
+// other systems).
+type LoadAvg struct {
+	Avg1     float64 `json:"avg1"`     // The average processor workload of the last minute
+	Avg5     float64 `json:"avg5"`     // The average processor workload of the last 5 minutes
+	Avg15    float64 `json:"avg15"`    // The average processor workload of the last 15 minutes
+	Runnable uint64  `json:"runnable"` // # of currently runnable kernel scheduling entities (processes, threads)
+	Total    uint64  `json:"total"`    // # of kernel scheduling entities that currently exist on the system
+	LastPid  uint64  `json:"lastpid"`  // PID of the process most recently created on the system
+}
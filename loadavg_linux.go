@@ -3,44 +3,57 @@
 package sysstats
 
 import (
-	"io/ioutil"
+	"errors"
 	"strconv"
 	"strings"
 )
 
-// LoadAvg represents the load average of the system
-type LoadAvg struct {
-	Avg1  float64 `json:"avg1"`  // The average processor workload of the last minute
-	Avg5  float64 `json:"avg5"`  // The average processor workload of the last 5 minutes
-	Avg15 float64 `json:"avg15"` // The average processor workload of the last 15 minutes
-}
-
 // getLoadAvg gets the load average of a linux system from the
 // file /proc/loadavg.
 func getLoadAvg() (loadAvg LoadAvg, err error) {
-	file, err := ioutil.ReadFile("/proc/loadavg")
+	content, err := getProcReader().readFile("loadavg")
 	if err != nil {
 		return LoadAvg{}, err
 	}
-	content := string(file[:len(file)])
 
-	loadAvg = LoadAvg{}
+	return parseLoadAvg(string(content))
+}
+
+// parseLoadAvg parses the content of /proc/loadavg, that has the following
+// format:
+//   0.20 0.18 0.12 1/80 11206
+func parseLoadAvg(content string) (loadAvg LoadAvg, err error) {
 	fields := strings.Fields(content)
-	loadAvg1, err := strconv.ParseFloat(fields[0], 64)
-	if err != nil {
+	if len(fields) != 5 {
+		return LoadAvg{}, errors.New("Error parsing file /proc/loadavg. It should have 5 fields")
+	}
+
+	if loadAvg.Avg1, err = strconv.ParseFloat(fields[0], 64); err != nil {
 		return LoadAvg{}, err
 	}
-	loadAvg.Avg1 = loadAvg1
-	loadAvg5, err := strconv.ParseFloat(fields[1], 64)
-	if err != nil {
+	if loadAvg.Avg5, err = strconv.ParseFloat(fields[1], 64); err != nil {
 		return LoadAvg{}, err
 	}
-	loadAvg.Avg5 = loadAvg5
-	loadAvg15, err := strconv.ParseFloat(fields[2], 64)
-	if err != nil {
+	if loadAvg.Avg15, err = strconv.ParseFloat(fields[2], 64); err != nil {
+		return LoadAvg{}, err
+	}
+
+	// The fourth field has the runnable and total entities separated by a
+	// slash '/'
+	entities := strings.Split(fields[3], `/`)
+	if len(entities) != 2 {
+		return LoadAvg{}, errors.New("Error parsing file /proc/loadavg. Unexpected field: " + fields[3])
+	}
+	if loadAvg.Runnable, err = strconv.ParseUint(entities[0], 10, 64); err != nil {
+		return LoadAvg{}, err
+	}
+	if loadAvg.Total, err = strconv.ParseUint(entities[1], 10, 64); err != nil {
+		return LoadAvg{}, err
+	}
+
+	if loadAvg.LastPid, err = strconv.ParseUint(fields[4], 10, 64); err != nil {
 		return LoadAvg{}, err
 	}
-	loadAvg.Avg15 = loadAvg15
 
 	return loadAvg, nil
 }
@@ -0,0 +1,79 @@
+// +build linux
+
+package sysstats
+
+import (
+	"regexp"
+)
+
+// KernelLockupEvent represents *one* hung task, lockup or RCU stall reported
+// by the kernel.
+type KernelLockupEvent struct {
+	Kind      string  `json:"kind"`      // hungtask, softlockup, hardlockup or rcustall
+	Timestamp float64 `json:"timestamp"` // Seconds since boot
+	Message   string  `json:"message"`   // Kernel message
+}
+
+// KernelLockupStats represents the hung tasks and lockups of a linux system.
+// The counters are got from the kernel log buffer, so they only include the
+// events still in it.
+type KernelLockupStats struct {
+	HungTasks           uint64              `json:"hungtasks"`           // # of "task blocked for more than N seconds" reports
+	SoftLockups         uint64              `json:"softlockups"`         // # of soft lockups (a CPU stuck in kernel mode)
+	HardLockups         uint64              `json:"hardlockups"`         // # of hard lockups (a CPU stuck with interrupts disabled)
+	RcuStalls           uint64              `json:"rcustalls"`           // # of RCU CPU stalls
+	HungTaskDetectCount int64               `json:"hungtaskdetectcount"` // # of hung tasks detected since boot (kernel.hung_task_detect_count, -1 if not available)
+	Events              []KernelLockupEvent `json:"events"`              // Events in the kernel log buffer
+}
+
+var kernelLockupPatterns = []struct {
+	kind string
+	re   *regexp.Regexp
+}{
+	{`hungtask`, regexp.MustCompile(`^INFO: task .+ blocked for more than \d+ seconds`)},
+	{`softlockup`, regexp.MustCompile(`BUG: soft lockup - CPU#\d+ stuck for`)},
+	{`hardlockup`, regexp.MustCompile(`Watchdog detected hard LOCKUP on cpu \d+`)},
+	{`rcustall`, regexp.MustCompile(`rcu_\w+ (?:self-)?detected (?:expedited )?stalls? on CPU`)},
+}
+
+// getKernelLockupStats gets the hung tasks, soft and hard lockups and RCU
+// stalls of a linux system from /dev/kmsg and
+// /proc/sys/kernel/hung_task_detect_count.
+func getKernelLockupStats() (kernelLockupStats KernelLockupStats, err error) {
+	records, err := readKmsg()
+	if err != nil {
+		return KernelLockupStats{}, err
+	}
+
+	kernelLockupStats = KernelLockupStats{HungTaskDetectCount: -1, Events: []KernelLockupEvent{}}
+	for _, record := range records {
+		for _, pattern := range kernelLockupPatterns {
+			if !pattern.re.MatchString(record.Message) {
+				continue
+			}
+			switch pattern.kind {
+			case `hungtask`:
+				kernelLockupStats.HungTasks++
+			case `softlockup`:
+				kernelLockupStats.SoftLockups++
+			case `hardlockup`:
+				kernelLockupStats.HardLockups++
+			case `rcustall`:
+				kernelLockupStats.RcuStalls++
+			}
+			kernelLockupStats.Events = append(kernelLockupStats.Events, KernelLockupEvent{
+				Kind:      pattern.kind,
+				Timestamp: record.Timestamp,
+				Message:   record.Message,
+			})
+			break
+		}
+	}
+
+	// Only newer kernels have it
+	if count, err := getProcReader().readUint("sys", "kernel", "hung_task_detect_count"); err == nil {
+		kernelLockupStats.HungTaskDetectCount = int64(count)
+	}
+
+	return kernelLockupStats, nil
+}
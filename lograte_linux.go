@@ -0,0 +1,138 @@
+// +build linux
+
+package sysstats
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// LogRateConfig represents the configuration of a log rate monitor.
+type LogRateConfig struct {
+	Files       []string // Log files to monitor
+	Journald    bool     // Whether to monitor the journald journals (/var/log/journal and /run/log/journal)
+	BurstFactor float64  // A source is bursting when its rate is over BurstFactor times its average rate (default 5)
+	MinBurst    float64  // Min rate in bytes per second to be considered a burst (default 64 KiB/s)
+}
+
+// LogRate represents the write rate of *one* log source.
+type LogRate struct {
+	Source  string  `json:"source"`  // Log file path or "journald"
+	Size    uint64  `json:"size"`    // Current size in bytes
+	Rate    float64 `json:"rate"`    // Write rate since the previous check (bytes per second)
+	AvgRate float64 `json:"avgrate"` // Average write rate (exponentially weighted, bytes per second)
+	Burst   bool    `json:"burst"`   // Whether the source is writing much faster than usual
+	Rotated bool    `json:"rotated"` // Whether the file was rotated or truncated since the previous check
+	Error   string  `json:"error"`   // Error reading the source (empty if it succeeded)
+}
+
+// LogRateMonitor measures the write rates of log files and journald, since
+// runaway logging is a top cause of disk-full and I/O saturation incidents.
+// The rates are calculated between calls to Check.
+type LogRateMonitor struct {
+	config LogRateConfig
+	mu     sync.Mutex
+	last   map[string]logRateState
+}
+
+type logRateState struct {
+	time    time.Time
+	size    uint64
+	ino     uint64
+	rates   uint64
+	avgRate float64
+}
+
+// logRateAlpha is the weight of the last rate in the average rate.
+const logRateAlpha = 0.2
+
+// NewLogRateMonitor returns a LogRateMonitor for the given configuration.
+func NewLogRateMonitor(config LogRateConfig) *LogRateMonitor {
+	if config.BurstFactor <= 0 {
+		config.BurstFactor = 5
+	}
+	if config.MinBurst <= 0 {
+		config.MinBurst = 64 << 10
+	}
+
+	return &LogRateMonitor{config: config, last: map[string]logRateState{}}
+}
+
+// Check returns the write rates of the configured sources since the previous
+// call (the rates are 0 on the first call).
+func (m *LogRateMonitor) Check() (rates []LogRate, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rates = make([]LogRate, 0, len(m.config.Files)+1)
+	now := time.Now()
+	for _, file := range m.config.Files {
+		info, err := os.Stat(file)
+		if err != nil {
+			rates = append(rates, LogRate{Source: file, Error: err.Error()})
+			delete(m.last, file)
+			continue
+		}
+		var ino uint64
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			ino = stat.Ino
+		}
+		rates = append(rates, m.update(file, uint64(info.Size()), ino, now))
+	}
+
+	if m.config.Journald {
+		// The journal files are rotated and vacuumed by journald, so the
+		// rate is calculated from the size of all of them
+		var size uint64
+		for _, dir := range []string{"/var/log/journal", "/run/log/journal"} {
+			files, _ := filepath.Glob(filepath.Join(dir, "*", "*.journal"))
+			for _, file := range files {
+				if info, err := os.Stat(file); err == nil {
+					size += uint64(info.Size())
+				}
+			}
+		}
+		rates = append(rates, m.update(`journald`, size, 0, now))
+	}
+
+	return rates, nil
+}
+
+// update calculates the rate of a source from its previous state and saves
+// the new one.
+func (m *LogRateMonitor) update(source string, size uint64, ino uint64, now time.Time) (rate LogRate) {
+	rate = LogRate{Source: source, Size: size}
+	last, ok := m.last[source]
+	state := logRateState{time: now, size: size, ino: ino}
+	if !ok {
+		m.last[source] = state
+		return rate
+	}
+
+	elapsed := now.Sub(last.time).Seconds()
+	if ino != last.ino || size < last.size {
+		// Rotated or truncated: only the size of the new file is known
+		rate.Rotated = true
+		if elapsed > 0 {
+			rate.Rate = float64(size) / elapsed
+		}
+	} else if elapsed > 0 {
+		rate.Rate = float64(size-last.size) / elapsed
+	}
+
+	// The first rate is the initial average and there is no burst until
+	// there is an average to compare with
+	state.rates = last.rates + 1
+	state.avgRate = rate.Rate
+	if last.rates > 0 {
+		state.avgRate = logRateAlpha*rate.Rate + (1-logRateAlpha)*last.avgRate
+		rate.Burst = rate.Rate >= m.config.MinBurst && rate.Rate > m.config.BurstFactor*last.avgRate
+	}
+	rate.AvgRate = state.avgRate
+	m.last[source] = state
+
+	return rate
+}
@@ -0,0 +1,67 @@
+// +build linux
+
+package sysstats
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// LoopDevice represents *one* configured loop device of a linux system.
+type LoopDevice struct {
+	Name        string   `json:"name"`        // Name of the loop device (loop0...)
+	BackingFile string   `json:"backingfile"` // File backing the device
+	Offset      uint64   `json:"offset"`      // Offset in the backing file (bytes)
+	SizeLimit   uint64   `json:"sizelimit"`   // Size limit (bytes, 0 if none)
+	Size        uint64   `json:"size"`        // Size of the device (bytes)
+	AutoClear   bool     `json:"autoclear"`   // Whether the device is detached when it's unmounted
+	MountPoints []string `json:"mountpoints"` // Where the device is mounted
+	Snap        string   `json:"snap"`        // Snap package backed by the device (if any)
+}
+
+// getLoopDevices gets the configured loop devices of a linux system from
+// /sys/block/loop*/loop and where they are mounted from /proc/self/mountinfo.
+func getLoopDevices() (loopDevices []LoopDevice, err error) {
+	loopDirs, err := filepath.Glob("/sys/block/loop*/loop")
+	if err != nil {
+		return nil, err
+	}
+
+	mounts, err := getMountInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	loopDevices = make([]LoopDevice, 0, len(loopDirs))
+	for _, loopDir := range loopDirs {
+		blockDir := filepath.Dir(loopDir)
+		loopDevice := LoopDevice{Name: filepath.Base(blockDir), MountPoints: []string{}}
+		if loopDevice.BackingFile, err = readStringFile(filepath.Join(loopDir, "backing_file")); err != nil {
+			// Detached while reading it
+			continue
+		}
+		loopDevice.Offset, _ = readUintFile(filepath.Join(loopDir, "offset"))
+		loopDevice.SizeLimit, _ = readUintFile(filepath.Join(loopDir, "sizelimit"))
+		autoClear, _ := readUintFile(filepath.Join(loopDir, "autoclear"))
+		loopDevice.AutoClear = autoClear == 1
+		// Size is in 512 bytes sectors
+		sectors, _ := readUintFile(filepath.Join(blockDir, "size"))
+		loopDevice.Size = sectors * 512
+
+		// Snaps are mounted from /var/lib/snapd/snaps/<name>_<revision>.snap
+		if strings.Contains(loopDevice.BackingFile, "/snapd/snaps/") &&
+			strings.HasSuffix(loopDevice.BackingFile, ".snap") {
+			loopDevice.Snap = strings.TrimSuffix(filepath.Base(loopDevice.BackingFile), ".snap")
+		}
+
+		for _, mount := range mounts {
+			if mount.Source == "/dev/"+loopDevice.Name {
+				loopDevice.MountPoints = append(loopDevice.MountPoints, mount.MountPoint)
+			}
+		}
+
+		loopDevices = append(loopDevices, loopDevice)
+	}
+
+	return loopDevices, nil
+}
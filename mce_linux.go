@@ -0,0 +1,184 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// HardwareErrorEvent represents *one* hardware error reported by the kernel:
+// a machine check (mce), a memory error of an EDAC driver (edac) or an error
+// of the firmware (ghes, APEI Generic Hardware Error Source).
+type HardwareErrorEvent struct {
+	Source    string  `json:"source"`    // Source of the error (mce, edac or ghes)
+	Severity  string  `json:"severity"`  // Severity of the error (corrected, uncorrected or fatal)
+	Cpu       int     `json:"cpu"`       // CPU that reported the machine check (-1 if it isn't a machine check)
+	Bank      int     `json:"bank"`      // Bank of the machine check (-1 if it isn't a machine check)
+	Status    string  `json:"status"`    // MCi_STATUS of the bank (hexadecimal). Empty if it isn't a machine check
+	Timestamp float64 `json:"timestamp"` // Seconds since boot
+	Message   string  `json:"message"`   // Kernel message
+}
+
+// MachineCheckStats represents the hardware errors of a linux system. The
+// events are got from the kernel log buffer, so they only include the ones
+// still in it (the details of the machine checks can be decoded with
+// `mcelog --ascii`).
+type MachineCheckStats struct {
+	Exceptions  uint64               `json:"exceptions"`  // # of machine check exceptions since boot (x86 only)
+	Polls       uint64               `json:"polls"`       // # of machine check polls since boot (x86 only)
+	Corrected   uint64               `json:"corrected"`   // # of corrected errors in the events
+	Uncorrected uint64               `json:"uncorrected"` // # of uncorrected (but recoverable) errors in the events
+	Fatal       uint64               `json:"fatal"`       // # of fatal errors in the events
+	Events      []HardwareErrorEvent `json:"events"`      // Hardware error events
+}
+
+var (
+	// reMceBank matches the machine checks of the kernel log:
+	//   mce: [Hardware Error]: CPU 2: Machine Check: 0 Bank 7: cc00008000010090
+	reMceBank = regexp.MustCompile(`CPU (\d+): Machine Check(?: Exception)?: [0-9a-fA-Fx]+ Bank (\d+): ([0-9a-fA-F]+)`)
+	// reMceFatal matches the machine checks that panic the system
+	reMceFatal = regexp.MustCompile(`Machine check: Processor context corrupt|Fatal machine check`)
+	// reEdacError matches the memory errors of the EDAC drivers:
+	//   EDAC MC0: 1 CE memory read error on CPU_SrcID#0_Channel#0_DIMM#0 (...)
+	reEdacError = regexp.MustCompile(`EDAC [^:]+: (?:\d+ )?(CE|UE) `)
+	// reGhesSeverity matches the severity of the errors of the firmware:
+	//   {1}[Hardware Error]:  event severity: corrected
+	reGhesSeverity = regexp.MustCompile(`\[Hardware Error\]:\s+event severity: (\w+)`)
+)
+
+// MCi_STATUS bits of the severity of a machine check.
+const (
+	mceStatusUc  = 1 << 61 // Uncorrected error
+	mceStatusPcc = 1 << 57 // Processor context corrupt
+)
+
+// getMachineCheckStats gets the hardware errors of a linux system from
+// /dev/kmsg and the machine check counters of /proc/interrupts.
+func getMachineCheckStats() (machineCheckStats MachineCheckStats, err error) {
+	records, err := readKmsg()
+	if err != nil {
+		return MachineCheckStats{}, err
+	}
+
+	machineCheckStats = MachineCheckStats{Events: []HardwareErrorEvent{}}
+	for _, record := range records {
+		event, ok := parseHardwareError(record)
+		if !ok {
+			continue
+		}
+		switch event.Severity {
+		case `corrected`:
+			machineCheckStats.Corrected++
+		case `uncorrected`:
+			machineCheckStats.Uncorrected++
+		case `fatal`:
+			machineCheckStats.Fatal++
+		}
+		machineCheckStats.Events = append(machineCheckStats.Events, event)
+	}
+
+	content, err := readProcFile("interrupts")
+	if err != nil {
+		return MachineCheckStats{}, err
+	}
+	counts := parseInterruptCounts(content, `MCE`, `MCP`)
+	machineCheckStats.Exceptions = counts[`MCE`]
+	machineCheckStats.Polls = counts[`MCP`]
+
+	return machineCheckStats, nil
+}
+
+// parseHardwareError returns the hardware error of a record of the kernel
+// log. It returns false if the record isn't a hardware error.
+func parseHardwareError(record KmsgRecord) (event HardwareErrorEvent, ok bool) {
+	event = HardwareErrorEvent{Cpu: -1, Bank: -1, Timestamp: record.Timestamp, Message: record.Message}
+
+	if stat := reMceBank.FindStringSubmatch(record.Message); stat != nil {
+		event.Source = `mce`
+		event.Cpu, _ = strconv.Atoi(stat[1])
+		event.Bank, _ = strconv.Atoi(stat[2])
+		event.Status = stat[3]
+		status, err := strconv.ParseUint(stat[3], 16, 64)
+		switch {
+		case err != nil:
+			event.Severity = `uncorrected`
+		case status&mceStatusPcc != 0:
+			event.Severity = `fatal`
+		case status&mceStatusUc != 0:
+			event.Severity = `uncorrected`
+		default:
+			event.Severity = `corrected`
+		}
+		return event, true
+	}
+	if reMceFatal.MatchString(record.Message) {
+		event.Source = `mce`
+		event.Severity = `fatal`
+		return event, true
+	}
+	if stat := reEdacError.FindStringSubmatch(record.Message); stat != nil {
+		event.Source = `edac`
+		event.Severity = `corrected`
+		if stat[1] == `UE` {
+			event.Severity = `uncorrected`
+		}
+		return event, true
+	}
+	if stat := reGhesSeverity.FindStringSubmatch(record.Message); stat != nil {
+		event.Source = `ghes`
+		switch stat[1] {
+		case `corrected`:
+			event.Severity = `corrected`
+		case `fatal`:
+			event.Severity = `fatal`
+		case `recoverable`:
+			event.Severity = `uncorrected`
+		default:
+			// info
+			return HardwareErrorEvent{}, false
+		}
+		return event, true
+	}
+
+	return HardwareErrorEvent{}, false
+}
+
+// parseInterruptCounts returns the # of interrupts (the sum of all the CPUs)
+// of the given rows of the content of /proc/interrupts:
+//              CPU0       CPU1
+//    MCE:          0          0   Machine check exceptions
+//    MCP:         52         52   Machine check polls
+func parseInterruptCounts(content []byte, names ...string) (counts map[string]uint64) {
+	counts = map[string]uint64{}
+	wanted := map[string]bool{}
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		name := strings.TrimSuffix(fields[0], `:`)
+		if !wanted[name] {
+			continue
+		}
+		for _, field := range fields[1:] {
+			count, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				// The description of the interrupt
+				break
+			}
+			counts[name] += count
+		}
+	}
+
+	return counts
+}
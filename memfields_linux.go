@@ -0,0 +1,311 @@
+// +build linux
+
+package sysstats
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// memFieldOverrides are the derived fields of MemInfo a memory field can
+// override. The other fields are read from /proc/meminfo as they are.
+var memFieldOverrides = map[string]func(memInfo *MemInfo) *uint64{
+	`memused`:  func(memInfo *MemInfo) *uint64 { return &memInfo.MemUsed },
+	`swapused`: func(memInfo *MemInfo) *uint64 { return &memInfo.SwapUsed },
+	`realfree`: func(memInfo *MemInfo) *uint64 { return &memInfo.RealFree },
+}
+
+// memField is *one* registered memory field.
+type memField struct {
+	formula string
+	expr    memExpr
+}
+
+var (
+	memFieldsMu sync.RWMutex
+	memFields   = map[string]memField{}
+)
+
+// RegisterMemoryField registers a memory field calculated with a formula of
+// the statistics of /proc/meminfo (by their name in the file, in kilobytes),
+// so the conventions of an organization don't need a fork of the parser:
+//   RegisterMemoryField("appmemory", "MemTotal - MemAvailable")
+//   RegisterMemoryField("memused", "MemTotal - MemFree - Buffers - Cached - SReclaimable")
+// The formulas have the operators + - * / and parentheses, numbers and the
+// names of the statistics (e.g. Active(anon)), plus memused, swapused and
+// realfree as calculated by the parser. The negative results are 0.
+//
+// A field named memused, swapused or realfree overrides how that field of
+// MemInfo is calculated. The values of the other fields are returned by
+// GetMemoryFields and they are added to the mem.* metrics of the agent
+// (mem.<name>). It returns an error
+// if the name isn't valid, it's the name of a field read from /proc/meminfo
+// or the formula can't be parsed.
+func RegisterMemoryField(name string, formula string) error {
+	name = strings.ToLower(name)
+	if name == `` || strings.TrimLeft(name, `abcdefghijklmnopqrstuvwxyz0123456789_`) != `` {
+		return errors.New("Memory field names can only have letters, digits and underscores")
+	}
+	if _, native := (&MemInfo{}).fields()[name]; native && memFieldOverrides[name] == nil {
+		return errors.New("Memory field " + name + " is read from /proc/meminfo: only memused, swapused and realfree can be overridden")
+	}
+	expr, err := parseMemExpr(formula)
+	if err != nil {
+		return errors.New("Invalid formula of memory field " + name + ": " + err.Error())
+	}
+
+	memFieldsMu.Lock()
+	defer memFieldsMu.Unlock()
+	memFields[name] = memField{formula: formula, expr: expr}
+
+	return nil
+}
+
+// UnregisterMemoryField removes a memory field from the registry (an
+// overridden field is calculated again as the parser does).
+func UnregisterMemoryField(name string) {
+	memFieldsMu.Lock()
+	defer memFieldsMu.Unlock()
+	delete(memFields, strings.ToLower(name))
+}
+
+// MemoryFields returns the formulas of the registered memory fields by name.
+func MemoryFields() (formulas map[string]string) {
+	memFieldsMu.RLock()
+	defer memFieldsMu.RUnlock()
+
+	formulas = make(map[string]string, len(memFields))
+	for name, field := range memFields {
+		formulas[name] = field.formula
+	}
+
+	return formulas
+}
+
+// evalMemFields calculates the registered memory fields from the content of
+// /proc/meminfo and memInfo, that has been read from it. The overrides are
+// set in memInfo and the other fields are returned (nil if there aren't
+// registered fields). The fields whose
+// formulas can't be calculated (e.g. a statistic the kernel doesn't have)
+// are returned as FieldErrors: the overrides keep the value of the parser.
+func evalMemFields(content []byte, memInfo *MemInfo) (values map[string]uint64, errs MultiError) {
+	memFieldsMu.RLock()
+	defer memFieldsMu.RUnlock()
+
+	if len(memFields) == 0 {
+		return nil, nil
+	}
+
+	values = map[string]uint64{}
+	vars := readMemInfoValues(content)
+	for name, field := range memFieldOverrides {
+		vars[name] = float64(*field(memInfo))
+	}
+	names := make([]string, 0, len(memFields))
+	for name := range memFields {
+		names = append(names, name)
+	}
+	// The overrides are calculated with the values of the parser
+	sort.Strings(names)
+	for _, name := range names {
+		value, err := memFields[name].expr.eval(vars)
+		if err != nil {
+			errs = append(errs, &FieldError{Field: name, Err: err})
+			continue
+		}
+		result := uint64(0)
+		if value > 0 {
+			result = uint64(value)
+		}
+		if field, ok := memFieldOverrides[name]; ok {
+			*field(memInfo) = result
+		} else {
+			values[name] = result
+		}
+	}
+
+	return values, errs
+}
+
+// readMemInfoValues returns all the statistics of the content of
+// /proc/meminfo by their name in the file. The lines that can't be parsed
+// are skipped.
+func readMemInfoValues(content []byte) (values map[string]float64) {
+	values = map[string]float64{}
+	for len(content) > 0 {
+		var text []byte
+		text, content = nextLine(content)
+		colon := strings.IndexByte(string(text), ':')
+		if colon < 0 {
+			continue
+		}
+		field, _ := nextField(text[colon+1:])
+		if value, ok := parseUintBytes(field); ok {
+			values[string(text[:colon])] = float64(value)
+		}
+	}
+
+	return values
+}
+
+// memExpr is a node of the parsed formula of a memory field: a number, a
+// statistic or an operation of 2 nodes.
+type memExpr struct {
+	op    byte // + - * / for the operations, 0 for the numbers and the statistics
+	value float64
+	name  string
+	left  *memExpr
+	right *memExpr
+}
+
+// eval calculates the value of the expression with the values of the
+// statistics vars.
+func (e memExpr) eval(vars map[string]float64) (value float64, err error) {
+	if e.op == 0 {
+		if e.name == `` {
+			return e.value, nil
+		}
+		value, ok := vars[e.name]
+		if !ok {
+			return 0, errors.New("The statistic " + e.name + " isn't in /proc/meminfo")
+		}
+		return value, nil
+	}
+
+	left, err := e.left.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	right, err := e.right.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	switch e.op {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	}
+	if right == 0 {
+		return 0, errors.New("Division by zero")
+	}
+
+	return left / right, nil
+}
+
+// memExprParser parses a formula with recursive descent:
+//   expr   = term { (+|-) term }
+//   term   = factor { (*|/) factor }
+//   factor = number | name | ( expr )
+// A name followed by a parenthesis without spaces (e.g. Active(anon)) is a
+// single name: the formulas don't have functions.
+type memExprParser struct {
+	formula string
+	pos     int
+}
+
+// parseMemExpr parses the formula of a memory field.
+func parseMemExpr(formula string) (expr memExpr, err error) {
+	p := &memExprParser{formula: formula}
+	if expr, err = p.expr(); err != nil {
+		return memExpr{}, err
+	}
+	if p.skipSpaces(); p.pos < len(p.formula) {
+		return memExpr{}, errors.New("Unexpected " + strconv.Quote(p.formula[p.pos:]))
+	}
+
+	return expr, nil
+}
+
+// skipSpaces advances the parser over the spaces.
+func (p *memExprParser) skipSpaces() {
+	for p.pos < len(p.formula) && (p.formula[p.pos] == ' ' || p.formula[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// binary parses the operations of ops whose operands are parsed by next.
+func (p *memExprParser) binary(ops string, next func() (memExpr, error)) (expr memExpr, err error) {
+	if expr, err = next(); err != nil {
+		return memExpr{}, err
+	}
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.formula) || strings.IndexByte(ops, p.formula[p.pos]) < 0 {
+			return expr, nil
+		}
+		op := p.formula[p.pos]
+		p.pos++
+		right, err := next()
+		if err != nil {
+			return memExpr{}, err
+		}
+		left := expr
+		expr = memExpr{op: op, left: &left, right: &right}
+	}
+}
+
+// expr parses a sum or a subtraction.
+func (p *memExprParser) expr() (memExpr, error) {
+	return p.binary(`+-`, p.term)
+}
+
+// term parses a product or a division.
+func (p *memExprParser) term() (memExpr, error) {
+	return p.binary(`*/`, p.factor)
+}
+
+// factor parses a number, a name or an expression within parentheses.
+func (p *memExprParser) factor() (expr memExpr, err error) {
+	p.skipSpaces()
+	if p.pos >= len(p.formula) {
+		return memExpr{}, errors.New("Unexpected end of the formula")
+	}
+
+	start := p.pos
+	c := p.formula[p.pos]
+	switch {
+	case c == '(':
+		p.pos++
+		if expr, err = p.expr(); err != nil {
+			return memExpr{}, err
+		}
+		if p.skipSpaces(); p.pos >= len(p.formula) || p.formula[p.pos] != ')' {
+			return memExpr{}, errors.New("Missing )")
+		}
+		p.pos++
+		return expr, nil
+	case c >= '0' && c <= '9' || c == '.':
+		for p.pos < len(p.formula) && (p.formula[p.pos] >= '0' && p.formula[p.pos] <= '9' || p.formula[p.pos] == '.') {
+			p.pos++
+		}
+		value, err := strconv.ParseFloat(p.formula[start:p.pos], 64)
+		if err != nil {
+			return memExpr{}, err
+		}
+		return memExpr{value: value}, nil
+	case isMemNameChar(c):
+		for p.pos < len(p.formula) && isMemNameChar(p.formula[p.pos]) {
+			p.pos++
+		}
+		// Active(anon), Inactive(file)...
+		if p.pos < len(p.formula) && p.formula[p.pos] == '(' {
+			if end := strings.IndexByte(p.formula[p.pos:], ')'); end > 1 && strings.TrimLeft(p.formula[p.pos+1:p.pos+end], `abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_`) == `` {
+				p.pos += end + 1
+			}
+		}
+		return memExpr{name: p.formula[start:p.pos]}, nil
+	}
+
+	return memExpr{}, errors.New("Unexpected " + strconv.Quote(string(c)))
+}
+
+// isMemNameChar returns true if c can be in the name of a statistic.
+func isMemNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
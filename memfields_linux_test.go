@@ -0,0 +1,59 @@
+// +build linux
+
+package sysstats
+
+import "testing"
+
+func TestMemoryFields(t *testing.T) {
+	useProcFixtures(t)
+	fields := map[string]string{
+		`appmemory`: `MemTotal - MemAvailable`,
+		`anonper`:   `(Active(anon) + Inactive(anon)) * 100 / MemTotal`,
+		`memused`:   `MemTotal - MemFree - Buffers - Cached - SReclaimable`,
+		`missing`:   `MemTotal - NoSuchStat`,
+	}
+	for name, formula := range fields {
+		if err := RegisterMemoryField(name, formula); err != nil {
+			t.Fatal(err)
+		}
+		name := name
+		t.Cleanup(func() { UnregisterMemoryField(name) })
+	}
+
+	memInfo, values, err := getMemInfoFields()
+	// Only the field with an unknown statistic fails
+	errs, ok := err.(MultiError)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("Errors %v, want the one of missing", err)
+	}
+	if values[`appmemory`] != 16303428-9879436 {
+		t.Errorf("appmemory %d, want %d", values[`appmemory`], 16303428-9879436)
+	}
+	if want := uint64((5407632 + 219488) * 100 / 16303428); values[`anonper`] != want {
+		t.Errorf("anonper %d, want %d", values[`anonper`], want)
+	}
+	if _, ok := values[`missing`]; ok {
+		t.Error("The field with an unknown statistic has a value")
+	}
+	// The override replaces the memused of the parser
+	if want := uint64(16303428 - 1954660 - 612840 - 7198624 - 397584); memInfo.MemUsed != want {
+		t.Errorf("memused %d, want %d", memInfo.MemUsed, want)
+	}
+	if _, ok := values[`memused`]; ok {
+		t.Error("The override is returned as a field")
+	}
+}
+
+func TestRegisterMemoryFieldErrors(t *testing.T) {
+	for name, formula := range map[string]string{
+		`app-memory`: `MemTotal - MemAvailable`,
+		`memtotal`:   `MemFree`,
+		`unbalanced`: `(MemTotal - MemFree`,
+		`operator`:   `MemTotal -`,
+	} {
+		if err := RegisterMemoryField(name, formula); err == nil {
+			UnregisterMemoryField(name)
+			t.Errorf("%s = %s was registered", name, formula)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package sysstats
+
+// MemStat represents the memory statistics on a linux system.
+//
+// Map keys:
+//   MemUsed      -  Total size of used memory in kilobytes.
+//   MemFree      -  Total size of free memory in kilobytes.
+//   MemTotal     -  Total size of memory in kilobytes.
+//   Buffers      -  Total size of buffers used from memory in kilobytes.
+//   Cached       -  Total size of cached memory in kilobytes.
+//   RealFree     -  Total size of memory is real free (memfree + buffers +
+//                   cached).
+//   SwapUsed     -  Total size of swap space is used is kilobytes.
+//   SwapFree     -  Total size of swap space is free in kilobytes.
+//   SwapTotal    -  Total size of swap space in kilobytes.
+//   Swapcached   -  Memory that once was swapped out, is swapped back in but
+//                   still also is in the swapfile.
+//   Active       -  Memory that has been used more recently and usually not
+//                   reclaimed unless absolutely necessary.
+//   Inactive     -  Memory which has been less recently used and is more
+//                   eligible to be reclaimed for other purposes.
+// The following statistics are only available for kernels >= 2.6
+//   Slab         -  Total size of memory in kilobytes that used by kernel for
+//                   data structure allocations.
+//   Dirty        -  Total size of memory pages in kilobytes that waits to be
+//                   written back to disk.
+//   Mapped       -  Total size of memory in kilobytes that is mapped by devices
+//                   or libraries with mmap.
+//   Writeback    -  Total size of memory that was written back to disk.
+//   Committed_AS -  The amount of memory presently allocated on the system.
+// The following statistic is only available for kernels >= 2.6.9
+//   CommitLimit  -  Total amount of memory currently available to be allocated
+//                   on the system.
+// The following statistics are only available with hugepages support
+//   HugePages_Total - # of hugepages in the pool.
+//   HugePages_Free  - # of hugepages in the pool not yet allocated.
+//   HugePages_Rsvd  - # of hugepages reserved but not yet allocated.
+//   HugePages_Surp  - # of hugepages above the size of the pool
+//                     (overcommitted).
+//   Hugepagesize    - Size of the hugepages in kilobytes.
+//   AnonHugePages   - Total size of the transparent hugepages in kilobytes.
+//
+// Deprecated: use MemInfo, that has the same statistics as fields.
+type MemStats map[string]uint64
+
+// MemInfo represents the memory statistics of a linux system as a struct, so
+// the set of statistics is checked at compile time. The JSON keys are the
+// MemStats map keys. All the sizes are in kilobytes (the HugePages_* fields
+// are # of pages).
+type MemInfo struct {
+	MemTotal       uint64 `json:"memtotal"`        // Total size of memory
+	MemFree        uint64 `json:"memfree"`         // Size of free memory
+	MemUsed        uint64 `json:"memused"`         // Size of used memory (memtotal - memfree)
+	Buffers        uint64 `json:"buffers"`         // Size of the buffers
+	Cached         uint64 `json:"cached"`          // Size of the page cache
+	RealFree       uint64 `json:"realfree"`        // Size of memory really free (memfree + buffers + cached)
+	SwapTotal      uint64 `json:"swaptotal"`       // Total size of swap space
+	SwapFree       uint64 `json:"swapfree"`        // Size of free swap space
+	SwapUsed       uint64 `json:"swapused"`        // Size of used swap space (swaptotal - swapfree)
+	SwapCached     uint64 `json:"swapcached"`      // Memory swapped back in that is still in the swapfile
+	Active         uint64 `json:"active"`          // Memory used more recently
+	Inactive       uint64 `json:"inactive"`        // Memory used less recently (more eligible to be reclaimed)
+	Slab           uint64 `json:"slab"`            // Memory used by the kernel data structures
+	Dirty          uint64 `json:"dirty"`           // Memory waiting to be written back to disk
+	Mapped         uint64 `json:"mapped"`          // Memory mapped with mmap
+	Writeback      uint64 `json:"writeback"`       // Memory being written back to disk
+	CommittedAS    uint64 `json:"committed_as"`    // Memory presently allocated on the system
+	CommitLimit    uint64 `json:"commitlimit"`     // Memory currently available to be allocated on the system
+	HugePagesTotal uint64 `json:"hugepages_total"` // # of hugepages in the pool
+	HugePagesFree  uint64 `json:"hugepages_free"`  // # of hugepages in the pool not yet allocated
+	HugePagesRsvd  uint64 `json:"hugepages_rsvd"`  // # of hugepages reserved but not yet allocated
+	HugePagesSurp  uint64 `json:"hugepages_surp"`  // # of hugepages above the size of the pool
+	Hugepagesize   uint64 `json:"hugepagesize"`    // Size of the hugepages
+	AnonHugePages  uint64 `json:"anonhugepages"`   // Memory used by transparent hugepages
+}
+
+// fields returns the fields of memInfo indexed by their MemStats map key.
+func (memInfo *MemInfo) fields() map[string]*uint64 {
+	return map[string]*uint64{
+		`memtotal`:        &memInfo.MemTotal,
+		`memfree`:         &memInfo.MemFree,
+		`memused`:         &memInfo.MemUsed,
+		`buffers`:         &memInfo.Buffers,
+		`cached`:          &memInfo.Cached,
+		`realfree`:        &memInfo.RealFree,
+		`swaptotal`:       &memInfo.SwapTotal,
+		`swapfree`:        &memInfo.SwapFree,
+		`swapused`:        &memInfo.SwapUsed,
+		`swapcached`:      &memInfo.SwapCached,
+		`active`:          &memInfo.Active,
+		`inactive`:        &memInfo.Inactive,
+		`slab`:            &memInfo.Slab,
+		`dirty`:           &memInfo.Dirty,
+		`mapped`:          &memInfo.Mapped,
+		`writeback`:       &memInfo.Writeback,
+		`committed_as`:    &memInfo.CommittedAS,
+		`commitlimit`:     &memInfo.CommitLimit,
+		`hugepages_total`: &memInfo.HugePagesTotal,
+		`hugepages_free`:  &memInfo.HugePagesFree,
+		`hugepages_rsvd`:  &memInfo.HugePagesRsvd,
+		`hugepages_surp`:  &memInfo.HugePagesSurp,
+		`hugepagesize`:    &memInfo.Hugepagesize,
+		`anonhugepages`:   &memInfo.AnonHugePages,
+	}
+}
+
+// MemInfo returns the memory statistics as a MemInfo.
+func (memStats MemStats) MemInfo() (memInfo MemInfo) {
+	for key, value := range memInfo.fields() {
+		*value = memStats[key]
+	}
+
+	return memInfo
+}
+
+// ToMap returns the memory statistics as a MemStats map.
+func (memInfo MemInfo) ToMap() (memStats MemStats) {
+	memStats = MemStats{}
+	for key, value := range memInfo.fields() {
+		memStats[key] = *value
+	}
+
+	return memStats
+}
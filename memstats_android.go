@@ -0,0 +1,138 @@
+// +build android
+
+package sysstats
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AndroidMemInfo represents the memory statistics of an Android system: the
+// MemInfo statistics and the carve-outs that are only reported by some
+// Android kernels (ION, CMA and the GPU memory). All the sizes are in
+// kilobytes. Reported has the JSON keys of the carve-outs the kernel reports,
+// so an unreported carve-out can be told apart from an empty one.
+type AndroidMemInfo struct {
+	MemInfo
+	CmaTotal    uint64   `json:"cmatotal"`    // Total size of the CMA (contiguous memory allocator) area
+	CmaFree     uint64   `json:"cmafree"`     // Size of free CMA memory
+	IonHeap     uint64   `json:"ionheap"`     // Memory allocated by the ION heaps
+	IonHeapPool uint64   `json:"ionheappool"` // Memory cached in the ION page pools
+	Gpu         uint64   `json:"gpu"`         // Memory allocated by the GPU driver (Adreno kgsl)
+	Reported    []string `json:"reported"`    // Carve-outs reported by the kernel
+}
+
+// reAndroidMemInfo matches the lines of /proc/meminfo with the carve-outs of
+// AndroidMemInfo.
+var reAndroidMemInfo = regexp.MustCompile(`^(CmaTotal|CmaFree|ION_heap|ION_heap_pool):\s*(\d+)`)
+
+// androidMemFiles are the files with the carve-outs that aren't in
+// /proc/meminfo, with the unit of their values (kilobytes or bytes).
+var androidMemFiles = []struct {
+	key  string
+	path string
+	kb   bool
+}{
+	// GKI kernels report ION in sysfs instead of /proc/meminfo
+	{`ionheap`, `/sys/kernel/ion/total_heaps_kb`, true},
+	{`ionheappool`, `/sys/kernel/ion/total_pools_kb`, true},
+	{`gpu`, `/sys/class/kgsl/kgsl/page_alloc`, false},
+}
+
+// fields returns the carve-outs of androidMemInfo indexed by their JSON key.
+func (androidMemInfo *AndroidMemInfo) fields() map[string]*uint64 {
+	return map[string]*uint64{
+		`cmatotal`:    &androidMemInfo.CmaTotal,
+		`cmafree`:     &androidMemInfo.CmaFree,
+		`ionheap`:     &androidMemInfo.IonHeap,
+		`ionheappool`: &androidMemInfo.IonHeapPool,
+		`gpu`:         &androidMemInfo.Gpu,
+	}
+}
+
+// getAndroidMemInfo gets the memory stats of an Android system from the file
+// /proc/meminfo, the ION files of sysfs (GKI kernels) and the kgsl driver.
+// The carve-outs the kernel doesn't report (or that can't be read) are
+// skipped.
+func getAndroidMemInfo() (androidMemInfo AndroidMemInfo, err error) {
+	content, err := getProcReader().readFile("meminfo")
+	if err != nil {
+		return AndroidMemInfo{}, err
+	}
+
+	androidMemInfo.Reported = []string{}
+	if androidMemInfo.MemInfo, err = parseMemInfo(content); err != nil {
+		return AndroidMemInfo{}, err
+	}
+	if err = parseAndroidMemInfo(content, &androidMemInfo); err != nil {
+		return AndroidMemInfo{}, err
+	}
+
+	fields := androidMemInfo.fields()
+	for _, file := range androidMemFiles {
+		if androidMemInfo.reported(file.key) {
+			continue
+		}
+		value, err := readStringFile(file.path)
+		if err != nil {
+			continue
+		}
+		// page_alloc has the unit after the value in some releases
+		values := strings.Fields(value)
+		if len(values) == 0 {
+			continue
+		}
+		size, err := strconv.ParseUint(values[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		if !file.kb {
+			size /= 1024
+		}
+		*fields[file.key] = size
+		androidMemInfo.Reported = append(androidMemInfo.Reported, file.key)
+	}
+
+	return androidMemInfo, nil
+}
+
+// parseAndroidMemInfo parses the carve-outs of the content of /proc/meminfo:
+//   CmaTotal:         122880 kB
+//   CmaFree:            4880 kB
+//   ION_heap:          98304 kB
+//   ION_heap_pool:     12288 kB
+func parseAndroidMemInfo(content []byte, androidMemInfo *AndroidMemInfo) (err error) {
+	fields := androidMemInfo.fields()
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		stat := reAndroidMemInfo.FindStringSubmatch(scanner.Text())
+		if stat == nil {
+			continue
+		}
+		value, err := strconv.ParseUint(stat[2], 10, 64)
+		if err != nil {
+			return err
+		}
+		key := strings.Replace(strings.ToLower(stat[1]), `_`, ``, -1)
+		*fields[key] = value
+		androidMemInfo.Reported = append(androidMemInfo.Reported, key)
+	}
+
+	return nil
+}
+
+// reported returns true if the kernel reports the carve-out key.
+func (androidMemInfo AndroidMemInfo) reported(key string) bool {
+	for _, reported := range androidMemInfo.Reported {
+		if reported == key {
+			return true
+		}
+	}
+
+	return false
+}
@@ -3,14 +3,124 @@
 package sysstats
 
 import (
+	"bufio"
 	"errors"
-	"runtime"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
 )
 
-// MemStat represents the memory statistics on an OSX system
-type MemStats map[string]uint64
+// reVmStatPageSize matches the header of the output of vm_stat:
+//   Mach Virtual Memory Statistics: (page size of 16384 bytes)
+var reVmStatPageSize = regexp.MustCompile(`page size of (\d+) bytes`)
 
-// getMemStats gets the memory stats of an OSX system
+// reSwapUsage matches the value of the sysctl vm.swapusage:
+//   total = 2048.00M  used = 1034.25M  free = 1013.75M  (encrypted)
+var reSwapUsage = regexp.MustCompile(`total = ([\d.]+)M\s+used = ([\d.]+)M\s+free = ([\d.]+)M`)
+
+// getMemInfo gets the memory stats of a macOS system from the sysctls
+// hw.memsize and vm.swapusage and the page counts of vm_stat. The file-backed
+// pages are reported as Cached and the real free memory is the free and the
+// inactive memory. The statistics that don't exist on macOS (buffers, slab,
+// dirty...) are always 0.
+func getMemInfo() (memInfo MemInfo, err error) {
+	memSize, err := sysctlUint(`hw.memsize`)
+	if err != nil {
+		return MemInfo{}, err
+	}
+
+	out, err := exec.Command(`vm_stat`).Output()
+	if err != nil {
+		return MemInfo{}, err
+	}
+	pageSize, pages, err := parseVmStat(string(out))
+	if err != nil {
+		return MemInfo{}, err
+	}
+
+	kb := func(name string) uint64 {
+		return pages[name] * pageSize / 1024
+	}
+	memInfo.MemTotal = memSize / 1024
+	memInfo.MemFree = kb(`Pages free`) + kb(`Pages speculative`)
+	memInfo.MemUsed = memInfo.MemTotal - memInfo.MemFree
+	memInfo.Active = kb(`Pages active`)
+	memInfo.Inactive = kb(`Pages inactive`)
+	memInfo.Cached = kb(`File-backed pages`)
+	memInfo.RealFree = memInfo.MemFree + memInfo.Inactive
+
+	swapUsage, err := sysctl(`vm.swapusage`)
+	if err != nil {
+		return MemInfo{}, err
+	}
+	if memInfo.SwapTotal, memInfo.SwapUsed, memInfo.SwapFree, err = parseSwapUsage(swapUsage); err != nil {
+		return MemInfo{}, err
+	}
+
+	return memInfo, nil
+}
+
+// getMemStats gets the memory stats of a macOS system.
 func getMemStats() (memStats MemStats, err error) {
-	return nil, errors.New("getMemStats: " + runtime.GOOS + " not supported yet")
+	memInfo, err := getMemInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	return memInfo.ToMap(), nil
+}
+
+// parseVmStat parses the output of vm_stat and returns the size of the pages
+// (bytes) and the # of pages indexed by their name:
+//   Mach Virtual Memory Statistics: (page size of 16384 bytes)
+//   Pages free:                               12345.
+//   Pages active:                            245678.
+//   File-backed pages:                        98765.
+func parseVmStat(out string) (pageSize uint64, pages map[string]uint64, err error) {
+	header := reVmStatPageSize.FindStringSubmatch(out)
+	if header == nil {
+		return 0, nil, errors.New("Error parsing the output of vm_stat. It doesn't have the page size")
+	}
+	if pageSize, err = strconv.ParseUint(header[1], 10, 64); err != nil {
+		return 0, nil, err
+	}
+
+	pages = map[string]uint64{}
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	scanner.Split(bufio.ScanLines)
+	// Filter the header
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), `:`, 2)
+		if len(fields) != 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(fields[1]), `.`), 10, 64)
+		if err != nil {
+			continue
+		}
+		pages[strings.Trim(fields[0], `"`)] = value
+	}
+
+	return pageSize, pages, nil
+}
+
+// parseSwapUsage parses the value of the sysctl vm.swapusage and returns the
+// total, used and free swap space in kilobytes.
+func parseSwapUsage(out string) (total uint64, used uint64, free uint64, err error) {
+	stats := reSwapUsage.FindStringSubmatch(out)
+	if stats == nil {
+		return 0, 0, 0, errors.New("Error parsing the sysctl vm.swapusage: " + out)
+	}
+
+	for i, value := range []*uint64{&total, &used, &free} {
+		megabytes, err := strconv.ParseFloat(stats[i+1], 64)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		*value = uint64(megabytes * 1024)
+	}
+
+	return total, used, free, nil
 }
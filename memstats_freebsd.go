@@ -0,0 +1,97 @@
+// +build freebsd
+
+package sysstats
+
+import (
+	"bufio"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// getMemInfo gets the memory stats of a FreeBSD system from the sysctls
+// vm.stats.vm.* (page counts), vfs.bufspace and kstat.zfs.misc.arcstats.size
+// (the ZFS ARC is reported as Cached) and the swap space from `swapinfo -k`.
+// The real free memory is the free, the inactive and the ARC memory. The
+// statistics that don't exist on FreeBSD (slab, dirty...) are always 0.
+func getMemInfo() (memInfo MemInfo, err error) {
+	pageSize, err := sysctlUint(`hw.pagesize`)
+	if err != nil {
+		return MemInfo{}, err
+	}
+
+	pages := map[string]*uint64{
+		`vm.stats.vm.v_page_count`:     &memInfo.MemTotal,
+		`vm.stats.vm.v_free_count`:     &memInfo.MemFree,
+		`vm.stats.vm.v_active_count`:   &memInfo.Active,
+		`vm.stats.vm.v_inactive_count`: &memInfo.Inactive,
+	}
+	for name, value := range pages {
+		count, err := sysctlUint(name)
+		if err != nil {
+			return MemInfo{}, err
+		}
+		*value = count * pageSize / 1024
+	}
+	memInfo.MemUsed = memInfo.MemTotal - memInfo.MemFree
+
+	if bufSpace, err := sysctlUint(`vfs.bufspace`); err == nil {
+		memInfo.Buffers = bufSpace / 1024
+	}
+	// The sysctl only exists if the zfs module is loaded
+	if arcSize, err := sysctlUint(`kstat.zfs.misc.arcstats.size`); err == nil {
+		memInfo.Cached = arcSize / 1024
+	}
+	memInfo.RealFree = memInfo.MemFree + memInfo.Inactive + memInfo.Cached
+
+	out, err := exec.Command(`swapinfo`, `-k`).Output()
+	if err != nil {
+		return MemInfo{}, err
+	}
+	if memInfo.SwapTotal, memInfo.SwapUsed, err = parseSwapInfo(string(out)); err != nil {
+		return MemInfo{}, err
+	}
+	memInfo.SwapFree = memInfo.SwapTotal - memInfo.SwapUsed
+
+	return memInfo, nil
+}
+
+// getMemStats gets the memory stats of a FreeBSD system.
+func getMemStats() (memStats MemStats, err error) {
+	memInfo, err := getMemInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	return memInfo.ToMap(), nil
+}
+
+// parseSwapInfo parses the output of `swapinfo -k` and returns the total and
+// used swap space of all the devices in kilobytes:
+//   Device          1K-blocks     Used    Avail Capacity
+//   /dev/ada0p3       2097152    10240  2086912     0%
+// If there are several devices there is a Total line, that is skipped.
+func parseSwapInfo(out string) (total uint64, used uint64, err error) {
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	scanner.Split(bufio.ScanLines)
+	// Filter the header
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[0] == `Total` {
+			continue
+		}
+		blocks, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		blocksUsed, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		total += blocks
+		used += blocksUsed
+	}
+
+	return total, used, nil
+}
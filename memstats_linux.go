@@ -3,83 +3,228 @@
 package sysstats
 
 import (
-	"bufio"
-	"fmt"
-	"os"
-	"regexp"
+	"bytes"
+	"errors"
 	"strconv"
-	"strings"
 )
 
-// MemStat represents the memory statistics on a linux system.
-//
-// Map keys:
-//   MemUsed      -  Total size of used memory in kilobytes.
-//   MemFree      -  Total size of free memory in kilobytes.
-//   MemTotal     -  Total size of memory in kilobytes.
-//   Buffers      -  Total size of buffers used from memory in kilobytes.
-//   Cached       -  Total size of cached memory in kilobytes.
-//   RealFree     -  Total size of memory is real free (memfree + buffers +
-//                   cached).
-//   SwapUsed     -  Total size of swap space is used is kilobytes.
-//   SwapFree     -  Total size of swap space is free in kilobytes.
-//   SwapTotal    -  Total size of swap space in kilobytes.
-//   Swapcached   -  Memory that once was swapped out, is swapped back in but
-//                   still also is in the swapfile.
-//   Active       -  Memory that has been used more recently and usually not
-//                   reclaimed unless absolutely necessary.
-//   Inactive     -  Memory which has been less recently used and is more
-//                   eligible to be reclaimed for other purposes.
-// The following statistics are only available for kernels >= 2.6
-//   Slab         -  Total size of memory in kilobytes that used by kernel for
-//                   data structure allocations.
-//   Dirty        -  Total size of memory pages in kilobytes that waits to be
-//                   written back to disk.
-//   Mapped       -  Total size of memory in kilobytes that is mapped by devices
-//                   or libraries with mmap.
-//   Writeback    -  Total size of memory that was written back to disk.
-//   Committed_AS -  The amount of memory presently allocated on the system.
-// The following statistic is only available for kernels >= 2.6.9
-//   CommitLimit  -  Total amount of memory currently available to be allocated
-//                   on the system.
-type MemStats map[string]uint64
-
-// getMemStats gets the memory stats of a linux system from the
-// file /proc/meminfo
-func getMemStats() (memStats MemStats, err error) {
-	file, err := os.Open("/proc/meminfo")
+// memInfoStat is *one* statistic of /proc/meminfo read into MemInfo.
+type memInfoStat struct {
+	bit   uint32                         // Bit of the statistic in the mask of the statistics found
+	key   string                         // Key of the statistic in MemStats
+	field func(memInfo *MemInfo) *uint64 // Field of the statistic in MemInfo
+}
+
+// memInfoStats are the statistics of /proc/meminfo read into MemInfo, by
+// their name in the file. The rest of the lines are skipped.
+var memInfoStats = map[string]memInfoStat{
+	`MemTotal`:        {1 << 0, `memtotal`, func(memInfo *MemInfo) *uint64 { return &memInfo.MemTotal }},
+	`MemFree`:         {1 << 1, `memfree`, func(memInfo *MemInfo) *uint64 { return &memInfo.MemFree }},
+	`Buffers`:         {1 << 2, `buffers`, func(memInfo *MemInfo) *uint64 { return &memInfo.Buffers }},
+	`Cached`:          {1 << 3, `cached`, func(memInfo *MemInfo) *uint64 { return &memInfo.Cached }},
+	`SwapCached`:      {1 << 4, `swapcached`, func(memInfo *MemInfo) *uint64 { return &memInfo.SwapCached }},
+	`SwapTotal`:       {1 << 5, `swaptotal`, func(memInfo *MemInfo) *uint64 { return &memInfo.SwapTotal }},
+	`SwapFree`:        {1 << 6, `swapfree`, func(memInfo *MemInfo) *uint64 { return &memInfo.SwapFree }},
+	`Active`:          {1 << 7, `active`, func(memInfo *MemInfo) *uint64 { return &memInfo.Active }},
+	`Inactive`:        {1 << 8, `inactive`, func(memInfo *MemInfo) *uint64 { return &memInfo.Inactive }},
+	`Dirty`:           {1 << 9, `dirty`, func(memInfo *MemInfo) *uint64 { return &memInfo.Dirty }},
+	`Writeback`:       {1 << 10, `writeback`, func(memInfo *MemInfo) *uint64 { return &memInfo.Writeback }},
+	`Mapped`:          {1 << 11, `mapped`, func(memInfo *MemInfo) *uint64 { return &memInfo.Mapped }},
+	`Slab`:            {1 << 12, `slab`, func(memInfo *MemInfo) *uint64 { return &memInfo.Slab }},
+	`CommitLimit`:     {1 << 13, `commitlimit`, func(memInfo *MemInfo) *uint64 { return &memInfo.CommitLimit }},
+	`Committed_AS`:    {1 << 14, `committed_as`, func(memInfo *MemInfo) *uint64 { return &memInfo.CommittedAS }},
+	`AnonHugePages`:   {1 << 15, `anonhugepages`, func(memInfo *MemInfo) *uint64 { return &memInfo.AnonHugePages }},
+	`HugePages_Total`: {1 << 16, `hugepages_total`, func(memInfo *MemInfo) *uint64 { return &memInfo.HugePagesTotal }},
+	`HugePages_Free`:  {1 << 17, `hugepages_free`, func(memInfo *MemInfo) *uint64 { return &memInfo.HugePagesFree }},
+	`HugePages_Rsvd`:  {1 << 18, `hugepages_rsvd`, func(memInfo *MemInfo) *uint64 { return &memInfo.HugePagesRsvd }},
+	`HugePages_Surp`:  {1 << 19, `hugepages_surp`, func(memInfo *MemInfo) *uint64 { return &memInfo.HugePagesSurp }},
+	`Hugepagesize`:    {1 << 20, `hugepagesize`, func(memInfo *MemInfo) *uint64 { return &memInfo.Hugepagesize }},
+}
+
+// memInfoRequired are the statistics of /proc/meminfo needed to calculate
+// memused, swapused and realfree.
+var memInfoRequired = []string{`MemTotal`, `MemFree`, `Buffers`, `Cached`, `SwapTotal`, `SwapFree`}
+
+// getMemInfo gets the memory stats of a linux system from the file
+// /proc/meminfo.
+func getMemInfo() (memInfo MemInfo, err error) {
+	err = parseProcFile(func(content []byte) error {
+		memInfo, err = parseMemInfo(content)
+		return err
+	}, "meminfo")
 	if err != nil {
-		return nil, err
+		return MemInfo{}, err
+	}
+
+	return memInfo, nil
+}
+
+// getMemInfoPartial gets the memory stats of a linux system from the file
+// /proc/meminfo, like getMemInfo, but the statistics that can't be parsed
+// (or that are missing) don't fail the collection: it returns the others
+// with a MultiError of FieldErrors.
+func getMemInfoPartial() (memInfo MemInfo, err error) {
+	var errs MultiError
+	err = parseProcFile(func(content []byte) error {
+		memInfo, errs = readMemInfo(content)
+		return nil
+	}, "meminfo")
+	if err != nil {
+		return MemInfo{}, err
+	}
+
+	return memInfo, errs.errorOrNil()
+}
+
+// parseMemInfo parses the content of /proc/meminfo, that has the following
+// format:
+//   MemTotal:        6158152 kB
+//   MemFree:         3164188 kB
+//   Buffers:          267480 kB
+// Then it calculates memused, swapused and realfree. It returns a ParseError
+// if a value can't be parsed; the missing statistics are 0.
+func parseMemInfo(content []byte) (memInfo MemInfo, err error) {
+	memInfo, errs := readMemInfo(content)
+	if err := memInfoParseError(errs); err != nil {
+		return MemInfo{}, err
+	}
+
+	return memInfo, nil
+}
+
+// memInfoParseError returns the first ParseError of errs, or nil if there
+// isn't any.
+func memInfoParseError(errs MultiError) error {
+	for _, err := range errs {
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			return parseErr
+		}
 	}
-	defer file.Close()
-
-	memStats = MemStats{}
-	re := regexp.MustCompile(`^((?:Mem|Swap)(?:Total|Free)|Buffers|Cached|` +
-		`SwapCached|Active|Inactive|Dirty|Writeback|Mapped|Slab|` +
-		`Commit(?:Limit|ted_AS)):\s*(\d+)`)
-
-	scanner := bufio.NewScanner(file)
-	scanner.Split(bufio.ScanLines)
-	for scanner.Scan() {
-		line := scanner.Text()
-		stat := re.FindStringSubmatch(line)
-		if stat == nil {
-			// No match
+
+	return nil
+}
+
+// readMemInfo reads the statistics of the content of /proc/meminfo and
+// returns the errors of the ones that can't be parsed or are required but
+// missing. memused, swapused and realfree are only calculated if the
+// statistics they need are there (and they don't underflow), and the
+// registered memory fields can override them (RegisterMemoryField).
+func readMemInfo(content []byte) (memInfo MemInfo, errs MultiError) {
+	memInfo, errs = readMemInfoStats(content)
+	_, fieldErrs := evalMemFields(content, &memInfo)
+	for _, err := range fieldErrs {
+		// Only the errors of the overrides are the ones of a MemInfo field
+		if fieldErr, ok := err.(*FieldError); ok && memFieldOverrides[fieldErr.Field] != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return memInfo, errs
+}
+
+// readMemInfoStats reads the statistics of the content of /proc/meminfo like
+// readMemInfo, without the overrides of the registered memory fields.
+func readMemInfoStats(content []byte) (memInfo MemInfo, errs MultiError) {
+	var found uint32
+
+	line := 0
+	for len(content) > 0 {
+		var text []byte
+		text, content = nextLine(content)
+		line++
+
+		colon := bytes.IndexByte(text, ':')
+		if colon < 0 {
+			continue
+		}
+		// The lookup with the converted key doesn't allocate
+		stat, ok := memInfoStats[string(text[:colon])]
+		if !ok {
+			continue
+		}
+		field, _ := nextField(text[colon+1:])
+		if len(field) == 0 || field[0] < '0' || field[0] > '9' {
+			// No value
 			continue
 		}
-		key := stat[1]
-		value, err := strconv.ParseUint(stat[2], 10, 64)
-		if err != nil {
-			fmt.Println(err)
+		found |= stat.bit
+		value, ok := parseUintBytes(field)
+		if !ok {
+			_, err := strconv.ParseUint(string(field), 10, 64)
+			errs = append(errs, &FieldError{Field: stat.key, Err: &ParseError{File: "/proc/meminfo", Line: line, Field: string(text[:colon]), Err: err}})
 			continue
-		} else {
-			memStats[strings.ToLower(key)] = value
+		}
+		*stat.field(&memInfo) = value
+	}
+	for _, name := range memInfoRequired {
+		if stat := memInfoStats[name]; found&stat.bit == 0 {
+			errs = append(errs, &FieldError{Field: stat.key, Err: ErrStatUnavailable})
+		}
+	}
+
+	if memInfoFound(found, `MemTotal`, `MemFree`) && memInfo.MemTotal >= memInfo.MemFree {
+		memInfo.MemUsed = memInfo.MemTotal - memInfo.MemFree
+	}
+	if memInfoFound(found, `SwapTotal`, `SwapFree`) && memInfo.SwapTotal >= memInfo.SwapFree {
+		memInfo.SwapUsed = memInfo.SwapTotal - memInfo.SwapFree
+	}
+	memInfo.RealFree = memInfo.MemFree + memInfo.Buffers + memInfo.Cached
+
+	return memInfo, errs
+}
+
+// memInfoFound returns true if all the statistics names are in the mask
+// found.
+func memInfoFound(found uint32, names ...string) bool {
+	for _, name := range names {
+		if found&memInfoStats[name].bit == 0 {
+			return false
 		}
 	}
 
-	memStats[`memused`] = memStats[`memtotal`] - memStats[`memfree`]
-	memStats[`swapused`] = memStats[`swaptotal`] - memStats[`swapfree`]
-	memStats[`realfree`] = memStats[`memfree`] + memStats[`buffers`] + memStats[`cached`]
+	return true
+}
+
+// getMemStats gets the memory stats of a linux system as a MemStats map. It
+// has the same keys as MemInfo.ToMap.
+func getMemStats() (memStats MemStats, err error) {
+	memInfo, err := getMemInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	return memInfo.ToMap(), nil
+}
+
+// getMemoryFields gets the values of the registered memory fields
+// (RegisterMemoryField) that don't override a MemInfo field, in kilobytes,
+// from the file /proc/meminfo. The fields whose formulas can't be calculated
+// are missing and the error is then a MultiError with a FieldError for each
+// of them.
+func getMemoryFields() (fields map[string]uint64, err error) {
+	_, fields, err = getMemInfoFields()
+	return fields, err
+}
+
+// getMemInfoFields gets the memory stats of a linux system like getMemInfo
+// and the values of the registered memory fields that don't override a
+// MemInfo field, reading /proc/meminfo once. err is a MultiError if only
+// some memory fields can't be calculated.
+func getMemInfoFields() (memInfo MemInfo, fields map[string]uint64, err error) {
+	var errs MultiError
+	err = parseProcFile(func(content []byte) error {
+		var statErrs MultiError
+		memInfo, statErrs = readMemInfoStats(content)
+		if err := memInfoParseError(statErrs); err != nil {
+			return err
+		}
+		fields, errs = evalMemFields(content, &memInfo)
+		return nil
+	}, "meminfo")
+	if err != nil {
+		return MemInfo{}, nil, err
+	}
 
-	return memStats, nil
+	return memInfo, fields, errs.errorOrNil()
 }
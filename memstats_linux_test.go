@@ -0,0 +1,56 @@
+// +build linux
+
+package sysstats
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestReadMemInfoStatsAllocs(t *testing.T) {
+	content := readProcFixture(t, "meminfo")
+	// The lookups of the statistics don't allocate
+	if allocs := testing.AllocsPerRun(100, func() { readMemInfoStats(content) }); allocs > 1 {
+		t.Errorf("readMemInfoStats allocates %.0f times, want at most 1", allocs)
+	}
+}
+
+func BenchmarkReadMemInfoStats(b *testing.B) {
+	content := readProcFixture(b, "meminfo")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		readMemInfoStats(content)
+	}
+}
+
+func BenchmarkGetMemInfo(b *testing.B) {
+	prevRoot := getProcRoot()
+	SetProcRoot(filepath.Join("testdata", "proc"))
+	defer SetProcRoot(prevRoot)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := getMemInfo(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func FuzzReadMemInfo(f *testing.F) {
+	f.Add(readProcFixture(f, "meminfo"))
+	f.Add([]byte("MemTotal:       16303428 kB\nMemFree:  "))
+	f.Add([]byte("MemTotal:       16303428 kB\nMemFree:         19546"))
+	f.Add([]byte("MemTotal:       163034\n"))
+	f.Add([]byte("MemTotal: 99999999999999999999999 kB\nMemFree: 1x kB\n"))
+	f.Add([]byte("MemTotal:\n:\nMemFree 1954660 kB\n"))
+	f.Fuzz(func(t *testing.T, content []byte) {
+		memInfo, _ := readMemInfo(content)
+		if memInfo.MemUsed > memInfo.MemTotal {
+			t.Errorf("MemUsed %d over MemTotal %d", memInfo.MemUsed, memInfo.MemTotal)
+		}
+		if memInfo.SwapUsed > memInfo.SwapTotal {
+			t.Errorf("SwapUsed %d over SwapTotal %d", memInfo.SwapUsed, memInfo.SwapTotal)
+		}
+	})
+}
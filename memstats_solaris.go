@@ -0,0 +1,92 @@
+// +build solaris
+
+package sysstats
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+)
+
+// getMemInfo gets the memory stats of an illumos/Solaris system from the
+// unix:0:system_pages and zfs:0:arcstats kstats (the ZFS ARC is reported as
+// Cached) and the swap space from `swap -s`. The statistics that don't exist
+// on illumos/Solaris (buffers, slab, dirty...) are always 0.
+func getMemInfo() (memInfo MemInfo, err error) {
+	kstats, err := getKstats(`unix:0:system_pages`, `zfs:0:arcstats:size`)
+	if err != nil {
+		return MemInfo{}, err
+	}
+
+	pageSize := uint64(os.Getpagesize()) / 1024
+	for _, k := range kstats {
+		switch {
+		case k.Module == `unix` && k.Name == `system_pages`:
+			physMem, err := k.uint(`physmem`)
+			if err != nil {
+				return MemInfo{}, err
+			}
+			freeMem, err := k.uint(`freemem`)
+			if err != nil {
+				return MemInfo{}, err
+			}
+			memInfo.MemTotal = physMem * pageSize
+			memInfo.MemFree = freeMem * pageSize
+		case k.Module == `zfs` && k.Name == `arcstats`:
+			arcSize, err := k.uint(`size`)
+			if err != nil {
+				return MemInfo{}, err
+			}
+			memInfo.Cached = arcSize / 1024
+		}
+	}
+	if memInfo.MemTotal == 0 {
+		return MemInfo{}, errors.New("Error getting the memory stats. The kstat unix:0:system_pages doesn't exist")
+	}
+	memInfo.MemUsed = memInfo.MemTotal - memInfo.MemFree
+	memInfo.RealFree = memInfo.MemFree + memInfo.Cached
+
+	out, err := exec.Command(`swap`, `-s`).Output()
+	if err != nil {
+		return MemInfo{}, err
+	}
+	if memInfo.SwapUsed, memInfo.SwapFree, err = parseSwapSummary(string(out)); err != nil {
+		return MemInfo{}, err
+	}
+	memInfo.SwapTotal = memInfo.SwapUsed + memInfo.SwapFree
+
+	return memInfo, nil
+}
+
+// reSwapSummary matches the output of `swap -s`:
+//   total: 129792k bytes allocated + 23744k reserved = 153536k used, 4719688k available
+var reSwapSummary = regexp.MustCompile(`=\s*(\d+)k used,\s*(\d+)k available`)
+
+// parseSwapSummary parses the output of `swap -s` and returns the swap space
+// used and available in kilobytes.
+func parseSwapSummary(out string) (used uint64, available uint64, err error) {
+	stats := reSwapSummary.FindStringSubmatch(out)
+	if stats == nil {
+		return 0, 0, errors.New("Error parsing the output of swap -s: " + out)
+	}
+	if used, err = strconv.ParseUint(stats[1], 10, 64); err != nil {
+		return 0, 0, err
+	}
+	if available, err = strconv.ParseUint(stats[2], 10, 64); err != nil {
+		return 0, 0, err
+	}
+
+	return used, available, nil
+}
+
+// getMemStats gets the memory stats of an illumos/Solaris system.
+func getMemStats() (memStats MemStats, err error) {
+	memInfo, err := getMemInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	return memInfo.ToMap(), nil
+}
@@ -0,0 +1,212 @@
+package sysstats
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Metric represents *one* value of a metric (e.g. the % of user CPU time of
+// cpu0), the common representation the exporters and the alerting rules work
+// with.
+//
+// Labels map keys depend on the metric: cpu (cpu.*), iface (net.*) and disk
+// (disk.*). Derived metrics don't have labels.
+type Metric struct {
+	Name    string            `json:"name"`    // Name of the metric (cpu.user, net.rxbytes, disk.readios...)
+	Labels  map[string]string `json:"labels"`  // Labels of the value
+	Value   float64           `json:"value"`   // Value
+	Derived bool              `json:"derived"` // Whether it's a user-defined derived metric
+}
+
+// DerivedMetricFunc computes the value of a derived metric from the
+// statistics between 2 snapshots.
+type DerivedMetricFunc func(stats SnapshotAvgStats) (float64, error)
+
+var (
+	derivedMetricsMu sync.RWMutex
+	derivedMetrics   = map[string]DerivedMetricFunc{}
+)
+
+// RegisterDerivedMetric registers a metric computed from the output of the
+// other collectors, e.g. the write/read bytes ratio of a disk:
+//   RegisterDerivedMetric("app.disk.writeratio", func(stats SnapshotAvgStats) (float64, error) {...})
+// The derived metrics are added to the metrics of every SnapshotAvgStats, so
+// they are exported and evaluated by the alerting rules like the native ones.
+// It returns an error if the name is empty, it's already registered or it's
+// the name of a native metric.
+func RegisterDerivedMetric(name string, fn DerivedMetricFunc) error {
+	if name == `` || fn == nil {
+		return errors.New("Derived metrics need a name and a function")
+	}
+	if isNativeMetric(name) {
+		return errors.New("Derived metric " + name + " has the name of a native metric")
+	}
+
+	derivedMetricsMu.Lock()
+	defer derivedMetricsMu.Unlock()
+	if _, ok := derivedMetrics[name]; ok {
+		return errors.New("Derived metric " + name + " is already registered")
+	}
+	derivedMetrics[name] = fn
+
+	return nil
+}
+
+// UnregisterDerivedMetric removes a derived metric from the registry.
+func UnregisterDerivedMetric(name string) {
+	derivedMetricsMu.Lock()
+	defer derivedMetricsMu.Unlock()
+	delete(derivedMetrics, name)
+}
+
+// DerivedMetrics returns the names (sorted) of the registered derived
+// metrics.
+func DerivedMetrics() (names []string) {
+	derivedMetricsMu.RLock()
+	defer derivedMetricsMu.RUnlock()
+
+	names = make([]string, 0, len(derivedMetrics))
+	for name := range derivedMetrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// isNativeMetric returns true if name is the name of a metric of
+// SnapshotAvgStats.
+func isNativeMetric(name string) bool {
+	for _, prefix := range []string{`cpu.`, `procs.`, `net.`, `disk.`} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Metrics returns the statistics between 2 snapshots as a list of metrics,
+// followed by the registered derived metrics (sorted by name). The derived
+// metrics that fail aren't returned; their errors are returned in errs
+// (indexed by metric name).
+func (stats SnapshotAvgStats) Metrics() (metrics []Metric, errs map[string]error) {
+	metrics = make([]Metric, 0, 256)
+
+	cpus := make([]string, 0, len(stats.Cpus))
+	for cpu := range stats.Cpus {
+		cpus = append(cpus, cpu)
+	}
+	sort.Strings(cpus)
+	for _, cpu := range cpus {
+		keys := make([]string, 0, len(stats.Cpus[cpu]))
+		for key := range stats.Cpus[cpu] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			metrics = append(metrics, Metric{Name: `cpu.` + key, Labels: map[string]string{`cpu`: cpu}, Value: stats.Cpus[cpu][key]})
+		}
+	}
+
+	for _, metric := range []Metric{
+		{Name: `procs.newprocs`, Value: stats.Procs.NewProcs},
+		{Name: `procs.running`, Value: float64(stats.Procs.Running)},
+		{Name: `procs.blocked`, Value: float64(stats.Procs.Blocked)},
+		{Name: `procs.runqueue`, Value: float64(stats.Procs.RunQueue)},
+		{Name: `procs.total`, Value: float64(stats.Procs.Total)},
+	} {
+		metric.Labels = map[string]string{}
+		metrics = append(metrics, metric)
+	}
+
+	metrics = append(metrics,
+		Metric{Name: `intr.total`, Labels: map[string]string{}, Value: stats.Irqs.Total},
+		Metric{Name: `intr.other`, Labels: map[string]string{}, Value: stats.Irqs.Other},
+	)
+	irqs := make([]string, 0, len(stats.Irqs.Irqs))
+	for irq := range stats.Irqs.Irqs {
+		irqs = append(irqs, irq)
+	}
+	// By number, not as strings
+	sort.Slice(irqs, func(i, j int) bool {
+		if len(irqs[i]) != len(irqs[j]) {
+			return len(irqs[i]) < len(irqs[j])
+		}
+		return irqs[i] < irqs[j]
+	})
+	for _, irq := range irqs {
+		metrics = append(metrics, Metric{Name: `intr.rate`, Labels: map[string]string{`irq`: irq}, Value: stats.Irqs.Irqs[irq]})
+	}
+	metrics = append(metrics, Metric{Name: `softirq.total`, Labels: map[string]string{}, Value: stats.Irqs.SoftirqTotal})
+	softirqs := make([]string, 0, len(stats.Irqs.Softirqs))
+	for softirq := range stats.Irqs.Softirqs {
+		softirqs = append(softirqs, softirq)
+	}
+	sort.Strings(softirqs)
+	for _, softirq := range softirqs {
+		metrics = append(metrics, Metric{Name: `softirq.rate`, Labels: map[string]string{`softirq`: softirq}, Value: stats.Irqs.Softirqs[softirq]})
+	}
+
+	ifaces := make([]string, 0, len(stats.Net))
+	for iface := range stats.Net {
+		ifaces = append(ifaces, iface)
+	}
+	sort.Strings(ifaces)
+	for _, iface := range ifaces {
+		keys := make([]string, 0, len(stats.Net[iface]))
+		for key := range stats.Net[iface] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			metrics = append(metrics, Metric{Name: `net.` + key, Labels: map[string]string{`iface`: iface}, Value: stats.Net[iface][key]})
+		}
+	}
+
+	for _, disk := range stats.Disks {
+		for _, metric := range []Metric{
+			{Name: `disk.readios`, Value: disk.ReadIOs},
+			{Name: `disk.readmerges`, Value: disk.ReadMerges},
+			{Name: `disk.readbytes`, Value: disk.ReadBytes},
+			{Name: `disk.writeios`, Value: disk.WriteIOs},
+			{Name: `disk.writemerges`, Value: disk.WriteMerges},
+			{Name: `disk.writebytes`, Value: disk.WriteBytes},
+			{Name: `disk.inflight`, Value: float64(disk.InFlight)},
+			{Name: `disk.ioticks`, Value: float64(disk.IOTicks)},
+			{Name: `disk.timeinqueue`, Value: float64(disk.TimeInQueue)},
+			{Name: `disk.util`, Value: disk.Util},
+			{Name: `disk.discardios`, Value: disk.DiscardIOs},
+			{Name: `disk.flushios`, Value: disk.FlushIOs},
+		} {
+			metric.Labels = map[string]string{`disk`: disk.Name}
+			metrics = append(metrics, metric)
+		}
+	}
+
+	// The functions are called without holding the lock, so they can use
+	// the registry
+	derivedMetricsMu.RLock()
+	names := make([]string, 0, len(derivedMetrics))
+	fns := make(map[string]DerivedMetricFunc, len(derivedMetrics))
+	for name, fn := range derivedMetrics {
+		names = append(names, name)
+		fns[name] = fn
+	}
+	derivedMetricsMu.RUnlock()
+	sort.Strings(names)
+
+	errs = map[string]error{}
+	for _, name := range names {
+		value, err := fns[name](stats)
+		if err != nil {
+			errs[name] = err
+			continue
+		}
+		metrics = append(metrics, Metric{Name: name, Labels: map[string]string{}, Value: value, Derived: true})
+	}
+
+	return metrics, errs
+}
@@ -0,0 +1,131 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// KernelModule represents *one* loaded kernel module.
+type KernelModule struct {
+	Name       string   `json:"name"`       // Name of the module
+	Size       uint64   `json:"size"`       // Memory size of the module (bytes)
+	RefCount   uint64   `json:"refcount"`   // # of references to the module
+	Dependents []string `json:"dependents"` // Modules that depend on (use) this module
+	State      string   `json:"state"`      // Load state (Live, Loading, Unloading)
+	TaintFlags string   `json:"taintflags"` // Taint flags of the module (e.g. OE), empty if none
+}
+
+// KernelTaint represents the taint status of the kernel.
+type KernelTaint struct {
+	Value   uint64   `json:"value"`   // Raw taint bitmask (/proc/sys/kernel/tainted)
+	Flags   string   `json:"flags"`   // Taint flags (as shown in oops messages, e.g. POE)
+	Reasons []string `json:"reasons"` // Description of every taint flag set
+}
+
+// kernelTaintFlags are the kernel taint flags indexed by bit (see
+// Documentation/admin-guide/tainted-kernels.rst).
+var kernelTaintFlags = []struct {
+	flag   byte
+	reason string
+}{
+	{'P', "proprietary module was loaded"},
+	{'F', "module was force loaded"},
+	{'S', "kernel running on an out of specification system"},
+	{'R', "module was force unloaded"},
+	{'M', "processor reported a Machine Check Exception"},
+	{'B', "bad page referenced or some unexpected page flags"},
+	{'U', "taint requested by userspace application"},
+	{'D', "kernel died recently, i.e. there was an OOPS or BUG"},
+	{'A', "ACPI table overridden by user"},
+	{'W', "kernel issued warning"},
+	{'C', "staging driver was loaded"},
+	{'I', "workaround for bug in platform firmware applied"},
+	{'O', "externally-built (out-of-tree) module was loaded"},
+	{'E', "unsigned module was loaded"},
+	{'L', "soft lockup occurred"},
+	{'K', "kernel has been live patched"},
+	{'X', "auxiliary taint, defined for and used by distros"},
+	{'T', "kernel was built with the struct randomization plugin"},
+	{'N', "an in-kernel test has been run"},
+}
+
+// getKernelModules gets the loaded kernel modules of a linux system from the
+// file /proc/modules.
+func getKernelModules() (modules []KernelModule, err error) {
+	file, err := getProcReader().open("modules")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	modules = make([]KernelModule, 0, 64)
+
+	scanner := bufio.NewScanner(file)
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		module, err := parseKernelModule(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		modules = append(modules, module)
+	}
+
+	return modules, nil
+}
+
+// parseKernelModule parses *one* line of /proc/modules, which has the
+// following format:
+//   nf_nat 49152 2 xt_MASQUERADE,nft_chain_nat, Live 0xffffffffc0a5e000
+//   vboxdrv 487424 2 vboxnetadp,vboxnetflt, Live 0xffffffffc0ff2000 (OE)
+func parseKernelModule(line string) (module KernelModule, err error) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 {
+		return KernelModule{}, errors.New("Error parsing file /proc/modules. It should have at least 5 fields")
+	}
+
+	module = KernelModule{Name: fields[0], Dependents: []string{}, State: fields[4]}
+	if module.Size, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+		return KernelModule{}, err
+	}
+	if module.RefCount, err = strconv.ParseUint(fields[2], 10, 64); err != nil {
+		// Modules without unload support have '-' as refcount
+		module.RefCount = 0
+	}
+	if fields[3] != `-` {
+		for _, dependent := range strings.Split(fields[3], `,`) {
+			if dependent != `` {
+				module.Dependents = append(module.Dependents, dependent)
+			}
+		}
+	}
+	if last := fields[len(fields)-1]; strings.HasPrefix(last, `(`) && strings.HasSuffix(last, `)`) {
+		module.TaintFlags = strings.Trim(last, `()`)
+	}
+
+	return module, nil
+}
+
+// getKernelTaint gets the taint status of the kernel from the file
+// /proc/sys/kernel/tainted.
+func getKernelTaint() (kernelTaint KernelTaint, err error) {
+	value, err := getProcReader().readUint("sys", "kernel", "tainted")
+	if err != nil {
+		return KernelTaint{}, err
+	}
+
+	kernelTaint = KernelTaint{Value: value, Reasons: []string{}}
+	flags := make([]byte, 0, len(kernelTaintFlags))
+	for bit, taint := range kernelTaintFlags {
+		if value&(1<<uint(bit)) != 0 {
+			flags = append(flags, taint.flag)
+			kernelTaint.Reasons = append(kernelTaint.Reasons, taint.reason)
+		}
+	}
+	kernelTaint.Flags = string(flags)
+
+	return kernelTaint, nil
+}
@@ -0,0 +1,152 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// MountInfo represents *one* mount of a linux system as it is in
+// /proc/self/mountinfo.
+type MountInfo struct {
+	MountId      int      `json:"mountid"`      // Unique id of the mount
+	ParentId     int      `json:"parentid"`     // Id of the parent mount
+	Major        int      `json:"major"`        // Major number of the device
+	Minor        int      `json:"minor"`        // Minor number of the device
+	Root         string   `json:"root"`         // Root of the mount within the filesystem
+	MountPoint   string   `json:"mountpoint"`   // Mount point
+	MountOptions []string `json:"mountoptions"` // Per-mount options (rw, noatime...)
+	Optional     []string `json:"optional"`     // Optional fields (shared:X, master:X...)
+	FsType       string   `json:"fstype"`       // File system type
+	Source       string   `json:"source"`       // Mount source (device, server:/export...)
+	SuperOptions []string `json:"superoptions"` // Per-superblock options
+}
+
+// getMountInfo gets the mounts of a linux system from the file
+// /proc/self/mountinfo, up to Limits.MaxMounts.
+func getMountInfo() (mounts []MountInfo, err error) {
+	file, err := getProcReader().open("self", "mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	mounts = make([]MountInfo, 0, 32)
+
+	scanner := bufio.NewScanner(file)
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		if overLimit(len(mounts), limits.MaxMounts, &truncations.Mounts) {
+			break
+		}
+		mount, err := parseMountInfo(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		mounts = append(mounts, mount)
+	}
+	// A line longer than the buffer of the scanner (e.g. a corrupted file)
+	// stops it
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return mounts, nil
+}
+
+// parseMountInfo parses *one* line of /proc/self/mountinfo, which has the
+// following format:
+//   36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+// Spaces, tabs, new lines and backslashes in the paths are escaped as octal
+// sequences (\040, \011, \012, \134).
+func parseMountInfo(line string) (mount MountInfo, err error) {
+	fields := strings.Fields(line)
+
+	// Look for the separator of the optional fields
+	sep := -1
+	for i := 6; i < len(fields); i++ {
+		if fields[i] == `-` {
+			sep = i
+			break
+		}
+	}
+	if sep < 0 || len(fields) < sep+3 {
+		return MountInfo{}, errors.New("Error parsing file /proc/self/mountinfo. Unexpected line: " + line)
+	}
+
+	if mount.MountId, err = strconv.Atoi(fields[0]); err != nil {
+		return MountInfo{}, err
+	}
+	if mount.ParentId, err = strconv.Atoi(fields[1]); err != nil {
+		return MountInfo{}, err
+	}
+	devNum := strings.SplitN(fields[2], `:`, 2)
+	if len(devNum) != 2 {
+		return MountInfo{}, errors.New("Error parsing file /proc/self/mountinfo. Unexpected device: " + fields[2])
+	}
+	if mount.Major, err = strconv.Atoi(devNum[0]); err != nil {
+		return MountInfo{}, err
+	}
+	if mount.Minor, err = strconv.Atoi(devNum[1]); err != nil {
+		return MountInfo{}, err
+	}
+	mount.Root = unescapeMountField(fields[3])
+	mount.MountPoint = unescapeMountField(fields[4])
+	mount.MountOptions = strings.Split(fields[5], `,`)
+	mount.Optional = append([]string{}, fields[6:sep]...)
+	mount.FsType = fields[sep+1]
+	mount.Source = unescapeMountField(fields[sep+2])
+	if len(fields) > sep+3 {
+		mount.SuperOptions = strings.Split(fields[sep+3], `,`)
+	}
+
+	return mount, nil
+}
+
+// HasOption returns true if the mount (or its superblock) has the given
+// option (e.g. ro).
+func (mount MountInfo) HasOption(option string) bool {
+	_, ok := mount.Option(option)
+	return ok
+}
+
+// Option returns the value of a mount (or superblock) option (e.g. the value
+// of upperdir=/x is /x). The value of options without value is empty.
+func (mount MountInfo) Option(option string) (value string, ok bool) {
+	for _, options := range [][]string{mount.MountOptions, mount.SuperOptions} {
+		for _, o := range options {
+			if o == option {
+				return ``, true
+			}
+			if strings.HasPrefix(o, option+`=`) {
+				return unescapeMountField(o[len(option)+1:]), true
+			}
+		}
+	}
+
+	return ``, false
+}
+
+// IsFuse returns true if the mount is a FUSE file system (sshfs, rclone,
+// gvfs, ntfs-3g...).
+func (mount MountInfo) IsFuse() bool {
+	return mount.FsType == `fuse` || mount.FsType == `fuseblk` || strings.HasPrefix(mount.FsType, `fuse.`)
+}
+
+// FuseType returns the name of the FUSE file system of the mount (e.g. sshfs
+// for fuse.sshfs). The mounts without subtype (fuse, fuseblk) are classified
+// by their source (e.g. gvfsd-fuse). It returns an empty string if the mount
+// isn't a FUSE file system.
+func (mount MountInfo) FuseType() string {
+	if !mount.IsFuse() {
+		return ``
+	}
+	if strings.HasPrefix(mount.FsType, `fuse.`) {
+		return strings.TrimPrefix(mount.FsType, `fuse.`)
+	}
+
+	return mount.Source
+}
@@ -0,0 +1,63 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseMountInfoEscapes(t *testing.T) {
+	mount, err := parseMountInfo(`412 28 0:48 /a\134b /media/My\040Disk\011\012 rw shared:221 - fuseblk /dev/disk\040one rw,user_id=0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mount.Root != `/a\b` || mount.MountPoint != "/media/My Disk\t\n" || mount.Source != `/dev/disk one` {
+		t.Errorf("Unescaped fields %q, %q and %q", mount.Root, mount.MountPoint, mount.Source)
+	}
+}
+
+func TestGetMountInfoLongLine(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "self"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// A line longer than the buffer of the scanner (64 KiB)
+	content := "22 1 259:2 / / rw - ext4 /dev/nvme0n1p2 rw\n23 22 0:5 / /" + strings.Repeat("a", 70000) + " rw - tmpfs tmpfs rw\n"
+	if err := ioutil.WriteFile(filepath.Join(root, "self", "mountinfo"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	prevRoot := getProcRoot()
+	SetProcRoot(root)
+	defer SetProcRoot(prevRoot)
+
+	if mounts, err := getMountInfo(); !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("Mounts %v and error %v, want %v", mounts, err, bufio.ErrTooLong)
+	}
+}
+
+func FuzzParseMountInfo(f *testing.F) {
+	for _, line := range strings.Split(string(readProcFixture(f, "self", "mountinfo")), "\n") {
+		f.Add(line)
+	}
+	f.Add(`412 28 0:48 / /media/My\040Disk rw,nosuid shared:221 - fuseblk /dev/sda1`)
+	f.Add(`412 28 0:48 / /media/My\040Disk rw,nosuid shared:221 - fuseblk`)
+	f.Add(`412 28 0:48 / /media/My\04`)
+	f.Add(`412 28 0:48 / /mnt\040 rw - ext4 /dev/my\134disk\012 rw`)
+	f.Add(`412 28 0: / / rw - - - -`)
+	f.Add(`- - - - - - - - -`)
+	f.Fuzz(func(t *testing.T, line string) {
+		mount, err := parseMountInfo(line)
+		if err != nil {
+			return
+		}
+		if mount.FsType == `` || len(mount.MountOptions) == 0 {
+			t.Errorf("Mount without a file system type or options: %+v", mount)
+		}
+	})
+}
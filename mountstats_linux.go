@@ -0,0 +1,244 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// NfsOpStats represents the RPC statistics of *one* NFS operation (READ,
+// WRITE, GETATTR...) on a mount.
+type NfsOpStats struct {
+	Ops           uint64  `json:"ops"`           // # of operations requested
+	Transmissions uint64  `json:"transmissions"` // # of times the operation was transmitted (includes retransmissions)
+	MajorTimeouts uint64  `json:"majortimeouts"` // # of major timeouts
+	BytesSent     uint64  `json:"bytessent"`     // # of bytes sent (including RPC headers)
+	BytesRecv     uint64  `json:"bytesrecv"`     // # of bytes received (including RPC headers)
+	QueueTime     uint64  `json:"queuetime"`     // Cumulative time waiting in queue before transmission (milliseconds)
+	RttTime       uint64  `json:"rtttime"`       // Cumulative round trip time (milliseconds)
+	ExecuteTime   uint64  `json:"executetime"`   // Cumulative time from queueing to completion (milliseconds)
+	Errors        uint64  `json:"errors"`        // # of operations that completed with an error (statvers >= 1.1)
+	AvgRtt        float64 `json:"avgrtt"`        // Average round trip time per operation (milliseconds)
+	AvgExecute    float64 `json:"avgexecute"`    // Average execution time per operation (milliseconds)
+}
+
+// MountStats represents the I/O statistics of *one* mount from
+// /proc/self/mountstats. Only NFS mounts have events, bytes, transport and
+// per-op statistics.
+//
+// Events map keys:
+//   inoderevalidates, dentryrevalidates, datainvalidates, attrinvalidates,
+//   vfsopen, vfslookup, vfsaccess, vfsupdatepage, vfsreadpage, vfsreadpages,
+//   vfswritepage, vfswritepages, vfsgetdents, vfssetattr, vfsflush, vfsfsync,
+//   vfslock, vfsrelease, congestionwait, setattrtrunc, extendwrite,
+//   sillyrename, shortread, shortwrite, delay, pnfsread, pnfswrite.
+// Bytes map keys:
+//   normalread, normalwrite, directread, directwrite, serverread, serverwrite,
+//   readpages, writepages.
+// Transport map keys (depending on the protocol):
+//   port, bindcount, connectcount, connecttime, idletime, sends, recvs,
+//   badxids, requ, bklogu, maxslots, sendingu, pendingu.
+type MountStats struct {
+	Device     string                `json:"device"`     // Mounted device (server:/export for NFS)
+	MountPoint string                `json:"mountpoint"` // Mount point
+	FsType     string                `json:"fstype"`     // File system type
+	StatVers   string                `json:"statvers"`   // Statistics format version (NFS only)
+	Age        uint64                `json:"age"`        // Seconds since the mount was done (NFS only)
+	Protocol   string                `json:"protocol"`   // RPC transport protocol (tcp, udp, rdma)
+	Events     map[string]uint64     `json:"events"`     // NFS client events
+	Bytes      map[string]uint64     `json:"bytes"`      // NFS client byte counters
+	Transport  map[string]uint64     `json:"transport"`  // RPC transport counters
+	Ops        map[string]NfsOpStats `json:"ops"`        // Per-op statistics
+}
+
+var (
+	nfsEventKeys = []string{`inoderevalidates`, `dentryrevalidates`, `datainvalidates`,
+		`attrinvalidates`, `vfsopen`, `vfslookup`, `vfsaccess`, `vfsupdatepage`,
+		`vfsreadpage`, `vfsreadpages`, `vfswritepage`, `vfswritepages`, `vfsgetdents`,
+		`vfssetattr`, `vfsflush`, `vfsfsync`, `vfslock`, `vfsrelease`, `congestionwait`,
+		`setattrtrunc`, `extendwrite`, `sillyrename`, `shortread`, `shortwrite`, `delay`,
+		`pnfsread`, `pnfswrite`}
+	nfsBytesKeys = []string{`normalread`, `normalwrite`, `directread`, `directwrite`,
+		`serverread`, `serverwrite`, `readpages`, `writepages`}
+	nfsXprtKeys = map[string][]string{
+		`tcp`:  {`port`, `bindcount`, `connectcount`, `connecttime`, `idletime`, `sends`, `recvs`, `badxids`, `requ`, `bklogu`, `maxslots`, `sendingu`, `pendingu`},
+		`rdma`: {`port`, `bindcount`, `connectcount`, `connecttime`, `idletime`, `sends`, `recvs`, `badxids`, `requ`, `bklogu`},
+		`udp`:  {`port`, `bindcount`, `sends`, `recvs`, `badxids`, `requ`, `bklogu`, `maxslots`, `sendingu`, `pendingu`},
+	}
+)
+
+// getMountStats gets the per-mount I/O statistics of a linux system from the
+// file /proc/self/mountstats.
+func getMountStats() (mountStatsArr []MountStats, err error) {
+	file, err := getProcReader().open("self", "mountstats")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	mountStatsArr = make([]MountStats, 0, 16)
+	var mountStats *MountStats
+	inOps := false
+
+	scanner := bufio.NewScanner(file)
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if fields[0] == `device` {
+			m, err := parseMountStatsDevice(fields)
+			if err != nil {
+				return nil, err
+			}
+			mountStatsArr = append(mountStatsArr, m)
+			mountStats = &mountStatsArr[len(mountStatsArr)-1]
+			inOps = false
+			continue
+		}
+		if mountStats == nil {
+			continue
+		}
+
+		switch {
+		case fields[0] == `age:` && len(fields) == 2:
+			if mountStats.Age, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+				return nil, err
+			}
+		case fields[0] == `events:`:
+			if mountStats.Events, err = parseMountStatsCounters(fields[1:], nfsEventKeys); err != nil {
+				return nil, err
+			}
+		case fields[0] == `bytes:`:
+			if mountStats.Bytes, err = parseMountStatsCounters(fields[1:], nfsBytesKeys); err != nil {
+				return nil, err
+			}
+		case fields[0] == `xprt:` && len(fields) > 1:
+			mountStats.Protocol = fields[1]
+			if mountStats.Transport, err = parseMountStatsCounters(fields[2:], nfsXprtKeys[fields[1]]); err != nil {
+				return nil, err
+			}
+		case fields[0] == `per-op`:
+			inOps = true
+			mountStats.Ops = map[string]NfsOpStats{}
+		case inOps && strings.HasSuffix(fields[0], `:`):
+			op, err := parseNfsOpStats(fields[1:])
+			if err != nil {
+				return nil, err
+			}
+			mountStats.Ops[strings.TrimSuffix(fields[0], `:`)] = op
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return mountStatsArr, nil
+}
+
+// parseMountStatsDevice parses the first line of a mount in
+// /proc/self/mountstats:
+//   device 10.0.0.1:/export mounted on /mnt/nfs with fstype nfs4 statvers=1.1
+func parseMountStatsDevice(fields []string) (mountStats MountStats, err error) {
+	// Mounts without device are reported as "device no device mounted on..."
+	if len(fields) > 2 && fields[1] == `no` && fields[2] == `device` {
+		fields = append([]string{`device`, `none`}, fields[3:]...)
+	}
+	if len(fields) < 8 || fields[2] != `mounted` || fields[3] != `on` ||
+		fields[5] != `with` || fields[6] != `fstype` {
+		return MountStats{}, errors.New("Error parsing file /proc/self/mountstats. Unexpected device line")
+	}
+
+	// Spaces in the device and mount point are escaped (\040)
+	mountStats = MountStats{
+		Device:     unescapeMountField(fields[1]),
+		MountPoint: unescapeMountField(fields[4]),
+		FsType:     fields[7],
+	}
+	if len(fields) > 8 {
+		mountStats.StatVers = strings.TrimPrefix(fields[8], `statvers=`)
+	}
+
+	return mountStats, nil
+}
+
+// parseMountStatsCounters parses a list of counters and assigns them the
+// given keys. Counters without a key (newer kernels may add more) are ignored.
+func parseMountStatsCounters(values []string, keys []string) (counters map[string]uint64, err error) {
+	counters = make(map[string]uint64, len(keys))
+	for i, value := range values {
+		if i >= len(keys) {
+			break
+		}
+		counter, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		counters[keys[i]] = counter
+	}
+
+	return counters, nil
+}
+
+// parseNfsOpStats parses the statistics of *one* NFS operation:
+//   READ: 1602 1602 0 230608 104917436 43 6009 6128 0
+func parseNfsOpStats(values []string) (op NfsOpStats, err error) {
+	if len(values) < 8 {
+		return NfsOpStats{}, errors.New("Error parsing file /proc/self/mountstats. Per-op statistics should have at least 8 fields")
+	}
+
+	counters := make([]uint64, 9)
+	for i := 0; i < len(values) && i < len(counters); i++ {
+		if counters[i], err = strconv.ParseUint(values[i], 10, 64); err != nil {
+			return NfsOpStats{}, err
+		}
+	}
+
+	op = NfsOpStats{
+		Ops:           counters[0],
+		Transmissions: counters[1],
+		MajorTimeouts: counters[2],
+		BytesSent:     counters[3],
+		BytesRecv:     counters[4],
+		QueueTime:     counters[5],
+		RttTime:       counters[6],
+		ExecuteTime:   counters[7],
+		Errors:        counters[8],
+	}
+	if op.Ops > 0 {
+		op.AvgRtt = float64(op.RttTime) / float64(op.Ops)
+		op.AvgExecute = float64(op.ExecuteTime) / float64(op.Ops)
+	}
+
+	return op, nil
+}
+
+// unescapeMountField replaces the octal escapes (\040, \011, \012, \134) the
+// kernel uses for spaces, tabs, new lines and backslashes in mount fields.
+func unescapeMountField(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		// A valid escape has 3 octal digits (up to \377), the others are
+		// kept as they are
+		if field[i] == '\\' && i+4 <= len(field) {
+			if value, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(value))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(field[i])
+	}
+
+	return b.String()
+}
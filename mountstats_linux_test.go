@@ -0,0 +1,57 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnescapeMountField(t *testing.T) {
+	tests := []struct {
+		field string
+		want  string
+	}{
+		{`/mnt`, `/mnt`},
+		{`/media/My\040Disk`, `/media/My Disk`},
+		// The escapes at the end of the field
+		{`/mnt\040`, `/mnt `},
+		{`/mnt\012`, "/mnt\n"},
+		{`\040`, ` `},
+		{`/a\134`, `/a\`},
+		// The invalid escapes are kept as they are
+		{`/mnt\04`, `/mnt\04`},
+		{`/mnt\400`, `/mnt\400`},
+		{`/mnt\`, `/mnt\`},
+		{`/mnt\x40`, `/mnt\x40`},
+	}
+	for _, test := range tests {
+		if field := unescapeMountField(test.field); field != test.want {
+			t.Errorf("unescapeMountField(%q) = %q, want %q", test.field, field, test.want)
+		}
+	}
+}
+
+func TestGetMountStatsLongLine(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "self"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// A line longer than the buffer of the scanner (64 KiB)
+	content := "device 10.0.0.1:/export mounted on /mnt/nfs with fstype nfs4 statvers=1.1\n\tevents:" + strings.Repeat(" 1", 40000) + "\n"
+	if err := ioutil.WriteFile(filepath.Join(root, "self", "mountstats"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	prevRoot := getProcRoot()
+	SetProcRoot(root)
+	defer SetProcRoot(prevRoot)
+
+	if mountStatsArr, err := getMountStats(); !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("Mount stats %v and error %v, want %v", mountStatsArr, err, bufio.ErrTooLong)
+	}
+}
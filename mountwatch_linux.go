@@ -0,0 +1,113 @@
+// +build linux
+
+package sysstats
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mount event kinds
+const (
+	MountEventMounted   = "mounted"   // A new mount appeared
+	MountEventUnmounted = "unmounted" // A mount disappeared
+	MountEventReadOnly  = "readonly"  // A mount flipped to read-only
+	MountEventReadWrite = "readwrite" // A mount flipped to read-write
+	MountEventRemounted = "remounted" // The options of a mount changed
+)
+
+// MountEvent represents *one* change of the mounts of a linux system.
+type MountEvent struct {
+	Kind       string    `json:"kind"`       // Kind of event (mounted, unmounted, readonly, readwrite, remounted)
+	Time       time.Time `json:"time"`       // Time the change was detected
+	MountPoint string    `json:"mountpoint"` // Mount point
+	Source     string    `json:"source"`     // Mount source (device, server:/export...)
+	FsType     string    `json:"fstype"`     // File system type
+	Before     string    `json:"before"`     // Mount and superblock options before the change
+	After      string    `json:"after"`      // Mount and superblock options after the change
+}
+
+// MountWatcher watches the mounts of a linux system and reports the changes
+// between calls to Check. Its main use is detecting the file systems that
+// flip to read-only (e.g. ext4 with errors=remount-ro after storage errors),
+// which otherwise only shows up as write errors in the applications.
+type MountWatcher struct {
+	mu     sync.Mutex
+	mounts map[int]MountInfo
+}
+
+// NewMountWatcher returns a MountWatcher.
+func NewMountWatcher() *MountWatcher {
+	return &MountWatcher{}
+}
+
+// Check returns the changes of the mounts (read from /proc/self/mountinfo)
+// since the previous call. The first call doesn't return any event.
+func (w *MountWatcher) Check() (events []MountEvent, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	mounts, err := getMountInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	current := make(map[int]MountInfo, len(mounts))
+	events = []MountEvent{}
+	for _, mount := range mounts {
+		current[mount.MountId] = mount
+		if w.mounts == nil {
+			continue
+		}
+
+		previous, ok := w.mounts[mount.MountId]
+		if !ok {
+			events = append(events, newMountEvent(MountEventMounted, now, MountInfo{}, mount))
+			continue
+		}
+		switch {
+		case !previous.HasOption(`ro`) && mount.HasOption(`ro`):
+			events = append(events, newMountEvent(MountEventReadOnly, now, previous, mount))
+		case previous.HasOption(`ro`) && !mount.HasOption(`ro`):
+			events = append(events, newMountEvent(MountEventReadWrite, now, previous, mount))
+		case mountOptions(previous) != mountOptions(mount):
+			events = append(events, newMountEvent(MountEventRemounted, now, previous, mount))
+		}
+	}
+	// The mounts of the previous check that are gone were unmounted
+	if w.mounts != nil {
+		for _, previous := range w.mounts {
+			if _, ok := current[previous.MountId]; !ok {
+				events = append(events, newMountEvent(MountEventUnmounted, now, previous, MountInfo{}))
+			}
+		}
+	}
+	w.mounts = current
+
+	return events, nil
+}
+
+// newMountEvent returns an event of the change of a mount from previous to
+// mount. previous (or mount) is empty if the mount appeared (or disappeared).
+func newMountEvent(kind string, now time.Time, previous MountInfo, mount MountInfo) MountEvent {
+	event := MountEvent{Kind: kind, Time: now, Before: mountOptions(previous), After: mountOptions(mount)}
+	info := mount
+	if kind == MountEventUnmounted {
+		info = previous
+	}
+	event.MountPoint, event.Source, event.FsType = info.MountPoint, info.Source, info.FsType
+
+	return event
+}
+
+// mountOptions returns the mount and superblock options of a mount as they
+// are in /proc/self/mountinfo (e.g. rw,noatime rw,errors=remount-ro).
+func mountOptions(mount MountInfo) string {
+	if mount.MountOptions == nil && mount.SuperOptions == nil {
+		return ``
+	}
+
+	return strings.Join(mount.MountOptions, `,`) + ` ` + strings.Join(mount.SuperOptions, `,`)
+}
@@ -0,0 +1,211 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MultipathPath represents *one* path of a dm-multipath map.
+type MultipathPath struct {
+	Device       string `json:"device"`       // Block device of the path (sdb...)
+	DevNum       string `json:"devnum"`       // Device major:minor
+	Group        int    `json:"group"`        // Path group the path belongs to (starting at 1)
+	Active       bool   `json:"active"`       // Whether the path is active (false if it's failed)
+	FailCount    uint64 `json:"failcount"`    // # of times the path has failed
+	CheckerState string `json:"checkerstate"` // Path checker state (ready, faulty, ghost...). Only if `multipathd` is available
+}
+
+// MultipathMap represents the health of *one* dm-multipath map (LUN).
+type MultipathMap struct {
+	Name        string          `json:"name"`        // Name of the map (mpatha...)
+	ActiveGroup int             `json:"activegroup"` // Path group currently used for I/O
+	ActivePaths int             `json:"activepaths"` // # of active paths
+	FailedPaths int             `json:"failedpaths"` // # of failed paths
+	Paths       []MultipathPath `json:"paths"`       // Paths of the map
+}
+
+// getMultipathMaps gets the dm-multipath maps of a linux system running the
+// command:
+//   dmsetup status --target multipath
+// The path checker states are got running (skipped if `multipathd` isn't
+// installed):
+//   multipathd show paths format "%d %T"
+func getMultipathMaps() (maps []MultipathMap, err error) {
+	dmsetup, err := exec.LookPath("dmsetup")
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := exec.Command(dmsetup, "status", "--target", "multipath").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	checkerStates := getMultipathCheckerStates()
+
+	maps = make([]MultipathMap, 0, 4)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == `No devices found` {
+			break
+		}
+		m, err := parseMultipathStatus(line)
+		if err != nil {
+			return nil, err
+		}
+		for i := range m.Paths {
+			m.Paths[i].Device = devNumToName(m.Paths[i].DevNum)
+			m.Paths[i].CheckerState = checkerStates[m.Paths[i].Device]
+		}
+		maps = append(maps, m)
+	}
+
+	return maps, nil
+}
+
+// parseMultipathStatus parses *one* line of `dmsetup status --target
+// multipath`, that has the following format:
+//   mpatha: 0 2097152 multipath 2 0 0 0 2 1 A 0 1 2 8:16 A 0 0 1 E 0 1 2 8:32 F 1 0 1
+// where after the target type there are:
+//   <#features> <features...> <#handler args> <handler args...>
+//   <#groups> <active group>
+//   <group state> <#group args> <group args...> <#paths> <#selector args>
+//     <path> <A|F> <fail count> <selector args...>
+func parseMultipathStatus(status string) (m MultipathMap, err error) {
+	fields := strings.Fields(status)
+	if len(fields) < 4 || fields[3] != `multipath` {
+		return MultipathMap{}, errors.New("Couldn't parse multipath status: " + status)
+	}
+	m.Name = strings.TrimSuffix(fields[0], `:`)
+
+	args := fields[4:]
+	pos := 0
+	next := func() (int, error) {
+		if pos >= len(args) {
+			return 0, errors.New("Couldn't parse multipath status of " + m.Name + ": truncated line")
+		}
+		value, err := strconv.Atoi(args[pos])
+		pos++
+		return value, err
+	}
+	skip := func(n int) error {
+		pos += n
+		if n < 0 || pos > len(args) {
+			return errors.New("Couldn't parse multipath status of " + m.Name + ": truncated line")
+		}
+		return nil
+	}
+
+	// Features and hardware handler
+	for i := 0; i < 2; i++ {
+		n, err := next()
+		if err != nil {
+			return MultipathMap{}, err
+		}
+		if err := skip(n); err != nil {
+			return MultipathMap{}, err
+		}
+	}
+
+	groups, err := next()
+	if err != nil {
+		return MultipathMap{}, err
+	}
+	if m.ActiveGroup, err = next(); err != nil {
+		return MultipathMap{}, err
+	}
+
+	m.Paths = make([]MultipathPath, 0, 2*groups)
+	for group := 1; group <= groups; group++ {
+		// Group state (A, E or D)
+		if err := skip(1); err != nil {
+			return MultipathMap{}, err
+		}
+		n, err := next()
+		if err != nil {
+			return MultipathMap{}, err
+		}
+		if err := skip(n); err != nil {
+			return MultipathMap{}, err
+		}
+		paths, err := next()
+		if err != nil {
+			return MultipathMap{}, err
+		}
+		selectorArgs, err := next()
+		if err != nil {
+			return MultipathMap{}, err
+		}
+		for i := 0; i < paths; i++ {
+			if pos+3 > len(args) {
+				return MultipathMap{}, errors.New("Couldn't parse multipath status of " + m.Name + ": truncated line")
+			}
+			path := MultipathPath{DevNum: args[pos], Group: group, Active: args[pos+1] == `A`}
+			if path.FailCount, err = strconv.ParseUint(args[pos+2], 10, 64); err != nil {
+				return MultipathMap{}, err
+			}
+			pos += 3
+			if err := skip(selectorArgs); err != nil {
+				return MultipathMap{}, err
+			}
+			if path.Active {
+				m.ActivePaths++
+			} else {
+				m.FailedPaths++
+			}
+			m.Paths = append(m.Paths, path)
+		}
+	}
+
+	return m, nil
+}
+
+// getMultipathCheckerStates returns the path checker state of each path
+// device. The map is empty if `multipathd` isn't installed or running.
+func getMultipathCheckerStates() (states map[string]string) {
+	states = map[string]string{}
+
+	multipathd, err := exec.LookPath("multipathd")
+	if err != nil {
+		return states
+	}
+
+	out, err := exec.Command(multipathd, "show", "paths", "format", "%d %T").Output()
+	if err != nil {
+		return states
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Split(bufio.ScanLines)
+	// Filter the header
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 {
+			states[fields[0]] = fields[1]
+		}
+	}
+
+	return states
+}
+
+// devNumToName returns the name of the block device major:minor using
+// /sys/dev/block. It returns devNum if it can't be resolved.
+func devNumToName(devNum string) string {
+	target, err := os.Readlink(filepath.Join("/sys/dev/block", devNum))
+	if err != nil {
+		return devNum
+	}
+
+	return filepath.Base(target)
+}
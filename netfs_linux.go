@@ -0,0 +1,275 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CifsOpStats represents the counters of *one* CIFS/SMB operation type.
+type CifsOpStats struct {
+	Total  uint64 `json:"total"`  // # of requests sent
+	Failed uint64 `json:"failed"` // # of failed requests
+}
+
+// CifsShareStats represents the client statistics of *one* CIFS/SMB share.
+//
+// Ops map keys are the operation names as they are in /proc/fs/cifs/Stats
+// (Creates, Closes, Reads, Writes, Locks, Flushes...).
+type CifsShareStats struct {
+	Share        string                 `json:"share"`        // UNC path of the share (\\server\share)
+	Smbs         uint64                 `json:"smbs"`         // # of SMBs sent to the share
+	BytesRead    uint64                 `json:"bytesread"`    // # of bytes read from the share
+	BytesWritten uint64                 `json:"byteswritten"` // # of bytes written to the share
+	Ops          map[string]CifsOpStats `json:"ops"`          // Per operation counters
+}
+
+// CephLatency represents the latency metrics of *one* CephFS operation type.
+type CephLatency struct {
+	Total      uint64  `json:"total"`      // # of operations
+	AvgLatency float64 `json:"avglatency"` // Average latency (microseconds)
+	MinLatency float64 `json:"minlatency"` // Minimum latency (microseconds)
+	MaxLatency float64 `json:"maxlatency"` // Maximum latency (microseconds)
+}
+
+// CephClientStats represents the statistics of *one* kernel Ceph client.
+//
+// Latency map keys are the operation types (read, write, metadata).
+type CephClientStats struct {
+	Client              string                 `json:"client"`              // Client name (<fsid>.client<id>)
+	InFlightOsdRequests uint64                 `json:"inflightosdrequests"` // # of requests in flight to OSDs
+	InFlightMdsRequests uint64                 `json:"inflightmdsrequests"` // # of requests in flight to MDSs
+	Latency             map[string]CephLatency `json:"latency"`             // Per operation latency metrics
+}
+
+// RbdDevice represents *one* mapped RBD image.
+type RbdDevice struct {
+	Id     string `json:"id"`     // RBD device id
+	Device string `json:"device"` // Block device name (rbd0...). Its I/O stats are in DiskRawStats
+	Pool   string `json:"pool"`   // Pool of the image
+	Image  string `json:"image"`  // Name of the image
+	Snap   string `json:"snap"`   // Snapshot mapped (- if none)
+}
+
+// NetFsClientStats represents the network filesystem clients statistics of a
+// linux system.
+type NetFsClientStats struct {
+	Cifs []CifsShareStats  `json:"cifs"` // CIFS/SMB shares
+	Ceph []CephClientStats `json:"ceph"` // Kernel Ceph clients (needs debugfs access)
+	Rbd  []RbdDevice       `json:"rbd"`  // Mapped RBD images
+}
+
+// getNetFsClientStats gets the network filesystem clients statistics of a
+// linux system from /proc/fs/cifs/Stats, /sys/kernel/debug/ceph and
+// /sys/bus/rbd/devices. The clients not loaded (or not readable) are empty.
+func getNetFsClientStats() (netFsClientStats NetFsClientStats, err error) {
+	netFsClientStats = NetFsClientStats{}
+
+	content, err := getProcReader().readFile("fs", "cifs", "Stats")
+	if err != nil && !os.IsNotExist(err) {
+		return NetFsClientStats{}, err
+	}
+	if netFsClientStats.Cifs, err = parseCifsStats(content); err != nil {
+		return NetFsClientStats{}, err
+	}
+
+	if netFsClientStats.Ceph, err = getCephClientStats(); err != nil {
+		return NetFsClientStats{}, err
+	}
+
+	if netFsClientStats.Rbd, err = getRbdDevices(); err != nil {
+		return NetFsClientStats{}, err
+	}
+
+	return netFsClientStats, nil
+}
+
+var (
+	reCifsShare   = regexp.MustCompile(`^\d+\)\s+(\S+)`)
+	reCifsSmbs    = regexp.MustCompile(`^SMBs:\s+(\d+)`)
+	reCifsBytes   = regexp.MustCompile(`^Bytes read:\s+(\d+)\s+Bytes written:\s+(\d+)`)
+	reCifsOp      = regexp.MustCompile(`(\w+):\s+(\d+)\s+(?:total|sent)\s+(\d+)\s+failed`)
+	reCifsSmb1Ops = regexp.MustCompile(`(\w+):\s+(\d+)`)
+)
+
+// parseCifsStats parses the per share section of /proc/fs/cifs/Stats:
+//   1) \\server\share
+//   SMBs: 20
+//   Bytes read: 4096  Bytes written: 8192
+//   TreeConnects: 1 total 0 failed
+//   Creates: 2 total 0 failed
+//   Closes: 1 total 0 failed
+func parseCifsStats(content []byte) (shares []CifsShareStats, err error) {
+	shares = []CifsShareStats{}
+	var share *CifsShareStats
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if stat := reCifsShare.FindStringSubmatch(line); stat != nil {
+			shares = append(shares, CifsShareStats{Share: stat[1], Ops: map[string]CifsOpStats{}})
+			share = &shares[len(shares)-1]
+			continue
+		}
+		if share == nil {
+			// Global section
+			continue
+		}
+		if stat := reCifsSmbs.FindStringSubmatch(line); stat != nil {
+			if share.Smbs, err = strconv.ParseUint(stat[1], 10, 64); err != nil {
+				return nil, err
+			}
+		} else if stat := reCifsBytes.FindStringSubmatch(line); stat != nil {
+			if share.BytesRead, err = strconv.ParseUint(stat[1], 10, 64); err != nil {
+				return nil, err
+			}
+			if share.BytesWritten, err = strconv.ParseUint(stat[2], 10, 64); err != nil {
+				return nil, err
+			}
+		} else if stats := reCifsOp.FindAllStringSubmatch(line, -1); stats != nil {
+			for _, stat := range stats {
+				op := CifsOpStats{}
+				if op.Total, err = strconv.ParseUint(stat[2], 10, 64); err != nil {
+					return nil, err
+				}
+				if op.Failed, err = strconv.ParseUint(stat[3], 10, 64); err != nil {
+					return nil, err
+				}
+				share.Ops[stat[1]] = op
+			}
+		} else {
+			// SMB1 shares use "Reads: 0 Bytes: 0" style lines
+			for i, stat := range reCifsSmb1Ops.FindAllStringSubmatch(line, -1) {
+				value, err := strconv.ParseUint(stat[2], 10, 64)
+				if err != nil {
+					return nil, err
+				}
+				if i > 0 && stat[1] == `Bytes` {
+					continue
+				}
+				share.Ops[stat[1]] = CifsOpStats{Total: value}
+			}
+		}
+	}
+
+	return shares, nil
+}
+
+// getCephClientStats gets the kernel Ceph clients statistics from
+// /sys/kernel/debug/ceph/*. It returns an empty slice if debugfs isn't
+// mounted or readable.
+func getCephClientStats() (clients []CephClientStats, err error) {
+	clients = []CephClientStats{}
+
+	clientDirs, err := filepath.Glob("/sys/kernel/debug/ceph/*")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, clientDir := range clientDirs {
+		client := CephClientStats{Client: filepath.Base(clientDir), Latency: map[string]CephLatency{}}
+		// Every line of osdc and mdsc is a request in flight
+		client.InFlightOsdRequests = countCephRequests(filepath.Join(clientDir, "osdc"))
+		client.InFlightMdsRequests = countCephRequests(filepath.Join(clientDir, "mdsc"))
+
+		// Newer kernels have a metrics directory, older ones a metrics file
+		latencyFile := filepath.Join(clientDir, "metrics", "latency")
+		if !fileExists(latencyFile) {
+			latencyFile = filepath.Join(clientDir, "metrics")
+		}
+		if content, err := ioutil.ReadFile(latencyFile); err == nil {
+			client.Latency = parseCephLatency(content)
+		}
+
+		clients = append(clients, client)
+	}
+
+	return clients, nil
+}
+
+// countCephRequests returns the # of requests in flight listed in a ceph
+// debugfs file (osdc or mdsc).
+func countCephRequests(path string) (requests uint64) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		// Request lines start with the transaction id
+		if len(fields) > 1 {
+			if _, err := strconv.ParseUint(fields[0], 10, 64); err == nil {
+				requests++
+			}
+		}
+	}
+
+	return requests
+}
+
+// parseCephLatency parses the ceph client latency metrics:
+//
+//	item          total       avg_lat(us)     min_lat(us)     max_lat(us)     stdev(us)
+//	-----------------------------------------------------------------------------------
+//	read          12          1190            450             3200            210
+//	write         3           2500            1800            3100            120
+//	metadata      40          800             300             2100            90
+func parseCephLatency(content []byte) (latency map[string]CephLatency) {
+	latency = map[string]CephLatency{}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		switch fields[0] {
+		case `read`, `write`, `metadata`:
+		default:
+			continue
+		}
+		total, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		l := CephLatency{Total: total}
+		l.AvgLatency, _ = strconv.ParseFloat(fields[2], 64)
+		l.MinLatency, _ = strconv.ParseFloat(fields[3], 64)
+		l.MaxLatency, _ = strconv.ParseFloat(fields[4], 64)
+		latency[fields[0]] = l
+	}
+
+	return latency
+}
+
+// getRbdDevices gets the mapped RBD images from /sys/bus/rbd/devices.
+func getRbdDevices() (devices []RbdDevice, err error) {
+	devices = []RbdDevice{}
+
+	deviceDirs, err := filepath.Glob("/sys/bus/rbd/devices/*")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, deviceDir := range deviceDirs {
+		device := RbdDevice{Id: filepath.Base(deviceDir), Device: `rbd` + filepath.Base(deviceDir)}
+		for file, value := range map[string]*string{
+			"pool":         &device.Pool,
+			"name":         &device.Image,
+			"current_snap": &device.Snap,
+		} {
+			*value, _ = readStringFile(filepath.Join(deviceDir, file))
+		}
+		devices = append(devices, device)
+	}
+
+	return devices, nil
+}
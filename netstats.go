@@ -0,0 +1,391 @@
+package sysstats
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IfaceRawStats represents *one* network interface raw statistics of a
+// linux system.
+//
+// Map keys:
+//   rxbytes -  # of bytes.
+//   rxpkts  -  # of packets.
+//   rxerrs  -  # of errors that happend while receiving packets.
+//   rxdrop  -  # of packets that were dropped.
+//   rxfifo  -  # of FIFO overruns that happend on received packets.
+//   rxframe -  # of carrier errors that happend on received packets.
+//   rxcompr -  # of compressed packets received.
+//   rxmulti -  # of multicast packets received.
+//   txbytes -  # of bytes transmitted.
+//   txpkts  -  # of packets transmitted.
+//   txerrs  -  # of errors that happend while transmitting packets.
+//   txdrop  -  # of packets that were dropped.
+//   txfifo  -  # of FIFO overruns that happend on transmitted packets.
+//   txcolls -  # of collisions that were detected.
+//   txcarr  -  # of carrier errors that happend on transmitted packets.
+//   txcompr -  # of compressed packets transmitted.
+type IfaceRawStats map[string]uint64
+
+// IfaceAvgStats represents *one* network interface statistics of a linux system.
+//
+// Map keys:
+//   rxbytes -  # of bytes per second.
+//   rxpkts  -  # of packets per second.
+//   rxerrs  -  # of errors that happend while receiving packets per second.
+//   rxdrop  -  # of packets that were dropped per second.
+//   rxfifo  -  # of FIFO overruns that happend on received packets per second.
+//   rxframe -  # of carrier errors that happend on received packets per second.
+//   rxcompr -  # of compressed packets received per second.
+//   rxmulti -  # of multicast packets received per second.
+//   txbytes -  # of bytes transmitted per second.
+//   txpkts  -  # of packets transmitted per second.
+//   txerrs  -  # of errors that happend while transmitting packets per second.
+//   txdrop  -  # of packets that were dropped per second.
+//   txfifo  -  # of FIFO overruns that happend on transmitted packets per second.
+//   txcolls -  # of collisions that were detected per second.
+//   txcarr  -  # of carrier errors that happend on transmitted packets per second.
+//   txcompr -  # of compressed packets transmitted per second.
+type IfaceAvgStats map[string]float64
+
+// NetRawStats represents *all* the network interfaces raw statistics of a linux system.
+//
+// Map keys:
+//   Name - name of the network interface
+type NetRawStats map[string]IfaceRawStats
+
+// NetAvgStats represents *all* the network interfaces statistics of a linux system.
+//
+// Map keys:
+//   Name - name of the network interface
+type NetAvgStats map[string]IfaceAvgStats
+
+// IfaceCounters represents *one* network interface raw statistics of a linux
+// system as a struct. The JSON keys are the IfaceRawStats map keys.
+type IfaceCounters struct {
+	RxBytes uint64 `json:"rxbytes"` // # of bytes received
+	RxPkts  uint64 `json:"rxpkts"`  // # of packets received
+	RxErrs  uint64 `json:"rxerrs"`  // # of errors while receiving packets
+	RxDrop  uint64 `json:"rxdrop"`  // # of received packets dropped
+	RxFifo  uint64 `json:"rxfifo"`  // # of FIFO overruns on received packets
+	RxFrame uint64 `json:"rxframe"` // # of framing errors on received packets
+	RxCompr uint64 `json:"rxcompr"` // # of compressed packets received
+	RxMulti uint64 `json:"rxmulti"` // # of multicast packets received
+	TxBytes uint64 `json:"txbytes"` // # of bytes transmitted
+	TxPkts  uint64 `json:"txpkts"`  // # of packets transmitted
+	TxErrs  uint64 `json:"txerrs"`  // # of errors while transmitting packets
+	TxDrop  uint64 `json:"txdrop"`  // # of transmitted packets dropped
+	TxFifo  uint64 `json:"txfifo"`  // # of FIFO overruns on transmitted packets
+	TxColls uint64 `json:"txcolls"` // # of collisions detected
+	TxCarr  uint64 `json:"txcarr"`  // # of carrier errors on transmitted packets
+	TxCompr uint64 `json:"txcompr"` // # of compressed packets transmitted
+	Time    uint64 `json:"time"`    // Time when the sample was taken (Unix time)
+}
+
+// IfaceRates represents *one* network interface statistics of a linux system
+// as a struct. The JSON keys are the IfaceAvgStats map keys.
+type IfaceRates struct {
+	RxBytes float64 `json:"rxbytes"` // # of bytes received per second
+	RxPkts  float64 `json:"rxpkts"`  // # of packets received per second
+	RxErrs  float64 `json:"rxerrs"`  // # of errors while receiving packets per second
+	RxDrop  float64 `json:"rxdrop"`  // # of received packets dropped per second
+	RxFifo  float64 `json:"rxfifo"`  // # of FIFO overruns on received packets per second
+	RxFrame float64 `json:"rxframe"` // # of framing errors on received packets per second
+	RxCompr float64 `json:"rxcompr"` // # of compressed packets received per second
+	RxMulti float64 `json:"rxmulti"` // # of multicast packets received per second
+	TxBytes float64 `json:"txbytes"` // # of bytes transmitted per second
+	TxPkts  float64 `json:"txpkts"`  // # of packets transmitted per second
+	TxErrs  float64 `json:"txerrs"`  // # of errors while transmitting packets per second
+	TxDrop  float64 `json:"txdrop"`  // # of transmitted packets dropped per second
+	TxFifo  float64 `json:"txfifo"`  // # of FIFO overruns on transmitted packets per second
+	TxColls float64 `json:"txcolls"` // # of collisions detected per second
+	TxCarr  float64 `json:"txcarr"`  // # of carrier errors on transmitted packets per second
+	TxCompr float64 `json:"txcompr"` // # of compressed packets transmitted per second
+}
+
+// ifaceKeys are the keys of the IfaceRawStats and IfaceAvgStats maps (except
+// time, that is only in IfaceRawStats).
+var ifaceKeys = []string{`rxbytes`, `rxpkts`, `rxerrs`, `rxdrop`, `rxfifo`,
+	`rxframe`, `rxcompr`, `rxmulti`, `txbytes`, `txpkts`, `txerrs`, `txdrop`,
+	`txfifo`, `txcolls`, `txcarr`, `txcompr`}
+
+// fields returns the counters of ifaceCounters in the order of ifaceKeys.
+func (ifaceCounters *IfaceCounters) fields() []*uint64 {
+	return []*uint64{&ifaceCounters.RxBytes, &ifaceCounters.RxPkts,
+		&ifaceCounters.RxErrs, &ifaceCounters.RxDrop, &ifaceCounters.RxFifo,
+		&ifaceCounters.RxFrame, &ifaceCounters.RxCompr, &ifaceCounters.RxMulti,
+		&ifaceCounters.TxBytes, &ifaceCounters.TxPkts, &ifaceCounters.TxErrs,
+		&ifaceCounters.TxDrop, &ifaceCounters.TxFifo, &ifaceCounters.TxColls,
+		&ifaceCounters.TxCarr, &ifaceCounters.TxCompr}
+}
+
+// fields returns the rates of ifaceRates in the order of ifaceKeys.
+func (ifaceRates *IfaceRates) fields() []*float64 {
+	return []*float64{&ifaceRates.RxBytes, &ifaceRates.RxPkts,
+		&ifaceRates.RxErrs, &ifaceRates.RxDrop, &ifaceRates.RxFifo,
+		&ifaceRates.RxFrame, &ifaceRates.RxCompr, &ifaceRates.RxMulti,
+		&ifaceRates.TxBytes, &ifaceRates.TxPkts, &ifaceRates.TxErrs,
+		&ifaceRates.TxDrop, &ifaceRates.TxFifo, &ifaceRates.TxColls,
+		&ifaceRates.TxCarr, &ifaceRates.TxCompr}
+}
+
+// Counters returns the interface raw statistics as an IfaceCounters.
+func (ifaceRawStats IfaceRawStats) Counters() (ifaceCounters IfaceCounters) {
+	for i, value := range ifaceCounters.fields() {
+		*value = ifaceRawStats[ifaceKeys[i]]
+	}
+	ifaceCounters.Time = ifaceRawStats[`time`]
+
+	return ifaceCounters
+}
+
+// ToMap returns the interface raw statistics as an IfaceRawStats map.
+func (ifaceCounters IfaceCounters) ToMap() (ifaceRawStats IfaceRawStats) {
+	ifaceRawStats = IfaceRawStats{}
+	for i, value := range ifaceCounters.fields() {
+		ifaceRawStats[ifaceKeys[i]] = *value
+	}
+	ifaceRawStats[`time`] = ifaceCounters.Time
+
+	return ifaceRawStats
+}
+
+// Rates returns the interface statistics as an IfaceRates.
+func (ifaceAvgStats IfaceAvgStats) Rates() (ifaceRates IfaceRates) {
+	for i, value := range ifaceRates.fields() {
+		*value = ifaceAvgStats[ifaceKeys[i]]
+	}
+
+	return ifaceRates
+}
+
+// ToMap returns the interface statistics as an IfaceAvgStats map.
+func (ifaceRates IfaceRates) ToMap() (ifaceAvgStats IfaceAvgStats) {
+	ifaceAvgStats = IfaceAvgStats{}
+	for i, value := range ifaceRates.fields() {
+		ifaceAvgStats[ifaceKeys[i]] = *value
+	}
+
+	return ifaceAvgStats
+}
+
+// Counters returns the raw statistics of all the interfaces as
+// IfaceCounters.
+func (netRawStats NetRawStats) Counters() (ifaceCounters map[string]IfaceCounters) {
+	ifaceCounters = make(map[string]IfaceCounters, len(netRawStats))
+	for ifaceName, ifaceRawStats := range netRawStats {
+		ifaceCounters[ifaceName] = ifaceRawStats.Counters()
+	}
+
+	return ifaceCounters
+}
+
+// Rates returns the statistics of all the interfaces as IfaceRates.
+func (netAvgStats NetAvgStats) Rates() (ifaceRates map[string]IfaceRates) {
+	ifaceRates = make(map[string]IfaceRates, len(netAvgStats))
+	for ifaceName, ifaceAvgStats := range netAvgStats {
+		ifaceRates[ifaceName] = ifaceAvgStats.Rates()
+	}
+
+	return ifaceRates
+}
+
+// reNetDevIface matches the lines of /proc/net/dev with the statistics of an
+// interface. The counters may be just after the colon if they're too long
+// (e.g. "eth0:1234567890123 ..."). Interface names can't have colons.
+var reNetDevIface = regexp.MustCompile(`^\s*([^:\s]+):\s*(.*)`)
+
+// readNetRawStats reads the network interfaces raw statistics from r, that has
+// the content of the file /proc/net/dev. now is the time of the sample.
+func readNetRawStats(r io.Reader, now int64) (netRawStats NetRawStats, err error) {
+	netRawStats = NetRawStats{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		stats := reNetDevIface.FindStringSubmatch(scanner.Text())
+		if stats == nil {
+			// Header lines
+			continue
+		}
+		rawStats, err := parseIfaceRawStats(stats[2])
+		if err != nil {
+			return nil, err
+		}
+		rawStats[`time`] = uint64(now)
+		netRawStats[stats[1]] = rawStats
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return netRawStats, nil
+}
+
+// parseIfaceRawStats parses the network stats of *one* interface as they are
+// in the file /proc/net/dev (after the name of the interface):
+//  eth0:  178331 2395 0 0 0 0 0 0 257286 1876 0 0 0 0 0 0
+//    lo:  166927  259 0 0 0 0 0 0 166927  259 0 0 0 0 0 0
+// It returns rawStats with the following format:
+//   map[rxbytes:178331 rxcompr:0 txdrop:0 rxpkts:2395 rxerrs:0 txfifo:0
+//       rxdrop:0 rxframe:0 rxmulti:0 txbytes:257286 txcolls:0 txcompr:0
+//       rxfifo:0 txpkts:1876 txerrs:0 txcarr:0]
+func parseIfaceRawStats(stats string) (rawStats IfaceRawStats, err error) {
+	rawStats = IfaceRawStats{}
+
+	fields := strings.Fields(stats)
+	if len(fields) < 16 {
+		return nil, errors.New("Error parsing file /proc/net/dev. Interface statistics should have 16 fields")
+	}
+
+	for i := 1; i <= 16; i++ {
+		stat, err := strconv.ParseUint(fields[i-1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		switch i {
+		case 1:
+			rawStats[`rxbytes`] = stat
+		case 2:
+			rawStats[`rxpkts`] = stat
+		case 3:
+			rawStats[`rxerrs`] = stat
+		case 4:
+			rawStats[`rxdrop`] = stat
+		case 5:
+			rawStats[`rxfifo`] = stat
+		case 6:
+			rawStats[`rxframe`] = stat
+		case 7:
+			rawStats[`rxcompr`] = stat
+		case 8:
+			rawStats[`rxmulti`] = stat
+		case 9:
+			rawStats[`txbytes`] = stat
+		case 10:
+			rawStats[`txpkts`] = stat
+		case 11:
+			rawStats[`txerrs`] = stat
+		case 12:
+			rawStats[`txdrop`] = stat
+		case 13:
+			rawStats[`txfifo`] = stat
+		case 14:
+			rawStats[`txcolls`] = stat
+		case 15:
+			rawStats[`txcarr`] = stat
+		case 16:
+			rawStats[`txcompr`] = stat
+		}
+	}
+
+	return rawStats, nil
+}
+
+// getNetAvgStats calculates the network traffic average between 2 NetRawStats samples.
+// The interval is got from the time of the samples (in whole seconds).
+func getNetAvgStats(firstSample NetRawStats, secondSample NetRawStats) (netAvgStats NetAvgStats, err error) {
+	return getNetAvgStatsOver(firstSample, secondSample, 0)
+}
+
+// getNetAvgStatsOver calculates the network traffic average between 2
+// NetRawStats samples taken seconds apart (e.g. measured with a monotonic
+// clock). If seconds is 0 it's got from the time of the samples.
+func getNetAvgStatsOver(firstSample NetRawStats, secondSample NetRawStats, seconds float64) (netAvgStats NetAvgStats, err error) {
+	netAvgStats = NetAvgStats{}
+	for ifaceName, secondRawStats := range secondSample {
+		// Skip the interfaces added (or recreated) between the samples
+		firstRawStats, ok := firstSample[ifaceName]
+		if !ok || ifaceRawStatsReset(firstRawStats, secondRawStats) {
+			continue
+		}
+
+		timeDelta := seconds
+		if timeDelta == 0 {
+			if secondRawStats[`time`] <= firstRawStats[`time`] {
+				// The samples were taken within the same second (or the
+				// clock went backwards)
+				continue
+			}
+			timeDelta = float64(secondRawStats[`time`] - firstRawStats[`time`])
+		}
+		ifaceAvgStats := IfaceAvgStats{}
+		for key, secondValue := range secondRawStats {
+			if key == `time` {
+				continue
+			}
+			ifaceAvgStats[key] = counterRate(firstRawStats[key], secondValue, timeDelta)
+		}
+		netAvgStats[ifaceName] = ifaceAvgStats
+	}
+
+	return netAvgStats, nil
+}
+
+// Delta returns the network traffic average (bytes/sec, packets/sec...)
+// between a previous sample and this one.
+func (netRawStats NetRawStats) Delta(prev NetRawStats) (NetAvgStats, error) {
+	return getNetAvgStats(prev, netRawStats)
+}
+
+// getNetCounters gets the network interfaces raw statistics of a linux
+// system as IfaceCounters.
+func getNetCounters() (ifaceCounters map[string]IfaceCounters, err error) {
+	netRawStats, err := getNetRawStats()
+	if err != nil {
+		return nil, err
+	}
+
+	return netRawStats.Counters(), nil
+}
+
+// getNetRates calculates the network traffic average between 2
+// IfaceCounters samples.
+func getNetRates(firstSample map[string]IfaceCounters, secondSample map[string]IfaceCounters) (ifaceRates map[string]IfaceRates, err error) {
+	firstRawStats := make(NetRawStats, len(firstSample))
+	for ifaceName, ifaceCounters := range firstSample {
+		firstRawStats[ifaceName] = ifaceCounters.ToMap()
+	}
+	secondRawStats := make(NetRawStats, len(secondSample))
+	for ifaceName, ifaceCounters := range secondSample {
+		secondRawStats[ifaceName] = ifaceCounters.ToMap()
+	}
+
+	netAvgStats, err := getNetAvgStats(firstRawStats, secondRawStats)
+	if err != nil {
+		return nil, err
+	}
+
+	return netAvgStats.Rates(), nil
+}
+
+// getNetAvgStatsInterval returns the network traffic average between 2 samples.
+// Time interval between the 2 samples is given in seconds.
+func getNetStatsInterval(interval int64) (netAvgStats NetAvgStats, err error) {
+	firstSample, err := getNetRawStats()
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+
+	time.Sleep(time.Duration(interval) * time.Second)
+
+	secondSample, err := getNetRawStats()
+	if err != nil {
+		return nil, err
+	}
+
+	// The interval is measured with the monotonic clock
+	netAvgStats, err = getNetAvgStatsOver(firstSample, secondSample, time.Since(start).Seconds())
+	if err != nil {
+		return nil, err
+	}
+
+	return netAvgStats, nil
+}
@@ -0,0 +1,13 @@
+// +build !linux,!solaris
+
+package sysstats
+
+import (
+	"errors"
+	"runtime"
+)
+
+// getNetRawStats gets the network interfaces raw statistics of the system
+func getNetRawStats() (netRawStats NetRawStats, err error) {
+	return nil, errors.New("getNetRawStats: " + runtime.GOOS + " not supported yet")
+}
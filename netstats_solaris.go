@@ -0,0 +1,59 @@
+// +build solaris
+
+package sysstats
+
+import (
+	"time"
+)
+
+// ifaceKstatKeys are the IfaceRawStats map keys of the statistics of the
+// link kstats. The other keys (rxfifo, rxframe, rxcompr...) don't exist on
+// illumos/Solaris and are always 0.
+var ifaceKstatKeys = map[string]string{
+	`rbytes64`:   `rxbytes`,
+	`ipackets64`: `rxpkts`,
+	`ierrors`:    `rxerrs`,
+	`norcvbuf`:   `rxdrop`,
+	`multircv`:   `rxmulti`,
+	`obytes64`:   `txbytes`,
+	`opackets64`: `txpkts`,
+	`oerrors`:    `txerrs`,
+	`noxmtbuf`:   `txdrop`,
+	`collisions`: `txcolls`,
+}
+
+// getNetRawStats gets the network interfaces raw statistics of an
+// illumos/Solaris system from the link kstats (one per datalink).
+func getNetRawStats() (netRawStats NetRawStats, err error) {
+	kstats, err := getKstats(`link:::`)
+	if err != nil {
+		return nil, err
+	}
+
+	return kstatsToNetRawStats(kstats, time.Now().Unix())
+}
+
+// kstatsToNetRawStats returns the network interfaces raw statistics of the
+// link kstats. now is the time of the sample.
+func kstatsToNetRawStats(kstats []kstat, now int64) (netRawStats NetRawStats, err error) {
+	netRawStats = NetRawStats{}
+
+	for _, k := range kstats {
+		if k.Module != `link` {
+			continue
+		}
+		rawStats := IfaceRawStats{}
+		for _, key := range ifaceKeys {
+			rawStats[key] = 0
+		}
+		for stat, key := range ifaceKstatKeys {
+			if rawStats[key], err = k.uint(stat); err != nil {
+				return nil, err
+			}
+		}
+		rawStats[`time`] = uint64(now)
+		netRawStats[k.Name] = rawStats
+	}
+
+	return netRawStats, nil
+}
@@ -0,0 +1,42 @@
+package sysstats
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadNetRawStatsLongLine(t *testing.T) {
+	// A line longer than the buffer of the scanner (64 KiB)
+	content := append([]byte("    lo: 18733164 72031 0 0 0 0 0 0 18733164 72031 0 0 0 0 0 0\neth0: "), bytes.Repeat([]byte("1 "), 40000)...)
+	if netRawStats, err := readNetRawStats(bytes.NewReader(content), 1); !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("Stats %v and error %v, want %v", netRawStats, err, bufio.ErrTooLong)
+	}
+}
+
+func FuzzReadNetRawStats(f *testing.F) {
+	content, err := ioutil.ReadFile(filepath.Join("testdata", "proc", "net", "dev"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(content)
+	f.Add([]byte("    lo: 18733164   72031    0    0    0     0          0         0 18733"))
+	f.Add([]byte("enp3s0:9812765432123 8021312 0 12 0 0 0 43211 1287349812 3521876 0 0 0 0 0 0\n"))
+	f.Add([]byte("eth0:\n:\n  :  1 2 3\n"))
+	f.Add([]byte("eth0: 99999999999999999999999 -1 0 0 0 0 0 0 0 0 0 0 0 0 0 0\n"))
+	f.Add(append([]byte("eth0: "), bytes.Repeat([]byte("1 "), 70000)...))
+	f.Fuzz(func(t *testing.T, content []byte) {
+		netRawStats, err := readNetRawStats(bytes.NewReader(content), 1)
+		if err != nil {
+			return
+		}
+		for iface, rawStats := range netRawStats {
+			if len(rawStats) != 17 {
+				t.Errorf("%s: %d statistics, want 16 and the time", iface, len(rawStats))
+			}
+		}
+	})
+}
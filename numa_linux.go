@@ -0,0 +1,126 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NumaNodeStats represents the memory statistics of *one* NUMA node. The
+// MemInfo statistics the nodes don't report (swap, commit...) are always 0,
+// and the file pages of the node are reported as Cached. The numa_* counters
+// (as numastat reports them) are # of pages allocated since boot.
+type NumaNodeStats struct {
+	Node          int     `json:"node"`          // # of the node
+	Cpus          string  `json:"cpus"`          // CPUs of the node (as a cpulist, e.g. 0-7,16-23)
+	MemInfo       MemInfo `json:"meminfo"`       // Memory statistics of the node
+	NumaHit       uint64  `json:"numahit"`       // Pages allocated in the node as intended
+	NumaMiss      uint64  `json:"numamiss"`      // Pages allocated in the node that were intended for another node
+	NumaForeign   uint64  `json:"numaforeign"`   // Pages intended for the node that were allocated in another node
+	InterleaveHit uint64  `json:"interleavehit"` // Pages of the interleave policy allocated in the node as intended
+	LocalNode     uint64  `json:"localnode"`     // Pages allocated in the node by a process running on it
+	OtherNode     uint64  `json:"othernode"`     // Pages allocated in the node by a process running on another node
+}
+
+// nodeDir is the directory of the NUMA nodes in sysfs.
+const nodeDir = "/sys/devices/system/node"
+
+// reNodeMemInfoPrefix matches the prefix of the lines of the meminfo file of
+// a node:
+//   Node 0 MemTotal:       16321692 kB
+var reNodeMemInfoPrefix = regexp.MustCompile(`(?m)^Node \d+ `)
+
+// getNumaNodeStats gets the memory statistics of the NUMA nodes of a linux
+// system from /sys/devices/system/node/node*. The systems that aren't NUMA
+// have one node.
+func getNumaNodeStats() (numaNodeStatsArr []NumaNodeStats, err error) {
+	nodeDirs, err := filepath.Glob(filepath.Join(nodeDir, "node[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+
+	numaNodeStatsArr = make([]NumaNodeStats, 0, len(nodeDirs))
+	for _, dir := range nodeDirs {
+		numaNodeStats := NumaNodeStats{}
+		if numaNodeStats.Node, err = strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "node")); err != nil {
+			return nil, err
+		}
+		numaNodeStats.Cpus, _ = readStringFile(filepath.Join(dir, "cpulist"))
+
+		content, err := ioutil.ReadFile(filepath.Join(dir, "meminfo"))
+		if err != nil {
+			return nil, err
+		}
+		if numaNodeStats.MemInfo, err = parseNodeMemInfo(content); err != nil {
+			return nil, err
+		}
+
+		content, err = ioutil.ReadFile(filepath.Join(dir, "numastat"))
+		if err != nil {
+			return nil, err
+		}
+		if err = parseNumaStat(content, &numaNodeStats); err != nil {
+			return nil, err
+		}
+
+		numaNodeStatsArr = append(numaNodeStatsArr, numaNodeStats)
+	}
+	sort.Slice(numaNodeStatsArr, func(i, j int) bool { return numaNodeStatsArr[i].Node < numaNodeStatsArr[j].Node })
+
+	return numaNodeStatsArr, nil
+}
+
+// parseNodeMemInfo parses the content of the meminfo file of a node, that
+// has the format of /proc/meminfo with the node as prefix:
+//   Node 0 MemTotal:       16321692 kB
+//   Node 0 MemFree:         9755876 kB
+//   Node 0 FilePages:       4617220 kB
+//   Node 0 HugePages_Total:     0
+func parseNodeMemInfo(content []byte) (memInfo MemInfo, err error) {
+	content = reNodeMemInfoPrefix.ReplaceAll(content, nil)
+	// The nodes report the page cache as FilePages
+	content = bytes.Replace(content, []byte("FilePages:"), []byte("Cached:"), 1)
+
+	return parseMemInfo(content)
+}
+
+// parseNumaStat parses the content of the numastat file of a node:
+//   numa_hit 161758987
+//   numa_miss 0
+//   numa_foreign 0
+//   interleave_hit 2845
+//   local_node 161758780
+//   other_node 0
+func parseNumaStat(content []byte, numaNodeStats *NumaNodeStats) (err error) {
+	counters := map[string]*uint64{
+		`numa_hit`:       &numaNodeStats.NumaHit,
+		`numa_miss`:      &numaNodeStats.NumaMiss,
+		`numa_foreign`:   &numaNodeStats.NumaForeign,
+		`interleave_hit`: &numaNodeStats.InterleaveHit,
+		`local_node`:     &numaNodeStats.LocalNode,
+		`other_node`:     &numaNodeStats.OtherNode,
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if value, ok := counters[fields[0]]; ok {
+			if *value, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,225 @@
+// Package numfmt formats the numbers of the human-oriented outputs (the
+// CLI, the reports, the chat messages) consistently: thousands separators
+// and decimal marks of a locale, binary (IEC) or decimal (SI) byte units and
+// compact durations:
+//   f := numfmt.Formatter{Locale: numfmt.LocaleFor(`de_DE`), Units: numfmt.SI}
+//   f.Bytes(1500000)     // 1,5 MB
+//   f.Number(12345.6, 1) // 12.345,6
+package numfmt
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Units represents the units of the sizes in bytes.
+type Units int
+
+const (
+	IEC Units = iota // Binary units, multiples of 1024 (KiB, MiB, GiB...)
+	SI               // Decimal units, multiples of 1000 (kB, MB, GB...)
+)
+
+// Locale represents the separators of the numbers of a locale.
+type Locale struct {
+	Name      string // Name of the locale (language code, e.g. de)
+	Decimal   string // Decimal mark
+	Thousands string // Thousands separator (empty means no separator)
+}
+
+var (
+	// Posix is the C/POSIX locale: decimal point and no thousands separator
+	Posix = Locale{Name: `C`, Decimal: `.`}
+	// English is the locale of the English speaking countries
+	English = Locale{Name: `en`, Decimal: `.`, Thousands: `,`}
+)
+
+// locales are the known locales by language code. The thousands separator
+// of the languages that group with spaces is a (narrow) no-break space, so
+// the numbers aren't split across lines.
+var locales = map[string]Locale{
+	`en`: English,
+	`ja`: {Name: `ja`, Decimal: `.`, Thousands: `,`},
+	`ko`: {Name: `ko`, Decimal: `.`, Thousands: `,`},
+	`zh`: {Name: `zh`, Decimal: `.`, Thousands: `,`},
+	`de`: {Name: `de`, Decimal: `,`, Thousands: `.`},
+	`es`: {Name: `es`, Decimal: `,`, Thousands: `.`},
+	`it`: {Name: `it`, Decimal: `,`, Thousands: `.`},
+	`nl`: {Name: `nl`, Decimal: `,`, Thousands: `.`},
+	`pt`: {Name: `pt`, Decimal: `,`, Thousands: `.`},
+	`da`: {Name: `da`, Decimal: `,`, Thousands: `.`},
+	`tr`: {Name: `tr`, Decimal: `,`, Thousands: `.`},
+	`fr`: {Name: `fr`, Decimal: `,`, Thousands: "\u202f"},
+	`ru`: {Name: `ru`, Decimal: `,`, Thousands: "\u00a0"},
+	`pl`: {Name: `pl`, Decimal: `,`, Thousands: "\u00a0"},
+	`cs`: {Name: `cs`, Decimal: `,`, Thousands: "\u00a0"},
+	`sv`: {Name: `sv`, Decimal: `,`, Thousands: "\u00a0"},
+	`fi`: {Name: `fi`, Decimal: `,`, Thousands: "\u00a0"},
+	`nb`: {Name: `nb`, Decimal: `,`, Thousands: "\u00a0"},
+}
+
+// LocaleFor returns the locale of a locale name as the ones of the LANG
+// environment variable (e.g. de_DE.UTF-8, fr_CA or pt). The unknown
+// languages have the English separators and C and POSIX the POSIX ones.
+func LocaleFor(name string) Locale {
+	if i := strings.IndexAny(name, `.@`); i >= 0 {
+		name = name[:i]
+	}
+	if name == `C` || name == `POSIX` {
+		return Posix
+	}
+	language := strings.ToLower(strings.SplitN(strings.Replace(name, `-`, `_`, -1), `_`, 2)[0])
+	if locale, ok := locales[language]; ok {
+		return locale
+	}
+
+	return English
+}
+
+// LocaleFromEnv returns the locale of the numbers of the environment: the
+// first one set of LC_ALL, LC_NUMERIC and LANG (English if none is).
+func LocaleFromEnv() Locale {
+	for _, key := range []string{`LC_ALL`, `LC_NUMERIC`, `LANG`} {
+		if name := os.Getenv(key); name != `` {
+			return LocaleFor(name)
+		}
+	}
+
+	return English
+}
+
+// Formatter formats the numbers with the separators of a locale and the
+// sizes in bytes in IEC or SI units. The zero value formats them as English
+// with IEC units.
+type Formatter struct {
+	Locale Locale // Separators of the numbers (default English)
+	Units  Units  // Units of the sizes in bytes
+}
+
+// Default is the formatter of the outputs that aren't given one: English
+// separators and IEC units.
+var Default = Formatter{Locale: English, Units: IEC}
+
+// Number returns a number with decimals decimals and the thousands
+// separators, e.g. 1,234,567.89.
+func (f Formatter) Number(value float64, decimals int) string {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	if decimals < 0 {
+		decimals = 0
+	}
+	locale := f.locale()
+
+	digits := strconv.FormatFloat(math.Abs(value), 'f', decimals, 64)
+	integer, fraction := digits, ``
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		integer, fraction = digits[:i], digits[i+1:]
+	}
+
+	var b strings.Builder
+	if value < 0 && strings.Trim(digits, `0.`) != `` {
+		b.WriteByte('-')
+	}
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteString(locale.Thousands)
+		}
+		b.WriteRune(digit)
+	}
+	if fraction != `` {
+		b.WriteString(locale.Decimal)
+		b.WriteString(fraction)
+	}
+
+	return b.String()
+}
+
+// Percent returns a percentage with 1 decimal, e.g. 12.5%.
+func (f Formatter) Percent(value float64) string {
+	return f.Number(value, 1) + `%`
+}
+
+// Bytes returns a # of bytes with the unit that fits it best, with 1
+// decimal (none for the bytes), e.g. 1.5 GiB (IEC) or 1.6 GB (SI).
+func (f Formatter) Bytes(value float64) string {
+	units, base := []string{`B`, `KiB`, `MiB`, `GiB`, `TiB`, `PiB`, `EiB`}, float64(1024)
+	if f.Units == SI {
+		units, base = []string{`B`, `kB`, `MB`, `GB`, `TB`, `PB`, `EB`}, 1000
+	}
+	i := 0
+	for math.Abs(value) >= base && i < len(units)-1 {
+		value /= base
+		i++
+	}
+	if i == 0 {
+		return f.Number(value, 0) + ` ` + units[i]
+	}
+
+	return f.Number(value, 1) + ` ` + units[i]
+}
+
+// Duration returns a duration with its 2 largest units, e.g. 3d 4h, 2h 5m,
+// 1m 30s, or with up to 1 decimal under a minute, e.g. 2.5s or 250ms.
+func (f Formatter) Duration(d time.Duration) string {
+	sign := ``
+	if d < 0 {
+		sign, d = `-`, -d
+	}
+	switch {
+	case d == 0:
+		return `0s`
+	case d < time.Microsecond:
+		return sign + f.Number(float64(d), 0) + `ns`
+	case d < time.Millisecond:
+		return sign + f.compact(float64(d)/float64(time.Microsecond)) + `µs`
+	case d < time.Second:
+		return sign + f.compact(float64(d)/float64(time.Millisecond)) + `ms`
+	case d < time.Minute:
+		return sign + f.compact(d.Seconds()) + `s`
+	}
+
+	units := []struct {
+		unit   time.Duration
+		suffix string
+	}{
+		{24 * time.Hour, `d`},
+		{time.Hour, `h`},
+		{time.Minute, `m`},
+		{time.Second, `s`},
+	}
+	parts := []string{}
+	for _, u := range units {
+		if len(parts) == 0 && d < u.unit {
+			continue
+		}
+		parts = append(parts, f.Number(float64(d/u.unit), 0)+u.suffix)
+		d %= u.unit
+		if len(parts) == 2 {
+			break
+		}
+	}
+
+	return sign + strings.Join(parts, ` `)
+}
+
+// compact returns a number with 1 decimal, without it if it's 0.
+func (f Formatter) compact(value float64) string {
+	if math.Round(value*10) == math.Round(value)*10 {
+		return f.Number(value, 0)
+	}
+
+	return f.Number(value, 1)
+}
+
+// locale returns the locale of the formatter (English if it isn't set).
+func (f Formatter) locale() Locale {
+	if f.Locale.Decimal == `` {
+		return English
+	}
+
+	return f.Locale
+}
@@ -0,0 +1,7 @@
+// +build linux,!mips,!mipsle,!mips64,!mips64le
+
+package sysstats
+
+// sysOpenat2 is the number of the openat2 syscall, the same on all the
+// architectures since the syscall tables were unified in 5.1 (but mips).
+const sysOpenat2 = 437
@@ -0,0 +1,6 @@
+// +build linux,mips64 linux,mips64le
+
+package sysstats
+
+// sysOpenat2 is the number of the openat2 syscall (n64 ABI).
+const sysOpenat2 = 5437
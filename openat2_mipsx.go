@@ -0,0 +1,6 @@
+// +build linux,mips linux,mipsle
+
+package sysstats
+
+// sysOpenat2 is the number of the openat2 syscall (o32 ABI).
+const sysOpenat2 = 4437
@@ -0,0 +1,45 @@
+// +build linux
+
+package sysstats
+
+import (
+	"strings"
+)
+
+// OverlayMount represents *one* overlayfs mount of a linux system.
+type OverlayMount struct {
+	MountPoint string   `json:"mountpoint"` // Mount point
+	LowerDirs  []string `json:"lowerdirs"`  // Read only layers (from top to bottom)
+	UpperDir   string   `json:"upperdir"`   // Writable layer (empty for read only overlays)
+	WorkDir    string   `json:"workdir"`    // Work directory
+	UpperUsage uint64   `json:"upperusage"` // Disk space used by the writable layer (bytes)
+}
+
+// getOverlayMounts gets the overlayfs mounts of a linux system from
+// /proc/self/mountinfo. The disk usage of the upper layers is calculated
+// walking them (like `du` does), so it can be slow on big layers.
+func getOverlayMounts() (overlayMounts []OverlayMount, err error) {
+	mounts, err := getMountInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	overlayMounts = make([]OverlayMount, 0, 8)
+	for _, mount := range mounts {
+		if mount.FsType != `overlay` {
+			continue
+		}
+		overlayMount := OverlayMount{MountPoint: mount.MountPoint, LowerDirs: []string{}}
+		if lowerDirs, ok := mount.Option(`lowerdir`); ok {
+			overlayMount.LowerDirs = strings.Split(lowerDirs, `:`)
+		}
+		overlayMount.UpperDir, _ = mount.Option(`upperdir`)
+		overlayMount.WorkDir, _ = mount.Option(`workdir`)
+		if overlayMount.UpperDir != `` {
+			overlayMount.UpperUsage = dirDiskUsage(overlayMount.UpperDir)
+		}
+		overlayMounts = append(overlayMounts, overlayMount)
+	}
+
+	return overlayMounts, nil
+}
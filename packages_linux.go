@@ -0,0 +1,205 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PackageFacts represents the package updates and reboot facts of a linux
+// system. They change slowly, so they don't need to be collected often.
+type PackageFacts struct {
+	PackageManager         string   `json:"packagemanager"`         // Package manager found (apt, dnf, yum or zypper)
+	PendingUpdates         int64    `json:"pendingupdates"`         // # of pending package updates (-1 if unknown)
+	PendingSecurityUpdates int64    `json:"pendingsecurityupdates"` // # of pending security updates (-1 if unknown)
+	RebootRequired         bool     `json:"rebootrequired"`         // Whether the distro flags that a reboot is required
+	RebootRequiredPkgs     []string `json:"rebootrequiredpkgs"`     // Packages that asked for the reboot (Debian/Ubuntu only)
+}
+
+// getPackageFacts gets the pending updates and the reboot-required flag of a
+// linux system. Depending on the distro they are got from:
+//   Debian/Ubuntu: /usr/lib/update-notifier/apt-check, /var/run/reboot-required
+//   RHEL/Fedora:   dnf (or yum) updateinfo, needs-restarting -r
+//   SUSE:          zypper list-updates, zypper needs-rebooting
+func getPackageFacts() (packageFacts PackageFacts, err error) {
+	packageFacts = PackageFacts{PendingUpdates: -1, PendingSecurityUpdates: -1, RebootRequiredPkgs: []string{}}
+
+	switch {
+	case fileExists("/usr/bin/apt-get"):
+		packageFacts.PackageManager = `apt`
+		packageFacts.PendingUpdates, packageFacts.PendingSecurityUpdates = getAptUpdates()
+		if fileExists("/var/run/reboot-required") {
+			packageFacts.RebootRequired = true
+			if pkgs, err := readStringFile("/var/run/reboot-required.pkgs"); err == nil && pkgs != `` {
+				packageFacts.RebootRequiredPkgs = strings.Fields(pkgs)
+			}
+		}
+	case fileExists("/usr/bin/dnf") || fileExists("/usr/bin/yum"):
+		packageFacts.PackageManager = `yum`
+		if fileExists("/usr/bin/dnf") {
+			packageFacts.PackageManager = `dnf`
+		}
+		packageFacts.PendingUpdates, packageFacts.PendingSecurityUpdates = getYumUpdates(packageFacts.PackageManager)
+		packageFacts.RebootRequired = getYumRebootRequired(packageFacts.PackageManager)
+	case fileExists("/usr/bin/zypper"):
+		packageFacts.PackageManager = `zypper`
+		packageFacts.PendingUpdates, packageFacts.PendingSecurityUpdates = getZypperUpdates()
+		// `zypper needs-rebooting` exits with 102 if a reboot is needed
+		if err := exec.Command("zypper", "needs-rebooting").Run(); err != nil {
+			if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 102 {
+				packageFacts.RebootRequired = true
+			}
+		}
+		if fileExists("/var/run/reboot-needed") {
+			packageFacts.RebootRequired = true
+		}
+	}
+
+	return packageFacts, nil
+}
+
+// getAptUpdates runs apt-check, that writes "<updates>;<security updates>"
+// to stderr. If it isn't installed the update-notifier stamp file is used:
+//   12 updates can be applied immediately.
+//   3 of these updates are standard security updates.
+func getAptUpdates() (updates int64, security int64) {
+	updates, security = -1, -1
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("/usr/lib/update-notifier/apt-check")
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err == nil {
+		counts := strings.SplitN(strings.TrimSpace(stderr.String()), `;`, 2)
+		if len(counts) == 2 {
+			updates, _ = strconv.ParseInt(counts[0], 10, 64)
+			security, _ = strconv.ParseInt(counts[1], 10, 64)
+			return updates, security
+		}
+	}
+
+	content, err := readStringFile("/var/lib/update-notifier/updates-available")
+	if err != nil {
+		return -1, -1
+	}
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		value, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		if strings.Contains(line, `security`) {
+			security = value
+		} else if strings.Contains(line, `can be applied`) || strings.Contains(line, `can be installed`) {
+			updates = value
+		}
+	}
+	if updates >= 0 && security < 0 {
+		security = 0
+	}
+
+	return updates, security
+}
+
+// getYumUpdates counts the pending updates running:
+//   dnf -q -C check-update
+//   dnf -q -C updateinfo list --security
+// The cache (-C) is used so the collection doesn't hit the network.
+func getYumUpdates(pm string) (updates int64, security int64) {
+	updates, security = -1, -1
+
+	// check-update exits with 100 if there are updates
+	out, err := exec.Command(pm, "-q", "-C", "check-update").Output()
+	if exitErr, ok := err.(*exec.ExitError); err == nil || (ok && exitErr.ExitCode() == 100) {
+		updates = countPackageLines(out, 3)
+	}
+
+	if out, err := exec.Command(pm, "-q", "-C", "updateinfo", "list", "--security").Output(); err == nil {
+		security = countPackageLines(out, 3)
+	}
+
+	return updates, security
+}
+
+// getYumRebootRequired runs `needs-restarting -r` (`dnf needs-restarting -r`
+// on dnf systems), which exits with 1 and writes "Reboot is required" if a
+// reboot is required. Both are checked, as dnf also exits with 1 if the
+// needs-restarting plugin isn't installed.
+func getYumRebootRequired(pm string) bool {
+	var cmd *exec.Cmd
+	if needsRestarting, err := exec.LookPath("needs-restarting"); err == nil {
+		cmd = exec.Command(needsRestarting, "-r")
+	} else if pm == `dnf` {
+		cmd = exec.Command(pm, "-q", "needs-restarting", "-r")
+	} else {
+		return false
+	}
+
+	out, err := cmd.CombinedOutput()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		return bytes.Contains(out, []byte(`Reboot is required`))
+	}
+
+	return false
+}
+
+var reZypperUpdate = regexp.MustCompile(`^v\s*\|`)
+
+// getZypperUpdates counts the pending updates running:
+//   zypper -q --no-refresh list-updates
+//   zypper -q --no-refresh list-patches --category security
+func getZypperUpdates() (updates int64, security int64) {
+	updates, security = -1, -1
+
+	if out, err := exec.Command("zypper", "-q", "--no-refresh", "list-updates").Output(); err == nil {
+		updates = 0
+		scanner := bufio.NewScanner(bytes.NewReader(out))
+		scanner.Split(bufio.ScanLines)
+		for scanner.Scan() {
+			if reZypperUpdate.MatchString(scanner.Text()) {
+				updates++
+			}
+		}
+	}
+
+	if out, err := exec.Command("zypper", "-q", "--no-refresh", "list-patches", "--category", "security").Output(); err == nil {
+		security = 0
+		scanner := bufio.NewScanner(bytes.NewReader(out))
+		scanner.Split(bufio.ScanLines)
+		for scanner.Scan() {
+			// Repository | Name | Category | Severity | Interactive | Status | Summary
+			fields := strings.Split(scanner.Text(), `|`)
+			if len(fields) > 5 && strings.TrimSpace(fields[2]) == `security` {
+				security++
+			}
+		}
+	}
+
+	return updates, security
+}
+
+// countPackageLines returns the # of lines of a command output that have at
+// least the given # of fields (package lines of dnf/yum).
+func countPackageLines(out []byte, minFields int) (lines int64) {
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// "Obsoleting Packages" and similar sections end the list
+		if strings.HasSuffix(line, `:`) {
+			break
+		}
+		if len(strings.Fields(line)) >= minFields {
+			lines++
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,330 @@
+// Package parquet writes the sysstats metrics as Parquet files, so they can
+// be analyzed offline with the standard tooling (pandas, DuckDB, Spark...)
+// without a metrics database. The Sink writes the windows of a History
+// partitioned by day and collector:
+//   sink := parquet.NewSink("/var/lib/sysstats/parquet", policy)
+//   files, err := sink.WriteHistory(history, sysstats.Last(time.Hour))
+//
+// The files are written without the Parquet libraries: a single row group
+// of uncompressed, PLAIN encoded and required columns, which every reader
+// supports.
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/rafacas/sysstats"
+)
+
+// Row represents *one* value of a metric of a Parquet file.
+type Row struct {
+	Time   int64             // Time of the value (milliseconds since the epoch)
+	Metric string            // Name of the metric (e.g. cpu.user)
+	Labels map[string]string // Labels of the value
+	Value  float64           // Value
+}
+
+// magic is the magic number at the start and the end of a Parquet file.
+const magic = `PAR1`
+
+// Types, repetitions, encodings and converted types of the Parquet format.
+const (
+	typeInt64     = 2
+	typeDouble    = 5
+	typeByteArray = 6
+
+	repetitionRequired = 0
+
+	encodingPlain = 0
+
+	convertedUtf8            = 0
+	convertedTimestampMillis = 9
+)
+
+// column represents *one* column of a Parquet file with its values PLAIN
+// encoded.
+type column struct {
+	name      string
+	typ       int32
+	converted int32
+	values    bytes.Buffer
+}
+
+// Write writes rows to w as a Parquet file with the columns time (timestamp
+// in milliseconds), metric, one string column per label of the rows (empty
+// if a row doesn't have it) and value. The label columns named like the
+// other columns have the label_ prefix (e.g. label_value).
+func Write(w io.Writer, rows []Row) error {
+	if len(rows) == 0 {
+		return errors.New("A Parquet file needs at least 1 row")
+	}
+
+	labels := map[string]bool{}
+	for _, row := range rows {
+		for label := range row.Labels {
+			labels[label] = true
+		}
+	}
+	labelNames := make([]string, 0, len(labels))
+	for label := range labels {
+		labelNames = append(labelNames, label)
+	}
+	sort.Strings(labelNames)
+
+	columns := []*column{
+		{name: `time`, typ: typeInt64, converted: convertedTimestampMillis},
+		{name: `metric`, typ: typeByteArray, converted: convertedUtf8},
+	}
+	for _, label := range labelNames {
+		name := label
+		if name == `time` || name == `metric` || name == `value` || strings.HasPrefix(name, `label_`) {
+			name = `label_` + name
+		}
+		columns = append(columns, &column{name: name, typ: typeByteArray, converted: convertedUtf8})
+	}
+	columns = append(columns, &column{name: `value`, typ: typeDouble, converted: -1})
+
+	var b [8]byte
+	for _, row := range rows {
+		binary.LittleEndian.PutUint64(b[:], uint64(row.Time))
+		columns[0].values.Write(b[:])
+		writeByteArray(&columns[1].values, row.Metric)
+		for i, label := range labelNames {
+			writeByteArray(&columns[2+i].values, row.Labels[label])
+		}
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(row.Value))
+		columns[len(columns)-1].values.Write(b[:])
+	}
+
+	return writeFile(w, columns, len(rows))
+}
+
+// Rows returns the rows of the metrics of batches.
+func Rows(batches []sysstats.MetricBatch) (rows []Row) {
+	rows = []Row{}
+	for _, batch := range batches {
+		t := batch.Time.UnixNano() / 1e6
+		for _, metric := range batch.Metrics {
+			rows = append(rows, Row{Time: t, Metric: metric.Name, Labels: metric.Labels, Value: metric.Value})
+		}
+	}
+
+	return rows
+}
+
+// writeByteArray writes a PLAIN encoded byte array: its length (4 bytes,
+// little endian) and its bytes.
+func writeByteArray(buf *bytes.Buffer, value string) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(len(value)))
+	buf.Write(b[:])
+	buf.WriteString(value)
+}
+
+// writeFile writes the Parquet file of columns with rows rows: the magic
+// number, a data page per column, the file metadata, its length and the
+// magic number again.
+func writeFile(w io.Writer, columns []*column, rows int) error {
+	var file bytes.Buffer
+	file.WriteString(magic)
+
+	metadata := newThriftWriter()
+	metadata.i32(1, 1) // version
+	metadata.listBegin(2, thriftStruct, len(columns)+1)
+	metadata.structBegin(0)
+	metadata.binary(4, `schema`)
+	metadata.i32(5, int32(len(columns)))
+	metadata.structEnd()
+	for _, column := range columns {
+		metadata.structBegin(0)
+		metadata.i32(1, column.typ)
+		metadata.i32(3, repetitionRequired)
+		metadata.binary(4, column.name)
+		if column.converted >= 0 {
+			metadata.i32(6, column.converted)
+		}
+		metadata.structEnd()
+	}
+	metadata.i64(3, int64(rows))
+
+	// The chunks are written before the metadata, which has their offsets
+	chunks := newThriftWriter()
+	totalSize := 0
+	for _, column := range columns {
+		offset := file.Len()
+		header := newThriftWriter()
+		header.i32(1, 0) // DATA_PAGE
+		header.i32(2, int32(column.values.Len()))
+		header.i32(3, int32(column.values.Len()))
+		header.structBegin(5)
+		header.i32(1, int32(rows))
+		header.i32(2, encodingPlain)
+		header.i32(3, 3) // RLE definition levels (there aren't any)
+		header.i32(4, 3) // RLE repetition levels (there aren't any)
+		header.structEnd()
+		header.stop()
+		file.Write(header.bytes())
+		file.Write(column.values.Bytes())
+		size := file.Len() - offset
+		totalSize += size
+
+		chunks.structBegin(0)
+		chunks.i64(2, int64(offset))
+		chunks.structBegin(3)
+		chunks.i32(1, column.typ)
+		chunks.listBegin(2, thriftI32, 1)
+		chunks.listI32(encodingPlain)
+		chunks.listBegin(3, thriftBinary, 1)
+		chunks.listBinary(column.name)
+		chunks.i32(4, 0) // UNCOMPRESSED
+		chunks.i64(5, int64(rows))
+		chunks.i64(6, int64(size))
+		chunks.i64(7, int64(size))
+		chunks.i64(9, int64(offset))
+		chunks.structEnd()
+		chunks.structEnd()
+	}
+
+	metadata.listBegin(4, thriftStruct, 1)
+	metadata.structBegin(0)
+	metadata.listBegin(1, thriftStruct, len(columns))
+	metadata.raw(chunks.bytes())
+	metadata.i64(2, int64(totalSize))
+	metadata.i64(3, int64(rows))
+	metadata.structEnd()
+	metadata.binary(6, `sysstats`) // created_by
+	metadata.stop()
+
+	file.Write(metadata.bytes())
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(len(metadata.bytes())))
+	file.Write(b[:])
+	file.WriteString(magic)
+
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+// Types of the Thrift compact protocol.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter writes the structures of the Parquet metadata with the Thrift
+// compact protocol.
+type thriftWriter struct {
+	buf       bytes.Buffer
+	lastField int16
+	fields    []int16 // Last field of the enclosing structures
+}
+
+// newThriftWriter returns a thriftWriter.
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{}
+}
+
+// bytes returns the bytes written.
+func (t *thriftWriter) bytes() []byte {
+	return t.buf.Bytes()
+}
+
+// raw writes bytes encoded by another thriftWriter (e.g. the elements of a
+// list).
+func (t *thriftWriter) raw(b []byte) {
+	t.buf.Write(b)
+}
+
+// field writes the header of a field: the delta from the previous field and
+// the type in a byte, or the type and the id if the delta doesn't fit.
+func (t *thriftWriter) field(id int16, typ byte) {
+	if delta := id - t.lastField; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(uint64(zigzag(int64(id))))
+	}
+	t.lastField = id
+}
+
+// i32 writes an i32 (or an enum) field.
+func (t *thriftWriter) i32(id int16, value int32) {
+	t.field(id, thriftI32)
+	t.varint(zigzag(int64(value)))
+}
+
+// i64 writes an i64 field.
+func (t *thriftWriter) i64(id int16, value int64) {
+	t.field(id, thriftI64)
+	t.varint(zigzag(value))
+}
+
+// binary writes a binary (or string) field.
+func (t *thriftWriter) binary(id int16, value string) {
+	t.field(id, thriftBinary)
+	t.listBinary(value)
+}
+
+// listBegin writes the header of a list field of n elements of typ.
+func (t *thriftWriter) listBegin(id int16, typ byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | typ)
+	} else {
+		t.buf.WriteByte(0xf0 | typ)
+		t.varint(uint64(n))
+	}
+}
+
+// listI32 writes an i32 element of a list.
+func (t *thriftWriter) listI32(value int32) {
+	t.varint(zigzag(int64(value)))
+}
+
+// listBinary writes a binary element of a list.
+func (t *thriftWriter) listBinary(value string) {
+	t.varint(uint64(len(value)))
+	t.buf.WriteString(value)
+}
+
+// structBegin writes the header of a struct field, or starts an element of
+// a list of structs if id is 0.
+func (t *thriftWriter) structBegin(id int16) {
+	if id != 0 {
+		t.field(id, thriftStruct)
+	}
+	t.fields = append(t.fields, t.lastField)
+	t.lastField = 0
+}
+
+// structEnd ends the struct started by the last structBegin.
+func (t *thriftWriter) structEnd() {
+	t.stop()
+	t.lastField = t.fields[len(t.fields)-1]
+	t.fields = t.fields[:len(t.fields)-1]
+}
+
+// stop writes the end of a struct.
+func (t *thriftWriter) stop() {
+	t.buf.WriteByte(0)
+}
+
+// varint writes an unsigned varint.
+func (t *thriftWriter) varint(value uint64) {
+	var b [binary.MaxVarintLen64]byte
+	t.buf.Write(b[:binary.PutUvarint(b[:], value)])
+}
+
+// zigzag returns the zigzag encoding of a signed integer.
+func zigzag(value int64) uint64 {
+	return uint64(value<<1) ^ uint64(value>>63)
+}
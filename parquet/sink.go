@@ -0,0 +1,97 @@
+package parquet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rafacas/sysstats"
+)
+
+// Sink writes batches of metrics as Parquet files partitioned by day (UTC)
+// and collector (the prefix of the name of the metrics, e.g. cpu for
+// cpu.user), in the Hive layout the standard tools discover the partitions
+// of:
+//   <dir>/day=2024-03-01/collector=cpu/part-<first>-<last>.parquet
+// where first and last are the times (milliseconds since the epoch) of the
+// first and the last rows of the file. The files aren't modified once
+// written, so writing the same window again replaces its files with the
+// same content.
+type Sink struct {
+	dir       string
+	redaction sysstats.RedactionPolicy
+}
+
+// NewSink returns a Sink that writes the files in dir, with the metrics
+// redacted by policy (the same policy of the ExporterManager, so the files
+// don't have the values the exporters don't send).
+func NewSink(dir string, policy sysstats.RedactionPolicy) *Sink {
+	return &Sink{dir: dir, redaction: policy}
+}
+
+// WriteHistory writes the metrics of a window of a history (see
+// History.Batches) and returns the paths of the files written.
+func (s *Sink) WriteHistory(history *sysstats.History, w sysstats.Window) (files []string, err error) {
+	return s.Write(history.Batches(w))
+}
+
+// Write writes batches of metrics, redacted by the policy of the Sink, and
+// returns the paths of the files written (sorted). Every file is written to a temporary file that is
+// renamed once it's complete, so the readers never see a partial file.
+func (s *Sink) Write(batches []sysstats.MetricBatch) (files []string, err error) {
+	redacted := make([]sysstats.MetricBatch, len(batches))
+	for i, batch := range batches {
+		redacted[i] = sysstats.MetricBatch{Time: batch.Time, Metrics: s.redaction.RedactMetrics(batch.Metrics)}
+	}
+
+	partitions := map[string][]Row{}
+	for _, row := range Rows(redacted) {
+		day := time.Unix(0, row.Time*1e6).UTC().Format(`2006-01-02`)
+		collector := row.Metric
+		if i := strings.IndexByte(collector, '.'); i >= 0 {
+			collector = collector[:i]
+		}
+		partition := filepath.Join(`day=`+day, `collector=`+collector)
+		partitions[partition] = append(partitions[partition], row)
+	}
+
+	files = []string{}
+	for partition, rows := range partitions {
+		dir := filepath.Join(s.dir, partition)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		name := `part-` + strconv.FormatInt(rows[0].Time, 10) + `-` + strconv.FormatInt(rows[len(rows)-1].Time, 10) + `.parquet`
+		path := filepath.Join(dir, name)
+		if err := writeParquetFile(path, rows); err != nil {
+			return nil, err
+		}
+		files = append(files, path)
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// writeParquetFile writes rows as the Parquet file path.
+func writeParquetFile(path string, rows []Row) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), `.`+filepath.Base(path)+`.*`)
+	if err != nil {
+		return err
+	}
+	if err := Write(tmp, rows); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
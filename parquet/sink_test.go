@@ -0,0 +1,49 @@
+package parquet
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rafacas/sysstats"
+)
+
+func TestSinkRedaction(t *testing.T) {
+	dir, err := ioutil.TempDir(``, `parquet`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sink := NewSink(dir, sysstats.RedactionPolicy{Fields: []string{`user`}, IPAddresses: true})
+	batches := []sysstats.MetricBatch{{
+		Time: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Metrics: []sysstats.Metric{
+			{Name: `proc.cpu`, Labels: map[string]string{`user`: `alice`, `peer`: `10.1.2.3`}, Value: 1},
+		},
+	}}
+	files, err := sink.Write(batches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("%d files, want 1", len(files))
+	}
+	content, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []string{`alice`, `10.1.2.3`} {
+		if bytes.Contains(content, []byte(value)) {
+			t.Errorf("%s not redacted in %s", value, files[0])
+		}
+	}
+	if !bytes.Contains(content, []byte(`[REDACTED]`)) {
+		t.Errorf("No redacted values in %s", files[0])
+	}
+	if batches[0].Metrics[0].Labels[`user`] != `alice` {
+		t.Error("Batches modified by Write")
+	}
+}
@@ -0,0 +1,140 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PciDevice represents *one* PCI device of a linux system with its PCIe link
+// and AER (Advanced Error Reporting) counters.
+//
+// AerCorrectable, AerNonFatal and AerFatal map keys are the names of the
+// errors as the kernel reports them (RxErr, BadTLP, CmpltTO...), and
+// TOTAL_ERR_COR, TOTAL_ERR_NONFATAL and TOTAL_ERR_FATAL has their sum. They
+// are nil if the device (or the kernel) doesn't support AER.
+type PciDevice struct {
+	Address          string            `json:"address"`          // PCI address (domain:bus:device.function)
+	Vendor           string            `json:"vendor"`           // Vendor id (0x8086...)
+	Device           string            `json:"device"`           // Device id
+	Class            string            `json:"class"`            // Class code (0x020000 is an Ethernet controller...)
+	SubsystemVendor  string            `json:"subsystemvendor"`  // Subsystem vendor id
+	SubsystemDevice  string            `json:"subsystemdevice"`  // Subsystem device id
+	Driver           string            `json:"driver"`           // Driver bound to the device (empty if there isn't any)
+	NumaNode         int               `json:"numanode"`         // NUMA node of the device (-1 if the system isn't NUMA)
+	CurrentLinkSpeed float64           `json:"currentlinkspeed"` // Negotiated link speed (GT/s). 0 if it isn't a PCIe device
+	MaxLinkSpeed     float64           `json:"maxlinkspeed"`     // Maximum link speed (GT/s)
+	CurrentLinkWidth uint64            `json:"currentlinkwidth"` // Negotiated link width (# of lanes)
+	MaxLinkWidth     uint64            `json:"maxlinkwidth"`     // Maximum link width (# of lanes)
+	Degraded         bool              `json:"degraded"`         // Whether the link runs below its maximum speed or width
+	AerCorrectable   map[string]uint64 `json:"aercorrectable"`   // Correctable errors
+	AerNonFatal      map[string]uint64 `json:"aernonfatal"`      // Uncorrectable non-fatal errors
+	AerFatal         map[string]uint64 `json:"aerfatal"`         // Uncorrectable fatal errors
+}
+
+// pciDevicesDir is the directory of the PCI devices in sysfs.
+const pciDevicesDir = "/sys/bus/pci/devices"
+
+// getPciDevices gets the PCI devices of a linux system from
+// /sys/bus/pci/devices. A link can be degraded because the device saves power
+// with a lower speed when it's idle (e.g. the GPUs), so Degraded should be
+// checked under load.
+func getPciDevices() (pciDevices []PciDevice, err error) {
+	dirs, err := filepath.Glob(filepath.Join(pciDevicesDir, "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	pciDevices = make([]PciDevice, 0, len(dirs))
+	for _, dir := range dirs {
+		pciDevice := PciDevice{Address: filepath.Base(dir), NumaNode: -1}
+		ids := map[string]*string{
+			`vendor`:           &pciDevice.Vendor,
+			`device`:           &pciDevice.Device,
+			`class`:            &pciDevice.Class,
+			`subsystem_vendor`: &pciDevice.SubsystemVendor,
+			`subsystem_device`: &pciDevice.SubsystemDevice,
+		}
+		for name, value := range ids {
+			*value, _ = readStringFile(filepath.Join(dir, name))
+		}
+		if driver, err := os.Readlink(filepath.Join(dir, "driver")); err == nil {
+			pciDevice.Driver = filepath.Base(driver)
+		}
+		if numaNode, err := readStringFile(filepath.Join(dir, "numa_node")); err == nil {
+			if pciDevice.NumaNode, err = strconv.Atoi(numaNode); err != nil {
+				pciDevice.NumaNode = -1
+			}
+		}
+
+		pciDevice.CurrentLinkSpeed = readPciLinkSpeed(filepath.Join(dir, "current_link_speed"))
+		pciDevice.MaxLinkSpeed = readPciLinkSpeed(filepath.Join(dir, "max_link_speed"))
+		pciDevice.CurrentLinkWidth, _ = readUintFile(filepath.Join(dir, "current_link_width"))
+		pciDevice.MaxLinkWidth, _ = readUintFile(filepath.Join(dir, "max_link_width"))
+		pciDevice.Degraded = pciDevice.CurrentLinkSpeed < pciDevice.MaxLinkSpeed || pciDevice.CurrentLinkWidth < pciDevice.MaxLinkWidth
+
+		pciDevice.AerCorrectable = readPciAerFile(filepath.Join(dir, "aer_dev_correctable"))
+		pciDevice.AerNonFatal = readPciAerFile(filepath.Join(dir, "aer_dev_nonfatal"))
+		pciDevice.AerFatal = readPciAerFile(filepath.Join(dir, "aer_dev_fatal"))
+
+		pciDevices = append(pciDevices, pciDevice)
+	}
+
+	return pciDevices, nil
+}
+
+// readPciLinkSpeed reads a link speed file of a PCI device, that has the
+// following format:
+//   8.0 GT/s PCIe
+// It returns 0 if the file doesn't exist or the speed is Unknown.
+func readPciLinkSpeed(path string) float64 {
+	value, err := readStringFile(path)
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return 0
+	}
+	speed, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0
+	}
+
+	return speed
+}
+
+// readPciAerFile reads an AER file of a PCI device, that has one counter per
+// line:
+//   RxErr 0
+//   BadTLP 2
+//   TOTAL_ERR_COR 2
+// It returns nil if the file doesn't exist.
+func readPciAerFile(path string) (counters map[string]uint64) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	counters = map[string]uint64{}
+	scanner := bufio.NewScanner(file)
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		counters[fields[0]] = value
+	}
+
+	return counters
+}
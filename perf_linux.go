@@ -0,0 +1,310 @@
+// +build linux
+
+package sysstats
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// PerfConfig represents what a PerfMonitor measures.
+type PerfConfig struct {
+	Cgroups []string // Cgroups measured, as paths within the perf_event hierarchy (e.g. /system.slice/nginx.service); empty means system-wide
+}
+
+// PerfStats represents the hardware counters of the CPUs (system-wide or
+// of *one* cgroup) since the previous check.
+//
+// A low IPC (high CPI) usually means the CPUs are stalled waiting for the
+// memory, which a high LLC miss rate confirms.
+type PerfStats struct {
+	Cgroup          string  `json:"cgroup"`          // Cgroup measured (empty if it's system-wide)
+	Cycles          float64 `json:"cycles"`          // # of CPU cycles per second
+	Instructions    float64 `json:"instructions"`    // # of instructions retired per second
+	CacheReferences float64 `json:"cachereferences"` // # of last level cache references per second
+	CacheMisses     float64 `json:"cachemisses"`     // # of last level cache misses per second
+	Branches        float64 `json:"branches"`        // # of branch instructions retired per second
+	BranchMisses    float64 `json:"branchmisses"`    // # of mispredicted branches per second
+	Ipc             float64 `json:"ipc"`             // Instructions per cycle
+	Cpi             float64 `json:"cpi"`             // Cycles per instruction
+	CacheMissRate   float64 `json:"cachemissrate"`   // % of the last level cache references that missed
+	BranchMissRate  float64 `json:"branchmissrate"`  // % of the branches that were mispredicted
+}
+
+// PerfMonitor measures the hardware counters of the CPUs with perf events
+// between calls to Check. It needs a PMU (most of the virtual machines don't
+// expose one) and CAP_PERFMON or CAP_SYS_ADMIN (or kernel.perf_event_paranoid
+// set to 0 or lower), and it has to be closed with Close.
+type PerfMonitor struct {
+	config PerfConfig
+	mu     sync.Mutex
+	time   time.Time    // Time of the previous read
+	groups []*perfGroup // Counters system-wide or by cgroup
+}
+
+// perfGroup is the counters of *one* cgroup (or system-wide): a perf event
+// per hardware event and CPU.
+type perfGroup struct {
+	cgroup   string
+	counters [len(perfHardwareEvents)][]*perfCounter
+}
+
+// perfCounter is *one* perf event and its values at the previous read.
+type perfCounter struct {
+	fd      int
+	value   uint64
+	enabled uint64 // Time the event was enabled (ns)
+	running uint64 // Time the event was counting (ns), less than enabled if it was multiplexed
+}
+
+const (
+	// perfTypeHardware is PERF_TYPE_HARDWARE (see linux/perf_event.h)
+	perfTypeHardware = 0
+	// perfTypeTracepoint is PERF_TYPE_TRACEPOINT (see linux/perf_event.h)
+	perfTypeTracepoint = 2
+	// perfFormatTotalTimes is PERF_FORMAT_TOTAL_TIME_ENABLED |
+	// PERF_FORMAT_TOTAL_TIME_RUNNING (see linux/perf_event.h)
+	perfFormatTotalTimes = 1 | 2
+	// perfFlagPidCgroup is PERF_FLAG_PID_CGROUP (see linux/perf_event.h)
+	perfFlagPidCgroup = 4
+	// perfFlagFdCloexec is PERF_FLAG_FD_CLOEXEC (see linux/perf_event.h)
+	perfFlagFdCloexec = 8
+)
+
+// perfHardwareEvents are the PERF_COUNT_HW_* events counted, in the order
+// of the counters of a perfGroup (see linux/perf_event.h).
+var perfHardwareEvents = [...]uint64{
+	0, // PERF_COUNT_HW_CPU_CYCLES
+	1, // PERF_COUNT_HW_INSTRUCTIONS
+	2, // PERF_COUNT_HW_CACHE_REFERENCES
+	3, // PERF_COUNT_HW_CACHE_MISSES
+	4, // PERF_COUNT_HW_BRANCH_INSTRUCTIONS
+	5, // PERF_COUNT_HW_BRANCH_MISSES
+}
+
+// perfEventAttr is the first version of struct perf_event_attr
+// (PERF_ATTR_SIZE_VER0), that is enough to count an event.
+type perfEventAttr struct {
+	Type         uint32
+	Size         uint32
+	Config       uint64
+	SamplePeriod uint64
+	SampleType   uint64
+	ReadFormat   uint64
+	Flags        uint64
+	WakeupEvents uint32
+	BpType       uint32
+	Config1      uint64
+}
+
+// onlineCpusPath is the path of the list of the online CPUs.
+var onlineCpusPath = `/sys/devices/system/cpu/online`
+
+// NewPerfMonitor returns a PerfMonitor for the given configuration, with
+// the counters already counting (so the first check has rates). It returns
+// an error if any of the counters can't be opened.
+func NewPerfMonitor(config PerfConfig) (monitor *PerfMonitor, err error) {
+	cpuList, err := readStringFile(onlineCpusPath)
+	if err != nil {
+		return nil, err
+	}
+	cpus, err := parseCpuList(cpuList)
+	if err != nil {
+		return nil, err
+	}
+
+	monitor = &PerfMonitor{config: config}
+	if len(config.Cgroups) == 0 {
+		group, err := openPerfGroup(``, -1, cpus, 0)
+		if err != nil {
+			return nil, err
+		}
+		monitor.groups = append(monitor.groups, group)
+	} else {
+		root, _ := cgroupRoot("perf_event")
+		if root == "" {
+			return nil, errors.New("The perf_event cgroup hierarchy isn't mounted")
+		}
+		for _, cgroup := range config.Cgroups {
+			dir, err := os.Open(filepath.Join(root, cgroup))
+			if err != nil {
+				monitor.Close()
+				return nil, err
+			}
+			group, err := openPerfGroup(cgroup, int(dir.Fd()), cpus, perfFlagPidCgroup)
+			dir.Close()
+			if err != nil {
+				monitor.Close()
+				return nil, err
+			}
+			monitor.groups = append(monitor.groups, group)
+		}
+	}
+	monitor.time = time.Now()
+
+	return monitor, nil
+}
+
+// Check returns the hardware counters since the previous call (or since the
+// monitor was created), system-wide or by cgroup in the order of the
+// configuration. The counts are scaled when the events were multiplexed
+// (there were more events than hardware counters).
+func (m *PerfMonitor) Check() (perfStatsArr []PerfStats, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.groups == nil {
+		return nil, errors.New("The perf monitor is closed")
+	}
+
+	now := time.Now()
+	seconds := now.Sub(m.time).Seconds()
+	m.time = now
+
+	perfStatsArr = make([]PerfStats, 0, len(m.groups))
+	for _, group := range m.groups {
+		var counts [len(perfHardwareEvents)]float64
+		for i, counters := range group.counters {
+			for _, counter := range counters {
+				counts[i] += counter.read()
+			}
+		}
+
+		perfStats := PerfStats{Cgroup: group.cgroup}
+		if seconds > 0 {
+			perfStats.Cycles = counts[0] / seconds
+			perfStats.Instructions = counts[1] / seconds
+			perfStats.CacheReferences = counts[2] / seconds
+			perfStats.CacheMisses = counts[3] / seconds
+			perfStats.Branches = counts[4] / seconds
+			perfStats.BranchMisses = counts[5] / seconds
+		}
+		if counts[0] > 0 {
+			perfStats.Ipc = counts[1] / counts[0]
+		}
+		if counts[1] > 0 {
+			perfStats.Cpi = counts[0] / counts[1]
+		}
+		if counts[2] > 0 {
+			perfStats.CacheMissRate = counts[3] * 100 / counts[2]
+		}
+		if counts[4] > 0 {
+			perfStats.BranchMissRate = counts[5] * 100 / counts[4]
+		}
+		perfStatsArr = append(perfStatsArr, perfStats)
+	}
+
+	return perfStatsArr, nil
+}
+
+// Close closes the perf events of the monitor.
+func (m *PerfMonitor) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, group := range m.groups {
+		group.close()
+	}
+	m.groups = nil
+
+	return nil
+}
+
+// openPerfGroup opens a perf event per hardware event and CPU, for the
+// processes of a cgroup (pid is the fd of its directory and flags is
+// perfFlagPidCgroup) or for all the processes (pid is -1).
+func openPerfGroup(cgroup string, pid int, cpus []int, flags uintptr) (group *perfGroup, err error) {
+	group = &perfGroup{cgroup: cgroup}
+	for i, event := range perfHardwareEvents {
+		attr := perfEventAttr{Type: perfTypeHardware, Config: event, ReadFormat: perfFormatTotalTimes}
+		for _, cpu := range cpus {
+			fd, err := perfEventOpen(attr, pid, cpu, flags)
+			if err != nil {
+				group.close()
+				return nil, err
+			}
+			counter := &perfCounter{fd: fd}
+			counter.read()
+			group.counters[i] = append(group.counters[i], counter)
+		}
+	}
+
+	return group, nil
+}
+
+// close closes the perf events of the group.
+func (group *perfGroup) close() {
+	for _, counters := range group.counters {
+		for _, counter := range counters {
+			syscall.Close(counter.fd)
+		}
+	}
+}
+
+// read reads a perf event (value, time enabled and time running) and
+// returns the count since the previous read, scaled by enabled/running. It
+// returns 0 if the event can't be read or it didn't run.
+func (counter *perfCounter) read() float64 {
+	buf := make([]byte, 24)
+	if n, err := syscall.Read(counter.fd, buf); err != nil || n != len(buf) {
+		return 0
+	}
+	value, enabled, running := nativeEndian.Uint64(buf[0:8]), nativeEndian.Uint64(buf[8:16]), nativeEndian.Uint64(buf[16:24])
+
+	deltaValue, _ := counterDelta(counter.value, value)
+	deltaEnabled, _ := counterDelta(counter.enabled, enabled)
+	deltaRunning, _ := counterDelta(counter.running, running)
+	counter.value, counter.enabled, counter.running = value, enabled, running
+	if deltaRunning == 0 {
+		return 0
+	}
+
+	return float64(deltaValue) * float64(deltaEnabled) / float64(deltaRunning)
+}
+
+// perfEventOpen opens a perf event with perf_event_open(2) for a process
+// (or thread) and a CPU (-1 means any of them). The fd is close-on-exec.
+func perfEventOpen(attr perfEventAttr, pid int, cpu int, flags uintptr) (fd int, err error) {
+	attr.Size = uint32(unsafe.Sizeof(attr))
+	r, _, errno := syscall.Syscall6(syscall.SYS_PERF_EVENT_OPEN, uintptr(unsafe.Pointer(&attr)), uintptr(pid),
+		uintptr(cpu), ^uintptr(0), flags|perfFlagFdCloexec, 0)
+	if errno != 0 {
+		return -1, os.NewSyscallError("perf_event_open", errno)
+	}
+
+	return int(r), nil
+}
+
+// parseCpuList parses a list of CPUs in the format of the kernel (e.g.
+// 0-3,8,10-11).
+func parseCpuList(cpuList string) (cpus []int, err error) {
+	cpus = []int{}
+	for _, cpuRange := range strings.Split(strings.TrimSpace(cpuList), `,`) {
+		if cpuRange == "" {
+			continue
+		}
+		bounds := strings.SplitN(cpuRange, `-`, 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, errors.New("Couldn't parse the CPU list: " + cpuList)
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil || last < first {
+				return nil, errors.New("Couldn't parse the CPU list: " + cpuList)
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+
+	return cpus, nil
+}
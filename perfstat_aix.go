@@ -0,0 +1,148 @@
+// +build aix,cgo
+
+package sysstats
+
+/*
+#cgo LDFLAGS: -lperfstat
+#include <libperfstat.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"time"
+)
+
+// perfstatPageSize is the size (in kilobytes) of the pages reported by
+// perfstat_memory_total.
+const perfstatPageSize = 4
+
+// perfstatTickMs is the # of milliseconds of a clock tick (AIX always has 100
+// ticks per second).
+const perfstatTickMs = 10
+
+// getMemInfo gets the memory stats of an AIX system with
+// perfstat_memory_total. The file pages are reported as Cached. The
+// statistics that don't exist on AIX (buffers, slab, dirty...) are always 0.
+func getMemInfo() (memInfo MemInfo, err error) {
+	var memory C.perfstat_memory_total_t
+	if rc, err := C.perfstat_memory_total(nil, &memory, C.sizeof_perfstat_memory_total_t, 1); rc < 1 {
+		return MemInfo{}, perfstatError(`perfstat_memory_total`, err)
+	}
+
+	memInfo.MemTotal = uint64(memory.real_total) * perfstatPageSize
+	memInfo.MemFree = uint64(memory.real_free) * perfstatPageSize
+	memInfo.MemUsed = uint64(memory.real_inuse) * perfstatPageSize
+	memInfo.Cached = uint64(memory.numperm) * perfstatPageSize
+	memInfo.RealFree = memInfo.MemFree + memInfo.Cached
+	memInfo.SwapTotal = uint64(memory.pgsp_total) * perfstatPageSize
+	memInfo.SwapFree = uint64(memory.pgsp_free) * perfstatPageSize
+	memInfo.SwapUsed = memInfo.SwapTotal - memInfo.SwapFree
+
+	return memInfo, nil
+}
+
+// getMemStats gets the memory stats of an AIX system.
+func getMemStats() (memStats MemStats, err error) {
+	memInfo, err := getMemInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	return memInfo.ToMap(), nil
+}
+
+// getCpuRawStats gets the CPU raw stats of an AIX system with perfstat_cpu
+// (cpu0, cpu1...) and perfstat_cpu_total (cpu). The times are in clock
+// ticks. The keys that don't exist on AIX (nice, irq, softirq...) are always
+// 0.
+func getCpuRawStats() (cpusRawStats CpusRawStats, err error) {
+	cpusRawStats = CpusRawStats{}
+
+	var total C.perfstat_cpu_total_t
+	if rc, err := C.perfstat_cpu_total(nil, &total, C.sizeof_perfstat_cpu_total_t, 1); rc < 1 {
+		return nil, perfstatError(`perfstat_cpu_total`, err)
+	}
+	cpusRawStats[`cpu`] = newPerfstatCpuRawStats(uint64(total.user), uint64(total.sys), uint64(total.idle), uint64(total.wait))
+
+	// With a NULL buffer perfstat_cpu returns the # of CPUs
+	n, err := C.perfstat_cpu(nil, nil, C.sizeof_perfstat_cpu_t, 0)
+	if n < 1 {
+		return nil, perfstatError(`perfstat_cpu`, err)
+	}
+	cpus := make([]C.perfstat_cpu_t, int(n))
+	// An empty name is the first CPU
+	var first C.perfstat_id_t
+	if n, err = C.perfstat_cpu(&first, &cpus[0], C.sizeof_perfstat_cpu_t, n); n < 1 {
+		return nil, perfstatError(`perfstat_cpu`, err)
+	}
+	for _, cpu := range cpus[:int(n)] {
+		cpusRawStats[C.GoString(&cpu.name[0])] = newPerfstatCpuRawStats(uint64(cpu.user), uint64(cpu.sys), uint64(cpu.idle), uint64(cpu.wait))
+	}
+
+	return cpusRawStats, nil
+}
+
+// newPerfstatCpuRawStats returns the CPU raw stats of the perfstat times.
+func newPerfstatCpuRawStats(user, sys, idle, wait uint64) (rawStats CpuRawStats) {
+	rawStats = CpuRawStats{}
+	for _, key := range cpuKeys {
+		rawStats[key] = 0
+	}
+	rawStats[`user`] = user
+	rawStats[`system`] = sys
+	rawStats[`idle`] = idle
+	rawStats[`iowait`] = wait
+	rawStats[`total`] = user + sys + idle + wait
+
+	return rawStats
+}
+
+// getFilteredDiskRawStats gets the disk IO stats of the disks of an AIX
+// system that match filter with perfstat_disk. AIX doesn't report the merges,
+// discards and flushes, and the read and write times, so they are always 0.
+func getFilteredDiskRawStats(filter DiskFilter) (diskRawStatsArr []DiskRawStats, err error) {
+	// With a NULL buffer perfstat_disk returns the # of disks
+	n, err := C.perfstat_disk(nil, nil, C.sizeof_perfstat_disk_t, 0)
+	if n < 0 {
+		return nil, perfstatError(`perfstat_disk`, err)
+	}
+	diskRawStatsArr = make([]DiskRawStats, 0, int(n))
+	if n == 0 {
+		return diskRawStatsArr, nil
+	}
+
+	disks := make([]C.perfstat_disk_t, int(n))
+	// An empty name is the first disk
+	var first C.perfstat_id_t
+	if n, err = C.perfstat_disk(&first, &disks[0], C.sizeof_perfstat_disk_t, n); n < 0 {
+		return nil, perfstatError(`perfstat_disk`, err)
+	}
+	now := time.Now().Unix()
+	for _, disk := range disks[:int(n)] {
+		// The blocks are of bsize bytes and the sectors of 512 bytes
+		sectors := uint64(disk.bsize) / 512
+		diskRawStats := DiskRawStats{
+			Name:         C.GoString(&disk.name[0]),
+			ReadIOs:      uint64(disk.xrate),
+			ReadSectors:  uint64(disk.rblks) * sectors,
+			WriteIOs:     uint64(disk.xfers) - uint64(disk.xrate),
+			WriteSectors: uint64(disk.wblks) * sectors,
+			InFlight:     uint64(disk.qdepth),
+			IOTicks:      uint64(disk.time) * perfstatTickMs,
+			SampleTime:   now,
+		}
+		diskRawStatsArr = append(diskRawStatsArr, diskRawStats)
+	}
+
+	return filterDiskRawStats(diskRawStatsArr, filter), nil
+}
+
+// perfstatError returns the error of a failed perfstat call.
+func perfstatError(function string, err error) error {
+	if err == nil {
+		return errors.New("Error calling " + function)
+	}
+
+	return errors.New("Error calling " + function + ": " + err.Error())
+}
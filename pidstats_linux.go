@@ -0,0 +1,194 @@
+// +build linux
+
+package sysstats
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PidStats represents the statistics of *one* process of a linux system.
+// Note: CPU time is measured in units of USER_HZ (1/100ths of a second on most
+// architectures).
+type PidStats struct {
+	Pid        int    `json:"pid"`        // Process id
+	Comm       string `json:"comm"`       // Command name
+	State      string `json:"state"`      // State (R running, S sleeping, D disk sleep, Z zombie, T stopped...)
+	Ppid       int    `json:"ppid"`       // Parent process id
+	Pgid       int    `json:"pgid"`       // Process group id
+	Sid        int    `json:"sid"`        // Session id
+	TtyNr      int    `json:"ttynr"`      // Controlling terminal (0 if none)
+	Utime      uint64 `json:"utime"`      // Time spent in user mode
+	Stime      uint64 `json:"stime"`      // Time spent in kernel mode
+	NumThreads uint64 `json:"numthreads"` // # of threads
+	StartTime  uint64 `json:"starttime"`  // Time the process started after boot
+	Vsize      uint64 `json:"vsize"`      // Virtual memory size in bytes
+	Rss        uint64 `json:"rss"`        // Resident set size in bytes
+}
+
+// ProcessGroup represents the aggregated statistics of *one* process group
+// (e.g. a shell pipeline or a batch job).
+type ProcessGroup struct {
+	Pgid       int    `json:"pgid"`       // Process group id
+	Sid        int    `json:"sid"`        // Session id
+	Leader     string `json:"leader"`     // Command name of the group leader (empty if it has exited)
+	Processes  uint64 `json:"processes"`  // # of processes in the group
+	NumThreads uint64 `json:"numthreads"` // # of threads in the group
+	Utime      uint64 `json:"utime"`      // Time spent in user mode by the processes of the group
+	Stime      uint64 `json:"stime"`      // Time spent in kernel mode by the processes of the group
+	Rss        uint64 `json:"rss"`        // Resident set size in bytes of the processes of the group
+	Pids       []int  `json:"pids"`       // Ids of the processes of the group
+}
+
+// getPids returns the ids (sorted) of the processes of a linux system from
+// /proc, up to Limits.MaxProcesses. The directory is read in batches, so a
+// fork bomb doesn't make it allocate the entries of all the processes.
+func getPids() (pids []int, err error) {
+	dir, err := getProcReader().open()
+	if err != nil {
+		return nil, err
+	}
+	defer dir.Close()
+
+	pids = make([]int, 0, 512)
+	for {
+		names, err := dir.Readdirnames(1024)
+		for _, name := range names {
+			// The processes are the directories with numeric names
+			pid, err := strconv.Atoi(name)
+			if err != nil {
+				continue
+			}
+			if overLimit(len(pids), limits.MaxProcesses, &truncations.Processes) {
+				sort.Ints(pids)
+				return pids, nil
+			}
+			pids = append(pids, pid)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Ints(pids)
+
+	return pids, nil
+}
+
+// getPidStats gets the statistics of all the processes of a linux system from
+// the files /proc/[pid]/stat. The processes that exit while they are read
+// are skipped.
+func getPidStats() (pidStatsArr []PidStats, err error) {
+	pids, err := getPids()
+	if err != nil {
+		return nil, err
+	}
+
+	pidStatsArr = make([]PidStats, 0, len(pids))
+	for _, pid := range pids {
+		content, err := getProcReader().readFile(strconv.Itoa(pid), "stat")
+		if err != nil {
+			// The process exited before (or while) reading it (or it
+			// can't be read in a restricted procfs)
+			if skipProcess(err) {
+				continue
+			}
+			return nil, err
+		}
+		pidStats, err := parsePidStats(string(content))
+		if err != nil {
+			return nil, err
+		}
+		pidStatsArr = append(pidStatsArr, pidStats)
+	}
+
+	return pidStatsArr, nil
+}
+
+// parsePidStats parses the content of /proc/[pid]/stat, that has the
+// following format:
+//   1234 (bash) S 1200 1234 1234 34816 1300 4194560 ...
+// The command name is between parentheses and it can have spaces and
+// parentheses, so the fields are got after the last ')'.
+func parsePidStats(stat string) (pidStats PidStats, err error) {
+	start := strings.IndexByte(stat, '(')
+	end := strings.LastIndexByte(stat, ')')
+	if start < 0 || end < start {
+		return PidStats{}, errors.New("Couldn't parse process stats: " + stat)
+	}
+	if pidStats.Pid, err = strconv.Atoi(strings.TrimSpace(stat[:start])); err != nil {
+		return PidStats{}, err
+	}
+	pidStats.Comm = stat[start+1 : end]
+
+	// fields[0] is the state (3rd field of the file)
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 22 {
+		return PidStats{}, errors.New("Error parsing file /proc/" + strconv.Itoa(pidStats.Pid) + "/stat. It should have at least 24 fields")
+	}
+	pidStats.State = fields[0]
+
+	ints := []*int{&pidStats.Ppid, &pidStats.Pgid, &pidStats.Sid, &pidStats.TtyNr}
+	for i, value := range ints {
+		if *value, err = strconv.Atoi(fields[1+i]); err != nil {
+			return PidStats{}, err
+		}
+	}
+
+	uints := map[int]*uint64{
+		11: &pidStats.Utime,
+		12: &pidStats.Stime,
+		17: &pidStats.NumThreads,
+		19: &pidStats.StartTime,
+		20: &pidStats.Vsize,
+		21: &pidStats.Rss,
+	}
+	for i, value := range uints {
+		if *value, err = strconv.ParseUint(fields[i], 10, 64); err != nil {
+			return PidStats{}, err
+		}
+	}
+	pidStats.Rss *= uint64(os.Getpagesize())
+
+	return pidStats, nil
+}
+
+// getProcessGroups aggregates the statistics of the processes of a linux
+// system by process group. The groups are sorted by pgid.
+func getProcessGroups() (groups []ProcessGroup, err error) {
+	pidStatsArr, err := getPidStats()
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := map[int]int{}
+	groups = make([]ProcessGroup, 0, 64)
+	for _, pidStats := range pidStatsArr {
+		i, ok := indexes[pidStats.Pgid]
+		if !ok {
+			i = len(groups)
+			indexes[pidStats.Pgid] = i
+			groups = append(groups, ProcessGroup{Pgid: pidStats.Pgid, Sid: pidStats.Sid, Pids: []int{}})
+		}
+		group := &groups[i]
+		if pidStats.Pid == pidStats.Pgid {
+			group.Leader = pidStats.Comm
+		}
+		group.Processes++
+		group.NumThreads += pidStats.NumThreads
+		group.Utime += pidStats.Utime
+		group.Stime += pidStats.Stime
+		group.Rss += pidStats.Rss
+		group.Pids = append(group.Pids, pidStats.Pid)
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Pgid < groups[j].Pgid })
+
+	return groups, nil
+}
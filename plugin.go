@@ -0,0 +1,245 @@
+package sysstats
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// PluginProtocolVersion is the version of the protocol spoken with the
+// plugins.
+const PluginProtocolVersion = 1
+
+// PluginConfig represents the configuration of an out-of-process plugin.
+type PluginConfig struct {
+	Path    string        // Path of the plugin executable
+	Args    []string      // Arguments of the plugin
+	Env     []string      // Environment of the plugin (empty means the environment of the agent)
+	Timeout time.Duration // Time to wait for the plugin handshake and responses (default 10 seconds)
+}
+
+// PluginStats represents the health of *one* plugin.
+type PluginStats struct {
+	Name     string `json:"name"`     // Name the plugin announced in the handshake
+	Running  bool   `json:"running"`  // Whether the plugin process is running
+	Starts   uint64 `json:"starts"`   // # of times the plugin process was started
+	Collects uint64 `json:"collects"` // # of collections
+	Failures uint64 `json:"failures"` // # of failed collections (errors, timeouts, crashes)
+}
+
+// Plugin runs a third-party collector as a subprocess, so a plugin that
+// crashes or hangs can't crash or slow down the agent, and plugins can be
+// written in any language. The plugin speaks JSON lines over its stdin and
+// stdout:
+//   plugin -> {"protocol":1,"name":"redis"}
+//   agent  -> {"method":"collect"}
+//   plugin -> {"metrics":[{"name":"clients","labels":{"db":"0"},"value":12}],"error":""}
+// The first line is the handshake the plugin writes when it starts, then the
+// agent sends a request for every collection and the plugin answers with one
+// line. The names of the metrics are prefixed with plugin.<name>. A plugin
+// that exits, answers garbage or doesn't answer in time is killed and started
+// again on the next collection.
+type Plugin struct {
+	config PluginConfig
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	lines  chan []byte
+	done   chan struct{}
+	stats  PluginStats
+}
+
+// pluginHandshake is the first line written by a plugin.
+type pluginHandshake struct {
+	Protocol int    `json:"protocol"`
+	Name     string `json:"name"`
+}
+
+// pluginRequest is a request sent to a plugin.
+type pluginRequest struct {
+	Method string `json:"method"`
+}
+
+// pluginResponse is the response of a plugin to a collect request.
+type pluginResponse struct {
+	Metrics []Metric `json:"metrics"`
+	Error   string   `json:"error"`
+}
+
+// NewPlugin returns a Plugin for the given configuration. The plugin process
+// is started on the first collection.
+func NewPlugin(config PluginConfig) *Plugin {
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	return &Plugin{config: config}
+}
+
+// Collect asks the plugin for its metrics.
+func (p *Plugin) Collect() (metrics []Metric, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stats.Collects++
+	if metrics, err = p.collect(); err != nil {
+		p.stats.Failures++
+		p.stop()
+		return nil, err
+	}
+
+	return metrics, nil
+}
+
+// collect starts the plugin if it isn't running and sends it a collect
+// request.
+func (p *Plugin) collect() (metrics []Metric, err error) {
+	if p.cmd == nil {
+		if err := p.start(); err != nil {
+			return nil, err
+		}
+	}
+
+	request, _ := json.Marshal(pluginRequest{Method: `collect`})
+	if _, err := p.stdin.Write(append(request, '\n')); err != nil {
+		return nil, errors.New("Couldn't send the request to plugin " + p.stats.Name + ": " + err.Error())
+	}
+	line, err := p.readLine()
+	if err != nil {
+		return nil, err
+	}
+
+	response := pluginResponse{}
+	if err := json.Unmarshal(line, &response); err != nil {
+		return nil, errors.New("Couldn't parse the response of plugin " + p.stats.Name + ": " + err.Error())
+	}
+	if response.Error != `` {
+		return nil, errors.New("Plugin " + p.stats.Name + " failed: " + response.Error)
+	}
+
+	metrics = make([]Metric, 0, len(response.Metrics))
+	for _, metric := range response.Metrics {
+		metric.Name = `plugin.` + p.stats.Name + `.` + metric.Name
+		metric.Derived = false
+		if metric.Labels == nil {
+			metric.Labels = map[string]string{}
+		}
+		metrics = append(metrics, metric)
+	}
+
+	return metrics, nil
+}
+
+// start starts the plugin process and reads its handshake.
+func (p *Plugin) start() (err error) {
+	cmd := exec.Command(p.config.Path, p.config.Args...)
+	if len(p.config.Env) > 0 {
+		cmd.Env = p.config.Env
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	p.cmd, p.stdin = cmd, stdin
+	p.stats.Starts++
+	p.stats.Running = true
+
+	// The lines are read in their own goroutine so the reads can time out.
+	// It exits when the plugin exits or it's stopped.
+	lines, done := make(chan []byte), make(chan struct{})
+	p.lines, p.done = lines, done
+	go func() {
+		defer close(lines)
+		reader := bufio.NewReader(stdout)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if err != nil {
+				return
+			}
+			select {
+			case lines <- line:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	line, err := p.readLine()
+	if err != nil {
+		return err
+	}
+	handshake := pluginHandshake{}
+	if err := json.Unmarshal(line, &handshake); err != nil {
+		return errors.New("Couldn't parse the handshake of plugin " + p.config.Path + ": " + err.Error())
+	}
+	if handshake.Protocol != PluginProtocolVersion {
+		return fmt.Errorf("Plugin %s speaks protocol version %d (expected %d)", p.config.Path, handshake.Protocol, PluginProtocolVersion)
+	}
+	if handshake.Name == `` {
+		return errors.New("Plugin " + p.config.Path + " didn't announce its name")
+	}
+	p.stats.Name = handshake.Name
+
+	return nil
+}
+
+// readLine reads a line written by the plugin, waiting at most the
+// configured timeout.
+func (p *Plugin) readLine() (line []byte, err error) {
+	timer := time.NewTimer(p.config.Timeout)
+	defer timer.Stop()
+
+	select {
+	case line, ok := <-p.lines:
+		if !ok {
+			return nil, errors.New("Plugin " + p.config.Path + " exited")
+		}
+		return line, nil
+	case <-timer.C:
+		return nil, errors.New("Plugin " + p.config.Path + " didn't answer in " + p.config.Timeout.String())
+	}
+}
+
+// stop kills the plugin process (if it's running) and waits for it.
+func (p *Plugin) stop() {
+	if p.cmd == nil {
+		return
+	}
+
+	close(p.done)
+	p.stdin.Close()
+	p.cmd.Process.Kill()
+	p.cmd.Wait()
+	p.cmd, p.stdin, p.lines, p.done = nil, nil, nil, nil
+	p.stats.Running = false
+}
+
+// Stats returns the health of the plugin.
+func (p *Plugin) Stats() PluginStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.stats
+}
+
+// Close stops the plugin process.
+func (p *Plugin) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stop()
+
+	return nil
+}
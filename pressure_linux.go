@@ -0,0 +1,105 @@
+// +build linux
+
+package sysstats
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// PressureLine represents *one* line (some or full) of a PSI file: the share
+// of time some (or all) the tasks were stalled on a resource.
+type PressureLine struct {
+	Avg10  float64 `json:"avg10"`  // % of time stalled in the last 10 seconds
+	Avg60  float64 `json:"avg60"`  // % of time stalled in the last 60 seconds
+	Avg300 float64 `json:"avg300"` // % of time stalled in the last 300 seconds
+	Total  uint64  `json:"total"`  // Total stall time (microseconds)
+}
+
+// ResourcePressure represents the pressure of *one* resource (CPU, memory or
+// IO).
+type ResourcePressure struct {
+	Some PressureLine `json:"some"` // Time some tasks were stalled
+	Full PressureLine `json:"full"` // Time all the non-idle tasks were stalled at once (for the CPU only since 5.13)
+}
+
+// PressureStats represents the Pressure Stall Information (PSI) of a linux
+// system.
+type PressureStats struct {
+	Enabled bool             `json:"enabled"` // Whether the kernel has PSI (4.20+ with CONFIG_PSI and not psi=0)
+	Cpu     ResourcePressure `json:"cpu"`     // CPU pressure
+	Memory  ResourcePressure `json:"memory"`  // Memory pressure
+	Io      ResourcePressure `json:"io"`      // IO pressure
+}
+
+// getPressureStats gets the Pressure Stall Information of a linux system from
+// the files /proc/pressure/cpu, /proc/pressure/memory and /proc/pressure/io.
+// If the kernel doesn't have PSI (the files don't exist or can't be read
+// because it's disabled), it isn't an error: Enabled is false.
+func getPressureStats() (pressureStats PressureStats, err error) {
+	for resource, resourcePressure := range map[string]*ResourcePressure{
+		"cpu":    &pressureStats.Cpu,
+		"memory": &pressureStats.Memory,
+		"io":     &pressureStats.Io,
+	} {
+		content, err := getProcReader().readString("pressure", resource)
+		if err != nil {
+			if os.IsNotExist(err) || errors.Is(err, syscall.EOPNOTSUPP) {
+				return PressureStats{}, nil
+			}
+			return PressureStats{}, err
+		}
+		if *resourcePressure, err = parsePressure(content); err != nil {
+			return PressureStats{}, err
+		}
+	}
+	pressureStats.Enabled = true
+
+	return pressureStats, nil
+}
+
+// parsePressure parses the content of a PSI file, that has the following
+// format:
+//   some avg10=3.24 avg60=3.14 avg300=4.22 total=65860502
+//   full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func parsePressure(content string) (resourcePressure ResourcePressure, err error) {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var pressureLine *PressureLine
+		switch fields[0] {
+		case `some`:
+			pressureLine = &resourcePressure.Some
+		case `full`:
+			pressureLine = &resourcePressure.Full
+		default:
+			continue
+		}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, `=`, 2)
+			if len(kv) != 2 {
+				return ResourcePressure{}, errors.New("Error parsing PSI file. Unexpected field: " + field)
+			}
+			switch kv[0] {
+			case `avg10`:
+				pressureLine.Avg10, err = strconv.ParseFloat(kv[1], 64)
+			case `avg60`:
+				pressureLine.Avg60, err = strconv.ParseFloat(kv[1], 64)
+			case `avg300`:
+				pressureLine.Avg300, err = strconv.ParseFloat(kv[1], 64)
+			case `total`:
+				pressureLine.Total, err = strconv.ParseUint(kv[1], 10, 64)
+			}
+			if err != nil {
+				return ResourcePressure{}, err
+			}
+		}
+	}
+
+	return resourcePressure, nil
+}
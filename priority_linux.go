@@ -0,0 +1,113 @@
+// +build linux
+
+package sysstats
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// IoClass is the I/O scheduling class of a process (see ionice(1)).
+type IoClass int
+
+const (
+	IoClassNone       IoClass = iota // Don't change the I/O scheduling class
+	IoClassRealtime                  // Realtime (needs CAP_SYS_ADMIN)
+	IoClassBestEffort                // Best-effort (the default class)
+	IoClassIdle                      // Idle: only gets disk time when nobody else needs it
+)
+
+// PriorityConfig represents the priority the agent runs with, so the
+// collection never competes with the workload it observes.
+type PriorityConfig struct {
+	Nice    int     // Nice level (-20 to 19). 0 doesn't change it
+	IoClass IoClass // I/O scheduling class
+	IoLevel int     // I/O priority within the class (0 to 7, 0 is the highest). Ignored by the idle class
+	Cgroup  string  // Cgroup to move the process to, relative to /sys/fs/cgroup (or absolute). Empty doesn't move it
+}
+
+// SetPriority sets the nice level, the I/O scheduling class and the cgroup of
+// the calling process. It should be called at startup: the nice level and the
+// I/O priority are per thread on linux, so they are set on all the current
+// threads of the process and the threads created later inherit them.
+func SetPriority(config PriorityConfig) error {
+	if config.Nice < -20 || config.Nice > 19 {
+		return errors.New("The nice level should be between -20 and 19")
+	}
+	if config.IoLevel < 0 || config.IoLevel > 7 {
+		return errors.New("The I/O priority level should be between 0 and 7")
+	}
+
+	// The cgroup goes first: moving the process to a cgroup doesn't change the
+	// priorities of its threads
+	if config.Cgroup != `` {
+		if err := setCgroup(config.Cgroup); err != nil {
+			return err
+		}
+	}
+
+	if config.Nice == 0 && config.IoClass == IoClassNone {
+		return nil
+	}
+
+	tids, err := processThreads()
+	if err != nil {
+		return err
+	}
+	for _, tid := range tids {
+		if config.Nice != 0 {
+			if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, config.Nice); err != nil {
+				return err
+			}
+		}
+		if config.IoClass != IoClassNone {
+			// IOPRIO_WHO_PROCESS is 1 and the class is in the top 3 bits of
+			// the 16 bits priority
+			ioprio := uintptr(config.IoClass)<<13 | uintptr(config.IoLevel)
+			if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, 1, uintptr(tid), ioprio); errno != 0 {
+				return errno
+			}
+		}
+	}
+
+	return nil
+}
+
+// processThreads returns the ids of the threads of the calling process from
+// /proc/self/task.
+func processThreads() (tids []int, err error) {
+	entries, err := ioutil.ReadDir("/proc/self/task")
+	if err != nil {
+		return nil, err
+	}
+
+	tids = make([]int, 0, len(entries))
+	for _, entry := range entries {
+		tid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		tids = append(tids, tid)
+	}
+
+	return tids, nil
+}
+
+// setCgroup moves the calling process to cgroup, creating it if it doesn't
+// exist. On cgroup v1 cgroup must be the absolute path of the cgroup in one
+// of the hierarchies (e.g. /sys/fs/cgroup/cpu/sysstats).
+func setCgroup(cgroup string) error {
+	if !filepath.IsAbs(cgroup) {
+		cgroup = filepath.Join("/sys/fs/cgroup", cgroup)
+	}
+	if err := os.MkdirAll(cgroup, 0755); err != nil {
+		return err
+	}
+
+	pid := []byte(strconv.Itoa(os.Getpid()))
+	return ioutil.WriteFile(filepath.Join(cgroup, "cgroup.procs"), pid, 0644)
+}
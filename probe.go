@@ -0,0 +1,273 @@
+package sysstats
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProbeType is the kind of active probe run against a target.
+type ProbeType int
+
+const (
+	ProbeIcmp ProbeType = iota // ICMP echo request (runs the `ping` command)
+	ProbeTcp                   // TCP connect to host:port
+	ProbeHttp                  // HTTP GET of an URL
+)
+
+// String returns the name of the probe type.
+func (t ProbeType) String() string {
+	switch t {
+	case ProbeIcmp:
+		return `icmp`
+	case ProbeTcp:
+		return `tcp`
+	case ProbeHttp:
+		return `http`
+	}
+	return `unknown`
+}
+
+// DefaultLatencyBuckets are the upper bounds (milliseconds) of the latency
+// histogram buckets used when no buckets are given to NewProber.
+var DefaultLatencyBuckets = []float64{1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000}
+
+// ProbeTarget represents a target to be probed.
+type ProbeTarget struct {
+	Name    string        // Name of the target (used to identify its results)
+	Type    ProbeType     // Kind of probe
+	Address string        // Host (icmp), host:port (tcp) or URL (http)
+	Timeout time.Duration // Timeout of each probe (default 5 seconds)
+}
+
+// LatencyHistogram represents the distribution of the latencies of a probe.
+type LatencyHistogram struct {
+	Buckets []float64 `json:"buckets"` // Upper bounds of the buckets (milliseconds)
+	Counts  []uint64  `json:"counts"`  // # of latencies <= the bucket upper bound (cumulative)
+	Count   uint64    `json:"count"`   // Total # of latencies observed
+	Sum     float64   `json:"sum"`     // Sum of the latencies observed (milliseconds)
+}
+
+// observe adds a latency (milliseconds) to the histogram.
+func (h *LatencyHistogram) observe(latency float64) {
+	for i, bound := range h.Buckets {
+		if latency <= bound {
+			h.Counts[i]++
+		}
+	}
+	h.Count++
+	h.Sum += latency
+}
+
+// ProbeResult represents the result of the probes run against *one* target.
+type ProbeResult struct {
+	Name      string           `json:"name"`      // Name of the target
+	Type      string           `json:"type"`      // Kind of probe (icmp, tcp or http)
+	Address   string           `json:"address"`   // Address of the target
+	Latency   float64          `json:"latency"`   // Latency of the last probe (milliseconds)
+	Error     string           `json:"error"`     // Error of the last probe (empty if it succeeded)
+	Probes    uint64           `json:"probes"`    // # of probes since the prober was created
+	Failures  uint64           `json:"failures"`  // # of failed probes since the prober was created
+	Histogram LatencyHistogram `json:"histogram"` // Latencies of the successful probes
+}
+
+// Prober runs active latency probes (ICMP ping, TCP connect and HTTP GET)
+// against a set of targets and keeps a latency histogram per target.
+type Prober struct {
+	targets []ProbeTarget
+	mu      sync.Mutex
+	results []ProbeResult
+}
+
+// NewProber returns a Prober for the given targets. If buckets is empty,
+// DefaultLatencyBuckets is used.
+func NewProber(targets []ProbeTarget, buckets []float64) *Prober {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+
+	prober := &Prober{
+		targets: make([]ProbeTarget, len(targets)),
+		results: make([]ProbeResult, len(targets)),
+	}
+	for i, target := range targets {
+		if target.Timeout <= 0 {
+			target.Timeout = 5 * time.Second
+		}
+		prober.targets[i] = target
+		prober.results[i] = ProbeResult{
+			Name:    target.Name,
+			Type:    target.Type.String(),
+			Address: target.Address,
+			Histogram: LatencyHistogram{
+				Buckets: append([]float64(nil), buckets...),
+				Counts:  make([]uint64, len(buckets)),
+			},
+		}
+	}
+
+	return prober
+}
+
+// Probe probes every target (concurrently) and returns their results.
+func (p *Prober) Probe() (results []ProbeResult, err error) {
+	var wg sync.WaitGroup
+	for i, target := range p.targets {
+		wg.Add(1)
+		go func(i int, target ProbeTarget) {
+			defer wg.Done()
+			latency, err := runProbe(target)
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			result := &p.results[i]
+			result.Probes++
+			if err != nil {
+				result.Failures++
+				result.Latency = 0
+				result.Error = err.Error()
+				return
+			}
+			result.Latency = latency
+			result.Error = ``
+			result.Histogram.observe(latency)
+		}(i, target)
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	results = make([]ProbeResult, len(p.results))
+	for i, result := range p.results {
+		result.Histogram.Buckets = append([]float64(nil), result.Histogram.Buckets...)
+		result.Histogram.Counts = append([]uint64(nil), result.Histogram.Counts...)
+		results[i] = result
+	}
+
+	return results, nil
+}
+
+// Collector returns a SamplerCollector named name that probes the targets
+// every interval and returns their results as metrics (see ProbeMetrics),
+// so the probes run with the other collectors of a Sampler, e.g. the one of
+// an Agent:
+//   agent.Sampler.Add(prober.Collector("probe", 30*time.Second))
+// The collector is expensive: an adaptive sampler drops it while the host is
+// overloaded.
+func (p *Prober) Collector(name string, interval time.Duration) SamplerCollector {
+	return SamplerCollector{
+		Name:      name,
+		Interval:  interval,
+		Expensive: true,
+		Collect: func() (interface{}, error) {
+			results, err := p.Probe()
+			return ProbeMetrics(results), err
+		},
+	}
+}
+
+// ProbeMetrics returns the results of the probes as metrics labeled with
+// the name and the type of their target:
+//   - probe.up: 1 if the last probe succeeded, 0 otherwise
+//   - probe.latency: latency of the last probe (milliseconds, only if it succeeded)
+//   - probe.failures: # of failed probes since the prober was created
+//   - probe.latencybucket: # of latencies <= each bucket (le label)
+//   - probe.latencycount and probe.latencysum: # and sum of the latencies
+func ProbeMetrics(results []ProbeResult) (metrics []Metric) {
+	metrics = []Metric{}
+	for _, result := range results {
+		labels := map[string]string{`target`: result.Name, `type`: result.Type}
+		up := 0.0
+		if result.Error == `` && result.Probes > 0 {
+			up = 1
+			metrics = append(metrics, Metric{Name: `probe.latency`, Labels: labels, Value: result.Latency})
+		}
+		metrics = append(metrics,
+			Metric{Name: `probe.up`, Labels: labels, Value: up},
+			Metric{Name: `probe.failures`, Labels: labels, Value: float64(result.Failures)},
+		)
+		for i, bound := range result.Histogram.Buckets {
+			bucketLabels := map[string]string{`target`: result.Name, `type`: result.Type, `le`: strconv.FormatFloat(bound, 'g', -1, 64)}
+			metrics = append(metrics, Metric{Name: `probe.latencybucket`, Labels: bucketLabels, Value: float64(result.Histogram.Counts[i])})
+		}
+		metrics = append(metrics,
+			Metric{Name: `probe.latencycount`, Labels: labels, Value: float64(result.Histogram.Count)},
+			Metric{Name: `probe.latencysum`, Labels: labels, Value: result.Histogram.Sum},
+		)
+	}
+
+	return metrics
+}
+
+// runProbe runs *one* probe against target and returns its latency in
+// milliseconds.
+func runProbe(target ProbeTarget) (latency float64, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), target.Timeout)
+	defer cancel()
+
+	switch target.Type {
+	case ProbeIcmp:
+		return pingProbe(ctx, target.Address)
+	case ProbeTcp:
+		start := time.Now()
+		d := net.Dialer{}
+		conn, err := d.DialContext(ctx, `tcp`, target.Address)
+		if err != nil {
+			return 0, err
+		}
+		latency = float64(time.Since(start)) / float64(time.Millisecond)
+		conn.Close()
+		return latency, nil
+	case ProbeHttp:
+		req, err := http.NewRequest(`GET`, target.Address, nil)
+		if err != nil {
+			return 0, err
+		}
+		start := time.Now()
+		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		latency = float64(time.Since(start)) / float64(time.Millisecond)
+		if resp.StatusCode >= 400 {
+			return 0, errors.New("HTTP GET " + target.Address + " returned " + resp.Status)
+		}
+		return latency, nil
+	}
+
+	return 0, errors.New("Unknown probe type " + strconv.Itoa(int(target.Type)))
+}
+
+var rePingTime = regexp.MustCompile(`time[=<]([\d.]+)\s*ms`)
+
+// pingProbe runs `ping -c 1 host` and returns the round trip time reported by
+// ping (milliseconds). The hosts that start with - are rejected, since ping
+// would read them as options.
+func pingProbe(ctx context.Context, host string) (latency float64, err error) {
+	if host == `` || strings.HasPrefix(host, `-`) {
+		return 0, errors.New("Invalid host to ping: " + host)
+	}
+	ping, err := exec.LookPath("ping")
+	if err != nil {
+		return 0, err
+	}
+
+	out, err := exec.CommandContext(ctx, ping, "-c", "1", host).Output()
+	if err != nil {
+		return 0, err
+	}
+
+	stat := rePingTime.FindStringSubmatch(string(out))
+	if stat == nil {
+		return 0, errors.New("Couldn't parse the output of ping " + host)
+	}
+
+	return strconv.ParseFloat(stat[1], 64)
+}
@@ -0,0 +1,62 @@
+package sysstats
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProberCollector(t *testing.T) {
+	listener, err := net.Listen(`tcp`, `127.0.0.1:0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	prober := NewProber([]ProbeTarget{{Name: `local`, Type: ProbeTcp, Address: listener.Addr().String()}}, []float64{1000, 5000})
+	sampler := NewSampler([]SamplerCollector{{Name: `load`, Collect: func() (interface{}, error) { return []Metric{}, nil }}}, nil)
+	if err := sampler.Add(prober.Collector(`probe`, time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := sampler.Add(prober.Collector(`probe`, time.Minute)); err == nil {
+		t.Error("A collector with the name of another one was added")
+	}
+
+	value, err := sampler.collectors[1].Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := map[string]float64{}
+	for _, metric := range value.([]Metric) {
+		if metric.Labels[`target`] != `local` || metric.Labels[`type`] != `tcp` {
+			t.Errorf("Labels of %s %v", metric.Name, metric.Labels)
+		}
+		metrics[metric.Name+metric.Labels[`le`]] = metric.Value
+	}
+	if metrics[`probe.up`] != 1 || metrics[`probe.failures`] != 0 || metrics[`probe.latencycount`] != 1 || metrics[`probe.latencybucket5000`] != 1 {
+		t.Errorf("Metrics of the probe %v", metrics)
+	}
+	if _, ok := metrics[`probe.latency`]; !ok {
+		t.Errorf("No latency in %v", metrics)
+	}
+}
+
+func TestPingProbeOptionHost(t *testing.T) {
+	for _, host := range []string{`-f`, `--help`, ``} {
+		_, err := pingProbe(context.Background(), host)
+		if err == nil || !strings.HasPrefix(err.Error(), `Invalid host`) {
+			t.Errorf("%q: error %v, want an invalid host", host, err)
+		}
+	}
+}
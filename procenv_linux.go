@@ -0,0 +1,118 @@
+// +build linux
+
+package sysstats
+
+import "strings"
+
+// ProcEnvironment represents how complete the procfs read by the collectors
+// is: whether it's mounted with hidepid (the processes of other users can't
+// be read), it's emulated by a sandbox (gVisor, WSL 1) or some of the files
+// the collectors read aren't available.
+type ProcEnvironment struct {
+	Root        string   `json:"root"`        // Mount point of the procfs (see SetProcRoot)
+	HidePid     string   `json:"hidepid"`     // hidepid mode of the mount: off, noaccess, invisible or ptraceable (empty if it's unknown)
+	SubsetPid   bool     `json:"subsetpid"`   // Whether the procfs has only the processes (mounted with subset=pid)
+	Sandbox     string   `json:"sandbox"`     // Sandbox that emulates the procfs: gvisor or wsl1 (empty if it's the one of the kernel)
+	Unavailable []string `json:"unavailable"` // Files of the procfs read by the collectors that don't exist or can't be read (e.g. diskstats)
+	Restricted  bool     `json:"restricted"`  // Whether the procfs is restricted (any of the above), see SetProcRestricted
+}
+
+// procEnvFiles are the files of the procfs read by the main collectors,
+// whose availability is checked.
+var procEnvFiles = []string{
+	`stat`,
+	`meminfo`,
+	`loadavg`,
+	`uptime`,
+	`vmstat`,
+	`diskstats`,
+	`net/dev`,
+	`net/snmp`,
+	`self/mountinfo`,
+	`self/stat`,
+}
+
+// hidePidModes are the names of the numeric hidepid modes (the kernels
+// before 5.8 only have the numbers).
+var hidePidModes = map[string]string{
+	`0`: `off`,
+	`1`: `noaccess`,
+	`2`: `invisible`,
+	`4`: `ptraceable`,
+}
+
+// gvisorVersion is the (fake) /proc/version of the gVisor sandboxes.
+const gvisorVersion = `Linux version 4.4.0 #1 SMP Sun Jan 10 15:06:54 PST 2016`
+
+// getProcEnvironment gets the environment of the procfs. The files that
+// can't be read (e.g. /proc/self/mountinfo in some sandboxes) only leave
+// their fields unknown, so it doesn't return an error.
+func getProcEnvironment() (procEnvironment ProcEnvironment) {
+	procEnvironment = ProcEnvironment{Root: getProcRoot(), Unavailable: []string{}}
+
+	for _, name := range procEnvFiles {
+		file, err := getProcReader().open(name)
+		if err != nil {
+			procEnvironment.Unavailable = append(procEnvironment.Unavailable, name)
+			continue
+		}
+		file.Close()
+	}
+
+	if mounts, err := getMountInfo(); err == nil {
+		// The last mount of the mount point is the visible one. If it isn't
+		// found (e.g. the mounts are the ones of another mount namespace) it's
+		// the one of /proc
+		var procMount *MountInfo
+		for _, mountPoint := range []string{getProcRoot(), `/proc`} {
+			for i, mount := range mounts {
+				if mount.FsType == `proc` && mount.MountPoint == mountPoint {
+					procMount = &mounts[i]
+				}
+			}
+			if procMount != nil {
+				break
+			}
+		}
+		if procMount != nil {
+			procEnvironment.HidePid = `off`
+			if mode, ok := procMount.Option(`hidepid`); ok {
+				procEnvironment.HidePid = mode
+				if name, ok := hidePidModes[mode]; ok {
+					procEnvironment.HidePid = name
+				}
+			}
+			if subset, ok := procMount.Option(`subset`); ok && subset == `pid` {
+				procEnvironment.SubsetPid = true
+			}
+		}
+	}
+
+	if content, err := getProcReader().readFile(`version`); err == nil {
+		version := strings.TrimSpace(string(content))
+		switch {
+		case version == gvisorVersion:
+			procEnvironment.Sandbox = `gvisor`
+		case strings.Contains(version, `Microsoft`):
+			// WSL 2 has a linux kernel (microsoft-standard), WSL 1 emulates it
+			procEnvironment.Sandbox = `wsl1`
+		}
+	}
+
+	procEnvironment.Restricted = (procEnvironment.HidePid != `` && procEnvironment.HidePid != `off`) ||
+		procEnvironment.SubsetPid || procEnvironment.Sandbox != `` || len(procEnvironment.Unavailable) > 0
+
+	return procEnvironment
+}
+
+// Available returns true if a file of the procfs read by the collectors
+// (e.g. diskstats or net/dev) is available.
+func (procEnvironment ProcEnvironment) Available(name string) bool {
+	for _, unavailable := range procEnvironment.Unavailable {
+		if unavailable == name {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,292 @@
+// +build linux
+
+package sysstats
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ProcessActivityConfig represents the processes watched by a
+// ProcessActivityMonitor.
+type ProcessActivityConfig struct {
+	Comms    []string // Command names of the processes watched
+	Pids     []int    // Ids of the processes watched
+	Syscalls bool     // Whether to count the syscalls with perf events (it needs CAP_PERFMON or CAP_SYS_ADMIN, or a low kernel.perf_event_paranoid)
+}
+
+// ProcessActivity represents the scheduling activity of *one* watched
+// process since the previous check.
+//
+// A CPU-bound process has a high CPU usage and most of its context switches
+// are involuntary (it's preempted), while a lock-contended (or I/O bound)
+// one has a low CPU usage and lots of voluntary context switches (it blocks).
+type ProcessActivity struct {
+	Pid                int     `json:"pid"`                // Process id
+	Comm               string  `json:"comm"`               // Command name
+	Threads            int     `json:"threads"`            // # of threads
+	CpuPer             float64 `json:"cpuper"`             // % CPU usage (of one CPU, so it can be over 100)
+	VoluntaryCtxtSw    float64 `json:"voluntaryctxtsw"`    // # of voluntary context switches per second (all the threads)
+	NonvoluntaryCtxtSw float64 `json:"nonvoluntaryctxtsw"` // # of involuntary context switches per second (all the threads)
+	NonvoluntaryRatio  float64 `json:"nonvoluntaryratio"`  // % of the context switches that were involuntary
+	SyscallsCounted    bool    `json:"syscallscounted"`    // Whether the syscalls were counted
+	Syscalls           float64 `json:"syscalls"`           // # of syscalls per second (all the threads)
+}
+
+// ProcessActivityMonitor measures the context switches (and optionally the
+// syscalls) of the watched processes between calls to Check, to distinguish
+// the CPU-bound services from the lock-contended ones. It has to be closed
+// (with Close) if it counts the syscalls.
+type ProcessActivityMonitor struct {
+	config     ProcessActivityConfig
+	mu         sync.Mutex
+	processes  map[taskKey]*processActivityState
+	tracepoint uint64 // Id of the raw_syscalls:sys_enter tracepoint (0 if it hasn't been looked up)
+}
+
+// processActivityState is the state of *one* watched process at the
+// previous check.
+type processActivityState struct {
+	time  time.Time
+	ticks uint64                       // utime + stime
+	tasks map[int]*processActivityTask // Threads by id
+}
+
+// processActivityTask is the state of *one* thread of a watched process.
+type processActivityTask struct {
+	voluntary    uint64
+	nonvoluntary uint64
+	syscallsFd   int // perf event counting the syscalls (-1 if they aren't counted)
+	syscalls     uint64
+}
+
+// syscallsTracepoints are the paths of the id of the raw_syscalls:sys_enter
+// tracepoint (tracefs or the debugfs of the older systems).
+var syscallsTracepoints = []string{
+	`/sys/kernel/tracing/events/raw_syscalls/sys_enter/id`,
+	`/sys/kernel/debug/tracing/events/raw_syscalls/sys_enter/id`,
+}
+
+// NewProcessActivityMonitor returns a ProcessActivityMonitor for the given
+// configuration.
+func NewProcessActivityMonitor(config ProcessActivityConfig) *ProcessActivityMonitor {
+	return &ProcessActivityMonitor{config: config, processes: map[taskKey]*processActivityState{}}
+}
+
+// Check returns the activity of the watched processes since the previous
+// call, sorted by pid. The first time a process is seen it doesn't have
+// rates. The context switches are read from /proc/[pid]/task/[tid]/status
+// (/proc/[pid]/status only has the ones of the main thread). If the
+// syscalls can't be counted, the activities are still returned and err is
+// a MultiError with a FieldError for syscalls.
+func (m *ProcessActivityMonitor) Check() (activities []ProcessActivity, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pidStatsArr, err := getPidStats()
+	if err != nil {
+		return nil, err
+	}
+	comms := make(map[string]bool, len(m.config.Comms))
+	for _, comm := range m.config.Comms {
+		comms[comm] = true
+	}
+	pids := make(map[int]bool, len(m.config.Pids))
+	for _, pid := range m.config.Pids {
+		pids[pid] = true
+	}
+
+	var syscallsErr error
+	if m.config.Syscalls && m.tracepoint == 0 {
+		m.tracepoint, syscallsErr = readSyscallsTracepoint()
+	}
+
+	now := time.Now()
+	activities = []ProcessActivity{}
+	processes := map[taskKey]*processActivityState{}
+	for _, pidStats := range pidStatsArr {
+		if !comms[pidStats.Comm] && !pids[pidStats.Pid] {
+			continue
+		}
+		key := taskKey{id: pidStats.Pid, startTime: pidStats.StartTime}
+		previous := m.processes[key]
+		state := &processActivityState{time: now, ticks: pidStats.Utime + pidStats.Stime, tasks: map[int]*processActivityTask{}}
+		if previous == nil {
+			previous = &processActivityState{tasks: map[int]*processActivityTask{}}
+		}
+
+		activity := ProcessActivity{Pid: pidStats.Pid, Comm: pidStats.Comm, SyscallsCounted: m.tracepoint != 0}
+		var voluntary, nonvoluntary, syscalls uint64
+		tids, err := getTaskIds(pidStats.Pid)
+		if err != nil {
+			if skipProcess(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, tid := range tids {
+			task, err := readTaskActivity(pidStats.Pid, tid)
+			if err != nil {
+				// The thread exited
+				continue
+			}
+			prev, seen := previous.tasks[tid]
+			if seen {
+				delete(previous.tasks, tid)
+				task.syscallsFd, task.syscalls = prev.syscallsFd, prev.syscalls
+				if delta, ok := counterDelta(prev.voluntary, task.voluntary); ok {
+					voluntary += delta
+				}
+				if delta, ok := counterDelta(prev.nonvoluntary, task.nonvoluntary); ok {
+					nonvoluntary += delta
+				}
+			}
+			if m.tracepoint != 0 && task.syscallsFd < 0 {
+				if task.syscallsFd, err = openSyscallsCounter(m.tracepoint, tid); err != nil {
+					if syscallsErr == nil {
+						syscallsErr = err
+					}
+					activity.SyscallsCounted = false
+				}
+			}
+			if task.syscallsFd >= 0 {
+				count := readSyscallsCounter(task.syscallsFd)
+				if delta, ok := counterDelta(task.syscalls, count); ok && seen {
+					syscalls += delta
+				}
+				task.syscalls = count
+			}
+			state.tasks[tid] = task
+		}
+		// The threads that exited since the previous check: their last
+		// syscalls are counted and their counters closed
+		for _, prev := range previous.tasks {
+			if prev.syscallsFd >= 0 {
+				if delta, ok := counterDelta(prev.syscalls, readSyscallsCounter(prev.syscallsFd)); ok {
+					syscalls += delta
+				}
+				syscall.Close(prev.syscallsFd)
+			}
+		}
+		activity.Threads = len(state.tasks)
+
+		if seconds := now.Sub(previous.time).Seconds(); !previous.time.IsZero() && seconds > 0 {
+			ticks, _ := counterDelta(previous.ticks, state.ticks)
+			activity.CpuPer = float64(ticks) * 100 / userHz / seconds
+			activity.VoluntaryCtxtSw = float64(voluntary) / seconds
+			activity.NonvoluntaryCtxtSw = float64(nonvoluntary) / seconds
+			if voluntary+nonvoluntary > 0 {
+				activity.NonvoluntaryRatio = float64(nonvoluntary) * 100 / float64(voluntary+nonvoluntary)
+			}
+			activity.Syscalls = float64(syscalls) / seconds
+		}
+
+		processes[key] = state
+		activities = append(activities, activity)
+	}
+	// The counters of the processes that exited
+	for key, previous := range m.processes {
+		if _, ok := processes[key]; !ok {
+			previous.close()
+		}
+	}
+	m.processes = processes
+
+	sort.Slice(activities, func(i, j int) bool { return activities[i].Pid < activities[j].Pid })
+
+	if syscallsErr != nil {
+		return activities, MultiError{&FieldError{Field: `syscalls`, Err: syscallsErr}}
+	}
+	return activities, nil
+}
+
+// Close closes the perf events counting the syscalls.
+func (m *ProcessActivityMonitor) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, state := range m.processes {
+		state.close()
+	}
+	m.processes = map[taskKey]*processActivityState{}
+
+	return nil
+}
+
+// close closes the perf events of the threads of the process.
+func (state *processActivityState) close() {
+	for _, task := range state.tasks {
+		if task.syscallsFd >= 0 {
+			syscall.Close(task.syscallsFd)
+		}
+	}
+}
+
+// getTaskIds returns the ids of the threads of a process, got from the
+// directory /proc/[pid]/task.
+func getTaskIds(pid int) (tids []int, err error) {
+	dir, err := getProcReader().open(strconv.Itoa(pid), "task")
+	if err != nil {
+		return nil, err
+	}
+	defer dir.Close()
+
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+	tids = make([]int, 0, len(names))
+	for _, name := range names {
+		if tid, err := strconv.Atoi(name); err == nil {
+			tids = append(tids, tid)
+		}
+	}
+
+	return tids, nil
+}
+
+// readTaskActivity reads the context switches of a thread from the file
+// /proc/[pid]/task/[tid]/status.
+func readTaskActivity(pid int, tid int) (task *processActivityTask, err error) {
+	content, err := getProcReader().readFile(strconv.Itoa(pid), "task", strconv.Itoa(tid), "status")
+	if err != nil {
+		return nil, err
+	}
+	processStats := ProcessStats{}
+	if err := parsePidStatus(content, &processStats); err != nil {
+		return nil, err
+	}
+
+	return &processActivityTask{voluntary: processStats.VoluntaryCtxtSw, nonvoluntary: processStats.NonvoluntaryCtxtSw, syscallsFd: -1}, nil
+}
+
+// readSyscallsTracepoint returns the id of the raw_syscalls:sys_enter
+// tracepoint.
+func readSyscallsTracepoint() (id uint64, err error) {
+	for _, path := range syscallsTracepoints {
+		if id, err = readUintFile(path); err == nil {
+			return id, nil
+		}
+	}
+
+	return 0, err
+}
+
+// openSyscallsCounter opens a perf event counting the syscalls of a thread.
+func openSyscallsCounter(tracepoint uint64, tid int) (fd int, err error) {
+	return perfEventOpen(perfEventAttr{Type: perfTypeTracepoint, Config: tracepoint}, tid, -1, 0)
+}
+
+// readSyscallsCounter returns the count of a perf event (0 if it can't be
+// read).
+func readSyscallsCounter(fd int) uint64 {
+	buf := make([]byte, 8)
+	if n, err := syscall.Read(fd, buf); err != nil || n != len(buf) {
+		return 0
+	}
+
+	return nativeEndian.Uint64(buf)
+}
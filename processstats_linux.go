@@ -0,0 +1,184 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// ProcessStats represents the detailed statistics of *one* process of a
+// linux system: the statistics of /proc/[pid]/stat (PidStats) plus the
+// memory, context switches and I/O statistics of /proc/[pid]/status and
+// /proc/[pid]/io and the # of open file descriptors.
+//
+// /proc/[pid]/io and /proc/[pid]/fd are only readable for the processes of the
+// same user (or with CAP_SYS_PTRACE). If they can't be read Io is false and
+// NumFds is -1.
+type ProcessStats struct {
+	PidStats
+	Uid                 int    `json:"uid"`                 // Real user id
+	VmHwm               uint64 `json:"vmhwm"`               // Peak resident set size in bytes
+	VmSwap              uint64 `json:"vmswap"`              // Swapped out memory in bytes
+	VoluntaryCtxtSw     uint64 `json:"voluntaryctxtsw"`     // # of voluntary context switches
+	NonvoluntaryCtxtSw  uint64 `json:"nonvoluntaryctxtsw"`  // # of involuntary context switches
+	NumFds              int    `json:"numfds"`              // # of open file descriptors (-1 if they can't be read)
+	Io                  bool   `json:"io"`                  // Whether the I/O statistics could be read
+	Rchar               uint64 `json:"rchar"`               // # of bytes read (including the page cache)
+	Wchar               uint64 `json:"wchar"`               // # of bytes written (including the page cache)
+	Syscr               uint64 `json:"syscr"`               // # of read syscalls
+	Syscw               uint64 `json:"syscw"`               // # of write syscalls
+	ReadBytes           uint64 `json:"readbytes"`           // # of bytes read from the storage
+	WriteBytes          uint64 `json:"writebytes"`          // # of bytes written to the storage
+	CancelledWriteBytes uint64 `json:"cancelledwritebytes"` // # of bytes not written to the storage (truncated page cache)
+}
+
+// getProcessStats gets the detailed statistics of a process of a linux system
+// from the files /proc/[pid]/stat, /proc/[pid]/status, /proc/[pid]/io and the
+// directory /proc/[pid]/fd.
+func getProcessStats(pid int) (processStats ProcessStats, err error) {
+	reader, dir := getProcReader(), strconv.Itoa(pid)
+
+	content, err := reader.readFile(dir, "stat")
+	if err != nil {
+		return ProcessStats{}, err
+	}
+	if processStats.PidStats, err = parsePidStats(string(content)); err != nil {
+		return ProcessStats{}, err
+	}
+
+	content, err = reader.readFile(dir, "status")
+	if err != nil {
+		return ProcessStats{}, err
+	}
+	if err := parsePidStatus(content, &processStats); err != nil {
+		return ProcessStats{}, err
+	}
+
+	if content, err := reader.readFile(dir, "io"); err == nil {
+		if err := parsePidIo(content, &processStats); err != nil {
+			return ProcessStats{}, err
+		}
+		processStats.Io = true
+	}
+
+	processStats.NumFds = -1
+	if fdDir, err := reader.open(dir, "fd"); err == nil {
+		if fds, err := fdDir.Readdirnames(-1); err == nil {
+			processStats.NumFds = len(fds)
+		}
+		fdDir.Close()
+	}
+
+	return processStats, nil
+}
+
+// getAllProcessStats gets the detailed statistics of all the processes of a
+// linux system. The processes that exit while they are read are skipped.
+func getAllProcessStats() (processStatsArr []ProcessStats, err error) {
+	pids, err := getPids()
+	if err != nil {
+		return nil, err
+	}
+
+	processStatsArr = make([]ProcessStats, 0, len(pids))
+	for _, pid := range pids {
+		processStats, err := getProcessStats(pid)
+		if err != nil {
+			// The process exited before (or while) reading it (or it
+			// can't be read in a restricted procfs)
+			if skipProcess(err) {
+				continue
+			}
+			return nil, err
+		}
+		processStatsArr = append(processStatsArr, processStats)
+	}
+
+	return processStatsArr, nil
+}
+
+// parsePidStatus parses the content of /proc/[pid]/status, that has the
+// following format:
+//   Name:	bash
+//   Uid:	1000	1000	1000	1000
+//   VmHWM:	    5120 kB
+//   VmSwap:	       0 kB
+//   voluntary_ctxt_switches:	150
+//   nonvoluntary_ctxt_switches:	3
+// The kernel threads don't have the Vm* lines.
+func parsePidStatus(content []byte, processStats *ProcessStats) (err error) {
+	kbs := map[string]*uint64{
+		`VmHWM`:  &processStats.VmHwm,
+		`VmSwap`: &processStats.VmSwap,
+	}
+	uints := map[string]*uint64{
+		`voluntary_ctxt_switches`:    &processStats.VoluntaryCtxtSw,
+		`nonvoluntary_ctxt_switches`: &processStats.NonvoluntaryCtxtSw,
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		key := strings.TrimSuffix(fields[0], `:`)
+		if key == `Uid` {
+			if processStats.Uid, err = strconv.Atoi(fields[1]); err != nil {
+				return err
+			}
+		} else if value, ok := kbs[key]; ok {
+			if *value, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+				return err
+			}
+			*value *= 1024
+		} else if value, ok := uints[key]; ok {
+			if *value, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// parsePidIo parses the content of /proc/[pid]/io, that has the following
+// format:
+//   rchar: 323934931
+//   wchar: 323929600
+//   syscr: 632687
+//   syscw: 632675
+//   read_bytes: 0
+//   write_bytes: 323932160
+//   cancelled_write_bytes: 0
+func parsePidIo(content []byte, processStats *ProcessStats) (err error) {
+	uints := map[string]*uint64{
+		`rchar`:                 &processStats.Rchar,
+		`wchar`:                 &processStats.Wchar,
+		`syscr`:                 &processStats.Syscr,
+		`syscw`:                 &processStats.Syscw,
+		`read_bytes`:            &processStats.ReadBytes,
+		`write_bytes`:           &processStats.WriteBytes,
+		`cancelled_write_bytes`: &processStats.CancelledWriteBytes,
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if value, ok := uints[strings.TrimSuffix(fields[0], `:`)]; ok {
+			if *value, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package sysstats
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// procRoot is the mount point of the procfs read by the collectors. It's
+// protected by procRootMu, since a Simulation changes it while the
+// collectors run.
+var procRoot = "/proc"
+
+// procRootMu protects procRoot and procRootReader.
+var procRootMu sync.RWMutex
+
+// procRootReader is the procReader of procRoot, opened on the first read
+// after procRoot is set. The procReaders of the previous mount points aren't
+// closed, since a collection can still be reading with them: their
+// descriptors are closed by their finalizers.
+var procRootReader *procReader
+
+// procRestricted is whether the procfs is restricted (mounted with hidepid,
+// or with some files denied by SELinux as on Android). Then the files of the
+// procfs that can't be read are handled as empty and the processes that
+// can't be read are skipped.
+var procRestricted = false
+
+// setProcRoot sets the mount point of the procfs read by the collectors.
+func setProcRoot(root string) {
+	procRootMu.Lock()
+	defer procRootMu.Unlock()
+	procRoot = filepath.Clean(root)
+	procRootReader = nil
+}
+
+// getProcRoot returns the mount point of the procfs read by the collectors.
+func getProcRoot() string {
+	procRootMu.RLock()
+	defer procRootMu.RUnlock()
+
+	return procRoot
+}
+
+// getProcReader returns the procReader of the procfs read by the
+// collectors.
+func getProcReader() *procReader {
+	procRootMu.RLock()
+	reader := procRootReader
+	procRootMu.RUnlock()
+	if reader != nil {
+		return reader
+	}
+
+	procRootMu.Lock()
+	defer procRootMu.Unlock()
+	if procRootReader == nil {
+		procRootReader = newProcReader(procRoot)
+		if procRootReader.dir == nil {
+			// Opened by path: the mount point is opened again on the next
+			// read, in case it's mounted later
+			reader := procRootReader
+			procRootReader = nil
+			return reader
+		}
+	}
+
+	return procRootReader
+}
+
+// setProcRestricted sets whether the procfs read by the collectors is
+// restricted.
+func setProcRestricted(restricted bool) {
+	procRestricted = restricted
+}
+
+// procPath returns the path of a file of the procfs, e.g. procPath("net",
+// "dev") is /proc/net/dev. The files are read with getProcReader: the paths
+// are the ones of the errors, the symlinks and the other operations.
+func procPath(elem ...string) string {
+	return filepath.Join(append([]string{getProcRoot()}, elem...)...)
+}
+
+// readProcFile reads a file of the procfs, e.g. readProcFile("net", "dev")
+// reads /proc/net/dev. If the procfs is restricted, the files that don't
+// exist or can't be read are returned empty.
+func readProcFile(elem ...string) (content []byte, err error) {
+	content, err = getProcReader().readFile(elem...)
+	if err != nil && procRestricted && (os.IsNotExist(err) || os.IsPermission(err)) {
+		return []byte{}, nil
+	}
+
+	return content, err
+}
+
+// procBufPool has the buffers the files of the procfs read on every sample
+// are read into, so a collection doesn't allocate them again.
+var procBufPool = sync.Pool{New: func() interface{} {
+	buf := make([]byte, 0, 8192)
+	return &buf
+}}
+
+// parseProcFile reads a file of the procfs into a buffer of procBufPool and
+// calls parse with its content, that can't be retained after parse returns.
+// If the procfs is restricted, the files that don't exist or can't be read
+// are parsed as empty.
+func parseProcFile(parse func(content []byte) error, elem ...string) error {
+	f, err := getProcReader().open(elem...)
+	if err != nil {
+		if procRestricted && (os.IsNotExist(err) || os.IsPermission(err)) {
+			return parse([]byte{})
+		}
+		return err
+	}
+
+	return parseFile(f, parse)
+}
+
+// skipProcess returns true if the error reading a process means that the
+// process has to be skipped: it exited before (or while) reading it or, if
+// the procfs is restricted, it can't be read.
+func skipProcess(err error) bool {
+	if os.IsNotExist(err) || errors.Is(err, errNoProcess) {
+		return true
+	}
+
+	return procRestricted && os.IsPermission(err)
+}
@@ -0,0 +1,10 @@
+// +build android
+
+package sysstats
+
+// The procfs of Android is restricted: the apps can't see the processes of
+// other users (hidepid) and SELinux denies /proc/stat, /proc/net/dev and
+// /proc/diskstats to them.
+func init() {
+	procRestricted = true
+}
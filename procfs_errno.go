@@ -0,0 +1,9 @@
+// +build !plan9
+
+package sysstats
+
+import "syscall"
+
+// errNoProcess is the error of the files of a process that exited while
+// they were read.
+var errNoProcess error = syscall.ESRCH
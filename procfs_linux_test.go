@@ -0,0 +1,177 @@
+// +build linux
+
+package sysstats
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// useProcFixtures sets the procfs to the fixtures of testdata/proc until the
+// test ends.
+func useProcFixtures(t *testing.T) {
+	prevRoot := getProcRoot()
+	SetProcRoot(filepath.Join("testdata", "proc"))
+	t.Cleanup(func() { SetProcRoot(prevRoot) })
+}
+
+func TestProcFixturesMemInfo(t *testing.T) {
+	useProcFixtures(t)
+
+	memInfo, err := getMemInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if memInfo.MemTotal != 16303428 || memInfo.MemFree != 1954660 {
+		t.Errorf("MemTotal %d and MemFree %d, want 16303428 and 1954660", memInfo.MemTotal, memInfo.MemFree)
+	}
+	if memInfo.MemUsed != 16303428-1954660 {
+		t.Errorf("MemUsed %d, want %d", memInfo.MemUsed, 16303428-1954660)
+	}
+	if memInfo.SwapUsed != 2097148-2078204 {
+		t.Errorf("SwapUsed %d, want %d", memInfo.SwapUsed, 2097148-2078204)
+	}
+	if memInfo.RealFree != 1954660+612840+7198624 {
+		t.Errorf("RealFree %d, want %d", memInfo.RealFree, 1954660+612840+7198624)
+	}
+}
+
+func TestProcFixturesCpuRawStats(t *testing.T) {
+	useProcFixtures(t)
+
+	cpusRawStats, err := getCpuRawStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cpusRawStats) != 5 {
+		t.Fatalf("%d cpus, want 5 (cpu and cpu0-3)", len(cpusRawStats))
+	}
+	cpu := cpusRawStats[`cpu`]
+	if cpu[`user`] != 2255034 || cpu[`idle`] != 55914722 || cpu[`softirq`] != 27131 {
+		t.Errorf("cpu stats %v", cpu)
+	}
+	if total := uint64(2255034 + 3871 + 617405 + 55914722 + 44837 + 27131); cpu[`total`] != total {
+		t.Errorf("cpu total %d, want %d", cpu[`total`], total)
+	}
+}
+
+func TestProcFixturesDiskRawStats(t *testing.T) {
+	useProcFixtures(t)
+
+	diskRawStatsArr, err := getFilteredDiskRawStats(DiskFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	disks := map[string]DiskRawStats{}
+	for _, diskRawStats := range diskRawStatsArr {
+		disks[diskRawStats.Name] = diskRawStats
+	}
+	nvme, ok := disks[`nvme0n1`]
+	if !ok {
+		t.Fatalf("nvme0n1 not in %v", diskRawStatsArr)
+	}
+	if nvme.Major != 259 || nvme.ReadIOs != 1232754 || nvme.WriteSectors != 142394560 || nvme.FlushIOs != 162371 {
+		t.Errorf("nvme0n1 stats %+v", nvme)
+	}
+	// Before 4.18 there aren't discard nor flush stats
+	if sda, ok := disks[`sda`]; !ok || sda.TimeInQueue != 51004 || sda.DiscardIOs != 0 {
+		t.Errorf("sda stats %+v", sda)
+	}
+}
+
+func TestProcFixturesNetRawStats(t *testing.T) {
+	useProcFixtures(t)
+
+	netRawStats, err := getNetRawStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(netRawStats) != 3 {
+		t.Fatalf("%d interfaces, want 3", len(netRawStats))
+	}
+	enp3s0 := netRawStats[`enp3s0`]
+	if enp3s0[`rxbytes`] != 9812765432 || enp3s0[`rxdrop`] != 12 || enp3s0[`rxmulti`] != 43211 || enp3s0[`txpkts`] != 3521876 {
+		t.Errorf("enp3s0 stats %v", enp3s0)
+	}
+}
+
+func TestProcFixturesMountInfo(t *testing.T) {
+	useProcFixtures(t)
+
+	mounts, err := getMountInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mounts) != 5 {
+		t.Fatalf("%d mounts, want 5", len(mounts))
+	}
+	root := mounts[2]
+	if root.MountPoint != `/` || root.FsType != `ext4` || root.Source != `/dev/nvme0n1p2` || root.Major != 259 || root.Minor != 2 {
+		t.Errorf("Root mount %+v", root)
+	}
+	if value, ok := root.Option(`errors`); !ok || value != `remount-ro` {
+		t.Errorf("errors option of the root mount %q", value)
+	}
+	if disk := mounts[4]; disk.MountPoint != `/media/My Disk` || !disk.IsFuse() {
+		t.Errorf("Mount point with a space %+v", disk)
+	}
+}
+
+func TestProcFixturesSysvipcMissingColumns(t *testing.T) {
+	useProcFixtures(t)
+
+	// msg without the lspid and lrpid columns
+	queues, err := getMsgQueues()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queues) != 1 || queues[0].Key != 1234 || queues[0].Qnum != 2 || queues[0].Lspid != 0 {
+		t.Errorf("Message queues %+v", queues)
+	}
+	// sem row without the gid column
+	sets, err := getSemSets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sets) != 1 || sets[0].SemId != 1 || sets[0].Uid != 1000 || sets[0].Gid != 0 {
+		t.Errorf("Semaphore sets %+v", sets)
+	}
+}
+
+func TestProcFixturesIpcLimitsWithoutMqueue(t *testing.T) {
+	useProcFixtures(t)
+
+	limits, err := getIpcLimits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits.ShmMni != 4096 || limits.SemMni != 32000 || limits.MqueuesMax != 0 {
+		t.Errorf("IPC limits %+v", limits)
+	}
+}
+
+func BenchmarkGetCpuRawStats(b *testing.B) {
+	prevRoot := getProcRoot()
+	SetProcRoot(filepath.Join("testdata", "proc"))
+	defer SetProcRoot(prevRoot)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := getCpuRawStats(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetDiskRawStats(b *testing.B) {
+	prevRoot := getProcRoot()
+	SetProcRoot(filepath.Join("testdata", "proc"))
+	defer SetProcRoot(prevRoot)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := getFilteredDiskRawStats(DiskFilter{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
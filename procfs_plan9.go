@@ -0,0 +1,7 @@
+package sysstats
+
+import "errors"
+
+// errNoProcess is the error of the files of a process that exited while
+// they were read. Plan 9 doesn't have ESRCH: its errors are strings.
+var errNoProcess = errors.New("process does not exist")
@@ -0,0 +1,150 @@
+package sysstats
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// procReader reads the files of a procfs. All the collectors read the
+// procfs through the one of getProcReader, so they all read the one of
+// SetProcRoot (or of a Simulation). On linux the files are opened relative
+// to a descriptor of the mount point with openat2 and RESOLVE_BENEATH: a
+// path can't escape the procfs (e.g. through a symlink of a fixture), and
+// the lookup of the mount point isn't repeated on every open.
+type procReader struct {
+	root string
+	dir  *os.File // Mount point (nil if it couldn't be opened: the files are opened by path)
+}
+
+// relName returns the path relative to the mount point of the file elem.
+// It returns an error (os.ErrInvalid) if the path is absolute or goes up
+// from the mount point.
+func relName(elem ...string) (name string, err error) {
+	name = filepath.Join(elem...)
+	if name == "" {
+		return ".", nil
+	}
+	if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return "", &os.PathError{Op: "open", Path: name, Err: os.ErrInvalid}
+	}
+
+	return name, nil
+}
+
+// open opens a file (or a directory) of the procfs, e.g. open("net", "dev")
+// opens /proc/net/dev. The errors are *os.PathErrors, as the ones of
+// os.Open.
+func (r *procReader) open(elem ...string) (*os.File, error) {
+	name, err := relName(elem...)
+	if err != nil {
+		return nil, err
+	}
+	if r.dir == nil {
+		return os.Open(filepath.Join(r.root, name))
+	}
+
+	return r.openBeneath(name)
+}
+
+// parse reads a file of the procfs into a buffer of procBufPool and calls
+// parse with its content, that can't be retained after parse returns.
+func (r *procReader) parse(parse func(content []byte) error, elem ...string) error {
+	f, err := r.open(elem...)
+	if err != nil {
+		return err
+	}
+
+	return parseFile(f, parse)
+}
+
+// parseFile reads f into a buffer of procBufPool, calls parse with its
+// content and closes f.
+func parseFile(f *os.File, parse func(content []byte) error) error {
+	defer f.Close()
+
+	bufPtr := procBufPool.Get().(*[]byte)
+	defer procBufPool.Put(bufPtr)
+	buf := (*bufPtr)[:0]
+	for {
+		if len(buf) == cap(buf) {
+			buf = append(buf, 0)[:len(buf)]
+		}
+		n, err := f.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	*bufPtr = buf
+
+	return parse(buf)
+}
+
+// readFile reads a file of the procfs, like ioutil.ReadFile. The file is
+// read into a pooled buffer, so only its content is allocated.
+func (r *procReader) readFile(elem ...string) (content []byte, err error) {
+	err = r.parse(func(buf []byte) error {
+		content = append(make([]byte, 0, len(buf)), buf...)
+		return nil
+	}, elem...)
+	if err != nil {
+		return nil, err
+	}
+
+	return content, nil
+}
+
+// readString reads a file of the procfs and returns its content without the
+// leading and trailing white spaces.
+func (r *procReader) readString(elem ...string) (value string, err error) {
+	err = r.parse(func(buf []byte) error {
+		value = strings.TrimSpace(string(buf))
+		return nil
+	}, elem...)
+
+	return value, err
+}
+
+// readUint reads a file of the procfs that only contains an unsigned
+// integer.
+func (r *procReader) readUint(elem ...string) (value uint64, err error) {
+	err = r.parse(func(buf []byte) error {
+		value, err = strconv.ParseUint(strings.TrimSpace(string(buf)), 10, 64)
+		return err
+	}, elem...)
+
+	return value, err
+}
+
+// readProcDirNames returns the names of the entries of a directory of the
+// procfs (e.g. readProcDirNames("1", "task") returns the threads of init),
+// like Readdirnames.
+func readProcDirNames(elem ...string) (names []string, err error) {
+	dir, err := getProcReader().open(elem...)
+	if err != nil {
+		return nil, err
+	}
+	defer dir.Close()
+
+	return dir.Readdirnames(-1)
+}
+
+// readPath reads the file path, like ioutil.ReadFile. The files of the
+// procfs (e.g. the extra files of a snapshot) are read with getProcReader.
+func readPath(path string) (content []byte, err error) {
+	reader := getProcReader()
+	if rel, err := filepath.Rel(reader.root, path); err == nil && filepath.IsAbs(path) {
+		if _, err := relName(rel); err == nil {
+			return reader.readFile(rel)
+		}
+	}
+
+	return ioutil.ReadFile(path)
+}
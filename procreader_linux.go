@@ -0,0 +1,92 @@
+// +build linux
+
+package sysstats
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+// The resolve flags of openat2 (linux/openat2.h).
+const (
+	resolveNoMagiclinks = 0x02 // Don't follow the magic links (e.g. /proc/<pid>/fd/*)
+	resolveBeneath      = 0x08 // Don't resolve outside of the directory
+)
+
+// openHow is the struct open_how of openat2.
+type openHow struct {
+	flags   uint64
+	mode    uint64
+	resolve uint64
+}
+
+// openat2Unsupported is 1 if openat2 isn't available (kernels before 5.6,
+// or seccomp profiles that deny it): the files are then opened with openat.
+var openat2Unsupported int32
+
+// newProcReader returns a procReader of the procfs mounted at root. If the
+// mount point can't be opened, the files are opened by path (and they fail
+// as they would with os.Open).
+func newProcReader(root string) *procReader {
+	reader := &procReader{root: root}
+	if dir, err := os.OpenFile(root, os.O_RDONLY|syscall.O_DIRECTORY, 0); err == nil {
+		reader.dir = dir
+	}
+
+	return reader
+}
+
+// openBeneath opens the file name, relative to the mount point, with
+// openat2 and RESOLVE_BENEATH. Without openat2, it's opened with openat:
+// relName already rejected the paths that go up, but not the symlinks that
+// escape the mount point.
+func (r *procReader) openBeneath(name string) (*os.File, error) {
+	// The finalizer of r.dir can't close it while its descriptor is used
+	defer runtime.KeepAlive(r.dir)
+	dirfd := int(r.dir.Fd())
+	path := filepath.Join(r.root, name)
+
+	for atomic.LoadInt32(&openat2Unsupported) == 0 {
+		fd, err := openat2(dirfd, name, &openHow{flags: syscall.O_RDONLY | syscall.O_CLOEXEC, resolve: resolveBeneath | resolveNoMagiclinks})
+		switch err {
+		case nil:
+			return os.NewFile(uintptr(fd), path), nil
+		case syscall.EINTR, syscall.EAGAIN:
+			// EAGAIN: a concurrent rename made the kernel retry the lookup
+			continue
+		case syscall.ENOSYS, syscall.EPERM:
+			atomic.StoreInt32(&openat2Unsupported, 1)
+		default:
+			return nil, &os.PathError{Op: "openat2", Path: path, Err: err}
+		}
+	}
+
+	for {
+		fd, err := syscall.Openat(dirfd, name, syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return nil, &os.PathError{Op: "openat", Path: path, Err: err}
+		}
+		return os.NewFile(uintptr(fd), path), nil
+	}
+}
+
+// openat2 calls the openat2 syscall (5.6+).
+func openat2(dirfd int, name string, how *openHow) (fd int, err error) {
+	namePtr, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return -1, err
+	}
+	r, _, errno := syscall.Syscall6(sysOpenat2, uintptr(dirfd), uintptr(unsafe.Pointer(namePtr)), uintptr(unsafe.Pointer(how)), unsafe.Sizeof(*how), 0, 0)
+	if errno != 0 {
+		return -1, errno
+	}
+
+	return int(r), nil
+}
@@ -0,0 +1,52 @@
+// +build linux
+
+package sysstats
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestProcReaderBeneath(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, `proc`)
+	if err := os.MkdirAll(filepath.Join(root, `net`), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, `net`, `dev`), []byte("dev\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, `secret`), []byte("secret\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, `secret`), filepath.Join(root, `escape`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(`../secret`, filepath.Join(root, `up`)); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := newProcReader(root)
+	if content, err := reader.readString(`net`, `dev`); err != nil || content != `dev` {
+		t.Errorf("net/dev %q (%v), want dev", content, err)
+	}
+	for _, elem := range [][]string{{`..`, `secret`}, {`net`, `..`, `..`, `secret`}, {`/etc/passwd`}} {
+		if _, err := reader.readFile(elem...); !errors.Is(err, os.ErrInvalid) {
+			t.Errorf("%v: error %v, want %v", elem, err, os.ErrInvalid)
+		}
+	}
+	// The symlinks out of the procfs are only rejected by openat2
+	if atomic.LoadInt32(&openat2Unsupported) == 0 {
+		for _, name := range []string{`escape`, `up`} {
+			if _, err := reader.readFile(name); err == nil {
+				t.Errorf("%s: the symlink out of the procfs was followed", name)
+			}
+		}
+	} else {
+		t.Log("openat2 isn't available: the symlinks aren't checked")
+	}
+}
@@ -0,0 +1,19 @@
+// +build !linux
+
+package sysstats
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// newProcReader returns a procReader of the procfs mounted at root. The
+// files are opened by path: only linux has openat2.
+func newProcReader(root string) *procReader {
+	return &procReader{root: root}
+}
+
+// openBeneath opens the file name, relative to the mount point.
+func (r *procReader) openBeneath(name string) (*os.File, error) {
+	return os.Open(filepath.Join(r.root, name))
+}
@@ -0,0 +1,165 @@
+package sysstats
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ProcStats represents the processes statistics (NOT counted since boot)
+type ProcStats struct {
+	Running uint64 `json:"running"` // # of processes in runnable state (Linux 2.5.45 onward)
+	// # of processes blocked waiting for I/O to complete (Linux 2.5.45 onward)
+	Blocked uint64 `json:"blocked"`
+	// # of currently runnable kernel scheduling entities (processes, threads)
+	RunQueue uint64 `json:"runqueue"`
+	// # of kernel scheduling entities that currently exist on the system
+	Total uint64 `json:"total"`
+}
+
+// ProcRawStats represents the raw processes statistics
+type ProcRawStats struct {
+	Processes uint64 `json:"processes"` // # of forks since boot
+	ProcStats
+	Time int64 `json:"time"` // Time when the sample was taken (Unix time)
+}
+
+// ProcAvgStats represents the processes statistics
+type ProcAvgStats struct {
+	NewProcs float64 `json:"newprocs"` // # of forks per second
+	ProcStats
+}
+
+// getProcRawStats gets the processes stats of a linux system from the files
+// /proc/loadavg and /proc/stat.
+// It returns a ProcRawStats var.
+func getProcRawStats() (procRawStats ProcRawStats, err error) {
+	now := clockNow().Unix()
+
+	loadavg, err := getProcReader().readFile("loadavg")
+	if err != nil {
+		return ProcRawStats{}, err
+	}
+	stat, err := getProcReader().readFile("stat")
+	if err != nil {
+		return ProcRawStats{}, err
+	}
+
+	return readProcRawStats(loadavg, stat, now)
+}
+
+// procStatFields are the lines of /proc/stat read into ProcRawStats, by their
+// name in the file, and their index in the stats of readProcRawStats. The
+// rest of the lines are skipped.
+var procStatFields = map[string]int{
+	`processes`:     0,
+	`procs_running`: 1,
+	`procs_blocked`: 2,
+}
+
+// readProcRawStats reads the processes stats from the contents of the files
+// /proc/loadavg and /proc/stat. now is the time of the sample.
+func readProcRawStats(loadavg []byte, stat []byte, now int64) (procRawStats ProcRawStats, err error) {
+	procRawStats = ProcRawStats{}
+	procRawStats.Time = now
+
+	// Get runnable and total processes from /proc/loadavg, that has the
+	// following format:
+	//   0.20 0.18 0.12 1/80 11206
+	var fields [5][]byte
+	n := 0
+	line, _ := nextLine(loadavg)
+	for field, rest := nextField(line); len(field) > 0; field, rest = nextField(rest) {
+		if n < len(fields) {
+			fields[n] = field
+		}
+		n++
+	}
+	if n != len(fields) {
+		return ProcRawStats{}, &ParseError{File: "/proc/loadavg", Line: 1, Field: `fields`, Err: errors.New("It should have 5 fields")}
+	}
+	// The two values we are interested in are in the fourth field (it consists
+	// of two numbers separated by a slash '/')
+	slash := bytes.IndexByte(fields[3], '/')
+	if slash < 0 || bytes.IndexByte(fields[3][slash+1:], '/') >= 0 {
+		return ProcRawStats{}, &ParseError{File: "/proc/loadavg", Line: 1, Field: `entities`, Err: errors.New("Unexpected field: " + string(fields[3]))}
+	}
+	var ok bool
+	if procRawStats.RunQueue, ok = parseUintBytes(fields[3][:slash]); !ok {
+		return ProcRawStats{}, parseUintError("/proc/loadavg", 1, `runqueue`, fields[3][:slash])
+	}
+	if procRawStats.Total, ok = parseUintBytes(fields[3][slash+1:]); !ok {
+		return ProcRawStats{}, parseUintError("/proc/loadavg", 1, `total`, fields[3][slash+1:])
+	}
+
+	// Get total, running and blocked processes from /proc/stat (an array of
+	// the fields instead of functions returning them, so procRawStats
+	// doesn't escape to the heap)
+	stats := [...]*uint64{&procRawStats.Processes, &procRawStats.Running, &procRawStats.Blocked}
+	for i := 1; len(stat) > 0; i++ {
+		line, stat = nextLine(stat)
+		name, rest := nextField(line)
+		// The lookup with the converted name doesn't allocate
+		index, ok := procStatFields[string(name)]
+		if !ok {
+			continue
+		}
+		value, _ := nextField(rest)
+		if *stats[index], ok = parseUintBytes(value); !ok {
+			return ProcRawStats{}, parseUintError("/proc/stat", i, string(name), value)
+		}
+	}
+
+	return procRawStats, nil
+}
+
+// parseUintError returns the ParseError of the field name of the line of
+// file that parseUintBytes couldn't parse, with the error of
+// strconv.ParseUint.
+func parseUintError(file string, line int, name string, field []byte) error {
+	_, err := strconv.ParseUint(string(field), 10, 64)
+	return &ParseError{File: file, Line: line, Field: name, Err: err}
+}
+
+// getProcAvgStats calculates the average between 2 ProcRawStats samples.
+func getProcAvgStats(firstSample ProcRawStats, secondSample ProcRawStats) (procAvgStats ProcAvgStats, err error) {
+	procAvgStats = ProcAvgStats{}
+
+	timeDelta := float64(secondSample.Time - firstSample.Time)
+
+	// Calculate number of new processes created per second
+	procAvgStats.NewProcs = counterRate(firstSample.Processes, secondSample.Processes, timeDelta)
+
+	// The other values of procAvgStats will be taken from the second sample because
+	// they are "current" values (not counted since boot)
+	procAvgStats.Running = secondSample.Running
+	procAvgStats.Blocked = secondSample.Blocked
+	procAvgStats.RunQueue = secondSample.RunQueue
+	procAvgStats.Total = secondSample.Total
+
+	return procAvgStats, nil
+}
+
+// getProcStatsInterval returns the processes statistics between 2 samples.
+// Time interval between the 2 samples is given in seconds
+func getProcStatsInterval(interval int64) (procAvgStats ProcAvgStats, err error) {
+	firstSample, err := getProcRawStats()
+	if err != nil {
+		return ProcAvgStats{}, err
+	}
+
+	time.Sleep(time.Duration(interval) * time.Second)
+
+	secondSample, err := getProcRawStats()
+	if err != nil {
+		return ProcAvgStats{}, err
+	}
+
+	procAvgStats, err = getProcAvgStats(firstSample, secondSample)
+	if err != nil {
+		return ProcAvgStats{}, err
+	}
+
+	return procAvgStats, nil
+}
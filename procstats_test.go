@@ -0,0 +1,84 @@
+package sysstats
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// readProcFixture returns the content of a fixture of testdata/proc.
+func readProcFixture(tb testing.TB, elem ...string) []byte {
+	content, err := ioutil.ReadFile(filepath.Join(append([]string{"testdata", "proc"}, elem...)...))
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return content
+}
+
+func TestReadProcRawStatsLoadAvg(t *testing.T) {
+	tests := []struct {
+		loadavg string
+		ok      bool
+	}{
+		{"1.24 0.98 0.87 3/1042 248731\n", true},
+		{"1.24 0.98 0.87 3 248731\n", false},
+		{"1.24 0.98 0.87 3/1042/7 248731\n", false},
+		{"1.24 0.98 0.87 x/1042 248731\n", false},
+		{"1.24 0.98 0.87 3/ 248731\n", false},
+		{"1.24 0.98 0.87 3/10", false},
+	}
+	for _, test := range tests {
+		procRawStats, err := readProcRawStats([]byte(test.loadavg), nil, 0)
+		if !test.ok {
+			var parseErr *ParseError
+			if err == nil {
+				t.Errorf("%q: no error, got %+v", test.loadavg, procRawStats)
+			} else if !errors.As(err, &parseErr) {
+				t.Errorf("%q: error %v isn't a ParseError", test.loadavg, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.loadavg, err)
+		} else if procRawStats.RunQueue != 3 || procRawStats.Total != 1042 {
+			t.Errorf("%q: runqueue %d and total %d, want 3 and 1042", test.loadavg, procRawStats.RunQueue, procRawStats.Total)
+		}
+	}
+}
+
+func TestReadProcRawStatsAllocs(t *testing.T) {
+	loadavg := readProcFixture(t, "loadavg")
+	stat := readProcFixture(t, "stat")
+	procRawStats, err := readProcRawStats(loadavg, stat, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if procRawStats.Processes != 1287312 || procRawStats.Running != 2 || procRawStats.RunQueue != 3 || procRawStats.Total != 1042 {
+		t.Errorf("Processes stats %+v", procRawStats)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { readProcRawStats(loadavg, stat, 0) }); allocs > 0 {
+		t.Errorf("readProcRawStats allocates %.0f times, want 0", allocs)
+	}
+}
+
+func BenchmarkReadProcRawStats(b *testing.B) {
+	loadavg := readProcFixture(b, "loadavg")
+	stat := readProcFixture(b, "stat")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		readProcRawStats(loadavg, stat, 0)
+	}
+}
+
+func FuzzReadProcRawStats(f *testing.F) {
+	f.Add(readProcFixture(f, "loadavg"), readProcFixture(f, "stat"))
+	f.Add([]byte("1.24 0.98 0.87 3 248731\n"), []byte("processes 12"))
+	f.Add([]byte("1.24 0.98 0.87 /\n"), []byte("procs_running\nprocs_blocked 99999999999999999999999\n"))
+	f.Add([]byte("1.24 0.98 0.87 3/1042/ 248731"), []byte("processes\n\n"))
+	f.Fuzz(func(t *testing.T, loadavg []byte, stat []byte) {
+		readProcRawStats(loadavg, stat, 0)
+	})
+}
@@ -0,0 +1,228 @@
+package prometheus
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/rafacas/sysstats"
+)
+
+// DroppedSeriesMetric is the metric with the # of series of each metric
+// dropped by a MaxSeries guard in the last batch (metric label).
+const DroppedSeriesMetric = `prometheus.droppedseries`
+
+// Relabel represents *one* relabeling rule of the labels of the metrics of a
+// collector, like the replace action of the Prometheus relabel_configs.
+type Relabel struct {
+	Label       string // Label whose value is matched (the sysstats name, e.g. iface)
+	Regex       string // Regular expression that must match the whole value (any value if empty). The metrics without the label have an empty value
+	Replacement string // Value written, with the groups of the regular expression ($1, ${name}...). The label is removed if it's empty
+	Target      string // Label written (Label if empty)
+}
+
+// CollectorLimits represents the cardinality controls of the metrics of
+// *one* collector (the first part of their names, e.g. proc for
+// proc.cpuper). They are applied in order: the relabeling rules, the label
+// allowlist and the max # of series of each metric.
+type CollectorLimits struct {
+	Relabel   []Relabel // Relabeling rules, applied in order
+	Labels    []string  // Labels kept after the relabeling (all if nil, none if empty): the values whose labels are then the same are added together
+	MaxSeries int       // Max # of series of each metric (unlimited if 0): the ones with the highest values are kept
+}
+
+// Limits are the cardinality controls of the metrics by collector. The raw
+// per-process or per-connection metrics can create a series per PID or per
+// socket, which a TSDB keeps long after they are gone:
+//   limits := prometheus.Limits{
+//       `proc`: {
+//           Relabel:   []prometheus.Relabel{{Label: `cmdline`, Replacement: ``}},
+//           Labels:    []string{`comm`},
+//           MaxSeries: 50,
+//       },
+//   }
+//   exporter.SetLimits(limits)
+type Limits map[string]CollectorLimits
+
+// limiter is the compiled form of Limits.
+type limiter map[string]collectorLimiter
+
+// collectorLimiter is the compiled form of CollectorLimits.
+type collectorLimiter struct {
+	relabel   []relabelRule
+	labels    map[string]bool // nil if all the labels are kept
+	maxSeries int
+}
+
+// relabelRule is the compiled form of a Relabel.
+type relabelRule struct {
+	Relabel
+	re *regexp.Regexp
+}
+
+// compile checks the limits and compiles their regular expressions.
+func (limits Limits) compile() (l limiter, err error) {
+	l = make(limiter, len(limits))
+	for collector, collectorLimits := range limits {
+		if collectorLimits.MaxSeries < 0 {
+			return nil, errors.New("The max # of series of the collector " + collector + " can't be negative")
+		}
+		compiled := collectorLimiter{maxSeries: collectorLimits.MaxSeries}
+		for _, relabel := range collectorLimits.Relabel {
+			if relabel.Label == `` {
+				return nil, errors.New("A relabeling rule of the collector " + collector + " doesn't have a label")
+			}
+			rule := relabelRule{Relabel: relabel}
+			if rule.Target == `` {
+				rule.Target = rule.Label
+			}
+			regex := relabel.Regex
+			if regex == `` {
+				regex = `.*`
+			}
+			// Anchored as the Prometheus ones
+			if rule.re, err = regexp.Compile(`^(?:` + regex + `)$`); err != nil {
+				return nil, errors.New("Invalid regular expression of a relabeling rule of the collector " + collector + ": " + err.Error())
+			}
+			compiled.relabel = append(compiled.relabel, rule)
+		}
+		if collectorLimits.Labels != nil {
+			compiled.labels = make(map[string]bool, len(collectorLimits.Labels))
+			for _, label := range collectorLimits.Labels {
+				compiled.labels[label] = true
+			}
+		}
+		l[collector] = compiled
+	}
+
+	return l, nil
+}
+
+// Apply applies the limits to metrics and returns the limited metrics,
+// grouped by metric in the order of metrics, followed by the
+// DroppedSeriesMetric of the metrics some of whose series were dropped. The
+// metrics of the collectors without limits are returned as they are.
+func (limits Limits) Apply(metrics []sysstats.Metric) ([]sysstats.Metric, error) {
+	l, err := limits.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	return l.apply(metrics), nil
+}
+
+// metricCollector returns the collector of a metric (the first part of its
+// name).
+func metricCollector(name string) string {
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		return name[:i]
+	}
+
+	return name
+}
+
+// apply applies the compiled limits to metrics (see Limits.Apply).
+func (l limiter) apply(metrics []sysstats.Metric) (limited []sysstats.Metric) {
+	if len(l) == 0 {
+		return metrics
+	}
+
+	// The series of each metric by their labels, in order
+	names := make([]string, 0, 64)
+	series := map[string][]sysstats.Metric{}
+	index := map[string]map[string]int{}
+	for _, metric := range metrics {
+		collectorLimits, ok := l[metricCollector(metric.Name)]
+		if ok {
+			metric.Labels = collectorLimits.limitLabels(metric.Labels)
+		}
+		if _, seen := series[metric.Name]; !seen {
+			names = append(names, metric.Name)
+			index[metric.Name] = map[string]int{}
+		}
+		if !ok {
+			series[metric.Name] = append(series[metric.Name], metric)
+			continue
+		}
+		key := formatLabels(metric.Labels)
+		if i, ok := index[metric.Name][key]; ok {
+			series[metric.Name][i].Value += metric.Value
+			continue
+		}
+		index[metric.Name][key] = len(series[metric.Name])
+		series[metric.Name] = append(series[metric.Name], metric)
+	}
+
+	limited = make([]sysstats.Metric, 0, len(metrics))
+	dropped := []sysstats.Metric{}
+	for _, name := range names {
+		metricSeries := series[name]
+		maxSeries := l[metricCollector(name)].maxSeries
+		if maxSeries > 0 && len(metricSeries) > maxSeries {
+			metricSeries = topSeries(metricSeries, maxSeries)
+			dropped = append(dropped, sysstats.Metric{
+				Name:   DroppedSeriesMetric,
+				Labels: map[string]string{`metric`: name},
+				Value:  float64(len(series[name]) - maxSeries),
+			})
+		}
+		limited = append(limited, metricSeries...)
+	}
+
+	return append(limited, dropped...)
+}
+
+// limitLabels returns the labels after the relabeling rules and the
+// allowlist (a copy: the labels of the metrics of the batch are shared with
+// the other exporters).
+func (c collectorLimiter) limitLabels(labels map[string]string) map[string]string {
+	limited := make(map[string]string, len(labels))
+	for name, value := range labels {
+		limited[name] = value
+	}
+	for _, rule := range c.relabel {
+		value := limited[rule.Label]
+		match := rule.re.FindStringSubmatchIndex(value)
+		if match == nil {
+			continue
+		}
+		replacement := string(rule.re.ExpandString(nil, rule.Replacement, value, match))
+		if replacement == `` {
+			delete(limited, rule.Target)
+		} else {
+			limited[rule.Target] = replacement
+		}
+	}
+	if c.labels != nil {
+		for name := range limited {
+			if !c.labels[name] {
+				delete(limited, name)
+			}
+		}
+	}
+
+	return limited
+}
+
+// topSeries returns the n series with the highest absolute values, in their
+// order.
+func topSeries(series []sysstats.Metric, n int) []sysstats.Metric {
+	order := make([]int, len(series))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return math.Abs(series[order[i]].Value) > math.Abs(series[order[j]].Value)
+	})
+	kept := order[:n]
+	sort.Ints(kept)
+
+	top := make([]sysstats.Metric, n)
+	for i, index := range kept {
+		top[i] = series[index]
+	}
+
+	return top
+}
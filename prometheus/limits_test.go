@@ -0,0 +1,59 @@
+package prometheus
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rafacas/sysstats"
+)
+
+func TestLimitsApply(t *testing.T) {
+	limits := Limits{
+		`proc`: {
+			Relabel: []Relabel{
+				{Label: `cmdline`, Replacement: ``},
+				{Label: `comm`, Regex: `(kworker)/.*`, Replacement: `$1`},
+			},
+			Labels:    []string{`comm`},
+			MaxSeries: 2,
+		},
+	}
+	metrics := []sysstats.Metric{
+		{Name: `proc.cpuper`, Labels: map[string]string{`pid`: `1`, `comm`: `systemd`, `cmdline`: `/sbin/init`}, Value: 1},
+		{Name: `proc.cpuper`, Labels: map[string]string{`pid`: `20`, `comm`: `kworker/0:1`}, Value: 2},
+		{Name: `proc.cpuper`, Labels: map[string]string{`pid`: `21`, `comm`: `kworker/1:0`}, Value: 3},
+		{Name: `proc.cpuper`, Labels: map[string]string{`pid`: `300`, `comm`: `postgres`}, Value: 4},
+		{Name: `cpu.user`, Labels: map[string]string{`cpu`: `cpu0`}, Value: 10},
+	}
+
+	limited, err := limits.Apply(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []sysstats.Metric{
+		// The kworkers are added together and only the 2 highest series are kept
+		{Name: `proc.cpuper`, Labels: map[string]string{`comm`: `kworker`}, Value: 5},
+		{Name: `proc.cpuper`, Labels: map[string]string{`comm`: `postgres`}, Value: 4},
+		{Name: `cpu.user`, Labels: map[string]string{`cpu`: `cpu0`}, Value: 10},
+		{Name: DroppedSeriesMetric, Labels: map[string]string{`metric`: `proc.cpuper`}, Value: 1},
+	}
+	if !reflect.DeepEqual(limited, want) {
+		t.Errorf("Limited metrics %+v, want %+v", limited, want)
+	}
+	// The labels of the batch are shared with the other exporters
+	if _, ok := metrics[0].Labels[`cmdline`]; !ok {
+		t.Error("The labels of the batch were modified")
+	}
+}
+
+func TestLimitsErrors(t *testing.T) {
+	for name, limits := range map[string]Limits{
+		`negative max`:  {`proc`: {MaxSeries: -1}},
+		`no label`:      {`proc`: {Relabel: []Relabel{{Regex: `.*`}}}},
+		`invalid regex`: {`proc`: {Relabel: []Relabel{{Label: `comm`, Regex: `(`}}}},
+	} {
+		if _, err := limits.Apply(nil); err == nil {
+			t.Errorf("%s: the limits were applied", name)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+// Package prometheus converts the sysstats metrics into the Prometheus text
+// exposition format, so they can be scraped without the Prometheus client
+// library. The Exporter keeps the last batch of an ExporterManager and
+// serves it as an http.Handler:
+//   exporter := prometheus.NewExporter()
+//   manager.Add(exporter)
+//   http.Handle("/metrics", exporter)
+//
+// The names of the metrics have the sysstats_ prefix and underscores instead
+// of dots (cpu.user is sysstats_cpu_user), and the iface and disk labels are
+// renamed to interface and device. The cardinality of the per-process or
+// per-connection metrics can be limited per collector with Limits (label
+// allowlists, relabeling and a max # of series of each metric), and the
+// scrapes can be authenticated with a sysstats.Authenticator (bearer tokens
+// or client certificates).
+package prometheus
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rafacas/sysstats"
+)
+
+// ContentType is the content type of the text exposition format.
+const ContentType = `text/plain; version=0.0.4; charset=utf-8`
+
+// Prefix is the prefix of the names of the metrics.
+const Prefix = `sysstats_`
+
+// labelNames are the Prometheus names of the sysstats labels that don't
+// follow the Prometheus conventions.
+var labelNames = map[string]string{
+	`iface`: `interface`,
+	`disk`:  `device`,
+}
+
+// Write writes metrics to w in the text exposition format. The values of a
+// metric are grouped together (in the order of metrics) with the help and
+// type of the metric catalogue.
+func Write(w io.Writer, metrics []sysstats.Metric) error {
+	// Group the values by metric, keeping the order of the metrics
+	names := make([]string, 0, 64)
+	groups := map[string][]sysstats.Metric{}
+	for _, metric := range metrics {
+		if _, ok := groups[metric.Name]; !ok {
+			names = append(names, metric.Name)
+		}
+		groups[metric.Name] = append(groups[metric.Name], metric)
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		promName := MetricName(name)
+		metricType := `untyped`
+		help := name
+		info, ok := sysstats.LookupMetric(name)
+		if !ok {
+			info, ok = exporterMetrics[name]
+		}
+		if ok {
+			metricType = info.Type
+			if info.Description != `` {
+				help = info.Description
+				if info.Unit != `` {
+					help += ` (` + info.Unit + `)`
+				}
+			}
+		}
+		bw.WriteString(`# HELP ` + promName + ` ` + escapeHelp(help) + "\n")
+		bw.WriteString(`# TYPE ` + promName + ` ` + metricType + "\n")
+		for _, metric := range groups[name] {
+			bw.WriteString(promName + formatLabels(metric.Labels) + ` ` + formatValue(metric.Value) + "\n")
+		}
+	}
+
+	return bw.Flush()
+}
+
+// MetricName returns the Prometheus name of a sysstats metric (e.g.
+// sysstats_cpu_user for cpu.user). The characters not allowed are replaced
+// with underscores.
+func MetricName(name string) string {
+	return Prefix + sanitizeName(name, true)
+}
+
+// LabelName returns the Prometheus name of a sysstats label (e.g. interface
+// for iface).
+func LabelName(name string) string {
+	if promName, ok := labelNames[name]; ok {
+		return promName
+	}
+
+	return sanitizeName(name, false)
+}
+
+// sanitizeName replaces the characters not allowed in the Prometheus names
+// ([a-zA-Z0-9_], and colons in the metric names) with underscores. The names
+// can't start with a digit.
+func sanitizeName(name string, colons bool) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+		case r >= '0' && r <= '9' && i > 0:
+		case r == ':' && colons:
+		default:
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// formatLabels returns the labels (sorted by name) as they are in the text
+// exposition format: {device="sda",interface="eth0"}. It returns an empty
+// string if there are no labels.
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ``
+	}
+
+	pairs := make([]string, 0, len(labels))
+	for name, value := range labels {
+		pairs = append(pairs, LabelName(name)+`="`+escapeLabelValue(value)+`"`)
+	}
+	sort.Strings(pairs)
+
+	return `{` + strings.Join(pairs, `,`) + `}`
+}
+
+// formatValue returns a value as it is in the text exposition format.
+func formatValue(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return `NaN`
+	case math.IsInf(value, 1):
+		return `+Inf`
+	case math.IsInf(value, -1):
+		return `-Inf`
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// escapeHelp escapes the backslashes and new lines of a help string.
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
+
+// escapeLabelValue escapes the backslashes, double quotes and new lines of a
+// label value.
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// exporterMetrics has the metadata of the metrics of the package, which
+// aren't in the catalogue of sysstats.
+var exporterMetrics = map[string]sysstats.MetricInfo{
+	DroppedSeriesMetric: {Name: DroppedSeriesMetric, Type: `gauge`, Unit: `series`, Labels: []string{`metric`}, Description: `Series of the metric dropped by the max # of series of its collector`},
+}
+
+// Exporter is a sysstats.Exporter that keeps the last batch of metrics and
+// serves it in the text exposition format (it's an http.Handler), so it can
+// be mounted on /metrics. The cardinality of the metrics can be limited with
+// SetLimits, and the scrapes can be authenticated with SetAuthenticator.
+type Exporter struct {
+	mu      sync.RWMutex
+	metrics []sysstats.Metric
+	limiter limiter
+	auth    *sysstats.Authenticator
+}
+
+// NewExporter returns an Exporter without metrics.
+func NewExporter() *Exporter {
+	return &Exporter{metrics: []sysstats.Metric{}}
+}
+
+// Name returns the name of the exporter.
+func (e *Exporter) Name() string {
+	return `prometheus`
+}
+
+// Start doesn't do anything: the exporter is served by the http.Server of
+// the application.
+func (e *Exporter) Start(ctx context.Context) error {
+	return nil
+}
+
+// SetLimits sets the cardinality controls of the metrics, applied from the
+// next batch. It returns an error if a relabeling rule is invalid.
+func (e *Exporter) SetLimits(limits Limits) error {
+	l, err := limits.compile()
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.limiter = l
+
+	return nil
+}
+
+// SetAuthenticator sets the Authenticator of the scrapes, which need
+// sysstats.AccessRead. The scrapes aren't authenticated if auth is nil (the
+// default).
+func (e *Exporter) SetAuthenticator(auth *sysstats.Authenticator) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.auth = auth
+}
+
+// Export replaces the metrics served by the last batch, limited by the
+// limits of SetLimits.
+func (e *Exporter) Export(metrics []sysstats.Metric) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.metrics = e.limiter.apply(metrics)
+	return nil
+}
+
+// Flush doesn't do anything: the metrics are scraped.
+func (e *Exporter) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Stop doesn't do anything: the last metrics are still served.
+func (e *Exporter) Stop(ctx context.Context) error {
+	return nil
+}
+
+// ServeHTTP writes the last batch of metrics in the text exposition format
+// (if the scrape is authenticated by the Authenticator of SetAuthenticator).
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.RLock()
+	auth := e.auth
+	e.mu.RUnlock()
+
+	if auth != nil {
+		auth.Handler(sysstats.AccessRead, http.HandlerFunc(e.serveMetrics)).ServeHTTP(w, r)
+		return
+	}
+	e.serveMetrics(w, r)
+}
+
+// serveMetrics writes the last batch of metrics in the text exposition
+// format.
+func (e *Exporter) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	e.mu.RLock()
+	metrics := e.metrics
+	e.mu.RUnlock()
+
+	w.Header().Set(`Content-Type`, ContentType)
+	Write(w, metrics)
+}
@@ -0,0 +1,136 @@
+package sysstats
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// RedactionPolicy represents which sensitive values are removed from the
+// metrics and the collectors output before they leave the host. The same
+// policy can be used by the ExporterManager (SetRedactionPolicy) and the
+// diagnostic bundles (BundleConfig), so every sink redacts the same fields.
+type RedactionPolicy struct {
+	Fields      []string // Names of the fields (JSON keys and metric labels) whose values are redacted (e.g. comm, cmdline, environ, uid, user)
+	IPAddresses bool     // Whether the IPv4 and IPv6 addresses are redacted in all the string values
+	Replacement string   // Value that replaces the redacted values (default [REDACTED])
+}
+
+var (
+	reRedactIPv4 = regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}\b`)
+	reRedactIPv6 = regexp.MustCompile(`[0-9A-Fa-f]{0,4}(?::[0-9A-Fa-f]{0,4}){1,6}:\d{1,3}(?:\.\d{1,3}){3}|[0-9A-Fa-f]{0,4}(?::[0-9A-Fa-f]{0,4}){2,7}`)
+)
+
+// Enabled returns true if the policy redacts anything.
+func (policy RedactionPolicy) Enabled() bool {
+	return len(policy.Fields) > 0 || policy.IPAddresses
+}
+
+// replacement returns the value that replaces the redacted values.
+func (policy RedactionPolicy) replacement() string {
+	if policy.Replacement == `` {
+		return `[REDACTED]`
+	}
+
+	return policy.Replacement
+}
+
+// redactsField returns true if the values of the field name are redacted
+// (case insensitive).
+func (policy RedactionPolicy) redactsField(name string) bool {
+	for _, field := range policy.Fields {
+		if strings.EqualFold(field, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RedactString returns s with the IP addresses redacted (if the policy
+// redacts them).
+func (policy RedactionPolicy) RedactString(s string) string {
+	if !policy.IPAddresses {
+		return s
+	}
+
+	replace := func(ip string) string {
+		if net.ParseIP(ip) == nil {
+			return ip
+		}
+		return policy.replacement()
+	}
+	// IPv6 first, so the IPv4-mapped addresses (::ffff:10.0.0.1) are
+	// replaced as a whole
+	s = reRedactIPv6.ReplaceAllStringFunc(s, replace)
+
+	return reRedactIPv4.ReplaceAllStringFunc(s, replace)
+}
+
+// RedactMetrics returns a copy of metrics with the values of the redacted
+// labels replaced and the IP addresses of the labels redacted. metrics isn't
+// modified.
+func (policy RedactionPolicy) RedactMetrics(metrics []Metric) []Metric {
+	if !policy.Enabled() {
+		return metrics
+	}
+
+	redacted := make([]Metric, len(metrics))
+	for i, metric := range metrics {
+		labels := make(map[string]string, len(metric.Labels))
+		for name, value := range metric.Labels {
+			if policy.redactsField(name) {
+				labels[name] = policy.replacement()
+			} else {
+				labels[name] = policy.RedactString(value)
+			}
+		}
+		metric.Labels = labels
+		redacted[i] = metric
+	}
+
+	return redacted
+}
+
+// RedactJSON redacts a JSON document: the values (of any type) of the
+// redacted fields are replaced and the IP addresses of all the strings are
+// redacted. The numbers are kept as they are (uint64 counters don't lose
+// precision).
+func (policy RedactionPolicy) RedactJSON(data []byte) (redacted []byte, err error) {
+	if !policy.Enabled() {
+		return data, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(policy.redactValue(value))
+}
+
+// redactValue redacts a decoded JSON value.
+func (policy RedactionPolicy) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if policy.redactsField(key) {
+				v[key] = policy.replacement()
+			} else {
+				v[key] = policy.redactValue(field)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = policy.redactValue(v[i])
+		}
+	case string:
+		return policy.RedactString(v)
+	}
+
+	return value
+}
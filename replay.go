@@ -0,0 +1,121 @@
+package sysstats
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// ReplayProvider provides the statistics of recorded snapshots (e.g. the
+// snapshots.json of a diagnostic bundle) instead of reading them from /proc.
+// It works on every platform, including js/wasm, so the recordings can be
+// explored in a browser with the same analysis code as the agent (rates,
+// topology changes, metrics and alerting rules).
+type ReplayProvider struct {
+	mu        sync.Mutex
+	snapshots []Snapshot
+}
+
+// NewReplayProvider returns a ReplayProvider with the snapshots of blobs (see
+// Add).
+func NewReplayProvider(blobs ...[]byte) (*ReplayProvider, error) {
+	p := &ReplayProvider{snapshots: []Snapshot{}}
+	for _, blob := range blobs {
+		if err := p.Add(blob); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// Add adds the snapshots of a blob: a snapshot or an array of snapshots
+// serialized as JSON by any version of the library. The snapshots are kept
+// sorted by the time they were taken.
+func (p *ReplayProvider) Add(blob []byte) error {
+	blob = bytes.TrimSpace(blob)
+	if len(blob) == 0 {
+		return errors.New("The snapshots blob is empty")
+	}
+
+	var snapshots []Snapshot
+	if blob[0] == '[' {
+		var err error
+		if snapshots, err = unmarshalSnapshots(blob); err != nil {
+			return err
+		}
+	} else {
+		snapshot, err := UnmarshalSnapshot(blob)
+		if err != nil {
+			return err
+		}
+		snapshots = []Snapshot{snapshot}
+	}
+
+	p.AddSnapshots(snapshots...)
+
+	return nil
+}
+
+// AddSnapshots adds snapshots (e.g. the ones of ImportSar). The snapshots are
+// kept sorted by the time they were taken.
+func (p *ReplayProvider) AddSnapshots(snapshots ...Snapshot) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.snapshots = append(p.snapshots, snapshots...)
+	sort.SliceStable(p.snapshots, func(i, j int) bool {
+		return p.snapshots[i].CollectedAt.Wall.Before(p.snapshots[j].CollectedAt.Wall)
+	})
+}
+
+// Len returns the # of snapshots.
+func (p *ReplayProvider) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.snapshots)
+}
+
+// Snapshot returns the snapshot i (0 is the oldest one).
+func (p *ReplayProvider) Snapshot(i int) (snapshot Snapshot, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if i < 0 || i >= len(p.snapshots) {
+		return Snapshot{}, errors.New("Snapshot " + strconv.Itoa(i) + " doesn't exist")
+	}
+
+	return p.snapshots[i], nil
+}
+
+// AvgStats returns the statistics between the snapshots i-1 and i.
+func (p *ReplayProvider) AvgStats(i int) (avgStats SnapshotAvgStats, err error) {
+	if i < 1 {
+		return SnapshotAvgStats{}, errors.New("The statistics need a previous snapshot")
+	}
+	first, err := p.Snapshot(i - 1)
+	if err != nil {
+		return SnapshotAvgStats{}, err
+	}
+	second, err := p.Snapshot(i)
+	if err != nil {
+		return SnapshotAvgStats{}, err
+	}
+
+	return getSnapshotAvgStats(first, second)
+}
+
+// Metrics returns the metrics between the snapshots i-1 and i and the errors
+// of the derived metrics (see SnapshotAvgStats.Metrics).
+func (p *ReplayProvider) Metrics(i int) (metrics []Metric, errs map[string]error, err error) {
+	avgStats, err := p.AvgStats(i)
+	if err != nil {
+		return nil, nil, err
+	}
+	metrics, errs = avgStats.Metrics()
+
+	return metrics, errs, nil
+}
@@ -0,0 +1,435 @@
+// Package report renders a capacity summary of a history window of samples
+// (peak CPU, memory headroom, disk growth and network peaks) as text,
+// Markdown or HTML, for the periodic capacity reviews:
+//   r, err := report.New(samples)
+//   r.Render(os.Stdout, report.Markdown)
+//
+// The samples can be built from the Watch samples and the file system usage
+// with NewSample (linux only), or from any other source (e.g. a time series
+// database).
+package report
+
+import (
+	"bufio"
+	"errors"
+	"html"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rafacas/sysstats"
+	"github.com/rafacas/sysstats/numfmt"
+)
+
+// Format represents the format of a rendered report.
+type Format int
+
+const (
+	Text     Format = iota // Plain text with aligned columns
+	Markdown               // Markdown tables
+	HTML                   // HTML fragment with tables
+)
+
+// Sample represents the statistics of *one* point of the history window.
+// The statistics that weren't collected are nil.
+//
+// Cpus map keys are the names of the CPUs; the "cpu" key has all the CPUs.
+// Net map keys are the names of the network interfaces.
+type Sample struct {
+	Time        time.Time                      // When the sample was taken
+	Cpus        map[string]sysstats.CpuUsage   // % CPU usage
+	Mem         *sysstats.MemInfo              // Memory statistics
+	Net         map[string]sysstats.IfaceRates // Network interfaces rates (per second)
+	Filesystems []Filesystem                   // Usage of the file systems
+}
+
+// Filesystem represents the usage of *one* file system in a sample.
+type Filesystem struct {
+	MountPoint string // Mount point
+	Total      uint64 // Size of the file system in bytes
+	Used       uint64 // Used space in bytes
+}
+
+// CpuSummary represents the CPU usage (% of CPU time not idle of all the
+// CPUs) over the window.
+type CpuSummary struct {
+	Peak   float64   `json:"peak"`   // Max % of CPU usage
+	PeakAt time.Time `json:"peakat"` // Time of the sample with the max usage
+	Avg    float64   `json:"avg"`    // Average % of CPU usage
+	P95    float64   `json:"p95"`    // 95th percentile of the % of CPU usage
+}
+
+// MemorySummary represents the memory usage over the window. The used memory
+// doesn't include the buffers and the page cache (it's memtotal - realfree),
+// and the headroom is the memory that isn't used. The sizes are in
+// kilobytes.
+type MemorySummary struct {
+	Total          uint64    `json:"total"`          // Total size of memory (of the last sample)
+	PeakUsed       uint64    `json:"peakused"`       // Max used memory
+	PeakUsedAt     time.Time `json:"peakusedat"`     // Time of the sample with the max used memory
+	AvgUsed        uint64    `json:"avgused"`        // Average used memory
+	MinHeadroom    uint64    `json:"minheadroom"`    // Min memory not used
+	MinHeadroomPer float64   `json:"minheadroomper"` // Min % of memory not used
+}
+
+// FilesystemGrowth represents the growth of the used space of *one* file
+// system over the window. The sizes are in bytes.
+type FilesystemGrowth struct {
+	MountPoint   string  `json:"mountpoint"`   // Mount point
+	Total        uint64  `json:"total"`        // Size of the file system (of the last sample)
+	FirstUsed    uint64  `json:"firstused"`    // Used space in the first sample
+	LastUsed     uint64  `json:"lastused"`     // Used space in the last sample
+	Growth       int64   `json:"growth"`       // Growth of the used space (negative if it shrank)
+	GrowthPerDay float64 `json:"growthperday"` // Growth of the used space per day
+	DaysToFull   float64 `json:"daystofull"`   // Days until it's full at the growth rate of the window (0 if it isn't growing)
+}
+
+// NetSummary represents the traffic of *one* network interface over the
+// window. The rates are in bytes per second.
+type NetSummary struct {
+	Iface    string    `json:"iface"`    // Name of the interface
+	PeakRx   float64   `json:"peakrx"`   // Max rate of bytes received
+	PeakRxAt time.Time `json:"peakrxat"` // Time of the sample with the max rate of bytes received
+	PeakTx   float64   `json:"peaktx"`   // Max rate of bytes transmitted
+	PeakTxAt time.Time `json:"peaktxat"` // Time of the sample with the max rate of bytes transmitted
+	AvgRx    float64   `json:"avgrx"`    // Average rate of bytes received
+	AvgTx    float64   `json:"avgtx"`    // Average rate of bytes transmitted
+}
+
+// Report represents the capacity summary of a history window. The summaries
+// of the statistics that aren't in any sample are empty.
+type Report struct {
+	From        time.Time          `json:"from"`        // Time of the first sample
+	To          time.Time          `json:"to"`          // Time of the last sample
+	Samples     int                `json:"samples"`     // # of samples
+	Cpu         CpuSummary         `json:"cpu"`         // CPU usage
+	Memory      MemorySummary      `json:"memory"`      // Memory usage and headroom
+	Filesystems []FilesystemGrowth `json:"filesystems"` // Growth of the file systems (sorted by mount point)
+	Net         []NetSummary       `json:"net"`         // Traffic of the network interfaces (sorted by name)
+}
+
+// New returns the capacity report of the samples of a history window (in
+// any order).
+func New(samples []Sample) (report Report, err error) {
+	if len(samples) == 0 {
+		return Report{}, errors.New("The report needs at least one sample")
+	}
+
+	samples = append([]Sample(nil), samples...)
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Time.Before(samples[j].Time) })
+	report.From = samples[0].Time
+	report.To = samples[len(samples)-1].Time
+	report.Samples = len(samples)
+
+	report.Cpu = newCpuSummary(samples)
+	report.Memory = newMemorySummary(samples)
+	report.Filesystems = newFilesystemGrowths(samples)
+	report.Net = newNetSummaries(samples)
+
+	return report, nil
+}
+
+// newCpuSummary returns the CPU usage of the samples.
+func newCpuSummary(samples []Sample) (summary CpuSummary) {
+	usages := make([]float64, 0, len(samples))
+	for _, sample := range samples {
+		usage, ok := sample.Cpus[`cpu`]
+		if !ok {
+			continue
+		}
+		if len(usages) == 0 || usage.Total > summary.Peak {
+			summary.Peak, summary.PeakAt = usage.Total, sample.Time
+		}
+		summary.Avg += usage.Total
+		usages = append(usages, usage.Total)
+	}
+	if len(usages) == 0 {
+		return CpuSummary{}
+	}
+	summary.Avg /= float64(len(usages))
+	sort.Float64s(usages)
+	summary.P95 = usages[int(math.Ceil(0.95*float64(len(usages))))-1]
+
+	return summary
+}
+
+// newMemorySummary returns the memory usage of the samples.
+func newMemorySummary(samples []Sample) (summary MemorySummary) {
+	var n, totalUsed uint64
+	for _, sample := range samples {
+		if sample.Mem == nil || sample.Mem.MemTotal == 0 {
+			continue
+		}
+		used := uint64(0)
+		if sample.Mem.MemTotal > sample.Mem.RealFree {
+			used = sample.Mem.MemTotal - sample.Mem.RealFree
+		}
+		headroom := sample.Mem.MemTotal - used
+		headroomPer := 100 * float64(headroom) / float64(sample.Mem.MemTotal)
+		if n == 0 || used > summary.PeakUsed {
+			summary.PeakUsed, summary.PeakUsedAt = used, sample.Time
+		}
+		if n == 0 || headroom < summary.MinHeadroom {
+			summary.MinHeadroom = headroom
+		}
+		if n == 0 || headroomPer < summary.MinHeadroomPer {
+			summary.MinHeadroomPer = headroomPer
+		}
+		summary.Total = sample.Mem.MemTotal
+		totalUsed += used
+		n++
+	}
+	if n == 0 {
+		return MemorySummary{}
+	}
+	summary.AvgUsed = totalUsed / n
+
+	return summary
+}
+
+// newFilesystemGrowths returns the growth of the file systems of the samples
+// between the first and the last sample that have each of them.
+func newFilesystemGrowths(samples []Sample) (growths []FilesystemGrowth) {
+	type fsWindow struct {
+		first, last         Filesystem
+		firstTime, lastTime time.Time
+	}
+	windows := map[string]*fsWindow{}
+	for _, sample := range samples {
+		for _, fs := range sample.Filesystems {
+			window, ok := windows[fs.MountPoint]
+			if !ok {
+				window = &fsWindow{first: fs, firstTime: sample.Time}
+				windows[fs.MountPoint] = window
+			}
+			window.last, window.lastTime = fs, sample.Time
+		}
+	}
+
+	growths = make([]FilesystemGrowth, 0, len(windows))
+	for mountPoint, window := range windows {
+		growth := FilesystemGrowth{
+			MountPoint: mountPoint,
+			Total:      window.last.Total,
+			FirstUsed:  window.first.Used,
+			LastUsed:   window.last.Used,
+			Growth:     int64(window.last.Used) - int64(window.first.Used),
+		}
+		if days := window.lastTime.Sub(window.firstTime).Hours() / 24; days > 0 {
+			growth.GrowthPerDay = float64(growth.Growth) / days
+		}
+		if growth.GrowthPerDay > 0 && growth.Total > growth.LastUsed {
+			growth.DaysToFull = float64(growth.Total-growth.LastUsed) / growth.GrowthPerDay
+		}
+		growths = append(growths, growth)
+	}
+	sort.Slice(growths, func(i, j int) bool { return growths[i].MountPoint < growths[j].MountPoint })
+
+	return growths
+}
+
+// newNetSummaries returns the traffic of the network interfaces of the
+// samples.
+func newNetSummaries(samples []Sample) (summaries []NetSummary) {
+	byIface := map[string]*NetSummary{}
+	counts := map[string]int{}
+	for _, sample := range samples {
+		for iface, rates := range sample.Net {
+			summary, ok := byIface[iface]
+			if !ok {
+				summary = &NetSummary{Iface: iface}
+				byIface[iface] = summary
+			}
+			if !ok || rates.RxBytes > summary.PeakRx {
+				summary.PeakRx, summary.PeakRxAt = rates.RxBytes, sample.Time
+			}
+			if !ok || rates.TxBytes > summary.PeakTx {
+				summary.PeakTx, summary.PeakTxAt = rates.TxBytes, sample.Time
+			}
+			summary.AvgRx += rates.RxBytes
+			summary.AvgTx += rates.TxBytes
+			counts[iface]++
+		}
+	}
+
+	summaries = make([]NetSummary, 0, len(byIface))
+	for iface, summary := range byIface {
+		summary.AvgRx /= float64(counts[iface])
+		summary.AvgTx /= float64(counts[iface])
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Iface < summaries[j].Iface })
+
+	return summaries
+}
+
+// section represents *one* table of a rendered report.
+type section struct {
+	title  string
+	header []string
+	rows   [][]string
+}
+
+// Render writes the report to w in format, with the numbers formatted by
+// numfmt.Default.
+func (r Report) Render(w io.Writer, format Format) error {
+	return r.RenderFormatted(w, format, numfmt.Default)
+}
+
+// RenderFormatted writes the report to w in format, with the numbers
+// formatted by f (e.g. with the separators of the locale of the user).
+func (r Report) RenderFormatted(w io.Writer, format Format, f numfmt.Formatter) error {
+	title := `Capacity report ` + formatTime(r.From) + ` - ` + formatTime(r.To) + ` (` + f.Number(float64(r.Samples), 0) + ` samples)`
+	sections := r.sections(f)
+
+	bw := bufio.NewWriter(w)
+	switch format {
+	case Text:
+		renderText(bw, title, sections)
+	case Markdown:
+		renderMarkdown(bw, title, sections)
+	case HTML:
+		renderHTML(bw, title, sections)
+	default:
+		return errors.New("Unknown report format " + strconv.Itoa(int(format)))
+	}
+
+	return bw.Flush()
+}
+
+// sections returns the tables of the report, with the numbers formatted by
+// f. The summaries without samples are skipped.
+func (r Report) sections(f numfmt.Formatter) (sections []section) {
+	if !r.Cpu.PeakAt.IsZero() {
+		sections = append(sections, section{
+			title:  `CPU`,
+			header: []string{`Peak`, `Peak at`, `Average`, `P95`},
+			rows:   [][]string{{f.Percent(r.Cpu.Peak), formatTime(r.Cpu.PeakAt), f.Percent(r.Cpu.Avg), f.Percent(r.Cpu.P95)}},
+		})
+	}
+	if r.Memory.Total > 0 {
+		sections = append(sections, section{
+			title:  `Memory`,
+			header: []string{`Total`, `Peak used`, `Peak at`, `Average used`, `Min headroom`},
+			rows: [][]string{{
+				f.Bytes(float64(r.Memory.Total) * 1024),
+				f.Bytes(float64(r.Memory.PeakUsed) * 1024),
+				formatTime(r.Memory.PeakUsedAt),
+				f.Bytes(float64(r.Memory.AvgUsed) * 1024),
+				f.Bytes(float64(r.Memory.MinHeadroom)*1024) + ` (` + f.Percent(r.Memory.MinHeadroomPer) + `)`,
+			}},
+		})
+	}
+	if len(r.Filesystems) > 0 {
+		fsSection := section{title: `Disk growth`, header: []string{`Mount point`, `Size`, `Used`, `Growth`, `Growth per day`, `Days to full`}}
+		for _, fs := range r.Filesystems {
+			daysToFull := `-`
+			if fs.DaysToFull > 0 {
+				daysToFull = f.Number(fs.DaysToFull, 1)
+			}
+			fsSection.rows = append(fsSection.rows, []string{
+				fs.MountPoint,
+				f.Bytes(float64(fs.Total)),
+				f.Bytes(float64(fs.LastUsed)),
+				f.Bytes(float64(fs.Growth)),
+				f.Bytes(fs.GrowthPerDay),
+				daysToFull,
+			})
+		}
+		sections = append(sections, fsSection)
+	}
+	if len(r.Net) > 0 {
+		netSection := section{title: `Network peaks`, header: []string{`Interface`, `Peak rx`, `Peak rx at`, `Peak tx`, `Peak tx at`, `Average rx`, `Average tx`}}
+		for _, net := range r.Net {
+			netSection.rows = append(netSection.rows, []string{
+				net.Iface,
+				f.Bytes(net.PeakRx) + `/s`,
+				formatTime(net.PeakRxAt),
+				f.Bytes(net.PeakTx) + `/s`,
+				formatTime(net.PeakTxAt),
+				f.Bytes(net.AvgRx) + `/s`,
+				f.Bytes(net.AvgTx) + `/s`,
+			})
+		}
+		sections = append(sections, netSection)
+	}
+
+	return sections
+}
+
+// renderText writes the sections as plain text with the columns aligned.
+func renderText(w io.Writer, title string, sections []section) {
+	io.WriteString(w, title+"\n")
+	for _, s := range sections {
+		io.WriteString(w, "\n"+s.title+"\n")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		io.WriteString(tw, strings.Join(s.header, "\t")+"\n")
+		for _, row := range s.rows {
+			io.WriteString(tw, strings.Join(row, "\t")+"\n")
+		}
+		tw.Flush()
+	}
+}
+
+// renderMarkdown writes the sections as Markdown tables.
+func renderMarkdown(w io.Writer, title string, sections []section) {
+	io.WriteString(w, "# "+title+"\n")
+	for _, s := range sections {
+		io.WriteString(w, "\n## "+s.title+"\n\n")
+		io.WriteString(w, markdownRow(s.header))
+		separators := make([]string, len(s.header))
+		for i := range separators {
+			separators[i] = `---`
+		}
+		io.WriteString(w, markdownRow(separators))
+		for _, row := range s.rows {
+			io.WriteString(w, markdownRow(row))
+		}
+	}
+}
+
+// markdownRow returns a row of a Markdown table. The pipes of the cells are
+// escaped.
+func markdownRow(cells []string) string {
+	escaped := make([]string, 0, len(cells))
+	for _, cell := range cells {
+		escaped = append(escaped, strings.Replace(cell, `|`, `\|`, -1))
+	}
+
+	return `| ` + strings.Join(escaped, ` | `) + " |\n"
+}
+
+// renderHTML writes the sections as an HTML fragment with a table for each
+// one.
+func renderHTML(w io.Writer, title string, sections []section) {
+	io.WriteString(w, "<h1>"+html.EscapeString(title)+"</h1>\n")
+	for _, s := range sections {
+		io.WriteString(w, "<h2>"+html.EscapeString(s.title)+"</h2>\n<table>\n")
+		io.WriteString(w, htmlRow(`th`, s.header))
+		for _, row := range s.rows {
+			io.WriteString(w, htmlRow(`td`, row))
+		}
+		io.WriteString(w, "</table>\n")
+	}
+}
+
+// htmlRow returns a row of an HTML table with the cells in tag elements.
+func htmlRow(tag string, cells []string) string {
+	var b strings.Builder
+	b.WriteString(`<tr>`)
+	for _, cell := range cells {
+		b.WriteString(`<` + tag + `>` + html.EscapeString(cell) + `</` + tag + `>`)
+	}
+	b.WriteString("</tr>\n")
+
+	return b.String()
+}
+
+// formatTime returns a time of the report in UTC.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(`2006-01-02 15:04 MST`)
+}
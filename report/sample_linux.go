@@ -0,0 +1,29 @@
+// +build linux
+
+package report
+
+import (
+	"github.com/rafacas/sysstats"
+)
+
+// NewSample returns the sample of a Watch sample (with WatchCpu, WatchMem and
+// WatchNet) and the usage of the file systems at the same time (e.g. from a
+// FsUsageCollector). The stale file systems and the ones with errors are
+// skipped.
+func NewSample(watchSample sysstats.WatchSample, fsUsageArr []sysstats.FsUsage) Sample {
+	sample := Sample{
+		Time:        watchSample.Time,
+		Cpus:        watchSample.Cpus,
+		Mem:         watchSample.Mem,
+		Net:         watchSample.Net,
+		Filesystems: make([]Filesystem, 0, len(fsUsageArr)),
+	}
+	for _, fsUsage := range fsUsageArr {
+		if fsUsage.Stale || fsUsage.Error != `` {
+			continue
+		}
+		sample.Filesystems = append(sample.Filesystems, Filesystem{MountPoint: fsUsage.MountPoint, Total: fsUsage.Total, Used: fsUsage.Used})
+	}
+
+	return sample
+}
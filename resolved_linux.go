@@ -0,0 +1,166 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os/exec"
+	"strconv"
+)
+
+// ResolvedServer represents *one* DNS server configured in
+// systemd-resolved.
+type ResolvedServer struct {
+	Iface   string `json:"iface"`   // Interface the server is configured on (empty if it's a global server)
+	Address string `json:"address"` // IP address of the server
+	Current bool   `json:"current"` // Whether it's the server resolved is currently using
+}
+
+// ResolvedStats represents the statistics of the systemd-resolved resolver
+// of a linux system.
+//
+// resolved doesn't count the failed transactions: the failures it reports
+// are the DNSSEC validations that failed (bogus).
+type ResolvedStats struct {
+	CurrentTransactions uint64           `json:"currenttransactions"` // # of transactions in flight
+	TotalTransactions   uint64           `json:"totaltransactions"`   // # of transactions since resolved started (or the statistics were reset)
+	CacheSize           uint64           `json:"cachesize"`           // # of entries in the cache
+	CacheHits           uint64           `json:"cachehits"`           // # of lookups answered from the cache
+	CacheMisses         uint64           `json:"cachemisses"`         // # of lookups that needed a transaction
+	CacheHitRate        float64          `json:"cachehitrate"`        // % of lookups answered from the cache
+	DnssecSecure        uint64           `json:"dnssecsecure"`        // # of DNSSEC validations with a secure verdict
+	DnssecInsecure      uint64           `json:"dnssecinsecure"`      // # of DNSSEC validations with an insecure verdict
+	DnssecBogus         uint64           `json:"dnssecbogus"`         // # of DNSSEC validations that failed
+	DnssecIndeterminate uint64           `json:"dnssecindeterminate"` // # of DNSSEC validations with an indeterminate verdict
+	Servers             []ResolvedServer `json:"servers"`             // DNS servers configured (global and per interface)
+}
+
+// resolvedProperties are the properties of org.freedesktop.resolve1.Manager
+// read, in the order they are returned.
+var resolvedProperties = []string{`TransactionStatistics`, `CacheStatistics`, `DNSSECStatistics`, `DNS`, `CurrentDNSServer`}
+
+// busctlValue is *one* value returned by `busctl --json=short`.
+type busctlValue struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// getResolvedStats gets the statistics of systemd-resolved of a linux system
+// from its D-Bus API, running the command:
+//   busctl --json=short get-property org.freedesktop.resolve1 /org/freedesktop/resolve1
+//     org.freedesktop.resolve1.Manager TransactionStatistics CacheStatistics ...
+// busctl is used instead of talking D-Bus directly so the package keeps
+// depending on the standard library only.
+func getResolvedStats() (resolvedStats ResolvedStats, err error) {
+	busctl, err := exec.LookPath("busctl")
+	if err != nil {
+		return ResolvedStats{}, err
+	}
+
+	args := append([]string{"--json=short", "get-property", "org.freedesktop.resolve1", "/org/freedesktop/resolve1",
+		"org.freedesktop.resolve1.Manager"}, resolvedProperties...)
+	out, err := exec.Command(busctl, args...).Output()
+	if err != nil {
+		return ResolvedStats{}, err
+	}
+
+	return parseResolvedStats(out)
+}
+
+// parseResolvedStats parses the output of busctl: one JSON value per
+// property, e.g.
+//   {"type":"(tt)","data":[0,1254]}
+//   {"type":"(ttt)","data":[31,980,274]}
+//   {"type":"(tttt)","data":[0,0,0,0]}
+//   {"type":"a(iiay)","data":[[0,2,[192,168,1,1]],[2,2,[10,0,0,1]]]}
+//   {"type":"(iiay)","data":[0,2,[192,168,1,1]]}
+func parseResolvedStats(out []byte) (resolvedStats ResolvedStats, err error) {
+	values := make([]busctlValue, 0, len(resolvedProperties))
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	for {
+		value := busctlValue{}
+		if err := decoder.Decode(&value); err == io.EOF {
+			break
+		} else if err != nil {
+			return ResolvedStats{}, errors.New("Couldn't parse the output of busctl: " + err.Error())
+		}
+		values = append(values, value)
+	}
+	if len(values) != len(resolvedProperties) {
+		return ResolvedStats{}, errors.New("The output of busctl should have " + strconv.Itoa(len(resolvedProperties)) + " properties")
+	}
+
+	counters := [][]*uint64{
+		{&resolvedStats.CurrentTransactions, &resolvedStats.TotalTransactions},
+		{&resolvedStats.CacheSize, &resolvedStats.CacheHits, &resolvedStats.CacheMisses},
+		{&resolvedStats.DnssecSecure, &resolvedStats.DnssecInsecure, &resolvedStats.DnssecBogus, &resolvedStats.DnssecIndeterminate},
+	}
+	for i, fields := range counters {
+		data := []uint64{}
+		if err := json.Unmarshal(values[i].Data, &data); err != nil || len(data) != len(fields) {
+			return ResolvedStats{}, errors.New("Couldn't parse the property " + resolvedProperties[i] + " of resolved")
+		}
+		for j, field := range fields {
+			*field = data[j]
+		}
+	}
+	if lookups := resolvedStats.CacheHits + resolvedStats.CacheMisses; lookups > 0 {
+		resolvedStats.CacheHitRate = float64(resolvedStats.CacheHits) * 100 / float64(lookups)
+	}
+
+	servers := [][]json.RawMessage{}
+	if err := json.Unmarshal(values[3].Data, &servers); err != nil {
+		return ResolvedStats{}, errors.New("Couldn't parse the property DNS of resolved")
+	}
+	current := []json.RawMessage{}
+	if err := json.Unmarshal(values[4].Data, &current); err != nil {
+		return ResolvedStats{}, errors.New("Couldn't parse the property CurrentDNSServer of resolved")
+	}
+	currentIfindex, currentAddress, currentOk := parseResolvedServer(current)
+
+	resolvedStats.Servers = make([]ResolvedServer, 0, len(servers))
+	for _, server := range servers {
+		ifindex, address, ok := parseResolvedServer(server)
+		if !ok {
+			continue
+		}
+		resolvedServer := ResolvedServer{Address: address}
+		if ifindex != 0 {
+			resolvedServer.Iface = strconv.Itoa(ifindex)
+			if iface, err := net.InterfaceByIndex(ifindex); err == nil {
+				resolvedServer.Iface = iface.Name
+			}
+		}
+		resolvedServer.Current = currentOk && ifindex == currentIfindex && address == currentAddress
+		resolvedStats.Servers = append(resolvedStats.Servers, resolvedServer)
+	}
+
+	return resolvedStats, nil
+}
+
+// parseResolvedServer parses a DNS server of resolved (interface index,
+// address family and address bytes). ok is false if there isn't a server
+// (e.g. CurrentDNSServer is [0,0,[]] when there isn't a current one).
+func parseResolvedServer(server []json.RawMessage) (ifindex int, address string, ok bool) {
+	if len(server) < 3 {
+		return 0, ``, false
+	}
+	// The address is an array of bytes, not a base64 string
+	octets := []int{}
+	if json.Unmarshal(server[0], &ifindex) != nil || json.Unmarshal(server[2], &octets) != nil {
+		return 0, ``, false
+	}
+	if len(octets) != net.IPv4len && len(octets) != net.IPv6len {
+		return 0, ``, false
+	}
+	ip := make(net.IP, len(octets))
+	for i, octet := range octets {
+		ip[i] = byte(octet)
+	}
+
+	return ifindex, ip.String(), true
+}
@@ -0,0 +1,512 @@
+package sysstats
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+// SamplerCollector represents *one* collector run by a Sampler.
+type SamplerCollector struct {
+	Name      string                      // Name of the collector (used to identify its samples)
+	Interval  time.Duration               // Time between runs (default 10 seconds)
+	Jitter    time.Duration               // Max random delay added to each run (default 10% of the interval)
+	Collect   func() (interface{}, error) // Function that collects the stats (e.g. GetMemStats)
+	Expensive bool                        // Whether the collector is expensive (e.g. it runs a command). They are dropped while an adaptive sampler is overloaded
+}
+
+// AdaptiveConfig represents the configuration of the adaptive mode of a
+// sampler. While the CPU usage of the process or the host pressure (PSI) are
+// over their thresholds the sampler is overloaded: the intervals of the
+// collectors are doubled on every check (up to MaxBackoff times) and the
+// expensive collectors aren't run. Once both are under their thresholds the
+// intervals are halved on every check until they are back to normal.
+type AdaptiveConfig struct {
+	MaxCpu        float64       // Max % CPU usage of the process (default 5%)
+	MaxPressure   float64       // Max "some avg10" of /proc/pressure/{cpu,io,memory} (default 20%)
+	MaxBackoff    int           // Max factor the intervals are multiplied by (default 8)
+	CheckInterval time.Duration // Time between checks (default 10 seconds)
+}
+
+// BudgetConfig represents the cost budget of every run of a collector. A
+// collector that exceeds it is throttled: its interval is doubled (up to
+// MaxThrottle times) on every run over budget and halved on every run within
+// budget.
+//
+// The CPU time is measured on the thread the collector runs on, so it doesn't
+// include the commands it runs. The allocations are measured for the whole
+// process, so they include the allocations of other goroutines running at
+// the same time, and the runtime accounts small allocations in batches, so
+// they can be accounted to a later run.
+type BudgetConfig struct {
+	CpuTime     time.Duration // Max CPU time of a run (0 means no limit)
+	Alloc       uint64        // Max bytes allocated by a run (0 means no limit)
+	MaxThrottle int           // Max factor the interval is multiplied by (default 8)
+}
+
+// Sample represents the output of *one* run of a collector.
+type Sample struct {
+	Collector  string        `json:"collector"`  // Name of the collector
+	Time       time.Time     `json:"time"`       // When the collector was run
+	Duration   time.Duration `json:"duration"`   // Time the collector took
+	CpuTime    time.Duration `json:"cputime"`    // CPU time the collector took
+	Alloc      uint64        `json:"alloc"`      // Bytes allocated while the collector ran
+	OverBudget bool          `json:"overbudget"` // Whether the run exceeded the budget of the sampler
+	Value      interface{}   `json:"value"`      // Stats collected
+	Error      string        `json:"error"`      // Error of the collector (empty if it succeeded)
+}
+
+// SamplerSelfStats represents the self-metrics of *one* collector of a
+// sampler.
+type SamplerSelfStats struct {
+	Collector   string        `json:"collector"`   // Name of the collector
+	Runs        uint64        `json:"runs"`        // # of runs
+	Skipped     uint64        `json:"skipped"`     // # of runs skipped because the previous one hadn't finished
+	Dropped     uint64        `json:"dropped"`     // # of runs dropped because the sampler was overloaded
+	OverBudget  uint64        `json:"overbudget"`  // # of runs that exceeded the budget
+	Throttle    int           `json:"throttle"`    // Factor the interval is multiplied by because of the budget
+	LastCpuTime time.Duration `json:"lastcputime"` // CPU time of the last run
+	LastAlloc   uint64        `json:"lastalloc"`   // Bytes allocated by the last run
+	Interval    time.Duration `json:"interval"`    // Time between runs (see SetInterval)
+	Enabled     bool          `json:"enabled"`     // Whether the collector is run (see SetEnabled)
+}
+
+// Sampler runs a set of collectors, each one at its own interval (e.g. CPU
+// every second, filesystems every minute, SMART every 30 minutes), within a
+// single scheduler. A random delay (jitter) is added to every run so the
+// agents of a fleet started at the same time don't read at the same time.
+// A collector isn't run again while its previous run hasn't finished.
+type Sampler struct {
+	collectors []SamplerCollector
+	handler    func(Sample)
+	mu         sync.Mutex
+	running    []bool
+	skipped    []uint64
+	dropped    []uint64
+	disabled   []bool
+	changed    []bool
+	reschedule chan struct{}
+	selfStats  []SamplerSelfStats
+	adaptive   *AdaptiveConfig
+	budget     *BudgetConfig
+	backoff    int
+	overloaded bool
+	rand       *rand.Rand
+}
+
+// NewSampler returns a Sampler for the given collectors. handler is called
+// (from the collector goroutine) with the output of every run.
+func NewSampler(collectors []SamplerCollector, handler func(Sample)) *Sampler {
+	sampler := &Sampler{
+		collectors: make([]SamplerCollector, len(collectors)),
+		handler:    handler,
+		running:    make([]bool, len(collectors)),
+		skipped:    make([]uint64, len(collectors)),
+		dropped:    make([]uint64, len(collectors)),
+		disabled:   make([]bool, len(collectors)),
+		changed:    make([]bool, len(collectors)),
+		reschedule: make(chan struct{}, 1),
+		selfStats:  make([]SamplerSelfStats, len(collectors)),
+		backoff:    1,
+		// Seeded with the time of the clock, so the jitter is deterministic
+		// with a FakeClock
+		rand: rand.New(rand.NewSource(clockNow().UnixNano())),
+	}
+	for i, collector := range collectors {
+		sampler.collectors[i] = collector.withDefaults()
+		sampler.selfStats[i] = SamplerSelfStats{Collector: collector.Name, Throttle: 1}
+	}
+
+	return sampler
+}
+
+// withDefaults returns the collector with the default interval and jitter
+// if they aren't set.
+func (collector SamplerCollector) withDefaults() SamplerCollector {
+	if collector.Interval <= 0 {
+		collector.Interval = 10 * time.Second
+	}
+	if collector.Jitter <= 0 {
+		collector.Jitter = collector.Interval / 10
+	}
+
+	return collector
+}
+
+// Add adds a collector to the sampler (e.g. the one of a Prober to the
+// sampler of an Agent). It must be called before Run. It returns an error if
+// the sampler already has a collector with the same name.
+func (s *Sampler) Add(collector SamplerCollector) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index(collector.Name) >= 0 {
+		return errors.New("The sampler already has the collector " + collector.Name)
+	}
+	s.collectors = append(s.collectors, collector.withDefaults())
+	s.running = append(s.running, false)
+	s.skipped = append(s.skipped, 0)
+	s.dropped = append(s.dropped, 0)
+	s.disabled = append(s.disabled, false)
+	s.changed = append(s.changed, false)
+	s.selfStats = append(s.selfStats, SamplerSelfStats{Collector: collector.Name, Throttle: 1})
+
+	return nil
+}
+
+// SetAdaptive enables the adaptive mode of the sampler. It must be called
+// before Run.
+func (s *Sampler) SetAdaptive(config AdaptiveConfig) {
+	if config.MaxCpu <= 0 {
+		config.MaxCpu = 5
+	}
+	if config.MaxPressure <= 0 {
+		config.MaxPressure = 20
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 8
+	}
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = 10 * time.Second
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.adaptive = &config
+}
+
+// SetBudget sets the cost budget of every run of the collectors. It must be
+// called before Run.
+func (s *Sampler) SetBudget(config BudgetConfig) {
+	if config.MaxThrottle <= 0 {
+		config.MaxThrottle = 8
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.budget = &config
+}
+
+// SetEnabled enables or disables a collector. It can be called while the
+// sampler runs: a disabled collector isn't run (its runs aren't counted as
+// skipped or dropped) until it's enabled again. It returns an error if the
+// sampler doesn't have the collector.
+func (s *Sampler) SetEnabled(name string, enabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(name)
+	if i < 0 {
+		return errors.New("The sampler doesn't have the collector " + name)
+	}
+	s.disabled[i] = !enabled
+
+	return nil
+}
+
+// SetInterval changes the interval of a collector (and its jitter to the
+// default, 10% of the interval). It can be called while the sampler runs:
+// the next run of the collector is rescheduled to the new interval from the
+// call. It returns an error if the interval isn't positive or the sampler
+// doesn't have the collector.
+func (s *Sampler) SetInterval(name string, interval time.Duration) error {
+	if interval <= 0 {
+		return errors.New("The interval of a collector must be positive")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(name)
+	if i < 0 {
+		return errors.New("The sampler doesn't have the collector " + name)
+	}
+	s.collectors[i].Interval = interval
+	s.collectors[i].Jitter = interval / 10
+	s.changed[i] = true
+	select {
+	case s.reschedule <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// index returns the index of the collector name (-1 if the sampler doesn't
+// have it). s.mu must be held.
+func (s *Sampler) index(name string) int {
+	for i, collector := range s.collectors {
+		if collector.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Run runs the collectors until ctx is done. Then it waits for the runs in
+// progress to finish.
+func (s *Sampler) Run(ctx context.Context) error {
+	if len(s.collectors) == 0 {
+		return errors.New("The sampler doesn't have any collector")
+	}
+
+	// base are the runs without jitter, so the jitter doesn't accumulate
+	clock := getClock()
+	now := clock.Now()
+	base := make([]time.Time, len(s.collectors))
+	next := make([]time.Time, len(s.collectors))
+	s.mu.Lock()
+	for i, collector := range s.collectors {
+		base[i] = now
+		next[i] = now.Add(s.jitter(collector.Jitter))
+		s.changed[i] = false
+	}
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	s.mu.Lock()
+	adaptive := s.adaptive
+	s.mu.Unlock()
+	if adaptive != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.adapt(ctx, *adaptive)
+		}()
+	}
+
+	for {
+		// Next collector to run
+		i := 0
+		for j := range next {
+			if next[j].Before(next[i]) {
+				i = j
+			}
+		}
+
+		timer := clock.NewTimer(next[i].Sub(clock.Now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-s.reschedule:
+			timer.Stop()
+			// The intervals changed by SetInterval start now
+			s.mu.Lock()
+			now := clock.Now()
+			for j := range s.changed {
+				if s.changed[j] {
+					s.changed[j] = false
+					base[j] = now.Add(s.collectors[j].Interval)
+					next[j] = base[j].Add(s.jitter(s.collectors[j].Jitter))
+				}
+			}
+			s.mu.Unlock()
+			continue
+		case <-timer.C():
+		}
+
+		s.mu.Lock()
+		backoff := s.backoff * s.selfStats[i].Throttle
+		collector := s.collectors[i]
+		if s.disabled[i] {
+			// It's still scheduled, so it runs on time once it's enabled again
+		} else if s.running[i] {
+			s.skipped[i]++
+		} else if s.overloaded && collector.Expensive {
+			s.dropped[i]++
+		} else {
+			s.running[i] = true
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				s.run(i)
+			}(i)
+		}
+		s.mu.Unlock()
+
+		base[i] = base[i].Add(collector.Interval * time.Duration(backoff))
+		// Don't try to catch up the runs missed (e.g. after a suspend)
+		if now := clock.Now(); base[i].Before(now) {
+			base[i] = now
+		}
+		next[i] = base[i].Add(s.jitter(collector.Jitter))
+	}
+}
+
+// Skipped returns the # of runs of each collector skipped because its
+// previous run hadn't finished.
+func (s *Sampler) Skipped() (skipped map[string]uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	skipped = make(map[string]uint64, len(s.collectors))
+	for i, collector := range s.collectors {
+		skipped[collector.Name] = s.skipped[i]
+	}
+
+	return skipped
+}
+
+// SelfStats returns the self-metrics of each collector.
+func (s *Sampler) SelfStats() (selfStats []SamplerSelfStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	selfStats = make([]SamplerSelfStats, len(s.selfStats))
+	for i, stats := range s.selfStats {
+		stats.Skipped = s.skipped[i]
+		stats.Dropped = s.dropped[i]
+		stats.Interval = s.collectors[i].Interval
+		stats.Enabled = !s.disabled[i]
+		selfStats[i] = stats
+	}
+
+	return selfStats
+}
+
+// Dropped returns the # of runs of each expensive collector dropped because
+// the sampler was overloaded.
+func (s *Sampler) Dropped() (dropped map[string]uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dropped = make(map[string]uint64, len(s.collectors))
+	for i, collector := range s.collectors {
+		dropped[collector.Name] = s.dropped[i]
+	}
+
+	return dropped
+}
+
+// Backoff returns the factor the intervals of the collectors are multiplied
+// by (1 if the sampler isn't backing off).
+func (s *Sampler) Backoff() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.backoff
+}
+
+// adapt checks the CPU usage of the process and the host pressure every check
+// interval and updates the backoff of the sampler until ctx is done.
+func (s *Sampler) adapt(ctx context.Context, config AdaptiveConfig) {
+	// The clock of the package, so the checks are on the time of a
+	// Simulation too
+	clock := getClock()
+	lastTime := clock.Now()
+	lastCpu, _ := processCpuTime()
+	for {
+		timer := clock.NewTimer(config.CheckInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		now := clock.Now()
+		cpuTime, err := processCpuTime()
+		if err != nil {
+			continue
+		}
+		cpu := float64(cpuTime-lastCpu) * 100 / float64(now.Sub(lastTime))
+		lastTime, lastCpu = now, cpuTime
+		// Errors reading PSI are ignored: the CPU usage is still checked
+		pressure, _ := hostPressure()
+
+		s.mu.Lock()
+		s.overloaded = cpu > config.MaxCpu || pressure > config.MaxPressure
+		if s.overloaded && s.backoff < config.MaxBackoff {
+			s.backoff *= 2
+			if s.backoff > config.MaxBackoff {
+				s.backoff = config.MaxBackoff
+			}
+		} else if !s.overloaded && s.backoff > 1 {
+			s.backoff /= 2
+		}
+		s.mu.Unlock()
+	}
+}
+
+// run runs the collector i and calls the handler with its output.
+func (s *Sampler) run(i int) {
+	s.mu.Lock()
+	collector := s.collectors[i]
+	s.mu.Unlock()
+	sample := Sample{Collector: collector.Name, Time: clockNow()}
+
+	// The thread CPU time is only meaningful if the collector doesn't move
+	// to another thread
+	runtime.LockOSThread()
+	startCpu, _ := threadCpuTime()
+	startAlloc := heapAllocs()
+	value, err := collector.Collect()
+	endCpu, _ := threadCpuTime()
+	endAlloc := heapAllocs()
+	runtime.UnlockOSThread()
+
+	sample.Duration = clockNow().Sub(sample.Time)
+	if endCpu > startCpu {
+		sample.CpuTime = endCpu - startCpu
+	}
+	if endAlloc > startAlloc {
+		sample.Alloc = endAlloc - startAlloc
+	}
+	sample.Value = value
+	if err != nil {
+		sample.Error = err.Error()
+	}
+
+	s.mu.Lock()
+	s.running[i] = false
+	stats := &s.selfStats[i]
+	stats.Runs++
+	stats.LastCpuTime = sample.CpuTime
+	stats.LastAlloc = sample.Alloc
+	if s.budget != nil {
+		sample.OverBudget = (s.budget.CpuTime > 0 && sample.CpuTime > s.budget.CpuTime) ||
+			(s.budget.Alloc > 0 && sample.Alloc > s.budget.Alloc)
+		if sample.OverBudget {
+			stats.OverBudget++
+			if stats.Throttle *= 2; stats.Throttle > s.budget.MaxThrottle {
+				stats.Throttle = s.budget.MaxThrottle
+			}
+		} else if stats.Throttle > 1 {
+			stats.Throttle /= 2
+		}
+	}
+	s.mu.Unlock()
+
+	if s.handler != nil {
+		s.handler(sample)
+	}
+}
+
+// heapAllocs returns the bytes allocated in the heap by the process since it
+// started.
+func heapAllocs() uint64 {
+	sample := []metrics.Sample{{Name: "/gc/heap/allocs:bytes"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+
+	return sample[0].Value.Uint64()
+}
+
+// jitter returns a random duration in [0, max). It's only called from Run.
+func (s *Sampler) jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+
+	return time.Duration(s.rand.Int63n(int64(max)))
+}
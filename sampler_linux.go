@@ -0,0 +1,47 @@
+// +build linux
+
+package sysstats
+
+import (
+	"syscall"
+	"time"
+)
+
+// processCpuTime returns the CPU time (user + system) used by the process.
+func processCpuTime() (cpuTime time.Duration, err error) {
+	var rusage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &rusage); err != nil {
+		return 0, err
+	}
+
+	return time.Duration(rusage.Utime.Nano() + rusage.Stime.Nano()), nil
+}
+
+// hostPressure returns the highest "some avg10" of the CPU, IO and memory
+// pressure (PSI). It returns 0 if the kernel doesn't have PSI.
+func hostPressure() (pressure float64, err error) {
+	pressureStats, err := getPressureStats()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, resourcePressure := range []ResourcePressure{pressureStats.Cpu, pressureStats.Io, pressureStats.Memory} {
+		if resourcePressure.Some.Avg10 > pressure {
+			pressure = resourcePressure.Some.Avg10
+		}
+	}
+
+	return pressure, nil
+}
+
+// threadCpuTime returns the CPU time (user + system) used by the calling
+// thread.
+func threadCpuTime() (cpuTime time.Duration, err error) {
+	var rusage syscall.Rusage
+	// RUSAGE_THREAD is 1
+	if err := syscall.Getrusage(1, &rusage); err != nil {
+		return 0, err
+	}
+
+	return time.Duration(rusage.Utime.Nano() + rusage.Stime.Nano()), nil
+}
@@ -0,0 +1,28 @@
+// +build !linux
+
+package sysstats
+
+import (
+	"errors"
+	"runtime"
+	"time"
+)
+
+// processCpuTime returns the CPU time used by the process. It isn't supported
+// outside linux, so the adaptive mode of the samplers doesn't limit the CPU
+// usage.
+func processCpuTime() (cpuTime time.Duration, err error) {
+	return 0, errors.New("processCpuTime: " + runtime.GOOS + " not supported yet")
+}
+
+// hostPressure returns the pressure of the host. There is no PSI outside
+// linux, so it's always 0.
+func hostPressure() (pressure float64, err error) {
+	return 0, nil
+}
+
+// threadCpuTime returns the CPU time used by the calling thread. It isn't
+// supported outside linux, so the CPU budget of the samplers isn't enforced.
+func threadCpuTime() (cpuTime time.Duration, err error) {
+	return 0, errors.New("threadCpuTime: " + runtime.GOOS + " not supported yet")
+}
@@ -0,0 +1,374 @@
+package sysstats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sarExport is the JSON export of a sa data file (sadf -j).
+type sarExport struct {
+	Sysstat struct {
+		Hosts []struct {
+			Nodename   string          `json:"nodename"`
+			Statistics []sarStatistics `json:"statistics"`
+		} `json:"hosts"`
+	} `json:"sysstat"`
+}
+
+// sarStatistics is *one* record of a sadf -j export. The rates are per
+// second and the sizes in kilobytes.
+type sarStatistics struct {
+	Timestamp struct {
+		Date     string `json:"date"`
+		Time     string `json:"time"`
+		Utc      int    `json:"utc"`
+		Interval int    `json:"interval"`
+	} `json:"timestamp"`
+	CpuLoad    []map[string]interface{} `json:"cpu-load"`     // sar -u
+	CpuLoadAll []map[string]interface{} `json:"cpu-load-all"` // sar -u ALL
+	Pcsw       *struct {
+		Proc float64 `json:"proc"`
+	} `json:"process-and-context-switch"` // sar -w
+	Queue *struct {
+		RunqSz  float64 `json:"runq-sz"`
+		PlistSz float64 `json:"plist-sz"`
+		Blocked float64 `json:"blocked"`
+	} `json:"queue"` // sar -q
+	Network *struct {
+		NetDev  []map[string]interface{} `json:"net-dev"`  // sar -n DEV
+		NetEdev []map[string]interface{} `json:"net-edev"` // sar -n EDEV
+	} `json:"network"`
+	Disk []struct {
+		Device  string  `json:"disk-device"`
+		Tps     float64 `json:"tps"`
+		RkB     float64 `json:"rkB"`
+		WkB     float64 `json:"wkB"`
+		DkB     float64 `json:"dkB"`
+		RdSec   float64 `json:"rd_sec"` // Before sysstat 11.5.7
+		WrSec   float64 `json:"wr_sec"` // Before sysstat 11.5.7
+		AquSz   float64 `json:"aqu-sz"`
+		AvgquSz float64 `json:"avgqu-sz"` // Before sysstat 11.5.7
+		Await   float64 `json:"await"`
+		Util    float64 `json:"util-percent"`
+	} `json:"disk"` // sar -d
+}
+
+// sarCpuKeys are the CpuRawStats keys of the fields of cpu-load and
+// cpu-load-all.
+var sarCpuKeys = map[string]string{
+	`user`:   `user`,
+	`usr`:    `user`,
+	`nice`:   `nice`,
+	`system`: `system`,
+	`sys`:    `system`,
+	`iowait`: `iowait`,
+	`steal`:  `steal`,
+	`irq`:    `irq`,
+	`soft`:   `softirq`,
+	`guest`:  `guest`,
+	`gnice`:  `guestnice`,
+	`idle`:   `idle`,
+}
+
+// sarIfaceKeys are the IfaceRawStats keys of the fields of net-dev and
+// net-edev, with the factor of their rates (1024 for the kilobytes).
+var sarIfaceKeys = map[string]struct {
+	key    string
+	factor float64
+}{
+	`rxpck`:  {`rxpkts`, 1},
+	`txpck`:  {`txpkts`, 1},
+	`rxkB`:   {`rxbytes`, 1024},
+	`txkB`:   {`txbytes`, 1024},
+	`rxcmp`:  {`rxcompr`, 1},
+	`txcmp`:  {`txcompr`, 1},
+	`rxmcst`: {`rxmulti`, 1},
+	`rxerr`:  {`rxerrs`, 1},
+	`txerr`:  {`txerrs`, 1},
+	`coll`:   {`txcolls`, 1},
+	`rxdrop`: {`rxdrop`, 1},
+	`txdrop`: {`txdrop`, 1},
+	`txcarr`: {`txcarr`, 1},
+	`rxfram`: {`rxframe`, 1},
+	`rxfifo`: {`rxfifo`, 1},
+	`txfifo`: {`txfifo`, 1},
+}
+
+// sarCpuTicks are the ticks per second of every 1% of CPU time of the
+// counters synthesized from the % of the records (sadf prints them with 2
+// decimals).
+const sarCpuTicks = 100
+
+// sarCounters are the counters synthesized from the rates of the records,
+// accumulated since the first one.
+type sarCounters struct {
+	cpus   map[string]map[string]float64
+	forks  float64
+	ifaces map[string]map[string]float64
+	disks  map[string]map[string]float64
+}
+
+// ImportSar reads the records of a sa data file of the sysstat tools (sar,
+// sadc) exported as JSON with sadf -j, e.g.
+//   sadf -j /var/log/sa/sa15 -- -u ALL -q -w -d -n DEV,EDEV
+// and returns them as snapshots, that can be replayed (see ReplayProvider),
+// analyzed or exported like the ones taken by the library. The binary format
+// of the data files changes between the versions of sysstat, which sadf
+// knows, so the files are read through its JSON export.
+//
+// The records only have rates, so the counters of the snapshots are
+// synthesized: they start at 0 one interval before the first record and
+// advance by the rates of each record, so the statistics between 2
+// snapshots are the ones of the record (the user and nice CPU times include
+// the guest ones, as in /proc/stat). The disks only have the # of I/Os,
+// which are split between the reads and the writes in proportion to their
+// sizes. The other activities (memory, swap...) aren't imported. A gap
+// between 2 records (e.g. the system was down) is an interval without
+// activity. Only the first host of the export is read.
+func ImportSar(r io.Reader) (snapshots []Snapshot, err error) {
+	var export sarExport
+	if err = json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, err
+	}
+	if len(export.Sysstat.Hosts) == 0 {
+		return nil, errors.New("The sadf export doesn't have any host")
+	}
+
+	counters := sarCounters{
+		cpus:   map[string]map[string]float64{},
+		ifaces: map[string]map[string]float64{},
+		disks:  map[string]map[string]float64{},
+	}
+	snapshots = []Snapshot{}
+	var first, last time.Time
+	for _, statistics := range export.Sysstat.Hosts[0].Statistics {
+		end, err := statistics.time()
+		if err != nil {
+			return nil, err
+		}
+		interval := time.Duration(statistics.Timestamp.Interval) * time.Second
+		if interval <= 0 || (len(snapshots) > 0 && !end.After(last)) {
+			// The restarts and the records out of order don't have rates
+			continue
+		}
+		start := end.Add(-interval)
+		if len(snapshots) == 0 {
+			first = start
+		}
+		if len(snapshots) == 0 || start.Sub(last) >= time.Second {
+			snapshots = append(snapshots, counters.snapshot(statistics, start, first, len(snapshots)))
+		} else {
+			// The intervals are rounded to seconds
+			interval = end.Sub(last)
+		}
+		counters.add(statistics, interval.Seconds())
+		snapshots = append(snapshots, counters.snapshot(statistics, end, first, len(snapshots)))
+		last = end
+	}
+
+	return snapshots, nil
+}
+
+// time returns the time of the end of a record.
+func (statistics sarStatistics) time() (time.Time, error) {
+	location := time.Local
+	if statistics.Timestamp.Utc == 1 {
+		location = time.UTC
+	}
+	t, err := time.ParseInLocation(`2006-01-02 15:04:05`, statistics.Timestamp.Date+` `+statistics.Timestamp.Time, location)
+	if err != nil {
+		return time.Time{}, errors.New("Error parsing the timestamp of a sadf record: " + err.Error())
+	}
+
+	return t, nil
+}
+
+// cpuLoad returns the CPU % of a record by CPU (cpu, cpu0...), with the
+// CpuRawStats keys. The user and nice times include the guest ones.
+func (statistics sarStatistics) cpuLoad() (cpus map[string]map[string]float64) {
+	cpus = map[string]map[string]float64{}
+	entries := statistics.CpuLoadAll
+	if len(entries) == 0 {
+		entries = statistics.CpuLoad
+	}
+	for _, entry := range entries {
+		name := `cpu`
+		if cpu := fmt.Sprint(entry[`cpu`]); cpu != `all` && cpu != `-1` {
+			name += cpu
+		}
+		cpu := map[string]float64{}
+		for field, value := range entry {
+			if key, ok := sarCpuKeys[field]; ok {
+				cpu[key], _ = value.(float64)
+			}
+		}
+		cpu[`user`] += cpu[`guest`]
+		cpu[`nice`] += cpu[`guestnice`]
+		cpus[name] = cpu
+	}
+
+	return cpus
+}
+
+// ifaces returns the rates of the network interfaces of a record, with the
+// IfaceRawStats keys.
+func (statistics sarStatistics) ifaces() (ifaces map[string]map[string]float64) {
+	ifaces = map[string]map[string]float64{}
+	if statistics.Network == nil {
+		return ifaces
+	}
+	for _, entry := range append(append([]map[string]interface{}{}, statistics.Network.NetDev...), statistics.Network.NetEdev...) {
+		name, _ := entry[`iface`].(string)
+		if name == `` {
+			continue
+		}
+		if _, ok := ifaces[name]; !ok {
+			ifaces[name] = map[string]float64{}
+		}
+		for field, value := range entry {
+			if key, ok := sarIfaceKeys[field]; ok {
+				rate, _ := value.(float64)
+				ifaces[name][key.key] = rate * key.factor
+			}
+		}
+	}
+
+	return ifaces
+}
+
+// add advances the counters by the rates of a record during seconds.
+func (counters *sarCounters) add(statistics sarStatistics, seconds float64) {
+	for name, cpu := range statistics.cpuLoad() {
+		if _, ok := counters.cpus[name]; !ok {
+			counters.cpus[name] = map[string]float64{}
+		}
+		for key, per := range cpu {
+			counters.cpus[name][key] += math.Round(per * seconds * sarCpuTicks)
+		}
+	}
+
+	if statistics.Pcsw != nil {
+		counters.forks += statistics.Pcsw.Proc * seconds
+	}
+
+	for name, rates := range statistics.ifaces() {
+		if _, ok := counters.ifaces[name]; !ok {
+			counters.ifaces[name] = map[string]float64{}
+		}
+		for key, rate := range rates {
+			counters.ifaces[name][key] += rate * seconds
+		}
+	}
+
+	for _, disk := range statistics.Disk {
+		if _, ok := counters.disks[disk.Device]; !ok {
+			counters.disks[disk.Device] = map[string]float64{}
+		}
+		readSectors, writeSectors, discardSectors, queue := disk.RkB*2, disk.WkB*2, disk.DkB*2, disk.AquSz
+		if disk.RdSec > 0 || disk.WrSec > 0 {
+			readSectors, writeSectors = disk.RdSec, disk.WrSec
+		}
+		if queue == 0 {
+			queue = disk.AvgquSz
+		}
+		ios := disk.Tps * seconds
+		readIOs, writeIOs, discardIOs := ios, 0.0, 0.0
+		if sectors := readSectors + writeSectors + discardSectors; sectors > 0 {
+			readIOs = ios * readSectors / sectors
+			writeIOs = ios * writeSectors / sectors
+			discardIOs = ios * discardSectors / sectors
+		}
+		c := counters.disks[disk.Device]
+		c[`readios`] += readIOs
+		c[`writeios`] += writeIOs
+		c[`discardios`] += discardIOs
+		c[`readsectors`] += readSectors * seconds
+		c[`writesectors`] += writeSectors * seconds
+		c[`discardsectors`] += discardSectors * seconds
+		// await is the average time (ms) of the I/Os
+		c[`readticks`] += disk.Await * readIOs
+		c[`writeticks`] += disk.Await * writeIOs
+		c[`ioticks`] += disk.Util * seconds * 10
+		c[`timeinqueue`] += queue * seconds * 1000
+	}
+}
+
+// snapshot returns the snapshot i, taken at t, of the counters of the
+// devices of a record. first is the time of the first snapshot.
+func (counters *sarCounters) snapshot(statistics sarStatistics, t time.Time, first time.Time, i int) (snapshot Snapshot) {
+	since := int64(t.Sub(first))
+	snapshot = Snapshot{
+		SchemaVersion: SnapshotSchemaVersion,
+		CollectedAt:   CollectedAt{Wall: t, Monotonic: since, Boottime: since},
+		Sequence:      uint64(i + 1),
+		Timestamp:     t,
+		Cpus:          CpusRawStats{},
+		Net:           NetRawStats{},
+		Disks:         []DiskRawStats{},
+		Files:         map[string][]byte{},
+	}
+	now := t.Unix()
+
+	for name := range statistics.cpuLoad() {
+		cpu := CpuRawStats{}
+		for _, key := range sarCpuKeys {
+			cpu[key] = uint64(counters.cpus[name][key])
+		}
+		cpu[`total`] = cpu[`user`] + cpu[`nice`] + cpu[`system`] + cpu[`idle`] + cpu[`iowait`] +
+			cpu[`irq`] + cpu[`softirq`] + cpu[`steal`]
+		snapshot.Cpus[name] = cpu
+	}
+
+	snapshot.Procs = ProcRawStats{Processes: uint64(math.Round(counters.forks)), Time: now}
+	if statistics.Queue != nil {
+		snapshot.Procs.Running = uint64(statistics.Queue.RunqSz)
+		snapshot.Procs.RunQueue = uint64(statistics.Queue.RunqSz)
+		snapshot.Procs.Blocked = uint64(statistics.Queue.Blocked)
+		snapshot.Procs.Total = uint64(statistics.Queue.PlistSz)
+	}
+
+	for name := range statistics.ifaces() {
+		iface := IfaceRawStats{`time`: uint64(now)}
+		for _, key := range ifaceKeys {
+			iface[key] = uint64(math.Round(counters.ifaces[name][key]))
+		}
+		snapshot.Net[name] = iface
+	}
+
+	for _, disk := range statistics.Disk {
+		c := counters.disks[disk.Device]
+		value := func(key string) uint64 { return uint64(math.Round(c[key])) }
+		diskRawStats := DiskRawStats{
+			Name:           disk.Device,
+			ReadIOs:        value(`readios`),
+			ReadSectors:    value(`readsectors`),
+			ReadTicks:      value(`readticks`),
+			WriteIOs:       value(`writeios`),
+			WriteSectors:   value(`writesectors`),
+			WriteTicks:     value(`writeticks`),
+			IOTicks:        value(`ioticks`),
+			TimeInQueue:    value(`timeinqueue`),
+			DiscardIOs:     value(`discardios`),
+			DiscardSectors: value(`discardsectors`),
+			SampleTime:     now,
+		}
+		// sar -d without -p names the disks devM-N
+		if numbers := strings.SplitN(strings.TrimPrefix(disk.Device, `dev`), `-`, 2); len(numbers) == 2 &&
+			strings.HasPrefix(disk.Device, `dev`) {
+			major, majorErr := strconv.Atoi(numbers[0])
+			minor, minorErr := strconv.Atoi(numbers[1])
+			if majorErr == nil && minorErr == nil {
+				diskRawStats.Major, diskRawStats.Minor = major, minor
+			}
+		}
+		snapshot.Disks = append(snapshot.Disks, diskRawStats)
+	}
+
+	return snapshot
+}
@@ -0,0 +1,64 @@
+package sysstats
+
+// nextLine returns the first line of content (without the newline) and the
+// content after it.
+func nextLine(content []byte) (line []byte, rest []byte) {
+	for i, c := range content {
+		if c == '\n' {
+			return content[:i], content[i+1:]
+		}
+	}
+
+	return content, nil
+}
+
+// nextField returns the first field of line (fields are separated by spaces
+// and tabs) and the line after it. field is empty if there are no more
+// fields.
+func nextField(line []byte) (field []byte, rest []byte) {
+	start := 0
+	for start < len(line) && (line[start] == ' ' || line[start] == '\t') {
+		start++
+	}
+	end := start
+	for end < len(line) && line[end] != ' ' && line[end] != '\t' {
+		end++
+	}
+
+	return line[start:end], line[end:]
+}
+
+// countLines returns the # of lines of content.
+func countLines(content []byte) (lines int) {
+	for _, c := range content {
+		if c == '\n' {
+			lines++
+		}
+	}
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		lines++
+	}
+
+	return lines
+}
+
+// parseUintBytes parses the decimal unsigned integer b without allocating.
+// ok is false if b is empty, has anything else than digits or overflows a
+// uint64 (strconv.ParseUint returns the error).
+func parseUintBytes(b []byte) (value uint64, ok bool) {
+	if len(b) == 0 {
+		return 0, false
+	}
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		digit := uint64(c - '0')
+		if value > (1<<64-1-digit)/10 {
+			return 0, false
+		}
+		value = value*10 + digit
+	}
+
+	return value, true
+}
@@ -0,0 +1,85 @@
+// +build linux
+
+package sysstats
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// SdNotify sends a state notification to systemd (e.g. READY=1, STOPPING=1,
+// WATCHDOG=1 or STATUS=...) so agents run as Type=notify services don't need
+// their own wrapper. It returns false if the process isn't run by systemd
+// (NOTIFY_SOCKET isn't set).
+func SdNotify(state string) (sent bool, err error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == `` {
+		return false, nil
+	}
+
+	// Abstract sockets start with @, which the net package already handles
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if _, err = conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// SdWatchdogInterval returns the watchdog interval set by WatchdogSec= in the
+// systemd unit. It returns 0 if the watchdog isn't enabled for this process.
+func SdWatchdogInterval() (interval time.Duration, err error) {
+	usecStr := os.Getenv("WATCHDOG_USEC")
+	if usecStr == `` {
+		return 0, nil
+	}
+	usec, err := strconv.ParseUint(usecStr, 10, 63)
+	if err != nil || usec == 0 {
+		return 0, errors.New("Couldn't parse WATCHDOG_USEC: " + usecStr)
+	}
+
+	// WATCHDOG_PID is set if the watchdog is meant for a specific process
+	if pidStr := os.Getenv("WATCHDOG_PID"); pidStr != `` {
+		pid, err := strconv.Atoi(pidStr)
+		if err != nil {
+			return 0, errors.New("Couldn't parse WATCHDOG_PID: " + pidStr)
+		}
+		if pid != os.Getpid() {
+			return 0, nil
+		}
+	}
+
+	return time.Duration(usec) * time.Microsecond, nil
+}
+
+// SdWatchdog sends WATCHDOG=1 to systemd every half of the watchdog interval
+// (as recommended by sd_watchdog_enabled(3)) until ctx is done. It returns
+// straight away if the watchdog isn't enabled.
+func SdWatchdog(ctx context.Context) error {
+	interval, err := SdWatchdogInterval()
+	if err != nil || interval == 0 {
+		return err
+	}
+
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		if _, err := SdNotify(`WATCHDOG=1`); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
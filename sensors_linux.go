@@ -0,0 +1,218 @@
+// +build linux
+
+package sysstats
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SensorReading represents *one* sensor of a hwmon chip. The values are in
+// Celsius (temp), RPM (fan), Volts (in), Amperes (curr), Watts (power) and
+// Joules (energy). The thresholds are 0 if the chip doesn't report them.
+type SensorReading struct {
+	Name  string  `json:"name"`  // Name of the sensor (temp1, fan2...)
+	Type  string  `json:"type"`  // Type of the sensor (temp, fan, in, curr, power or energy)
+	Label string  `json:"label"` // Label of the sensor (Package id 0, Core 1...). Empty if the chip doesn't report it
+	Value float64 `json:"value"` // Reading of the sensor
+	Min   float64 `json:"min"`   // Minimum threshold
+	Max   float64 `json:"max"`   // Maximum threshold
+	Crit  float64 `json:"crit"`  // Critical threshold
+	Alarm bool    `json:"alarm"` // Whether an alarm (or the critical alarm) of the sensor is raised
+}
+
+// SensorChip represents *one* hwmon chip.
+type SensorChip struct {
+	Name    string          `json:"name"`    // Name of the chip (coretemp, k10temp, nct6775...)
+	Hwmon   string          `json:"hwmon"`   // hwmon directory (hwmon0...)
+	Sensors []SensorReading `json:"sensors"` // Sensors of the chip
+}
+
+// ThermalZone represents *one* thermal zone of the kernel.
+type ThermalZone struct {
+	Name        string  `json:"name"`        // Name of the zone (thermal_zone0...)
+	Type        string  `json:"type"`        // Type of the zone (x86_pkg_temp, acpitz, cpu-thermal...)
+	Temperature float64 `json:"temperature"` // Temperature (Celsius)
+	Crit        float64 `json:"crit"`        // Temperature of the critical trip point (Celsius). 0 if the zone doesn't have it
+}
+
+// CpuThrottle represents the thermal throttling events of *one* CPU since
+// boot (x86 only).
+type CpuThrottle struct {
+	Core    uint64 `json:"core"`    // # of times the core was throttled
+	Package uint64 `json:"package"` // # of times the package of the core was throttled (all its cores report the same count)
+}
+
+// SensorStats represents the hardware sensors of a linux system.
+//
+// Throttling map keys are the names of the CPUs (cpu0, cpu1...).
+type SensorStats struct {
+	Chips        []SensorChip           `json:"chips"`        // hwmon chips
+	ThermalZones []ThermalZone          `json:"thermalzones"` // Thermal zones
+	Throttling   map[string]CpuThrottle `json:"throttling"`   // Thermal throttling events by CPU
+}
+
+// reHwmonSensor matches the files with the readings of the hwmon sensors.
+var reHwmonSensor = regexp.MustCompile(`^(temp|fan|in|curr|power|energy)(\d+)_(input|average)$`)
+
+// hwmonScales are the divisors that convert the sysfs values of the types of
+// the sensors (millidegrees, millivolts, microwatts...) into their units.
+var hwmonScales = map[string]float64{
+	`temp`:   1000,
+	`fan`:    1,
+	`in`:     1000,
+	`curr`:   1000,
+	`power`:  1000000,
+	`energy`: 1000000,
+}
+
+// getSensorStats gets the hardware sensors of a linux system from
+// /sys/class/hwmon, /sys/class/thermal and the thermal_throttle directories
+// of the CPUs.
+func getSensorStats() (sensorStats SensorStats, err error) {
+	if sensorStats.Chips, err = getHwmonChips(); err != nil {
+		return SensorStats{}, err
+	}
+	if sensorStats.ThermalZones, err = getThermalZones(); err != nil {
+		return SensorStats{}, err
+	}
+
+	sensorStats.Throttling = map[string]CpuThrottle{}
+	throttleDirs, _ := filepath.Glob("/sys/devices/system/cpu/cpu*/thermal_throttle")
+	for _, dir := range throttleDirs {
+		throttle := CpuThrottle{}
+		throttle.Core, _ = readUintFile(filepath.Join(dir, "core_throttle_count"))
+		throttle.Package, _ = readUintFile(filepath.Join(dir, "package_throttle_count"))
+		sensorStats.Throttling[filepath.Base(filepath.Dir(dir))] = throttle
+	}
+
+	return sensorStats, nil
+}
+
+// getHwmonChips gets the chips of /sys/class/hwmon. The old drivers have the
+// files of the sensors in the device directory of the hwmon.
+func getHwmonChips() (chips []SensorChip, err error) {
+	hwmons, err := filepath.Glob(filepath.Join(hwmonDir, "hwmon*"))
+	if err != nil {
+		return nil, err
+	}
+
+	chips = make([]SensorChip, 0, len(hwmons))
+	for _, hwmon := range hwmons {
+		dir := hwmon
+		if !fileExists(filepath.Join(dir, "name")) {
+			dir = filepath.Join(hwmon, "device")
+		}
+		name, err := readStringFile(filepath.Join(dir, "name"))
+		if err != nil {
+			continue
+		}
+		chips = append(chips, SensorChip{Name: name, Hwmon: filepath.Base(hwmon), Sensors: readHwmonSensors(dir)})
+	}
+
+	return chips, nil
+}
+
+// readHwmonSensors reads the sensors of the directory of a hwmon chip, sorted
+// by type and number. The sensors with an average and an input reading only
+// have the input.
+func readHwmonSensors(dir string) (sensors []SensorReading) {
+	files, _ := filepath.Glob(filepath.Join(dir, "*_*"))
+	readings := map[string]SensorReading{}
+	for _, file := range files {
+		match := reHwmonSensor.FindStringSubmatch(filepath.Base(file))
+		if match == nil {
+			continue
+		}
+		sensorName := match[1] + match[2]
+		if _, ok := readings[sensorName]; ok && match[3] == `average` {
+			continue
+		}
+		scale := hwmonScales[match[1]]
+		value, err := readHwmonValue(file, scale)
+		if err != nil {
+			// The sensors that are disabled can't be read
+			continue
+		}
+
+		prefix := filepath.Join(dir, sensorName+`_`)
+		reading := SensorReading{Name: sensorName, Type: match[1], Value: value}
+		reading.Label, _ = readStringFile(prefix + "label")
+		reading.Min, _ = readHwmonValue(prefix+"min", scale)
+		reading.Max, _ = readHwmonValue(prefix+"max", scale)
+		reading.Crit, _ = readHwmonValue(prefix+"crit", scale)
+		for _, alarm := range []string{"alarm", "crit_alarm"} {
+			if raised, err := readUintFile(prefix + alarm); err == nil && raised != 0 {
+				reading.Alarm = true
+			}
+		}
+		readings[sensorName] = reading
+	}
+
+	sensors = make([]SensorReading, 0, len(readings))
+	for _, reading := range readings {
+		sensors = append(sensors, reading)
+	}
+	sort.Slice(sensors, func(i, j int) bool {
+		if sensors[i].Type != sensors[j].Type {
+			return sensors[i].Type < sensors[j].Type
+		}
+		return hwmonSensorNumber(sensors[i]) < hwmonSensorNumber(sensors[j])
+	})
+
+	return sensors
+}
+
+// hwmonSensorNumber returns the number of a sensor (2 for fan2).
+func hwmonSensorNumber(reading SensorReading) int {
+	number, _ := strconv.Atoi(strings.TrimPrefix(reading.Name, reading.Type))
+	return number
+}
+
+// readHwmonValue reads a file of a hwmon sensor and returns its value divided
+// by scale.
+func readHwmonValue(path string, scale float64) (value float64, err error) {
+	content, err := readStringFile(path)
+	if err != nil {
+		return 0, err
+	}
+	raw, err := strconv.ParseInt(content, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return float64(raw) / scale, nil
+}
+
+// getThermalZones gets the thermal zones of /sys/class/thermal with their
+// critical trip point.
+func getThermalZones() (zones []ThermalZone, err error) {
+	zoneDirs, err := filepath.Glob(filepath.Join(thermalDir, "thermal_zone*"))
+	if err != nil {
+		return nil, err
+	}
+
+	zones = make([]ThermalZone, 0, len(zoneDirs))
+	for _, zoneDir := range zoneDirs {
+		temp, err := readHwmonTemp(filepath.Join(zoneDir, "temp"))
+		if err != nil {
+			continue
+		}
+		zone := ThermalZone{Name: filepath.Base(zoneDir), Temperature: temp}
+		zone.Type, _ = readStringFile(filepath.Join(zoneDir, "type"))
+		tripTypes, _ := filepath.Glob(filepath.Join(zoneDir, "trip_point_*_type"))
+		for _, tripType := range tripTypes {
+			if kind, err := readStringFile(tripType); err != nil || kind != `critical` {
+				continue
+			}
+			zone.Crit, _ = readHwmonTemp(strings.TrimSuffix(tripType, "_type") + "_temp")
+			break
+		}
+		zones = append(zones, zone)
+	}
+
+	return zones, nil
+}
@@ -0,0 +1,347 @@
+// +build linux
+
+package sysstats
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Service event kinds
+const (
+	ServiceEventStarted   = "started"   // The service started (its first process appeared)
+	ServiceEventRestarted = "restarted" // The service started again after running (its main process was replaced, or it had stopped)
+	ServiceEventStopped   = "stopped"   // All the processes of the service are gone
+	ServiceEventReady     = "ready"     // The readiness probe of the service succeeded after it started
+	ServiceEventCrashLoop = "crashloop" // The service restarted too often (see ServiceWatcherConfig)
+	ServiceEventRecovered = "recovered" // The service ran without restarting during a whole crash loop window after a crash loop
+)
+
+// WatchedService represents *one* service watched by a ServiceWatcher. Its
+// processes are the ones in its cgroup (or in one of its children) or, if it
+// doesn't have a cgroup, the ones with its command name. The main process
+// is the oldest one.
+type WatchedService struct {
+	Name      string       // Name of the service (used to identify its events and metrics)
+	Cgroup    string       // Cgroup of the service within the hierarchy (e.g. /system.slice/nginx.service)
+	Comm      string       // Command name of the processes of the service (used if Cgroup is empty)
+	Readiness *ProbeTarget // Probe run after every start until it succeeds (optional)
+}
+
+// ServiceWatcherConfig represents the services watched by a ServiceWatcher
+// and when their restarts are a crash loop.
+type ServiceWatcherConfig struct {
+	Services          []WatchedService // Services watched
+	CrashLoopRestarts int              // # of restarts within the window that make a crash loop (default 3)
+	CrashLoopWindow   time.Duration    // Window of the restarts of a crash loop (default 5 minutes)
+}
+
+// ServiceEvent represents *one* change of a watched service.
+type ServiceEvent struct {
+	Kind        string        `json:"kind"`        // Kind of event (started, restarted, stopped, ready, crashloop, recovered)
+	Time        time.Time     `json:"time"`        // Time of the event (the start time of the process for the starts and the restarts)
+	Service     string        `json:"service"`     // Name of the service
+	Cgroup      string        `json:"cgroup"`      // Cgroup of the service (empty if it's watched by command name)
+	Pid         int           `json:"pid"`         // Main process of the service (its last one for the stops)
+	Restarts    uint64        `json:"restarts"`    // # of restarts seen by the watcher
+	TimeToReady time.Duration `json:"timetoready"` // Time between the start and the first successful readiness probe (ready events)
+	Error       string        `json:"error"`       // Error of the last readiness probe that failed (ready events that succeeded after failures)
+}
+
+// ServiceStatus represents the state of *one* watched service.
+type ServiceStatus struct {
+	Service     string        `json:"service"`     // Name of the service
+	Cgroup      string        `json:"cgroup"`      // Cgroup of the service (empty if it's watched by command name)
+	Running     bool          `json:"running"`     // Whether the service has any process
+	Pid         int           `json:"pid"`         // Main process of the service (0 if it isn't running)
+	Processes   int           `json:"processes"`   // # of processes of the service
+	StartedAt   time.Time     `json:"startedat"`   // Start time of the main process
+	Restarts    uint64        `json:"restarts"`    // # of restarts seen by the watcher
+	Ready       bool          `json:"ready"`       // Whether the readiness probe succeeded since the last start (always true without probe)
+	TimeToReady time.Duration `json:"timetoready"` // Time to ready of the last start
+	CrashLoop   bool          `json:"crashloop"`   // Whether the service is in a crash loop
+}
+
+// ServiceWatcher tracks the restarts, the time to ready and the crash loops
+// of a set of services between calls to Check. Its events carry the cgroup
+// of the service, so they can be matched with the cgroup metrics (CPU,
+// memory, I/O, pressure) of the same service.
+//
+// The readiness probes are run on every Check, so the time to ready is
+// accurate to the interval between checks.
+type ServiceWatcher struct {
+	config   ServiceWatcherConfig
+	mu       sync.Mutex
+	services []*serviceState
+	seen     bool
+}
+
+// serviceState is the state of *one* watched service at the previous check.
+type serviceState struct {
+	status   ServiceStatus
+	main     taskKey
+	ran      bool        // Whether the service ran since the watcher started
+	restarts []time.Time // Times of the restarts within the crash loop window
+	probeErr string      // Error of the last readiness probe that failed
+}
+
+// NewServiceWatcher returns a ServiceWatcher for the given configuration.
+func NewServiceWatcher(config ServiceWatcherConfig) (*ServiceWatcher, error) {
+	if config.CrashLoopRestarts <= 0 {
+		config.CrashLoopRestarts = 3
+	}
+	if config.CrashLoopWindow <= 0 {
+		config.CrashLoopWindow = 5 * time.Minute
+	}
+
+	w := &ServiceWatcher{config: config, services: make([]*serviceState, 0, len(config.Services))}
+	w.config.Services = make([]WatchedService, 0, len(config.Services))
+	names := map[string]bool{}
+	for _, service := range config.Services {
+		if service.Name == `` || (service.Cgroup == `` && service.Comm == ``) {
+			return nil, errors.New("A watched service needs a name and a cgroup or a command name")
+		}
+		if names[service.Name] {
+			return nil, errors.New("The service " + service.Name + " is watched twice")
+		}
+		names[service.Name] = true
+		if service.Readiness != nil && service.Readiness.Timeout <= 0 {
+			readiness := *service.Readiness
+			readiness.Timeout = 5 * time.Second
+			service.Readiness = &readiness
+		}
+		w.config.Services = append(w.config.Services, service)
+		w.services = append(w.services, &serviceState{status: ServiceStatus{Service: service.Name, Cgroup: service.Cgroup}})
+	}
+
+	return w, nil
+}
+
+// Check returns the events of the watched services since the previous
+// call (sorted by time). The first call only records the services running:
+// they are ready without waiting for their readiness probes.
+func (w *ServiceWatcher) Check() (events []ServiceEvent, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	pidStatsArr, err := getPidStats()
+	if err != nil {
+		return nil, err
+	}
+	uptime, err := getUptime()
+	if err != nil {
+		return nil, err
+	}
+	now := clockNow()
+	boot := now.Add(-uptime.Uptime)
+
+	processes := w.serviceProcesses(pidStatsArr)
+	events = []ServiceEvent{}
+	for i, state := range w.services {
+		service := w.config.Services[i]
+		events = append(events, w.checkService(service, state, processes[i], boot, now)...)
+	}
+	w.seen = true
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+
+	return events, nil
+}
+
+// serviceProcesses returns the processes of each watched service. The
+// cgroups of the processes are only read if a service is watched by cgroup.
+func (w *ServiceWatcher) serviceProcesses(pidStatsArr []PidStats) (processes [][]PidStats) {
+	processes = make([][]PidStats, len(w.config.Services))
+	byCgroup := false
+	for _, service := range w.config.Services {
+		byCgroup = byCgroup || service.Cgroup != ``
+	}
+
+	for _, pidStats := range pidStatsArr {
+		cgroup := ``
+		if byCgroup {
+			// The processes that exited are skipped
+			cgroup, _ = getPidCgroup(pidStats.Pid)
+		}
+		for i, service := range w.config.Services {
+			if service.Cgroup != `` {
+				if cgroup == service.Cgroup || strings.HasPrefix(cgroup, strings.TrimSuffix(service.Cgroup, `/`)+`/`) {
+					processes[i] = append(processes[i], pidStats)
+				}
+			} else if pidStats.Comm == service.Comm {
+				processes[i] = append(processes[i], pidStats)
+			}
+		}
+	}
+
+	return processes
+}
+
+// checkService updates the state of a service with its processes and
+// returns its events.
+func (w *ServiceWatcher) checkService(service WatchedService, state *serviceState, processes []PidStats, boot time.Time, now time.Time) (events []ServiceEvent) {
+	status := &state.status
+	wasRunning := status.Running
+	status.Processes = len(processes)
+	status.Running = len(processes) > 0
+	if !status.Running {
+		if wasRunning {
+			events = append(events, w.newServiceEvent(ServiceEventStopped, now, state))
+		}
+		status.Pid, status.Ready = 0, false
+		return append(events, w.checkCrashLoop(state, now)...)
+	}
+
+	// The main process is the oldest one (the lowest pid if they started
+	// at the same tick)
+	main := processes[0]
+	for _, pidStats := range processes[1:] {
+		if pidStats.StartTime < main.StartTime || (pidStats.StartTime == main.StartTime && pidStats.Pid < main.Pid) {
+			main = pidStats
+		}
+	}
+	key := taskKey{id: main.Pid, startTime: main.StartTime}
+	startedAt := boot.Add(time.Duration(main.StartTime) * time.Second / userHz)
+
+	if !wasRunning || key != state.main {
+		status.Pid, status.StartedAt = main.Pid, startedAt
+		state.main, state.probeErr = key, ``
+		status.Ready, status.TimeToReady = service.Readiness == nil, 0
+		switch {
+		case !w.seen:
+			// Running before the watcher started
+			status.Ready = true
+		case state.ran:
+			status.Restarts++
+			state.restarts = append(state.restarts, startedAt)
+			events = append(events, w.newServiceEvent(ServiceEventRestarted, startedAt, state))
+		default:
+			events = append(events, w.newServiceEvent(ServiceEventStarted, startedAt, state))
+		}
+		state.ran = true
+	}
+
+	if !status.Ready {
+		if _, err := runProbe(*service.Readiness); err != nil {
+			state.probeErr = err.Error()
+		} else {
+			status.Ready = true
+			if status.TimeToReady = now.Sub(status.StartedAt); status.TimeToReady < 0 {
+				status.TimeToReady = 0
+			}
+			event := w.newServiceEvent(ServiceEventReady, now, state)
+			event.TimeToReady, event.Error = status.TimeToReady, state.probeErr
+			events = append(events, event)
+		}
+	}
+
+	return append(events, w.checkCrashLoop(state, now)...)
+}
+
+// checkCrashLoop drops the restarts of a service out of the crash loop
+// window and returns the crash loop event (or the recovered one) if its
+// state changed. A service in a crash loop recovers once it runs without
+// restarting for a whole window.
+func (w *ServiceWatcher) checkCrashLoop(state *serviceState, now time.Time) (events []ServiceEvent) {
+	restarts := state.restarts[:0]
+	for _, restart := range state.restarts {
+		if now.Sub(restart) <= w.config.CrashLoopWindow {
+			restarts = append(restarts, restart)
+		}
+	}
+	state.restarts = restarts
+
+	switch {
+	case !state.status.CrashLoop && len(state.restarts) >= w.config.CrashLoopRestarts:
+		state.status.CrashLoop = true
+		return []ServiceEvent{w.newServiceEvent(ServiceEventCrashLoop, now, state)}
+	case state.status.CrashLoop && len(state.restarts) == 0 && state.status.Running:
+		state.status.CrashLoop = false
+		return []ServiceEvent{w.newServiceEvent(ServiceEventRecovered, now, state)}
+	}
+
+	return nil
+}
+
+// newServiceEvent returns an event of a service.
+func (w *ServiceWatcher) newServiceEvent(kind string, at time.Time, state *serviceState) ServiceEvent {
+	return ServiceEvent{
+		Kind:     kind,
+		Time:     at,
+		Service:  state.status.Service,
+		Cgroup:   state.status.Cgroup,
+		Pid:      state.main.id,
+		Restarts: state.status.Restarts,
+	}
+}
+
+// Statuses returns the state of the watched services at the last check.
+func (w *ServiceWatcher) Statuses() (statuses []ServiceStatus) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	statuses = make([]ServiceStatus, 0, len(w.services))
+	for _, state := range w.services {
+		statuses = append(statuses, state.status)
+	}
+
+	return statuses
+}
+
+// Metrics returns the state of the watched services at the last check as
+// the metrics service.up, service.processes, service.restarts,
+// service.ready, service.timetoready (seconds) and service.crashloop,
+// labeled with the service and its cgroup.
+func (w *ServiceWatcher) Metrics() (metrics []Metric) {
+	statuses := w.Statuses()
+	metrics = make([]Metric, 0, 6*len(statuses))
+	for _, status := range statuses {
+		labels := map[string]string{`service`: status.Service, `cgroup`: status.Cgroup}
+		metrics = append(metrics,
+			Metric{Name: `service.up`, Labels: labels, Value: boolValue(status.Running)},
+			Metric{Name: `service.processes`, Labels: labels, Value: float64(status.Processes)},
+			Metric{Name: `service.restarts`, Labels: labels, Value: float64(status.Restarts)},
+			Metric{Name: `service.ready`, Labels: labels, Value: boolValue(status.Running && status.Ready)},
+			Metric{Name: `service.timetoready`, Labels: labels, Value: status.TimeToReady.Seconds()},
+			Metric{Name: `service.crashloop`, Labels: labels, Value: boolValue(status.CrashLoop)},
+		)
+	}
+
+	return metrics
+}
+
+// boolValue returns 1 if b is true and 0 otherwise.
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// AddServiceEvents adds the events of a ServiceWatcher.
+func (t *Timeline) AddServiceEvents(serviceEvents []ServiceEvent) {
+	events := make([]TimelineEvent, 0, len(serviceEvents))
+	for _, serviceEvent := range serviceEvents {
+		message := serviceEvent.Service + ` ` + serviceEvent.Kind
+		if serviceEvent.Kind == ServiceEventReady {
+			message += ` in ` + serviceEvent.TimeToReady.String()
+		}
+		events = append(events, TimelineEvent{
+			Time:    serviceEvent.Time,
+			Kind:    TimelineService,
+			Action:  serviceEvent.Kind,
+			Message: message,
+			Labels: map[string]string{
+				`service`:  serviceEvent.Service,
+				`cgroup`:   serviceEvent.Cgroup,
+				`pid`:      strconv.Itoa(serviceEvent.Pid),
+				`restarts`: strconv.FormatUint(serviceEvent.Restarts, 10),
+			},
+		})
+	}
+	t.Add(events...)
+}
@@ -0,0 +1,89 @@
+// +build linux
+
+package sysstats
+
+import (
+	"syscall"
+)
+
+// TmpfsMount represents the usage of *one* tmpfs mount.
+type TmpfsMount struct {
+	MountPoint   string `json:"mountpoint"`   // Mount point
+	Total        uint64 `json:"total"`        // Size of the filesystem (bytes)
+	Used         uint64 `json:"used"`         // Memory used by the files of the filesystem (bytes)
+	Available    uint64 `json:"available"`    // Space available (bytes)
+	Inodes       uint64 `json:"inodes"`       // Total # of inodes
+	InodesFree   uint64 `json:"inodesfree"`   // # of free inodes
+	SharedMemory bool   `json:"sharedmemory"` // Whether it's the POSIX shared memory mount (/dev/shm)
+}
+
+// SharedMemStats represents the memory used by tmpfs filesystems and SysV
+// shared memory segments of a linux system.
+type SharedMemStats struct {
+	Tmpfs       []TmpfsMount `json:"tmpfs"`       // tmpfs mounts
+	TmpfsUsed   uint64       `json:"tmpfsused"`   // Memory used by all the tmpfs mounts (bytes)
+	ShmSegments []ShmSegment `json:"shmsegments"` // SysV shared memory segments
+	ShmSize     uint64       `json:"shmsize"`     // Size of all the SysV shared memory segments (bytes)
+	ShmRss      uint64       `json:"shmrss"`      // Resident memory of all the SysV shared memory segments (bytes)
+}
+
+// getSharedMemStats gets the tmpfs mounts (from /proc/self/mountinfo) and
+// their usage (statfs) and the SysV shared memory segments (from
+// /proc/sysvipc/shm) of a linux system.
+func getSharedMemStats() (sharedMemStats SharedMemStats, err error) {
+	mounts, err := getMountInfo()
+	if err != nil {
+		return SharedMemStats{}, err
+	}
+
+	sharedMemStats = SharedMemStats{Tmpfs: make([]TmpfsMount, 0, 8)}
+	for _, mount := range mounts {
+		if mount.FsType != `tmpfs` {
+			continue
+		}
+		statfs := syscall.Statfs_t{}
+		if err := syscall.Statfs(mount.MountPoint, &statfs); err != nil {
+			// Not accessible (e.g. another mount namespace)
+			continue
+		}
+		bsize := uint64(statfs.Bsize)
+		tmpfs := TmpfsMount{
+			MountPoint:   mount.MountPoint,
+			Total:        statfs.Blocks * bsize,
+			Used:         (statfs.Blocks - statfs.Bfree) * bsize,
+			Available:    statfs.Bavail * bsize,
+			Inodes:       statfs.Files,
+			InodesFree:   statfs.Ffree,
+			SharedMemory: mount.MountPoint == `/dev/shm`,
+		}
+		sharedMemStats.Tmpfs = append(sharedMemStats.Tmpfs, tmpfs)
+	}
+
+	// A mount point may have been mounted more than once; only the last
+	// mount is visible.
+	visible := make([]TmpfsMount, 0, len(sharedMemStats.Tmpfs))
+	for i, tmpfs := range sharedMemStats.Tmpfs {
+		shadowed := false
+		for _, next := range sharedMemStats.Tmpfs[i+1:] {
+			if next.MountPoint == tmpfs.MountPoint {
+				shadowed = true
+				break
+			}
+		}
+		if !shadowed {
+			sharedMemStats.TmpfsUsed += tmpfs.Used
+			visible = append(visible, tmpfs)
+		}
+	}
+	sharedMemStats.Tmpfs = visible
+
+	if sharedMemStats.ShmSegments, err = getShmSegments(); err != nil {
+		return SharedMemStats{}, err
+	}
+	for _, segment := range sharedMemStats.ShmSegments {
+		sharedMemStats.ShmSize += segment.Size
+		sharedMemStats.ShmRss += segment.Rss
+	}
+
+	return sharedMemStats, nil
+}
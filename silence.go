@@ -0,0 +1,194 @@
+package sysstats
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Silence represents a maintenance window: while it's active the alerts it
+// matches aren't notified. When it ends, the alerts that changed while they
+// were silenced are notified in the next evaluation.
+type Silence struct {
+	Id       string            `json:"id"`       // Id of the silence (set by AddSilence)
+	Rule     string            `json:"rule"`     // Name of the rule silenced (empty matches all the rules)
+	Matchers map[string]string `json:"matchers"` // Labels the alerts must have to be silenced (empty matches all the alerts)
+	Start    time.Time         `json:"start"`    // Start of the silence (zero means now)
+	End      time.Time         `json:"end"`      // End of the silence
+	Comment  string            `json:"comment"`  // Why the alerts are silenced (e.g. the maintenance ticket)
+}
+
+// active returns true if the silence is active at the time now.
+func (s Silence) active(now time.Time) bool {
+	return !now.Before(s.Start) && now.Before(s.End)
+}
+
+// matches returns true if the silence matches the alerts with labels of the
+// rule.
+func (s Silence) matches(rule string, labels map[string]string) bool {
+	return (s.Rule == `` || s.Rule == rule) && matchLabels(labels, s.Matchers)
+}
+
+// SetSilenceFile makes the silences of the engine persistent: the silences of
+// path (if it exists) are loaded and every change of the silences is saved to
+// it, so they survive restarts of the agent.
+func (e *AlertEngine) SetSilenceFile(path string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	silences := []Silence{}
+	if len(content) > 0 {
+		if err := json.Unmarshal(content, &silences); err != nil {
+			return errors.New("Couldn't parse the silences of " + path + ": " + err.Error())
+		}
+	}
+
+	e.silenceFile = path
+	e.silences = map[string]Silence{}
+	for _, silence := range silences {
+		e.silences[silence.Id] = silence
+	}
+
+	return nil
+}
+
+// AddSilence adds a silence and returns its id. It returns an error if the
+// silence ends before it starts or it has already ended.
+func (e *AlertEngine) AddSilence(silence Silence) (id string, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := clockNow()
+	if silence.Start.IsZero() {
+		silence.Start = now
+	}
+	if !silence.End.After(silence.Start) || !silence.End.After(now) {
+		return ``, errors.New("The silence should end after it starts and after now")
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ``, err
+	}
+	silence.Id = hex.EncodeToString(b)
+	if e.silences == nil {
+		e.silences = map[string]Silence{}
+	}
+	e.silences[silence.Id] = silence
+
+	if err := e.saveSilences(); err != nil {
+		delete(e.silences, silence.Id)
+		return ``, err
+	}
+
+	return silence.Id, nil
+}
+
+// RemoveSilence removes a silence (e.g. when the maintenance ends early).
+func (e *AlertEngine) RemoveSilence(id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.silences[id]; !ok {
+		return errors.New("Silence " + id + " doesn't exist")
+	}
+	delete(e.silences, id)
+
+	return e.saveSilences()
+}
+
+// Silences returns the silences that haven't ended yet (sorted by start).
+func (e *AlertEngine) Silences() (silences []Silence) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := clockNow()
+	silences = make([]Silence, 0, len(e.silences))
+	for _, silence := range e.silences {
+		if silence.End.After(now) {
+			silences = append(silences, silence)
+		}
+	}
+	sort.Slice(silences, func(i, j int) bool {
+		if !silences[i].Start.Equal(silences[j].Start) {
+			return silences[i].Start.Before(silences[j].Start)
+		}
+		return silences[i].Id < silences[j].Id
+	})
+
+	return silences
+}
+
+// silenced returns true if an active silence matches the alerts with labels
+// of the rule. The expired silences are removed. The caller must hold the
+// lock.
+func (e *AlertEngine) silenced(rule string, labels map[string]string, now time.Time) bool {
+	for _, silence := range e.silences {
+		if silence.active(now) && silence.matches(rule, labels) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// expireSilences removes the silences that have ended. The caller must hold
+// the lock.
+func (e *AlertEngine) expireSilences(now time.Time) {
+	expired := false
+	for id, silence := range e.silences {
+		if !silence.End.After(now) {
+			delete(e.silences, id)
+			expired = true
+		}
+	}
+	if expired {
+		// It isn't an error if it can't be saved: the expired silences
+		// loaded again are inactive and they're removed again
+		e.saveSilences()
+	}
+}
+
+// saveSilences writes the silences to the silence file (if it's set). The
+// file is replaced atomically. The caller must hold the lock.
+func (e *AlertEngine) saveSilences() error {
+	if e.silenceFile == `` {
+		return nil
+	}
+
+	silences := make([]Silence, 0, len(e.silences))
+	for _, silence := range e.silences {
+		silences = append(silences, silence)
+	}
+	sort.Slice(silences, func(i, j int) bool { return silences[i].Id < silences[j].Id })
+	content, err := json.MarshalIndent(silences, ``, `  `)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(e.silenceFile), filepath.Base(e.silenceFile)+`.*`)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), e.silenceFile)
+}
@@ -0,0 +1,144 @@
+package sysstats
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SimulationStep represents *one* step of a Simulation.
+type SimulationStep struct {
+	Advance time.Duration     // Time the clock advances when the step starts (ignored in the first step)
+	Files   map[string]string // Content of the procfs files that change in the step, by path relative to the procfs (e.g. net/dev)
+}
+
+// Simulation runs the samplers, the alert engines and the exporters
+// deterministically in the integration tests: the time is the one of a
+// FakeClock and the procfs read by the collectors is a scripted sequence of
+// fixtures, one per step. The files of a step are the ones of the previous
+// step with the ones of the step replaced:
+//   simulation, err := sysstats.NewSimulation(start, []sysstats.SimulationStep{
+//       {Files: map[string]string{`meminfo`: meminfo, `stat`: stat}},
+//       {Advance: 10 * time.Second, Files: map[string]string{`stat`: busyStat}},
+//   })
+//   simulation.Start()
+//   defer simulation.Close()
+//   go sampler.Run(ctx) // Its handler sends the samples to a channel
+//   for simulation.Clock.BlockUntil(1); simulation.Next(); simulation.Clock.BlockUntil(1) {
+//       sample := <-samples // Collected from the fixtures of the step
+//   }
+// The timers of the samplers only fire when the clock advances, so the
+// files of the first step are only read by the collectors called directly.
+// The adaptive samplers check the pressure of the fixtures (pressure/cpu,
+// pressure/io and pressure/memory) on the clock of the simulation, so their
+// timers count in BlockUntil too; only the CPU usage of the process is the
+// real one. Only the procfs is simulated: the collectors that read /sys, run
+// commands or sleep between 2 reads (e.g. GetCpuAvgStats) still use the
+// system. Only one simulation can run at a time.
+type Simulation struct {
+	Clock *FakeClock // Clock of the simulation
+
+	dir      string
+	advances []time.Duration // Advance of each step
+	step     int
+	prevRoot string
+}
+
+// NewSimulation returns a Simulation of steps whose clock starts at start.
+// The fixtures of all the steps are written to a temporary directory.
+func NewSimulation(start time.Time, steps []SimulationStep) (*Simulation, error) {
+	if len(steps) == 0 {
+		return nil, errors.New("A simulation needs at least 1 step")
+	}
+
+	dir, err := ioutil.TempDir("", "sysstats-simulation-")
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]string{}
+	advances := make([]time.Duration, len(steps))
+	for i, step := range steps {
+		advances[i] = step.Advance
+		for name, content := range step.Files {
+			name = filepath.Clean(name)
+			if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+				os.RemoveAll(dir)
+				return nil, errors.New("The file " + name + " isn't relative to the procfs")
+			}
+			files[name] = content
+		}
+		if err := writeSimulationStep(filepath.Join(dir, strconv.Itoa(i)), files); err != nil {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+	}
+
+	return &Simulation{Clock: NewFakeClock(start), dir: dir, advances: advances}, nil
+}
+
+// writeSimulationStep writes the procfs files of a step to dir.
+func writeSimulationStep(dir string, files map[string]string) error {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Start starts the simulation at its first step: it sets the clock of the
+// package to the one of the simulation and the procfs to the fixtures of
+// the step.
+func (s *Simulation) Start() {
+	s.prevRoot = getProcRoot()
+	s.step = 0
+	setClock(s.Clock)
+	setProcRoot(s.stepDir(0))
+}
+
+// Next moves the simulation to its next step: it switches the procfs to the
+// fixtures of the step and then advances the clock, so the collectors whose
+// timers fire read the files of the step. It returns false if the
+// simulation was already at its last step.
+func (s *Simulation) Next() bool {
+	if s.step+1 >= len(s.advances) {
+		return false
+	}
+
+	s.step++
+	setProcRoot(s.stepDir(s.step))
+	s.Clock.Advance(s.advances[s.step])
+
+	return true
+}
+
+// Step returns the index of the current step.
+func (s *Simulation) Step() int {
+	return s.step
+}
+
+// Close ends the simulation: it restores the real clock and the previous
+// procfs, and it removes the fixtures.
+func (s *Simulation) Close() error {
+	if s.prevRoot != "" {
+		setClock(nil)
+		setProcRoot(s.prevRoot)
+		s.prevRoot = ""
+	}
+
+	return os.RemoveAll(s.dir)
+}
+
+// stepDir returns the directory of the fixtures of step i.
+func (s *Simulation) stepDir(i int) string {
+	return filepath.Join(s.dir, strconv.Itoa(i))
+}
@@ -0,0 +1,139 @@
+// +build linux
+
+package sysstats
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSimulationSampler(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	simulation, err := NewSimulation(start, []SimulationStep{
+		{Files: map[string]string{`stat`: "cpu  100 0 50 1000 0 0 0 0 0 0\n"}},
+		{Advance: 10 * time.Second, Files: map[string]string{`stat`: "cpu  200 0 60 1100 0 0 0 0 0 0\n"}},
+		{Advance: 10 * time.Second, Files: map[string]string{`stat`: "cpu  350 0 70 1150 0 0 0 0 0 0\n"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prevRoot := getProcRoot()
+	simulation.Start()
+	defer func() {
+		if err := simulation.Close(); err != nil {
+			t.Error(err)
+		}
+		if root := getProcRoot(); root != prevRoot {
+			t.Errorf("procfs %s after the simulation, want %s", root, prevRoot)
+		}
+	}()
+
+	// The files of the first step are read by the collectors called directly
+	cpusRawStats, err := getCpuRawStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user := cpusRawStats[`cpu`][`user`]; user != 100 {
+		t.Errorf("user %d in the first step, want 100", user)
+	}
+
+	samples := make(chan Sample, 4)
+	sampler := NewSampler([]SamplerCollector{
+		{Name: `cpu`, Interval: 10 * time.Second, Collect: func() (interface{}, error) { return getCpuRawStats() }},
+	}, func(sample Sample) { samples <- sample })
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- sampler.Run(ctx) }()
+
+	users := []uint64{}
+	times := []time.Time{}
+	for simulation.Clock.BlockUntil(1); simulation.Next(); simulation.Clock.BlockUntil(1) {
+		sample := <-samples
+		if sample.Error != `` {
+			t.Fatal(sample.Error)
+		}
+		users = append(users, sample.Value.(CpusRawStats)[`cpu`][`user`])
+		times = append(times, sample.Time)
+	}
+	cancel()
+	<-done
+
+	if len(users) != 2 || users[0] != 200 || users[1] != 350 {
+		t.Errorf("user of the samples %v, want [200 350]", users)
+	}
+	for i, sampleTime := range times {
+		if want := start.Add(time.Duration(i+1) * 10 * time.Second); !sampleTime.Equal(want) {
+			t.Errorf("Time of sample %d %s, want %s", i, sampleTime, want)
+		}
+	}
+}
+
+func TestSimulationAdaptiveSampler(t *testing.T) {
+	const idle = "some avg10=0.00 avg60=0.00 avg300=0.00 total=0\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=0\n"
+	const busy = "some avg10=50.00 avg60=20.00 avg300=5.00 total=9000000\nfull avg10=10.00 avg60=5.00 avg300=1.00 total=2000000\n"
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	steps := []SimulationStep{
+		{Files: map[string]string{`stat`: "cpu  100 0 50 1000 0 0 0 0 0 0\n", `pressure/cpu`: idle, `pressure/io`: idle, `pressure/memory`: idle}},
+		{Advance: 5 * time.Second, Files: map[string]string{`pressure/io`: busy}},
+		{Advance: 5 * time.Second},
+		{Advance: 5 * time.Second, Files: map[string]string{`pressure/io`: idle}},
+	}
+	for i := 0; i < 5; i++ {
+		steps = append(steps, SimulationStep{Advance: 5 * time.Second})
+	}
+	simulation, err := NewSimulation(start, steps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	simulation.Start()
+	defer simulation.Close()
+
+	samples := make(chan Sample, 8)
+	sampler := NewSampler([]SamplerCollector{
+		// The jitter of 1ns is always 0, so the runs are every 10s
+		{Name: `cpu`, Interval: 10 * time.Second, Jitter: time.Nanosecond, Collect: func() (interface{}, error) { return getCpuRawStats() }},
+	}, func(sample Sample) { samples <- sample })
+	// The real CPU usage of the test is far below MaxCpu, so only the
+	// pressure of the fixtures backs off (up to 2, so the checks at the
+	// time of the runs don't change it)
+	sampler.SetAdaptive(AdaptiveConfig{MaxCpu: 100, MaxPressure: 20, MaxBackoff: 2, CheckInterval: 5 * time.Second})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- sampler.Run(ctx) }()
+
+	// The runs at 0s, 10s (backoff 2 since the check at 5s), 30s (not 20s)
+	// and 40s (backoff 1 since the check at 15s), and the checks every 5s
+	runs := map[int]bool{2: true, 6: true, 8: true}
+	backoffs := []int{}
+	times := []time.Time{(<-samples).Time}
+	// The timers of the sampler and of its checks
+	for simulation.Clock.BlockUntil(2); simulation.Next(); simulation.Clock.BlockUntil(2) {
+		if runs[simulation.Step()] {
+			sample := <-samples
+			if sample.Error != `` {
+				t.Fatal(sample.Error)
+			}
+			times = append(times, sample.Time)
+		}
+		simulation.Clock.BlockUntil(2)
+		backoffs = append(backoffs, sampler.Backoff())
+	}
+	cancel()
+	<-done
+	close(samples)
+	for sample := range samples {
+		t.Errorf("Unexpected sample at %s", sample.Time.Sub(start))
+	}
+
+	if want := []int{2, 2, 1, 1, 1, 1, 1, 1}; fmt.Sprint(backoffs) != fmt.Sprint(want) {
+		t.Errorf("Backoffs %v, want %v", backoffs, want)
+	}
+	wantTimes := []time.Duration{0, 10 * time.Second, 30 * time.Second, 40 * time.Second}
+	for i, sampleTime := range times {
+		if want := start.Add(wantTimes[i]); !sampleTime.Equal(want) {
+			t.Errorf("Time of sample %d %s, want %s", i, sampleTime.Sub(start), wantTimes[i])
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package sysstats
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SloObjective represents a service level objective over the values of a
+// metric: a value is good if it satisfies the condition (e.g. cpu.total < 80
+// or the latency of a probe < 50), and Target is the % of the values that
+// must be good.
+type SloObjective struct {
+	Name      string            // Unique name of the objective
+	Metric    string            // Name of the metric (e.g. cpu.total)
+	Labels    map[string]string // Labels the values must have (e.g. cpu: cpu). Empty matches all the values
+	Op        string            // Comparison of the value with the threshold a good value satisfies (>, >=, <, <=, ==, !=)
+	Threshold float64           // Threshold
+	Target    float64           // % of the values that must be good (e.g. 99.9)
+	Windows   []time.Duration   // Windows of the burn rates (default 5 minutes, 30 minutes, 1 hour and 6 hours)
+}
+
+// BurnRate represents how fast *one* value (metric and labels) of an
+// objective consumes its error budget over a window: 1 means the budget is
+// consumed exactly at the end of the SLO period, 14.4 means a 30 days budget
+// is gone in 2 days. Alerting on a long and a short window together (e.g.
+// 1h and 5m both > 14.4) detects the fast burns without firing on the ones
+// that already stopped.
+type BurnRate struct {
+	Objective  string            `json:"objective"`  // Name of the objective
+	Labels     map[string]string `json:"labels"`     // Labels of the value
+	Window     time.Duration     `json:"window"`     // Window
+	Samples    uint64            `json:"samples"`    // # of values within the window
+	ErrorRatio float64           `json:"errorratio"` // Ratio (0-1) of the values within the window that were bad
+	BurnRate   float64           `json:"burnrate"`   // Error ratio relative to the error budget (1 - Target/100)
+}
+
+// SloTracker computes the multi-window burn rates of a set of objectives
+// from batches of metrics. The values are counted in buckets of 1/30 of the
+// shortest window of their objective, so the memory doesn't grow with the
+// rate of the batches.
+type SloTracker struct {
+	mu         sync.Mutex
+	objectives []SloObjective
+	series     map[string]*sloSeries
+}
+
+// sloSeries is the state of *one* value of an objective.
+type sloSeries struct {
+	objective int
+	labels    map[string]string
+	buckets   []sloBucket // Oldest first
+}
+
+// sloBucket is the count of the good and bad values of a time bucket.
+type sloBucket struct {
+	start time.Time
+	good  uint64
+	bad   uint64
+}
+
+// sloBuckets is the # of buckets of the shortest window of an objective.
+const sloBuckets = 30
+
+// NewSloTracker returns a SloTracker for the given objectives. It returns an
+// error if an objective doesn't have a name or a metric, has an unknown
+// operator, has a target that isn't between 0 and 100 (excluded) or has the
+// name of another objective.
+func NewSloTracker(objectives []SloObjective) (*SloTracker, error) {
+	objectives = append([]SloObjective{}, objectives...)
+	names := map[string]bool{}
+	for i, objective := range objectives {
+		if objective.Name == `` || objective.Metric == `` {
+			return nil, errors.New("SLO objectives need a name and a metric")
+		}
+		if names[objective.Name] {
+			return nil, errors.New("SLO objective " + objective.Name + " is duplicated")
+		}
+		names[objective.Name] = true
+		if _, ok := alertOps[objective.Op]; !ok {
+			return nil, errors.New("SLO objective " + objective.Name + " has an unknown operator: " + objective.Op)
+		}
+		if objective.Target <= 0 || objective.Target >= 100 {
+			return nil, errors.New("The target of SLO objective " + objective.Name + " should be between 0 and 100")
+		}
+		windows := []time.Duration{}
+		for _, window := range objective.Windows {
+			if window > 0 {
+				windows = append(windows, window)
+			}
+		}
+		if len(windows) == 0 {
+			windows = []time.Duration{5 * time.Minute, 30 * time.Minute, time.Hour, 6 * time.Hour}
+		}
+		sort.Slice(windows, func(i, j int) bool { return windows[i] < windows[j] })
+		objectives[i].Windows = windows
+	}
+
+	return &SloTracker{objectives: objectives, series: map[string]*sloSeries{}}, nil
+}
+
+// Observe counts the values of a batch of metrics and returns the burn rates
+// of every value of the objectives seen, sorted by objective, labels and
+// window.
+func (t *SloTracker) Observe(metrics []Metric) (burnRates []BurnRate) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.observe(metrics, clockNow())
+}
+
+// observe counts the metrics and returns the burn rates at the time now.
+func (t *SloTracker) observe(metrics []Metric, now time.Time) (burnRates []BurnRate) {
+	for i, objective := range t.objectives {
+		for _, metric := range metrics {
+			if metric.Name != objective.Metric || !matchLabels(metric.Labels, objective.Labels) {
+				continue
+			}
+			key := objective.Name + `{` + labelsKey(metric.Labels) + `}`
+			s, ok := t.series[key]
+			if !ok {
+				s = &sloSeries{objective: i, labels: metric.Labels}
+				t.series[key] = s
+			}
+			s.add(alertOps[objective.Op](metric.Value, objective.Threshold), now, objective.Windows[0]/sloBuckets)
+		}
+	}
+
+	keys := make([]string, 0, len(t.series))
+	for key := range t.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	burnRates = []BurnRate{}
+	for _, key := range keys {
+		s := t.series[key]
+		objective := t.objectives[s.objective]
+		windows := objective.Windows
+		s.expire(now.Add(-windows[len(windows)-1]))
+		if len(s.buckets) == 0 {
+			delete(t.series, key)
+			continue
+		}
+		budget := 1 - objective.Target/100
+		for _, window := range windows {
+			good, bad := s.count(now.Add(-window))
+			burnRate := BurnRate{Objective: objective.Name, Labels: s.labels, Window: window, Samples: good + bad}
+			if burnRate.Samples > 0 {
+				burnRate.ErrorRatio = float64(bad) / float64(burnRate.Samples)
+				burnRate.BurnRate = burnRate.ErrorRatio / budget
+			}
+			burnRates = append(burnRates, burnRate)
+		}
+	}
+
+	return burnRates
+}
+
+// add counts a value at the time now, in the bucket of the time now.
+func (s *sloSeries) add(good bool, now time.Time, resolution time.Duration) {
+	if resolution < time.Second {
+		resolution = time.Second
+	}
+	start := now.Truncate(resolution)
+	if n := len(s.buckets); n == 0 || s.buckets[n-1].start.Before(start) {
+		s.buckets = append(s.buckets, sloBucket{start: start})
+	}
+	bucket := &s.buckets[len(s.buckets)-1]
+	if good {
+		bucket.good++
+	} else {
+		bucket.bad++
+	}
+}
+
+// expire drops the buckets before the time from.
+func (s *sloSeries) expire(from time.Time) {
+	i := 0
+	for i < len(s.buckets) && s.buckets[i].start.Before(from) {
+		i++
+	}
+	s.buckets = append(s.buckets[:0], s.buckets[i:]...)
+}
+
+// count returns the # of good and bad values of the buckets since the time
+// from.
+func (s *sloSeries) count(from time.Time) (good uint64, bad uint64) {
+	for i := len(s.buckets) - 1; i >= 0 && !s.buckets[i].start.Before(from); i-- {
+		good += s.buckets[i].good
+		bad += s.buckets[i].bad
+	}
+
+	return good, bad
+}
+
+// BurnRateMetrics returns the burn rates as derived metrics named
+// slo.burnrate, with the labels of the value plus slo (the name of the
+// objective) and window (e.g. 1h0m0s), so they can be exported and
+// evaluated by the alerting rules. The burn rates without values within
+// their window are skipped.
+func BurnRateMetrics(burnRates []BurnRate) (metrics []Metric) {
+	metrics = make([]Metric, 0, len(burnRates))
+	for _, burnRate := range burnRates {
+		if burnRate.Samples == 0 {
+			continue
+		}
+		labels := make(map[string]string, len(burnRate.Labels)+2)
+		for name, value := range burnRate.Labels {
+			labels[name] = value
+		}
+		labels[`slo`] = burnRate.Objective
+		labels[`window`] = burnRate.Window.String()
+		metrics = append(metrics, Metric{Name: `slo.burnrate`, Labels: labels, Value: burnRate.BurnRate, Derived: true})
+	}
+
+	return metrics
+}
@@ -0,0 +1,103 @@
+package sysstats
+
+import (
+	"errors"
+	"time"
+)
+
+// CollectedAt represents when a snapshot was collected, both in wall clock
+// time and in CLOCK_MONOTONIC time. The monotonic time isn't affected by wall
+// clock jumps (NTP steps, manual changes), so it can be used to detect them.
+// CLOCK_MONOTONIC doesn't advance while the system is suspended but
+// CLOCK_BOOTTIME does, so the difference between them is the time the system
+// has been suspended.
+type CollectedAt struct {
+	Wall      time.Time `json:"wall"`      // Wall clock time
+	Monotonic int64     `json:"monotonic"` // CLOCK_MONOTONIC time (nanoseconds)
+	Boottime  int64     `json:"boottime"`  // CLOCK_BOOTTIME time (nanoseconds)
+}
+
+// Snapshot represents the raw statistics of a linux system read from all the
+// files as close together as possible, so the values derived from several
+// files (e.g. CPU % of a process, that needs /proc/stat and /proc/[pid]/stat)
+// are consistent.
+//
+// Files map keys are the paths of the extra files requested to GetSnapshot.
+type Snapshot struct {
+	SchemaVersion int               `json:"schemaversion"` // Version of the schema of the snapshot (SnapshotSchemaVersion)
+	CollectedAt   CollectedAt       `json:"collectedat"`   // When the snapshot was collected
+	Sequence      uint64            `json:"sequence"`      // Sequence number of the snapshot (starts at 1 and increments by 1 on every snapshot)
+	Timestamp     time.Time         `json:"timestamp"`     // When the first file was read (it has a monotonic clock reading)
+	ReadDuration  time.Duration     `json:"readduration"`  // Time between the first and the last read (skew between the files)
+	Cpus          CpusRawStats      `json:"cpus"`          // CPU raw stats (/proc/stat)
+	Procs         ProcRawStats      `json:"procs"`         // Processes raw stats (/proc/stat and /proc/loadavg)
+	Irqs          IrqRawStats       `json:"irqs"`          // Interrupt and softirq counters (/proc/stat)
+	Net           NetRawStats       `json:"net"`           // Network raw stats (/proc/net/dev)
+	Disks         []DiskRawStats    `json:"disks"`         // Disk IO raw stats (/proc/diskstats)
+	Files         map[string][]byte `json:"files"`         // Content of the extra files
+}
+
+// SnapshotAvgStats represents the statistics of a linux system between 2
+// snapshots. The counters don't advance while the system is suspended, so the
+// rates of a resumed sample shouldn't be compared with the rates of the
+// other samples.
+type SnapshotAvgStats struct {
+	Interval        time.Duration    `json:"interval"`        // Time between the snapshots (monotonic)
+	Skew            time.Duration    `json:"skew"`            // Max read duration of the snapshots
+	Suspended       time.Duration    `json:"suspended"`       // Time the system was suspended between the snapshots
+	Resumed         bool             `json:"resumed"`         // Whether the system was suspended (and resumed) between the snapshots
+	Cpus            CpusAvgStats     `json:"cpus"`            // % CPU usage
+	Procs           ProcAvgStats     `json:"procs"`           // Processes stats
+	Irqs            IrqAvgStats      `json:"irqs"`            // Interrupts and softirqs (per second)
+	Net             NetAvgStats      `json:"net"`             // Network stats (per second)
+	Disks           []DiskAvgStats   `json:"disks"`           // Disk IO stats (per second)
+	TopologyChanges []TopologyChange `json:"topologychanges"` // CPUs, interfaces and disks that changed between the snapshots (their stats aren't calculated)
+}
+
+// suspendThreshold is the minimum suspended time for a sample to be marked as
+// resumed. CLOCK_MONOTONIC and CLOCK_BOOTTIME can drift a few milliseconds
+// apart without a suspend.
+const suspendThreshold = time.Second
+
+// getSnapshotAvgStats calculates the statistics between 2 snapshots. All the
+// raw stats of a snapshot share the same sample time, so the rates of the
+// different files are calculated over the same interval. The interval is got
+// from the monotonic clock readings of the snapshots (not from the sample
+// times, that are whole seconds of the wall clock).
+func getSnapshotAvgStats(firstSnapshot Snapshot, secondSnapshot Snapshot) (snapshotAvgStats SnapshotAvgStats, err error) {
+	snapshotAvgStats.Interval = secondSnapshot.Timestamp.Sub(firstSnapshot.Timestamp)
+	if snapshotAvgStats.Interval < time.Second {
+		return SnapshotAvgStats{}, errors.New("The snapshots should be taken at least 1 second apart")
+	}
+	snapshotAvgStats.Skew = firstSnapshot.ReadDuration
+	if secondSnapshot.ReadDuration > snapshotAvgStats.Skew {
+		snapshotAvgStats.Skew = secondSnapshot.ReadDuration
+	}
+
+	// Time elapsed in CLOCK_BOOTTIME but not in CLOCK_MONOTONIC
+	snapshotAvgStats.Suspended = time.Duration((secondSnapshot.CollectedAt.Boottime - firstSnapshot.CollectedAt.Boottime) -
+		(secondSnapshot.CollectedAt.Monotonic - firstSnapshot.CollectedAt.Monotonic))
+	if snapshotAvgStats.Suspended < 0 {
+		snapshotAvgStats.Suspended = 0
+	}
+	snapshotAvgStats.Resumed = snapshotAvgStats.Suspended >= suspendThreshold
+
+	snapshotAvgStats.TopologyChanges = getTopologyChanges(firstSnapshot, secondSnapshot)
+	if snapshotAvgStats.Cpus, err = getCpuAvgStats(firstSnapshot.Cpus, secondSnapshot.Cpus); err != nil {
+		return SnapshotAvgStats{}, err
+	}
+	if snapshotAvgStats.Procs, err = getProcAvgStats(firstSnapshot.Procs, secondSnapshot.Procs); err != nil {
+		return SnapshotAvgStats{}, err
+	}
+	if snapshotAvgStats.Irqs, err = getIrqAvgStatsOver(firstSnapshot.Irqs, secondSnapshot.Irqs, snapshotAvgStats.Interval.Seconds()); err != nil {
+		return SnapshotAvgStats{}, err
+	}
+	if snapshotAvgStats.Net, err = getNetAvgStatsOver(firstSnapshot.Net, secondSnapshot.Net, snapshotAvgStats.Interval.Seconds()); err != nil {
+		return SnapshotAvgStats{}, err
+	}
+	if snapshotAvgStats.Disks, err = getDiskAvgStatsOver(firstSnapshot.Disks, secondSnapshot.Disks, snapshotAvgStats.Interval.Seconds()); err != nil {
+		return SnapshotAvgStats{}, err
+	}
+
+	return snapshotAvgStats, nil
+}
@@ -0,0 +1,91 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bytes"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+// snapshotSequence is the sequence number of the last snapshot.
+var snapshotSequence uint64
+
+// snapshotFiles are the files of the procfs read on every snapshot.
+var snapshotFiles = []string{"stat", "loadavg", "net/dev", "diskstats"}
+
+// getSnapshot reads /proc/stat, /proc/loadavg, /proc/net/dev,
+// /proc/diskstats and the extra files back to back, without parsing anything
+// until all of them have been read. Then it parses the content.
+func getSnapshot(extraFiles ...string) (snapshot Snapshot, err error) {
+	contents := make([][]byte, len(snapshotFiles)+len(extraFiles))
+
+	snapshot.SchemaVersion = SnapshotSchemaVersion
+	snapshot.Sequence = atomic.AddUint64(&snapshotSequence, 1)
+	if snapshot.CollectedAt, err = getCollectedAt(); err != nil {
+		return Snapshot{}, err
+	}
+	snapshot.Timestamp = clockNow()
+	// The files denied in a restricted procfs are empty
+	for i, file := range snapshotFiles {
+		if contents[i], err = readProcFile(file); err != nil {
+			return Snapshot{}, err
+		}
+	}
+	for i, path := range extraFiles {
+		if contents[len(snapshotFiles)+i], err = readPath(path); err != nil {
+			return Snapshot{}, err
+		}
+	}
+	snapshot.ReadDuration = clockNow().Sub(snapshot.Timestamp)
+
+	now := snapshot.Timestamp.Unix()
+	if snapshot.Cpus, err = readCpuRawStats(contents[0]); err != nil {
+		return Snapshot{}, err
+	}
+	if snapshot.Procs, err = readProcRawStats(contents[1], contents[0], now); err != nil {
+		return Snapshot{}, err
+	}
+	if snapshot.Irqs, err = readIrqRawStats(contents[0], now); err != nil {
+		return Snapshot{}, err
+	}
+	if snapshot.Net, err = readNetRawStats(bytes.NewReader(contents[2]), now); err != nil {
+		return Snapshot{}, err
+	}
+	if snapshot.Disks, err = readDiskRawStats(contents[3], now); err != nil {
+		return Snapshot{}, err
+	}
+
+	snapshot.Files = make(map[string][]byte, len(extraFiles))
+	for i, path := range extraFiles {
+		snapshot.Files[path] = contents[len(snapshotFiles)+i]
+	}
+
+	return snapshot, nil
+}
+
+// getCollectedAt returns the current wall clock, CLOCK_MONOTONIC and
+// CLOCK_BOOTTIME times.
+func getCollectedAt() (collectedAt CollectedAt, err error) {
+	collectedAt.Wall = clockNow()
+	// CLOCK_MONOTONIC is 1 and CLOCK_BOOTTIME is 7
+	if collectedAt.Monotonic, err = clockGettime(1); err != nil {
+		return CollectedAt{}, err
+	}
+	if collectedAt.Boottime, err = clockGettime(7); err != nil {
+		return CollectedAt{}, err
+	}
+
+	return collectedAt, nil
+}
+
+// clockGettime returns the time of the given clock in nanoseconds.
+func clockGettime(clockId uintptr) (nsec int64, err error) {
+	var ts syscall.Timespec
+	if _, _, errno := syscall.Syscall(syscall.SYS_CLOCK_GETTIME, clockId, uintptr(unsafe.Pointer(&ts)), 0); errno != 0 {
+		return 0, errno
+	}
+
+	return ts.Nano(), nil
+}
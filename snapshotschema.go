@@ -0,0 +1,97 @@
+package sysstats
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SnapshotSchemaVersion is the version of the schema of the serialized
+// snapshots. It's incremented on every incompatible change of Snapshot (or of
+// the types it has), and UnmarshalSnapshot can read the snapshots of any
+// previous version.
+//
+// Versions:
+//   1 - Snapshots without schema version (and, before the collection time
+//       was added, without collectedat and sequence).
+//   2 - Snapshots with schema version.
+const SnapshotSchemaVersion = 2
+
+// snapshotUpgrades has the functions that upgrade a serialized snapshot from
+// the version of the key to the next one.
+var snapshotUpgrades = map[int]func(fields map[string]json.RawMessage) error{
+	1: upgradeSnapshotV1,
+}
+
+// UnmarshalSnapshot parses a snapshot serialized as JSON by any version of
+// the library, upgrading it to the current schema. It returns an error if the
+// snapshot has a newer schema than the current one.
+func UnmarshalSnapshot(data []byte) (snapshot Snapshot, err error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return Snapshot{}, err
+	}
+
+	version := 1
+	if raw, ok := fields[`schemaversion`]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return Snapshot{}, err
+		}
+	}
+	if version > SnapshotSchemaVersion {
+		return Snapshot{}, fmt.Errorf("Couldn't read the snapshot: schema version %d isn't supported", version)
+	}
+
+	for ; version < SnapshotSchemaVersion; version++ {
+		if err := snapshotUpgrades[version](fields); err != nil {
+			return Snapshot{}, fmt.Errorf("Couldn't upgrade the snapshot from schema version %d: %v", version, err)
+		}
+	}
+
+	// fields only has JSON values, so it can always be marshalled
+	data, _ = json.Marshal(fields)
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return Snapshot{}, err
+	}
+
+	return snapshot, nil
+}
+
+// upgradeSnapshotV1 upgrades a snapshot from version 1 to version 2. The
+// oldest snapshots don't have the collection time: the wall clock time is
+// got from the timestamp (the monotonic and boot times can't be recovered).
+func upgradeSnapshotV1(fields map[string]json.RawMessage) (err error) {
+	if _, ok := fields[`collectedat`]; !ok {
+		var timestamp time.Time
+		if raw, ok := fields[`timestamp`]; ok {
+			if err := json.Unmarshal(raw, &timestamp); err != nil {
+				return err
+			}
+		}
+		if fields[`collectedat`], err = json.Marshal(CollectedAt{Wall: timestamp}); err != nil {
+			return err
+		}
+	}
+	fields[`schemaversion`] = json.RawMessage(`2`)
+
+	return nil
+}
+
+// unmarshalSnapshots parses a JSON array of snapshots with UnmarshalSnapshot.
+func unmarshalSnapshots(data []byte) (snapshots []Snapshot, err error) {
+	raws := []json.RawMessage{}
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return nil, err
+	}
+
+	snapshots = make([]Snapshot, 0, len(raws))
+	for _, raw := range raws {
+		snapshot, err := UnmarshalSnapshot(raw)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, nil
+}
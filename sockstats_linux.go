@@ -4,26 +4,108 @@ package sysstats
 
 import (
 	"bufio"
+	"errors"
+	"io"
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
 )
 
 // SockStats represents the socket statistics of a linux system.
+//
+// TcpStates map keys are the states of the TCP connections (ESTABLISHED,
+// TIME_WAIT, LISTEN...) and its values the # of IPv4 and IPv6 connections in
+// that state. The TCP and UDP counters are accumulated since boot.
 type SockStats struct {
-	Used        uint64 `json:"used"`        // Total number of used sockets
-	TcpInUse    uint64 `json:"tcpinuse"`    // TCP sockets in use
-	TcpOrphaned uint64 `json:"tcporphaned"` // TCP sockets orphaned
-	TcpTimeWait uint64 `json:"tcptimewait"` // TCP sockets in TIME_WAIT
-	UdpInUse    uint64 `json:"udpinuse"`    // UDP sockets in use
-	Raw         uint64 `json:"raw"`         // RAW sockets in use
-	IpFrag      uint64 `json:"ipfrag"`      // # of IP fragments in use
+	Used            uint64            `json:"used"`            // Total number of used sockets
+	TcpInUse        uint64            `json:"tcpinuse"`        // TCP sockets in use
+	TcpOrphaned     uint64            `json:"tcporphaned"`     // TCP sockets orphaned
+	TcpTimeWait     uint64            `json:"tcptimewait"`     // TCP sockets in TIME_WAIT
+	UdpInUse        uint64            `json:"udpinuse"`        // UDP sockets in use
+	Raw             uint64            `json:"raw"`             // RAW sockets in use
+	IpFrag          uint64            `json:"ipfrag"`          // # of IP fragments in use
+	TcpStates       map[string]uint64 `json:"tcpstates"`       // # of TCP connections by state
+	TcpOutSegs      uint64            `json:"tcpoutsegs"`      // # of TCP segments sent
+	TcpRetransSegs  uint64            `json:"tcpretranssegs"`  // # of TCP segments retransmitted
+	TcpInErrs       uint64            `json:"tcpinerrs"`       // # of TCP segments received with errors
+	TcpOutRsts      uint64            `json:"tcpoutrsts"`      // # of TCP resets sent
+	UdpInErrors     uint64            `json:"udpinerrors"`     // # of UDP datagrams received with errors
+	UdpNoPorts      uint64            `json:"udpnoports"`      // # of UDP datagrams received for a port without listener
+	UdpRcvbufErrors uint64            `json:"udprcvbuferrors"` // # of UDP datagrams dropped because the receive buffer was full
+	UdpSndbufErrors uint64            `json:"udpsndbuferrors"` // # of UDP datagrams dropped because the send buffer was full
 }
 
-// getSockStats gets the socket statistics of a linux system from the file
-// /proc/net/sockstat
+// tcpStates are the names of the states of the TCP connections by their
+// number in /proc/net/tcp (include/net/tcp_states.h).
+var tcpStates = map[uint64]string{
+	0x01: `ESTABLISHED`,
+	0x02: `SYN_SENT`,
+	0x03: `SYN_RECV`,
+	0x04: `FIN_WAIT1`,
+	0x05: `FIN_WAIT2`,
+	0x06: `TIME_WAIT`,
+	0x07: `CLOSE`,
+	0x08: `CLOSE_WAIT`,
+	0x09: `LAST_ACK`,
+	0x0A: `LISTEN`,
+	0x0B: `CLOSING`,
+	0x0C: `NEW_SYN_RECV`,
+}
+
+// getSockStats gets the socket statistics of a linux system from the files
+// /proc/net/sockstat, /proc/net/tcp, /proc/net/tcp6 (skipped if IPv6 is
+// disabled) and /proc/net/snmp. The states of up to Limits.MaxConnections
+// connections per address family are counted.
 func getSockStats() (sockStats SockStats, err error) {
-	file, err := os.Open("/proc/net/sockstat")
+	if sockStats, err = getSockstat(); err != nil {
+		return SockStats{}, err
+	}
+
+	sockStats.TcpStates = map[string]uint64{}
+	for _, state := range tcpStates {
+		sockStats.TcpStates[state] = 0
+	}
+	for _, name := range []string{`tcp`, `tcp6`} {
+		// The files are read as a stream: they have a line per connection
+		file, err := getProcReader().open("net", name)
+		if os.IsNotExist(err) || (procRestricted && os.IsPermission(err)) {
+			continue
+		}
+		if err != nil {
+			return SockStats{}, err
+		}
+		err = parseTcpStates(file, sockStats.TcpStates)
+		file.Close()
+		if err != nil {
+			return SockStats{}, err
+		}
+	}
+
+	content, err := readProcFile("net", "snmp")
+	if err != nil {
+		return SockStats{}, err
+	}
+	snmp, err := parseNetSnmp(content)
+	if err != nil {
+		return SockStats{}, err
+	}
+	sockStats.TcpOutSegs = snmp[`Tcp`][`OutSegs`]
+	sockStats.TcpRetransSegs = snmp[`Tcp`][`RetransSegs`]
+	sockStats.TcpInErrs = snmp[`Tcp`][`InErrs`]
+	sockStats.TcpOutRsts = snmp[`Tcp`][`OutRsts`]
+	sockStats.UdpInErrors = snmp[`Udp`][`InErrors`]
+	sockStats.UdpNoPorts = snmp[`Udp`][`NoPorts`]
+	sockStats.UdpRcvbufErrors = snmp[`Udp`][`RcvbufErrors`]
+	sockStats.UdpSndbufErrors = snmp[`Udp`][`SndbufErrors`]
+
+	return sockStats, nil
+}
+
+// getSockstat gets the # of sockets in use of a linux system from the file
+// /proc/net/sockstat
+func getSockstat() (sockStats SockStats, err error) {
+	file, err := getProcReader().open("net", "sockstat")
 	if err != nil {
 		return SockStats{}, err
 	}
@@ -85,3 +167,66 @@ func getSockStats() (sockStats SockStats, err error) {
 
 	return sockStats, nil
 }
+
+// parseTcpStates counts the connections of /proc/net/tcp (or /proc/net/tcp6)
+// by state (the 4th column, in hexadecimal), up to Limits.MaxConnections:
+//   sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+//    0: 0100007F:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000   120        0 20683 1 ...
+func parseTcpStates(r io.Reader, states map[string]uint64) (err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanLines)
+	// Filter the header
+	scanner.Scan()
+	for n := 0; scanner.Scan(); n++ {
+		if overLimit(n, limits.MaxConnections, &truncations.Connections) {
+			break
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		st, err := strconv.ParseUint(fields[3], 16, 64)
+		if err != nil {
+			return err
+		}
+		if state, ok := tcpStates[st]; ok {
+			states[state]++
+		}
+	}
+
+	return scanner.Err()
+}
+
+// parseNetSnmp parses the content of /proc/net/snmp, that has pairs of lines
+// with the names and the values of the counters of each protocol:
+//   Tcp: RtoAlgorithm RtoMin RtoMax MaxConn ActiveOpens PassiveOpens ...
+//   Tcp: 1 200 120000 -1 45 12 ...
+// It returns the counters indexed by protocol and name. The negative values
+// (MaxConn) are returned as 0.
+func parseNetSnmp(content []byte) (snmp map[string]map[string]uint64, err error) {
+	snmp = map[string]map[string]uint64{}
+
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	for i := 0; i+1 < len(lines); i += 2 {
+		names := strings.Fields(lines[i])
+		values := strings.Fields(lines[i+1])
+		if len(names) == 0 || len(names) != len(values) || names[0] != values[0] {
+			return nil, errors.New("Error parsing file /proc/net/snmp. Unexpected lines: " + lines[i] + " " + lines[i+1])
+		}
+		protocol := strings.TrimSuffix(names[0], `:`)
+		snmp[protocol] = map[string]uint64{}
+		for j := 1; j < len(names); j++ {
+			if strings.HasPrefix(values[j], `-`) {
+				snmp[protocol][names[j]] = 0
+				continue
+			}
+			value, err := strconv.ParseUint(values[j], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			snmp[protocol][names[j]] = value
+		}
+	}
+
+	return snmp, nil
+}
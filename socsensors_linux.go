@@ -0,0 +1,168 @@
+// +build linux
+
+package sysstats
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SocThrottling represents the throttling flags of a Raspberry Pi, as they
+// are reported by the firmware (`vcgencmd get_throttled`). The Occurred flags
+// are sticky: they are set if it happened at any time since boot.
+type SocThrottling struct {
+	Raw                   uint64 `json:"raw"`                   // Bits reported by the firmware
+	UnderVoltage          bool   `json:"undervoltage"`          // Under-voltage detected
+	FreqCapped            bool   `json:"freqcapped"`            // ARM frequency capped
+	Throttled             bool   `json:"throttled"`             // Currently throttled
+	SoftTempLimit         bool   `json:"softtemplimit"`         // Soft temperature limit active
+	UnderVoltageOccurred  bool   `json:"undervoltageoccurred"`  // Under-voltage has occurred
+	FreqCappedOccurred    bool   `json:"freqcappedoccurred"`    // ARM frequency capping has occurred
+	ThrottledOccurred     bool   `json:"throttledoccurred"`     // Throttling has occurred
+	SoftTempLimitOccurred bool   `json:"softtemplimitoccurred"` // Soft temperature limit has occurred
+}
+
+// SocStats represents the sensors of an ARM SoC (Raspberry Pi and the like).
+//
+// Zones map keys are the types of the thermal zones (cpu-thermal,
+// gpu-thermal...).
+type SocStats struct {
+	Model       string             `json:"model"`       // Model of the board (from the device tree)
+	Temperature float64            `json:"temperature"` // Temperature of the SoC (Celsius)
+	Zones       map[string]float64 `json:"zones"`       // Temperature of every thermal zone (Celsius)
+	CpuFreq     uint64             `json:"cpufreq"`     // Current frequency of the CPU (kHz)
+	Throttling  *SocThrottling     `json:"throttling"`  // Throttling flags (nil if not a Raspberry Pi or not readable)
+	Source      string             `json:"source"`      // Source of the throttling flags (sysfs, vcgencmd or hwmon)
+}
+
+const (
+	thermalDir      = "/sys/class/thermal"
+	hwmonDir        = "/sys/class/hwmon"
+	socThrottledRpi = "/sys/devices/platform/soc/soc:firmware/get_throttled"
+	socModelFile    = "/sys/firmware/devicetree/base/model"
+	socCpuFreqFile  = "/sys/devices/system/cpu/cpu0/cpufreq/scaling_cur_freq"
+)
+
+// getSocStats gets the SoC sensors of a linux system: the temperatures of the
+// thermal zones (/sys/class/thermal), the CPU frequency (cpufreq) and, on a
+// Raspberry Pi, the throttling flags. The flags are read from the firmware
+// sysfs file (get_throttled), from `vcgencmd get_throttled` if the kernel
+// doesn't have it, and otherwise the under-voltage is read from the rpi_volt
+// hwmon. It returns an error if the system doesn't have any thermal zone.
+func getSocStats() (socStats SocStats, err error) {
+	socStats = SocStats{Zones: map[string]float64{}}
+
+	if model, err := readStringFile(socModelFile); err == nil {
+		// The device tree strings are NUL terminated
+		socStats.Model = strings.TrimRight(model, "\x00")
+	}
+
+	zones, err := filepath.Glob(filepath.Join(thermalDir, "thermal_zone*"))
+	if err != nil {
+		return SocStats{}, err
+	}
+	if len(zones) == 0 {
+		return SocStats{}, errors.New("The system doesn't have thermal zones")
+	}
+	socZone := ``
+	for _, zone := range zones {
+		zoneType, err := readStringFile(filepath.Join(zone, "type"))
+		if err != nil {
+			continue
+		}
+		// The temperatures are in millidegrees Celsius (and can be negative)
+		temp, err := readStringFile(filepath.Join(zone, "temp"))
+		if err != nil {
+			continue
+		}
+		milli, err := strconv.ParseInt(temp, 10, 64)
+		if err != nil {
+			continue
+		}
+		socStats.Zones[zoneType] = float64(milli) / 1000
+		if socZone == `` && (strings.Contains(zoneType, `cpu`) || strings.Contains(zoneType, `soc`)) {
+			socZone = zoneType
+			socStats.Temperature = socStats.Zones[zoneType]
+		}
+	}
+	if socZone == `` {
+		// The first zone is the SoC on most boards
+		if zoneType, err := readStringFile(filepath.Join(zones[0], "type")); err == nil {
+			socStats.Temperature = socStats.Zones[zoneType]
+		}
+	}
+
+	socStats.CpuFreq, _ = readUintFile(socCpuFreqFile)
+	socStats.Throttling, socStats.Source = getSocThrottling()
+
+	return socStats, nil
+}
+
+// getSocThrottling gets the throttling flags of a Raspberry Pi and their
+// source. It returns nil if they can't be read.
+func getSocThrottling() (throttling *SocThrottling, source string) {
+	// The firmware file has the flags in hexadecimal without 0x
+	if value, err := readStringFile(socThrottledRpi); err == nil {
+		if raw, err := strconv.ParseUint(value, 16, 64); err == nil {
+			return newSocThrottling(raw), `sysfs`
+		}
+	}
+
+	if vcgencmd, err := exec.LookPath("vcgencmd"); err == nil {
+		if out, err := exec.Command(vcgencmd, "get_throttled").Output(); err == nil {
+			if raw, err := parseVcgencmdThrottled(string(out)); err == nil {
+				return newSocThrottling(raw), `vcgencmd`
+			}
+		}
+	}
+
+	// The rpi_volt hwmon only has the under-voltage alarm
+	hwmons, _ := filepath.Glob(filepath.Join(hwmonDir, "hwmon*"))
+	for _, hwmon := range hwmons {
+		if name, err := readStringFile(filepath.Join(hwmon, "name")); err != nil || name != `rpi_volt` {
+			continue
+		}
+		alarm, err := readUintFile(filepath.Join(hwmon, "in0_lcrit_alarm"))
+		if err != nil {
+			continue
+		}
+		return &SocThrottling{Raw: alarm, UnderVoltage: alarm == 1}, `hwmon`
+	}
+
+	return nil, ``
+}
+
+// parseVcgencmdThrottled parses the output of `vcgencmd get_throttled`:
+//   throttled=0x50005
+func parseVcgencmdThrottled(out string) (raw uint64, err error) {
+	value := strings.TrimSpace(out)
+	if !strings.HasPrefix(value, `throttled=0x`) {
+		return 0, errors.New("Couldn't parse the output of vcgencmd get_throttled: " + value)
+	}
+
+	return strconv.ParseUint(strings.TrimPrefix(value, `throttled=0x`), 16, 64)
+}
+
+// newSocThrottling returns the throttling flags of the bits reported by the
+// firmware: the bits 0-3 are the current state and the bits 16-19 whether it
+// has occurred since boot.
+func newSocThrottling(raw uint64) *SocThrottling {
+	bit := func(n uint) bool {
+		return raw&(1<<n) != 0
+	}
+
+	return &SocThrottling{
+		Raw:                   raw,
+		UnderVoltage:          bit(0),
+		FreqCapped:            bit(1),
+		Throttled:             bit(2),
+		SoftTempLimit:         bit(3),
+		UnderVoltageOccurred:  bit(16),
+		FreqCappedOccurred:    bit(17),
+		ThrottledOccurred:     bit(18),
+		SoftTempLimitOccurred: bit(19),
+	}
+}
@@ -0,0 +1,173 @@
+// +build linux
+
+package sysstats
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SriovVf represents the statistics of *one* SR-IOV virtual function.
+type SriovVf struct {
+	Index      int    `json:"index"`      // VF index on the physical function
+	PciAddress string `json:"pciaddress"` // PCI address of the VF
+	Driver     string `json:"driver"`     // Driver bound to the VF (e.g. vfio-pci when assigned to a guest)
+	Netdev     string `json:"netdev"`     // Host network interface of the VF (empty if it isn't bound to a host driver)
+	LinkState  string `json:"linkstate"`  // Link state (operstate of the host interface or the VF link state)
+	RxBytes    uint64 `json:"rxbytes"`    // # of bytes received
+	RxPackets  uint64 `json:"rxpackets"`  // # of packets received
+	RxDropped  uint64 `json:"rxdropped"`  // # of received packets dropped
+	TxBytes    uint64 `json:"txbytes"`    // # of bytes transmitted
+	TxPackets  uint64 `json:"txpackets"`  // # of packets transmitted
+	TxDropped  uint64 `json:"txdropped"`  // # of transmitted packets dropped
+}
+
+// SriovStats represents the virtual functions statistics of *all* the SR-IOV
+// capable NICs of a linux system.
+//
+// Map keys:
+//   Name - name of the physical function network interface
+type SriovStats map[string][]SriovVf
+
+// getSriovStats gets the SR-IOV VF statistics of a linux system from
+// /sys/class/net/<pf>/device/virtfn*. VFs bound to a host driver are read from
+// the statistics of their host interface; the counters of the VFs without a
+// host interface (e.g. assigned to guests) are got running the command
+// (skipped if `ip` isn't installed):
+//   ip -j -s link show dev <pf>
+func getSriovStats() (sriovStats SriovStats, err error) {
+	numVfsFiles, err := filepath.Glob("/sys/class/net/*/device/sriov_numvfs")
+	if err != nil {
+		return nil, err
+	}
+
+	sriovStats = SriovStats{}
+	for _, numVfsFile := range numVfsFiles {
+		deviceDir := filepath.Dir(numVfsFile)
+		pf := filepath.Base(filepath.Dir(deviceDir))
+		numVfs, err := readUintFile(numVfsFile)
+		if err != nil {
+			return nil, err
+		}
+		if numVfs == 0 {
+			continue
+		}
+
+		var ipVfs map[int]SriovVf
+		vfs := make([]SriovVf, 0, numVfs)
+		for i := 0; i < int(numVfs); i++ {
+			vfDir := filepath.Join(deviceDir, "virtfn"+strconv.Itoa(i))
+			vf := SriovVf{Index: i}
+			if target, err := os.Readlink(vfDir); err == nil {
+				vf.PciAddress = filepath.Base(target)
+			}
+			if target, err := os.Readlink(filepath.Join(vfDir, "driver")); err == nil {
+				vf.Driver = filepath.Base(target)
+			}
+
+			netdevs, _ := filepath.Glob(filepath.Join(vfDir, "net", "*"))
+			if len(netdevs) > 0 {
+				vf.Netdev = filepath.Base(netdevs[0])
+				if err := readSriovNetdevStats(netdevs[0], &vf); err != nil {
+					return nil, err
+				}
+			} else {
+				if ipVfs == nil {
+					if ipVfs, err = getIpVfStats(pf); err != nil {
+						return nil, err
+					}
+				}
+				if ipVf, ok := ipVfs[i]; ok {
+					ipVf.PciAddress = vf.PciAddress
+					ipVf.Driver = vf.Driver
+					vf = ipVf
+				}
+			}
+			vfs = append(vfs, vf)
+		}
+		sriovStats[pf] = vfs
+	}
+
+	return sriovStats, nil
+}
+
+// readSriovNetdevStats reads the operstate and statistics of the host
+// interface of a VF.
+func readSriovNetdevStats(netdevDir string, vf *SriovVf) (err error) {
+	if vf.LinkState, err = readStringFile(filepath.Join(netdevDir, "operstate")); err != nil {
+		return err
+	}
+
+	statsDir := filepath.Join(netdevDir, "statistics")
+	for file, value := range map[string]*uint64{
+		"rx_bytes":   &vf.RxBytes,
+		"rx_packets": &vf.RxPackets,
+		"rx_dropped": &vf.RxDropped,
+		"tx_bytes":   &vf.TxBytes,
+		"tx_packets": &vf.TxPackets,
+		"tx_dropped": &vf.TxDropped,
+	} {
+		if *value, err = readUintFile(filepath.Join(statsDir, file)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getIpVfStats returns the VF link state and counters reported by
+// `ip -j -s link show dev <pf>` indexed by VF index. The map is empty if `ip`
+// isn't installed.
+func getIpVfStats(pf string) (vfs map[int]SriovVf, err error) {
+	vfs = map[int]SriovVf{}
+
+	ip, err := exec.LookPath("ip")
+	if err != nil {
+		return vfs, nil
+	}
+
+	out, err := exec.Command(ip, "-j", "-s", "link", "show", "dev", pf).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	type vfCounters struct {
+		Bytes   uint64 `json:"bytes"`
+		Packets uint64 `json:"packets"`
+		Dropped uint64 `json:"dropped"`
+	}
+	var links []struct {
+		VfInfoList []struct {
+			Vf        int    `json:"vf"`
+			LinkState string `json:"link_state"`
+			Stats     struct {
+				Rx vfCounters `json:"rx"`
+				Tx vfCounters `json:"tx"`
+			} `json:"stats"`
+		} `json:"vfinfo_list"`
+	}
+	if err := json.Unmarshal(out, &links); err != nil {
+		return nil, err
+	}
+
+	for _, link := range links {
+		for _, info := range link.VfInfoList {
+			vfs[info.Vf] = SriovVf{
+				Index:     info.Vf,
+				LinkState: strings.ToLower(info.LinkState),
+				RxBytes:   info.Stats.Rx.Bytes,
+				RxPackets: info.Stats.Rx.Packets,
+				RxDropped: info.Stats.Rx.Dropped,
+				TxBytes:   info.Stats.Tx.Bytes,
+				TxPackets: info.Stats.Tx.Packets,
+				TxDropped: info.Stats.Tx.Dropped,
+			}
+		}
+	}
+
+	return vfs, nil
+}
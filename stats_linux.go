@@ -0,0 +1,251 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stats represents the statistics of all the collectors enabled, collected
+// in the same interval, with the metadata needed to ship them (when and
+// where they were collected).
+type Stats struct {
+	Hostname   string   `json:"hostname"`   // Hostname of the system
+	Collectors []string `json:"collectors"` // Collectors enabled (cpu, mem, net, disk, procs, load)
+	WatchSample
+}
+
+// statsCollectors are the names of the collectors of each flag.
+var statsCollectors = []struct {
+	flag WatchFlags
+	name string
+}{
+	{WatchCpu, `cpu`},
+	{WatchMem, `mem`},
+	{WatchNet, `net`},
+	{WatchDisk, `disk`},
+	{WatchProcs, `procs`},
+	{WatchLoad, `load`},
+}
+
+// getStats gets the statistics of the collectors of flags. The rates (CPU,
+// network, disk and processes) are calculated over interval, so it blocks
+// for interval (at least 1 second). It fails if any collector fails.
+func getStats(interval time.Duration, flags WatchFlags) (stats Stats, err error) {
+	stats, errs, err := collectStats(interval, flags)
+	if err != nil {
+		return Stats{}, err
+	}
+	if len(errs) > 0 {
+		return Stats{}, errs
+	}
+
+	return stats, nil
+}
+
+// getStatsPartial gets the statistics of the collectors of flags like
+// getStats, but the collectors that fail don't fail the others: it returns
+// the statistics collected (with the Error of the stats set) and a
+// MultiError with a FieldError for each collector that failed.
+func getStatsPartial(interval time.Duration, flags WatchFlags) (stats Stats, err error) {
+	stats, errs, err := collectStats(interval, flags)
+	if err != nil {
+		return Stats{}, err
+	}
+	if len(errs) > 0 {
+		stats.Error = errs.Error()
+	}
+
+	return stats, errs.errorOrNil()
+}
+
+// collectStats gets the statistics of the collectors of flags and returns
+// the errors of the collectors that failed. It returns an error if the
+// statistics can't be collected at all.
+func collectStats(interval time.Duration, flags WatchFlags) (stats Stats, errs MultiError, err error) {
+	if interval < time.Second {
+		return Stats{}, nil, errors.New("The stats interval should be at least 1 second")
+	}
+	if flags&WatchAll == 0 {
+		return Stats{}, nil, errors.New("The stats flags should have at least one collector")
+	}
+
+	prev, err := getWatchSnapshot()
+	if err != nil {
+		return Stats{}, nil, err
+	}
+	time.Sleep(interval)
+	snapshot, err := getWatchSnapshot()
+	if err != nil {
+		return Stats{}, nil, err
+	}
+
+	if stats.WatchSample, errs, err = collectWatchSample(prev, snapshot, flags); err != nil {
+		return Stats{}, nil, err
+	}
+	if stats.Hostname, err = getHostname(); err != nil {
+		errs = append(errs, &FieldError{Field: `hostname`, Err: err})
+	}
+	stats.Collectors = make([]string, 0, len(statsCollectors))
+	for _, collector := range statsCollectors {
+		if flags&collector.flag != 0 {
+			stats.Collectors = append(stats.Collectors, collector.name)
+		}
+	}
+
+	return stats, errs, nil
+}
+
+// MarshalJSON returns the stats as JSON with the time in UTC (RFC 3339) and
+// the interval in seconds, so the output can be shipped to log pipelines as
+// it is. The keys of the maps (CPUs, interfaces) are sorted.
+func (stats Stats) MarshalJSON() ([]byte, error) {
+	// statsJSON doesn't have the MarshalJSON method
+	type statsJSON Stats
+	return json.Marshal(struct {
+		statsJSON
+		Time     string  `json:"time"`
+		Interval float64 `json:"interval"`
+	}{
+		statsJSON: statsJSON(stats),
+		Time:      stats.Time.UTC().Format(time.RFC3339Nano),
+		Interval:  stats.Interval.Seconds(),
+	})
+}
+
+// StatsCSVWriter writes stats as CSV rows, one per Stats. The columns are
+// the JSON fields of the stats flattened with dots (e.g. cpu.cpu0.user,
+// mem.memfree, net.eth0.rxbytes, disks.sda.util). They are set by the first
+// row written (the header): the values of the CPUs, interfaces or disks that
+// appear later are dropped and the missing ones are empty. The topology
+// changes and the error aren't written.
+type StatsCSVWriter struct {
+	writer  *csv.Writer
+	columns []string
+}
+
+// NewStatsCSVWriter returns a StatsCSVWriter that writes to w.
+func NewStatsCSVWriter(w io.Writer) *StatsCSVWriter {
+	return &StatsCSVWriter{writer: csv.NewWriter(w)}
+}
+
+// Write writes stats as a row (and the header before the first one). The
+// rows are buffered until Flush is called.
+func (w *StatsCSVWriter) Write(stats Stats) error {
+	values, err := flattenStats(stats)
+	if err != nil {
+		return err
+	}
+
+	if w.columns == nil {
+		w.columns = make([]string, 0, len(values))
+		for column := range values {
+			switch column {
+			case `time`, `hostname`, `interval`:
+			default:
+				w.columns = append(w.columns, column)
+			}
+		}
+		sort.Strings(w.columns)
+		w.columns = append([]string{`time`, `hostname`, `interval`}, w.columns...)
+		if err := w.writer.Write(w.columns); err != nil {
+			return err
+		}
+	}
+
+	row := make([]string, len(w.columns))
+	for i, column := range w.columns {
+		row[i] = values[column]
+	}
+
+	return w.writer.Write(row)
+}
+
+// Flush writes the buffered rows to the underlying writer.
+func (w *StatsCSVWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// flattenStats returns the JSON fields of stats flattened with dots.
+func flattenStats(stats Stats) (values map[string]string, err error) {
+	content, err := json.Marshal(stats)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+	delete(fields, `topologychanges`)
+	delete(fields, `error`)
+
+	values = map[string]string{}
+	flattenStatsValue(``, fields, values)
+
+	return values, nil
+}
+
+// flattenStatsValue adds the decoded JSON value to values with the key
+// prefix. The objects of the arrays are keyed by their name (e.g. the disks)
+// and the arrays of scalars are joined with spaces (e.g. the collectors).
+func flattenStatsValue(prefix string, value interface{}, values map[string]string) {
+	join := func(key string) string {
+		if prefix == `` {
+			return key
+		}
+		return prefix + `.` + key
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			flattenStatsValue(join(key), field, values)
+		}
+	case []interface{}:
+		scalars := make([]string, 0, len(v))
+		for i, element := range v {
+			if object, ok := element.(map[string]interface{}); ok {
+				key := strconv.Itoa(i)
+				if name, ok := object[`name`].(string); ok {
+					key = name
+				}
+				flattenStatsValue(join(key), object, values)
+			} else {
+				scalars = append(scalars, fmtStatsScalar(element))
+			}
+		}
+		if len(scalars) > 0 {
+			values[prefix] = strings.Join(scalars, ` `)
+		}
+	case nil:
+		// Collectors not enabled
+	default:
+		values[prefix] = fmtStatsScalar(v)
+	}
+}
+
+// fmtStatsScalar returns a decoded JSON scalar as a string.
+func fmtStatsScalar(value interface{}) string {
+	switch v := value.(type) {
+	case json.Number:
+		return v.String()
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	}
+
+	return ``
+}
@@ -0,0 +1,136 @@
+// +build linux
+
+package sysstats
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rafacas/sysstats/numfmt"
+)
+
+// SystemSummary represents a one-glance overview of a linux system (like
+// free -h plus the load and the busiest disk and network interface), for
+// the CLIs and the chat bots. The memory sizes are in bytes.
+type SystemSummary struct {
+	MemTotal  uint64        `json:"memtotal"`  // Total size of memory
+	MemUsed   uint64        `json:"memused"`   // Size of used memory (without the buffers and the page cache)
+	MemFree   uint64        `json:"memfree"`   // Size of memory really free (memfree + buffers + cached)
+	SwapTotal uint64        `json:"swaptotal"` // Total size of swap space
+	SwapUsed  uint64        `json:"swapused"`  // Size of used swap space
+	Load1     float64       `json:"load1"`     // Load average of the last minute
+	Load5     float64       `json:"load5"`     // Load average of the last 5 minutes
+	Load15    float64       `json:"load15"`    // Load average of the last 15 minutes
+	Cpus      int           `json:"cpus"`      // # of CPUs
+	CpuPer    float64       `json:"cpuper"`    // % of CPU time not idle (all the CPUs)
+	TopDisk   *SummaryDisk  `json:"topdisk"`   // Disk with the highest utilization (nil if there isn't any disk)
+	TopIface  *SummaryIface `json:"topiface"`  // Network interface with the most traffic, without the loopback (nil if there isn't any)
+}
+
+// SummaryDisk represents the busiest disk of a SystemSummary.
+type SummaryDisk struct {
+	Name       string  `json:"name"`       // Name of the disk
+	Util       float64 `json:"util"`       // % of time the disk was doing I/Os
+	ReadBytes  float64 `json:"readbytes"`  // Bytes read per second
+	WriteBytes float64 `json:"writebytes"` // Bytes written per second
+}
+
+// SummaryIface represents the busiest network interface of a SystemSummary.
+type SummaryIface struct {
+	Name    string  `json:"name"`    // Name of the interface
+	RxBytes float64 `json:"rxbytes"` // Bytes received per second
+	TxBytes float64 `json:"txbytes"` // Bytes transmitted per second
+}
+
+// getSummary gets the summary of a linux system. The CPU usage and the
+// busiest disk and network interface are calculated between 2 snapshots
+// taken 1 second apart.
+func getSummary() (summary SystemSummary, err error) {
+	first, err := getWatchSnapshot()
+	if err != nil {
+		return SystemSummary{}, err
+	}
+	time.Sleep(time.Second)
+	second, err := getWatchSnapshot()
+	if err != nil {
+		return SystemSummary{}, err
+	}
+	avgStats, err := getSnapshotAvgStats(first, second)
+	if err != nil {
+		return SystemSummary{}, err
+	}
+	memInfo, err := getMemInfo()
+	if err != nil {
+		return SystemSummary{}, err
+	}
+	loadAvg, err := getLoadAvg()
+	if err != nil {
+		return SystemSummary{}, err
+	}
+
+	summary = SystemSummary{
+		MemTotal:  memInfo.MemTotal * 1024,
+		MemFree:   memInfo.RealFree * 1024,
+		SwapTotal: memInfo.SwapTotal * 1024,
+		SwapUsed:  memInfo.SwapUsed * 1024,
+		Load1:     loadAvg.Avg1,
+		Load5:     loadAvg.Avg5,
+		Load15:    loadAvg.Avg15,
+		CpuPer:    avgStats.Cpus[`cpu`][`total`],
+	}
+	if memInfo.RealFree < memInfo.MemTotal {
+		summary.MemUsed = (memInfo.MemTotal - memInfo.RealFree) * 1024
+	}
+	for cpu := range avgStats.Cpus {
+		if cpu != `cpu` {
+			summary.Cpus++
+		}
+	}
+
+	for _, disk := range avgStats.Disks {
+		if summary.TopDisk == nil || disk.Util > summary.TopDisk.Util {
+			summary.TopDisk = &SummaryDisk{Name: disk.Name, Util: disk.Util, ReadBytes: disk.ReadBytes, WriteBytes: disk.WriteBytes}
+		}
+	}
+	for iface, stats := range avgStats.Net {
+		if iface == `lo` {
+			continue
+		}
+		traffic := stats[`rxbytes`] + stats[`txbytes`]
+		if summary.TopIface == nil || traffic > summary.TopIface.RxBytes+summary.TopIface.TxBytes ||
+			(traffic == summary.TopIface.RxBytes+summary.TopIface.TxBytes && iface < summary.TopIface.Name) {
+			summary.TopIface = &SummaryIface{Name: iface, RxBytes: stats[`rxbytes`], TxBytes: stats[`txbytes`]}
+		}
+	}
+
+	return summary, nil
+}
+
+// String returns the summary as a few lines of text, e.g.
+//             total      used       free
+//   Mem:      15.5 GiB   4.2 GiB    11.3 GiB
+//   Swap:     2.0 GiB    0 B        2.0 GiB
+//   Load:     0.52 0.40 0.31 (8 CPUs), CPU 12.3%
+//   Disk:     sda 35.2% util, 1.2 MiB/s read, 300.0 KiB/s written
+//   Net:      eth0 1.5 MiB/s rx, 200.0 KiB/s tx
+func (summary SystemSummary) String() string {
+	f := numfmt.Default
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-10s%-11s%-11s%s\n", ``, `total`, `used`, `free`)
+	fmt.Fprintf(&b, "%-10s%-11s%-11s%s\n", `Mem:`, f.Bytes(float64(summary.MemTotal)), f.Bytes(float64(summary.MemUsed)),
+		f.Bytes(float64(summary.MemFree)))
+	fmt.Fprintf(&b, "%-10s%-11s%-11s%s\n", `Swap:`, f.Bytes(float64(summary.SwapTotal)), f.Bytes(float64(summary.SwapUsed)),
+		f.Bytes(float64(summary.SwapTotal)-float64(summary.SwapUsed)))
+	fmt.Fprintf(&b, "%-10s%s %s %s (%d CPUs), CPU %s\n", `Load:`, f.Number(summary.Load1, 2), f.Number(summary.Load5, 2),
+		f.Number(summary.Load15, 2), summary.Cpus, f.Percent(summary.CpuPer))
+	if disk := summary.TopDisk; disk != nil {
+		fmt.Fprintf(&b, "%-10s%s %s util, %s/s read, %s/s written\n", `Disk:`, disk.Name, f.Percent(disk.Util),
+			f.Bytes(disk.ReadBytes), f.Bytes(disk.WriteBytes))
+	}
+	if iface := summary.TopIface; iface != nil {
+		fmt.Fprintf(&b, "%-10s%s %s/s rx, %s/s tx\n", `Net:`, iface.Name, f.Bytes(iface.RxBytes), f.Bytes(iface.TxBytes))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,101 @@
+// +build darwin freebsd
+
+package sysstats
+
+import (
+	"errors"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sysctl returns the value of a sysctl variable running the command:
+//   sysctl -n <name>
+func sysctl(name string) (value string, err error) {
+	out, err := exec.Command(`sysctl`, `-n`, name).Output()
+	if err != nil {
+		return ``, err
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// sysctlUint returns the value of a numeric sysctl variable.
+func sysctlUint(name string) (value uint64, err error) {
+	out, err := sysctl(name)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(out, 10, 64)
+}
+
+// getLoadAvg gets the load average of a macOS/FreeBSD system from the sysctl
+// vm.loadavg.
+func getLoadAvg() (loadAvg LoadAvg, err error) {
+	out, err := sysctl(`vm.loadavg`)
+	if err != nil {
+		return LoadAvg{}, err
+	}
+
+	return parseSysctlLoadAvg(out)
+}
+
+// parseSysctlLoadAvg parses the value of the sysctl vm.loadavg, that has the
+// following format:
+//   { 1.33 1.27 1.38 }
+func parseSysctlLoadAvg(out string) (loadAvg LoadAvg, err error) {
+	fields := strings.Fields(strings.Trim(out, `{} `))
+	if len(fields) != 3 {
+		return LoadAvg{}, errors.New("Error parsing the sysctl vm.loadavg: " + out)
+	}
+
+	for i, value := range []*float64{&loadAvg.Avg1, &loadAvg.Avg5, &loadAvg.Avg15} {
+		if *value, err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return LoadAvg{}, err
+		}
+	}
+
+	return loadAvg, nil
+}
+
+// reBootTime matches the value of the sysctl kern.boottime:
+//   { sec = 1700000000, usec = 440000 } Tue Nov 14 22:13:20 2023
+var reBootTime = regexp.MustCompile(`sec\s*=\s*(\d+),\s*usec\s*=\s*(\d+)`)
+
+// getUptime gets the uptime of a macOS/FreeBSD system from the boot time of
+// the sysctl kern.boottime. The idle time isn't reported.
+func getUptime() (uptime Uptime, err error) {
+	out, err := sysctl(`kern.boottime`)
+	if err != nil {
+		return Uptime{}, err
+	}
+
+	bootTime, err := parseBootTime(out)
+	if err != nil {
+		return Uptime{}, err
+	}
+	uptime.Uptime = time.Since(bootTime)
+
+	return uptime, nil
+}
+
+// parseBootTime parses the value of the sysctl kern.boottime.
+func parseBootTime(out string) (bootTime time.Time, err error) {
+	stats := reBootTime.FindStringSubmatch(out)
+	if stats == nil {
+		return time.Time{}, errors.New("Error parsing the sysctl kern.boottime: " + out)
+	}
+	sec, err := strconv.ParseInt(stats[1], 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	usec, err := strconv.ParseInt(stats[2], 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(sec, usec*int64(time.Microsecond)), nil
+}
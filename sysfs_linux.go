@@ -0,0 +1,38 @@
+// +build linux
+
+package sysstats
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// readUintFile reads a file that only contains an unsigned integer (as lots
+// of the sysfs files do).
+func readUintFile(path string) (value uint64, err error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+}
+
+// readStringFile reads a file and returns its content without the leading
+// and trailing white spaces.
+func readStringFile(path string) (value string, err error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(content)), nil
+}
+
+// fileExists returns true if path exists.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
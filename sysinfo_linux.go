@@ -3,23 +3,21 @@
 package sysstats
 
 import (
-	"errors"
-	"io/ioutil"
 	"os/exec"
-	"strconv"
 	"strings"
 )
 
 // SysInfo represents the linux system info.
 type SysInfo struct {
-	Hostname  string  `json:"hostname"`
-	FQDN      string  `json:"fqdn"`
-	Domain    string  `json:"domain"`
-	OsType    string  `json:"ostype"`
-	OsRelease string  `json:"osrelease"`
-	OsVersion string  `json:"osversion"`
-	OsArch    string  `json:"osarch"`
-	Uptime    float64 `json:"uptime"`
+	Hostname  string          `json:"hostname"`
+	FQDN      string          `json:"fqdn"`
+	Domain    string          `json:"domain"`
+	OsType    string          `json:"ostype"`
+	OsRelease string          `json:"osrelease"`
+	OsVersion string          `json:"osversion"`
+	OsArch    string          `json:"osarch"`
+	Uptime    float64         `json:"uptime"`
+	ProcEnv   ProcEnvironment `json:"procenv"`
 }
 
 // getSysInfo gets the system info.
@@ -73,7 +71,7 @@ func getSysInfo() (sysInfo SysInfo, err error) {
 	if err != nil {
 		return SysInfo{}, err
 	}
-	sysInfo.Uptime = uptime
+	sysInfo.Uptime = uptime.Uptime.Seconds()
 
 	// FQDN
 	fqdn, err := getFqdn()
@@ -82,11 +80,14 @@ func getSysInfo() (sysInfo SysInfo, err error) {
 	}
 	sysInfo.FQDN = fqdn
 
+	// Environment of the procfs
+	sysInfo.ProcEnv = getProcEnvironment()
+
 	return sysInfo, nil
 }
 
 func getHostname() (hostname string, err error) {
-	content, err := ioutil.ReadFile("/proc/sys/kernel/hostname")
+	content, err := getProcReader().readFile("sys", "kernel", "hostname")
 	if err != nil {
 		return "", err
 	}
@@ -96,7 +97,7 @@ func getHostname() (hostname string, err error) {
 }
 
 func getDomain() (domain string, err error) {
-	content, err := ioutil.ReadFile("/proc/sys/kernel/domainname")
+	content, err := getProcReader().readFile("sys", "kernel", "domainname")
 	if err != nil {
 		return "", err
 	}
@@ -106,7 +107,7 @@ func getDomain() (domain string, err error) {
 }
 
 func getOsType() (osType string, err error) {
-	content, err := ioutil.ReadFile("/proc/sys/kernel/ostype")
+	content, err := getProcReader().readFile("sys", "kernel", "ostype")
 	if err != nil {
 		return "", err
 	}
@@ -116,7 +117,7 @@ func getOsType() (osType string, err error) {
 }
 
 func getOsRelease() (osRelease string, err error) {
-	content, err := ioutil.ReadFile("/proc/sys/kernel/osrelease")
+	content, err := getProcReader().readFile("sys", "kernel", "osrelease")
 	if err != nil {
 		return "", err
 	}
@@ -126,7 +127,7 @@ func getOsRelease() (osRelease string, err error) {
 }
 
 func getOsVersion() (osVersion string, err error) {
-	content, err := ioutil.ReadFile("/proc/sys/kernel/version")
+	content, err := getProcReader().readFile("sys", "kernel", "version")
 	if err != nil {
 		return "", err
 	}
@@ -152,25 +153,6 @@ func getOsArch() (osArch string, err error) {
 	return osArch, nil
 }
 
-func getUptime() (uptime float64, err error) {
-	content, err := ioutil.ReadFile("/proc/uptime")
-	if err != nil {
-		return -1, err
-	}
-
-	fields := strings.Fields(string(content))
-	if len(fields) != 2 {
-		return -1, errors.New("Error parsing /proc/uptime. It should have 2 fields")
-	}
-
-	uptime, err = strconv.ParseFloat(fields[0], 64)
-	if err != nil {
-		return -1, err
-	}
-
-	return uptime, nil
-}
-
 func getFqdn() (fqdn string, err error) {
 	// Check `hostname` path
 	hostname, err := exec.LookPath("hostname")
@@ -1,47 +1,145 @@
-// Package sysstats provides system statistics.
+// +build linux
+
 package sysstats
 
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// SetProcRoot sets the mount point of the procfs read by all the collectors
+// (/proc by default), e.g. /host/proc in a monitoring container with the
+// procfs of the host bind-mounted. It should be called before collecting any
+// statistics: it isn't safe to call it while other goroutines are collecting.
+// On linux 5.6+ the files are opened beneath it (openat2 with
+// RESOLVE_BENEATH), so a symlink can't make the collectors read files out of
+// it.
+func SetProcRoot(root string) {
+	setProcRoot(root)
+}
+
+// SetProcRestricted sets whether the procfs is restricted (mounted with
+// hidepid or with files denied by SELinux): then the files that can't be
+// read are handled as empty and the processes that can't be read are
+// skipped. It's set by default on Android. Like SetProcRoot, it should be
+// called before collecting any statistics.
+func SetProcRestricted(restricted bool) {
+	setProcRestricted(restricted)
+}
+
+// SetClock sets the clock of the samplers, the alert engines, the silences,
+// the histories and the snapshots (the real one if c is nil), e.g. a
+// FakeClock in the tests. Like SetProcRoot, it should be called before
+// collecting any statistics.
+func SetClock(c Clock) {
+	setClock(c)
+}
+
 // GetLoadAvg returns the load average of the system.
 func GetLoadAvg() (LoadAvg, error) {
 	return getLoadAvg()
 }
 
+// GetUptime returns the uptime and idle time of the system.
+func GetUptime() (Uptime, error) {
+	return getUptime()
+}
+
 // GetMemStats returns the memory statistics of the system.
+//
+// Deprecated: use GetMemInfo. MemInfo.ToMap returns the same map.
 func GetMemStats() (MemStats, error) {
 	return getMemStats()
 }
 
+// GetMemInfo returns the memory statistics of the system as a struct.
+func GetMemInfo() (MemInfo, error) {
+	return getMemInfo()
+}
+
+// GetMemInfoPartial returns the memory statistics of the system as a struct,
+// with the statistics that could be collected if some can't be parsed (or
+// are missing). The error is then a MultiError with a FieldError for each
+// of them.
+func GetMemInfoPartial() (MemInfo, error) {
+	return getMemInfoPartial()
+}
+
+// GetMemoryFields returns the values of the registered memory fields
+// (RegisterMemoryField) that don't override a MemInfo field, in kilobytes.
+// The error is a MultiError with a FieldError for each field whose formula
+// can't be calculated (and that is then missing).
+func GetMemoryFields() (map[string]uint64, error) {
+	return getMemoryFields()
+}
+
 // GetCpuRawStats returns the CPUs statistics for the system at the moment
 // the function is called.
+//
+// Deprecated: use GetCpuTimes. CpuTimes.ToMap returns the same map.
 func GetCpuRawStats() (CpusRawStats, error) {
 	return getCpuRawStats()
 }
 
 // GetCpuAvgStats calculates average between 2 CPUs statistics samples and
 // returns the % CPU usage
+//
+// Deprecated: use GetCpuUsage. CpuUsage.ToMap returns the same map.
 func GetCpuAvgStats(firstSample CpusRawStats, secondSample CpusRawStats) (CpusAvgStats, error) {
 	return getCpuAvgStats(firstSample, secondSample)
 }
 
 // GetCpuStatsInterval returns the % CPU utilization between 2 samples where
 // the sample interval is passed as an argument (in seconds).
+//
+// Deprecated: use GetCpuUsage with 2 GetCpuTimes samples.
 func GetCpuStatsInterval(interval int64) (CpusAvgStats, error) {
 	return getCpuStatsInterval(interval)
 }
 
+// GetCpuTimes returns the CPUs statistics of the system at the moment the
+// function is called.
+func GetCpuTimes() (map[string]CpuTimes, error) {
+	return getCpuTimes()
+}
+
+// GetCpuUsage calculates the % CPU usage between 2 GetCpuTimes samples.
+func GetCpuUsage(firstSample map[string]CpuTimes, secondSample map[string]CpuTimes) (map[string]CpuUsage, error) {
+	return getCpuUsage(firstSample, secondSample)
+}
+
+// GetNetCounters returns the statistics of all the network interfaces of the
+// system at the moment the function is called.
+func GetNetCounters() (map[string]IfaceCounters, error) {
+	return getNetCounters()
+}
+
+// GetNetRates calculates the network traffic (per second) between 2
+// GetNetCounters samples.
+func GetNetRates(firstSample map[string]IfaceCounters, secondSample map[string]IfaceCounters) (map[string]IfaceRates, error) {
+	return getNetRates(firstSample, secondSample)
+}
+
 // GetNetRawStats returns all the network interfaces statistics of the system
+//
+// Deprecated: use GetNetCounters. IfaceCounters.ToMap returns the same map.
 func GetNetRawStats() (NetRawStats, error) {
 	return getNetRawStats()
 }
 
 // GetNetAvgStats calculates average between 2 network stats samples
 // and return the network traffic between them.
+//
+// Deprecated: use GetNetRates. IfaceRates.ToMap returns the same map.
 func GetNetAvgStats(firstSample NetRawStats, secondSample NetRawStats) (NetAvgStats, error) {
 	return getNetAvgStats(firstSample, secondSample)
 }
 
 // GetNetStatsInterval returns the network traffic between 2 samples where the
 // sample interval is passed as an argument (in seconds).
+//
+// Deprecated: use GetNetRates with 2 GetNetCounters samples.
 func GetNetStatsInterval(interval int64) (NetAvgStats, error) {
 	return getNetStatsInterval(interval)
 }
@@ -53,11 +151,17 @@ func GetDiskUsage() ([]DiskUsage, error) {
 }
 
 // GetDiskRawStats gets the disk IO stats of the system at the moment
-// the function is called.
+// the function is called. The loop and ram devices are skipped.
 func GetDiskRawStats() ([]DiskRawStats, error) {
 	return getDiskRawStats()
 }
 
+// GetFilteredDiskRawStats gets the disk IO stats of the disks of the system
+// that match filter at the moment.
+func GetFilteredDiskRawStats(filter DiskFilter) ([]DiskRawStats, error) {
+	return getFilteredDiskRawStats(filter)
+}
+
 // GetDiskAvgStats calculates the average between 2 DiskRawStats samples and
 // returns the number of IOs per second.
 func GetDiskAvgStats(firstSampleArr []DiskRawStats, secondSampleArr []DiskRawStats) ([]DiskAvgStats, error) {
@@ -70,7 +174,8 @@ func GetDiskStatsInterval(interval int64) ([]DiskAvgStats, error) {
 	return getDiskStatsInterval(interval)
 }
 
-// GetSockStats returns the socket statistics of the system.
+// GetSockStats returns the socket statistics of the system: the sockets in
+// use, the TCP connections by state and the TCP and UDP error counters.
 func GetSockStats() (SockStats, error) {
 	return getSockStats()
 }
@@ -100,3 +205,452 @@ func GetProcAvgStats(firstSample ProcRawStats, secondSample ProcRawStats) (ProcA
 func GetProcStatsInterval(interval int64) (ProcAvgStats, error) {
 	return getProcStatsInterval(interval)
 }
+
+// GetTcpRttStats returns the RTT and retransmission summaries (p50/p95) of the
+// established TCP connections of the system grouped by remote port or subnet.
+func GetTcpRttStats(groupBy TcpGroupBy) (TcpRttStats, error) {
+	return getTcpRttStats(groupBy)
+}
+
+// GetFirewallStats returns the packet and byte counters of the firewall
+// (iptables and nftables) rules and chains of the system.
+func GetFirewallStats() ([]FirewallCounter, error) {
+	return getFirewallStats()
+}
+
+// GetWireGuardStats returns the per-peer statistics of the WireGuard
+// interfaces of the system.
+func GetWireGuardStats() (WireGuardStats, error) {
+	return getWireGuardStats()
+}
+
+// GetBridgeStats returns the forwarding database size, per-VLAN counters and
+// STP port states of the bridges of the system.
+func GetBridgeStats() ([]BridgeStats, error) {
+	return getBridgeStats()
+}
+
+// GetSriovStats returns the per-VF counters and link state of the SR-IOV
+// capable NICs of the system.
+func GetSriovStats() (SriovStats, error) {
+	return getSriovStats()
+}
+
+// GetInfinibandStats returns the port counters of the InfiniBand/RDMA devices
+// of the system.
+func GetInfinibandStats() ([]InfinibandPortStats, error) {
+	return getInfinibandStats()
+}
+
+// GetFcHostStats returns the link and frame statistics of the Fibre Channel
+// HBAs of the system.
+func GetFcHostStats() ([]FcHostStats, error) {
+	return getFcHostStats()
+}
+
+// GetIscsiSessions returns the state and error counters of the iSCSI sessions
+// of the system.
+func GetIscsiSessions() ([]IscsiSession, error) {
+	return getIscsiSessions()
+}
+
+// GetMultipathMaps returns the active/failed paths, path checker states and
+// path fail counts of the dm-multipath maps of the system.
+func GetMultipathMaps() ([]MultipathMap, error) {
+	return getMultipathMaps()
+}
+
+// GetNetFsClientStats returns the CIFS, CephFS and RBD client statistics of
+// the system.
+func GetNetFsClientStats() (NetFsClientStats, error) {
+	return getNetFsClientStats()
+}
+
+// GetMountStats returns the per-mount I/O statistics of the system, including
+// the NFS per-operation latencies.
+func GetMountStats() ([]MountStats, error) {
+	return getMountStats()
+}
+
+// GetLoopDevices returns the loop devices of the system with the files (or
+// snaps) backing them and where they are mounted.
+func GetLoopDevices() ([]LoopDevice, error) {
+	return getLoopDevices()
+}
+
+// GetOverlayMounts returns the overlayfs mounts of the system with their
+// layers and the disk space used by the writable layer.
+func GetOverlayMounts() ([]OverlayMount, error) {
+	return getOverlayMounts()
+}
+
+// GetSharedMemStats returns the memory used by the tmpfs mounts (including
+// /dev/shm) and the SysV shared memory segments of the system.
+func GetSharedMemStats() (SharedMemStats, error) {
+	return getSharedMemStats()
+}
+
+// GetIpcStats returns the SysV (shared memory, message queues, semaphores)
+// and POSIX message queue usage of the system together with the kernel
+// limits.
+func GetIpcStats() (IpcStats, error) {
+	return getIpcStats()
+}
+
+// GetKernelModules returns the kernel modules loaded in the system.
+func GetKernelModules() ([]KernelModule, error) {
+	return getKernelModules()
+}
+
+// GetKernelTaint returns the taint status of the kernel.
+func GetKernelTaint() (KernelTaint, error) {
+	return getKernelTaint()
+}
+
+// GetSystemdStats returns the systemd units of the system with their state
+// and restart counts.
+func GetSystemdStats() (SystemdStats, error) {
+	return getSystemdStats()
+}
+
+// GetPackageFacts returns the pending (security) updates of the system and
+// whether a reboot is required.
+func GetPackageFacts() (PackageFacts, error) {
+	return getPackageFacts()
+}
+
+// GetSnapshot reads the CPU, processes, network and disk raw stats (and the
+// content of the extra files given) as close together as possible.
+func GetSnapshot(extraFiles ...string) (Snapshot, error) {
+	return getSnapshot(extraFiles...)
+}
+
+// GetSnapshotAvgStats returns the statistics between 2 snapshots.
+func GetSnapshotAvgStats(firstSnapshot Snapshot, secondSnapshot Snapshot) (SnapshotAvgStats, error) {
+	return getSnapshotAvgStats(firstSnapshot, secondSnapshot)
+}
+
+// GetBundle collects a diagnostic bundle of the system (all the collectors,
+// several snapshots and the host facts).
+func GetBundle(config BundleConfig) (Bundle, error) {
+	return getBundle(config)
+}
+
+// WriteBundle writes a diagnostic bundle to w as a gzipped tar archive.
+func WriteBundle(w io.Writer, bundle Bundle) error {
+	return writeBundle(w, bundle)
+}
+
+// ReadBundle reads a diagnostic bundle written by WriteBundle.
+func ReadBundle(r io.Reader) (Bundle, error) {
+	return readBundle(r)
+}
+
+// Compare returns what changed between 2 diagnostic bundles (kernel
+// parameters, mounts, metrics baselines...).
+func Compare(firstBundle Bundle, secondBundle Bundle) []BundleChange {
+	return compareBundles(firstBundle, secondBundle)
+}
+
+// WriteCrashReport writes a crash report (a one-shot bundle and the recent
+// snapshots of the history) to the directory of the configuration, e.g. from
+// a panic handler. It returns the path of the report.
+func WriteCrashReport(config CrashReportConfig, reason string) (string, error) {
+	return writeCrashReport(config, reason)
+}
+
+// GetPidStats returns the statistics of all the processes of the system.
+func GetPidStats() ([]PidStats, error) {
+	return getPidStats()
+}
+
+// GetPids returns the ids (sorted) of the processes of the system.
+func GetPids() ([]int, error) {
+	return getPids()
+}
+
+// GetProcessStats returns the detailed statistics (memory, CPU, I/O and open
+// file descriptors) of a process.
+func GetProcessStats(pid int) (ProcessStats, error) {
+	return getProcessStats(pid)
+}
+
+// GetAllProcessStats returns the detailed statistics of all the processes of
+// the system.
+func GetAllProcessStats() ([]ProcessStats, error) {
+	return getAllProcessStats()
+}
+
+// GetProcessGroups returns the statistics of the processes of the system
+// aggregated by process group.
+func GetProcessGroups() ([]ProcessGroup, error) {
+	return getProcessGroups()
+}
+
+// GetDeletedOpenFiles returns the processes that hold deleted files open and
+// the space used by those files.
+func GetDeletedOpenFiles() ([]DeletedOpenFiles, error) {
+	return getDeletedOpenFiles()
+}
+
+// GetKernelLockupStats returns the hung tasks, lockups and RCU stalls
+// reported by the kernel.
+func GetKernelLockupStats() (KernelLockupStats, error) {
+	return getKernelLockupStats()
+}
+
+// GetBlockQueues returns the I/O scheduler and request queue parameters of
+// the block devices of the system.
+func GetBlockQueues() ([]BlockQueue, error) {
+	return getBlockQueues()
+}
+
+// GetCgroupIoStats returns the block I/O limits, counters and stalled times
+// of the cgroups of the system.
+func GetCgroupIoStats() ([]CgroupIoStats, error) {
+	return getCgroupIoStats()
+}
+
+// GetCgroupCpuStats returns the CPU bandwidth throttling statistics of the
+// cgroups of the system.
+func GetCgroupCpuStats() ([]CgroupCpuStats, error) {
+	return getCgroupCpuStats()
+}
+
+// GetFileLocks returns the file locks of the system.
+func GetFileLocks() (FileLocks, error) {
+	return getFileLocks()
+}
+
+// Watch samples the statistics of flags (e.g. WatchCpu|WatchMem|WatchNet)
+// every interval and sends them to the channel returned, that is closed when
+// ctx is done. The rates are calculated between consecutive samples.
+func Watch(ctx context.Context, interval time.Duration, flags WatchFlags) (<-chan WatchSample, error) {
+	return watch(ctx, interval, flags)
+}
+
+// GetStats returns the statistics of the collectors of flags (e.g.
+// WatchCpu|WatchMem|WatchLoad) in one struct, with the time and the hostname.
+// The rates are calculated over interval, so it blocks for interval.
+func GetStats(interval time.Duration, flags WatchFlags) (Stats, error) {
+	return getStats(interval, flags)
+}
+
+// GetStatsPartial returns the statistics of the collectors of flags like
+// GetStats, with the statistics of the collectors that succeeded if some
+// fail. The error is then a MultiError with a FieldError for each of them.
+func GetStatsPartial(interval time.Duration, flags WatchFlags) (Stats, error) {
+	return getStatsPartial(interval, flags)
+}
+
+// GetCgroupStats returns the memory, CPU, processes and I/O usage and limits
+// of a cgroup (e.g. /system.slice/docker-<id>.scope). If cgroup is empty,
+// it's the cgroup of the calling process, e.g. the container it runs in.
+func GetCgroupStats(cgroup string) (CgroupStats, error) {
+	return getCgroupStats(cgroup)
+}
+
+// GetPressureStats returns the Pressure Stall Information (some/full stall
+// averages and totals) of the CPU, memory and IO of the system. Enabled is
+// false if the kernel doesn't have PSI.
+func GetPressureStats() (PressureStats, error) {
+	return getPressureStats()
+}
+
+// GetSocStats returns the sensors of the SoC of the system (Raspberry Pi and
+// other ARM boards): temperatures, CPU frequency and throttling flags.
+func GetSocStats() (SocStats, error) {
+	return getSocStats()
+}
+
+// GetFsUsage returns the disk space and inode usage of the mounted file
+// systems. The statfs calls that don't return in the configured timeout are
+// reported as stale (use a FsUsageCollector to keep collecting them).
+func GetFsUsage(config FsUsageConfig) ([]FsUsage, error) {
+	return NewFsUsageCollector(config).Collect()
+}
+
+// GetIpmiStats returns the sensors of the BMC of the system (fans, power
+// supplies, temperatures...) and its power draw. It needs ipmitool and the
+// OpenIPMI driver.
+func GetIpmiStats() (IpmiStats, error) {
+	return getIpmiStats()
+}
+
+// GetUpsStats returns the status of the UPSs (battery charge, runtime
+// remaining, line voltage...) managed by the local NUT or apcupsd daemon.
+func GetUpsStats(config UpsConfig) ([]UpsStats, error) {
+	return getUpsStats(config)
+}
+
+// GetDiskTemps returns the temperatures of the drives of the system reported
+// by the drivetemp and nvme hwmons (it doesn't need SMART access).
+func GetDiskTemps() ([]DiskTemp, error) {
+	return getDiskTemps()
+}
+
+// GetVmRawStats returns the virtual memory and scheduler counters of the
+// system (paging, swapping, page faults, context switches, interrupts and
+// forks).
+func GetVmRawStats() (VmRawStats, error) {
+	return getVmRawStats()
+}
+
+// GetVmAvgStats calculates the statistics per second between 2 virtual memory
+// and scheduler counters samples.
+func GetVmAvgStats(firstSample VmRawStats, secondSample VmRawStats) (VmAvgStats, error) {
+	return getVmAvgStats(firstSample, secondSample)
+}
+
+// GetVmStatsInterval returns the virtual memory and scheduler statistics per
+// second between 2 samples taken in an interval (seconds).
+func GetVmStatsInterval(interval int64) (VmAvgStats, error) {
+	return getVmStatsInterval(interval)
+}
+
+// GetPciDevices returns the PCI devices of the system with their PCIe link
+// speed and width and their AER error counters.
+func GetPciDevices() ([]PciDevice, error) {
+	return getPciDevices()
+}
+
+// GetEdacStats returns the correctable and uncorrectable ECC memory errors of
+// the memory controllers of the system and their DIMMs.
+func GetEdacStats() ([]EdacController, error) {
+	return getEdacStats()
+}
+
+// GetSensorStats returns the hardware sensors of the system: the
+// temperatures, fans, voltages and power of the hwmon chips, the thermal zones
+// and the thermal throttling events of the CPUs.
+func GetSensorStats() (SensorStats, error) {
+	return getSensorStats()
+}
+
+// GetMachineCheckStats returns the hardware errors (machine checks, EDAC
+// memory errors and firmware errors) reported by the kernel with their
+// severity.
+func GetMachineCheckStats() (MachineCheckStats, error) {
+	return getMachineCheckStats()
+}
+
+// GetNumaNodeStats returns the memory statistics of each NUMA node of the
+// system (meminfo and numastat).
+func GetNumaNodeStats() ([]NumaNodeStats, error) {
+	return getNumaNodeStats()
+}
+
+// GetTopByCpu returns the n processes that used more CPU during interval
+// (all of them if n <= 0), sorted by CPU usage.
+func GetTopByCpu(n int, interval time.Duration) ([]ProcessSummary, error) {
+	return getTopByCpu(n, interval)
+}
+
+// GetTopByMemory returns the n processes with the biggest resident set size
+// (all of them if n <= 0), sorted by it.
+func GetTopByMemory(n int) ([]ProcessSummary, error) {
+	return getTopByMemory(n)
+}
+
+// GetTop returns the n processes ranked by CPU usage, resident set size or
+// GPU utilization (all of them if n <= 0), optionally with their GPU usage
+// (memory and utilization from the DRM fdinfo and nvidia-smi), so the
+// processes of a ML host can be ranked across the CPU, the RAM and the GPU
+// in a single view. The usage is calculated during interval, that is
+// optional for the memory ranking.
+func GetTop(n int, interval time.Duration, options TopOptions) ([]ProcessSummary, error) {
+	return getTop(n, interval, options)
+}
+
+// GetWatchdogs returns the watchdog devices of the system with their state,
+// timeout and whether the last boot was caused by the watchdog.
+func GetWatchdogs() ([]Watchdog, error) {
+	return getWatchdogs()
+}
+
+// GetResolvedStats returns the cache, transaction and DNSSEC statistics and
+// the DNS servers of systemd-resolved.
+func GetResolvedStats() (ResolvedStats, error) {
+	return getResolvedStats()
+}
+
+// GetTimeSyncStats returns the synchronization status and the sources of the
+// time daemon (chronyd or ntpd).
+func GetTimeSyncStats() (TimeSyncStats, error) {
+	return getTimeSyncStats()
+}
+
+// GetAuditStats returns the status of the kernel audit subsystem: the
+// backlog, the lost events and the rate limit.
+func GetAuditStats() (AuditStats, error) {
+	return getAuditStats()
+}
+
+// Summary returns a one-glance overview of the system (memory, swap, load
+// and the busiest disk and network interface) that renders like free -h with
+// String. It takes 1 second.
+func Summary() (SystemSummary, error) {
+	return getSummary()
+}
+
+// GetPidContainer returns the container (and the Kubernetes pod) a process
+// of the host runs in, from its cgroup. It returns nil if the process doesn't
+// run in a container.
+func GetPidContainer(pid int) (*ProcessContainer, error) {
+	return getPidContainer(pid)
+}
+
+// GetProcEnvironment returns the environment of the procfs: whether it's
+// mounted with hidepid, emulated by a sandbox (gVisor, WSL 1) or some of the
+// files the collectors read aren't available. If it's restricted, the
+// collectors should be run with SetProcRestricted(true) and the ones whose
+// files aren't available skipped.
+func GetProcEnvironment() ProcEnvironment {
+	return getProcEnvironment()
+}
+
+// SetLimits sets the max # of processes, TCP connections and mounts the
+// collectors read (DefaultLimits by default). Like SetProcRoot, it should be
+// called before collecting any statistics.
+func SetLimits(l Limits) {
+	setLimits(l)
+}
+
+// GetTruncations returns the # of times the collectors stopped reading at
+// one of the limits since the process started.
+func GetTruncations() Truncations {
+	return getTruncations()
+}
+
+// GetHostScore returns the saturation score of the system (USE method: the
+// utilization, saturation and errors of the CPU, the memory, the busiest
+// disk and the busiest network interface), all of it calculated between the
+// same 2 snapshots taken 1 second apart.
+func GetHostScore() (HostScore, error) {
+	return getHostScoreInterval()
+}
+
+// GetIrqRawStats returns the interrupt and softirq counters of the system
+// (the totals, per IRQ and per softirq type) from /proc/stat.
+func GetIrqRawStats() (IrqRawStats, error) {
+	return getIrqRawStats()
+}
+
+// GetIrqAvgStats calculates the interrupts and softirqs per second between 2
+// interrupt and softirq counters samples.
+func GetIrqAvgStats(firstSample IrqRawStats, secondSample IrqRawStats) (IrqAvgStats, error) {
+	return getIrqAvgStats(firstSample, secondSample)
+}
+
+// GetIrqStatsInterval returns the interrupts and softirqs per second between
+// 2 samples taken in an interval (seconds).
+func GetIrqStatsInterval(interval int64) (IrqAvgStats, error) {
+	return getIrqStatsInterval(interval)
+}
+
+// GetHostFingerprint returns the fingerprint of the host (machine-id,
+// primary MAC address and disk serials), to detect that a stored state
+// belongs to another machine.
+func GetHostFingerprint() (HostFingerprint, error) {
+	return getHostFingerprint()
+}
@@ -0,0 +1,43 @@
+// +build aix,cgo
+
+package sysstats
+
+// GetMemInfo returns the memory statistics of the system as a struct.
+func GetMemInfo() (MemInfo, error) {
+	return getMemInfo()
+}
+
+// GetCpuTimes returns the CPUs statistics of the system at the moment the
+// function is called.
+func GetCpuTimes() (map[string]CpuTimes, error) {
+	return getCpuTimes()
+}
+
+// GetCpuUsage calculates the % CPU usage between 2 GetCpuTimes samples.
+func GetCpuUsage(firstSample map[string]CpuTimes, secondSample map[string]CpuTimes) (map[string]CpuUsage, error) {
+	return getCpuUsage(firstSample, secondSample)
+}
+
+// GetDiskRawStats gets the disk IO stats of the system at the moment
+// the function is called.
+func GetDiskRawStats() ([]DiskRawStats, error) {
+	return getDiskRawStats()
+}
+
+// GetFilteredDiskRawStats gets the disk IO stats of the disks of the system
+// that match filter at the moment.
+func GetFilteredDiskRawStats(filter DiskFilter) ([]DiskRawStats, error) {
+	return getFilteredDiskRawStats(filter)
+}
+
+// GetDiskAvgStats calculates the average between 2 DiskRawStats samples and
+// returns the number of IOs per second.
+func GetDiskAvgStats(firstSampleArr []DiskRawStats, secondSampleArr []DiskRawStats) ([]DiskAvgStats, error) {
+	return getDiskAvgStats(firstSampleArr, secondSampleArr)
+}
+
+// GetUpsStats returns the status of the UPSs (battery charge, runtime
+// remaining, line voltage...) managed by the local NUT or apcupsd daemon.
+func GetUpsStats(config UpsConfig) ([]UpsStats, error) {
+	return getUpsStats(config)
+}
@@ -0,0 +1,9 @@
+// +build android
+
+package sysstats
+
+// GetAndroidMemInfo returns the memory statistics of the system with the
+// carve-outs (ION, CMA and GPU memory) some Android kernels report.
+func GetAndroidMemInfo() (AndroidMemInfo, error) {
+	return getAndroidMemInfo()
+}
@@ -0,0 +1,35 @@
+// +build darwin freebsd
+
+package sysstats
+
+// GetLoadAvg returns the load average of the system.
+func GetLoadAvg() (LoadAvg, error) {
+	return getLoadAvg()
+}
+
+// GetUptime returns the time since the system booted.
+func GetUptime() (Uptime, error) {
+	return getUptime()
+}
+
+// GetMemInfo returns the memory statistics of the system as a struct.
+func GetMemInfo() (MemInfo, error) {
+	return getMemInfo()
+}
+
+// GetCpuTimes returns the CPUs statistics of the system at the moment the
+// function is called. On macOS it needs cgo.
+func GetCpuTimes() (map[string]CpuTimes, error) {
+	return getCpuTimes()
+}
+
+// GetCpuUsage calculates the % CPU usage between 2 GetCpuTimes samples.
+func GetCpuUsage(firstSample map[string]CpuTimes, secondSample map[string]CpuTimes) (map[string]CpuUsage, error) {
+	return getCpuUsage(firstSample, secondSample)
+}
+
+// GetUpsStats returns the status of the UPSs (battery charge, runtime
+// remaining, line voltage...) managed by the local NUT or apcupsd daemon.
+func GetUpsStats(config UpsConfig) ([]UpsStats, error) {
+	return getUpsStats(config)
+}
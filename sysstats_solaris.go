@@ -0,0 +1,37 @@
+// +build solaris
+
+package sysstats
+
+// GetMemInfo returns the memory statistics of the system as a struct.
+func GetMemInfo() (MemInfo, error) {
+	return getMemInfo()
+}
+
+// GetCpuTimes returns the CPUs statistics of the system at the moment the
+// function is called.
+func GetCpuTimes() (map[string]CpuTimes, error) {
+	return getCpuTimes()
+}
+
+// GetCpuUsage calculates the % CPU usage between 2 GetCpuTimes samples.
+func GetCpuUsage(firstSample map[string]CpuTimes, secondSample map[string]CpuTimes) (map[string]CpuUsage, error) {
+	return getCpuUsage(firstSample, secondSample)
+}
+
+// GetNetCounters returns the statistics of all the network interfaces of the
+// system at the moment the function is called.
+func GetNetCounters() (map[string]IfaceCounters, error) {
+	return getNetCounters()
+}
+
+// GetNetRates calculates the network traffic (per second) between 2
+// GetNetCounters samples.
+func GetNetRates(firstSample map[string]IfaceCounters, secondSample map[string]IfaceCounters) (map[string]IfaceRates, error) {
+	return getNetRates(firstSample, secondSample)
+}
+
+// GetUpsStats returns the status of the UPSs (battery charge, runtime
+// remaining, line voltage...) managed by the local NUT or apcupsd daemon.
+func GetUpsStats(config UpsConfig) ([]UpsStats, error) {
+	return getUpsStats(config)
+}
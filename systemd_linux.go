@@ -0,0 +1,124 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// SystemdUnit represents the state of *one* systemd unit.
+type SystemdUnit struct {
+	Name        string `json:"name"`        // Name of the unit (e.g. sshd.service)
+	Load        string `json:"load"`        // Load state (loaded, not-found, masked...)
+	Active      string `json:"active"`      // Active state (active, inactive, failed...)
+	Sub         string `json:"sub"`         // Sub state (running, exited, dead...)
+	Description string `json:"description"` // Description of the unit
+	NRestarts   uint64 `json:"nrestarts"`   // # of automatic restarts (services only)
+}
+
+// SystemdStats represents the systemd units inventory of a linux system.
+type SystemdStats struct {
+	Units  []SystemdUnit `json:"units"`  // All the units known by systemd
+	Active uint64        `json:"active"` // # of active units
+	Failed uint64        `json:"failed"` // # of failed units
+}
+
+// getSystemdStats gets the systemd units of a linux system running the
+// commands:
+//   systemctl list-units --all --plain --no-legend --no-pager
+//   systemctl show --property=Id,NRestarts <services>
+// systemctl is used instead of talking D-Bus directly so the package keeps
+// depending on the standard library only.
+func getSystemdStats() (systemdStats SystemdStats, err error) {
+	systemctl, err := exec.LookPath("systemctl")
+	if err != nil {
+		return SystemdStats{}, err
+	}
+
+	out, err := exec.Command(systemctl, "list-units", "--all", "--plain", "--no-legend", "--no-pager").Output()
+	if err != nil {
+		return SystemdStats{}, err
+	}
+
+	systemdStats = SystemdStats{Units: make([]SystemdUnit, 0, 128)}
+	services := make([]string, 0, 64)
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		// UNIT LOAD ACTIVE SUB DESCRIPTION
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		unit := SystemdUnit{
+			Name:        fields[0],
+			Load:        fields[1],
+			Active:      fields[2],
+			Sub:         fields[3],
+			Description: strings.Join(fields[4:], ` `),
+		}
+		switch unit.Active {
+		case `active`:
+			systemdStats.Active++
+		case `failed`:
+			systemdStats.Failed++
+		}
+		if strings.HasSuffix(unit.Name, `.service`) {
+			services = append(services, unit.Name)
+		}
+		systemdStats.Units = append(systemdStats.Units, unit)
+	}
+
+	if len(services) == 0 {
+		return systemdStats, nil
+	}
+
+	restarts, err := getSystemdRestarts(systemctl, services)
+	if err != nil {
+		return SystemdStats{}, err
+	}
+	for i := range systemdStats.Units {
+		systemdStats.Units[i].NRestarts = restarts[systemdStats.Units[i].Name]
+	}
+
+	return systemdStats, nil
+}
+
+// getSystemdRestarts returns the # of restarts of the given services. The
+// output of `systemctl show` has one block per unit separated by empty lines:
+//   NRestarts=0
+//   Id=sshd.service
+//
+//   NRestarts=3
+//   Id=cron.service
+func getSystemdRestarts(systemctl string, services []string) (restarts map[string]uint64, err error) {
+	args := append([]string{"show", "--property=Id,NRestarts"}, services...)
+	out, err := exec.Command(systemctl, args...).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	restarts = map[string]uint64{}
+	for _, block := range strings.Split(string(out), "\n\n") {
+		id := ``
+		var nRestarts uint64
+		for _, line := range strings.Split(block, "\n") {
+			if strings.HasPrefix(line, `Id=`) {
+				id = line[3:]
+			} else if strings.HasPrefix(line, `NRestarts=`) {
+				// Old systemd versions don't have NRestarts
+				nRestarts, _ = strconv.ParseUint(line[10:], 10, 64)
+			}
+		}
+		if id != `` {
+			restarts[id] = nRestarts
+		}
+	}
+
+	return restarts, nil
+}
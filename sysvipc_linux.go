@@ -0,0 +1,334 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ShmSegment represents *one* SysV shared memory segment.
+type ShmSegment struct {
+	Key    int64  `json:"key"`    // Key of the segment
+	ShmId  uint64 `json:"shmid"`  // Id of the segment
+	Perms  string `json:"perms"`  // Permissions (octal)
+	Size   uint64 `json:"size"`   // Size of the segment (bytes)
+	Cpid   uint64 `json:"cpid"`   // PID of the creator
+	Lpid   uint64 `json:"lpid"`   // PID of the last process that attached/detached it
+	Nattch uint64 `json:"nattch"` // # of processes attached
+	Uid    uint64 `json:"uid"`    // Owner user id
+	Gid    uint64 `json:"gid"`    // Owner group id
+	Rss    uint64 `json:"rss"`    // Resident memory of the segment (bytes, since linux 4.x)
+	Swap   uint64 `json:"swap"`   // Swapped memory of the segment (bytes, since linux 4.x)
+}
+
+// MsgQueue represents *one* SysV message queue.
+type MsgQueue struct {
+	Key    int64  `json:"key"`    // Key of the queue
+	MsqId  uint64 `json:"msqid"`  // Id of the queue
+	Perms  string `json:"perms"`  // Permissions (octal)
+	Cbytes uint64 `json:"cbytes"` // # of bytes in the queue
+	Qnum   uint64 `json:"qnum"`   // # of messages in the queue
+	Lspid  uint64 `json:"lspid"`  // PID of the last sender
+	Lrpid  uint64 `json:"lrpid"`  // PID of the last receiver
+	Uid    uint64 `json:"uid"`    // Owner user id
+	Gid    uint64 `json:"gid"`    // Owner group id
+}
+
+// SemSet represents *one* SysV semaphore set.
+type SemSet struct {
+	Key   int64  `json:"key"`   // Key of the set
+	SemId uint64 `json:"semid"` // Id of the set
+	Perms string `json:"perms"` // Permissions (octal)
+	Nsems uint64 `json:"nsems"` // # of semaphores in the set
+	Uid   uint64 `json:"uid"`   // Owner user id
+	Gid   uint64 `json:"gid"`   // Owner group id
+}
+
+// PosixMqueue represents *one* POSIX message queue (from /dev/mqueue).
+type PosixMqueue struct {
+	Name  string `json:"name"`  // Name of the queue
+	Qsize uint64 `json:"qsize"` // # of bytes in the queue
+	Uid   uint64 `json:"uid"`   // Owner user id
+	Gid   uint64 `json:"gid"`   // Owner group id
+}
+
+// IpcLimits represents the kernel limits of the IPC resources.
+type IpcLimits struct {
+	ShmMax        uint64 `json:"shmmax"`        // Max size of a shared memory segment (bytes)
+	ShmAll        uint64 `json:"shmall"`        // Max shared memory of the system (pages)
+	ShmMni        uint64 `json:"shmmni"`        // Max # of shared memory segments
+	MsgMax        uint64 `json:"msgmax"`        // Max size of a message (bytes)
+	MsgMnb        uint64 `json:"msgmnb"`        // Max size of a message queue (bytes)
+	MsgMni        uint64 `json:"msgmni"`        // Max # of message queues
+	SemMsl        uint64 `json:"semmsl"`        // Max # of semaphores per set
+	SemMns        uint64 `json:"semmns"`        // Max # of semaphores of the system
+	SemOpm        uint64 `json:"semopm"`        // Max # of operations per semop call
+	SemMni        uint64 `json:"semmni"`        // Max # of semaphore sets
+	MqueuesMax    uint64 `json:"mqueuesmax"`    // Max # of POSIX message queues
+	MqueueMsgMax  uint64 `json:"mqueuemsgmax"`  // Max # of messages per POSIX queue
+	MqueueMsgsize uint64 `json:"mqueuemsgsize"` // Max size of a POSIX message (bytes)
+}
+
+// IpcStats represents the POSIX and SysV IPC statistics of a linux system.
+type IpcStats struct {
+	ShmSegments []ShmSegment  `json:"shmsegments"` // SysV shared memory segments
+	MsgQueues   []MsgQueue    `json:"msgqueues"`   // SysV message queues
+	SemSets     []SemSet      `json:"semsets"`     // SysV semaphore sets
+	PosixQueues []PosixMqueue `json:"posixqueues"` // POSIX message queues (empty if mqueue isn't mounted)
+	Limits      IpcLimits     `json:"limits"`      // Kernel limits
+}
+
+// getIpcStats gets the IPC statistics of a linux system from /proc/sysvipc,
+// /dev/mqueue, /proc/sys/kernel and /proc/sys/fs/mqueue.
+func getIpcStats() (ipcStats IpcStats, err error) {
+	ipcStats = IpcStats{}
+
+	if ipcStats.ShmSegments, err = getShmSegments(); err != nil {
+		return IpcStats{}, err
+	}
+	if ipcStats.MsgQueues, err = getMsgQueues(); err != nil {
+		return IpcStats{}, err
+	}
+	if ipcStats.SemSets, err = getSemSets(); err != nil {
+		return IpcStats{}, err
+	}
+	if ipcStats.PosixQueues, err = getPosixMqueues(); err != nil {
+		return IpcStats{}, err
+	}
+	if ipcStats.Limits, err = getIpcLimits(); err != nil {
+		return IpcStats{}, err
+	}
+
+	return ipcStats, nil
+}
+
+// getShmSegments gets the SysV shared memory segments of a linux system from
+// the file /proc/sysvipc/shm.
+func getShmSegments() (segments []ShmSegment, err error) {
+	rows, err := readSysvipcFile("shm")
+	if err != nil {
+		return nil, err
+	}
+
+	segments = make([]ShmSegment, 0, len(rows))
+	for _, row := range rows {
+		segment := ShmSegment{Perms: row[`perms`]}
+		if segment.Key, err = strconv.ParseInt(row[`key`], 10, 64); err != nil {
+			return nil, err
+		}
+		for key, value := range map[string]*uint64{
+			`shmid`:  &segment.ShmId,
+			`size`:   &segment.Size,
+			`cpid`:   &segment.Cpid,
+			`lpid`:   &segment.Lpid,
+			`nattch`: &segment.Nattch,
+			`uid`:    &segment.Uid,
+			`gid`:    &segment.Gid,
+			`rss`:    &segment.Rss,
+			`swap`:   &segment.Swap,
+		} {
+			if field, ok := row[key]; ok {
+				if *value, err = strconv.ParseUint(field, 10, 64); err != nil {
+					return nil, err
+				}
+			}
+		}
+		segments = append(segments, segment)
+	}
+
+	return segments, nil
+}
+
+// getMsgQueues gets the SysV message queues of a linux system from the file
+// /proc/sysvipc/msg.
+func getMsgQueues() (queues []MsgQueue, err error) {
+	rows, err := readSysvipcFile("msg")
+	if err != nil {
+		return nil, err
+	}
+
+	queues = make([]MsgQueue, 0, len(rows))
+	for _, row := range rows {
+		queue := MsgQueue{Perms: row[`perms`]}
+		if queue.Key, err = strconv.ParseInt(row[`key`], 10, 64); err != nil {
+			return nil, err
+		}
+		for key, value := range map[string]*uint64{
+			`msqid`:  &queue.MsqId,
+			`cbytes`: &queue.Cbytes,
+			`qnum`:   &queue.Qnum,
+			`lspid`:  &queue.Lspid,
+			`lrpid`:  &queue.Lrpid,
+			`uid`:    &queue.Uid,
+			`gid`:    &queue.Gid,
+		} {
+			if field, ok := row[key]; ok {
+				if *value, err = strconv.ParseUint(field, 10, 64); err != nil {
+					return nil, err
+				}
+			}
+		}
+		queues = append(queues, queue)
+	}
+
+	return queues, nil
+}
+
+// getSemSets gets the SysV semaphore sets of a linux system from the file
+// /proc/sysvipc/sem.
+func getSemSets() (sets []SemSet, err error) {
+	rows, err := readSysvipcFile("sem")
+	if err != nil {
+		return nil, err
+	}
+
+	sets = make([]SemSet, 0, len(rows))
+	for _, row := range rows {
+		set := SemSet{Perms: row[`perms`]}
+		if set.Key, err = strconv.ParseInt(row[`key`], 10, 64); err != nil {
+			return nil, err
+		}
+		for key, value := range map[string]*uint64{
+			`semid`: &set.SemId,
+			`nsems`: &set.Nsems,
+			`uid`:   &set.Uid,
+			`gid`:   &set.Gid,
+		} {
+			if field, ok := row[key]; ok {
+				if *value, err = strconv.ParseUint(field, 10, 64); err != nil {
+					return nil, err
+				}
+			}
+		}
+		sets = append(sets, set)
+	}
+
+	return sets, nil
+}
+
+var reMqueueQsize = regexp.MustCompile(`QSIZE:(\d+)`)
+
+// getPosixMqueues gets the POSIX message queues of a linux system from
+// /dev/mqueue. Every file has the following content:
+//   QSIZE:129     NOTIFY:2    SIGNO:0    NOTIFY_PID:8260
+// It returns an empty slice if the mqueue filesystem isn't mounted.
+func getPosixMqueues() (queues []PosixMqueue, err error) {
+	queues = []PosixMqueue{}
+
+	files, err := ioutil.ReadDir("/dev/mqueue")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return queues, nil
+		}
+		return nil, err
+	}
+
+	for _, file := range files {
+		content, err := ioutil.ReadFile(filepath.Join("/dev/mqueue", file.Name()))
+		if err != nil {
+			continue
+		}
+		queue := PosixMqueue{Name: file.Name()}
+		if stat := reMqueueQsize.FindStringSubmatch(string(content)); stat != nil {
+			if queue.Qsize, err = strconv.ParseUint(stat[1], 10, 64); err != nil {
+				return nil, err
+			}
+		}
+		if stat, ok := file.Sys().(*syscall.Stat_t); ok {
+			queue.Uid = uint64(stat.Uid)
+			queue.Gid = uint64(stat.Gid)
+		}
+		queues = append(queues, queue)
+	}
+
+	return queues, nil
+}
+
+// getIpcLimits gets the IPC limits of a linux system from /proc/sys/kernel
+// and /proc/sys/fs/mqueue. The POSIX message queue limits are 0 if the
+// kernel doesn't have them (/proc/sys/fs/mqueue doesn't exist).
+func getIpcLimits() (limits IpcLimits, err error) {
+	limits = IpcLimits{}
+
+	for file, value := range map[string]*uint64{
+		"kernel/shmmax":         &limits.ShmMax,
+		"kernel/shmall":         &limits.ShmAll,
+		"kernel/shmmni":         &limits.ShmMni,
+		"kernel/msgmax":         &limits.MsgMax,
+		"kernel/msgmnb":         &limits.MsgMnb,
+		"kernel/msgmni":         &limits.MsgMni,
+		"fs/mqueue/queues_max":  &limits.MqueuesMax,
+		"fs/mqueue/msg_max":     &limits.MqueueMsgMax,
+		"fs/mqueue/msgsize_max": &limits.MqueueMsgsize,
+	} {
+		if *value, err = getProcReader().readUint("sys", file); err != nil {
+			if os.IsNotExist(err) && strings.HasPrefix(file, "fs/mqueue/") {
+				*value = 0
+				continue
+			}
+			return IpcLimits{}, err
+		}
+	}
+
+	// /proc/sys/kernel/sem has the format: SEMMSL SEMMNS SEMOPM SEMMNI
+	content, err := getProcReader().readFile("sys", "kernel", "sem")
+	if err != nil {
+		return IpcLimits{}, err
+	}
+	fields := strings.Fields(string(content))
+	if len(fields) != 4 {
+		return IpcLimits{}, errors.New("Error parsing file /proc/sys/kernel/sem. It should have 4 fields")
+	}
+	for i, value := range []*uint64{&limits.SemMsl, &limits.SemMns, &limits.SemOpm, &limits.SemMni} {
+		if *value, err = strconv.ParseUint(fields[i], 10, 64); err != nil {
+			return IpcLimits{}, err
+		}
+	}
+
+	return limits, nil
+}
+
+// readSysvipcFile reads the file name of /proc/sysvipc. The first line of these files
+// is a header with the name of the columns:
+//        key      shmid perms       size  cpid  lpid nattch   uid   gid  cuid  cgid ...
+//          0          3  1600     524288  1234  5678      2  1000  1000  1000  1000 ...
+// It returns a map (column name -> value) per row.
+func readSysvipcFile(name string) (rows []map[string]string, err error) {
+	file, err := getProcReader().open("sysvipc", name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rows = make([]map[string]string, 0, 8)
+
+	scanner := bufio.NewScanner(file)
+	scanner.Split(bufio.ScanLines)
+	if !scanner.Scan() {
+		return rows, scanner.Err()
+	}
+	header := strings.Fields(scanner.Text())
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			if i < len(fields) {
+				row[name] = fields[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
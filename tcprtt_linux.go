@@ -0,0 +1,274 @@
+// +build linux
+
+package sysstats
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// TcpGroupBy indicates how the established TCP connections are grouped when
+// their RTT summaries are calculated.
+type TcpGroupBy int
+
+const (
+	// TcpGroupByRemotePort groups the connections by remote port.
+	TcpGroupByRemotePort TcpGroupBy = iota
+	// TcpGroupByRemoteSubnet groups the connections by remote subnet (/24 for
+	// IPv4 and /64 for IPv6).
+	TcpGroupByRemoteSubnet
+)
+
+// TcpRttSummary represents the RTT and retransmission distribution of a group
+// of established TCP connections.
+type TcpRttSummary struct {
+	Connections    uint64  `json:"connections"`    // # of established connections in the group
+	RttP50         float64 `json:"rttp50"`         // Median smoothed RTT (milliseconds)
+	RttP95         float64 `json:"rttp95"`         // 95th percentile of the smoothed RTT (milliseconds)
+	RttVarP50      float64 `json:"rttvarp50"`      // Median RTT variance (milliseconds)
+	RetransRateP50 float64 `json:"retransratep50"` // Median % of retransmitted segments
+	RetransRateP95 float64 `json:"retransratep95"` // 95th percentile of the % of retransmitted segments
+}
+
+// TcpRttStats represents the RTT summaries of the established TCP connections
+// of a linux system.
+//
+// Map keys:
+//   Group - remote port (e.g. 443) or remote subnet (e.g. 10.0.0.0/24)
+type TcpRttStats map[string]TcpRttSummary
+
+// Netlink sock_diag constants (see linux/sock_diag.h and linux/inet_diag.h)
+const (
+	netlinkSockDiag   = 4  // NETLINK_SOCK_DIAG
+	sockDiagByFamily  = 20 // SOCK_DIAG_BY_FAMILY
+	inetDiagInfo      = 2  // INET_DIAG_INFO
+	tcpEstablished    = 1  // TCP_ESTABLISHED
+	inetDiagReqV2Len  = 56 // sizeof(struct inet_diag_req_v2)
+	inetDiagMsgLen    = 72 // sizeof(struct inet_diag_msg)
+	tcpInfoRttOff     = 68 // offsetof(struct tcp_info, tcpi_rtt)
+	tcpInfoRttVarOff  = 72 // offsetof(struct tcp_info, tcpi_rttvar)
+	tcpInfoRetransOff = 100
+	tcpInfoSegsOutOff = 136
+)
+
+// tcpConnInfo represents the information of *one* established TCP connection
+// returned by sock_diag.
+type tcpConnInfo struct {
+	remoteIP      net.IP
+	remotePort    uint16
+	rtt           uint32 // microseconds
+	rttVar        uint32 // microseconds
+	totalRetrans  uint32
+	segsOut       uint32
+	hasSegsOutCnt bool
+}
+
+// getTcpRttStats gets the RTT summaries of the established TCP connections
+// (IPv4 and IPv6) of a linux system using the sock_diag netlink interface.
+func getTcpRttStats(groupBy TcpGroupBy) (tcpRttStats TcpRttStats, err error) {
+	conns := make([]tcpConnInfo, 0, 64)
+	for _, family := range []uint8{syscall.AF_INET, syscall.AF_INET6} {
+		familyConns, err := dumpTcpConns(family)
+		if err != nil {
+			return nil, err
+		}
+		conns = append(conns, familyConns...)
+	}
+
+	rtts := map[string][]float64{}
+	rttVars := map[string][]float64{}
+	retransRates := map[string][]float64{}
+	for _, conn := range conns {
+		group, err := tcpConnGroup(conn, groupBy)
+		if err != nil {
+			return nil, err
+		}
+		rtts[group] = append(rtts[group], float64(conn.rtt)/1000)
+		rttVars[group] = append(rttVars[group], float64(conn.rttVar)/1000)
+		if conn.hasSegsOutCnt && conn.segsOut > 0 {
+			rate := float64(conn.totalRetrans) * 100 / float64(conn.segsOut)
+			retransRates[group] = append(retransRates[group], rate)
+		}
+	}
+
+	tcpRttStats = TcpRttStats{}
+	for group, groupRtts := range rtts {
+		tcpRttStats[group] = TcpRttSummary{
+			Connections:    uint64(len(groupRtts)),
+			RttP50:         percentile(groupRtts, 50),
+			RttP95:         percentile(groupRtts, 95),
+			RttVarP50:      percentile(rttVars[group], 50),
+			RetransRateP50: percentile(retransRates[group], 50),
+			RetransRateP95: percentile(retransRates[group], 95),
+		}
+	}
+
+	return tcpRttStats, nil
+}
+
+// tcpConnGroup returns the name of the group the connection belongs to.
+func tcpConnGroup(conn tcpConnInfo, groupBy TcpGroupBy) (group string, err error) {
+	switch groupBy {
+	case TcpGroupByRemotePort:
+		return strconv.Itoa(int(conn.remotePort)), nil
+	case TcpGroupByRemoteSubnet:
+		if ip4 := conn.remoteIP.To4(); ip4 != nil {
+			subnet := net.IPNet{IP: ip4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}
+			return subnet.String(), nil
+		}
+		subnet := net.IPNet{IP: conn.remoteIP.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}
+		return subnet.String(), nil
+	}
+
+	return "", errors.New("Unknown TCP group by " + strconv.Itoa(int(groupBy)))
+}
+
+// dumpTcpConns sends a sock_diag dump request for the established TCP
+// connections of the given address family and parses the answer, up to
+// Limits.MaxConnections connections (the rest of the dump is dropped when
+// the socket is closed).
+func dumpTcpConns(family uint8) (conns []tcpConnInfo, err error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, netlinkSockDiag)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.Close(fd)
+
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, err
+	}
+
+	// struct nlmsghdr + struct inet_diag_req_v2
+	req := make([]byte, syscall.NLMSG_HDRLEN+inetDiagReqV2Len)
+	nativeEndian.PutUint32(req[0:4], uint32(len(req)))
+	nativeEndian.PutUint16(req[4:6], sockDiagByFamily)
+	nativeEndian.PutUint16(req[6:8], syscall.NLM_F_REQUEST|syscall.NLM_F_DUMP)
+	nativeEndian.PutUint32(req[8:12], 1)
+	req[16] = family
+	req[17] = syscall.IPPROTO_TCP
+	req[18] = 1 << (inetDiagInfo - 1)
+	nativeEndian.PutUint32(req[20:24], 1<<tcpEstablished)
+
+	if err := syscall.Sendto(fd, req, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, err
+	}
+
+	conns = make([]tcpConnInfo, 0, 64)
+	buf := make([]byte, 32*1024)
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return nil, err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range msgs {
+			switch msg.Header.Type {
+			case syscall.NLMSG_DONE:
+				return conns, nil
+			case syscall.NLMSG_ERROR:
+				return nil, errors.New("sock_diag netlink request failed")
+			}
+			if overLimit(len(conns), limits.MaxConnections, &truncations.Connections) {
+				return conns, nil
+			}
+			conn, ok, err := parseInetDiagMsg(family, msg.Data)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				conns = append(conns, conn)
+			}
+		}
+	}
+}
+
+// parseInetDiagMsg parses a struct inet_diag_msg followed by its attributes.
+// It returns false if the message doesn't include the tcp_info attribute and
+// an error if the message or one of its attributes is truncated.
+func parseInetDiagMsg(family uint8, data []byte) (conn tcpConnInfo, ok bool, err error) {
+	if len(data) < inetDiagMsgLen {
+		return tcpConnInfo{}, false, errors.New("Truncated inet_diag_msg")
+	}
+
+	// struct inet_diag_sockid starts at offset 4: sport, dport (big endian),
+	// src[4], dst[4]
+	conn.remotePort = binary.BigEndian.Uint16(data[6:8])
+	if family == syscall.AF_INET {
+		conn.remoteIP = net.IP(append([]byte(nil), data[24:28]...))
+	} else {
+		conn.remoteIP = net.IP(append([]byte(nil), data[24:40]...))
+	}
+
+	attrs := data[inetDiagMsgLen:]
+	for len(attrs) > 0 {
+		if len(attrs) < syscall.SizeofRtAttr {
+			return tcpConnInfo{}, false, errors.New("Truncated inet_diag_msg attribute")
+		}
+		attrLen := int(nativeEndian.Uint16(attrs[0:2]))
+		attrType := nativeEndian.Uint16(attrs[2:4])
+		if attrLen < syscall.SizeofRtAttr || attrLen > len(attrs) {
+			return tcpConnInfo{}, false, errors.New("Truncated inet_diag_msg attribute")
+		}
+		if attrType == inetDiagInfo {
+			info := attrs[syscall.SizeofRtAttr:attrLen]
+			if len(info) < tcpInfoRetransOff+4 {
+				return tcpConnInfo{}, false, errors.New("Truncated tcp_info")
+			}
+			conn.rtt = nativeEndian.Uint32(info[tcpInfoRttOff:])
+			conn.rttVar = nativeEndian.Uint32(info[tcpInfoRttVarOff:])
+			conn.totalRetrans = nativeEndian.Uint32(info[tcpInfoRetransOff:])
+			if len(info) >= tcpInfoSegsOutOff+4 {
+				conn.segsOut = nativeEndian.Uint32(info[tcpInfoSegsOutOff:])
+				conn.hasSegsOutCnt = true
+			}
+			return conn, true, nil
+		}
+		// Attributes are aligned to 4 bytes (the padding of the last one may
+		// be missing)
+		next := (attrLen + syscall.RTA_ALIGNTO - 1) & ^(syscall.RTA_ALIGNTO - 1)
+		if next > len(attrs) {
+			next = len(attrs)
+		}
+		attrs = attrs[next:]
+	}
+
+	return tcpConnInfo{}, false, nil
+}
+
+// percentile returns the p-th percentile (nearest rank) of values. It returns
+// 0 if there are no values.
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+
+	return sorted[rank]
+}
+
+// nativeEndian is the byte order of the netlink messages (host byte order).
+var nativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
@@ -0,0 +1,132 @@
+// +build linux
+
+package sysstats
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// inetDiagMsg is the payload of a SOCK_DIAG_BY_FAMILY answer in the layout of
+// an x86_64 host: an established IPv4 connection from 10.0.0.5:22 to
+// 192.168.1.20:54321 with its INET_DIAG_SHUTDOWN and INET_DIAG_INFO
+// attributes. The tcp_info is zeroed except for the fields read by
+// parseInetDiagMsg (and a few others) so the values are easy to check.
+var inetDiagMsg = strings.Join([]string{
+	// struct inet_diag_msg
+	"02010000 0016d431",
+	"0a000005 00000000 00000000 00000000",
+	"c0a80114 00000000 00000000 00000000",
+	"00000000 1f8a0300 00000000",
+	"00000000 00000000 00000000 e8030000 5d8e1f00",
+	// INET_DIAG_SHUTDOWN (1 byte, padded to 4)
+	"05000800 00000000",
+	// INET_DIAG_INFO: struct tcp_info (232 bytes)
+	"ec000200",
+	"01000000 00770000 e01c0300 00000000",
+	"a8050000 18020000 00000000 00000000",
+	"00000000 00000000 00000000 00000000",
+	"00000000 00000000 00000000 00000000",
+	"00000000 e2040000 71020000 ffffff7f",
+	"0a000000 00000000 00000000 00000000",
+	"00000000 03000000 00000000 00000000",
+	"00000000 00000000 00000000 00000000",
+	"00000000 00000000 2a000000 00000000",
+	"00000000 00000000 00000000 00000000",
+	"00000000 00000000 00000000 00000000",
+	"00000000 00000000 00000000 00000000",
+	"00000000 00000000 00000000 00000000",
+	"00000000 00000000 00000000 00000000",
+	"00000000 00000000",
+}, " ")
+
+// decodeInetDiagMsg returns the bytes of the captured message. It skips the
+// test on big endian hosts, where the attributes and the tcp_info of the
+// capture don't match the host byte order.
+func decodeInetDiagMsg(t *testing.T) []byte {
+	t.Helper()
+	if nativeEndian != binary.LittleEndian {
+		t.Skip("The captured sock_diag message is little endian")
+	}
+	data, err := hex.DecodeString(strings.Replace(inetDiagMsg, " ", "", -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseInetDiagMsg(t *testing.T) {
+	data := decodeInetDiagMsg(t)
+
+	conn, ok, err := parseInetDiagMsg(syscall.AF_INET, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("parseInetDiagMsg didn't find the tcp_info attribute")
+	}
+	want := tcpConnInfo{
+		remoteIP:      net.IP{192, 168, 1, 20},
+		remotePort:    54321,
+		rtt:           1250,
+		rttVar:        625,
+		totalRetrans:  3,
+		segsOut:       42,
+		hasSegsOutCnt: true,
+	}
+	if !reflect.DeepEqual(conn, want) {
+		t.Errorf("parseInetDiagMsg = %+v, want %+v", conn, want)
+	}
+
+	// A tcp_info of an old kernel, without tcpi_segs_out
+	old := append([]byte(nil), data[:inetDiagMsgLen+8]...)
+	old = append(old, 0, 0, 0, 0)
+	nativeEndian.PutUint16(old[inetDiagMsgLen+8:], syscall.SizeofRtAttr+tcpInfoSegsOutOff)
+	nativeEndian.PutUint16(old[inetDiagMsgLen+10:], inetDiagInfo)
+	old = append(old, data[inetDiagMsgLen+12:inetDiagMsgLen+12+tcpInfoSegsOutOff]...)
+	conn, ok, err = parseInetDiagMsg(syscall.AF_INET, old[:len(old):len(old)])
+	if err != nil || !ok {
+		t.Fatalf("parseInetDiagMsg = %v, %v, want true, nil", ok, err)
+	}
+	if conn.rtt != 1250 || conn.rttVar != 625 || conn.hasSegsOutCnt {
+		t.Errorf("parseInetDiagMsg = %+v, want rtt 1250, rttVar 625 and no segsOut", conn)
+	}
+
+	// Without INET_DIAG_INFO (and without the padding of the last attribute)
+	conn, ok, err = parseInetDiagMsg(syscall.AF_INET, data[:inetDiagMsgLen+5])
+	if err != nil || ok {
+		t.Errorf("parseInetDiagMsg without tcp_info = %+v, %v, %v, want false, nil", conn, ok, err)
+	}
+}
+
+func TestParseInetDiagMsgTruncated(t *testing.T) {
+	data := decodeInetDiagMsg(t)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"inet_diag_msg", data[:inetDiagMsgLen-1]},
+		{"attribute", data[:inetDiagMsgLen+8+100]},
+		{"attribute header", data[:inetDiagMsgLen+10]},
+		{"tcp_info", func() []byte {
+			short := append([]byte(nil), data[:inetDiagMsgLen+8+syscall.SizeofRtAttr+tcpInfoRetransOff]...)
+			nativeEndian.PutUint16(short[inetDiagMsgLen+8:], syscall.SizeofRtAttr+tcpInfoRetransOff)
+			return short
+		}()},
+	}
+	for _, test := range tests {
+		// Cap the slices so reading past their length panics
+		d := test.data[:len(test.data):len(test.data)]
+		conn, ok, err := parseInetDiagMsg(syscall.AF_INET, d)
+		if err == nil || ok {
+			t.Errorf("%s: parseInetDiagMsg = %+v, %v, %v, want an error", test.name, conn, ok, err)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// TenantRule represents how the cgroups of *one* tenant (team, customer,
+// pod...) are identified on a shared host.
+//
+// Labels map values can reference the groups of the pattern ($1, ${team}...).
+// If the rule has no labels the named groups of the pattern are the labels,
+// e.g. the pattern
+//   ^/kubepods\.slice/.*-pod(?P<pod>[0-9a-f_]+)\.slice
+// labels the cgroups of the pods with their uid.
+type TenantRule struct {
+	Pattern string            // Regular expression matched against the cgroup path (e.g. ^/team\.slice/(?P<team>[^/]+)/)
+	Labels  map[string]string // Labels of the cgroups matched (e.g. tenant: ${team})
+}
+
+// TenantMapper labels the cgroups (and the processes in them) with the
+// identifiers of their tenants, so the downstream systems can show each
+// tenant only its own stats.
+type TenantMapper struct {
+	rules    []TenantRule
+	patterns []*regexp.Regexp
+}
+
+// NewTenantMapper returns a TenantMapper with the given rules. The rules are
+// tried in order and the first one that matches labels the cgroup.
+func NewTenantMapper(rules []TenantRule) (*TenantMapper, error) {
+	m := &TenantMapper{rules: make([]TenantRule, len(rules)), patterns: make([]*regexp.Regexp, len(rules))}
+	for i, rule := range rules {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, errors.New("Couldn't compile the pattern of tenant rule " + strconv.Itoa(i) + ": " + err.Error())
+		}
+		labels := make(map[string]string, len(rule.Labels))
+		for name, value := range rule.Labels {
+			labels[name] = value
+		}
+		if len(labels) == 0 {
+			for _, name := range pattern.SubexpNames() {
+				if name != `` {
+					labels[name] = `${` + name + `}`
+				}
+			}
+		}
+		if len(labels) == 0 {
+			return nil, errors.New("Tenant rule " + strconv.Itoa(i) + " should have labels or named groups")
+		}
+		m.rules[i] = TenantRule{Pattern: rule.Pattern, Labels: labels}
+		m.patterns[i] = pattern
+	}
+
+	return m, nil
+}
+
+// Labels returns the tenant labels of a cgroup (its path within the
+// hierarchy, e.g. CgroupCpuStats.Cgroup). It returns an empty map if no rule
+// matches the cgroup.
+func (m *TenantMapper) Labels(cgroup string) (labels map[string]string) {
+	labels = map[string]string{}
+	for i, pattern := range m.patterns {
+		match := pattern.FindStringSubmatchIndex(cgroup)
+		if match == nil {
+			continue
+		}
+		for name, value := range m.rules[i].Labels {
+			labels[name] = string(pattern.ExpandString(nil, value, cgroup, match))
+		}
+		break
+	}
+
+	return labels
+}
+
+// PidLabels returns the tenant labels of a process from its cgroup.
+func (m *TenantMapper) PidLabels(pid int) (labels map[string]string, err error) {
+	cgroup, err := getPidCgroup(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Labels(cgroup), nil
+}
+
+// LabelMetrics returns a copy of metrics with the tenant labels added to the
+// metrics that have a cgroup label. The labels the metrics already have
+// aren't replaced. metrics isn't modified.
+func (m *TenantMapper) LabelMetrics(metrics []Metric) []Metric {
+	labeled := make([]Metric, len(metrics))
+	for i, metric := range metrics {
+		cgroup, ok := metric.Labels[`cgroup`]
+		if ok {
+			labels := make(map[string]string, len(metric.Labels))
+			for name, value := range m.Labels(cgroup) {
+				labels[name] = value
+			}
+			for name, value := range metric.Labels {
+				labels[name] = value
+			}
+			metric.Labels = labels
+		}
+		labeled[i] = metric
+	}
+
+	return labeled
+}
+
+// getPidCgroup gets the cgroup of a process from /proc/[pid]/cgroup. On
+// cgroup v1 it's the cgroup of the cpu controller:
+//   12:cpu,cpuacct:/system.slice/sshd.service
+// and on cgroup v2 the cgroup of the unified hierarchy:
+//   0::/system.slice/sshd.service
+func getPidCgroup(pid int) (cgroup string, err error) {
+	file, err := getProcReader().open(strconv.Itoa(pid), "cgroup")
+	if err != nil {
+		return ``, err
+	}
+	defer file.Close()
+
+	unified := ``
+	scanner := bufio.NewScanner(file)
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), `:`, 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if fields[0] == `0` && fields[1] == `` {
+			unified = fields[2]
+			continue
+		}
+		for _, controller := range strings.Split(fields[1], `,`) {
+			if controller == `cpu` {
+				return fields[2], nil
+			}
+		}
+	}
+	if unified == `` {
+		return ``, errors.New("Couldn't find the cgroup of process " + strconv.Itoa(pid))
+	}
+
+	return unified, nil
+}
@@ -0,0 +1,142 @@
+// Package termgraph renders the recent values of a metric (e.g. a History
+// series) as unicode sparklines and braille graphs for the terminal:
+//   values := history.CpuUsage(`cpu`, sysstats.Last(time.Minute)).Values()
+//   fmt.Println(termgraph.Sparkline(termgraph.Resample(values, 30)))
+//   fmt.Print(termgraph.Graph(values, 40, 4))
+package termgraph
+
+import (
+	"math"
+	"strings"
+)
+
+// sparks are the blocks of a sparkline, from the lowest to the highest.
+var sparks = []rune(`▁▂▃▄▅▆▇█`)
+
+// brailleBase is the blank braille pattern (U+2800): the dots of a cell are
+// the bits of its offset.
+const brailleBase = 0x2800
+
+// brailleDots are the bits of the dots of a braille cell by column and row
+// (from the top).
+var brailleDots = [2][4]rune{
+	{0x01, 0x02, 0x04, 0x40},
+	{0x08, 0x10, 0x20, 0x80},
+}
+
+// Sparkline returns the values as a sparkline of *one* block per value,
+// scaled between the min and the max value (all the blocks are the lowest
+// one if the values are the same). The NaN values are blanks.
+func Sparkline(values []float64) string {
+	min, max := bounds(values)
+	var b strings.Builder
+	for _, value := range values {
+		if math.IsNaN(value) {
+			b.WriteRune(' ')
+			continue
+		}
+		i := 0
+		if max > min {
+			i = int(math.Round((value - min) / (max - min) * float64(len(sparks)-1)))
+		}
+		b.WriteRune(sparks[i])
+	}
+
+	return b.String()
+}
+
+// Graph returns the values as an area graph of width x height braille
+// cells (one line per row, each one ended by a newline). Every cell has 2x4
+// dots, so the graph has the last 2*width values and 4*height levels, scaled
+// between 0 (or the min value if it's negative) and the max value (an empty
+// graph if they're all 0). The graph is right aligned when there are fewer
+// values.
+func Graph(values []float64, width int, height int) string {
+	if width <= 0 || height <= 0 {
+		return ``
+	}
+	if len(values) > 2*width {
+		values = values[len(values)-2*width:]
+	}
+	min, max := bounds(values)
+	if min > 0 {
+		min = 0
+	}
+	levels := 4 * height
+
+	cells := make([][]rune, height)
+	for row := range cells {
+		cells[row] = make([]rune, width)
+		for col := range cells[row] {
+			cells[row][col] = brailleBase
+		}
+	}
+	offset := 2*width - len(values)
+	for i, value := range values {
+		if math.IsNaN(value) {
+			continue
+		}
+		level := 0
+		if max > min {
+			level = int(math.Round((value - min) / (max - min) * float64(levels)))
+		}
+		x := offset + i
+		// The area under the value, from the bottom
+		for dot := 0; dot < level; dot++ {
+			y := levels - 1 - dot
+			cells[y/4][x/2] |= brailleDots[x%2][y%4]
+		}
+	}
+
+	var b strings.Builder
+	for _, row := range cells {
+		b.WriteString(string(row))
+		b.WriteByte('\n')
+	}
+
+	return b.String()
+}
+
+// Resample returns the values averaged into width buckets (the values as
+// they are if there are width or fewer), so a long window fits in a
+// sparkline. The NaN values are skipped (a bucket with only NaN values is
+// NaN).
+func Resample(values []float64, width int) []float64 {
+	if width <= 0 || len(values) <= width {
+		return values
+	}
+
+	resampled := make([]float64, width)
+	for i := range resampled {
+		from, to := i*len(values)/width, (i+1)*len(values)/width
+		sum, n := float64(0), 0
+		for _, value := range values[from:to] {
+			if !math.IsNaN(value) {
+				sum += value
+				n++
+			}
+		}
+		resampled[i] = math.NaN()
+		if n > 0 {
+			resampled[i] = sum / float64(n)
+		}
+	}
+
+	return resampled
+}
+
+// bounds returns the min and the max of the values that aren't NaN (0 if
+// there aren't any).
+func bounds(values []float64) (min float64, max float64) {
+	min, max = math.Inf(1), math.Inf(-1)
+	for _, value := range values {
+		if !math.IsNaN(value) {
+			min, max = math.Min(min, value), math.Max(max, value)
+		}
+	}
+	if math.IsInf(min, 1) {
+		return 0, 0
+	}
+
+	return min, max
+}
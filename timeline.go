@@ -0,0 +1,154 @@
+package sysstats
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Timeline event kinds
+const (
+	TimelineOomKill = "oomkill" // The OOM killer killed a process
+	TimelineMount   = "mount"   // A mount changed (mounted, unmounted, read-only...)
+	TimelineProcess = "process" // A watched process started or stopped
+	TimelineAlert   = "alert"   // An alert fired or was resolved
+	TimelineService = "service" // A watched service started, restarted, stopped, got ready or crash looped
+	TimelineTrace   = "trace"   // A block I/O request failed or a NIC timed out or changed its link (TraceWatcher)
+)
+
+// TimelineConfig represents how many events a Timeline retains. If both are
+// set, the events are dropped when either limit is reached.
+type TimelineConfig struct {
+	MaxAge    time.Duration // Max age of the events, relative to the newest one (default 24 hours if MaxEvents isn't set)
+	MaxEvents int           // Max # of events (0 means no limit)
+}
+
+// TimelineEvent represents *one* notable event of the system.
+//
+// Labels map keys depend on the kind of event: pid and comm (oomkill and
+// process), mountpoint, source and fstype (mount), rule and the labels of
+// the group (alert), and service, cgroup, pid and restarts (service).
+type TimelineEvent struct {
+	Time    time.Time         `json:"time"`    // Time of the event
+	Kind    string            `json:"kind"`    // Kind of event (oomkill, mount, process, alert, service or any other)
+	Action  string            `json:"action"`  // What happened (killed, mounted, readonly, started, stopped, firing, resolved...)
+	Message string            `json:"message"` // Description of the event
+	Labels  map[string]string `json:"labels"`  // Details of the event
+}
+
+// Timeline retains the notable events of the system (OOM kills, mount
+// changes, processes started and stopped, alerts...) sorted by time, so an
+// incident can be reconstructed from one place. The events are added by the
+// watchers (see TimelineWatcher) or by the application with Add.
+type Timeline struct {
+	config TimelineConfig
+	mu     sync.RWMutex
+	events []TimelineEvent
+	firing map[string]time.Time // Alerts firing (rule and labels) added to the timeline, with the time they started firing
+}
+
+// NewTimeline returns a Timeline for the given configuration.
+func NewTimeline(config TimelineConfig) *Timeline {
+	if config.MaxAge <= 0 && config.MaxEvents <= 0 {
+		config.MaxAge = 24 * time.Hour
+	}
+
+	return &Timeline{config: config, events: []TimelineEvent{}, firing: map[string]time.Time{}}
+}
+
+// Add adds events to the timeline (in any order) and drops the events over
+// the limits.
+func (t *Timeline) Add(events ...TimelineEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.events = append(t.events, events...)
+	sort.SliceStable(t.events, func(i, j int) bool { return t.events[i].Time.Before(t.events[j].Time) })
+
+	drop := 0
+	if t.config.MaxEvents > 0 && len(t.events) > t.config.MaxEvents {
+		drop = len(t.events) - t.config.MaxEvents
+	}
+	if t.config.MaxAge > 0 {
+		newest := t.events[len(t.events)-1].Time
+		for drop < len(t.events)-1 && newest.Sub(t.events[drop].Time) > t.config.MaxAge {
+			drop++
+		}
+	}
+	if drop > 0 {
+		t.events = append([]TimelineEvent{}, t.events[drop:]...)
+	}
+}
+
+// AddAlertEvents adds the alerts of the events of an AlertEngine: one event
+// when an alert starts firing (at the time it started) and one when it's
+// resolved. The alerts still firing in later events aren't added again.
+func (t *Timeline) AddAlertEvents(alertEvents []AlertEvent) {
+	events := make([]TimelineEvent, 0, len(alertEvents))
+	t.mu.Lock()
+	for _, alertEvent := range alertEvents {
+		for _, alert := range alertEvent.Firing {
+			key := alertEvent.Rule + `{` + labelsKey(alert.Labels) + `}`
+			if since, ok := t.firing[key]; ok && since.Equal(alert.Since) {
+				continue
+			}
+			t.firing[key] = alert.Since
+			events = append(events, newAlertTimelineEvent(alertEvent.Rule, alert, `firing`, alert.Since))
+		}
+		for _, alert := range alertEvent.Resolved {
+			delete(t.firing, alertEvent.Rule+`{`+labelsKey(alert.Labels)+`}`)
+			events = append(events, newAlertTimelineEvent(alertEvent.Rule, alert, `resolved`, alertEvent.Time))
+		}
+	}
+	t.mu.Unlock()
+	t.Add(events...)
+}
+
+// newAlertTimelineEvent returns the event of an alert of rule.
+func newAlertTimelineEvent(rule string, alert Alert, action string, at time.Time) TimelineEvent {
+	labels := map[string]string{`rule`: rule}
+	for name, value := range alert.Labels {
+		labels[name] = value
+	}
+
+	return TimelineEvent{
+		Time:    at,
+		Kind:    TimelineAlert,
+		Action:  action,
+		Message: rule + ` ` + action + ` {` + labelsKey(alert.Labels) + `}`,
+		Labels:  labels,
+	}
+}
+
+// Events returns the events of the window (oldest first). If kinds are
+// given, only the events of those kinds are returned.
+func (t *Timeline) Events(w Window, kinds ...string) (events []TimelineEvent) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	wanted := map[string]bool{}
+	for _, kind := range kinds {
+		wanted[kind] = true
+	}
+	events = []TimelineEvent{}
+	now := clockNow()
+	for _, event := range t.events {
+		if w.contains(event.Time, now) && (len(wanted) == 0 || wanted[event.Kind]) {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+// Len returns the # of events retained.
+func (t *Timeline) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return len(t.events)
+}
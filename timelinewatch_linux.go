@@ -0,0 +1,198 @@
+// +build linux
+
+package sysstats
+
+import (
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TimelineWatcherConfig represents the sources of events of a
+// TimelineWatcher.
+type TimelineWatcherConfig struct {
+	OomKills  bool     // Whether to add the OOM kills (read from /dev/kmsg, it needs CAP_SYSLOG if kernel.dmesg_restrict is set)
+	Mounts    bool     // Whether to add the changes of the mounts
+	Processes []string // Command names of the processes (e.g. the services) whose starts and stops are added
+}
+
+// TimelineWatcher watches the sources of events of a linux system and adds
+// their events to a Timeline on every call to Check. The alerts aren't
+// watched: the events of an AlertEngine are added with AddAlertEvents.
+type TimelineWatcher struct {
+	timeline  *Timeline
+	config    TimelineWatcherConfig
+	mu        sync.Mutex
+	mounts    *MountWatcher
+	sequence  uint64 // Sequence of the last kmsg record seen
+	kmsgSeen  bool
+	processes map[taskKey]PidStats
+	procsSeen bool
+}
+
+// reOomKill matches the kmsg record of the OOM killer killing a process:
+//   Out of memory: Killed process 1234 (java) total-vm:...
+//   Memory cgroup out of memory: Killed process 1234 (java) total-vm:...
+var reOomKill = regexp.MustCompile(`[Oo]ut of memory: Kill(?:ed)? process (\d+) \(([^)]*)\)`)
+
+// NewTimelineWatcher returns a TimelineWatcher that adds the events to
+// timeline.
+func NewTimelineWatcher(timeline *Timeline, config TimelineWatcherConfig) *TimelineWatcher {
+	return &TimelineWatcher{timeline: timeline, config: config, mounts: NewMountWatcher(), processes: map[taskKey]PidStats{}}
+}
+
+// Check adds the events since the previous call to the timeline and returns
+// them. The first call only adds the OOM kills still in the kernel log
+// buffer. If a source fails, the events of the other ones are still added
+// and err is a MultiError with a FieldError for each source that failed
+// (oomkill, mount or process).
+func (w *TimelineWatcher) Check() (events []TimelineEvent, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	events = []TimelineEvent{}
+	errs := MultiError{}
+	if w.config.OomKills {
+		oomEvents, err := w.checkOomKills(now)
+		if err != nil {
+			errs = append(errs, &FieldError{Field: TimelineOomKill, Err: err})
+		}
+		events = append(events, oomEvents...)
+	}
+	if w.config.Mounts {
+		mountEvents, err := w.mounts.Check()
+		if err != nil {
+			errs = append(errs, &FieldError{Field: TimelineMount, Err: err})
+		}
+		events = append(events, mountTimelineEvents(mountEvents)...)
+	}
+	if len(w.config.Processes) > 0 {
+		processEvents, err := w.checkProcesses(now)
+		if err != nil {
+			errs = append(errs, &FieldError{Field: TimelineProcess, Err: err})
+		}
+		events = append(events, processEvents...)
+	}
+	w.timeline.Add(events...)
+
+	return events, errs.errorOrNil()
+}
+
+// AddMountEvents adds the changes of the mounts of a MountWatcher.
+func (t *Timeline) AddMountEvents(mountEvents []MountEvent) {
+	t.Add(mountTimelineEvents(mountEvents)...)
+}
+
+// mountTimelineEvents returns the events of the changes of the mounts.
+func mountTimelineEvents(mountEvents []MountEvent) (events []TimelineEvent) {
+	events = make([]TimelineEvent, 0, len(mountEvents))
+	for _, mountEvent := range mountEvents {
+		events = append(events, TimelineEvent{
+			Time:    mountEvent.Time,
+			Kind:    TimelineMount,
+			Action:  mountEvent.Kind,
+			Message: mountEvent.MountPoint + ` ` + mountEvent.Kind,
+			Labels:  map[string]string{`mountpoint`: mountEvent.MountPoint, `source`: mountEvent.Source, `fstype`: mountEvent.FsType},
+		})
+	}
+
+	return events
+}
+
+// checkOomKills returns the OOM kills of the kmsg records after the last one
+// seen. The timestamps of the records (seconds since boot) are converted to
+// wall clock times with the uptime.
+func (w *TimelineWatcher) checkOomKills(now time.Time) (events []TimelineEvent, err error) {
+	records, err := readKmsg()
+	if err != nil {
+		return nil, err
+	}
+	uptime, err := getUptime()
+	if err != nil {
+		return nil, err
+	}
+	boot := now.Add(-uptime.Uptime)
+
+	events = []TimelineEvent{}
+	for _, record := range records {
+		if w.kmsgSeen && record.Sequence <= w.sequence {
+			continue
+		}
+		w.sequence = record.Sequence
+		w.kmsgSeen = true
+
+		match := reOomKill.FindStringSubmatch(record.Message)
+		if match == nil {
+			continue
+		}
+		events = append(events, TimelineEvent{
+			Time:    boot.Add(time.Duration(record.Timestamp * float64(time.Second))),
+			Kind:    TimelineOomKill,
+			Action:  `killed`,
+			Message: record.Message,
+			Labels:  map[string]string{`pid`: match[1], `comm`: match[2]},
+		})
+	}
+
+	return events, nil
+}
+
+// checkProcesses returns the watched processes that started and stopped
+// since the previous call. The processes are identified by pid and start
+// time, so a reused pid is a stop and a start. The first call only records
+// the processes running.
+func (w *TimelineWatcher) checkProcesses(now time.Time) (events []TimelineEvent, err error) {
+	pidStatsArr, err := getPidStats()
+	if err != nil {
+		return nil, err
+	}
+	uptime, err := getUptime()
+	if err != nil {
+		return nil, err
+	}
+	boot := now.Add(-uptime.Uptime)
+
+	watched := make(map[string]bool, len(w.config.Processes))
+	for _, comm := range w.config.Processes {
+		watched[comm] = true
+	}
+
+	events = []TimelineEvent{}
+	processes := map[taskKey]PidStats{}
+	for _, pidStats := range pidStatsArr {
+		if !watched[pidStats.Comm] {
+			continue
+		}
+		key := taskKey{id: pidStats.Pid, startTime: pidStats.StartTime}
+		processes[key] = pidStats
+		if _, ok := w.processes[key]; !ok && w.procsSeen {
+			started := boot.Add(time.Duration(pidStats.StartTime) * time.Second / userHz)
+			events = append(events, newProcessTimelineEvent(pidStats, `started`, started))
+		}
+	}
+	for key, pidStats := range w.processes {
+		if _, ok := processes[key]; !ok {
+			// The exact time it stopped isn't known: it's the time it was detected
+			events = append(events, newProcessTimelineEvent(pidStats, `stopped`, now))
+		}
+	}
+	w.processes = processes
+	w.procsSeen = true
+
+	return events, nil
+}
+
+// newProcessTimelineEvent returns the event of a watched process.
+func newProcessTimelineEvent(pidStats PidStats, action string, at time.Time) TimelineEvent {
+	pid := strconv.Itoa(pidStats.Pid)
+
+	return TimelineEvent{
+		Time:    at,
+		Kind:    TimelineProcess,
+		Action:  action,
+		Message: pidStats.Comm + ` (` + pid + `) ` + action,
+		Labels:  map[string]string{`pid`: pid, `comm`: pidStats.Comm},
+	}
+}
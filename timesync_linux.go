@@ -0,0 +1,322 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// TimeSource represents *one* source (server, peer or reference clock) of
+// the time daemon.
+type TimeSource struct {
+	Name      string  `json:"name"`      // Name or IP address of the source
+	Mode      string  `json:"mode"`      // Mode of the source (server, peer or refclock)
+	State     string  `json:"state"`     // Selection state (selected, combined, candidate, notcombined, falseticker, variable, unreachable or unknown)
+	Stratum   uint64  `json:"stratum"`   // Stratum of the source
+	Poll      uint64  `json:"poll"`      // Polling interval (seconds)
+	Reach     uint64  `json:"reach"`     // Reachability register (one bit per the last 8 polls)
+	Reachable bool    `json:"reachable"` // Whether any of the last 8 polls got an answer
+	LastRx    uint64  `json:"lastrx"`    // Seconds since the last sample was received
+	Offset    float64 `json:"offset"`    // Offset of the local clock from the source (seconds, positive if it's ahead)
+	Jitter    float64 `json:"jitter"`    // Jitter of the samples of the source (seconds; the error bound with chronyd)
+	Delay     float64 `json:"delay"`     // Round-trip delay to the source (seconds; only reported by ntpd)
+}
+
+// TimeSyncStats represents the synchronization status reported by the time
+// daemon (chronyd or ntpd) of a linux system.
+type TimeSyncStats struct {
+	Daemon         string       `json:"daemon"`         // Time daemon (chronyd or ntpd)
+	Reference      string       `json:"reference"`      // Name or IP address of the reference the clock is synchronized to
+	Stratum        uint64       `json:"stratum"`        // Stratum of the local clock
+	Offset         float64      `json:"offset"`         // Offset of the local clock (seconds, positive if it's ahead)
+	RmsOffset      float64      `json:"rmsoffset"`      // Long-term average of the offset (seconds; only reported by chronyd)
+	Jitter         float64      `json:"jitter"`         // Jitter of the local clock (seconds; only reported by ntpd)
+	Frequency      float64      `json:"frequency"`      // Frequency error of the local clock (ppm, positive if it's fast)
+	RootDelay      float64      `json:"rootdelay"`      // Total round-trip delay to the stratum 1 (seconds)
+	RootDispersion float64      `json:"rootdispersion"` // Total dispersion to the stratum 1 (seconds)
+	Leap           string       `json:"leap"`           // Leap status (normal, insert, delete or unsynchronised)
+	Sources        []TimeSource `json:"sources"`        // Sources of the time daemon
+}
+
+// chronyStates are the selection states of the sources of `chronyc sources`.
+var chronyStates = map[string]string{
+	`*`: `selected`,
+	`+`: `combined`,
+	`-`: `notcombined`,
+	`x`: `falseticker`,
+	`~`: `variable`,
+	`?`: `unreachable`,
+}
+
+// chronyModes are the modes of the sources of `chronyc sources`.
+var chronyModes = map[string]string{
+	`^`: `server`,
+	`=`: `peer`,
+	`#`: `refclock`,
+}
+
+// ntpStates are the selection states of the tally codes of `ntpq -p`.
+var ntpStates = map[byte]string{
+	'*': `selected`,
+	'o': `selected`,
+	'+': `combined`,
+	'#': `candidate`,
+	'-': `notcombined`,
+	'x': `falseticker`,
+	'.': `notcombined`,
+	' ': `unknown`,
+}
+
+// ntpLeaps are the leap status of the leap indicator of ntpd.
+var ntpLeaps = map[string]string{
+	`00`: `normal`,
+	`01`: `insert`,
+	`10`: `delete`,
+	`11`: `unsynchronised`,
+}
+
+// getTimeSyncStats gets the synchronization status of the time daemon of a
+// linux system. chronyd is queried running the commands:
+//   chronyc -c -n tracking
+//   chronyc -c -n sources
+// and, if chronyc isn't installed, ntpd running the commands:
+//   ntpq -n -c rv
+//   ntpq -n -p
+// It returns an error if neither is installed or the daemon doesn't answer.
+func getTimeSyncStats() (timeSyncStats TimeSyncStats, err error) {
+	if chronyc, err := exec.LookPath("chronyc"); err == nil {
+		return getChronyStats(chronyc)
+	}
+	ntpq, err := exec.LookPath("ntpq")
+	if err != nil {
+		return TimeSyncStats{}, errors.New("Neither chronyc nor ntpq is installed")
+	}
+
+	return getNtpStats(ntpq)
+}
+
+// getChronyStats gets the synchronization status of chronyd.
+func getChronyStats(chronyc string) (timeSyncStats TimeSyncStats, err error) {
+	tracking, err := exec.Command(chronyc, "-c", "-n", "tracking").Output()
+	if err != nil {
+		return TimeSyncStats{}, err
+	}
+	if timeSyncStats, err = parseChronyTracking(tracking); err != nil {
+		return TimeSyncStats{}, err
+	}
+
+	sources, err := exec.Command(chronyc, "-c", "-n", "sources").Output()
+	if err != nil {
+		return TimeSyncStats{}, err
+	}
+	if timeSyncStats.Sources, err = parseChronySources(sources); err != nil {
+		return TimeSyncStats{}, err
+	}
+
+	return timeSyncStats, nil
+}
+
+// parseChronyTracking parses the output of `chronyc -c tracking`, that has
+// *one* line with the comma separated fields: reference id, reference,
+// stratum, reference time, system time offset, last offset, RMS offset,
+// frequency, residual frequency, skew, root delay, root dispersion, update
+// interval and leap status:
+//   C0A80101,192.168.1.1,3,1700000000.123,-0.000012,0.000003,0.000020,-12.345,0.001,0.050,0.012345,0.000678,64.5,Normal
+func parseChronyTracking(out []byte) (timeSyncStats TimeSyncStats, err error) {
+	fields := strings.Split(strings.TrimSpace(string(out)), `,`)
+	if len(fields) < 14 {
+		return TimeSyncStats{}, errors.New("Error parsing the output of chronyc tracking. It should have 14 fields")
+	}
+
+	timeSyncStats = TimeSyncStats{Daemon: `chronyd`, Reference: fields[1], Leap: strings.ToLower(fields[13])}
+	if timeSyncStats.Stratum, err = strconv.ParseUint(fields[2], 10, 64); err != nil {
+		return TimeSyncStats{}, err
+	}
+	for i, value := range map[int]*float64{
+		4:  &timeSyncStats.Offset,
+		6:  &timeSyncStats.RmsOffset,
+		7:  &timeSyncStats.Frequency,
+		10: &timeSyncStats.RootDelay,
+		11: &timeSyncStats.RootDispersion,
+	} {
+		if *value, err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return TimeSyncStats{}, err
+		}
+	}
+	// chronyc reports the time the clock is slow and the frequency error
+	// that makes it slow
+	timeSyncStats.Offset = -timeSyncStats.Offset
+	timeSyncStats.Frequency = -timeSyncStats.Frequency
+	if timeSyncStats.Leap == `not synchronised` {
+		timeSyncStats.Leap = `unsynchronised`
+	}
+
+	return timeSyncStats, nil
+}
+
+// parseChronySources parses the output of `chronyc -c sources`, that has one
+// line per source with the comma separated fields: mode, state, name,
+// stratum, poll (log2 seconds), reach (octal), last rx, adjusted offset,
+// measured offset and error:
+//   ^,*,192.168.1.1,2,6,377,32,0.000012,0.000015,0.000500
+func parseChronySources(out []byte) (sources []TimeSource, err error) {
+	sources = []TimeSource{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), `,`)
+		if len(fields) < 10 {
+			continue
+		}
+		source := TimeSource{Name: fields[2], Mode: chronyModes[fields[0]], State: chronyStates[fields[1]]}
+		if source.State == `` {
+			source.State = `unknown`
+		}
+		source.Stratum, _ = strconv.ParseUint(fields[3], 10, 64)
+		if poll, err := strconv.ParseInt(fields[4], 10, 64); err == nil && poll >= 0 && poll < 64 {
+			source.Poll = 1 << uint(poll)
+		}
+		source.Reach, _ = strconv.ParseUint(fields[5], 8, 64)
+		source.Reachable = source.Reach != 0
+		source.LastRx, _ = strconv.ParseUint(fields[6], 10, 64)
+		source.Offset, _ = strconv.ParseFloat(fields[7], 64)
+		source.Jitter, _ = strconv.ParseFloat(fields[9], 64)
+		sources = append(sources, source)
+	}
+
+	return sources, nil
+}
+
+// getNtpStats gets the synchronization status of ntpd.
+func getNtpStats(ntpq string) (timeSyncStats TimeSyncStats, err error) {
+	rv, err := exec.Command(ntpq, "-n", "-c", "rv").Output()
+	if err != nil {
+		return TimeSyncStats{}, err
+	}
+	timeSyncStats = parseNtpVariables(rv)
+
+	peers, err := exec.Command(ntpq, "-n", "-p").Output()
+	if err != nil {
+		return TimeSyncStats{}, err
+	}
+	timeSyncStats.Sources = parseNtpPeers(peers)
+	for _, source := range timeSyncStats.Sources {
+		if source.State == `selected` {
+			timeSyncStats.Reference = source.Name
+		}
+	}
+
+	return timeSyncStats, nil
+}
+
+// parseNtpVariables parses the system variables of `ntpq -c rv`, that are
+// comma separated name=value pairs (the times are in milliseconds and the
+// frequency in ppm):
+//   associd=0 status=0615 leap_none, sync_ntp, 1 event, clock_sync,
+//   version="ntpd 4.2.8p15", processor="x86_64", system="Linux/5.15.0",
+//   leap=00, stratum=3, precision=-23, rootdelay=12.345, rootdisp=0.678,
+//   refid=192.168.1.1, offset=-0.023, frequency=-12.345, sys_jitter=0.011
+func parseNtpVariables(out []byte) (timeSyncStats TimeSyncStats) {
+	timeSyncStats = TimeSyncStats{Daemon: `ntpd`}
+	for _, pair := range strings.Split(strings.Replace(string(out), "\n", ``, -1), `,`) {
+		equal := strings.IndexByte(pair, '=')
+		if equal < 0 {
+			continue
+		}
+		name := strings.TrimSpace(pair[:equal])
+		value := strings.Trim(strings.TrimSpace(pair[equal+1:]), `"`)
+		number, _ := strconv.ParseFloat(value, 64)
+		switch name {
+		case `leap`:
+			timeSyncStats.Leap = ntpLeaps[value]
+		case `stratum`:
+			timeSyncStats.Stratum, _ = strconv.ParseUint(value, 10, 64)
+		case `offset`:
+			// ntpd reports the offset of the servers from the local clock
+			timeSyncStats.Offset = -number / 1000
+		case `sys_jitter`:
+			timeSyncStats.Jitter = number / 1000
+		case `frequency`:
+			timeSyncStats.Frequency = number
+		case `rootdelay`:
+			timeSyncStats.RootDelay = number / 1000
+		case `rootdisp`:
+			timeSyncStats.RootDispersion = number / 1000
+		}
+	}
+
+	return timeSyncStats
+}
+
+// parseNtpPeers parses the peers of `ntpq -p`, that has the tally code
+// before the name of each peer (the times are in milliseconds):
+//        remote           refid      st t when poll reach   delay   offset  jitter
+//   ==============================================================================
+//   *192.168.1.1     .GPS.            1 u   33   64  377    0.512   -0.023   0.011
+//   +10.0.0.1        192.168.1.1      2 u   12   64  377    1.234    0.120   0.050
+func parseNtpPeers(out []byte) (sources []TimeSource) {
+	sources = []TimeSource{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) < 2 || strings.HasPrefix(line, `=`) || strings.HasPrefix(strings.TrimSpace(line), `remote`) {
+			continue
+		}
+		fields := strings.Fields(line[1:])
+		if len(fields) < 10 {
+			continue
+		}
+		source := TimeSource{Name: fields[0], Mode: `server`, State: ntpStates[line[0]]}
+		if source.State == `` {
+			source.State = `unknown`
+		}
+		switch fields[3] {
+		case `s`, `S`:
+			source.Mode = `peer`
+		case `l`:
+			source.Mode = `refclock`
+		}
+		source.Stratum, _ = strconv.ParseUint(fields[2], 10, 64)
+		source.LastRx = parseNtpDuration(fields[4])
+		source.Poll = parseNtpDuration(fields[5])
+		source.Reach, _ = strconv.ParseUint(fields[6], 8, 64)
+		source.Reachable = source.Reach != 0
+		if !source.Reachable && source.State == `unknown` {
+			source.State = `unreachable`
+		}
+		delay, _ := strconv.ParseFloat(fields[7], 64)
+		offset, _ := strconv.ParseFloat(fields[8], 64)
+		jitter, _ := strconv.ParseFloat(fields[9], 64)
+		// ntpq reports the offset of the peer from the local clock
+		source.Delay, source.Offset, source.Jitter = delay/1000, -offset/1000, jitter/1000
+		sources = append(sources, source)
+	}
+
+	return sources
+}
+
+// parseNtpDuration parses a duration of `ntpq -p` (seconds, or minutes,
+// hours and days with the m, h and d suffixes). A - (never) is 0.
+func parseNtpDuration(value string) uint64 {
+	multiplier := uint64(1)
+	switch {
+	case strings.HasSuffix(value, `m`):
+		multiplier = 60
+	case strings.HasSuffix(value, `h`):
+		multiplier = 60 * 60
+	case strings.HasSuffix(value, `d`):
+		multiplier = 24 * 60 * 60
+	}
+	n, err := strconv.ParseUint(strings.TrimRight(value, `mhd`), 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return n * multiplier
+}
@@ -0,0 +1,214 @@
+// +build linux
+
+package sysstats
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProcessSummary represents the summary of *one* process of a top-N ranking.
+type ProcessSummary struct {
+	Pid       int               `json:"pid"`       // Process id
+	Comm      string            `json:"comm"`      // Command name
+	Cmdline   string            `json:"cmdline"`   // Command line (with spaces between the arguments). Empty for the kernel threads
+	CpuPer    float64           `json:"cpuper"`    // % of CPU time used (100% is a whole CPU, as top and ps)
+	Rss       uint64            `json:"rss"`       // Resident set size in bytes
+	Cgroup    string            `json:"cgroup"`    // Cgroup of the process (empty if it has exited)
+	Container *ProcessContainer `json:"container"` // Container (and pod) of the process (nil if it doesn't run in a container)
+	Gpu       *ProcessGpu       `json:"gpu"`       // GPU usage of the process (nil if it wasn't requested or the process doesn't use a GPU)
+}
+
+// userHz is the USER_HZ of the CPU times of /proc/[pid]/stat (it's 100 on all
+// the architectures supported by Go).
+const userHz = 100
+
+// Top-N rankings
+const (
+	TopByCpu    = "cpu"    // By CPU usage
+	TopByMemory = "memory" // By resident set size
+	TopByGpu    = "gpu"    // By GPU utilization (and GPU memory)
+)
+
+// TopOptions represents how the processes of a top-N ranking are ranked and
+// what they include.
+type TopOptions struct {
+	SortBy string // Ranking (cpu, memory or gpu). It's cpu by default
+	Gpu    bool   // Whether to include the GPU usage of the processes (always included by the gpu ranking)
+}
+
+// getTopByCpu returns the n processes of a linux system that used more CPU
+// during interval, sorted by CPU usage. If n <= 0 it returns all the
+// processes. The processes that started during the interval are ranked by all
+// their CPU time, and the ones that exited are skipped.
+func getTopByCpu(n int, interval time.Duration) (summaries []ProcessSummary, err error) {
+	return getTop(n, interval, TopOptions{SortBy: TopByCpu})
+}
+
+// getTopByMemory returns the n processes of a linux system with the biggest
+// resident set size, sorted by it. If n <= 0 it returns all the processes.
+// The CPU usage is the average since the process started (as ps).
+func getTopByMemory(n int) (summaries []ProcessSummary, err error) {
+	return getTop(n, 0, TopOptions{SortBy: TopByMemory})
+}
+
+// getTop returns the n processes of a linux system ranked as options (all
+// of them if n <= 0). The CPU usage (and the GPU utilization) is calculated
+// during interval, that is only optional for the memory ranking: without it
+// the CPU usage is the average since the process started and the GPU
+// utilization isn't known. The processes that started during the interval
+// are ranked by all their CPU time, and the ones that exited are skipped.
+func getTop(n int, interval time.Duration, options TopOptions) (summaries []ProcessSummary, err error) {
+	if options.SortBy == `` {
+		options.SortBy = TopByCpu
+	}
+	if options.SortBy != TopByCpu && options.SortBy != TopByMemory && options.SortBy != TopByGpu {
+		return nil, errors.New("Unknown top-N ranking " + options.SortBy)
+	}
+	gpu := options.Gpu || options.SortBy == TopByGpu
+	if interval <= 0 && options.SortBy != TopByMemory {
+		return nil, errors.New("The interval must be greater than 0")
+	}
+
+	var firstSample []PidStats
+	var firstGpu gpuSample
+	var start time.Time
+	if interval > 0 {
+		if firstSample, err = getPidStats(); err != nil {
+			return nil, err
+		}
+		if gpu {
+			firstGpu = getGpuSample(pidStatsPids(firstSample), false)
+		}
+		start = time.Now()
+		time.Sleep(interval)
+	}
+	secondSample, err := getPidStats()
+	if err != nil {
+		return nil, err
+	}
+	elapsed := time.Since(start).Seconds()
+
+	var gpus map[int]*ProcessGpu
+	if gpu {
+		secondGpu := getGpuSample(pidStatsPids(secondSample), true)
+		if interval <= 0 {
+			firstGpu = secondGpu
+		}
+		gpus = getProcessGpus(firstGpu, secondGpu)
+	}
+
+	var uptime Uptime
+	if interval <= 0 {
+		if uptime, err = getUptime(); err != nil {
+			return nil, err
+		}
+	}
+
+	type pidStart struct {
+		pid       int
+		startTime uint64
+	}
+	times := make(map[pidStart]uint64, len(firstSample))
+	for _, pidStats := range firstSample {
+		times[pidStart{pidStats.Pid, pidStats.StartTime}] = pidStats.Utime + pidStats.Stime
+	}
+
+	summaries = make([]ProcessSummary, 0, len(secondSample))
+	for _, pidStats := range secondSample {
+		cpuPer := float64(0)
+		if interval > 0 {
+			// The pids are reused, so a process is the same if it has the
+			// same start time
+			delta := pidStats.Utime + pidStats.Stime
+			if cpuTime, ok := times[pidStart{pidStats.Pid, pidStats.StartTime}]; ok && cpuTime <= delta {
+				delta -= cpuTime
+			}
+			cpuPer = 100 * float64(delta) / userHz / elapsed
+		} else if elapsed := uptime.Uptime.Seconds() - float64(pidStats.StartTime)/userHz; elapsed > 0 {
+			cpuPer = 100 * float64(pidStats.Utime+pidStats.Stime) / userHz / elapsed
+		}
+		summary := newProcessSummary(pidStats, cpuPer)
+		if gpu {
+			summary.Gpu = gpus[pidStats.Pid]
+		}
+		summaries = append(summaries, summary)
+	}
+
+	switch options.SortBy {
+	case TopByCpu:
+		sort.SliceStable(summaries, func(i, j int) bool { return summaries[i].CpuPer > summaries[j].CpuPer })
+	case TopByMemory:
+		sort.SliceStable(summaries, func(i, j int) bool { return summaries[i].Rss > summaries[j].Rss })
+	case TopByGpu:
+		// The processes that don't use a GPU aren't ranked
+		ranked := summaries[:0]
+		for _, summary := range summaries {
+			if summary.Gpu != nil {
+				ranked = append(ranked, summary)
+			}
+		}
+		summaries = ranked
+		sort.SliceStable(summaries, func(i, j int) bool {
+			if summaries[i].Gpu.Utilization != summaries[j].Gpu.Utilization {
+				return summaries[i].Gpu.Utilization > summaries[j].Gpu.Utilization
+			}
+			return summaries[i].Gpu.Memory > summaries[j].Gpu.Memory
+		})
+	}
+
+	return topProcessSummaries(summaries, n), nil
+}
+
+// pidStatsPids returns the ids of the processes of pidStatsArr.
+func pidStatsPids(pidStatsArr []PidStats) (pids []int) {
+	pids = make([]int, 0, len(pidStatsArr))
+	for _, pidStats := range pidStatsArr {
+		pids = append(pids, pidStats.Pid)
+	}
+
+	return pids
+}
+
+// newProcessSummary returns the summary of a process (without the command
+// line).
+func newProcessSummary(pidStats PidStats, cpuPer float64) ProcessSummary {
+	return ProcessSummary{
+		Pid:    pidStats.Pid,
+		Comm:   pidStats.Comm,
+		CpuPer: cpuPer,
+		Rss:    pidStats.Rss,
+	}
+}
+
+// topProcessSummaries returns the first n summaries (all of them if n <= 0)
+// with their command lines and containers, which are only read for the
+// ranked processes.
+func topProcessSummaries(summaries []ProcessSummary, n int) []ProcessSummary {
+	if n > 0 && n < len(summaries) {
+		summaries = summaries[:n]
+	}
+	for i := range summaries {
+		summaries[i].Cmdline = readCmdline(summaries[i].Pid)
+		if cgroup, err := getPidCgroup(summaries[i].Pid); err == nil {
+			summaries[i].Cgroup, summaries[i].Container = cgroup, parseContainerCgroup(cgroup)
+		}
+	}
+
+	return summaries
+}
+
+// readCmdline returns the command line of a process from /proc/[pid]/cmdline,
+// that has the arguments separated by NUL. It returns an empty string if the
+// process has exited (or it's a kernel thread).
+func readCmdline(pid int) string {
+	content, err := getProcReader().readFile(strconv.Itoa(pid), "cmdline")
+	if err != nil {
+		return ``
+	}
+
+	return strings.TrimSpace(strings.Replace(string(content), "\x00", ` `, -1))
+}
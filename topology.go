@@ -0,0 +1,118 @@
+package sysstats
+
+import (
+	"sort"
+)
+
+// TopologyChange represents a change of the CPUs, network interfaces or disks
+// of a linux system between 2 samples (CPU hot-plug, NIC renames, disk
+// hot-plug...). The rates of the affected CPU, interface or disk aren't
+// calculated for those samples: the series starts again on the next sample.
+//
+// Kind values:
+//   cpu, iface, disk.
+// Change values:
+//   added   - It's in the second sample but not in the first one (a renamed
+//             interface is removed with its old name and added with the new one).
+//   removed - It's in the first sample but not in the second one.
+//   reset   - It's in both samples but its counters went backwards (e.g. the
+//             interface was recreated or another disk got the same name).
+type TopologyChange struct {
+	Kind   string `json:"kind"`   // What changed (cpu, iface or disk)
+	Name   string `json:"name"`   // Name of the CPU, interface or disk
+	Change string `json:"change"` // Type of change (added, removed or reset)
+}
+
+// cpuRawStatsReset returns true if the counters of a CPU went backwards
+// between 2 samples.
+func cpuRawStatsReset(firstSample CpuRawStats, secondSample CpuRawStats) bool {
+	return secondSample[`total`] < firstSample[`total`]
+}
+
+// ifaceRawStatsReset returns true if any counter of a network interface was
+// reset between 2 samples (the 32-bit counters that wrapped around weren't).
+func ifaceRawStatsReset(firstSample IfaceRawStats, secondSample IfaceRawStats) bool {
+	for key, secondValue := range secondSample {
+		if key == `time` {
+			continue
+		}
+		if counterReset(firstSample[key], secondValue) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// diskRawStatsReset returns true if the 2 samples are from different devices
+// with the same name or any counter of the disk was reset (the 32-bit
+// counters that wrapped around weren't).
+func diskRawStatsReset(firstSample DiskRawStats, secondSample DiskRawStats) bool {
+	return firstSample.Major != secondSample.Major ||
+		firstSample.Minor != secondSample.Minor ||
+		counterReset(firstSample.ReadIOs, secondSample.ReadIOs) ||
+		counterReset(firstSample.ReadSectors, secondSample.ReadSectors) ||
+		counterReset(firstSample.WriteIOs, secondSample.WriteIOs) ||
+		counterReset(firstSample.WriteSectors, secondSample.WriteSectors) ||
+		counterReset(firstSample.TimeInQueue, secondSample.TimeInQueue)
+}
+
+// getTopologyChanges returns the CPUs, network interfaces and disks that
+// changed between 2 snapshots, sorted by kind and name.
+func getTopologyChanges(firstSnapshot Snapshot, secondSnapshot Snapshot) (changes []TopologyChange) {
+	changes = []TopologyChange{}
+
+	for name, secondRawStats := range secondSnapshot.Cpus {
+		if firstRawStats, ok := firstSnapshot.Cpus[name]; !ok {
+			changes = append(changes, TopologyChange{Kind: `cpu`, Name: name, Change: `added`})
+		} else if cpuRawStatsReset(firstRawStats, secondRawStats) {
+			changes = append(changes, TopologyChange{Kind: `cpu`, Name: name, Change: `reset`})
+		}
+	}
+	for name := range firstSnapshot.Cpus {
+		if _, ok := secondSnapshot.Cpus[name]; !ok {
+			changes = append(changes, TopologyChange{Kind: `cpu`, Name: name, Change: `removed`})
+		}
+	}
+
+	for name, secondRawStats := range secondSnapshot.Net {
+		if firstRawStats, ok := firstSnapshot.Net[name]; !ok {
+			changes = append(changes, TopologyChange{Kind: `iface`, Name: name, Change: `added`})
+		} else if ifaceRawStatsReset(firstRawStats, secondRawStats) {
+			changes = append(changes, TopologyChange{Kind: `iface`, Name: name, Change: `reset`})
+		}
+	}
+	for name := range firstSnapshot.Net {
+		if _, ok := secondSnapshot.Net[name]; !ok {
+			changes = append(changes, TopologyChange{Kind: `iface`, Name: name, Change: `removed`})
+		}
+	}
+
+	firstDisks := make(map[string]DiskRawStats, len(firstSnapshot.Disks))
+	for _, disk := range firstSnapshot.Disks {
+		firstDisks[disk.Name] = disk
+	}
+	secondDisks := make(map[string]DiskRawStats, len(secondSnapshot.Disks))
+	for _, disk := range secondSnapshot.Disks {
+		secondDisks[disk.Name] = disk
+		if firstDisk, ok := firstDisks[disk.Name]; !ok {
+			changes = append(changes, TopologyChange{Kind: `disk`, Name: disk.Name, Change: `added`})
+		} else if diskRawStatsReset(firstDisk, disk) {
+			changes = append(changes, TopologyChange{Kind: `disk`, Name: disk.Name, Change: `reset`})
+		}
+	}
+	for name := range firstDisks {
+		if _, ok := secondDisks[name]; !ok {
+			changes = append(changes, TopologyChange{Kind: `disk`, Name: name, Change: `removed`})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Kind != changes[j].Kind {
+			return changes[i].Kind < changes[j].Kind
+		}
+		return changes[i].Name < changes[j].Name
+	})
+
+	return changes
+}
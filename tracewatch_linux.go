@@ -0,0 +1,423 @@
+// +build linux
+
+package sysstats
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Trace event kinds
+const (
+	TraceEventBlockError = "blockerror" // A block I/O request failed
+	TraceEventTxTimeout  = "txtimeout"  // A transmit queue of a NIC timed out (the driver is reset)
+	TraceEventLinkUp     = "linkup"     // The carrier of a NIC came up
+	TraceEventLinkDown   = "linkdown"   // The carrier of a NIC went down
+)
+
+// TraceEvent represents *one* kernel tracepoint hit reported by a
+// TraceWatcher.
+type TraceEvent struct {
+	Kind      string    `json:"kind"`      // Kind of event (blockerror, txtimeout, linkup, linkdown)
+	Time      time.Time `json:"time"`      // Time of the event
+	Timestamp float64   `json:"timestamp"` // Seconds since boot
+	Device    string    `json:"device"`    // Block device or network interface (e.g. sda, eth0)
+	Driver    string    `json:"driver"`    // Driver of the network interface (txtimeout only)
+	Queue     int       `json:"queue"`     // Transmit queue that timed out (txtimeout only)
+	Op        string    `json:"op"`        // Operation of the failed request (blockerror only, e.g. W, RA, FWS)
+	Sector    uint64    `json:"sector"`    // First sector of the failed request (blockerror only)
+	Sectors   uint64    `json:"sectors"`   // # of sectors of the failed request (blockerror only)
+	Error     int       `json:"error"`     // errno of the failed request (blockerror only, e.g. -5 for EIO)
+	Message   string    `json:"message"`   // Tracepoint output
+}
+
+// TraceWatcherConfig represents the configuration of a TraceWatcher.
+type TraceWatcherConfig struct {
+	Kinds    []string // Kinds of events to watch (all by default)
+	Instance string   // Name of the tracefs instance (sysstats by default, only letters, digits and underscores). Each watcher needs its own
+}
+
+// TraceWatcher watches the kernel tracepoints of the block I/O errors and
+// the network interface problems and reports them as TraceEvents between
+// calls to Check. The counters (e.g. the ioerr_cnt of the disks, the carrier
+// changes of the interfaces) only show that something failed after the
+// fact: the events tell which request or queue failed and when. It needs the
+// tracefs (/sys/kernel/tracing) and root (or CAP_SYS_ADMIN). The events are
+// recorded in a tracefs instance of their own, so the global trace buffer
+// isn't touched:
+//   block:block_rq_error (5.18+, block:block_rq_complete with errors before)
+//   net:net_dev_xmit_timeout (5.4+)
+//   kprobes of netif_carrier_on and netif_carrier_off (CONFIG_KPROBE_EVENTS)
+// Close must be called to remove the instance and the kprobes.
+type TraceWatcher struct {
+	mu       sync.Mutex
+	dir      string // tracefs
+	instance string
+	kinds    []string
+	kprobes  []string
+	pipe     int
+	partial  []byte          // Line of trace_pipe not read completely
+	links    map[string]bool // Carrier of the interfaces (to skip the calls that don't change it)
+}
+
+// traceMountPoints are the mount points of the tracefs (or the debugfs of
+// the older systems).
+var traceMountPoints = []string{`/sys/kernel/tracing`, `/sys/kernel/debug/tracing`}
+
+// traceCarrierProbes are the kprobes of the link changes by event kind.
+var traceCarrierProbes = map[string]string{
+	TraceEventLinkUp:   `netif_carrier_on`,
+	TraceEventLinkDown: `netif_carrier_off`,
+}
+
+// NewTraceWatcher returns a TraceWatcher for the given configuration. It
+// creates its tracefs instance and enables the tracepoints of the kinds
+// available in the kernel: it returns an error if the tracefs can't be used
+// or none of them is available.
+func NewTraceWatcher(config TraceWatcherConfig) (*TraceWatcher, error) {
+	w := &TraceWatcher{instance: config.Instance, kinds: []string{}, pipe: -1, links: map[string]bool{}}
+	if w.instance == `` {
+		w.instance = `sysstats`
+	}
+	if strings.Trim(w.instance, `abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_`) != `` {
+		return nil, errors.New("The names of the tracefs instances can only have letters, digits and underscores")
+	}
+	kinds := config.Kinds
+	if len(kinds) == 0 {
+		kinds = []string{TraceEventBlockError, TraceEventTxTimeout, TraceEventLinkUp, TraceEventLinkDown}
+	}
+
+	for _, dir := range traceMountPoints {
+		if fileExists(filepath.Join(dir, `instances`)) {
+			w.dir = dir
+			break
+		}
+	}
+	if w.dir == `` {
+		return nil, errors.New("The tracefs isn't mounted (or it doesn't have instances)")
+	}
+	instanceDir := w.instanceDir()
+	if err := os.Mkdir(instanceDir, 0755); err != nil && !os.IsExist(err) {
+		return nil, err
+	}
+	// The timestamps are the seconds since boot of /proc/uptime (4.10+)
+	ioutil.WriteFile(filepath.Join(instanceDir, `trace_clock`), []byte(`boot`), 0644)
+
+	for _, kind := range kinds {
+		if err := w.enable(kind); err == nil {
+			w.kinds = append(w.kinds, kind)
+		}
+	}
+	if len(w.kinds) == 0 {
+		w.Close()
+		return nil, errors.New("None of the tracepoints of the events is available")
+	}
+
+	pipe, err := syscall.Open(filepath.Join(instanceDir, `trace_pipe`), syscall.O_RDONLY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		w.Close()
+		return nil, &os.PathError{Op: "open", Path: filepath.Join(instanceDir, `trace_pipe`), Err: err}
+	}
+	w.pipe = pipe
+
+	return w, nil
+}
+
+// instanceDir returns the directory of the tracefs instance.
+func (w *TraceWatcher) instanceDir() string {
+	return filepath.Join(w.dir, `instances`, w.instance)
+}
+
+// enable enables the tracepoint of an event kind in the instance.
+func (w *TraceWatcher) enable(kind string) error {
+	events := filepath.Join(w.instanceDir(), `events`)
+	switch kind {
+	case TraceEventBlockError:
+		if err := writeTraceFile(filepath.Join(events, `block`, `block_rq_error`, `enable`), `1`); err == nil {
+			return nil
+		}
+		if err := writeTraceFile(filepath.Join(events, `block`, `block_rq_complete`, `filter`), `error != 0`); err != nil {
+			return err
+		}
+		return writeTraceFile(filepath.Join(events, `block`, `block_rq_complete`, `enable`), `1`)
+	case TraceEventTxTimeout:
+		return writeTraceFile(filepath.Join(events, `net`, `net_dev_xmit_timeout`, `enable`), `1`)
+	case TraceEventLinkUp, TraceEventLinkDown:
+		function := traceCarrierProbes[kind]
+		// The kprobes are global: they are named after the instance
+		probe := w.instance + `/` + function
+		if err := w.addCarrierProbe(probe, function); err != nil {
+			return err
+		}
+		w.kprobes = append(w.kprobes, probe)
+		return writeTraceFile(filepath.Join(events, w.instance, function, `enable`), `1`)
+	}
+
+	return errors.New("Unknown trace event kind " + kind)
+}
+
+// addCarrierProbe adds the kprobe of a function of the carrier changes, with
+// the name of the interface of its struct net_device argument. The name is
+// fetched through the BTF of the kernel (6.6+ with CONFIG_DEBUG_INFO_BTF).
+// Without it, the name is fetched as the first field of the struct, which it
+// only is before 6.8 (the fields of net_device were reordered by their use
+// in the fast path), so the newer kernels need the BTF.
+func (w *TraceWatcher) addCarrierProbe(probe string, function string) error {
+	kprobeEvents := filepath.Join(w.dir, `kprobe_events`)
+	// A watcher that wasn't closed could have left it
+	err := appendTraceFile(kprobeEvents, `p:`+probe+` `+function+` dev=$arg1->name:string`)
+	if err == nil || os.IsExist(err) {
+		return nil
+	}
+	if !kernelBefore(6, 8) {
+		return err
+	}
+	if err := appendTraceFile(kprobeEvents, `p:`+probe+` `+function+` dev=+0($arg1):string`); err != nil && !os.IsExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// kernelBefore returns true if the version of the kernel is older than
+// major.minor. It returns false if the version can't be read.
+func kernelBefore(major int, minor int) bool {
+	release, err := getOsRelease()
+	if err != nil {
+		return false
+	}
+	// e.g. 6.8.0-45-generic
+	version := strings.SplitN(release, `.`, 3)
+	if len(version) < 2 {
+		return false
+	}
+	kernelMajor, err := strconv.Atoi(version[0])
+	if err != nil {
+		return false
+	}
+	// The minor of the release candidates has a suffix (e.g. 6.8-rc1)
+	if i := strings.IndexFunc(version[1], func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+		version[1] = version[1][:i]
+	}
+	kernelMinor, err := strconv.Atoi(version[1])
+	if err != nil {
+		return false
+	}
+
+	return kernelMajor < major || (kernelMajor == major && kernelMinor < minor)
+}
+
+// writeTraceFile writes value to a file of the tracefs.
+func writeTraceFile(path string, value string) error {
+	return ioutil.WriteFile(path, []byte(value), 0644)
+}
+
+// appendTraceFile appends a line to a file of the tracefs (writing to
+// kprobe_events without O_APPEND removes all the kprobes).
+func appendTraceFile(path string, line string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Kinds returns the kinds of events watched (the ones whose tracepoints are
+// available).
+func (w *TraceWatcher) Kinds() []string {
+	return append([]string{}, w.kinds...)
+}
+
+// Check returns the events recorded since the previous call (or since the
+// watcher was created), in order. The kernel drops the oldest events if
+// they fill the buffer of the instance before Check is called.
+func (w *TraceWatcher) Check() (events []TraceEvent, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.pipe < 0 {
+		return nil, errors.New("The trace watcher is closed")
+	}
+
+	content := w.partial
+	buf := make([]byte, 65536)
+	for {
+		n, err := syscall.Read(w.pipe, buf)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err == syscall.EAGAIN || n == 0 {
+			break
+		}
+		if err != nil {
+			return nil, &os.PathError{Op: "read", Path: filepath.Join(w.instanceDir(), `trace_pipe`), Err: err}
+		}
+		content = append(content, buf[:n]...)
+	}
+	end := strings.LastIndexByte(string(content), '\n') + 1
+	w.partial = append([]byte{}, content[end:]...)
+
+	bootTime := time.Time{}
+	if uptime, err := getUptime(); err == nil {
+		bootTime = clockNow().Add(-uptime.Uptime)
+	}
+	events = []TraceEvent{}
+	for _, line := range strings.Split(string(content[:end]), "\n") {
+		event, ok := parseTraceLine(line)
+		if !ok {
+			continue
+		}
+		if event.Kind == TraceEventLinkUp || event.Kind == TraceEventLinkDown {
+			// netif_carrier_on and netif_carrier_off are also called when
+			// the carrier doesn't change
+			up := event.Kind == TraceEventLinkUp
+			if previous, seen := w.links[event.Device]; seen && previous == up {
+				continue
+			}
+			w.links[event.Device] = up
+		}
+		if !bootTime.IsZero() {
+			event.Time = bootTime.Add(time.Duration(event.Timestamp * float64(time.Second)))
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+var (
+	// traceLineRe matches a line of trace_pipe:
+	//   kworker/1:1-63 [001] d..1. 8476.512528: block_rq_error: 8,0 W () 2048 + 8 [-5]
+	// The flags (irq-info) depend on the kernel and the options.
+	traceLineRe = regexp.MustCompile(`^\s*.+-\d+\s+(?:\(\s*\S+\)\s+)?\[\d+\]\s+(?:\S+\s+)?(\d+\.\d+): (\w+): (.*)$`)
+	// traceBlockRe matches the output of block_rq_error and
+	// block_rq_complete (the ioprio of 6.10+ is optional):
+	//   8,0 W () 2048 + 8 [-5]
+	//   8,0 W () 2048 + 8 none,0,0 [-5]
+	traceBlockRe = regexp.MustCompile(`^(\d+),(\d+) (\S*) \(.*\) (\d+) \+ (\d+)(?: \S+)? \[(-?\d+)\]`)
+	// traceTxTimeoutRe matches the output of net_dev_xmit_timeout:
+	//   dev=eth0 driver=e1000e queue=0
+	traceTxTimeoutRe = regexp.MustCompile(`^dev=(\S+) driver=(\S+) queue=(\d+)`)
+	// traceCarrierRe matches the output of the carrier kprobes:
+	//   (netif_carrier_on+0x0/0x60) dev="eth0"
+	traceCarrierRe = regexp.MustCompile(`^\((\w+)\+\S*\) dev="(.*)"`)
+)
+
+// parseTraceLine parses a line of trace_pipe into a TraceEvent. ok is false
+// if the line isn't one of the events of a TraceWatcher (or it can't be
+// parsed). The block devices are resolved to their names with
+// /sys/dev/block.
+func parseTraceLine(line string) (event TraceEvent, ok bool) {
+	match := traceLineRe.FindStringSubmatch(line)
+	if match == nil {
+		return TraceEvent{}, false
+	}
+	timestamp, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return TraceEvent{}, false
+	}
+	event = TraceEvent{Timestamp: timestamp, Message: match[3]}
+
+	switch match[2] {
+	case `block_rq_error`, `block_rq_complete`:
+		fields := traceBlockRe.FindStringSubmatch(match[3])
+		if fields == nil {
+			return TraceEvent{}, false
+		}
+		event.Kind, event.Device, event.Op = TraceEventBlockError, devNumToName(fields[1]+`:`+fields[2]), fields[3]
+		event.Sector, _ = strconv.ParseUint(fields[4], 10, 64)
+		event.Sectors, _ = strconv.ParseUint(fields[5], 10, 64)
+		event.Error, _ = strconv.Atoi(fields[6])
+		// The filter of block_rq_complete keeps only the errors, but a
+		// watcher of another instance could have enabled it unfiltered
+		if event.Error == 0 {
+			return TraceEvent{}, false
+		}
+	case `net_dev_xmit_timeout`:
+		fields := traceTxTimeoutRe.FindStringSubmatch(match[3])
+		if fields == nil {
+			return TraceEvent{}, false
+		}
+		event.Kind, event.Device, event.Driver = TraceEventTxTimeout, fields[1], fields[2]
+		event.Queue, _ = strconv.Atoi(fields[3])
+	default:
+		fields := traceCarrierRe.FindStringSubmatch(match[3])
+		if fields == nil {
+			return TraceEvent{}, false
+		}
+		for kind, function := range traceCarrierProbes {
+			if fields[1] == function {
+				event.Kind = kind
+			}
+		}
+		if event.Kind == `` {
+			return TraceEvent{}, false
+		}
+		event.Device = fields[2]
+	}
+
+	return event, true
+}
+
+// Close disables the tracepoints and removes the tracefs instance and the
+// kprobes of the watcher.
+func (w *TraceWatcher) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.pipe >= 0 {
+		syscall.Close(w.pipe)
+		w.pipe = -1
+	}
+	// Removing the instance disables its events
+	err := os.Remove(w.instanceDir())
+	if err != nil && os.IsNotExist(err) {
+		err = nil
+	}
+	for _, probe := range w.kprobes {
+		if probeErr := appendTraceFile(filepath.Join(w.dir, `kprobe_events`), `-:`+probe); probeErr != nil && err == nil {
+			err = probeErr
+		}
+	}
+	w.kprobes = nil
+
+	return err
+}
+
+// AddTraceEvents adds the events of a TraceWatcher.
+func (t *Timeline) AddTraceEvents(traceEvents []TraceEvent) {
+	events := make([]TimelineEvent, 0, len(traceEvents))
+	for _, traceEvent := range traceEvents {
+		labels := map[string]string{`device`: traceEvent.Device}
+		message := traceEvent.Device + ` ` + traceEvent.Kind
+		switch traceEvent.Kind {
+		case TraceEventBlockError:
+			labels[`op`], labels[`error`] = traceEvent.Op, strconv.Itoa(traceEvent.Error)
+			message += ` (` + traceEvent.Op + ` of sector ` + strconv.FormatUint(traceEvent.Sector, 10) + `, error ` + strconv.Itoa(traceEvent.Error) + `)`
+		case TraceEventTxTimeout:
+			labels[`driver`], labels[`queue`] = traceEvent.Driver, strconv.Itoa(traceEvent.Queue)
+			message += ` (queue ` + strconv.Itoa(traceEvent.Queue) + `)`
+		}
+		events = append(events, TimelineEvent{
+			Time:    traceEvent.Time,
+			Kind:    TimelineTrace,
+			Action:  traceEvent.Kind,
+			Message: message,
+			Labels:  labels,
+		})
+	}
+	t.Add(events...)
+}
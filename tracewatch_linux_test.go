@@ -0,0 +1,81 @@
+// +build linux
+
+package sysstats
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseTraceLine(t *testing.T) {
+	tests := []struct {
+		line  string
+		ok    bool
+		event TraceEvent
+	}{
+		{
+			`kworker/1:1-63 [001] d..1. 8476.512528: block_rq_error: 250,250 W () 2048 + 8 [-5]`, true,
+			TraceEvent{Kind: TraceEventBlockError, Timestamp: 8476.512528, Device: `250:250`, Op: `W`, Sector: 2048, Sectors: 8, Error: -5},
+		},
+		{
+			// ioprio of 6.10+
+			`  <idle>-0       [003] ..s1. 12.000001: block_rq_complete: 250,250 RA () 4096 + 256 none,0,0 [-61]`, true,
+			TraceEvent{Kind: TraceEventBlockError, Timestamp: 12.000001, Device: `250:250`, Op: `RA`, Sector: 4096, Sectors: 256, Error: -61},
+		},
+		{`kworker/1:1-63 [001] d..1. 8476.512528: block_rq_complete: 250,250 W () 2048 + 8 [0]`, false, TraceEvent{}},
+		{
+			`ksoftirqd/0-15 (     15) [000] ..s1. 901.25: net_dev_xmit_timeout: dev=eth0 driver=e1000e queue=3`, true,
+			TraceEvent{Kind: TraceEventTxTimeout, Timestamp: 901.25, Device: `eth0`, Driver: `e1000e`, Queue: 3},
+		},
+		{
+			`kworker/u8:2-301 [002] ..... 77.5: netif_carrier_off: (netif_carrier_off+0x0/0x60) dev="enp3s0"`, true,
+			TraceEvent{Kind: TraceEventLinkDown, Timestamp: 77.5, Device: `enp3s0`},
+		},
+		{`kworker/u8:2-301 [002] ..... 77.5: dev_close: (dev_close+0x0/0x60) dev="enp3s0"`, false, TraceEvent{}},
+		{`# tracer: nop`, false, TraceEvent{}},
+	}
+	for _, test := range tests {
+		event, ok := parseTraceLine(test.line)
+		if ok != test.ok {
+			t.Errorf("%s: ok %t, want %t", test.line, ok, test.ok)
+			continue
+		}
+		event.Message = ``
+		if event != test.event {
+			t.Errorf("%s: event %+v, want %+v", test.line, event, test.event)
+		}
+	}
+}
+
+func TestKernelBefore(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, `sys`, `kernel`), 0755); err != nil {
+		t.Fatal(err)
+	}
+	prevRoot := getProcRoot()
+	SetProcRoot(root)
+	defer SetProcRoot(prevRoot)
+
+	tests := []struct {
+		release string
+		before  bool
+	}{
+		{`6.7.12-arch1-1`, true},
+		{`5.15.0-122-generic`, true},
+		{`6.8.0-45-generic`, false},
+		{`6.8-rc1`, false},
+		{`6.10.3`, false},
+		{`7.0.1`, false},
+		{`unknown`, false},
+	}
+	for _, test := range tests {
+		if err := ioutil.WriteFile(filepath.Join(root, `sys`, `kernel`, `osrelease`), []byte(test.release+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if before := kernelBefore(6, 8); before != test.before {
+			t.Errorf("%s: before 6.8 %t, want %t", test.release, before, test.before)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package sysstats
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UpsConfig represents the configuration of the UPS collector.
+type UpsConfig struct {
+	Daemon  string        // Daemon to query: nut or apcupsd (default: nut, and apcupsd if nut isn't running)
+	Address string        // host:port of the daemon (default localhost:3493 for nut and localhost:3551 for apcupsd)
+	Timeout time.Duration // Time to wait for the daemon (default 5 seconds)
+}
+
+// UpsStats represents the status of *one* UPS.
+//
+// Vars map has the variables reported by the daemon as they are (e.g.
+// battery.charge for nut and BCHARGE for apcupsd).
+type UpsStats struct {
+	Name          string            `json:"name"`          // Name of the UPS
+	Daemon        string            `json:"daemon"`        // Daemon that reported it (nut or apcupsd)
+	Model         string            `json:"model"`         // Model of the UPS
+	Status        string            `json:"status"`        // Status reported by the daemon (OL CHRG, ONBATT...)
+	OnBattery     bool              `json:"onbattery"`     // Whether the UPS is running on battery
+	BatteryCharge float64           `json:"batterycharge"` // Charge of the battery (percentage)
+	Runtime       uint64            `json:"runtime"`       // Runtime remaining on battery (seconds)
+	InputVoltage  float64           `json:"inputvoltage"`  // Voltage of the line (Volts)
+	OutputVoltage float64           `json:"outputvoltage"` // Output voltage (Volts)
+	Load          float64           `json:"load"`          // Load of the UPS (percentage)
+	Vars          map[string]string `json:"vars"`          // Variables reported by the daemon
+}
+
+const (
+	upsNutAddress     = `localhost:3493`
+	upsApcupsdAddress = `localhost:3551`
+)
+
+// getUpsStats gets the status of the UPSs managed by the local NUT (upsd) or
+// apcupsd daemon. If the daemon isn't configured, it queries nut and, if it
+// isn't running, apcupsd.
+func getUpsStats(config UpsConfig) (upsStatsArr []UpsStats, err error) {
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+
+	switch config.Daemon {
+	case `nut`:
+		return getNutStats(config)
+	case `apcupsd`:
+		return getApcupsdStats(config)
+	case ``:
+		upsStatsArr, err = getNutStats(config)
+		if _, ok := err.(*net.OpError); ok && config.Address == `` {
+			return getApcupsdStats(config)
+		}
+		return upsStatsArr, err
+	}
+
+	return nil, errors.New("Unknown UPS daemon: " + config.Daemon)
+}
+
+// getNutStats gets the status of the UPSs of a NUT daemon with the commands of
+// the network protocol:
+//   LIST UPS
+//   LIST VAR <ups>
+func getNutStats(config UpsConfig) (upsStatsArr []UpsStats, err error) {
+	address := config.Address
+	if address == `` {
+		address = upsNutAddress
+	}
+	conn, err := net.DialTimeout("tcp", address, config.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(config.Timeout))
+
+	reader := bufio.NewReader(conn)
+	upsList, err := nutList(conn, reader, `UPS`)
+	if err != nil {
+		return nil, err
+	}
+
+	upsStatsArr = make([]UpsStats, 0, len(upsList))
+	for _, ups := range upsList {
+		name := strings.Fields(ups)[0]
+		vars, err := nutList(conn, reader, `VAR `+name)
+		if err != nil {
+			return nil, err
+		}
+		upsStats := UpsStats{Name: name, Daemon: `nut`, Vars: map[string]string{}}
+		for _, v := range vars {
+			// battery.charge "100"
+			fields := strings.SplitN(strings.TrimPrefix(v, name+` `), ` `, 2)
+			if len(fields) != 2 {
+				continue
+			}
+			upsStats.Vars[fields[0]] = strings.Trim(fields[1], `"`)
+		}
+		setNutStats(&upsStats)
+		upsStatsArr = append(upsStatsArr, upsStats)
+	}
+
+	return upsStatsArr, nil
+}
+
+// nutList sends the command LIST <query> to a NUT daemon and returns the
+// lines of the answer without the BEGIN and END lines and the type of the
+// list, e.g. for LIST VAR myups:
+//   BEGIN LIST VAR myups
+//   VAR myups battery.charge "100"
+//   END LIST VAR myups
+// it returns `myups battery.charge "100"`.
+func nutList(conn net.Conn, reader *bufio.Reader, query string) (lines []string, err error) {
+	if _, err = io.WriteString(conn, `LIST `+query+"\n"); err != nil {
+		return nil, err
+	}
+
+	query = strings.Fields(query)[0]
+	lines = make([]string, 0, 32)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case strings.HasPrefix(line, `ERR `):
+			return nil, errors.New("nut: " + strings.TrimPrefix(line, `ERR `))
+		case strings.HasPrefix(line, `BEGIN LIST `):
+		case strings.HasPrefix(line, `END LIST `):
+			return lines, nil
+		case strings.HasPrefix(line, query+` `):
+			lines = append(lines, strings.TrimPrefix(line, query+` `))
+		}
+	}
+}
+
+// setNutStats sets the status fields of upsStats from the NUT variables.
+func setNutStats(upsStats *UpsStats) {
+	upsStats.Model = upsStats.Vars[`ups.model`]
+	if upsStats.Model == `` {
+		upsStats.Model = upsStats.Vars[`device.model`]
+	}
+	upsStats.Status = upsStats.Vars[`ups.status`]
+	for _, flag := range strings.Fields(upsStats.Status) {
+		if flag == `OB` {
+			upsStats.OnBattery = true
+		}
+	}
+	upsStats.BatteryCharge = upsFloat(upsStats.Vars[`battery.charge`])
+	upsStats.Runtime = uint64(upsFloat(upsStats.Vars[`battery.runtime`]))
+	upsStats.InputVoltage = upsFloat(upsStats.Vars[`input.voltage`])
+	upsStats.OutputVoltage = upsFloat(upsStats.Vars[`output.voltage`])
+	upsStats.Load = upsFloat(upsStats.Vars[`ups.load`])
+}
+
+// getApcupsdStats gets the status of the UPS of an apcupsd daemon with the
+// status command of its network information server (NIS). The messages of
+// NIS are prefixed with their length (2 bytes, big endian) and the answer
+// ends with an empty message.
+func getApcupsdStats(config UpsConfig) (upsStatsArr []UpsStats, err error) {
+	address := config.Address
+	if address == `` {
+		address = upsApcupsdAddress
+	}
+	conn, err := net.DialTimeout("tcp", address, config.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(config.Timeout))
+
+	command := []byte(`status`)
+	msg := make([]byte, 2, 2+len(command))
+	binary.BigEndian.PutUint16(msg, uint16(len(command)))
+	if _, err = conn.Write(append(msg, command...)); err != nil {
+		return nil, err
+	}
+
+	upsStats := UpsStats{Daemon: `apcupsd`, Vars: map[string]string{}}
+	reader := bufio.NewReader(conn)
+	for {
+		var size uint16
+		if err = binary.Read(reader, binary.BigEndian, &size); err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			break
+		}
+		line := make([]byte, size)
+		if _, err = io.ReadFull(reader, line); err != nil {
+			return nil, err
+		}
+		// BCHARGE  : 100.0 Percent
+		fields := strings.SplitN(string(line), `:`, 2)
+		if len(fields) != 2 {
+			continue
+		}
+		upsStats.Vars[strings.TrimSpace(fields[0])] = strings.TrimSpace(fields[1])
+	}
+	setApcupsdStats(&upsStats)
+
+	return []UpsStats{upsStats}, nil
+}
+
+// setApcupsdStats sets the status fields of upsStats from the apcupsd
+// variables, that have the unit after the value (45.0 Minutes).
+func setApcupsdStats(upsStats *UpsStats) {
+	upsStats.Name = upsStats.Vars[`UPSNAME`]
+	upsStats.Model = upsStats.Vars[`MODEL`]
+	upsStats.Status = upsStats.Vars[`STATUS`]
+	for _, flag := range strings.Fields(upsStats.Status) {
+		if flag == `ONBATT` {
+			upsStats.OnBattery = true
+		}
+	}
+	upsStats.BatteryCharge = upsFloat(upsStats.Vars[`BCHARGE`])
+	upsStats.Runtime = uint64(upsFloat(upsStats.Vars[`TIMELEFT`]) * 60)
+	upsStats.InputVoltage = upsFloat(upsStats.Vars[`LINEV`])
+	upsStats.OutputVoltage = upsFloat(upsStats.Vars[`OUTPUTV`])
+	upsStats.Load = upsFloat(upsStats.Vars[`LOADPCT`])
+}
+
+// upsFloat returns the number at the start of value (0 if there isn't one).
+func upsFloat(value string) float64 {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return 0
+	}
+	f, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0
+	}
+
+	return f
+}
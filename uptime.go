@@ -0,0 +1,12 @@
+package sysstats
+
+import (
+	"time"
+)
+
+// Uptime represents the uptime of the system. Idle is only reported by linux
+// (it's 0 on the other systems).
+type Uptime struct {
+	Uptime time.Duration `json:"uptime"` // Time since the system booted (including the time suspended)
+	Idle   time.Duration `json:"idle"`   // Time spent idle (the sum of all the CPUs, so it can be greater than Uptime)
+}
@@ -0,0 +1,35 @@
+// +build linux
+
+package sysstats
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getUptime gets the uptime of a linux system from the file /proc/uptime,
+// that has the following format:
+//   350735.47 234388.90
+func getUptime() (uptime Uptime, err error) {
+	content, err := getProcReader().readFile("uptime")
+	if err != nil {
+		return Uptime{}, err
+	}
+
+	fields := strings.Fields(string(content))
+	if len(fields) != 2 {
+		return Uptime{}, errors.New("Error parsing /proc/uptime. It should have 2 fields")
+	}
+
+	for i, value := range []*time.Duration{&uptime.Uptime, &uptime.Idle} {
+		seconds, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return Uptime{}, err
+		}
+		*value = time.Duration(seconds * float64(time.Second))
+	}
+
+	return uptime, nil
+}
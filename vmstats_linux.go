@@ -0,0 +1,158 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VmRawStats represents the virtual memory and scheduler counters of a linux
+// system (counted since boot, except ProcsRunning and ProcsBlocked).
+type VmRawStats struct {
+	PgpgIn       uint64 `json:"pgpgin"`       // Kilobytes paged in from disk
+	PgpgOut      uint64 `json:"pgpgout"`      // Kilobytes paged out to disk
+	PswpIn       uint64 `json:"pswpin"`       // Pages swapped in
+	PswpOut      uint64 `json:"pswpout"`      // Pages swapped out
+	PgFault      uint64 `json:"pgfault"`      // Page faults (minor and major)
+	PgMajFault   uint64 `json:"pgmajfault"`   // Major page faults (that needed I/O)
+	Ctxt         uint64 `json:"ctxt"`         // Context switches
+	Intr         uint64 `json:"intr"`         // Interrupts serviced
+	Processes    uint64 `json:"processes"`    // Forks
+	ProcsRunning uint64 `json:"procsrunning"` // # of processes in runnable state
+	ProcsBlocked uint64 `json:"procsblocked"` // # of processes blocked waiting for I/O
+	Time         int64  `json:"time"`         // Time when the sample was taken (Unix time)
+}
+
+// VmAvgStats represents the virtual memory and scheduler statistics per
+// second between 2 VmRawStats samples.
+type VmAvgStats struct {
+	PgpgIn       float64 `json:"pgpgin"`       // Kilobytes paged in per second
+	PgpgOut      float64 `json:"pgpgout"`      // Kilobytes paged out per second
+	PswpIn       float64 `json:"pswpin"`       // Pages swapped in per second
+	PswpOut      float64 `json:"pswpout"`      // Pages swapped out per second
+	PgFault      float64 `json:"pgfault"`      // Page faults per second
+	PgMajFault   float64 `json:"pgmajfault"`   // Major page faults per second
+	Ctxt         float64 `json:"ctxt"`         // Context switches per second
+	Intr         float64 `json:"intr"`         // Interrupts per second
+	Forks        float64 `json:"forks"`        // Forks per second
+	ProcsRunning uint64  `json:"procsrunning"` // # of processes in runnable state
+	ProcsBlocked uint64  `json:"procsblocked"` // # of processes blocked waiting for I/O
+}
+
+// getVmRawStats gets the virtual memory and scheduler counters of a linux
+// system from the files /proc/vmstat and /proc/stat.
+func getVmRawStats() (vmRawStats VmRawStats, err error) {
+	now := clockNow().Unix()
+
+	vmstat, err := readProcFile("vmstat")
+	if err != nil {
+		return VmRawStats{}, err
+	}
+	stat, err := readProcFile("stat")
+	if err != nil {
+		return VmRawStats{}, err
+	}
+
+	vmRawStats.Time = now
+	err = parseVmCounters(vmstat, map[string]*uint64{
+		`pgpgin`:     &vmRawStats.PgpgIn,
+		`pgpgout`:    &vmRawStats.PgpgOut,
+		`pswpin`:     &vmRawStats.PswpIn,
+		`pswpout`:    &vmRawStats.PswpOut,
+		`pgfault`:    &vmRawStats.PgFault,
+		`pgmajfault`: &vmRawStats.PgMajFault,
+	})
+	if err != nil {
+		return VmRawStats{}, err
+	}
+	err = parseVmCounters(stat, map[string]*uint64{
+		`ctxt`:          &vmRawStats.Ctxt,
+		`intr`:          &vmRawStats.Intr,
+		`processes`:     &vmRawStats.Processes,
+		`procs_running`: &vmRawStats.ProcsRunning,
+		`procs_blocked`: &vmRawStats.ProcsBlocked,
+	})
+	if err != nil {
+		return VmRawStats{}, err
+	}
+
+	return vmRawStats, nil
+}
+
+// parseVmCounters parses the counters of the content of /proc/vmstat or
+// /proc/stat, that have one counter per line:
+//   pgpgin 1255464
+//   pgmajfault 5316
+//   ctxt 2125313923
+//   intr 1244742074 37 9 0 0 ...
+// The lines of intr have the # of interrupts of each one after the total,
+// which are ignored.
+func parseVmCounters(content []byte, counters map[string]*uint64) (err error) {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		if value, ok := counters[fields[0]]; ok {
+			if *value, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// getVmAvgStats calculates the statistics per second between 2 VmRawStats
+// samples.
+func getVmAvgStats(firstSample VmRawStats, secondSample VmRawStats) (vmAvgStats VmAvgStats, err error) {
+	timeDelta := float64(secondSample.Time - firstSample.Time)
+
+	rate := func(first uint64, second uint64) float64 {
+		if timeDelta <= 0 || second < first {
+			return 0
+		}
+		return float64(second-first) / timeDelta
+	}
+
+	vmAvgStats = VmAvgStats{
+		PgpgIn:     rate(firstSample.PgpgIn, secondSample.PgpgIn),
+		PgpgOut:    rate(firstSample.PgpgOut, secondSample.PgpgOut),
+		PswpIn:     rate(firstSample.PswpIn, secondSample.PswpIn),
+		PswpOut:    rate(firstSample.PswpOut, secondSample.PswpOut),
+		PgFault:    rate(firstSample.PgFault, secondSample.PgFault),
+		PgMajFault: rate(firstSample.PgMajFault, secondSample.PgMajFault),
+		Ctxt:       rate(firstSample.Ctxt, secondSample.Ctxt),
+		Intr:       rate(firstSample.Intr, secondSample.Intr),
+		Forks:      rate(firstSample.Processes, secondSample.Processes),
+		// They are "current" values (not counted since boot)
+		ProcsRunning: secondSample.ProcsRunning,
+		ProcsBlocked: secondSample.ProcsBlocked,
+	}
+
+	return vmAvgStats, nil
+}
+
+// getVmStatsInterval returns the virtual memory and scheduler statistics
+// between 2 samples. Time interval between the 2 samples is given in seconds
+func getVmStatsInterval(interval int64) (vmAvgStats VmAvgStats, err error) {
+	firstSample, err := getVmRawStats()
+	if err != nil {
+		return VmAvgStats{}, err
+	}
+
+	time.Sleep(time.Duration(interval) * time.Second)
+
+	secondSample, err := getVmRawStats()
+	if err != nil {
+		return VmAvgStats{}, err
+	}
+
+	return getVmAvgStats(firstSample, secondSample)
+}
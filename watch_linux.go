@@ -0,0 +1,174 @@
+// +build linux
+
+package sysstats
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// WatchFlags represents the statistics sampled by Watch.
+type WatchFlags uint
+
+const (
+	WatchCpu   WatchFlags = 1 << iota // % CPU usage
+	WatchMem                          // Memory statistics
+	WatchNet                          // Network interfaces traffic
+	WatchDisk                         // Disk IO
+	WatchProcs                        // Processes statistics
+	WatchLoad                         // Load average and uptime
+	WatchAll   = WatchCpu | WatchMem | WatchNet | WatchDisk | WatchProcs | WatchLoad
+)
+
+// WatchSample represents the statistics of *one* interval sampled by Watch.
+// Only the statistics requested are set, the others are nil.
+type WatchSample struct {
+	Time            time.Time             `json:"time"`            // When the sample was taken
+	Interval        time.Duration         `json:"interval"`        // Time since the previous sample (monotonic)
+	Resumed         bool                  `json:"resumed"`         // Whether the system was suspended (and resumed) during the interval
+	Cpus            map[string]CpuUsage   `json:"cpus"`            // % CPU usage
+	Mem             *MemInfo              `json:"mem"`             // Memory statistics at the time of the sample
+	Net             map[string]IfaceRates `json:"net"`             // Network stats (per second)
+	Disks           []DiskAvgStats        `json:"disks"`           // Disk IO stats (per second)
+	Procs           *ProcAvgStats         `json:"procs"`           // Processes stats
+	Load            *LoadAvg              `json:"load"`            // Load average at the time of the sample
+	Uptime          *Uptime               `json:"uptime"`          // Uptime at the time of the sample
+	TopologyChanges []TopologyChange      `json:"topologychanges"` // CPUs, interfaces and disks that changed during the interval (their stats aren't calculated)
+	Error           string                `json:"error"`           // Error reading the sample (empty if it succeeded)
+}
+
+// watch samples the statistics of flags every interval until ctx is done.
+// The rates are calculated between consecutive snapshots, so the CPUs,
+// interfaces and disks whose counters went backwards (or that were added or
+// removed) are skipped in that interval. The channel is closed when ctx is
+// done. If the receiver is slower than interval the ticks in between are
+// skipped.
+func watch(ctx context.Context, interval time.Duration, flags WatchFlags) (<-chan WatchSample, error) {
+	if interval < time.Second {
+		return nil, errors.New("The watch interval should be at least 1 second")
+	}
+	if flags&WatchAll == 0 {
+		return nil, errors.New("The watch flags should have at least one statistic")
+	}
+
+	prev, err := getWatchSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make(chan WatchSample)
+	go func() {
+		defer close(samples)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			sample := WatchSample{}
+			snapshot, err := getWatchSnapshot()
+			if err != nil {
+				sample.Time = clockNow()
+				sample.Error = err.Error()
+			} else if snapshot.Timestamp.Sub(prev.Timestamp) < time.Second {
+				// The tick came early (the rates need 1 second at least):
+				// the next sample covers this interval too
+				continue
+			} else {
+				sample = newWatchSample(prev, snapshot, flags)
+				// Keep the previous snapshot if the rates couldn't be
+				// calculated, so the next sample covers both intervals
+				if sample.Error == `` {
+					prev = snapshot
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case samples <- sample:
+			}
+		}
+	}()
+
+	return samples, nil
+}
+
+// getWatchSnapshot takes a snapshot without the loop and ram devices, like
+// GetDiskRawStats.
+func getWatchSnapshot() (snapshot Snapshot, err error) {
+	if snapshot, err = getSnapshot(); err != nil {
+		return Snapshot{}, err
+	}
+	snapshot.Disks = filterDiskRawStats(snapshot.Disks, DiskFilter{})
+
+	return snapshot, nil
+}
+
+// newWatchSample returns the sample of flags between 2 snapshots. The
+// errors of the collectors are joined in the Error of the sample.
+func newWatchSample(prev Snapshot, snapshot Snapshot, flags WatchFlags) (sample WatchSample) {
+	sample, errs, err := collectWatchSample(prev, snapshot, flags)
+	if err != nil {
+		sample.Error = err.Error()
+	} else if len(errs) > 0 {
+		sample.Error = errs.Error()
+	}
+
+	return sample
+}
+
+// collectWatchSample returns the sample of flags between 2 snapshots, with
+// the statistics of the collectors that succeeded and a FieldError for each
+// one that failed (mem or load). It returns an error if the rates between
+// the snapshots can't be calculated.
+func collectWatchSample(prev Snapshot, snapshot Snapshot, flags WatchFlags) (sample WatchSample, errs MultiError, err error) {
+	sample.Time = snapshot.CollectedAt.Wall
+
+	avgStats, err := getSnapshotAvgStats(prev, snapshot)
+	if err != nil {
+		return sample, nil, err
+	}
+	sample.Interval = avgStats.Interval
+	sample.Resumed = avgStats.Resumed
+	sample.TopologyChanges = avgStats.TopologyChanges
+
+	if flags&WatchCpu != 0 {
+		sample.Cpus = avgStats.Cpus.Usage()
+	}
+	if flags&WatchNet != 0 {
+		sample.Net = avgStats.Net.Rates()
+	}
+	if flags&WatchDisk != 0 {
+		sample.Disks = avgStats.Disks
+	}
+	if flags&WatchProcs != 0 {
+		sample.Procs = &avgStats.Procs
+	}
+	if flags&WatchMem != 0 {
+		if memInfo, err := getMemInfo(); err != nil {
+			errs = append(errs, &FieldError{Field: `mem`, Err: err})
+		} else {
+			sample.Mem = &memInfo
+		}
+	}
+	if flags&WatchLoad != 0 {
+		if loadAvg, err := getLoadAvg(); err != nil {
+			errs = append(errs, &FieldError{Field: `load`, Err: err})
+		} else {
+			sample.Load = &loadAvg
+		}
+		if uptime, err := getUptime(); err != nil {
+			errs = append(errs, &FieldError{Field: `uptime`, Err: err})
+		} else {
+			sample.Uptime = &uptime
+		}
+	}
+
+	return sample, errs, nil
+}
@@ -0,0 +1,102 @@
+// +build linux
+
+package sysstats
+
+import (
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Watchdog represents *one* watchdog device of the kernel. The timeouts are 0
+// if the driver doesn't report them.
+type Watchdog struct {
+	Name          string   `json:"name"`          // Name of the device (watchdog0...)
+	Identity      string   `json:"identity"`      // Identity of the driver (iTCO_wdt, Software Watchdog...)
+	Software      bool     `json:"software"`      // Whether it's the software watchdog (softdog)
+	Active        bool     `json:"active"`        // Whether the watchdog is armed (a process opened the device)
+	NoWayOut      bool     `json:"nowayout"`      // Whether the watchdog can't be stopped once it's armed
+	Timeout       uint64   `json:"timeout"`       // Timeout (seconds)
+	TimeLeft      uint64   `json:"timeleft"`      // Seconds left until the system is reset
+	Pretimeout    uint64   `json:"pretimeout"`    // Seconds before the timeout the pretimeout governor is notified
+	BootStatus    uint64   `json:"bootstatus"`    // Status at boot (WDIOF_* flags)
+	WatchdogReset bool     `json:"watchdogreset"` // Whether the last boot was caused by the watchdog
+	BootReasons   []string `json:"bootreasons"`   // Reasons of the boot status (cardreset, overheat...)
+}
+
+// watchdogDir is the directory of the watchdog devices in sysfs.
+const watchdogDir = "/sys/class/watchdog"
+
+// wdiofCardReset is the WDIOF_* flag of the boot status that shows that the
+// last reboot was caused by the watchdog.
+const wdiofCardReset = 0x0020
+
+// watchdogBootReasons are the names of the WDIOF_* flags of the boot status.
+var watchdogBootReasons = []struct {
+	flag   uint64
+	reason string
+}{
+	{0x0001, `overheat`},
+	{0x0002, `fanfault`},
+	{0x0004, `extern1`},
+	{0x0008, `extern2`},
+	{0x0010, `powerunder`},
+	{wdiofCardReset, `cardreset`},
+	{0x0040, `powerover`},
+}
+
+// getWatchdogs gets the watchdog devices of a linux system from
+// /sys/class/watchdog. The kernels older than 4.6 don't have the sysfs
+// attributes of the devices, so only the names are returned.
+func getWatchdogs() (watchdogs []Watchdog, err error) {
+	watchdogDirs, err := filepath.Glob(filepath.Join(watchdogDir, "watchdog[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+
+	watchdogs = make([]Watchdog, 0, len(watchdogDirs))
+	for _, dir := range watchdogDirs {
+		watchdog := Watchdog{Name: filepath.Base(dir), BootReasons: []string{}}
+		watchdog.Identity, _ = readStringFile(filepath.Join(dir, "identity"))
+		watchdog.Software = watchdog.Identity == `Software Watchdog`
+		if state, err := readStringFile(filepath.Join(dir, "state")); err == nil {
+			watchdog.Active = state == `active`
+		}
+		if nowayout, err := readUintFile(filepath.Join(dir, "nowayout")); err == nil {
+			watchdog.NoWayOut = nowayout != 0
+		}
+		watchdog.Timeout, _ = readUintFile(filepath.Join(dir, "timeout"))
+		watchdog.TimeLeft, _ = readUintFile(filepath.Join(dir, "timeleft"))
+		watchdog.Pretimeout, _ = readUintFile(filepath.Join(dir, "pretimeout"))
+		watchdog.BootStatus, _ = readWatchdogFlags(filepath.Join(dir, "bootstatus"))
+		watchdog.WatchdogReset = watchdog.BootStatus&wdiofCardReset != 0
+		for _, bootReason := range watchdogBootReasons {
+			if watchdog.BootStatus&bootReason.flag != 0 {
+				watchdog.BootReasons = append(watchdog.BootReasons, bootReason.reason)
+			}
+		}
+
+		watchdogs = append(watchdogs, watchdog)
+	}
+	sort.Slice(watchdogs, func(i, j int) bool { return watchdogNumber(watchdogs[i]) < watchdogNumber(watchdogs[j]) })
+
+	return watchdogs, nil
+}
+
+// readWatchdogFlags reads a file of flags of a watchdog, that can be decimal
+// or hexadecimal (0x20).
+func readWatchdogFlags(path string) (flags uint64, err error) {
+	content, err := readStringFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(content, 0, 64)
+}
+
+// watchdogNumber returns the number of a watchdog (1 for watchdog1).
+func watchdogNumber(watchdog Watchdog) int {
+	number, _ := strconv.Atoi(strings.TrimPrefix(watchdog.Name, "watchdog"))
+	return number
+}
@@ -0,0 +1,101 @@
+// +build linux
+
+package sysstats
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WireGuardPeer represents the statistics of *one* WireGuard peer.
+type WireGuardPeer struct {
+	PublicKey           string   `json:"publickey"`           // Public key of the peer
+	Endpoint            string   `json:"endpoint"`            // Current endpoint (ip:port) of the peer
+	AllowedIPs          []string `json:"allowedips"`          // Allowed IPs of the peer
+	LatestHandshake     int64    `json:"latesthandshake"`     // Time of the latest handshake (Unix time, 0 if never)
+	HandshakeAge        float64  `json:"handshakeage"`        // Seconds since the latest handshake (-1 if never)
+	RxBytes             uint64   `json:"rxbytes"`             // # of bytes received from the peer
+	TxBytes             uint64   `json:"txbytes"`             // # of bytes transmitted to the peer
+	PersistentKeepalive uint64   `json:"persistentkeepalive"` // Persistent keepalive interval in seconds (0 if off)
+}
+
+// WireGuardStats represents the peers statistics of *all* the WireGuard
+// interfaces of a linux system.
+//
+// Map keys:
+//   Name - name of the WireGuard interface (wg0, wg1...)
+type WireGuardStats map[string][]WireGuardPeer
+
+// getWireGuardStats gets the WireGuard peers statistics of a linux system
+// running the command:
+//   wg show all dump
+func getWireGuardStats() (wireGuardStats WireGuardStats, err error) {
+	wg, err := exec.LookPath("wg")
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := exec.Command(wg, "show", "all", "dump").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseWireGuardDump(out, time.Now().Unix())
+}
+
+// parseWireGuardDump parses the output of `wg show all dump`. Each line is
+// tab separated and is either an interface (5 fields):
+//   wg0 <private-key> <public-key> 51820 off
+// or a peer (9 fields):
+//   wg0 <public-key> (none) 10.0.0.2:51820 10.10.0.2/32 1577836800 1024 2048 25
+func parseWireGuardDump(out []byte, now int64) (wireGuardStats WireGuardStats, err error) {
+	wireGuardStats = WireGuardStats{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		switch len(fields) {
+		case 5:
+			// Interface line
+			if _, ok := wireGuardStats[fields[0]]; !ok {
+				wireGuardStats[fields[0]] = []WireGuardPeer{}
+			}
+		case 9:
+			peer := WireGuardPeer{PublicKey: fields[1], HandshakeAge: -1}
+			if fields[3] != `(none)` {
+				peer.Endpoint = fields[3]
+			}
+			if fields[4] != `(none)` {
+				peer.AllowedIPs = strings.Split(fields[4], `,`)
+			}
+			if peer.LatestHandshake, err = strconv.ParseInt(fields[5], 10, 64); err != nil {
+				return nil, err
+			}
+			if peer.LatestHandshake > 0 {
+				peer.HandshakeAge = float64(now - peer.LatestHandshake)
+			}
+			if peer.RxBytes, err = strconv.ParseUint(fields[6], 10, 64); err != nil {
+				return nil, err
+			}
+			if peer.TxBytes, err = strconv.ParseUint(fields[7], 10, 64); err != nil {
+				return nil, err
+			}
+			if fields[8] != `off` {
+				if peer.PersistentKeepalive, err = strconv.ParseUint(fields[8], 10, 64); err != nil {
+					return nil, err
+				}
+			}
+			wireGuardStats[fields[0]] = append(wireGuardStats[fields[0]], peer)
+		default:
+			return nil, errors.New("Couldn't parse the output of wg show all dump: unexpected # of fields")
+		}
+	}
+
+	return wireGuardStats, nil
+}
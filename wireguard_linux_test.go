@@ -0,0 +1,62 @@
+// +build linux
+
+package sysstats
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// wgDump is the output of `wg show all dump` of a host with 2 interfaces: wg0
+// with a peer that has never connected (no endpoint nor handshake) and one
+// that has, and wg1 without peers.
+var wgDump = strings.Join([]string{
+	"wg0\tyAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=\tHIgo9xNzJMWLKASShiTqIybxZ0U3wGLiUeJ1PKf8ykw=\t51820\toff",
+	"wg0\txTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=\t(none)\t(none)\t10.10.0.2/32\t0\t0\t0\toff",
+	"wg0\tTrMvSoP4jYQlY6RIzBgbssQqY3vxI2Pi+y71lOWWXX0=\t(none)\t192.95.5.69:41414\t10.10.0.3/32,fd00::3/128\t1760522400\t6015612\t1047664\t25",
+	"wg1\tGFZsgo9ESPwp9dhe8gjzrmqGsk/fF5+9w1BKIIAbJVY=\tS5IfFY6l4dSGMBiFQpe6Ymv4KPzx2VoDp1BJMRhTTXw=\t51821\t0xca6c",
+}, "\n") + "\n"
+
+func TestParseWireGuardDump(t *testing.T) {
+	wireGuardStats, err := parseWireGuardDump([]byte(wgDump), 1760522460)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := WireGuardStats{
+		`wg0`: {
+			{PublicKey: `xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=`, HandshakeAge: -1, AllowedIPs: []string{`10.10.0.2/32`}},
+			{
+				PublicKey:           `TrMvSoP4jYQlY6RIzBgbssQqY3vxI2Pi+y71lOWWXX0=`,
+				Endpoint:            `192.95.5.69:41414`,
+				AllowedIPs:          []string{`10.10.0.3/32`, `fd00::3/128`},
+				LatestHandshake:     1760522400,
+				HandshakeAge:        60,
+				RxBytes:             6015612,
+				TxBytes:             1047664,
+				PersistentKeepalive: 25,
+			},
+		},
+		`wg1`: {},
+	}
+	if !reflect.DeepEqual(wireGuardStats, want) {
+		t.Errorf("Stats\n%+v\nwant\n%+v", wireGuardStats, want)
+	}
+}
+
+func TestParseWireGuardDumpErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		dump string
+	}{
+		{`truncated peer`, "wg0\tkey\t(none)\t(none)\t10.10.0.2/32\t0\t0"},
+		{`bad handshake`, "wg0\tkey\t(none)\t(none)\t10.10.0.2/32\tnever\t0\t0\toff"},
+		{`bad bytes`, "wg0\tkey\t(none)\t(none)\t10.10.0.2/32\t0\t-1\t0\toff"},
+		{`bad keepalive`, "wg0\tkey\t(none)\t(none)\t10.10.0.2/32\t0\t0\t0\tevery"},
+	}
+	for _, test := range tests {
+		if wireGuardStats, err := parseWireGuardDump([]byte(test.dump), 0); err == nil {
+			t.Errorf("%s: stats %v without error", test.name, wireGuardStats)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// +build linux
+
+package sysstats
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ZombieParent represents *one* process with zombie children it isn't
+// reaping (i.e. it doesn't call wait on them).
+type ZombieParent struct {
+	Pid         int       `json:"pid"`         // Process id of the parent
+	Comm        string    `json:"comm"`        // Command name of the parent
+	Zombies     uint64    `json:"zombies"`     // # of zombie children
+	OldestSince time.Time `json:"oldestsince"` // First time the oldest zombie child was seen
+	Pids        []int     `json:"pids"`        // Ids of the zombie children
+}
+
+// ZombieReport represents the zombie and orphan processes of a linux system.
+//
+// An orphan is a process that has been reparented to init (its parent exited
+// before it), e.g. a background job of a closed shell. Processes that are
+// session leaders (daemons) aren't considered orphans.
+type ZombieReport struct {
+	Zombies    uint64         `json:"zombies"`    // # of zombie processes
+	Orphans    uint64         `json:"orphans"`    // # of orphan processes
+	NewZombies uint64         `json:"newzombies"` // # of zombies that appeared since the previous check
+	Reaped     uint64         `json:"reaped"`     // # of zombies reaped since the previous check
+	MaxZombies uint64         `json:"maxzombies"` // Max # of zombies seen by the tracker
+	Parents    []ZombieParent `json:"parents"`    // Parents with zombie children (most zombies first)
+	OrphanPids []int          `json:"orphanpids"` // Ids of the orphan processes
+}
+
+// ZombieTracker tracks the zombie processes between calls to Check, so it
+// can report how long the zombies have been around and how many of them are
+// created and reaped.
+type ZombieTracker struct {
+	mu         sync.Mutex
+	since      map[taskKey]time.Time
+	maxZombies uint64
+}
+
+// NewZombieTracker returns a ZombieTracker.
+func NewZombieTracker() *ZombieTracker {
+	return &ZombieTracker{since: map[taskKey]time.Time{}}
+}
+
+// Check returns the zombie and orphan processes of a linux system, got from
+// the files /proc/[pid]/stat.
+func (t *ZombieTracker) Check() (report ZombieReport, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pidStatsArr, err := getPidStats()
+	if err != nil {
+		return ZombieReport{}, err
+	}
+
+	comms := make(map[int]string, len(pidStatsArr))
+	for _, pidStats := range pidStatsArr {
+		comms[pidStats.Pid] = pidStats.Comm
+	}
+
+	now := time.Now()
+	since := map[taskKey]time.Time{}
+	parents := map[int]*ZombieParent{}
+	report = ZombieReport{Parents: []ZombieParent{}, OrphanPids: []int{}}
+	for _, pidStats := range pidStatsArr {
+		if pidStats.State != `Z` {
+			if pidStats.Ppid == 1 && pidStats.Pid != pidStats.Sid {
+				report.Orphans++
+				report.OrphanPids = append(report.OrphanPids, pidStats.Pid)
+			}
+			continue
+		}
+
+		report.Zombies++
+		key := taskKey{id: pidStats.Pid, startTime: pidStats.StartTime}
+		if first, ok := t.since[key]; ok {
+			since[key] = first
+		} else {
+			since[key] = now
+			report.NewZombies++
+		}
+
+		parent, ok := parents[pidStats.Ppid]
+		if !ok {
+			parent = &ZombieParent{Pid: pidStats.Ppid, Comm: comms[pidStats.Ppid], OldestSince: since[key], Pids: []int{}}
+			parents[pidStats.Ppid] = parent
+		}
+		parent.Zombies++
+		parent.Pids = append(parent.Pids, pidStats.Pid)
+		if since[key].Before(parent.OldestSince) {
+			parent.OldestSince = since[key]
+		}
+	}
+
+	// The zombies of the previous check that are gone were reaped
+	for key := range t.since {
+		if _, ok := since[key]; !ok {
+			report.Reaped++
+		}
+	}
+	t.since = since
+	if report.Zombies > t.maxZombies {
+		t.maxZombies = report.Zombies
+	}
+	report.MaxZombies = t.maxZombies
+
+	for _, parent := range parents {
+		report.Parents = append(report.Parents, *parent)
+	}
+	sort.Slice(report.Parents, func(i, j int) bool {
+		if report.Parents[i].Zombies != report.Parents[j].Zombies {
+			return report.Parents[i].Zombies > report.Parents[j].Zombies
+		}
+		return report.Parents[i].Pid < report.Parents[j].Pid
+	})
+
+	return report, nil
+}